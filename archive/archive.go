@@ -0,0 +1,206 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/codec"
+	"github.com/0xPolygonID/refresh-service/lock"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/pkg/errors"
+)
+
+// settleDelay is how long after a window ends it is archived, so records
+// saved late with a timestamp inside the window are not missed.
+const settleDelay = time.Minute
+
+const lockKey = "archive"
+
+// Sink is the object storage archives are written to.
+type Sink interface {
+	Exists(ctx context.Context, key string) (bool, error)
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// Source lists the records to archive.
+type Source interface {
+	ListRefreshes(ctx context.Context, filter storage.RefreshFilter) ([]storage.RefreshRecord, error)
+	storage.LineageLog
+}
+
+type Option func(*Archiver)
+
+// WithPrefix puts archives under prefix in the bucket.
+func WithPrefix(prefix string) Option {
+	return func(a *Archiver) {
+		a.prefix = prefix
+	}
+}
+
+// WithWindow sets the span of time archived in one object, which is also
+// how often archives are written.
+func WithWindow(window time.Duration) Option {
+	return func(a *Archiver) {
+		if window > 0 {
+			a.window = window
+		}
+	}
+}
+
+// WithLookback sets how far back missing archives are written again, e.g.
+// after the service was down.
+func WithLookback(lookback time.Duration) Option {
+	return func(a *Archiver) {
+		if lookback > 0 {
+			a.lookback = lookback
+		}
+	}
+}
+
+// WithLocker lets only one replica archive at a time.
+func WithLocker(locker lock.Locker) Option {
+	return func(a *Archiver) {
+		a.locker = locker
+	}
+}
+
+// Archiver copies the refresh history and the credential lineage to object
+// storage, one gzipped JSON Lines object per table and window. An object
+// is written once, when its window has ended, so the archive outlives the
+// retention of the database.
+type Archiver struct {
+	source   Source
+	sink     Sink
+	prefix   string
+	window   time.Duration
+	lookback time.Duration
+	locker   lock.Locker
+}
+
+func NewArchiver(source Source, sink Sink, opts ...Option) *Archiver {
+	a := &Archiver{
+		source:   source,
+		sink:     sink,
+		window:   time.Hour,
+		lookback: 24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Run archives the ended windows on every window until ctx ends.
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.window)
+	defer ticker.Stop()
+	for {
+		if written, err := a.Archive(ctx, time.Now()); err != nil {
+			logger.DefaultLogger.Errorf("failed to archive refresh history: %v", err)
+		} else if written > 0 {
+			logger.DefaultLogger.Infof("archived %d objects of refresh history", written)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Archive writes the objects of the windows which ended within the
+// lookback before now and are not archived yet. It returns the number of
+// objects written.
+func (a *Archiver) Archive(ctx context.Context, now time.Time) (int, error) {
+	if a.locker != nil {
+		lease, err := a.locker.Acquire(ctx, lockKey, a.window)
+		if errors.Is(err, lock.ErrNotAcquired) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		defer func() {
+			_ = lease.Release(context.Background())
+		}()
+	}
+
+	end := now.Add(-settleDelay).UTC().Truncate(a.window)
+	var written int
+	for from := end.Add(-a.lookback).Truncate(a.window); from.Before(end); from = from.Add(a.window) {
+		to := from.Add(a.window)
+		for _, table := range tables {
+			key := a.key(table.name, from)
+			exists, err := a.sink.Exists(ctx, key)
+			if err != nil {
+				return written, err
+			}
+			if exists {
+				continue
+			}
+			body, err := table.export(ctx, a.source, from, to)
+			if err != nil {
+				return written, errors.Wrapf(err, "failed to export %s of %s", table.name, from.Format(time.RFC3339))
+			}
+			if err := a.sink.Put(ctx, key, body, "application/x-ndjson"); err != nil {
+				return written, err
+			}
+			written++
+		}
+	}
+	return written, nil
+}
+
+// key is e.g. '<prefix>/refresh_history/2024/01/31/130000.jsonl.gz'.
+func (a *Archiver) key(table string, from time.Time) string {
+	return path.Join(a.prefix, table, from.Format("2006/01/02/150405")+".jsonl.gz")
+}
+
+type table struct {
+	name   string
+	export func(ctx context.Context, source Source, from, to time.Time) ([]byte, error)
+}
+
+var tables = []table{
+	{name: "refresh_history", export: exportRefreshes},
+	{name: "lineage", export: exportLineage},
+}
+
+func exportRefreshes(ctx context.Context, source Source, from, to time.Time) ([]byte, error) {
+	records, err := source.ListRefreshes(ctx, storage.RefreshFilter{From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	return jsonLines(records)
+}
+
+func exportLineage(ctx context.Context, source Source, from, to time.Time) ([]byte, error) {
+	records, err := source.ListLineage(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return jsonLines(records)
+}
+
+// jsonLines encodes records one per line and gzips them.
+func jsonLines[T any](records []T) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for i := range records {
+		if err := codec.Encode(zw, records[i]); err != nil {
+			return nil, errors.Wrap(err, "failed to encode record")
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/lock"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *memorySink) Exists(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.objects[key]
+	return ok, nil
+}
+
+func (s *memorySink) Put(_ context.Context, key string, body []byte, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = body
+	return nil
+}
+
+func (s *memorySink) lines(t *testing.T, key string) []string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(s.objects[key]))
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return strings.Fields(string(body))
+}
+
+type heldLocker struct{}
+
+func (heldLocker) Acquire(context.Context, string, time.Duration) (*lock.Lease, error) {
+	return nil, lock.ErrNotAcquired
+}
+
+func TestArchive(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	start := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	for _, r := range []storage.RefreshRecord{
+		{CredentialID: "1", Status: storage.RefreshStatusSucceeded, CreatedAt: start.Add(10 * time.Minute)},
+		{CredentialID: "2", Status: storage.RefreshStatusFailed, CreatedAt: start.Add(20 * time.Minute)},
+		{CredentialID: "3", Status: storage.RefreshStatusSucceeded, CreatedAt: start.Add(70 * time.Minute)},
+		// the current window is not archived
+		{CredentialID: "4", Status: storage.RefreshStatusSucceeded, CreatedAt: start.Add(125 * time.Minute)},
+	} {
+		require.NoError(t, store.SaveRefresh(ctx, r))
+	}
+	require.NoError(t, store.SaveLineage(ctx, storage.LineageRecord{
+		CredentialID: "3", ParentID: "1", CreatedAt: start.Add(70 * time.Minute),
+	}))
+
+	sink := &memorySink{objects: map[string][]byte{}}
+	archiver := NewArchiver(store, sink, WithPrefix("audit"), WithLookback(2*time.Hour))
+
+	// windows end a minute late, so late records are still archived
+	written, err := archiver.Archive(ctx, start.Add(2*time.Hour+30*time.Second))
+	require.NoError(t, err)
+	require.Equal(t, 4, written)
+	require.Empty(t, sink.lines(t, "audit/refresh_history/2024/01/31/090000.jsonl.gz"))
+	require.Len(t, sink.lines(t, "audit/refresh_history/2024/01/31/100000.jsonl.gz"), 2)
+	require.Empty(t, sink.lines(t, "audit/lineage/2024/01/31/100000.jsonl.gz"))
+
+	now := start.Add(2*time.Hour + 5*time.Minute)
+	written, err = archiver.Archive(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, 2, written)
+	require.Len(t, sink.objects, 6)
+	refreshes := sink.lines(t, "audit/refresh_history/2024/01/31/110000.jsonl.gz")
+	require.Len(t, refreshes, 1)
+	require.Contains(t, refreshes[0], `"credentialId":"3"`)
+	lineage := sink.lines(t, "audit/lineage/2024/01/31/110000.jsonl.gz")
+	require.Equal(t, []string{`{"credentialId":"3","parentId":"1","issuer":"","createdAt":"2024-01-31T11:10:00Z"}`}, lineage)
+
+	// archived windows are not written again
+	written, err = archiver.Archive(ctx, now)
+	require.NoError(t, err)
+	require.Zero(t, written)
+
+	delete(sink.objects, "audit/lineage/2024/01/31/110000.jsonl.gz")
+	written, err = NewArchiver(store, sink, WithPrefix("audit"), WithLocker(heldLocker{})).Archive(ctx, now)
+	require.NoError(t, err)
+	require.Zero(t, written)
+}
@@ -0,0 +1,141 @@
+// Package audit records changes of the runtime configuration, who made
+// them, when and what changed, for change management.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/features"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/storage"
+)
+
+// Targets of configuration changes.
+const (
+	TargetProviders    = "providers"
+	TargetFeatureFlags = "feature_flags"
+	TargetSecrets      = "secrets"
+)
+
+// Sources of configuration changes.
+const (
+	SourceAdminAPI = "admin-api"
+	SourceReload   = "reload"
+)
+
+// ActorSystem is the actor of changes picked up by the service itself,
+// like a reloaded feature flag file.
+const ActorSystem = "system"
+
+// Recorder saves configuration changes. A nil Recorder records nothing.
+type Recorder struct {
+	store storage.ConfigAudit
+	now   func() time.Time
+}
+
+func NewRecorder(store storage.ConfigAudit) *Recorder {
+	return &Recorder{store: store, now: time.Now}
+}
+
+// Record saves change unless nothing was added, removed or changed. A
+// change which can't be saved is logged, it is in effect already.
+func (r *Recorder) Record(ctx context.Context, change storage.ConfigChange) {
+	if r == nil || len(change.Added)+len(change.Removed)+len(change.Changed) == 0 {
+		return
+	}
+	if change.CreatedAt.IsZero() {
+		change.CreatedAt = r.now().UTC()
+	}
+	logger.DefaultLogger.Infow("configuration changed",
+		"actor", change.Actor,
+		"target", change.Target,
+		"source", change.Source,
+		"added", change.Added,
+		"removed", change.Removed,
+		"changed", change.Changed,
+	)
+	if err := r.store.SaveConfigChange(ctx, change); err != nil {
+		logger.DefaultLogger.Errorf("failed to record configuration change of %s by '%s': %v",
+			change.Target, change.Actor, err)
+	}
+}
+
+// List returns the recorded changes matching filter, newest first.
+func (r *Recorder) List(ctx context.Context, filter storage.ConfigChangeFilter) ([]storage.ConfigChange, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return r.store.ListConfigChanges(ctx, filter)
+}
+
+// Providers records a provider configuration change from the fingerprints
+// of the credential types before and after it.
+func (r *Recorder) Providers(ctx context.Context, actor, source string, before, after map[string]string) {
+	r.Record(ctx, change(actor, TargetProviders, source, before, after))
+}
+
+// Flags returns a hook for features.Set.OnChange which records reloaded
+// flags.
+func (r *Recorder) Flags(ctx context.Context) func(before, after map[string]features.Flag) {
+	return func(before, after map[string]features.Flag) {
+		r.Record(ctx, change(ActorSystem, TargetFeatureFlags, SourceReload, before, after))
+	}
+}
+
+// Secrets returns a hook for secrets.Store.OnChange which records the names
+// of reloaded secrets.
+func (r *Recorder) Secrets(ctx context.Context) func(added, removed, changed []string) {
+	return func(added, removed, changed []string) {
+		r.Record(ctx, storage.ConfigChange{
+			Actor:   ActorSystem,
+			Target:  TargetSecrets,
+			Source:  SourceReload,
+			Added:   added,
+			Removed: removed,
+			Changed: changed,
+		})
+	}
+}
+
+// valueChange is the before and after value of a key, missing when the key
+// was added or removed.
+type valueChange[T any] struct {
+	Before *T `json:"before,omitempty"`
+	After  *T `json:"after,omitempty"`
+}
+
+// change compares before and after by key and keeps the changed values as
+// details.
+func change[T any](actor, target, source string, before, after map[string]T) storage.ConfigChange {
+	c := storage.ConfigChange{Actor: actor, Target: target, Source: source}
+	details := make(map[string]valueChange[T])
+	for key, v := range after {
+		old, ok := before[key]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, key)
+			details[key] = valueChange[T]{After: &v}
+		case !reflect.DeepEqual(old, v):
+			c.Changed = append(c.Changed, key)
+			details[key] = valueChange[T]{Before: &old, After: &v}
+		}
+	}
+	for key, v := range before {
+		if _, ok := after[key]; !ok {
+			c.Removed = append(c.Removed, key)
+			details[key] = valueChange[T]{Before: &v}
+		}
+	}
+	sort.Strings(c.Added)
+	sort.Strings(c.Removed)
+	sort.Strings(c.Changed)
+	if len(details) > 0 {
+		// maps of strings and flags always encode
+		c.Details, _ = json.Marshal(details)
+	}
+	return c
+}
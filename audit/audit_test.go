@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/features"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	store := memory.NewStore()
+	r := NewRecorder(store)
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	r.Providers(ctx, "alice", SourceAdminAPI,
+		map[string]string{"Balance": "a", "KYCAge": "b"},
+		map[string]string{"Balance": "c", "Email": "d"})
+	r.Flags(ctx)(
+		map[string]features.Flag{"cache": {Enabled: true}},
+		map[string]features.Flag{"cache": {Enabled: true}})
+	r.Secrets(ctx)(nil, nil, []string{"API_KEY"})
+
+	changes, err := r.List(ctx, storage.ConfigChangeFilter{})
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	require.Equal(t, ActorSystem, changes[0].Actor)
+	require.Equal(t, TargetSecrets, changes[0].Target)
+	require.Equal(t, []string{"API_KEY"}, changes[0].Changed)
+	require.Empty(t, changes[0].Details)
+
+	require.Equal(t, "alice", changes[1].Actor)
+	require.Equal(t, TargetProviders, changes[1].Target)
+	require.Equal(t, SourceAdminAPI, changes[1].Source)
+	require.Equal(t, []string{"Email"}, changes[1].Added)
+	require.Equal(t, []string{"KYCAge"}, changes[1].Removed)
+	require.Equal(t, []string{"Balance"}, changes[1].Changed)
+	require.JSONEq(t, `{
+		"Balance": {"before": "a", "after": "c"},
+		"Email": {"after": "d"},
+		"KYCAge": {"before": "b"}
+	}`, string(changes[1].Details))
+	require.Equal(t, now, changes[1].CreatedAt)
+
+	changes, err = r.List(ctx, storage.ConfigChangeFilter{Target: TargetProviders, Actor: "bob"})
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
+
+func TestRecorder_Nil(t *testing.T) {
+	var r *Recorder
+	r.Flags(context.Background())(nil, map[string]features.Flag{"cache": {}})
+	changes, err := r.List(context.Background(), storage.ConfigChangeFilter{})
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
@@ -0,0 +1,242 @@
+package batch
+
+import (
+	"context"
+	"sync"
+
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// ErrRenewalUnsupported fails items renewed with a new expiration only when
+// the engine has no renewal, see WithRenewal.
+var ErrRenewalUnsupported = errors.New("expiration only renewal is not supported")
+
+type Refresher interface {
+	Process(ctx context.Context, issuer, owner, id string) (*verifiable.W3CCredential, error)
+}
+
+type Item struct {
+	Issuer       string `json:"issuer"`
+	Owner        string `json:"owner"`
+	CredentialID string `json:"credentialId"`
+	// ExpirationOnly renews the credential with a new expiration only.
+	ExpirationOnly bool `json:"expirationOnly,omitempty"`
+}
+
+// Result is the outcome of the item at Index of a batch.
+type Result struct {
+	Index      int
+	Item       Item
+	Credential *verifiable.W3CCredential
+	Err        error
+}
+
+type Options struct {
+	// Workers is the number of refreshes running at the same time.
+	Workers int
+	// IssuerConcurrency caps running refreshes per issuer across all
+	// batches of the engine.
+	IssuerConcurrency int
+	// QueueSize is how many items are buffered before producers block.
+	QueueSize int
+	// Renewal marks the context of an item to be renewed with a new
+	// expiration only.
+	Renewal func(ctx context.Context) context.Context
+}
+
+type Option func(*Options)
+
+func WithWorkers(n int) Option {
+	return func(o *Options) {
+		o.Workers = n
+	}
+}
+
+func WithIssuerConcurrency(n int) Option {
+	return func(o *Options) {
+		o.IssuerConcurrency = n
+	}
+}
+
+func WithQueueSize(n int) Option {
+	return func(o *Options) {
+		o.QueueSize = n
+	}
+}
+
+// WithRenewal lets items be renewed with a new expiration only, the
+// refresher is called with the context marked by renewal.
+func WithRenewal(renewal func(ctx context.Context) context.Context) Option {
+	return func(o *Options) {
+		o.Renewal = renewal
+	}
+}
+
+// Engine refreshes credentials in bulk with a fixed number of workers.
+// Producers and consumers are throttled by bounded channels, so a slow
+// issuer slows the batch down instead of piling up goroutines.
+type Engine struct {
+	refresher Refresher
+	opts      Options
+
+	mu          sync.Mutex
+	issuerSlots map[string]chan struct{}
+}
+
+func NewEngine(refresher Refresher, opts ...Option) *Engine {
+	options := Options{
+		Workers:           8,
+		IssuerConcurrency: 4,
+		QueueSize:         16,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Workers < 1 {
+		options.Workers = 1
+	}
+	return &Engine{
+		refresher:   refresher,
+		opts:        options,
+		issuerSlots: make(map[string]chan struct{}),
+	}
+}
+
+// Stream refreshes items until the channel is closed or ctx is done.
+// Results are sent in completion order and the returned channel is closed
+// when all started refreshes have finished. Items are not read while
+// results are not consumed.
+func (e *Engine) Stream(ctx context.Context, items <-chan Item) <-chan Result {
+	type indexed struct {
+		index int
+		item  Item
+	}
+	queue := make(chan indexed, e.opts.QueueSize)
+	results := make(chan Result)
+
+	go func() {
+		defer close(queue)
+		index := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok := <-items:
+				if !ok {
+					return
+				}
+				select {
+				case queue <- indexed{index: index, item: item}:
+					index++
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < e.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next := range queue {
+				result := e.refresh(ctx, next.index, next.item)
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// Process refreshes items and returns their results in the input order.
+// Items not started before ctx is done fail with the context error.
+func (e *Engine) Process(ctx context.Context, items []Item) []Result {
+	in := make(chan Item)
+	go func() {
+		defer close(in)
+		for _, item := range items {
+			select {
+			case in <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make([]Result, len(items))
+	done := make([]bool, len(items))
+	for result := range e.Stream(ctx, in) {
+		results[result.Index] = result
+		done[result.Index] = true
+	}
+	for i := range results {
+		if !done[i] {
+			results[i] = Result{Index: i, Item: items[i], Err: ctx.Err()}
+		}
+	}
+	return results
+}
+
+// RefreshCredentials refreshes the credentials of owner at issuer for agent
+// messages carrying several credential ids.
+func (e *Engine) RefreshCredentials(ctx context.Context, issuer, owner string, ids []string) []service.BatchResult {
+	items := make([]Item, len(ids))
+	for i, id := range ids {
+		items[i] = Item{Issuer: issuer, Owner: owner, CredentialID: id}
+	}
+	results := make([]service.BatchResult, len(ids))
+	for i, result := range e.Process(ctx, items) {
+		results[i] = service.BatchResult{Credential: result.Credential, Err: result.Err}
+	}
+	return results
+}
+
+func (e *Engine) refresh(ctx context.Context, index int, item Item) Result {
+	result := Result{Index: index, Item: item}
+	release, err := e.acquireIssuer(ctx, item.Issuer)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer release()
+	if item.ExpirationOnly {
+		if e.opts.Renewal == nil {
+			result.Err = ErrRenewalUnsupported
+			return result
+		}
+		ctx = e.opts.Renewal(ctx)
+	}
+	result.Credential, result.Err = e.refresher.Process(ctx, item.Issuer, item.Owner, item.CredentialID)
+	return result
+}
+
+func (e *Engine) acquireIssuer(ctx context.Context, issuer string) (func(), error) {
+	if e.opts.IssuerConcurrency <= 0 {
+		return func() {}, nil
+	}
+	e.mu.Lock()
+	slots, ok := e.issuerSlots[issuer]
+	if !ok {
+		slots = make(chan struct{}, e.opts.IssuerConcurrency)
+		e.issuerSlots[issuer] = slots
+	}
+	e.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,141 @@
+package batch
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type countingRefresher struct {
+	mu        sync.Mutex
+	running   map[string]int
+	total     int
+	maxIssuer map[string]int
+	maxTotal  int
+	delay     time.Duration
+}
+
+func newCountingRefresher(delay time.Duration) *countingRefresher {
+	return &countingRefresher{
+		running:   make(map[string]int),
+		maxIssuer: make(map[string]int),
+		delay:     delay,
+	}
+}
+
+func (r *countingRefresher) Process(ctx context.Context, issuer, _, id string) (*verifiable.W3CCredential, error) {
+	r.mu.Lock()
+	r.running[issuer]++
+	r.total++
+	r.maxIssuer[issuer] = max(r.maxIssuer[issuer], r.running[issuer])
+	r.maxTotal = max(r.maxTotal, r.total)
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.running[issuer]--
+		r.total--
+		r.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(r.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if id == "bad" {
+		return nil, errors.New("refresh failed")
+	}
+	return &verifiable.W3CCredential{ID: id}, nil
+}
+
+func TestEngine_Process(t *testing.T) {
+	refresher := newCountingRefresher(5 * time.Millisecond)
+	engine := NewEngine(refresher, WithWorkers(4), WithIssuerConcurrency(2), WithQueueSize(1))
+
+	var items []Item
+	for i := 0; i < 20; i++ {
+		issuer := "did:iden3:issuer" + strconv.Itoa(i%3)
+		items = append(items, Item{Issuer: issuer, Owner: "did:iden3:owner", CredentialID: strconv.Itoa(i)})
+	}
+	items[7].CredentialID = "bad"
+
+	results := engine.Process(context.Background(), items)
+	require.Len(t, results, len(items))
+	for i, result := range results {
+		require.Equal(t, i, result.Index)
+		require.Equal(t, items[i], result.Item)
+		if i == 7 {
+			require.EqualError(t, result.Err, "refresh failed")
+			continue
+		}
+		require.NoError(t, result.Err)
+		require.Equal(t, items[i].CredentialID, result.Credential.ID)
+	}
+	require.LessOrEqual(t, refresher.maxTotal, 4)
+	for issuer, n := range refresher.maxIssuer {
+		require.LessOrEqual(t, n, 2, issuer)
+	}
+}
+
+func TestEngine_Process_Canceled(t *testing.T) {
+	refresher := newCountingRefresher(time.Second)
+	engine := NewEngine(refresher, WithWorkers(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	items := make([]Item, 10)
+	results := engine.Process(ctx, items)
+	require.Len(t, results, len(items))
+	for _, result := range results {
+		require.ErrorIs(t, result.Err, context.DeadlineExceeded)
+	}
+}
+
+func TestEngine_RefreshCredentials(t *testing.T) {
+	refresher := newCountingRefresher(time.Millisecond)
+	engine := NewEngine(refresher, WithWorkers(2), WithIssuerConcurrency(1))
+
+	results := engine.RefreshCredentials(context.Background(), "did:issuer", "did:owner", []string{"1", "bad", "3"})
+	require.Len(t, results, 3)
+	require.Equal(t, "1", results[0].Credential.ID)
+	require.EqualError(t, results[1].Err, "refresh failed")
+	require.Equal(t, "3", results[2].Credential.ID)
+	require.Equal(t, 1, refresher.maxIssuer["did:issuer"])
+}
+
+type renewalKey struct{}
+
+// renewalRefresher reports in the credential id whether the refresh was a
+// renewal.
+type renewalRefresher struct{}
+
+func (renewalRefresher) Process(ctx context.Context, _, _, id string) (*verifiable.W3CCredential, error) {
+	if renewal, _ := ctx.Value(renewalKey{}).(bool); renewal {
+		id += ":renewed"
+	}
+	return &verifiable.W3CCredential{ID: id}, nil
+}
+
+func TestEngine_Renewal(t *testing.T) {
+	items := []Item{
+		{Issuer: "issuer", CredentialID: "refreshed"},
+		{Issuer: "issuer", CredentialID: "expiring", ExpirationOnly: true},
+	}
+
+	results := NewEngine(renewalRefresher{}).Process(context.Background(), items)
+	require.Equal(t, "refreshed", results[0].Credential.ID)
+	require.ErrorIs(t, results[1].Err, ErrRenewalUnsupported)
+
+	engine := NewEngine(renewalRefresher{}, WithRenewal(func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, renewalKey{}, true)
+	}))
+	results = engine.Process(context.Background(), items)
+	require.Equal(t, "refreshed", results[0].Credential.ID)
+	require.Equal(t, "expiring:renewed", results[1].Credential.ID)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/issuercontract"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/pkg/errors"
+)
+
+// checkIssuer runs the issuer node contract suite, see issuercontract.
+func checkIssuer(args []string) error {
+	fs := flag.NewFlagSet("check-issuer", flag.ContinueOnError)
+	issuer := fs.String("issuer", "", "issuer DID")
+	claimID := fs.String("claim-id", "", "existing credential of the issuer which is copied, the copy is revoked")
+	node := fs.String("node", "", "issuer node URL, the one of the issuer in SUPPORTED_ISSUERS by default")
+	asyncTimeout := fs.Duration("async-timeout", 2*time.Minute, "how long to wait for the proofs and the revocation of the copy")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *issuer == "" || *claimID == "" {
+		fs.Usage()
+		return errors.New("--issuer and --claim-id are required")
+	}
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+	supportedIssuers := cfg.SupportedIssuers
+	if *node != "" {
+		supportedIssuers = KVstring{*issuer: *node}
+	}
+	issuerService := service.NewIssuerService(supportedIssuers, cfg.SupportedIssuersBasicAuth,
+		httpclient.NewClient(httpclient.DefaultOptions, 0))
+
+	report := issuercontract.Run(context.Background(), issuerService, issuercontract.Options{
+		IssuerDID:    *issuer,
+		CredentialID: *claimID,
+		AsyncTimeout: *asyncTimeout,
+	})
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, result := range report.Results {
+			status := "ok  "
+			switch {
+			case result.Skipped:
+				status = "skip"
+			case !result.Passed:
+				status = "FAIL"
+			}
+			if result.Detail == "" {
+				printf("%s  %s\n", status, result.Name)
+				continue
+			}
+			printf("%s  %s: %s\n", status, result.Name, result.Detail)
+		}
+	}
+	if !report.Compatible {
+		return errors.Errorf("issuer node of '%s' is not compatible", *issuer)
+	}
+	return nil
+}
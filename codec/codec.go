@@ -0,0 +1,18 @@
+// Package codec is the JSON codec of the issuer and data provider paths,
+// where decoding dominates CPU. The standard library is used by default;
+// building with the gojson tag switches to github.com/goccy/go-json:
+//
+//	go build -tags gojson .
+package codec
+
+import "io"
+
+// Decode reads the next JSON value from r and stores it in v.
+func Decode(r io.Reader, v interface{}) error {
+	return newDecoder(r).Decode(v)
+}
+
+// Encode writes the JSON encoding of v to w followed by a newline.
+func Encode(w io.Writer, v interface{}) error {
+	return newEncoder(w).Encode(v)
+}
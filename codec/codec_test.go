@@ -0,0 +1,42 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	type payload struct {
+		ID      string          `json:"id"`
+		Balance float64         `json:"balance"`
+		Raw     json.RawMessage `json:"raw"`
+	}
+
+	var decoded payload
+	require.NoError(t, Decode(strings.NewReader(`{"id":"1","balance":12.5,"raw":{"a":[1,2]}}`), &decoded))
+	require.Equal(t, "1", decoded.ID)
+	require.InDelta(t, 12.5, decoded.Balance, 0)
+	require.JSONEq(t, `{"a":[1,2]}`, string(decoded.Raw))
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, decoded))
+	var encoded payload
+	require.NoError(t, Unmarshal(buf.Bytes(), &encoded))
+	require.Equal(t, decoded, encoded)
+
+	marshaled, err := Marshal(map[string]interface{}{"n": 1})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"n":1}`, string(marshaled))
+}
+
+func TestDecode_Numbers(t *testing.T) {
+	// data provider responses are decoded into generic maps
+	var response map[string]interface{}
+	require.NoError(t, Decode(strings.NewReader(`{"result":"42","count":3}`), &response))
+	require.Equal(t, "42", response["result"])
+	require.InDelta(t, float64(3), response["count"], 0)
+}
@@ -0,0 +1,28 @@
+//go:build gojson
+
+package codec
+
+import (
+	"io"
+
+	json "github.com/goccy/go-json"
+)
+
+// Name of the JSON implementation the binary was built with.
+const Name = "github.com/goccy/go-json"
+
+func Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func newDecoder(r io.Reader) *json.Decoder {
+	return json.NewDecoder(r)
+}
+
+func newEncoder(w io.Writer) *json.Encoder {
+	return json.NewEncoder(w)
+}
@@ -0,0 +1,27 @@
+//go:build !gojson
+
+package codec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Name of the JSON implementation the binary was built with.
+const Name = "encoding/json"
+
+func Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func newDecoder(r io.Reader) *json.Decoder {
+	return json.NewDecoder(r)
+}
+
+func newEncoder(w io.Writer) *json.Encoder {
+	return json.NewEncoder(w)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/features"
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/secrets"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+)
+
+// commands are run as `refresh-service <command> [flags]`. Without a command
+// the service is started.
+var commands = map[string]func(args []string) error{
+	"check-issuer":      checkIssuer,
+	"inspect":           inspect,
+	"scaffold-provider": scaffoldProvider,
+	"simulate":          simulate,
+	"test-provider":     testProvider,
+	"verify-schemas":    verifySchemas,
+}
+
+func runCommand(name string, args []string) error {
+	command, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return errors.Errorf("unknown command '%s', available commands: %s", name, strings.Join(names, ", "))
+	}
+	return command(args)
+}
+
+// CommandConfig is the part of the service configuration the CLI commands
+// use. Nothing is required, so commands also run outside of a deployment.
+type CommandConfig struct {
+	SupportedIssuers          KVstring      `envconfig:"SUPPORTED_ISSUERS"`
+	IPFSGWURL                 string        `envconfig:"IPFS_GATEWAY_URL" default:"https://ipfs.io"`
+	HTTPConfigPath            string        `envconfig:"HTTP_CONFIG_PATH" default:"config.yaml"`
+	SupportedIssuersBasicAuth KVstring      `envconfig:"ISSUERS_BASIC_AUTH"`
+	RefreshServiceTypes       []string      `envconfig:"REFRESH_SERVICE_TYPES" default:"Iden3RefreshService2023"`
+	RefreshServiceEmitType    string        `envconfig:"REFRESH_SERVICE_EMIT_TYPE"`
+	IssuersStatusType         KVstring      `envconfig:"ISSUERS_CREDENTIAL_STATUS_TYPE"`
+	IssuersDisplayMethod      KVstring      `envconfig:"ISSUERS_DISPLAY_METHOD"`
+	ContextLoadConcurrency    int           `envconfig:"CONTEXT_LOAD_CONCURRENCY" default:"4"`
+	ExpirationSkew            time.Duration `envconfig:"EXPIRATION_SKEW" default:"0s"`
+	ReissueIssuanceDate       string        `envconfig:"REISSUE_ISSUANCE_DATE" default:"now"`
+	RefreshPolicyPath         string        `envconfig:"REFRESH_POLICY_PATH"`
+	SubjectMaxBytes           int           `envconfig:"SUBJECT_MAX_BYTES"`
+	SubjectMaxFields          int           `envconfig:"SUBJECT_MAX_FIELDS"`
+	SubjectMaxDepth           int           `envconfig:"SUBJECT_MAX_DEPTH"`
+	SecretsPath               string        `envconfig:"SECRETS_PATH"`
+	FeatureFlagsPath          string        `envconfig:"FEATURE_FLAGS_PATH"`
+	FeatureFlagsURL           string        `envconfig:"FEATURE_FLAGS_URL"`
+	SchemaRegistryURL         string        `envconfig:"SCHEMA_REGISTRY_URL"`
+	SchemaRegistryToken       string        `envconfig:"SCHEMA_REGISTRY_TOKEN"`
+	SchemaRegistryAliases     KVstring      `envconfig:"SCHEMA_REGISTRY_ALIASES"`
+	LogLevel                  string        `envconfig:"LOG_LEVEL" default:"warn"`
+}
+
+func loadCommandConfig() (CommandConfig, error) {
+	var cfg CommandConfig
+	if _, err := loadProfile(); err != nil {
+		return cfg, errors.Errorf("failed init config profile: %v", err)
+	}
+	if err := envconfig.Process("", &cfg); err != nil {
+		return cfg, errors.Errorf("failed init config: %v", err)
+	}
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		return cfg, errors.Errorf("failed init log level: %v", err)
+	}
+	return cfg, nil
+}
+
+// schemaRegistry is the schema registry of the service. Commands run once,
+// resolved documents are kept for the whole run.
+func (c *CommandConfig) schemaRegistry() schemaRegistryConfig {
+	return schemaRegistryConfig{
+		URL:      c.SchemaRegistryURL,
+		Token:    c.SchemaRegistryToken,
+		Aliases:  c.SchemaRegistryAliases,
+		CacheTTL: 24 * time.Hour,
+	}
+}
+
+// providerFactory loads the data provider configuration. Secrets are read
+// once, commands don't watch them.
+func (c *CommandConfig) providerFactory(client *http.Client) (flexiblehttp.FactoryFlexibleHTTP, error) {
+	if client == nil {
+		client = httpclient.NewClient(httpclient.DefaultOptions, 0)
+	}
+	var opts []flexiblehttp.FactoryOption
+	if c.SecretsPath != "" {
+		store := secrets.NewStore(0)
+		if err := store.Reload(context.Background(), secrets.FileSource{Path: c.SecretsPath}); err != nil {
+			return flexiblehttp.FactoryFlexibleHTTP{}, errors.Errorf("failed init secrets: %v", err)
+		}
+		opts = append(opts, flexiblehttp.WithSecrets(store))
+	}
+	return flexiblehttp.NewFactoryFlexibleHTTP(c.HTTPConfigPath, client, opts...)
+}
+
+// refreshService builds the refresh pipeline of the service. Nothing it
+// does is recorded or locked.
+func (c *CommandConfig) refreshService(
+	supportedIssuers KVstring,
+	issuerClient, providerClient *http.Client,
+) (*service.RefreshService, error) {
+	providers, err := c.providerFactory(providerClient)
+	if err != nil {
+		return nil, errors.Errorf("failed init flexiblehttp: %v", err)
+	}
+	documentLoader, _, err := initDocumentLoaderWithCache(c.IPFSGWURL, nil, c.schemaRegistry())
+	if err != nil {
+		return nil, errors.Errorf("failed init document loader: %v", err)
+	}
+	var flags *features.Set
+	flagsSource, err := featureFlagsSource(c.FeatureFlagsPath, c.FeatureFlagsURL)
+	if err != nil {
+		return nil, err
+	}
+	if flagsSource != nil {
+		if flags, err = features.Load(context.Background(), flagsSource); err != nil {
+			return nil, errors.Errorf("failed init feature flags: %v", err)
+		}
+	}
+	refreshOptions, err := refreshPipelineOptions(
+		c.RefreshServiceTypes,
+		c.RefreshServiceEmitType,
+		c.IssuersStatusType,
+		c.IssuersDisplayMethod,
+		c.ContextLoadConcurrency,
+		c.ExpirationSkew,
+		c.ReissueIssuanceDate,
+		c.RefreshPolicyPath,
+		service.SubjectLimits{
+			MaxBytes:  c.SubjectMaxBytes,
+			MaxFields: c.SubjectMaxFields,
+			MaxDepth:  c.SubjectMaxDepth,
+		},
+		flags,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return service.NewRefreshService(
+		service.NewIssuerService(supportedIssuers, c.SupportedIssuersBasicAuth, issuerClient),
+		documentLoader,
+		providers,
+		refreshOptions...,
+	), nil
+}
+
+// credentialFixture serves the credential of file as the one of every
+// issuer node.
+func credentialFixture(file string) (KVstring, *http.Client, error) {
+	credential, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, errors.Errorf("failed to read credential: %v", err)
+	}
+	body, err := json.Marshal(map[string]json.RawMessage{"vc": credential})
+	if err != nil {
+		return nil, nil, errors.Errorf("invalid credential: %v", err)
+	}
+	return KVstring{"*": "http://mock-issuer"}, &http.Client{Transport: fixtureTransport{body: body}}, nil
+}
+
+// fixtureTransport answers every request with body, in place of an issuer
+// node or data provider.
+type fixtureTransport struct {
+	body []byte
+}
+
+func (t fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(t.body)),
+		Request:    req,
+	}, nil
+}
+
+func printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(os.Stdout, format, args...)
+}
@@ -0,0 +1,28 @@
+package correlation
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// FromContext returns the inbound request id assigned by the
+// RequestID middleware.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	return middleware.GetReqID(ctx)
+}
+
+// SetHeader forwards the request id and the traceparent from ctx to an
+// outbound request, so upstream calls join the trace of the request.
+func SetHeader(ctx context.Context, request *http.Request) {
+	if id := FromContext(ctx); id != "" {
+		request.Header.Set(middleware.RequestIDHeader, id)
+	}
+	if traceparent := traceparentOf(ctx); traceparent != "" {
+		request.Header.Set(TraceparentHeader, traceparent)
+	}
+}
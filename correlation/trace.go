@@ -0,0 +1,62 @@
+package correlation
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+)
+
+// TraceparentHeader carries the W3C Trace Context of a request.
+const TraceparentHeader = "traceparent"
+
+// traceparentRe is the version 00 traceparent, 'version-traceid-parentid-flags'.
+var traceparentRe = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+type traceparentKey struct{}
+
+// WithTraceparent keeps the traceparent of an inbound request in ctx when it
+// is valid.
+func WithTraceparent(ctx context.Context, traceparent string) context.Context {
+	if _, ok := parseTraceID(traceparent); !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, traceparentKey{}, traceparent)
+}
+
+// TraceID returns the trace id of the inbound traceparent, empty when the
+// request carried none.
+func TraceID(ctx context.Context) string {
+	traceID, _ := parseTraceID(traceparentOf(ctx))
+	return traceID
+}
+
+func traceparentOf(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	traceparent, _ := ctx.Value(traceparentKey{}).(string)
+	return traceparent
+}
+
+// parseTraceID returns the trace id of a traceparent. Invalid and all-zero
+// ids are refused, as the specification requires.
+func parseTraceID(traceparent string) (string, bool) {
+	m := traceparentRe.FindStringSubmatch(traceparent)
+	if m == nil || m[1] == "ff" || m[2] == "00000000000000000000000000000000" ||
+		m[3] == "0000000000000000" {
+		return "", false
+	}
+	return m[2], true
+}
+
+// Traceparent keeps the traceparent header of requests in their context,
+// see TraceID.
+func Traceparent(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if traceparent := r.Header.Get(TraceparentHeader); traceparent != "" {
+			r = r.WithContext(WithTraceparent(r.Context(), traceparent))
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,67 @@
+package correlation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceID(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		expected    string
+	}{
+		{
+			name:        "Valid",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			expected:    "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:        "Upper case",
+			traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01",
+		},
+		{
+			name:        "All-zero trace id",
+			traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			name:        "All-zero parent id",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		},
+		{
+			name:        "Invalid version",
+			traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			name:        "Malformed",
+			traceparent: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, TraceID(WithTraceparent(context.Background(), tt.traceparent)))
+		})
+	}
+	require.Empty(t, TraceID(nil)) //nolint:staticcheck // nil contexts are handled
+}
+
+func TestTraceparent(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var outbound *http.Request
+	handler := Traceparent(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		outbound = httptest.NewRequest(http.MethodGet, "http://upstream/", http.NoBody)
+		SetHeader(r.Context(), outbound)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req.Header.Set(TraceparentHeader, traceparent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, traceparent, outbound.Header.Get(TraceparentHeader))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	require.Empty(t, outbound.Header.Get(TraceparentHeader))
+}
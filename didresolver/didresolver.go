@@ -0,0 +1,100 @@
+// Package didresolver resolves owner DIDs to the Ethereum accounts which
+// control them, so requests signed by the owner can be verified whatever
+// the DID method.
+package didresolver
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnsupportedMethod = errors.New("unsupported DID method")
+	ErrInvalidDID        = errors.New("invalid DID")
+)
+
+// Document is what ownership checks need of a resolved DID.
+type Document struct {
+	ID string
+	// ChainID is the chain the DID lives on, 0 when it isn't bound to one.
+	ChainID int64
+	// Controllers are the Ethereum accounts which may sign for the DID.
+	Controllers []common.Address
+}
+
+// Controls reports whether address may sign for the DID.
+func (d Document) Controls(address common.Address) bool {
+	for _, controller := range d.Controllers {
+		if controller == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver resolves the DIDs of one method.
+type Resolver interface {
+	Resolve(ctx context.Context, did string) (Document, error)
+}
+
+// Method returns the method of did, e.g. 'ethr' for 'did:ethr:0x...'.
+func Method(did string) (string, error) {
+	parts := strings.SplitN(did, ":", 3)
+	if len(parts) != 3 || parts[0] != "did" || parts[1] == "" || parts[2] == "" {
+		return "", errors.Wrapf(ErrInvalidDID, "'%s'", did)
+	}
+	return parts[1], nil
+}
+
+// Registry resolves DIDs with the resolver registered for their method.
+type Registry struct {
+	resolvers map[string]Resolver
+}
+
+func NewRegistry() *Registry {
+	return &Registry{resolvers: make(map[string]Resolver)}
+}
+
+// NewDefaultRegistry resolves the iden3, polygonid, ethr, key and web
+// methods. client fetches did:web documents.
+func NewDefaultRegistry(client *http.Client) *Registry {
+	r := NewRegistry()
+	r.Register("iden3", Iden3{})
+	r.Register("polygonid", Iden3{})
+	r.Register("ethr", Ethr{})
+	r.Register("key", Key{})
+	r.Register("web", Web{Client: client})
+	return r
+}
+
+// Register resolves the DIDs of method with resolver.
+func (r *Registry) Register(method string, resolver Resolver) {
+	r.resolvers[method] = resolver
+}
+
+func (r *Registry) Resolve(ctx context.Context, did string) (Document, error) {
+	method, err := Method(did)
+	if err != nil {
+		return Document{}, err
+	}
+	resolver, ok := r.resolvers[method]
+	if !ok {
+		return Document{}, errors.Wrapf(ErrUnsupportedMethod, "'%s'", method)
+	}
+	return resolver.Resolve(ctx, did)
+}
+
+// Methods returns the registered methods.
+func (r *Registry) Methods() []string {
+	methods := make([]string, 0, len(r.resolvers))
+	for method := range r.resolvers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
@@ -0,0 +1,196 @@
+package didresolver
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	core "github.com/iden3/go-iden3-core/v2"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = hexutil.MustDecode("0x4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+
+func testAccount(t *testing.T) (common.Address, []byte) {
+	t.Helper()
+	privateKey, err := crypto.ToECDSA(testKey)
+	require.NoError(t, err)
+	return crypto.PubkeyToAddress(privateKey.PublicKey), crypto.CompressPubkey(&privateKey.PublicKey)
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	address, compressed := testAccount(t)
+	typ, err := core.BuildDIDType(core.DIDMethodPolygonID, core.Polygon, core.Amoy)
+	require.NoError(t, err)
+	iden3DID, err := core.ParseDIDFromID(core.NewID(typ, core.GenesisFromEthAddress(address)))
+	require.NoError(t, err)
+	keyDID := "did:key:z" + base58.Encode(append([]byte{0xe7, 0x01}, compressed...))
+
+	tests := []struct {
+		name            string
+		did             string
+		expectedChainID int64
+		expectedErr     error
+	}{
+		{
+			name:            "Polygon ID",
+			did:             iden3DID.String(),
+			expectedChainID: 80002,
+		},
+		{
+			name:            "ethr address",
+			did:             "did:ethr:" + address.Hex(),
+			expectedChainID: 1,
+		},
+		{
+			name:            "ethr network and public key",
+			did:             "did:ethr:sepolia:" + hexutil.Encode(compressed),
+			expectedChainID: 11155111,
+		},
+		{
+			name:            "ethr hex chain id",
+			did:             "did:ethr:0x89:" + address.Hex(),
+			expectedChainID: 137,
+		},
+		{
+			name:        "ethr unknown network",
+			did:         "did:ethr:moon:" + address.Hex(),
+			expectedErr: ErrInvalidDID,
+		},
+		{
+			name: "key",
+			did:  keyDID,
+		},
+		{
+			name:        "ed25519 key",
+			did:         "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK",
+			expectedErr: ErrUnsupportedMethod,
+		},
+		{
+			name:        "Unregistered method",
+			did:         "did:example:123",
+			expectedErr: ErrUnsupportedMethod,
+		},
+		{
+			name:        "Not a DID",
+			did:         "0x123",
+			expectedErr: ErrInvalidDID,
+		},
+	}
+
+	registry := NewDefaultRegistry(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := registry.Resolve(context.Background(), tt.did)
+			if tt.expectedErr != nil {
+				require.True(t, errors.Is(err, tt.expectedErr), err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.did, doc.ID)
+			require.Equal(t, tt.expectedChainID, doc.ChainID)
+			require.True(t, doc.Controls(address))
+		})
+	}
+}
+
+func TestWeb_Resolve(t *testing.T) {
+	address, compressed := testAccount(t)
+	privateKey, err := crypto.ToECDSA(testKey)
+	require.NoError(t, err)
+	uncompressed := crypto.FromECDSAPub(&privateKey.PublicKey)
+
+	var document string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/alice/did.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(document))
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+	did := "did:web:" + strings.ReplaceAll(host, ":", "%3A") + ":users:alice"
+
+	tests := []struct {
+		name           string
+		document       string
+		expectedErr    bool
+		expectedOwners int
+	}{
+		{
+			name: "Blockchain account",
+			document: fmt.Sprintf(`{"id": %q, "verificationMethod": [{"id": "#owner",
+				"blockchainAccountId": "eip155:1:%s"}], "authentication": ["#owner"]}`, did, address.Hex()),
+			expectedOwners: 1,
+		},
+		{
+			name: "Embedded keys",
+			document: fmt.Sprintf(`{"id": %q, "authentication": [
+				{"id": "#hex", "publicKeyHex": %q},
+				{"id": "#jwk", "publicKeyJwk": {"kty": "EC", "crv": "secp256k1", "x": %q, "y": %q}},
+				{"id": "#ed25519", "publicKeyMultibase": "z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK"}
+			]}`, did, hexutil.Encode(compressed)[2:],
+				base64.RawURLEncoding.EncodeToString(uncompressed[1:33]),
+				base64.RawURLEncoding.EncodeToString(uncompressed[33:])),
+			expectedOwners: 2,
+		},
+		{
+			name: "Assertion only",
+			document: fmt.Sprintf(`{"id": %q, "verificationMethod": [{"id": "#owner",
+				"blockchainAccountId": "eip155:1:%s"}], "assertionMethod": ["#owner"]}`, did, address.Hex()),
+		},
+		{
+			name:        "Other DID",
+			document:    `{"id": "did:web:example.com"}`,
+			expectedErr: true,
+		},
+	}
+
+	resolver := Web{Client: srv.Client()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document = tt.document
+			doc, err := resolver.Resolve(context.Background(), did)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, doc.Controllers, tt.expectedOwners)
+			require.Equal(t, tt.expectedOwners > 0, doc.Controls(address))
+		})
+	}
+}
+
+func TestWebDocumentURL(t *testing.T) {
+	tests := []struct {
+		did      string
+		expected string
+	}{
+		{did: "did:web:example.com", expected: "https://example.com/.well-known/did.json"},
+		{did: "did:web:example.com%3A8443:users:alice", expected: "https://example.com:8443/users/alice/did.json"},
+		{did: "did:web:example.com%2Fadmin"},
+		{did: "did:web:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.did, func(t *testing.T) {
+			u, err := WebDocumentURL(tt.did)
+			if tt.expected == "" {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, u)
+		})
+	}
+}
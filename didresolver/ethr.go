@@ -0,0 +1,64 @@
+package didresolver
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// ethrNetworks are the chain ids of the network names did:ethr uses.
+var ethrNetworks = map[string]int64{
+	"mainnet": 1,
+	"sepolia": 11155111,
+	"polygon": 137,
+	"amoy":    80002,
+	"linea":   59144,
+}
+
+// Ethr resolves did:ethr:[<network>:]<address or public key>. The account
+// of the identifier is the controller; owner changes and delegates set in
+// the ERC-1056 registry are not read.
+type Ethr struct{}
+
+func (Ethr) Resolve(_ context.Context, did string) (Document, error) {
+	parts := strings.Split(strings.TrimPrefix(did, "did:ethr:"), ":")
+	if !strings.HasPrefix(did, "did:ethr:") || len(parts) > 2 {
+		return Document{}, errors.Wrapf(ErrInvalidDID, "'%s'", did)
+	}
+	chainID := ethrNetworks["mainnet"]
+	if len(parts) == 2 {
+		network := parts[0]
+		id, ok := ethrNetworks[network]
+		if !ok {
+			var err error
+			id, err = strconv.ParseInt(strings.TrimPrefix(network, "0x"), 16, 64)
+			if err != nil || !strings.HasPrefix(network, "0x") {
+				return Document{}, errors.Wrapf(ErrInvalidDID, "unknown network '%s' of '%s'", network, did)
+			}
+		}
+		chainID = id
+	}
+
+	identifier := parts[len(parts)-1]
+	var address common.Address
+	switch {
+	case common.IsHexAddress(identifier) && len(identifier) == 2+2*common.AddressLength:
+		address = common.HexToAddress(identifier)
+	default:
+		key, err := hexutil.Decode(identifier)
+		if err != nil {
+			return Document{}, errors.Wrapf(ErrInvalidDID, "'%s': %v", did, err)
+		}
+		pub, err := crypto.DecompressPubkey(key)
+		if err != nil {
+			return Document{}, errors.Wrapf(ErrInvalidDID, "'%s': %v", did, err)
+		}
+		address = crypto.PubkeyToAddress(*pub)
+	}
+	return Document{ID: did, ChainID: chainID, Controllers: []common.Address{address}}, nil
+}
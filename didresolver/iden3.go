@@ -0,0 +1,39 @@
+package didresolver
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	core "github.com/iden3/go-iden3-core/v2"
+	"github.com/iden3/go-iden3-core/v2/w3c"
+	"github.com/pkg/errors"
+)
+
+// Iden3 resolves the iden3 and polygonid DIDs created from an Ethereum
+// address, which is the only controller. DIDs of other identities have no
+// Ethereum controller.
+type Iden3 struct{}
+
+func (Iden3) Resolve(_ context.Context, did string) (Document, error) {
+	parsed, err := w3c.ParseDID(did)
+	if err != nil {
+		return Document{}, errors.Wrapf(ErrInvalidDID, "'%s': %v", did, err)
+	}
+	chainID, err := core.ChainIDfromDID(*parsed)
+	if err != nil {
+		return Document{}, errors.Errorf("failed to get chain id of '%s': %v", did, err)
+	}
+	id, err := core.IDFromDID(*parsed)
+	if err != nil {
+		return Document{}, errors.Wrapf(ErrInvalidDID, "'%s': %v", did, err)
+	}
+	address, err := core.EthAddressFromID(id)
+	if err != nil {
+		return Document{}, errors.Errorf("'%s' is not Ethereum controlled: %v", did, err)
+	}
+	return Document{
+		ID:          did,
+		ChainID:     int64(chainID),
+		Controllers: []common.Address{common.Address(address)},
+	}, nil
+}
@@ -0,0 +1,51 @@
+package didresolver
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+)
+
+// secp256k1Multicodec is the varint encoded multicodec of a compressed
+// secp256k1 public key.
+var secp256k1Multicodec = []byte{0xe7, 0x01}
+
+// Key resolves did:key DIDs of secp256k1 keys, whose account is the
+// controller. Other key types can't sign for an Ethereum account.
+type Key struct{}
+
+func (Key) Resolve(_ context.Context, did string) (Document, error) {
+	if !strings.HasPrefix(did, "did:key:") {
+		return Document{}, errors.Wrapf(ErrInvalidDID, "'%s'", did)
+	}
+	address, err := multibaseAddress(strings.TrimPrefix(did, "did:key:"))
+	if err != nil {
+		return Document{}, errors.Wrapf(err, "'%s'", did)
+	}
+	return Document{ID: did, Controllers: []common.Address{address}}, nil
+}
+
+// multibaseAddress returns the account of a base58btc multibase encoded
+// secp256k1 public key.
+func multibaseAddress(value string) (common.Address, error) {
+	if !strings.HasPrefix(value, "z") {
+		return common.Address{}, errors.Wrap(ErrInvalidDID, "only base58btc multibase keys are supported")
+	}
+	decoded, err := base58.Decode(value[1:])
+	if err != nil {
+		return common.Address{}, errors.Wrapf(ErrInvalidDID, "invalid base58: %v", err)
+	}
+	if !bytes.HasPrefix(decoded, secp256k1Multicodec) {
+		return common.Address{}, errors.Wrap(ErrUnsupportedMethod, "only secp256k1 keys are supported")
+	}
+	pub, err := crypto.DecompressPubkey(decoded[len(secp256k1Multicodec):])
+	if err != nil {
+		return common.Address{}, errors.Wrapf(ErrInvalidDID, "invalid secp256k1 key: %v", err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
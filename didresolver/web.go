@@ -0,0 +1,180 @@
+package didresolver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// maxDocumentSize caps a fetched DID document.
+const maxDocumentSize = 1024 * 1024
+
+// Web resolves did:web DIDs by fetching their DID document over HTTPS. The
+// controllers are the accounts of the verification methods listed under
+// authentication.
+type Web struct {
+	Client *http.Client
+}
+
+type webDocument struct {
+	ID                 string               `json:"id"`
+	VerificationMethod []verificationMethod `json:"verificationMethod"`
+	Authentication     []json.RawMessage    `json:"authentication"`
+}
+
+type verificationMethod struct {
+	ID                  string `json:"id"`
+	BlockchainAccountID string `json:"blockchainAccountId"`
+	PublicKeyHex        string `json:"publicKeyHex"`
+	PublicKeyMultibase  string `json:"publicKeyMultibase"`
+	PublicKeyJwk        *struct {
+		Kty string `json:"kty"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	} `json:"publicKeyJwk"`
+}
+
+func (w Web) Resolve(ctx context.Context, did string) (Document, error) {
+	documentURL, err := WebDocumentURL(did)
+	if err != nil {
+		return Document{}, err
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, documentURL, http.NoBody)
+	if err != nil {
+		return Document{}, errors.Errorf("failed to create DID document request: %v", err)
+	}
+	req.Header.Set("Accept", "application/did+json, application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return Document{}, errors.Errorf("failed to fetch DID document of '%s': %v", did, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Document{}, errors.Errorf("failed to fetch DID document of '%s': status code '%d'", did, resp.StatusCode)
+	}
+	var doc webDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(&doc); err != nil {
+		return Document{}, errors.Errorf("failed to parse DID document of '%s': %v", did, err)
+	}
+	if doc.ID != did {
+		return Document{}, errors.Errorf("DID document of '%s' is for '%s'", did, doc.ID)
+	}
+
+	methods := make(map[string]verificationMethod, len(doc.VerificationMethod))
+	for _, m := range doc.VerificationMethod {
+		methods[m.ID] = m
+		// references may be relative to the DID
+		if strings.HasPrefix(m.ID, "#") {
+			methods[did+m.ID] = m
+		}
+	}
+	resolved := Document{ID: did}
+	for _, raw := range doc.Authentication {
+		var (
+			m   verificationMethod
+			ref string
+		)
+		if err := json.Unmarshal(raw, &ref); err == nil {
+			var ok bool
+			if m, ok = methods[ref]; !ok {
+				continue
+			}
+		} else if err := json.Unmarshal(raw, &m); err != nil {
+			continue
+		}
+		// methods which are not Ethereum keys can't sign for an account
+		if address, ok := m.address(); ok {
+			resolved.Controllers = append(resolved.Controllers, address)
+		}
+	}
+	return resolved, nil
+}
+
+// WebDocumentURL returns where the DID document of a did:web DID is, e.g.
+// 'https://example.com/user/alice/did.json' for
+// 'did:web:example.com:user:alice'.
+func WebDocumentURL(did string) (string, error) {
+	if !strings.HasPrefix(did, "did:web:") {
+		return "", errors.Wrapf(ErrInvalidDID, "'%s'", did)
+	}
+	segments := strings.Split(strings.TrimPrefix(did, "did:web:"), ":")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil || unescaped == "" || strings.ContainsAny(unescaped, "/?#@") {
+			return "", errors.Wrapf(ErrInvalidDID, "'%s'", did)
+		}
+		segments[i] = unescaped
+	}
+	if len(segments) == 1 {
+		return "https://" + segments[0] + "/.well-known/did.json", nil
+	}
+	return "https://" + strings.Join(segments, "/") + "/did.json", nil
+}
+
+// address returns the account of a blockchain account id or a secp256k1
+// public key.
+func (m verificationMethod) address() (common.Address, bool) {
+	switch {
+	case m.BlockchainAccountID != "":
+		// CAIP-10, e.g. 'eip155:1:0xab16a96D359eC26a11e2C2b3d8f8B8942d5Bfcdb'
+		parts := strings.Split(m.BlockchainAccountID, ":")
+		if len(parts) != 3 || parts[0] != "eip155" || !common.IsHexAddress(parts[2]) {
+			return common.Address{}, false
+		}
+		return common.HexToAddress(parts[2]), true
+	case m.PublicKeyHex != "":
+		key, err := hexutil.Decode("0x" + strings.TrimPrefix(m.PublicKeyHex, "0x"))
+		if err != nil {
+			return common.Address{}, false
+		}
+		return keyAddress(key)
+	case m.PublicKeyMultibase != "":
+		address, err := multibaseAddress(m.PublicKeyMultibase)
+		return address, err == nil
+	case m.PublicKeyJwk != nil:
+		jwk := m.PublicKeyJwk
+		if jwk.Kty != "EC" || jwk.Crv != "secp256k1" {
+			return common.Address{}, false
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+		y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return common.Address{}, false
+		}
+		return keyAddress(append([]byte{0x04}, append(x, y...)...))
+	}
+	return common.Address{}, false
+}
+
+// keyAddress returns the account of a compressed or uncompressed secp256k1
+// public key.
+func keyAddress(key []byte) (common.Address, bool) {
+	var (
+		pub *ecdsa.PublicKey
+		err error
+	)
+	if len(key) == 33 {
+		pub, err = crypto.DecompressPubkey(key)
+	} else {
+		pub, err = crypto.UnmarshalPubkey(key)
+	}
+	if err != nil {
+		return common.Address{}, false
+	}
+	return crypto.PubkeyToAddress(*pub), true
+}
@@ -0,0 +1,94 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+)
+
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Cipher encrypts data at rest. Implementations backed by a KMS can be
+// used instead of the local AES-GCM keys.
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// AESGCM encrypts with the primary key and decrypts with any known key,
+// so keys can be rotated without re-encrypting stored data.
+// Ciphertext layout: len(keyID) | keyID | nonce | sealed data.
+type AESGCM struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewAESGCM creates a cipher from base64 encoded 16, 24 or 32 byte keys.
+func NewAESGCM(keys map[string]string, primary string) (*AESGCM, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, errors.Wrapf(ErrUnknownKey, "primary key '%s'", primary)
+	}
+	c := &AESGCM{
+		primary: primary,
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+	}
+	for id, encoded := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, errors.Errorf("invalid key id '%s'", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Errorf("key '%s' is not base64: %v", id, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.Errorf("invalid key '%s': %v", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Errorf("invalid key '%s': %v", id, err)
+		}
+		c.keys[id] = aead
+	}
+	return c, nil
+}
+
+func (c *AESGCM) Encrypt(plaintext []byte) ([]byte, error) {
+	aead := c.keys[c.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Errorf("failed to generate nonce: %v", err)
+	}
+	out := make([]byte, 0, 1+len(c.primary)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, byte(len(c.primary)))
+	out = append(out, c.primary...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, []byte(c.primary)), nil
+}
+
+func (c *AESGCM) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 1 {
+		return nil, errors.New("ciphertext is too short")
+	}
+	idLen := int(ciphertext[0])
+	if len(ciphertext) < 1+idLen {
+		return nil, errors.New("ciphertext is too short")
+	}
+	keyID := string(ciphertext[1 : 1+idLen])
+	aead, ok := c.keys[keyID]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownKey, "key '%s'", keyID)
+	}
+	rest := ciphertext[1+idLen:]
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, errors.Errorf("failed to decrypt: %v", err)
+	}
+	return plaintext, nil
+}
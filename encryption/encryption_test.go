@@ -0,0 +1,45 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	newKey = base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+)
+
+func TestAESGCM(t *testing.T) {
+	old, err := NewAESGCM(map[string]string{"v1": oldKey}, "v1")
+	require.NoError(t, err)
+	sealed, err := old.Encrypt([]byte(`{"birthday": 19960424}`))
+	require.NoError(t, err)
+	require.NotContains(t, string(sealed), "birthday")
+
+	rotated, err := NewAESGCM(map[string]string{"v1": oldKey, "v2": newKey}, "v2")
+	require.NoError(t, err)
+	plaintext, err := rotated.Decrypt(sealed)
+	require.NoError(t, err)
+	require.Equal(t, `{"birthday": 19960424}`, string(plaintext))
+
+	resealed, err := rotated.Encrypt(plaintext)
+	require.NoError(t, err)
+	_, err = old.Decrypt(resealed)
+	require.ErrorIs(t, err, ErrUnknownKey)
+
+	resealed[len(resealed)-1] ^= 0xff
+	_, err = rotated.Decrypt(resealed)
+	require.Error(t, err)
+}
+
+func TestNewAESGCM_Error(t *testing.T) {
+	_, err := NewAESGCM(map[string]string{"v1": oldKey}, "v2")
+	require.ErrorIs(t, err, ErrUnknownKey)
+	_, err = NewAESGCM(map[string]string{"v1": "not base64"}, "v1")
+	require.Error(t, err)
+	_, err = NewAESGCM(map[string]string{"v1": base64.StdEncoding.EncodeToString([]byte("short"))}, "v1")
+	require.Error(t, err)
+}
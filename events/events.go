@@ -0,0 +1,60 @@
+// Package events publishes the refresh lifecycle to a message bus, so
+// analytics and fraud pipelines can follow reissues as they happen.
+package events
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Event types of the refresh lifecycle.
+const (
+	TypeRefreshRequested = "refresh.requested"
+	TypeRefreshSucceeded = "refresh.succeeded"
+	TypeRefreshFailed    = "refresh.failed"
+)
+
+// Event is published as JSON. Outcome fields are set on succeeded and
+// failed events only.
+type Event struct {
+	Type           string    `json:"type"`
+	Time           time.Time `json:"time"`
+	RequestID      string    `json:"requestId,omitempty"`
+	Issuer         string    `json:"issuer"`
+	Owner          string    `json:"owner"`
+	CredentialID   string    `json:"credentialId"`
+	CredentialType string    `json:"credentialType,omitempty"`
+	RefreshedID    string    `json:"refreshedId,omitempty"`
+	ErrorCode      int       `json:"errorCode,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMillis int64     `json:"durationMs,omitempty"`
+}
+
+// Publisher sends events to a message bus. Publish must not wait for the
+// bus to acknowledge the event, it runs on the refresh path.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+	Close() error
+}
+
+// New connects to the bus at rawURL, 'nats://host:4222' or
+// 'kafka://broker1:9092,broker2:9092'. Events are published to topic, on
+// NATS with the event type appended as a subject token, e.g.
+// 'refresh-service.refresh.succeeded'.
+func New(rawURL, topic string) (Publisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Errorf("invalid events url: %v", err)
+	}
+	switch u.Scheme {
+	case "nats", "tls":
+		return NewNATS(rawURL, topic)
+	case "kafka":
+		return NewKafka(strings.Split(u.Host, ","), topic), nil
+	}
+	return nil, errors.Errorf("unsupported events url scheme '%s'", u.Scheme)
+}
@@ -0,0 +1,24 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	publisher, err := New("kafka://broker1:9092,broker2:9092", "refresh-service")
+	require.NoError(t, err)
+	kafkaPublisher, ok := publisher.(*Kafka)
+	require.True(t, ok)
+	require.Equal(t, "refresh-service", kafkaPublisher.writer.Topic)
+	require.Equal(t, "broker1:9092,broker2:9092", kafkaPublisher.writer.Addr.String())
+	require.NoError(t, publisher.Close())
+
+	_, err = New("amqp://broker:5672", "refresh-service")
+	require.ErrorContains(t, err, "unsupported events url scheme 'amqp'")
+
+	// nothing listens here
+	_, err = New("nats://127.0.0.1:1", "refresh-service")
+	require.ErrorContains(t, err, "failed to connect to nats")
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/segmentio/kafka-go"
+)
+
+// Kafka publishes events to a topic, keyed by credential id so the events
+// of a credential stay in order. The event type is in the 'type' header.
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+// NewKafka writes to topic on brokers. Events are batched and sent in the
+// background, failures are logged.
+func NewKafka(brokers []string, topic string) *Kafka {
+	return &Kafka{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireOne,
+		Async:        true,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				logger.SampledWarnf("failed to publish %d events to kafka: %v", len(messages), err)
+			}
+		},
+	}}
+}
+
+func (k *Kafka) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return k.writer.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(event.CredentialID),
+		Value:   payload,
+		Headers: []kafka.Header{{Key: "type", Value: []byte(event.Type)}},
+	})
+}
+
+// Close flushes pending events.
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// NATS publishes events to the subject '<topic>.<event type>'.
+type NATS struct {
+	conn  *nats.Conn
+	topic string
+}
+
+// NewNATS connects to the NATS servers in url. The client reconnects on its
+// own and buffers events published in the meantime.
+func NewNATS(url, topic string) (*NATS, error) {
+	conn, err := nats.Connect(url, nats.Name("refresh-service"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, errors.Errorf("failed to connect to nats: %v", err)
+	}
+	return &NATS{conn: conn, topic: topic}, nil
+}
+
+func (n *NATS) Publish(_ context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return n.conn.Publish(n.topic+"."+event.Type, payload)
+}
+
+// Close sends the buffered events before closing the connection.
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
@@ -0,0 +1,136 @@
+// Package features gates risky behaviors with flags which are enabled per
+// issuer or for a percentage of requests, so they can be rolled out
+// gradually.
+package features
+
+import (
+	"context"
+	"hash/fnv"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+)
+
+// Flag enables a feature for everyone, for the listed issuers or for a
+// percentage of the requests.
+type Flag struct {
+	Enabled bool     `yaml:"enabled" json:"enabled"`
+	Issuers []string `yaml:"issuers" json:"issuers"`
+	// Percentage is the share of keys, from 0 to 100, the feature is
+	// enabled for.
+	Percentage float64 `yaml:"percentage" json:"percentage"`
+}
+
+// Target is what a flag is evaluated for. Key keeps the outcome of a
+// percentage rollout stable, e.g. the credential id.
+type Target struct {
+	Issuer string
+	Key    string
+}
+
+// On reports whether the flag name enables its feature for target.
+func (f Flag) On(name string, target Target) bool {
+	if f.Enabled {
+		return true
+	}
+	for _, issuer := range f.Issuers {
+		if issuer == target.Issuer {
+			return true
+		}
+	}
+	if f.Percentage <= 0 {
+		return false
+	}
+	// the name is hashed along, so features don't roll out to the same keys
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name + ":" + target.Key))
+	return float64(h.Sum32()%10000) < f.Percentage*100
+}
+
+// Source loads flags by name.
+type Source interface {
+	Load(ctx context.Context) (map[string]Flag, error)
+}
+
+// Set holds the current flags. A nil Set has no flags.
+type Set struct {
+	flags    atomic.Pointer[map[string]Flag]
+	onChange atomic.Pointer[func(before, after map[string]Flag)]
+}
+
+// NewSet returns a set of flags.
+func NewSet(flags map[string]Flag) *Set {
+	s := &Set{}
+	s.Update(flags)
+	return s
+}
+
+// Load returns the flags of source.
+func Load(ctx context.Context, source Source) (*Set, error) {
+	flags, err := source.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewSet(flags), nil
+}
+
+// Enabled reports whether the feature name is enabled for target. Features
+// without a flag keep their fallback.
+func (s *Set) Enabled(name string, target Target, fallback bool) bool {
+	if s == nil {
+		return fallback
+	}
+	flag, ok := (*s.flags.Load())[name]
+	if !ok {
+		return fallback
+	}
+	return flag.On(name, target)
+}
+
+// Update replaces all flags.
+func (s *Set) Update(flags map[string]Flag) {
+	if flags == nil {
+		flags = make(map[string]Flag)
+	}
+	before := s.flags.Swap(&flags)
+	if fn := s.onChange.Load(); fn != nil && before != nil && !reflect.DeepEqual(*before, flags) {
+		(*fn)(*before, flags)
+	}
+}
+
+// OnChange calls fn whenever an update changes the flags.
+func (s *Set) OnChange(fn func(before, after map[string]Flag)) {
+	s.onChange.Store(&fn)
+}
+
+// Reload loads flags from source. On failure the current flags are kept.
+func (s *Set) Reload(ctx context.Context, source Source) error {
+	flags, err := source.Load(ctx)
+	if err != nil {
+		return err
+	}
+	s.Update(flags)
+	return nil
+}
+
+// Watch reloads flags every interval, it returns right away when interval
+// is not positive.
+func (s *Set) Watch(ctx context.Context, source Source, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.Reload(ctx, source); err != nil {
+			logger.DefaultLogger.Errorf("failed to reload feature flags, keeping current values: %v", err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package features
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlag_On(t *testing.T) {
+	tests := []struct {
+		name     string
+		flag     Flag
+		target   Target
+		expected bool
+	}{
+		{
+			name:     "Enabled",
+			flag:     Flag{Enabled: true},
+			target:   Target{Issuer: "did:issuer:a"},
+			expected: true,
+		},
+		{
+			name:     "Listed issuer",
+			flag:     Flag{Issuers: []string{"did:issuer:a"}},
+			target:   Target{Issuer: "did:issuer:a"},
+			expected: true,
+		},
+		{
+			name:   "Other issuer",
+			flag:   Flag{Issuers: []string{"did:issuer:a"}},
+			target: Target{Issuer: "did:issuer:b"},
+		},
+		{
+			name:     "Full rollout",
+			flag:     Flag{Percentage: 100},
+			target:   Target{Key: "1"},
+			expected: true,
+		},
+		{
+			name:   "Disabled",
+			target: Target{Issuer: "did:issuer:a", Key: "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.flag.On("feature", tt.target))
+		})
+	}
+}
+
+func TestFlag_On_Percentage(t *testing.T) {
+	flag := Flag{Percentage: 25}
+	var on int
+	for i := 0; i < 10000; i++ {
+		key := strconv.Itoa(i)
+		enabled := flag.On("feature", Target{Key: key})
+		// the outcome is stable for a key
+		require.Equal(t, enabled, flag.On("feature", Target{Key: key}))
+		if enabled {
+			on++
+		}
+	}
+	require.InDelta(t, 2500, on, 250)
+}
+
+func TestSet_Enabled(t *testing.T) {
+	set := NewSet(map[string]Flag{"on": {Enabled: true}, "off": {}})
+	require.True(t, set.Enabled("on", Target{}, false))
+	require.False(t, set.Enabled("off", Target{}, true))
+	require.True(t, set.Enabled("unknown", Target{}, true))
+
+	var nilSet *Set
+	require.True(t, nilSet.Enabled("on", Target{}, true))
+	require.False(t, nilSet.Enabled("on", Target{}, false))
+}
+
+func TestSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+batch-messages:
+  issuers: [did:issuer:a]
+new-provider:
+  percentage: 10
+`), 0o600))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"batch-messages": {"issuers": ["did:issuer:a"]}, "new-provider": {"percentage": 10}}`))
+	}))
+	defer srv.Close()
+	expected := map[string]Flag{
+		"batch-messages": {Issuers: []string{"did:issuer:a"}},
+		"new-provider":   {Percentage: 10},
+	}
+
+	tests := []struct {
+		name   string
+		source Source
+	}{
+		{name: "File", source: FileSource{Path: path}},
+		{name: "Remote provider", source: HTTPSource{URL: srv.URL}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := tt.source.Load(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, expected, flags)
+		})
+	}
+}
+
+func TestSet_Reload(t *testing.T) {
+	set := NewSet(map[string]Flag{"feature": {Enabled: true}})
+	err := set.Reload(context.Background(), FileSource{Path: filepath.Join(t.TempDir(), "missing.yaml")})
+	require.Error(t, err)
+	// the current flags are kept
+	require.True(t, set.Enabled("feature", Target{}, false))
+}
+
+func TestSet_Watch_Disabled(t *testing.T) {
+	set := NewSet(map[string]Flag{"feature": {Enabled: true}})
+	for _, interval := range []time.Duration{0, -time.Second} {
+		// returns instead of panicking on the ticker
+		set.Watch(context.Background(), FileSource{Path: filepath.Join(t.TempDir(), "missing.yaml")}, interval)
+	}
+	require.True(t, set.Enabled("feature", Target{}, false))
+}
+
+func TestSet_OnChange(t *testing.T) {
+	set := NewSet(map[string]Flag{"feature": {Enabled: true}})
+	var calls []map[string]Flag
+	set.OnChange(func(before, after map[string]Flag) {
+		calls = append(calls, before, after)
+	})
+
+	set.Update(map[string]Flag{"feature": {Enabled: true}})
+	require.Empty(t, calls)
+
+	set.Update(map[string]Flag{"feature": {Percentage: 10}})
+	require.Equal(t, []map[string]Flag{
+		{"feature": {Enabled: true}},
+		{"feature": {Percentage: 10}},
+	}, calls)
+}
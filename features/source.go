@@ -0,0 +1,70 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// FileSource reads flags from a YAML file:
+//
+//	batch-messages:
+//	  issuers: [did:iden3:polygon:amoy:x7Z...]
+//	new-balance-provider:
+//	  percentage: 10
+type FileSource struct {
+	Path string
+}
+
+func (fs FileSource) Load(_ context.Context) (map[string]Flag, error) {
+	//nolint:gosec // flags path comes from the service configuration
+	content, err := os.ReadFile(fs.Path)
+	if err != nil {
+		return nil, errors.Errorf("failed to read feature flags '%s': %v", fs.Path, err)
+	}
+	flags := make(map[string]Flag)
+	if err := yaml.Unmarshal(content, &flags); err != nil {
+		return nil, errors.Errorf("failed to parse feature flags '%s': %v", fs.Path, err)
+	}
+	return flags, nil
+}
+
+// HTTPSource reads flags from a remote provider answering GET requests
+// with the flags as a JSON object by name.
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// maxFlagsSize caps the response of a remote provider.
+const maxFlagsSize = 1024 * 1024
+
+func (hs HTTPSource) Load(ctx context.Context) (map[string]Flag, error) {
+	client := hs.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hs.URL, http.NoBody)
+	if err != nil {
+		return nil, errors.Errorf("failed to create feature flags request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Errorf("failed to fetch feature flags: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to fetch feature flags: status code '%d'", resp.StatusCode)
+	}
+	flags := make(map[string]Flag)
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxFlagsSize)).Decode(&flags); err != nil {
+		return nil, errors.Errorf("failed to parse feature flags: %v", err)
+	}
+	return flags, nil
+}
@@ -0,0 +1,179 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded"
+)
+
+// Checker reports the health of a single dependency.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to the Checker interface.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type dependency struct {
+	checker  Checker
+	critical bool
+}
+
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latencyMs"`
+}
+
+type Report struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// Aggregator runs dependency checks concurrently and combines them into
+// a single report. A failing critical dependency marks the service down,
+// a failing non-critical one marks it degraded.
+type Aggregator struct {
+	mu           sync.RWMutex
+	timeout      time.Duration
+	dependencies map[string]dependency
+	patterns     []string
+
+	// checkMu serializes checks while reports are cached, so concurrent
+	// probes share one round of checks
+	checkMu   sync.Mutex
+	cacheTTL  time.Duration
+	cached    Report
+	checkedAt time.Time
+}
+
+func NewAggregator(timeout time.Duration) *Aggregator {
+	return &Aggregator{
+		timeout:      timeout,
+		dependencies: make(map[string]dependency),
+	}
+}
+
+func (a *Aggregator) Register(name string, checker Checker, critical bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.dependencies[name] = dependency{
+		checker:  checker,
+		critical: critical,
+	}
+}
+
+func (a *Aggregator) Names() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	names := make([]string, 0, len(a.dependencies))
+	for name := range a.dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CacheFor keeps a report for ttl, so frequent probes don't each call every
+// dependency. Zero checks on every call.
+func (a *Aggregator) CacheFor(ttl time.Duration) {
+	a.checkMu.Lock()
+	defer a.checkMu.Unlock()
+	a.cacheTTL = ttl
+}
+
+// Check reports the health of the dependencies, from the cache when the
+// last report is younger than the cache TTL.
+func (a *Aggregator) Check(ctx context.Context) Report {
+	a.checkMu.Lock()
+	if a.cacheTTL <= 0 {
+		a.checkMu.Unlock()
+		return a.check(ctx)
+	}
+	defer a.checkMu.Unlock()
+	if a.checkedAt.IsZero() || time.Since(a.checkedAt) >= a.cacheTTL {
+		a.cached = a.check(ctx)
+		a.checkedAt = time.Now()
+	}
+	return a.cached
+}
+
+// WithoutErrors returns a copy of the report without the errors of the
+// dependencies, which may name internal hosts.
+func (r Report) WithoutErrors() Report {
+	dependencies := make(map[string]DependencyStatus, len(r.Dependencies))
+	for name, status := range r.Dependencies {
+		status.Error = ""
+		dependencies[name] = status
+	}
+	return Report{Status: r.Status, Dependencies: dependencies}
+}
+
+func (a *Aggregator) check(ctx context.Context) Report {
+	a.mu.RLock()
+	dependencies := make(map[string]dependency, len(a.dependencies))
+	for k, v := range a.dependencies {
+		if a.selected(k) {
+			dependencies[k] = v
+		}
+	}
+	a.mu.RUnlock()
+
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		report = Report{
+			Status:       StatusUp,
+			Dependencies: make(map[string]DependencyStatus, len(dependencies)),
+		}
+	)
+	for name, dep := range dependencies {
+		wg.Add(1)
+		go func(name string, dep dependency) {
+			defer wg.Done()
+			start := time.Now()
+			err := dep.checker.Check(ctx)
+			status := DependencyStatus{
+				Status:    StatusUp,
+				Critical:  dep.critical,
+				LatencyMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				status.Status = StatusDown
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Dependencies[name] = status
+			if err == nil {
+				return
+			}
+			if dep.critical {
+				report.Status = StatusDown
+			} else if report.Status == StatusUp {
+				report.Status = StatusDegraded
+			}
+		}(name, dep)
+	}
+	wg.Wait()
+	return report
+}
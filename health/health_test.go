@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregator_Check(t *testing.T) {
+	ok := CheckerFunc(func(context.Context) error { return nil })
+	failing := CheckerFunc(func(context.Context) error { return errors.New("connection refused") })
+
+	tests := []struct {
+		name           string
+		register       func(a *Aggregator)
+		expectedStatus string
+		expectedDeps   map[string]string
+	}{
+		{
+			name: "All dependencies are up",
+			register: func(a *Aggregator) {
+				a.Register("issuer", ok, true)
+				a.Register("provider", ok, false)
+			},
+			expectedStatus: StatusUp,
+			expectedDeps:   map[string]string{"issuer": StatusUp, "provider": StatusUp},
+		},
+		{
+			name: "Non critical dependency is down",
+			register: func(a *Aggregator) {
+				a.Register("issuer", ok, true)
+				a.Register("provider", failing, false)
+			},
+			expectedStatus: StatusDegraded,
+			expectedDeps:   map[string]string{"issuer": StatusUp, "provider": StatusDown},
+		},
+		{
+			name: "Critical dependency is down",
+			register: func(a *Aggregator) {
+				a.Register("issuer", failing, true)
+				a.Register("provider", failing, false)
+			},
+			expectedStatus: StatusDown,
+			expectedDeps:   map[string]string{"issuer": StatusDown, "provider": StatusDown},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAggregator(time.Second)
+			tt.register(a)
+			report := a.Check(context.Background())
+			require.Equal(t, tt.expectedStatus, report.Status)
+			require.Len(t, report.Dependencies, len(tt.expectedDeps))
+			for name, status := range tt.expectedDeps {
+				require.Equal(t, status, report.Dependencies[name].Status)
+			}
+		})
+	}
+}
+
+func TestAggregator_CheckTimeout(t *testing.T) {
+	a := NewAggregator(10 * time.Millisecond)
+	a.Register("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), true)
+	report := a.Check(context.Background())
+	require.Equal(t, StatusDown, report.Status)
+	require.Equal(t, context.DeadlineExceeded.Error(), report.Dependencies["slow"].Error)
+}
+
+func TestAggregator_CacheFor(t *testing.T) {
+	calls := 0
+	a := NewAggregator(time.Second)
+	a.Register("issuer", CheckerFunc(func(context.Context) error {
+		calls++
+		return errors.New("dial tcp 10.0.0.1:443: connection refused")
+	}), true)
+
+	a.Check(context.Background())
+	a.Check(context.Background())
+	require.Equal(t, 2, calls)
+
+	a.CacheFor(time.Hour)
+	report := a.Check(context.Background())
+	a.Check(context.Background())
+	require.Equal(t, 3, calls)
+	require.Equal(t, StatusDown, report.Status)
+
+	redacted := report.WithoutErrors()
+	require.Equal(t, StatusDown, redacted.Dependencies["issuer"].Status)
+	require.Empty(t, redacted.Dependencies["issuer"].Error)
+	require.NotEmpty(t, report.Dependencies["issuer"].Error)
+}
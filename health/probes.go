@@ -0,0 +1,114 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Gate is a condition which is met once, e.g. caches warmed at startup. It
+// fails its check until it is opened.
+type Gate struct {
+	opened atomic.Bool
+	reason string
+}
+
+// NewGate returns a closed gate, reason is reported while it is closed.
+func NewGate(reason string) *Gate {
+	return &Gate{reason: reason}
+}
+
+func (g *Gate) Open() {
+	g.opened.Store(true)
+}
+
+func (g *Gate) Check(context.Context) error {
+	if !g.opened.Load() {
+		return errors.New(g.reason)
+	}
+	return nil
+}
+
+// Heartbeat checks that the process still schedules its goroutines: it
+// beats every interval and fails its check when the last beat is older than
+// maxLag, e.g. when the process is starved or stuck in garbage collection.
+type Heartbeat struct {
+	interval time.Duration
+	maxLag   time.Duration
+	last     atomic.Int64
+}
+
+func NewHeartbeat(interval, maxLag time.Duration) *Heartbeat {
+	h := &Heartbeat{interval: interval, maxLag: maxLag}
+	h.last.Store(time.Now().UnixNano())
+	return h
+}
+
+// Run beats until ctx is done.
+func (h *Heartbeat) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.last.Store(now.UnixNano())
+		}
+	}
+}
+
+func (h *Heartbeat) Check(context.Context) error {
+	lag := time.Since(time.Unix(0, h.last.Load()))
+	if lag > h.maxLag {
+		return errors.Errorf("no heartbeat for %s", lag.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// Reachable checks that the server of rawURL answers a HEAD request with
+// client. Any HTTP response counts, only failed connections fail the check.
+func Reachable(client *http.Client, rawURL string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, http.NoBody)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	})
+}
+
+// Select restricts the checks of the aggregator to the dependencies whose
+// name matches one of patterns, in path.Match syntax, e.g. 'issuer:*'. All
+// dependencies are checked without patterns.
+func (a *Aggregator) Select(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "check pattern '%s'", pattern)
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.patterns = patterns
+	return nil
+}
+
+func (a *Aggregator) selected(name string) bool {
+	if len(a.patterns) == 0 {
+		return true
+	}
+	for _, pattern := range a.patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGate(t *testing.T) {
+	g := NewGate("caches are warming up")
+	require.EqualError(t, g.Check(context.Background()), "caches are warming up")
+	g.Open()
+	require.NoError(t, g.Check(context.Background()))
+}
+
+func TestHeartbeat(t *testing.T) {
+	h := NewHeartbeat(5*time.Millisecond, 50*time.Millisecond)
+	require.NoError(t, h.Check(context.Background()))
+
+	h.last.Store(time.Now().Add(-time.Second).UnixNano())
+	require.ErrorContains(t, h.Check(context.Background()), "no heartbeat for")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Run(ctx)
+	require.Eventually(t, func() bool {
+		return h.Check(context.Background()) == nil
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestReachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodHead, r.Method)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	require.NoError(t, Reachable(srv.Client(), srv.URL).Check(context.Background()))
+	srv.Close()
+	require.Error(t, Reachable(srv.Client(), srv.URL).Check(context.Background()))
+}
+
+func TestAggregator_Select(t *testing.T) {
+	ok := CheckerFunc(func(context.Context) error { return nil })
+
+	tests := []struct {
+		name         string
+		patterns     []string
+		expectedDeps []string
+		expectedErr  bool
+	}{
+		{
+			name:         "All dependencies without patterns",
+			expectedDeps: []string{"database", "issuer:a", "issuer:b", "provider:x"},
+		},
+		{
+			name:         "Selected dependencies",
+			patterns:     []string{"database", "issuer:*"},
+			expectedDeps: []string{"database", "issuer:a", "issuer:b"},
+		},
+		{
+			name:     "No dependency matches",
+			patterns: []string{"redis"},
+		},
+		{
+			name:        "Invalid pattern",
+			patterns:    []string{"issuer:["},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAggregator(time.Second)
+			for _, name := range []string{"database", "issuer:a", "issuer:b", "provider:x"} {
+				a.Register(name, ok, true)
+			}
+			err := a.Select(tt.patterns)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			report := a.Check(context.Background())
+			require.Equal(t, StatusUp, report.Status)
+			require.Len(t, report.Dependencies, len(tt.expectedDeps))
+			for _, name := range tt.expectedDeps {
+				require.Contains(t, report.Dependencies, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package httpclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RetryBudget bounds the retries of one refresh across data providers and
+// the issuer node, so the retries of each stage can't add up to a very long
+// request. Both the number of retries and the time spent in them count.
+type RetryBudget struct {
+	mu       sync.Mutex
+	retries  int
+	latency  time.Duration
+	used     int
+	spent    time.Duration
+	rejected int
+}
+
+type budgetKey struct{}
+
+// NewRetryBudget allows up to retries retries taking latency in total. Zero
+// values are not limited.
+func NewRetryBudget(retries int, latency time.Duration) *RetryBudget {
+	return &RetryBudget{retries: retries, latency: latency}
+}
+
+// WithRetryBudget attaches budget to ctx.
+func WithRetryBudget(ctx context.Context, budget *RetryBudget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+// Retry takes a retry from the budget of ctx. It returns false when the
+// budget is spent, otherwise done must be called once the retry has been
+// answered. Without a budget retries are not limited.
+func Retry(ctx context.Context) (done func(), ok bool) {
+	budget, _ := ctx.Value(budgetKey{}).(*RetryBudget)
+	if budget == nil {
+		return func() {}, true
+	}
+	return budget.take()
+}
+
+func (b *RetryBudget) take() (func(), bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if (b.retries > 0 && b.used >= b.retries) || (b.latency > 0 && b.spent >= b.latency) {
+		b.rejected++
+		return nil, false
+	}
+	b.used++
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			b.spent += time.Since(start)
+			b.mu.Unlock()
+		})
+	}, true
+}
+
+// Rejected returns how many retries were refused because the budget was
+// spent.
+func (b *RetryBudget) Rejected() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rejected
+}
@@ -0,0 +1,53 @@
+package httpclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		retries  int
+		latency  time.Duration
+		spend    time.Duration
+		allowed  int
+		rejected int
+	}{
+		{name: "Retries", retries: 3, allowed: 3, rejected: 2},
+		{name: "Latency", latency: 5 * time.Millisecond, spend: 10 * time.Millisecond, allowed: 1, rejected: 4},
+		{name: "Retries within latency", retries: 2, latency: time.Minute, allowed: 2, rejected: 3},
+		{name: "Unlimited", allowed: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := NewRetryBudget(tt.retries, tt.latency)
+			ctx := WithRetryBudget(context.Background(), budget)
+			var allowed int
+			for range 5 {
+				done, ok := Retry(ctx)
+				if !ok {
+					continue
+				}
+				allowed++
+				time.Sleep(tt.spend)
+				done()
+				done()
+			}
+			require.Equal(t, tt.allowed, allowed)
+			require.Equal(t, tt.rejected, budget.Rejected())
+		})
+	}
+}
+
+func TestRetry_WithoutBudget(t *testing.T) {
+	for range 10 {
+		done, ok := Retry(context.Background())
+		require.True(t, ok)
+		done()
+	}
+}
@@ -0,0 +1,175 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/metrics"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Fault targets are the upstreams faults are injected into.
+const (
+	FaultTargetProvider = "provider"
+	FaultTargetIssuer   = "issuer"
+)
+
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultRule delays and fails a share of the requests to Target made for a
+// credential type. It is meant for resilience tests in staging.
+type FaultRule struct {
+	Target string `yaml:"target"`
+	// CredentialType is the credential type of the refresh, '*' or empty
+	// for every refresh. Rules of a credential type take precedence.
+	CredentialType string `yaml:"credentialType"`
+	// Latency delays every request, Jitter adds up to that much more.
+	Latency time.Duration `yaml:"latency"`
+	Jitter  time.Duration `yaml:"jitter"`
+	// ErrorRate is the share of requests which fail, between 0 and 1.
+	// They are answered with Status, or fail to connect without it.
+	ErrorRate float64 `yaml:"errorRate"`
+	Status    int     `yaml:"status"`
+}
+
+// FaultInjector injects the faults of its rules into the requests of
+// wrapped clients.
+type FaultInjector struct {
+	rules  map[string]map[string]FaultRule
+	random func() float64
+}
+
+// LoadFaults reads the fault rules of a YAML file with a 'faults' list.
+func LoadFaults(path string) (*FaultInjector, error) {
+	//nolint:gosec // the path is set by the operator
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Errorf("failed to read faults: %v", err)
+	}
+	var config struct {
+		Faults []FaultRule `yaml:"faults"`
+	}
+	if err := yaml.Unmarshal(raw, &config); err != nil {
+		return nil, errors.Errorf("invalid faults '%s': %v", path, err)
+	}
+	return NewFaultInjector(config.Faults)
+}
+
+func NewFaultInjector(rules []FaultRule) (*FaultInjector, error) {
+	f := &FaultInjector{
+		rules:  make(map[string]map[string]FaultRule),
+		random: rand.Float64,
+	}
+	for i, rule := range rules {
+		switch {
+		case rule.Target != FaultTargetProvider && rule.Target != FaultTargetIssuer:
+			return nil, errors.Errorf("fault %d: unsupported target '%s'", i, rule.Target)
+		case rule.Latency < 0 || rule.Jitter < 0:
+			return nil, errors.Errorf("fault %d: latency must not be negative", i)
+		case rule.ErrorRate < 0 || rule.ErrorRate > 1:
+			return nil, errors.Errorf("fault %d: error rate must be between 0 and 1", i)
+		case rule.Status != 0 && (rule.Status < 100 || rule.Status > 599):
+			return nil, errors.Errorf("fault %d: invalid status %d", i, rule.Status)
+		}
+		if rule.CredentialType == "" {
+			rule.CredentialType = "*"
+		}
+		if f.rules[rule.Target] == nil {
+			f.rules[rule.Target] = make(map[string]FaultRule)
+		}
+		if _, ok := f.rules[rule.Target][rule.CredentialType]; ok {
+			return nil, errors.Errorf("fault %d: duplicate rule for '%s' of '%s'", i, rule.Target, rule.CredentialType)
+		}
+		f.rules[rule.Target][rule.CredentialType] = rule
+	}
+	return f, nil
+}
+
+type credentialTypeKey struct{}
+
+// WithCredentialType scopes the requests made with ctx to credentialType.
+// Requests without one, e.g. fetching the credential, only get the faults
+// of the rules for every credential type.
+func WithCredentialType(ctx context.Context, credentialType string) context.Context {
+	return context.WithValue(ctx, credentialTypeKey{}, credentialType)
+}
+
+// Wrap returns a copy of client whose requests to target get the faults.
+// The client keeps its faults through WithTLSConfig.
+func (f *FaultInjector) Wrap(client *http.Client, target string) *http.Client {
+	if f == nil || len(f.rules[target]) == 0 {
+		return client
+	}
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	faulty := *client
+	faulty.Transport = &faultTransport{injector: f, target: target, next: next}
+	return &faulty
+}
+
+func (f *FaultInjector) rule(target, credentialType string) (FaultRule, bool) {
+	if rule, ok := f.rules[target][credentialType]; ok && credentialType != "" {
+		return rule, true
+	}
+	rule, ok := f.rules[target]["*"]
+	return rule, ok
+}
+
+type faultTransport struct {
+	injector *FaultInjector
+	target   string
+	next     http.RoundTripper
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	credentialType, _ := req.Context().Value(credentialTypeKey{}).(string)
+	rule, ok := t.injector.rule(t.target, credentialType)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	label := rule.CredentialType
+
+	if delay := rule.Latency + time.Duration(t.injector.random()*float64(rule.Jitter)); delay > 0 {
+		metrics.InjectedFaults.WithLabelValues(t.target, label, "latency").Inc()
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	if rule.ErrorRate == 0 || t.injector.random() >= rule.ErrorRate {
+		return t.next.RoundTrip(req)
+	}
+
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	if rule.Status == 0 {
+		metrics.InjectedFaults.WithLabelValues(t.target, label, "connection").Inc()
+		return nil, errors.Wrapf(ErrInjectedFault, "connection to '%s' refused", req.URL.Host)
+	}
+	metrics.InjectedFaults.WithLabelValues(t.target, label, "status").Inc()
+	body := fmt.Sprintf(`{"error":"%s: status %d"}`, ErrInjectedFault, rule.Status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rule.Status, http.StatusText(rule.Status)),
+		StatusCode:    rule.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
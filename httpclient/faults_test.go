@@ -0,0 +1,177 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFaultInjector_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []FaultRule
+		expected string
+	}{
+		{
+			name:     "Unknown target",
+			rules:    []FaultRule{{Target: "database", ErrorRate: 0.5}},
+			expected: "fault 0: unsupported target 'database'",
+		},
+		{
+			name:     "Error rate over 1",
+			rules:    []FaultRule{{Target: FaultTargetIssuer, ErrorRate: 1.5}},
+			expected: "fault 0: error rate must be between 0 and 1",
+		},
+		{
+			name:     "Negative latency",
+			rules:    []FaultRule{{Target: FaultTargetIssuer, Latency: -time.Second}},
+			expected: "fault 0: latency must not be negative",
+		},
+		{
+			name:     "Invalid status",
+			rules:    []FaultRule{{Target: FaultTargetProvider, ErrorRate: 1, Status: 42}},
+			expected: "fault 0: invalid status 42",
+		},
+		{
+			name: "Duplicate rule",
+			rules: []FaultRule{
+				{Target: FaultTargetProvider, ErrorRate: 1},
+				{Target: FaultTargetProvider, CredentialType: "*", Latency: time.Second},
+			},
+			expected: "fault 1: duplicate rule for 'provider' of '*'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFaultInjector(tt.rules)
+			require.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+func TestFaultInjector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	faults, err := NewFaultInjector([]FaultRule{
+		{Target: FaultTargetProvider, ErrorRate: 0.5, Status: http.StatusServiceUnavailable},
+		{Target: FaultTargetProvider, CredentialType: "urn:balance", ErrorRate: 0.5},
+		{Target: FaultTargetIssuer, Latency: 20 * time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		target         string
+		credentialType string
+		random         float64
+		expectedStatus int
+		expectedErr    error
+		minDuration    time.Duration
+	}{
+		{
+			name:           "Request passes under the error rate",
+			target:         FaultTargetProvider,
+			random:         0.7,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Request fails with the status of the rule",
+			target:         FaultTargetProvider,
+			credentialType: "urn:kyc",
+			random:         0.2,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "Rule of the credential type fails to connect",
+			target:         FaultTargetProvider,
+			credentialType: "urn:balance",
+			random:         0.2,
+			expectedErr:    ErrInjectedFault,
+		},
+		{
+			name:           "Delayed request",
+			target:         FaultTargetIssuer,
+			random:         0.2,
+			expectedStatus: http.StatusOK,
+			minDuration:    20 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			faults.random = func() float64 { return tt.random }
+			client := faults.Wrap(NewClient(DefaultOptions, time.Second), tt.target)
+			ctx := context.Background()
+			if tt.credentialType != "" {
+				ctx = WithCredentialType(ctx, tt.credentialType)
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, http.NoBody)
+			require.NoError(t, err)
+
+			start := time.Now()
+			resp, err := client.Do(req)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+			require.GreaterOrEqual(t, time.Since(start), tt.minDuration)
+		})
+	}
+}
+
+func TestFaultInjector_Wrap(t *testing.T) {
+	faults, err := NewFaultInjector([]FaultRule{{Target: FaultTargetIssuer, ErrorRate: 1}})
+	require.NoError(t, err)
+
+	client := NewClient(DefaultOptions, time.Second)
+	require.Same(t, client, faults.Wrap(client, FaultTargetProvider))
+	var none *FaultInjector
+	require.Same(t, client, none.Wrap(client, FaultTargetIssuer))
+
+	// clients with their own TLS configuration keep the faults
+	tlsClient := WithTLSConfig(faults.Wrap(client, FaultTargetIssuer), &tls.Config{MinVersion: tls.VersionTLS13})
+	faulty, ok := tlsClient.Transport.(*faultTransport)
+	require.True(t, ok)
+	require.Equal(t, uint16(tls.VersionTLS13), faulty.next.(*http.Transport).TLSClientConfig.MinVersion)
+}
+
+func TestLoadFaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faults.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+faults:
+  - target: provider
+    credentialType: urn:balance
+    latency: 200ms
+    jitter: 100ms
+    errorRate: 0.1
+    status: 503
+`), 0o600))
+	faults, err := LoadFaults(path)
+	require.NoError(t, err)
+	rule, ok := faults.rule(FaultTargetProvider, "urn:balance")
+	require.True(t, ok)
+	require.Equal(t, FaultRule{
+		Target:         FaultTargetProvider,
+		CredentialType: "urn:balance",
+		Latency:        200 * time.Millisecond,
+		Jitter:         100 * time.Millisecond,
+		ErrorRate:      0.1,
+		Status:         503,
+	}, rule)
+	_, ok = faults.rule(FaultTargetProvider, "urn:kyc")
+	require.False(t, ok)
+}
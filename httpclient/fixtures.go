@@ -0,0 +1,55 @@
+package httpclient
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// fixtureTransport answers requests from files named after the requested
+// host, e.g. 'api.example.com.json'. Hosts without a fixture are passed on
+// to next.
+type fixtureTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+// fixtureExtensions are tried in order, the first file found is served.
+var fixtureExtensions = []string{".json", ".xml", ".csv"}
+
+// WithFixtures serves the responses of client from the fixtures in dir.
+// It is meant for local development against providers which are not
+// reachable.
+func WithFixtures(client *http.Client, dir string) *http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	fixtures := *client
+	fixtures.Transport = &fixtureTransport{dir: dir, next: next}
+	return &fixtures
+}
+
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for _, ext := range fixtureExtensions {
+		path := filepath.Join(t.dir, filepath.Base(req.URL.Hostname())+ext)
+		//nolint:gosec // the directory is set by the operator
+		body, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{mime.TypeByExtension(ext)}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			Request:    req,
+		}, nil
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,54 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithFixtures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("upstream"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "balance.example.com.json"), []byte(`{"balance":"10"}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "kyc.example.com.xml"), []byte(`<age>21</age>`), 0o600))
+
+	client := NewClient(DefaultOptions, time.Second)
+	fixtures := WithFixtures(client, dir)
+	require.Equal(t, time.Second, fixtures.Timeout)
+	require.IsType(t, &http.Transport{}, client.Transport)
+
+	tests := []struct {
+		url         string
+		body        string
+		contentType string
+	}{
+		{url: "https://balance.example.com/v1/balance?address=0x1", body: `{"balance":"10"}`, contentType: "application/json"},
+		{url: "https://kyc.example.com/age", body: `<age>21</age>`, contentType: "text/xml; charset=utf-8"},
+		{url: srv.URL, body: "upstream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			resp, err := fixtures.Get(tt.url)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, tt.body, string(body))
+			if tt.contentType != "" {
+				require.Equal(t, tt.contentType, resp.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+var ErrBlockedDestination = errors.New("outbound destination is not allowed")
+
+// carrierGradeNAT is the shared address space of RFC 6598, not covered by
+// net.IP.IsPrivate.
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Guard protects requests to URLs that configuration or credential data
+// can point anywhere. Addresses are checked when the connection is dialed,
+// after DNS resolution, so a host can't be re-resolved to a blocked address
+// between the check and the request.
+type Guard struct {
+	// Schemes are the allowed URL schemes, any when empty.
+	Schemes []string
+	// BlockPrivate rejects loopback, private, link-local and other
+	// non-public addresses.
+	BlockPrivate bool
+	// AllowedNetworks are exceptions to BlockPrivate.
+	AllowedNetworks []*net.IPNet
+}
+
+// ParseNetworks parses CIDRs or single IP addresses.
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if ip := net.ParseIP(v); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, errors.Errorf("invalid network '%s'", v)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// CheckURL checks the scheme of u.
+func (g *Guard) CheckURL(u *url.URL) error {
+	if len(g.Schemes) != 0 && !slices.Contains(g.Schemes, strings.ToLower(u.Scheme)) {
+		return errors.Wrapf(ErrBlockedDestination, "scheme '%s' of '%s'", u.Scheme, u.Redacted())
+	}
+	return nil
+}
+
+// CheckIP checks a resolved address.
+func (g *Guard) CheckIP(ip net.IP) error {
+	if !g.BlockPrivate {
+		return nil
+	}
+	for _, network := range g.AllowedNetworks {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		carrierGradeNAT.Contains(ip) {
+		return errors.Wrapf(ErrBlockedDestination, "address '%s' is not public", ip)
+	}
+	return nil
+}
+
+// control is a net.Dialer Control checking the address being dialed.
+func (g *Guard) control(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Wrapf(ErrBlockedDestination, "unresolved address '%s'", address)
+	}
+	return g.CheckIP(ip)
+}
+
+type guardedTransport struct {
+	guard *Guard
+	next  *http.Transport
+}
+
+// RoundTrip checks every request, redirects included.
+func (t *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.guard.CheckURL(req.URL); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
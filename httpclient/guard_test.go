@@ -0,0 +1,92 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuard_CheckIP(t *testing.T) {
+	allowed, err := ParseNetworks([]string{"10.1.0.0/16", "192.168.0.7"})
+	require.NoError(t, err)
+	guard := &Guard{BlockPrivate: true, AllowedNetworks: allowed}
+
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{ip: "93.184.216.34"},
+		{ip: "2606:4700::1111"},
+		{ip: "127.0.0.1", blocked: true},
+		{ip: "::1", blocked: true},
+		{ip: "10.0.0.1", blocked: true},
+		{ip: "10.1.2.3"},
+		{ip: "192.168.0.7"},
+		{ip: "192.168.0.8", blocked: true},
+		{ip: "169.254.169.254", blocked: true},
+		{ip: "100.64.0.1", blocked: true},
+		{ip: "fd00::1", blocked: true},
+		{ip: "0.0.0.0", blocked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			err := guard.CheckIP(net.ParseIP(tt.ip))
+			if tt.blocked {
+				require.ErrorIs(t, err, ErrBlockedDestination)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+
+	_, err = ParseNetworks([]string{"10.0.0.0/33"})
+	require.Error(t, err)
+}
+
+func TestNewClient_Guard(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// httptest listens on loopback
+	opts := DefaultOptions
+	opts.Guard = &Guard{Schemes: []string{"https", "http"}, BlockPrivate: true}
+	_, err := NewClient(opts, time.Second).Get(srv.URL)
+	require.ErrorIs(t, err, ErrBlockedDestination)
+
+	opts.Guard.Schemes = []string{"https"}
+	opts.Guard.AllowedNetworks, _ = ParseNetworks([]string{"127.0.0.1"})
+	_, err = NewClient(opts, time.Second).Get(srv.URL)
+	require.ErrorIs(t, err, ErrBlockedDestination)
+
+	opts.Guard.Schemes = nil
+	client := WithTLSConfig(NewClient(opts, time.Second), &tls.Config{MinVersion: tls.VersionTLS12})
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	// the guard survives a TLS configuration change
+	opts.Guard.AllowedNetworks = nil
+	client = WithTLSConfig(NewClient(opts, time.Second), &tls.Config{MinVersion: tls.VersionTLS12})
+	_, err = client.Get(srv.URL)
+	require.ErrorIs(t, err, ErrBlockedDestination)
+}
+
+func TestNewTransport_GuardProxy(t *testing.T) {
+	opts := DefaultOptions
+	require.NotNil(t, NewTransport(opts).Proxy)
+
+	opts.Guard = &Guard{Schemes: []string{"https"}}
+	require.NotNil(t, NewTransport(opts).Proxy)
+
+	// dialing the proxy would only check the address of the proxy
+	opts.Guard = &Guard{BlockPrivate: true}
+	require.Nil(t, NewTransport(opts).Proxy)
+}
@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Options tune the connection pool of a transport.
+type Options struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	// Guard restricts the destinations of requests, when set.
+	Guard *Guard
+}
+
+// DefaultOptions keep more idle connections per host than
+// http.DefaultTransport, which keeps only two and reconnects under
+// concurrent load.
+var DefaultOptions = Options{
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 32,
+	IdleConnTimeout:     90 * time.Second,
+	DialTimeout:         10 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+}
+
+// NewTransport returns a keep-alive transport with HTTP/2 enabled. A guard
+// blocking private addresses disables the proxy of the environment: the
+// guard would check the address of the proxy instead of the destination.
+func NewTransport(opts Options) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	proxy := http.ProxyFromEnvironment
+	if opts.Guard != nil {
+		dialer.Control = opts.Guard.control
+		if opts.Guard.BlockPrivate {
+			proxy = nil
+		}
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opts.MaxConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   opts.TLSHandshakeTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// NewClient returns a client on a new tuned transport. Clients of one
+// subsystem share it so connections to the same host are reused.
+func NewClient(opts Options, timeout time.Duration) *http.Client {
+	var transport http.RoundTripper = NewTransport(opts)
+	if opts.Guard != nil {
+		transport = &guardedTransport{guard: opts.Guard, next: transport.(*http.Transport)}
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+// WithTLSConfig returns a copy of client using cfg, keeping its guard and
+// injected faults.
+func WithTLSConfig(client *http.Client, cfg *tls.Config) *http.Client {
+	rt := client.Transport
+	faulty, isFaulty := rt.(*faultTransport)
+	if isFaulty {
+		rt = faulty.next
+	}
+	guarded, isGuarded := rt.(*guardedTransport)
+	if isGuarded {
+		rt = guarded.next
+	}
+	var transport *http.Transport
+	if t, ok := rt.(*http.Transport); ok {
+		transport = t.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.TLSClientConfig = cfg
+
+	c := *client
+	c.Transport = transport
+	if isGuarded {
+		c.Transport = &guardedTransport{guard: guarded.guard, next: transport}
+	}
+	if isFaulty {
+		c.Transport = &faultTransport{injector: faulty.injector, target: faulty.target, next: c.Transport}
+	}
+	return &c
+}
@@ -0,0 +1,38 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient_ReusesConnections(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		connections = map[string]struct{}{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		connections[r.RemoteAddr] = struct{}{}
+		mu.Unlock()
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := NewClient(DefaultOptions, time.Second)
+	for i := 0; i < 10; i++ {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		_, _ = resp.Body.Read(make([]byte, 2))
+		require.NoError(t, resp.Body.Close())
+	}
+	require.Len(t, connections, 1)
+
+	transport := client.Transport.(*http.Transport)
+	require.True(t, transport.ForceAttemptHTTP2)
+	require.Equal(t, DefaultOptions.MaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+}
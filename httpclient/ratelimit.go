@@ -0,0 +1,37 @@
+package httpclient
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitTransport makes requests wait for their turn before they are
+// sent.
+type rateLimitTransport struct {
+	limiter *rate.Limiter
+	next    http.RoundTripper
+}
+
+// WithRateLimit caps the requests of client to limiter. Requests over the
+// cap wait for their turn and fail only when their context ends first.
+func WithRateLimit(client *http.Client, limiter *rate.Limiter) *http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	limited := *client
+	limited.Transport = &rateLimitTransport{limiter: limiter, next: next}
+	return &limited
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, errors.Wrapf(err, "waiting for the rate limit of '%s'", req.URL.Host)
+	}
+	return t.next.RoundTrip(req)
+}
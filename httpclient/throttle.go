@@ -0,0 +1,62 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ThrottledError is returned when an upstream answers 429 or 503. It wraps
+// the error of the failed call and carries the delay the upstream asked
+// for, zero when it sent no Retry-After.
+type ThrottledError struct {
+	Err        error
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%v, retry after %s", e.Err, e.RetryAfter)
+	}
+	return e.Err.Error()
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// Throttle returns err as a ThrottledError when resp is 429 or 503, and err
+// unchanged otherwise.
+func Throttle(resp *http.Response, err error) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return err
+	}
+	return &ThrottledError{
+		Err:        err,
+		StatusCode: resp.StatusCode,
+		RetryAfter: RetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// RetryAfter parses a Retry-After value given in seconds or as an HTTP
+// date. Invalid and past values are zero.
+func RetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+	return date.Sub(now)
+}
@@ -0,0 +1,47 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{name: "Seconds", value: "120", expected: 2 * time.Minute},
+		{name: "HTTP date", value: "Mon, 01 Jan 2024 12:00:30 GMT", expected: 30 * time.Second},
+		{name: "Past date", value: "Mon, 01 Jan 2024 11:00:00 GMT"},
+		{name: "Negative", value: "-1"},
+		{name: "Invalid", value: "soon"},
+		{name: "Empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, RetryAfter(tt.value, now))
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	errUpstream := errors.New("upstream failed")
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"5"}}}
+
+	var throttled *ThrottledError
+	err := Throttle(resp, errUpstream)
+	require.ErrorAs(t, err, &throttled)
+	require.ErrorIs(t, err, errUpstream)
+	require.Equal(t, 5*time.Second, throttled.RetryAfter)
+	require.Equal(t, "upstream failed, retry after 5s", err.Error())
+
+	resp.StatusCode = http.StatusBadGateway
+	require.Equal(t, errUpstream, Throttle(resp, errUpstream))
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/pkg/errors"
+)
+
+// inspect prints the core claim of a credential and the checks deciding
+// whether the service would refresh it.
+func inspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	issuer := fs.String("issuer", "", "issuer DID, the credential issuer with --credential")
+	owner := fs.String("owner", "", "owner DID, the ownership checks are skipped without it")
+	claimID := fs.String("claim-id", "", "credential id, the credential id with --credential")
+	credentialFile := fs.String("credential", "", "JSON file with the credential, used in place of the issuer node")
+	asJSON := fs.Bool("json", false, "print the inspection as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *credentialFile != "" {
+		var credential struct {
+			ID     string `json:"id"`
+			Issuer string `json:"issuer"`
+		}
+		if err := readJSON(*credentialFile, &credential); err != nil {
+			return errors.Errorf("failed to read credential: %v", err)
+		}
+		if *issuer == "" {
+			*issuer = credential.Issuer
+		}
+		if *claimID == "" {
+			*claimID = credential.ID
+		}
+	}
+	if *issuer == "" || *claimID == "" {
+		fs.Usage()
+		return errors.New("--issuer and --claim-id, or --credential, are required")
+	}
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+	supportedIssuers, issuerClient := cfg.SupportedIssuers, httpclient.NewClient(httpclient.DefaultOptions, 0)
+	if *credentialFile != "" {
+		if supportedIssuers, issuerClient, err = credentialFixture(*credentialFile); err != nil {
+			return err
+		}
+	}
+	refreshService, err := cfg.refreshService(supportedIssuers, issuerClient, nil)
+	if err != nil {
+		return err
+	}
+
+	inspection, err := refreshService.Inspect(context.Background(), *issuer, *owner, *claimID)
+	if err != nil {
+		return errors.Errorf("failed to fetch credential: %v", err)
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(inspection); err != nil {
+			return err
+		}
+	} else {
+		printInspection(inspection)
+	}
+	if !inspection.Updatable {
+		return errors.New("the credential is not updatable")
+	}
+	return nil
+}
+
+func printInspection(inspection *service.Inspection) {
+	printf("credential:        %s\n", inspection.ID)
+	printf("issuer:            %s\n", inspection.Issuer)
+	printf("type:              %s\n", strings.Join(inspection.Type, ", "))
+	if inspection.CredentialType != "" {
+		printf("credential type:   %s\n", inspection.CredentialType)
+	}
+	if inspection.Expiration != nil {
+		printf("expiration:        %s\n", inspection.Expiration.UTC().Format(time.RFC3339))
+	}
+	if inspection.RevocationNonce != nil {
+		printf("revocation nonce:  %d\n", *inspection.RevocationNonce)
+	}
+	printf("issued layout:     merklized root %s, subject %s\n",
+		orNone(inspection.IssuedLayout.MerklizedRootPosition), orNone(inspection.IssuedLayout.SubjectPosition))
+
+	if claim := inspection.CoreClaim; claim != nil {
+		printf("\ncore claim:\n")
+		printf("  schema hash:     %s\n", claim.SchemaHash)
+		printf("  version:         %d\n", claim.Version)
+		printf("  updatable flag:  %t\n", claim.UpdatableFlag)
+		printf("  revocation nonce: %d\n", claim.RevocationNonce)
+		printf("  merklized root:  %s\n", orNone(claim.MerklizedRootPosition))
+		printf("  subject:         %s\n", orNone(claim.SubjectPosition))
+		for i, slot := range claim.IndexSlots {
+			printf("  index[%d]:        %s\n", i, slot)
+		}
+		for i, slot := range claim.ValueSlots {
+			printf("  value[%d]:        %s\n", i, slot)
+		}
+		if len(claim.FieldSlots) > 0 || len(claim.UnslottedFields) > 0 {
+			printf("  field slots:\n")
+			fields := make([]string, 0, len(claim.FieldSlots))
+			for field := range claim.FieldSlots {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			for _, field := range fields {
+				slot := claim.FieldSlots[field]
+				part := "value"
+				if slot < 4 {
+					part = "index"
+				}
+				printf("    %s: %d (%s)\n", field, slot, part)
+			}
+			for _, field := range claim.UnslottedFields {
+				printf("    %s: no serialization info\n", field)
+			}
+		}
+	}
+
+	printf("\nchecks:\n")
+	for _, check := range inspection.Checks {
+		status := "ok  "
+		if !check.Passed {
+			status = "FAIL"
+		}
+		if check.Detail == "" {
+			printf("  %s  %s\n", status, check.Name)
+			continue
+		}
+		printf("  %s  %s: %s\n", status, check.Name, check.Detail)
+	}
+	printf("\nupdatable: %t\n", inspection.Updatable)
+}
+
+func orNone(position string) string {
+	if position == "" {
+		return "none"
+	}
+	return position
+}
@@ -0,0 +1,259 @@
+// Package issuercontract checks that an issuer node serves the API the
+// refresh service relies on, so a node upgrade can be validated before
+// production traffic is pointed at it. The suite reads a sample credential,
+// creates a copy of it, waits for the copy to be issued and revokes it.
+package issuercontract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// Issuer is the issuer node client under test, service.IssuerService.
+type Issuer interface {
+	Ping(ctx context.Context, issuerDID string) error
+	GetClaimByID(ctx context.Context, issuerDID, claimID string) (*verifiable.W3CCredential, error)
+	CopyCredential(ctx context.Context, issuerDID string, credential *verifiable.W3CCredential,
+		expiration time.Time) (string, error)
+	RevokeCredential(ctx context.Context, issuerDID string, nonce uint64) error
+	RevocationStatus(ctx context.Context, issuerDID string, nonce uint64) (bool, error)
+}
+
+var _ Issuer = (*service.IssuerService)(nil)
+
+// Options select the issuer and the sample credential of a run.
+type Options struct {
+	IssuerDID string
+	// CredentialID is an existing credential of the issuer which is copied.
+	// It is only read.
+	CredentialID string
+	// AsyncTimeout bounds the wait for the copy to have the proofs of the
+	// sample, which issuer nodes add when they publish their state, and for
+	// its revocation to show. PollInterval is the wait between attempts.
+	AsyncTimeout time.Duration
+	PollInterval time.Duration
+}
+
+// Check names, in the order they run.
+const (
+	CheckStatus           = "status"
+	CheckGetCredential    = "get credential"
+	CheckCreateCredential = "create credential"
+	CheckAsyncIssuance    = "async issuance"
+	CheckRevoke           = "revoke credential"
+	CheckRevocationStatus = "revocation status"
+)
+
+// Result is the outcome of a check. Skipped checks depend on one which
+// failed.
+type Result struct {
+	Name       string `json:"name"`
+	Passed     bool   `json:"passed"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+type Report struct {
+	IssuerDID  string   `json:"issuer"`
+	Results    []Result `json:"results"`
+	Compatible bool     `json:"compatible"`
+}
+
+// Run runs the suite against the issuer node of opts.IssuerDID. A copy of
+// the sample credential is left revoked on the node.
+func Run(ctx context.Context, issuer Issuer, opts Options) *Report {
+	if opts.AsyncTimeout <= 0 {
+		opts.AsyncTimeout = 2 * time.Minute
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	r := &run{ctx: ctx, issuer: issuer, opts: opts, report: &Report{IssuerDID: opts.IssuerDID}}
+	r.run()
+
+	r.report.Compatible = true
+	for _, result := range r.report.Results {
+		if !result.Passed {
+			r.report.Compatible = false
+		}
+	}
+	return r.report
+}
+
+type run struct {
+	ctx    context.Context
+	issuer Issuer
+	opts   Options
+	report *Report
+}
+
+func (r *run) run() {
+	r.check(CheckStatus, func() (string, error) {
+		return "", r.issuer.Ping(r.ctx, r.opts.IssuerDID)
+	})
+
+	var sample *verifiable.W3CCredential
+	if !r.check(CheckGetCredential, func() (detail string, err error) {
+		sample, err = r.issuer.GetClaimByID(r.ctx, r.opts.IssuerDID, r.opts.CredentialID)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s with proofs %s", strings.Join(sample.Type, ", "), proofTypes(sample)), nil
+	}) {
+		r.skip(CheckCreateCredential, CheckAsyncIssuance, CheckRevoke, CheckRevocationStatus)
+		return
+	}
+
+	var copyID string
+	if !r.check(CheckCreateCredential, func() (detail string, err error) {
+		copyID, err = r.issuer.CopyCredential(r.ctx, r.opts.IssuerDID, sample, time.Now().Add(24*time.Hour))
+		if err == nil && copyID == "" {
+			err = errors.New("the issuer node returned no credential id")
+		}
+		return copyID, err
+	}) {
+		r.skip(CheckAsyncIssuance, CheckRevoke, CheckRevocationStatus)
+		return
+	}
+
+	// a copy which was issued is revoked even when it fails the check, so it
+	// doesn't stay valid
+	var issued *verifiable.W3CCredential
+	if !r.check(CheckAsyncIssuance, func() (string, error) {
+		err := r.poll(func() (err error) {
+			issued, err = r.issuer.GetClaimByID(r.ctx, r.opts.IssuerDID, copyID)
+			if err != nil {
+				return err
+			}
+			return missingProofs(sample, issued)
+		})
+		if err != nil {
+			return "", err
+		}
+		if err := sameSubject(sample, issued); err != nil {
+			return "", err
+		}
+		return "proofs " + proofTypes(issued), nil
+	}) && issued == nil {
+		r.skip(CheckRevoke, CheckRevocationStatus)
+		return
+	}
+
+	nonce, err := service.RevocationNonce(issued)
+	if !r.check(CheckRevoke, func() (string, error) {
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("nonce %d", nonce), r.issuer.RevokeCredential(r.ctx, r.opts.IssuerDID, nonce)
+	}) {
+		r.skip(CheckRevocationStatus)
+		return
+	}
+
+	r.check(CheckRevocationStatus, func() (string, error) {
+		return fmt.Sprintf("nonce %d is revoked", nonce), r.poll(func() error {
+			revoked, err := r.issuer.RevocationStatus(r.ctx, r.opts.IssuerDID, nonce)
+			if err == nil && !revoked {
+				err = errors.Errorf("nonce %d is not revoked", nonce)
+			}
+			return err
+		})
+	})
+}
+
+func (r *run) check(name string, fn func() (string, error)) bool {
+	start := time.Now()
+	detail, err := fn()
+	result := Result{Name: name, Passed: err == nil, Detail: detail, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Detail = err.Error()
+	}
+	r.report.Results = append(r.report.Results, result)
+	return err == nil
+}
+
+func (r *run) skip(names ...string) {
+	for _, name := range names {
+		r.report.Results = append(r.report.Results, Result{Name: name, Skipped: true})
+	}
+}
+
+// poll calls fn until it succeeds or the async timeout ends, and returns
+// the last error then.
+func (r *run) poll(fn func() error) error {
+	ctx, cancel := context.WithTimeout(r.ctx, r.opts.AsyncTimeout)
+	defer cancel()
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "after %s", r.opts.AsyncTimeout)
+		case <-time.After(r.opts.PollInterval):
+		}
+	}
+}
+
+func proofTypes(credential *verifiable.W3CCredential) string {
+	types := make([]string, 0, len(credential.Proof))
+	for _, p := range credential.Proof {
+		types = append(types, string(p.ProofType()))
+	}
+	if len(types) == 0 {
+		return "none"
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// missingProofs fails while issued lacks a proof type of sample, e.g. the
+// Merkle tree proof until the issuer node has published its state.
+func missingProofs(sample, issued *verifiable.W3CCredential) error {
+	have := make(map[verifiable.ProofType]bool, len(issued.Proof))
+	for _, p := range issued.Proof {
+		have[p.ProofType()] = true
+	}
+	var missing []string
+	for _, p := range sample.Proof {
+		if !have[p.ProofType()] {
+			missing = append(missing, string(p.ProofType()))
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("credential has no %s proof yet", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// sameSubject checks that the issuer node stored the subject it was sent.
+func sameSubject(sample, issued *verifiable.W3CCredential) error {
+	var changed []string
+	for field, want := range sample.CredentialSubject {
+		if !sameJSON(want, issued.CredentialSubject[field]) {
+			changed = append(changed, field)
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return errors.Errorf("credential subject fields changed: %s", strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+func sameJSON(a, b interface{}) bool {
+	aj, errA := json.Marshal(a)
+	bj, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aj, bj)
+}
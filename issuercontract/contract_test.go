@@ -0,0 +1,176 @@
+package issuercontract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleCredential = `{
+	"id": "urn:uuid:sample",
+	"issuer": "did:iden3:issuer",
+	"type": ["VerifiableCredential", "Balance"],
+	"credentialSubject": {"id": "did:iden3:owner", "type": "Balance", "balance": 10},
+	"credentialSchema": {"id": "https://example.com/balance.json", "type": "JsonSchema2023"},
+	"credentialStatus": {"id": "https://issuer/status", "type": "SparseMerkleTreeProof", "revocationNonce": %d}
+}`
+
+func credential(t *testing.T, nonce uint64, published bool) *verifiable.W3CCredential {
+	t.Helper()
+	var c verifiable.W3CCredential
+	require.NoError(t, json.Unmarshal([]byte(fmt.Sprintf(sampleCredential, nonce)), &c))
+	c.Proof = verifiable.CredentialProofs{
+		&verifiable.BJJSignatureProof2021{Type: verifiable.BJJSignatureProofType},
+	}
+	if published {
+		c.Proof = append(c.Proof, &verifiable.Iden3SparseMerkleTreeProof{Type: verifiable.Iden3SparseMerkleTreeProofType})
+	}
+	return &c
+}
+
+// fakeIssuer is an issuer node whose copies get their Merkle tree proof
+// after publishAfter reads.
+type fakeIssuer struct {
+	t            *testing.T
+	ping         error
+	sample       *verifiable.W3CCredential
+	created      bool
+	publishAfter int
+	reads        int
+	revoked      map[uint64]bool
+	ignoreRevoke bool
+}
+
+func (f *fakeIssuer) Ping(context.Context, string) error {
+	return f.ping
+}
+
+func (f *fakeIssuer) GetClaimByID(_ context.Context, _, claimID string) (*verifiable.W3CCredential, error) {
+	switch {
+	case claimID == "sample" && f.sample != nil:
+		return f.sample, nil
+	case claimID == "copy" && f.created:
+		f.reads++
+		return credential(f.t, 7, f.reads > f.publishAfter), nil
+	}
+	return nil, errors.New("invalid status code: '404'")
+}
+
+func (f *fakeIssuer) CopyCredential(context.Context, string, *verifiable.W3CCredential, time.Time) (string, error) {
+	f.created = true
+	return "copy", nil
+}
+
+func (f *fakeIssuer) RevokeCredential(_ context.Context, _ string, nonce uint64) error {
+	if !f.ignoreRevoke {
+		f.revoked[nonce] = true
+	}
+	return nil
+}
+
+func (f *fakeIssuer) RevocationStatus(_ context.Context, _ string, nonce uint64) (bool, error) {
+	return f.revoked[nonce], nil
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		issuer     func(f *fakeIssuer)
+		expected   map[string]string
+		compatible bool
+	}{
+		{
+			name: "Compatible issuer node",
+			issuer: func(f *fakeIssuer) {
+				f.publishAfter = 2
+			},
+			expected: map[string]string{
+				CheckStatus:           "passed",
+				CheckGetCredential:    "passed",
+				CheckCreateCredential: "passed",
+				CheckAsyncIssuance:    "passed",
+				CheckRevoke:           "passed",
+				CheckRevocationStatus: "passed",
+			},
+			compatible: true,
+		},
+		{
+			name: "Unknown sample credential",
+			issuer: func(f *fakeIssuer) {
+				f.sample = nil
+			},
+			expected: map[string]string{
+				CheckStatus:           "passed",
+				CheckGetCredential:    "failed",
+				CheckCreateCredential: "skipped",
+				CheckAsyncIssuance:    "skipped",
+				CheckRevoke:           "skipped",
+				CheckRevocationStatus: "skipped",
+			},
+		},
+		{
+			name: "Merkle tree proof is never published",
+			issuer: func(f *fakeIssuer) {
+				f.publishAfter = 1000
+			},
+			expected: map[string]string{
+				CheckStatus:           "passed",
+				CheckGetCredential:    "passed",
+				CheckCreateCredential: "passed",
+				CheckAsyncIssuance:    "failed",
+				CheckRevoke:           "passed",
+				CheckRevocationStatus: "passed",
+			},
+		},
+		{
+			name: "Revocation doesn't show",
+			issuer: func(f *fakeIssuer) {
+				f.ignoreRevoke = true
+			},
+			expected: map[string]string{
+				CheckStatus:           "passed",
+				CheckGetCredential:    "passed",
+				CheckCreateCredential: "passed",
+				CheckAsyncIssuance:    "passed",
+				CheckRevoke:           "passed",
+				CheckRevocationStatus: "failed",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeIssuer{
+				t:       t,
+				sample:  credential(t, 1, true),
+				revoked: map[uint64]bool{},
+			}
+			tt.issuer(f)
+			report := Run(context.Background(), f, Options{
+				IssuerDID:    "did:iden3:issuer",
+				CredentialID: "sample",
+				AsyncTimeout: 50 * time.Millisecond,
+				PollInterval: time.Millisecond,
+			})
+			require.Equal(t, tt.compatible, report.Compatible)
+			outcomes := make(map[string]string, len(report.Results))
+			for _, result := range report.Results {
+				switch {
+				case result.Skipped:
+					outcomes[result.Name] = "skipped"
+				case result.Passed:
+					outcomes[result.Name] = "passed"
+				default:
+					outcomes[result.Name] = "failed"
+				}
+			}
+			require.Equal(t, tt.expected, outcomes)
+		})
+	}
+}
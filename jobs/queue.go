@@ -0,0 +1,224 @@
+package jobs
+
+import (
+	"context"
+	"crypto/subtle"
+	"math"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/google/uuid"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+var ErrJobNotDead = errors.New("job is not in the dead-letter queue")
+
+type Refresher interface {
+	Process(ctx context.Context, issuer, owner, id string) (*verifiable.W3CCredential, error)
+}
+
+type Options struct {
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+	StaleAfter   time.Duration
+}
+
+type Option func(*Options)
+
+func WithMaxAttempts(n int) Option {
+	return func(o *Options) {
+		o.MaxAttempts = n
+	}
+}
+
+func WithBackoff(base, maxBackoff time.Duration) Option {
+	return func(o *Options) {
+		o.BaseBackoff = base
+		o.MaxBackoff = maxBackoff
+	}
+}
+
+func WithPollInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.PollInterval = d
+	}
+}
+
+// Queue runs refresh jobs persisted in storage. Transient failures are
+// retried with exponential backoff, permanent failures and jobs out of
+// attempts are moved to the dead-letter queue.
+type Queue struct {
+	store     storage.Jobs
+	refresher Refresher
+	opts      Options
+	changes   changes
+}
+
+func NewQueue(store storage.Jobs, refresher Refresher, opts ...Option) *Queue {
+	options := Options{
+		MaxAttempts:  5,
+		BaseBackoff:  10 * time.Second,
+		MaxBackoff:   10 * time.Minute,
+		PollInterval: time.Second,
+		BatchSize:    10,
+		StaleAfter:   10 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Queue{
+		store:     store,
+		refresher: refresher,
+		opts:      options,
+	}
+}
+
+func (q *Queue) Enqueue(ctx context.Context, issuer, owner, credentialID string) (storage.Job, error) {
+	return q.EnqueueForSession(ctx, "", issuer, owner, credentialID)
+}
+
+// EnqueueForSession enqueues a job bound to the owner session session, whose
+// result is then only handed out by GetForSession with the same session.
+func (q *Queue) EnqueueForSession(ctx context.Context, session, issuer, owner, credentialID string) (storage.Job, error) {
+	job := storage.Job{
+		ID:            uuid.New().String(),
+		Issuer:        issuer,
+		Owner:         owner,
+		CredentialID:  credentialID,
+		Status:        storage.JobStatusPending,
+		NextAttemptAt: time.Now().UTC(),
+		Session:       session,
+	}
+	if err := q.store.SaveJob(ctx, job); err != nil {
+		return storage.Job{}, err
+	}
+	return q.store.GetJob(ctx, job.ID)
+}
+
+func (q *Queue) Get(ctx context.Context, id string) (storage.Job, error) {
+	return q.store.GetJob(ctx, id)
+}
+
+// GetForSession returns the job only when it is bound to session. Jobs of
+// other sessions and jobs enqueued without one are not found, so holding a
+// job id doesn't tell whether the job exists.
+func (q *Queue) GetForSession(ctx context.Context, id, session string) (storage.Job, error) {
+	job, err := q.store.GetJob(ctx, id)
+	if err != nil {
+		return storage.Job{}, err
+	}
+	if session == "" || subtle.ConstantTimeCompare([]byte(job.Session), []byte(session)) != 1 {
+		return storage.Job{}, errors.Wrapf(storage.ErrNotFound, "job '%s'", id)
+	}
+	return job, nil
+}
+
+func (q *Queue) DeadLetters(ctx context.Context, limit int) ([]storage.Job, error) {
+	return q.store.ListJobs(ctx, storage.JobStatusDead, limit)
+}
+
+// Requeue moves a dead job back to the queue with a fresh attempt budget.
+func (q *Queue) Requeue(ctx context.Context, id string) (storage.Job, error) {
+	job, err := q.store.GetJob(ctx, id)
+	if err != nil {
+		return storage.Job{}, err
+	}
+	if job.Status != storage.JobStatusDead {
+		return storage.Job{}, errors.Wrapf(ErrJobNotDead, "job '%s' is '%s'", id, job.Status)
+	}
+	job.Status = storage.JobStatusPending
+	job.Attempts = 0
+	job.NextAttemptAt = time.Now().UTC()
+	if err := q.store.SaveJob(ctx, job); err != nil {
+		return storage.Job{}, err
+	}
+	q.changes.notify()
+	return q.store.GetJob(ctx, id)
+}
+
+// Run processes due jobs until ctx is canceled.
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		q.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce claims and processes one batch of due jobs.
+func (q *Queue) RunOnce(ctx context.Context) {
+	due, err := q.store.ClaimDueJobs(ctx, q.opts.BatchSize, q.opts.StaleAfter)
+	if err != nil {
+		logger.DefaultLogger.Errorf("failed to claim refresh jobs: %v", err)
+		return
+	}
+	if len(due) > 0 {
+		q.changes.notify()
+	}
+	for _, job := range due {
+		q.process(ctx, job)
+	}
+}
+
+func (q *Queue) process(ctx context.Context, job storage.Job) {
+	job.Attempts++
+	refreshed, err := q.refresher.Process(ctx, job.Issuer, job.Owner, job.CredentialID)
+	if err == nil {
+		job.Status = storage.JobStatusSucceeded
+		job.Error, job.ErrorCode, job.ErrorClass = "", 0, ""
+		job.Result, err = service.MarshalCredential(refreshed)
+		if err != nil {
+			logger.DefaultLogger.Errorf("failed to serialize result of job '%s': %v", job.ID, err)
+		}
+	} else {
+		q.fail(&job, err)
+	}
+	if err := q.store.SaveJob(context.WithoutCancel(ctx), job); err != nil {
+		logger.DefaultLogger.Errorf("failed to save job '%s': %v", job.ID, err)
+	}
+	q.changes.notify()
+}
+
+func (q *Queue) fail(job *storage.Job, err error) {
+	job.Error = err.Error()
+	job.ErrorCode = service.ErrorCode(err)
+	job.ErrorClass = storage.ErrorClassPermanent
+	if service.IsRetryable(err) {
+		job.ErrorClass = storage.ErrorClassTransient
+	}
+
+	if job.ErrorClass == storage.ErrorClassTransient && job.Attempts < q.opts.MaxAttempts {
+		job.Status = storage.JobStatusPending
+		delay := q.backoff(job.Attempts)
+		if retryAfter, ok := service.RetryAfter(err); ok && retryAfter > delay {
+			// the upstream asked us not to come back sooner
+			delay = retryAfter
+		}
+		job.NextAttemptAt = time.Now().UTC().Add(delay)
+		logger.DefaultLogger.Warnf("refresh job '%s' failed (attempt %d/%d), retry at %s: %v",
+			job.ID, job.Attempts, q.opts.MaxAttempts, job.NextAttemptAt.Format(time.RFC3339), err)
+		return
+	}
+	job.Status = storage.JobStatusDead
+	logger.DefaultLogger.Errorf("refresh job '%s' moved to dead-letter queue after %d attempts: %v",
+		job.ID, job.Attempts, err)
+}
+
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := time.Duration(float64(q.opts.BaseBackoff) * math.Pow(2, float64(attempt-1)))
+	if d <= 0 || d > q.opts.MaxBackoff {
+		return q.opts.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,173 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type scriptedRefresher struct {
+	errs  []error
+	calls int
+}
+
+func (r *scriptedRefresher) Process(context.Context, string, string, string) (*verifiable.W3CCredential, error) {
+	defer func() { r.calls++ }()
+	if r.calls < len(r.errs) && r.errs[r.calls] != nil {
+		return nil, r.errs[r.calls]
+	}
+	return &verifiable.W3CCredential{ID: "urn:uuid:refreshed"}, nil
+}
+
+func runDue(t *testing.T, q *Queue, store *memory.Store, id string) storage.Job {
+	t.Helper()
+	job, err := store.GetJob(context.Background(), id)
+	require.NoError(t, err)
+	job.NextAttemptAt = time.Now().Add(-time.Second)
+	require.NoError(t, store.SaveJob(context.Background(), job))
+	q.RunOnce(context.Background())
+	job, err = store.GetJob(context.Background(), id)
+	require.NoError(t, err)
+	return job
+}
+
+func TestQueue_RetryTransient(t *testing.T) {
+	store := memory.NewStore()
+	refresher := &scriptedRefresher{errs: []error{
+		errors.Wrap(flexiblehttp.ErrDataProviderIssue, "timeout"),
+		nil,
+	}}
+	q := NewQueue(store, refresher, WithMaxAttempts(3), WithBackoff(time.Minute, time.Hour))
+
+	job, err := q.Enqueue(context.Background(), "issuer", "owner", "credential")
+	require.NoError(t, err)
+
+	q.RunOnce(context.Background())
+	job, err = store.GetJob(context.Background(), job.ID)
+	require.NoError(t, err)
+	require.Equal(t, storage.JobStatusPending, job.Status)
+	require.Equal(t, storage.ErrorClassTransient, job.ErrorClass)
+	require.Equal(t, service.CodeDataProviderIssue, job.ErrorCode)
+	require.True(t, job.NextAttemptAt.After(time.Now().Add(50*time.Second)))
+
+	job = runDue(t, q, store, job.ID)
+	require.Equal(t, storage.JobStatusSucceeded, job.Status)
+	require.Equal(t, 2, job.Attempts)
+	require.JSONEq(t, `"urn:uuid:refreshed"`, string(mustField(t, job.Result, "id")))
+}
+
+func TestQueue_RetryAfter(t *testing.T) {
+	store := memory.NewStore()
+	refresher := &scriptedRefresher{errs: []error{&httpclient.ThrottledError{
+		Err:        errors.Wrap(flexiblehttp.ErrDataProviderIssue, "too many requests"),
+		StatusCode: http.StatusTooManyRequests,
+		RetryAfter: 2 * time.Hour,
+	}}}
+	q := NewQueue(store, refresher, WithMaxAttempts(3), WithBackoff(time.Minute, time.Hour))
+
+	job, err := q.Enqueue(context.Background(), "issuer", "owner", "credential")
+	require.NoError(t, err)
+
+	q.RunOnce(context.Background())
+	job, err = store.GetJob(context.Background(), job.ID)
+	require.NoError(t, err)
+	require.Equal(t, storage.JobStatusPending, job.Status)
+	require.Equal(t, service.CodeDataProviderIssue, job.ErrorCode)
+	require.True(t, job.NextAttemptAt.After(time.Now().Add(119*time.Minute)))
+}
+
+func TestQueue_DeadLetter(t *testing.T) {
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		class    string
+	}{
+		{
+			name:     "Permanent error",
+			errs:     []error{errors.Wrap(service.ErrCredentialNotUpdatable, "not expired")},
+			attempts: 1,
+			class:    storage.ErrorClassPermanent,
+		},
+		{
+			name: "Out of attempts",
+			errs: []error{
+				errors.Wrap(service.ErrGetClaim, "503"),
+				errors.Wrap(service.ErrGetClaim, "503"),
+			},
+			attempts: 2,
+			class:    storage.ErrorClassTransient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.NewStore()
+			q := NewQueue(store, &scriptedRefresher{errs: tt.errs}, WithMaxAttempts(2))
+			job, err := q.Enqueue(context.Background(), "issuer", "owner", "credential")
+			require.NoError(t, err)
+			for i := 0; i < tt.attempts; i++ {
+				job = runDue(t, q, store, job.ID)
+			}
+			require.Equal(t, storage.JobStatusDead, job.Status)
+			require.Equal(t, tt.class, job.ErrorClass)
+
+			dead, err := q.DeadLetters(context.Background(), 10)
+			require.NoError(t, err)
+			require.Len(t, dead, 1)
+
+			job, err = q.Requeue(context.Background(), job.ID)
+			require.NoError(t, err)
+			require.Equal(t, storage.JobStatusPending, job.Status)
+			require.Zero(t, job.Attempts)
+
+			_, err = q.Requeue(context.Background(), job.ID)
+			require.ErrorIs(t, err, ErrJobNotDead)
+		})
+	}
+}
+
+func mustField(t *testing.T, raw []byte, field string) []byte {
+	t.Helper()
+	var m map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(raw, &m))
+	return m[field]
+}
+
+func TestQueue_GetForSession(t *testing.T) {
+	ctx := context.Background()
+	q := NewQueue(memory.NewStore(), &scriptedRefresher{})
+
+	bound, err := q.EnqueueForSession(ctx, "session", "issuer", "owner", "credential")
+	require.NoError(t, err)
+	unbound, err := q.Enqueue(ctx, "issuer", "owner", "credential")
+	require.NoError(t, err)
+
+	job, err := q.GetForSession(ctx, bound.ID, "session")
+	require.NoError(t, err)
+	require.Equal(t, "session", job.Session)
+
+	// the session survives the runs of the job
+	q.RunOnce(ctx)
+	job, err = q.GetForSession(ctx, bound.ID, "session")
+	require.NoError(t, err)
+	require.Equal(t, storage.JobStatusSucceeded, job.Status)
+
+	_, err = q.GetForSession(ctx, bound.ID, "other")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = q.GetForSession(ctx, unbound.ID, "")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = q.GetForSession(ctx, "missing", "session")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
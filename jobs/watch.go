@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/storage"
+)
+
+// changes wakes up watchers when a job of this replica changes. Jobs run by
+// other replicas are noticed by polling the store.
+type changes struct {
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+func (c *changes) wait() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.changed == nil {
+		c.changed = make(chan struct{})
+	}
+	return c.changed
+}
+
+func (c *changes) notify() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.changed != nil {
+		close(c.changed)
+		c.changed = nil
+	}
+}
+
+// Finished reports whether job won't change anymore unless it is requeued.
+func Finished(job storage.Job) bool {
+	switch job.Status {
+	case storage.JobStatusSucceeded, storage.JobStatusFailed, storage.JobStatusDead:
+		return true
+	}
+	return false
+}
+
+// Watch sends the job with id, first as it is and then on every status
+// transition or new attempt. The channel is closed once the job is finished
+// or ctx is canceled.
+func (q *Queue) Watch(ctx context.Context, id string) (<-chan storage.Job, error) {
+	job, err := q.store.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	updates := make(chan storage.Job)
+	go func() {
+		defer close(updates)
+		for {
+			select {
+			case updates <- job:
+			case <-ctx.Done():
+				return
+			}
+			if Finished(job) {
+				return
+			}
+			next, ok := q.nextChange(ctx, job)
+			if !ok {
+				return
+			}
+			job = next
+		}
+	}()
+	return updates, nil
+}
+
+// nextChange waits until job changes its status or attempts.
+func (q *Queue) nextChange(ctx context.Context, job storage.Job) (storage.Job, bool) {
+	for {
+		changed := q.changes.wait()
+		next, err := q.store.GetJob(ctx, job.ID)
+		switch {
+		case ctx.Err() != nil:
+			return storage.Job{}, false
+		case err != nil:
+			logger.DefaultLogger.Warnf("failed to watch job '%s': %v", job.ID, err)
+		case next.Status != job.Status || next.Attempts != job.Attempts:
+			return next, true
+		}
+		select {
+		case <-ctx.Done():
+			return storage.Job{}, false
+		case <-changed:
+		case <-time.After(q.opts.PollInterval):
+		}
+	}
+}
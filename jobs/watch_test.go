@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedRefresher blocks every refresh until it is released.
+type gatedRefresher struct {
+	release chan struct{}
+}
+
+func (r *gatedRefresher) Process(context.Context, string, string, string) (*verifiable.W3CCredential, error) {
+	<-r.release
+	return &verifiable.W3CCredential{ID: "urn:uuid:refreshed"}, nil
+}
+
+func TestQueue_Watch(t *testing.T) {
+	store := memory.NewStore()
+	refresher := &gatedRefresher{release: make(chan struct{})}
+	// transitions are noticed without polling
+	q := NewQueue(store, refresher, WithPollInterval(time.Hour))
+
+	job, err := q.Enqueue(context.Background(), "issuer", "owner", "credential")
+	require.NoError(t, err)
+	updates, err := q.Watch(context.Background(), job.ID)
+	require.NoError(t, err)
+	require.Equal(t, storage.JobStatusPending, (<-updates).Status)
+
+	go q.RunOnce(context.Background())
+	require.Equal(t, storage.JobStatusRunning, (<-updates).Status)
+
+	close(refresher.release)
+	finished := <-updates
+	require.Equal(t, storage.JobStatusSucceeded, finished.Status)
+	require.Contains(t, string(finished.Result), "urn:uuid:refreshed")
+
+	_, open := <-updates
+	require.False(t, open)
+}
+
+func TestQueue_Watch_Canceled(t *testing.T) {
+	q := NewQueue(memory.NewStore(), &gatedRefresher{}, WithPollInterval(10*time.Millisecond))
+	job, err := q.Enqueue(context.Background(), "issuer", "owner", "credential")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := q.Watch(ctx, job.ID)
+	require.NoError(t, err)
+	<-updates
+	cancel()
+	_, open := <-updates
+	require.False(t, open)
+
+	_, err = q.Watch(context.Background(), "missing")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
@@ -0,0 +1,188 @@
+// Package lambda serves an http.Handler from an AWS Lambda function behind
+// API Gateway, so the refresh service can run serverless with the same
+// routes and service code as the long running server. It implements the
+// proxy integration payloads and the Lambda runtime API on net/http.
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/pkg/errors"
+)
+
+// ProxyRequest is an API Gateway proxy integration event, of a REST API
+// (payload format 1.0) or of an HTTP API (payload format 2.0, with
+// Version, RawPath and RawQueryString).
+type ProxyRequest struct {
+	Version                         string              `json:"version"`
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	RawPath                         string              `json:"rawPath"`
+	RawQueryString                  string              `json:"rawQueryString"`
+	Headers                         map[string]string   `json:"headers"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	Cookies                         []string            `json:"cookies"`
+	RequestContext                  RequestContext      `json:"requestContext"`
+	Body                            string              `json:"body"`
+	IsBase64Encoded                 bool                `json:"isBase64Encoded"`
+}
+
+// RequestContext is the part of the request context of an event the
+// adapter reads.
+type RequestContext struct {
+	RequestID string `json:"requestId"`
+	Stage     string `json:"stage"`
+	Identity  struct {
+		SourceIP string `json:"sourceIp"`
+	} `json:"identity"`
+	HTTP struct {
+		Method   string `json:"method"`
+		SourceIP string `json:"sourceIp"`
+	} `json:"http"`
+}
+
+// ProxyResponse is the response of a proxy integration. MultiValueHeaders
+// is only set for REST APIs, Cookies only for HTTP APIs.
+type ProxyResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Cookies           []string            `json:"cookies,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+type Option func(*Adapter)
+
+// WithBasePath strips prefix from the paths of requests, e.g. the base path
+// of an API Gateway custom domain mapping.
+func WithBasePath(prefix string) Option {
+	return func(a *Adapter) {
+		a.basePath = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// Adapter invokes an http.Handler with API Gateway proxy events.
+type Adapter struct {
+	handler  http.Handler
+	basePath string
+}
+
+func NewAdapter(handler http.Handler, opts ...Option) *Adapter {
+	a := &Adapter{handler: handler}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Invoke serves the event in payload and returns the proxy response.
+func (a *Adapter) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var event ProxyRequest
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, errors.Errorf("invalid proxy event: %v", err)
+	}
+	r, err := a.request(ctx, &event)
+	if err != nil {
+		return nil, err
+	}
+	w := httptest.NewRecorder()
+	a.handler.ServeHTTP(w, r)
+	return json.Marshal(response(&event, w.Result().StatusCode, w.Header(), w.Body.Bytes()))
+}
+
+func (a *Adapter) request(ctx context.Context, event *ProxyRequest) (*http.Request, error) {
+	method, path, sourceIP := event.HTTPMethod, event.Path, event.RequestContext.Identity.SourceIP
+	query := url.Values{}
+	if event.Version == "2.0" {
+		method, path, sourceIP = event.RequestContext.HTTP.Method, event.RawPath, event.RequestContext.HTTP.SourceIP
+		// HTTP APIs keep the stage in the path unless it is the default one
+		if stage := event.RequestContext.Stage; stage != "" && stage != "$default" {
+			path = strings.TrimPrefix(path, "/"+stage)
+		}
+		var err error
+		if query, err = url.ParseQuery(event.RawQueryString); err != nil {
+			return nil, errors.Errorf("invalid query string: %v", err)
+		}
+	} else if len(event.MultiValueQueryStringParameters) != 0 {
+		for key, values := range event.MultiValueQueryStringParameters {
+			query[key] = values
+		}
+	} else {
+		for key, value := range event.QueryStringParameters {
+			query.Set(key, value)
+		}
+	}
+	if a.basePath != "" {
+		path = strings.TrimPrefix(path, a.basePath)
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(event.Body); err != nil {
+			return nil, errors.Errorf("invalid base64 body: %v", err)
+		}
+	}
+	r, err := http.NewRequestWithContext(ctx, method, (&url.URL{Path: path, RawQuery: query.Encode()}).RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Errorf("invalid proxy event: %v", err)
+	}
+	for key, values := range event.MultiValueHeaders {
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+	for key, value := range event.Headers {
+		if r.Header.Get(key) == "" {
+			r.Header.Set(key, value)
+		}
+	}
+	for _, cookie := range event.Cookies {
+		r.Header.Add("Cookie", cookie)
+	}
+	if r.Header.Get(middleware.RequestIDHeader) == "" && event.RequestContext.RequestID != "" {
+		r.Header.Set(middleware.RequestIDHeader, event.RequestContext.RequestID)
+	}
+	r.Host = r.Header.Get("Host")
+	r.ContentLength = int64(len(body))
+	r.RemoteAddr = net.JoinHostPort(sourceIP, "0")
+	return r, nil
+}
+
+// response builds the proxy response. Bodies which are not valid UTF-8 are
+// base64 encoded.
+func response(event *ProxyRequest, status int, header http.Header, body []byte) *ProxyResponse {
+	resp := &ProxyResponse{StatusCode: status, Body: string(body)}
+	if !utf8.Valid(body) {
+		resp.Body, resp.IsBase64Encoded = base64.StdEncoding.EncodeToString(body), true
+	}
+	if event.Version != "2.0" {
+		resp.MultiValueHeaders = header
+		return resp
+	}
+	resp.Headers = make(map[string]string, len(header))
+	for key, values := range header {
+		if key == "Set-Cookie" {
+			resp.Cookies = values
+			continue
+		}
+		resp.Headers[key] = strings.Join(values, ",")
+	}
+	return resp
+}
@@ -0,0 +1,170 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/require"
+)
+
+// echo answers with what the adapter made of the event.
+func echo(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if r.URL.Path == "/binary" {
+		_, _ = w.Write([]byte{0xff, 0xfe})
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"method":    r.Method,
+		"uri":       r.URL.RequestURI(),
+		"body":      string(body),
+		"type":      r.Header.Get("Content-Type"),
+		"cookie":    r.Header.Get("Cookie"),
+		"requestID": r.Header.Get(middleware.RequestIDHeader),
+		"remote":    r.RemoteAddr,
+	})
+}
+
+func TestAdapter_Invoke(t *testing.T) {
+	tests := []struct {
+		name             string
+		opts             []Option
+		event            string
+		expectedEcho     map[string]interface{}
+		expectedResponse ProxyResponse
+	}{
+		{
+			name: "REST API",
+			event: `{
+				"httpMethod": "POST",
+				"path": "/",
+				"multiValueHeaders": {"Content-Type": ["application/json"]},
+				"multiValueQueryStringParameters": {"a": ["1", "2"]},
+				"requestContext": {"requestId": "req-1", "stage": "prod", "identity": {"sourceIp": "203.0.113.1"}},
+				"body": "{\"id\": 1}"
+			}`,
+			expectedEcho: map[string]interface{}{
+				"method":    "POST",
+				"uri":       "/?a=1&a=2",
+				"body":      `{"id": 1}`,
+				"type":      "application/json",
+				"cookie":    "",
+				"requestID": "req-1",
+				"remote":    "203.0.113.1:0",
+			},
+			expectedResponse: ProxyResponse{
+				StatusCode: http.StatusCreated,
+				MultiValueHeaders: map[string][]string{
+					"Content-Type": {"application/json"},
+					"Set-Cookie":   {"session=1"},
+				},
+			},
+		},
+		{
+			name: "HTTP API with a stage",
+			event: `{
+				"version": "2.0",
+				"rawPath": "/prod/health/ready",
+				"rawQueryString": "verbose=1",
+				"headers": {"content-type": "text/plain", "x-request-id": "client-id"},
+				"cookies": ["a=1", "b=2"],
+				"requestContext": {"requestId": "req-2", "stage": "prod", "http": {"method": "GET", "sourceIp": "203.0.113.2"}},
+				"body": "aGVsbG8=",
+				"isBase64Encoded": true
+			}`,
+			expectedEcho: map[string]interface{}{
+				"method":    "GET",
+				"uri":       "/health/ready?verbose=1",
+				"body":      "hello",
+				"type":      "text/plain",
+				"cookie":    "a=1",
+				"requestID": "client-id",
+				"remote":    "203.0.113.2:0",
+			},
+			expectedResponse: ProxyResponse{
+				StatusCode: http.StatusCreated,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Cookies:    []string{"session=1"},
+			},
+		},
+		{
+			name: "Base path",
+			opts: []Option{WithBasePath("/refresh/")},
+			event: `{
+				"version": "2.0",
+				"rawPath": "/refresh",
+				"requestContext": {"stage": "$default", "http": {"method": "POST", "sourceIp": "203.0.113.3"}}
+			}`,
+			expectedEcho: map[string]interface{}{
+				"method":    "POST",
+				"uri":       "/",
+				"body":      "",
+				"type":      "",
+				"cookie":    "",
+				"requestID": "",
+				"remote":    "203.0.113.3:0",
+			},
+			expectedResponse: ProxyResponse{
+				StatusCode: http.StatusCreated,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Cookies:    []string{"session=1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := NewAdapter(http.HandlerFunc(echo), tt.opts...).Invoke(context.Background(), []byte(tt.event))
+			require.NoError(t, err)
+			var resp ProxyResponse
+			require.NoError(t, json.Unmarshal(payload, &resp))
+			var echoed map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(resp.Body), &echoed))
+			require.Equal(t, tt.expectedEcho, echoed)
+
+			resp.Body = ""
+			require.Equal(t, tt.expectedResponse, resp)
+		})
+	}
+}
+
+func TestAdapter_InvokeBinary(t *testing.T) {
+	payload, err := NewAdapter(http.HandlerFunc(echo)).Invoke(context.Background(),
+		[]byte(`{"version": "2.0", "rawPath": "/binary", "requestContext": {"http": {"method": "GET"}}}`))
+	require.NoError(t, err)
+	var resp ProxyResponse
+	require.NoError(t, json.Unmarshal(payload, &resp))
+	require.True(t, resp.IsBase64Encoded)
+	require.Equal(t, "//4=", resp.Body)
+}
+
+func TestAdapter_InvokeInvalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		event       string
+		expectedErr string
+	}{
+		{
+			name:        "Not JSON",
+			event:       `{`,
+			expectedErr: "invalid proxy event",
+		},
+		{
+			name:        "Invalid base64 body",
+			event:       `{"httpMethod": "POST", "path": "/", "body": "%", "isBase64Encoded": true}`,
+			expectedErr: "invalid base64 body",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAdapter(http.HandlerFunc(echo)).Invoke(context.Background(), []byte(tt.event))
+			require.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
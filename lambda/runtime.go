@@ -0,0 +1,107 @@
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/pkg/errors"
+)
+
+// RuntimeAPIEnv is the environment variable Lambda sets to the address of
+// the runtime API in the function.
+const RuntimeAPIEnv = "AWS_LAMBDA_RUNTIME_API"
+
+const runtimeAPIVersion = "2018-06-01"
+
+// Start takes invocations from the Lambda runtime API at runtimeAPI and
+// answers them until ctx is done. The context of an invocation ends at its
+// deadline; an invocation which was taken is answered even when ctx is done
+// meanwhile. Invocations the adapter can't decode are reported to Lambda as
+// errors, errors of the runtime API itself end Start.
+func (a *Adapter) Start(ctx context.Context, runtimeAPI string) error {
+	base := "http://" + runtimeAPI + "/" + runtimeAPIVersion + "/runtime/invocation/"
+	// the next invocation is long polled
+	client := &http.Client{}
+	logger.DefaultLogger.Infof("Serving invocations of the Lambda runtime API '%s'", runtimeAPI)
+	for {
+		id, deadline, payload, err := next(ctx, client, base)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		invocationCtx, cancel := context.WithDeadline(ctx, deadline)
+		response, err := a.Invoke(invocationCtx, payload)
+		cancel()
+		answerCtx := context.WithoutCancel(ctx)
+		if err != nil {
+			logger.DefaultLogger.Warnf("failed Lambda invocation '%s': %v", id, err)
+			response, _ = json.Marshal(map[string]string{
+				"errorMessage": err.Error(),
+				"errorType":    "InvalidEvent",
+			})
+			err = post(answerCtx, client, base+id+"/error", response)
+		} else {
+			err = post(answerCtx, client, base+id+"/response", response)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// next waits for the next invocation and returns its id, deadline and
+// event.
+func next(ctx context.Context, client *http.Client, base string) (string, time.Time, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"next", http.NoBody)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, nil, errors.Errorf("failed to get the next invocation: %v", err)
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, nil, errors.Errorf("failed to read the next invocation: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, nil, errors.Errorf("failed to get the next invocation: status %d", resp.StatusCode)
+	}
+	id := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+	if id == "" {
+		return "", time.Time{}, nil, errors.New("invocation without request id")
+	}
+	deadline := time.Now().Add(15 * time.Minute)
+	if ms, err := strconv.ParseInt(resp.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64); err == nil {
+		deadline = time.UnixMilli(ms)
+	}
+	return id, deadline, payload, nil
+}
+
+func post(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Errorf("failed to post the invocation result: %v", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusAccepted {
+		return errors.Errorf("failed to post the invocation result: status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRuntime is a Lambda runtime API handing out events and collecting the
+// results by invocation id.
+type fakeRuntime struct {
+	events chan string
+
+	mu      sync.Mutex
+	results map[string]string
+	done    chan struct{}
+}
+
+func (f *fakeRuntime) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/2018-06-01/runtime/invocation/")
+	if path == "next" {
+		select {
+		case event := <-f.events:
+			id, payload, _ := strings.Cut(event, " ")
+			w.Header().Set("Lambda-Runtime-Aws-Request-Id", id)
+			w.Header().Set("Lambda-Runtime-Deadline-Ms", strconv.FormatInt(time.Now().Add(time.Minute).UnixMilli(), 10))
+			_, _ = w.Write([]byte(payload))
+		case <-r.Context().Done():
+		}
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.results[path] = string(body)
+	if len(f.results) == 2 {
+		close(f.done)
+	}
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func TestAdapter_Start(t *testing.T) {
+	runtime := &fakeRuntime{
+		events:  make(chan string, 2),
+		results: make(map[string]string),
+		done:    make(chan struct{}),
+	}
+	runtime.events <- `1 {"httpMethod": "GET", "path": "/ping", "requestContext": {"identity": {"sourceIp": "203.0.113.1"}}}`
+	runtime.events <- `2 {`
+	srv := httptest.NewServer(runtime)
+	defer srv.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("pong " + r.URL.Path))
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- NewAdapter(handler).Start(ctx, strings.TrimPrefix(srv.URL, "http://"))
+	}()
+
+	select {
+	case <-runtime.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("invocations were not answered")
+	}
+	cancel()
+	require.NoError(t, <-stopped)
+
+	var resp ProxyResponse
+	require.NoError(t, json.Unmarshal([]byte(runtime.results["1/response"]), &resp))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "pong /ping", resp.Body)
+	require.Contains(t, runtime.results["2/error"], "invalid proxy event")
+}
@@ -0,0 +1,137 @@
+// Package leader elects one replica to run work which must not run on
+// several replicas at once, e.g. a scheduler of bulk refreshes. The other
+// replicas stay hot standbys and take over when the leader stops renewing
+// its lease.
+package leader
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/lock"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/pkg/errors"
+)
+
+type Option func(*Elector)
+
+// WithTTL sets how long the lease of the leader lasts without renewal,
+// which is how long a crashed leader is waited for at most. The lease is
+// renewed, and standbys campaign, every third of it.
+func WithTTL(ttl time.Duration) Option {
+	return func(e *Elector) {
+		if ttl > 0 {
+			e.ttl = ttl
+		}
+	}
+}
+
+// WithOnChange calls fn when the replica becomes the leader or stops being
+// it.
+func WithOnChange(fn func(leader bool)) Option {
+	return func(e *Elector) {
+		e.onChange = fn
+	}
+}
+
+// Elector campaigns for the leadership of key with the replicas sharing the
+// locker.
+type Elector struct {
+	locker   lock.Locker
+	key      string
+	ttl      time.Duration
+	onChange func(leader bool)
+	leader   atomic.Bool
+}
+
+func NewElector(locker lock.Locker, key string, opts ...Option) *Elector {
+	e := &Elector{
+		locker: locker,
+		key:    "leader:" + key,
+		ttl:    15 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// IsLeader tells whether the replica holds the leadership.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns until ctx ends. While the replica is the leader lead runs
+// with a context which is cancelled when the leadership is lost; Run waits
+// for lead to return before it campaigns again. The lease is released when
+// ctx ends, so a standby takes over without waiting for it to expire.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		lease, err := e.locker.Acquire(ctx, e.key, e.ttl)
+		switch {
+		case err == nil:
+			e.lead(ctx, lease, ticker, lead)
+		case !errors.Is(err, lock.ErrNotAcquired) && ctx.Err() == nil:
+			logger.DefaultLogger.Warnf("failed to campaign for '%s': %v", e.key, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead runs lead and renews the lease until ctx ends or a renewal fails.
+func (e *Elector) lead(ctx context.Context, lease *lock.Lease, ticker *time.Ticker, lead func(ctx context.Context)) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	e.setLeader(true)
+	go func() {
+		defer close(stopped)
+		lead(leaderCtx)
+	}()
+	stop := func() {
+		cancel()
+		<-stopped
+		e.setLeader(false)
+	}
+
+	done := stopped
+	for {
+		select {
+		case <-ctx.Done():
+			// lead stops before the lease is released, so it never runs on
+			// two replicas
+			stop()
+			releaseCtx, cancelRelease := context.WithTimeout(context.Background(), e.ttl/3)
+			defer cancelRelease()
+			if err := lease.Release(releaseCtx); err != nil {
+				logger.DefaultLogger.Warnf("failed to release the leadership of '%s': %v", e.key, err)
+			}
+			return
+		case <-done:
+			// lead returned, the leadership is kept so no standby starts it
+			done = nil
+		case <-ticker.C:
+			// the lease may be taken over once it expires, so the leader
+			// steps down when it isn't sure it renewed it
+			if err := lease.Extend(ctx, e.ttl); err != nil {
+				if ctx.Err() == nil {
+					logger.DefaultLogger.Warnf("lost the leadership of '%s': %v", e.key, err)
+				}
+				stop()
+				return
+			}
+		}
+	}
+}
+
+func (e *Elector) setLeader(leader bool) {
+	if e.leader.Swap(leader) != leader && e.onChange != nil {
+		e.onChange(leader)
+	}
+}
@@ -0,0 +1,139 @@
+package leader
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/lock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+type replica struct {
+	elector *Elector
+	cancel  context.CancelFunc
+	leading atomic.Int32
+	stopped chan struct{}
+}
+
+func runReplica(t *testing.T, locker lock.Locker) *replica {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &replica{
+		elector: NewElector(locker, "scheduler", WithTTL(30*time.Millisecond)),
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+	}
+	go func() {
+		defer close(r.stopped)
+		r.elector.Run(ctx, func(ctx context.Context) {
+			r.leading.Add(1)
+			defer r.leading.Add(-1)
+			<-ctx.Done()
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-r.stopped
+	})
+	return r
+}
+
+func TestElector(t *testing.T) {
+	srv := miniredis.RunT(t)
+	locker := lock.NewRedisLocker(redis.NewClient(&redis.Options{Addr: srv.Addr()}))
+
+	first := runReplica(t, locker)
+	require.Eventually(t, first.elector.IsLeader, time.Second, time.Millisecond)
+	second := runReplica(t, locker)
+
+	// the standby doesn't take over while the leader renews its lease
+	time.Sleep(100 * time.Millisecond)
+	require.True(t, first.elector.IsLeader())
+	require.False(t, second.elector.IsLeader())
+	require.EqualValues(t, 1, first.leading.Load())
+	require.EqualValues(t, 0, second.leading.Load())
+
+	// a stopped leader hands over at once
+	first.cancel()
+	<-first.stopped
+	require.False(t, first.elector.IsLeader())
+	require.EqualValues(t, 0, first.leading.Load())
+	require.Eventually(t, second.elector.IsLeader, time.Second, time.Millisecond)
+	require.EqualValues(t, 1, second.leading.Load())
+}
+
+func TestElector_Lost(t *testing.T) {
+	srv := miniredis.RunT(t)
+	locker := lock.NewRedisLocker(redis.NewClient(&redis.Options{Addr: srv.Addr()}))
+
+	var changes []bool
+	changed := make(chan struct{}, 4)
+	elector := NewElector(locker, "scheduler", WithTTL(30*time.Millisecond), WithOnChange(func(leader bool) {
+		changes = append(changes, leader)
+		changed <- struct{}{}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	leadCancelled := make(chan struct{})
+	go elector.Run(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		close(leadCancelled)
+	})
+	<-changed
+
+	// the lease is taken over, e.g. after the leader was paused past its ttl
+	srv.Del("refresh-service:lock:leader:scheduler")
+	_, err := locker.Acquire(context.Background(), "leader:scheduler", time.Minute)
+	require.NoError(t, err)
+
+	<-changed
+	<-leadCancelled
+	require.Equal(t, []bool{true, false}, changes)
+	require.False(t, elector.IsLeader())
+}
+
+// memoryLocker is a Locker of one process, renewing its leases with
+// lock.NewLease like the Redis one.
+type memoryLocker struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+func (l *memoryLocker) Acquire(_ context.Context, key string, ttl time.Duration) (*lock.Lease, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Now().Before(l.expires[key]) {
+		return nil, lock.ErrNotAcquired
+	}
+	l.expires[key] = time.Now().Add(ttl)
+	release := func(context.Context) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.expires, key)
+		return nil
+	}
+	extend := func(_ context.Context, ttl time.Duration) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if time.Now().After(l.expires[key]) {
+			return lock.ErrLost
+		}
+		l.expires[key] = time.Now().Add(ttl)
+		return nil
+	}
+	return lock.NewLease(key, 1, release, extend), nil
+}
+
+func TestElector_OtherLocker(t *testing.T) {
+	r := runReplica(t, &memoryLocker{expires: make(map[string]time.Time)})
+	require.Eventually(t, r.elector.IsLeader, time.Second, time.Millisecond)
+
+	// the leadership is kept over several renewals
+	time.Sleep(100 * time.Millisecond)
+	require.True(t, r.elector.IsLeader())
+	require.EqualValues(t, 1, r.leading.Load())
+}
@@ -0,0 +1,149 @@
+// Package loadercache caches the JSON-LD documents of the document loader.
+package loadercache
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/metrics"
+	"github.com/iden3/go-schema-processor/v2/loaders"
+	"github.com/piprate/json-gold/ld"
+)
+
+// Results of cache lookups, see metrics.DocumentCacheLookups.
+const (
+	lookupHit     = "hit"
+	lookupMiss    = "miss"
+	lookupExpired = "expired"
+)
+
+type cachedDocument struct {
+	document   *ld.RemoteDocument
+	storedAt   time.Time
+	expireTime time.Time
+	size       int
+}
+
+// Entry describes a cached document.
+type Entry struct {
+	URL       string    `json:"url"`
+	StoredAt  time.Time `json:"storedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Size is the length of the JSON encoded document in bytes.
+	Size     int  `json:"size"`
+	Embedded bool `json:"embedded"`
+}
+
+// Memory is an in-memory loaders.CacheEngine which, unlike the one of the
+// schema processor, can be flushed. Embedded documents are never flushed.
+type Memory struct {
+	mu        sync.RWMutex
+	documents map[string]cachedDocument
+	embedded  map[string]cachedDocument
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		documents: make(map[string]cachedDocument),
+		embedded:  make(map[string]cachedDocument),
+	}
+}
+
+// Embed serves doc for url without loading it.
+func (m *Memory) Embed(url string, doc []byte) error {
+	document := &ld.RemoteDocument{DocumentURL: url}
+	if err := json.Unmarshal(doc, &document.Document); err != nil {
+		return err
+	}
+	m.embedded[url] = cachedDocument{document: document, storedAt: time.Now(), size: len(doc)}
+	return nil
+}
+
+func (m *Memory) Get(key string) (*ld.RemoteDocument, time.Time, error) {
+	if cached, ok := m.embedded[key]; ok {
+		metrics.DocumentCacheLookups.WithLabelValues(lookupHit).Inc()
+		return cached.document, time.Now().Add(time.Hour), nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cached, ok := m.documents[key]
+	switch {
+	case !ok:
+		metrics.DocumentCacheLookups.WithLabelValues(lookupMiss).Inc()
+		return nil, time.Time{}, loaders.ErrCacheMiss
+	case !cached.expireTime.After(time.Now()):
+		// the loader fetches the document again
+		metrics.DocumentCacheLookups.WithLabelValues(lookupExpired).Inc()
+	default:
+		metrics.DocumentCacheLookups.WithLabelValues(lookupHit).Inc()
+	}
+	return cached.document, cached.expireTime, nil
+}
+
+func (m *Memory) Set(key string, doc *ld.RemoteDocument, expireTime time.Time) error {
+	if _, ok := m.embedded[key]; ok {
+		return nil
+	}
+	size := 0
+	if raw, err := json.Marshal(doc.Document); err == nil {
+		size = len(raw)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.documents[key] = cachedDocument{document: doc, storedAt: time.Now(), expireTime: expireTime, size: size}
+	return nil
+}
+
+// Flush removes all loaded documents and returns how many there were.
+func (m *Memory) Flush(context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := len(m.documents)
+	m.documents = make(map[string]cachedDocument)
+	return int64(n), nil
+}
+
+// Entries lists the cached documents by URL, expired ones which were not
+// loaded again included. Embedded documents never expire.
+func (m *Memory) Entries() []Entry {
+	m.mu.RLock()
+	entries := make([]Entry, 0, len(m.documents)+len(m.embedded))
+	for url, cached := range m.documents {
+		entries = append(entries, Entry{
+			URL:       url,
+			StoredAt:  cached.storedAt,
+			ExpiresAt: cached.expireTime,
+			Size:      cached.size,
+		})
+	}
+	m.mu.RUnlock()
+	for url, cached := range m.embedded {
+		entries = append(entries, Entry{URL: url, StoredAt: cached.storedAt, Size: cached.size, Embedded: true})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].URL < entries[j].URL
+	})
+	return entries
+}
+
+// countingLoader counts the documents which failed to load.
+type countingLoader struct {
+	next ld.DocumentLoader
+}
+
+// CountErrors counts the failed loads of next in
+// metrics.DocumentLoadErrors.
+func CountErrors(next ld.DocumentLoader) ld.DocumentLoader {
+	return countingLoader{next: next}
+}
+
+func (l countingLoader) LoadDocument(u string) (*ld.RemoteDocument, error) {
+	doc, err := l.next.LoadDocument(u)
+	if err != nil {
+		metrics.DocumentLoadErrors.Inc()
+	}
+	return doc, err
+}
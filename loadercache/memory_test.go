@@ -0,0 +1,94 @@
+package loadercache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/metrics"
+	"github.com/iden3/go-schema-processor/v2/loaders"
+	"github.com/piprate/json-gold/ld"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	cache := NewMemory()
+	require.NoError(t, cache.Embed("https://example.com/embedded", []byte(`{"@context": {}}`)))
+	expires := time.Now().Add(time.Hour)
+	require.NoError(t, cache.Set("https://example.com/loaded", &ld.RemoteDocument{DocumentURL: "loaded"}, expires))
+	// embedded documents are not replaced
+	require.NoError(t, cache.Set("https://example.com/embedded", &ld.RemoteDocument{DocumentURL: "other"}, expires))
+
+	loaded, expireTime, err := cache.Get("https://example.com/loaded")
+	require.NoError(t, err)
+	require.Equal(t, "loaded", loaded.DocumentURL)
+	require.Equal(t, expires, expireTime)
+
+	n, err := cache.Flush(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	_, _, err = cache.Get("https://example.com/loaded")
+	require.ErrorIs(t, err, loaders.ErrCacheMiss)
+	embedded, _, err := cache.Get("https://example.com/embedded")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/embedded", embedded.DocumentURL)
+}
+
+func TestMemory_Metrics(t *testing.T) {
+	cache := NewMemory()
+	require.NoError(t, cache.Set("https://example.com/fresh", &ld.RemoteDocument{}, time.Now().Add(time.Hour)))
+	require.NoError(t, cache.Set("https://example.com/stale", &ld.RemoteDocument{}, time.Now().Add(-time.Second)))
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedResult string
+	}{
+		{name: "Hit", url: "https://example.com/fresh", expectedResult: lookupHit},
+		{name: "Expired", url: "https://example.com/stale", expectedResult: lookupExpired},
+		{name: "Miss", url: "https://example.com/unknown", expectedResult: lookupMiss},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := metrics.DocumentCacheLookups.WithLabelValues(tt.expectedResult)
+			before := testutil.ToFloat64(counter)
+			_, _, _ = cache.Get(tt.url)
+			require.InDelta(t, before+1, testutil.ToFloat64(counter), 0)
+		})
+	}
+}
+
+func TestMemory_Entries(t *testing.T) {
+	cache := NewMemory()
+	require.NoError(t, cache.Embed("https://example.com/embedded", []byte(`{"@context": {}}`)))
+	expires := time.Now().Add(time.Hour)
+	require.NoError(t, cache.Set("https://example.com/loaded",
+		&ld.RemoteDocument{Document: map[string]interface{}{"@context": "x"}}, expires))
+
+	entries := cache.Entries()
+	require.Len(t, entries, 2)
+	require.Equal(t, "https://example.com/embedded", entries[0].URL)
+	require.True(t, entries[0].Embedded)
+	require.Equal(t, len(`{"@context": {}}`), entries[0].Size)
+	require.Equal(t, "https://example.com/loaded", entries[1].URL)
+	require.Equal(t, expires, entries[1].ExpiresAt)
+	require.Equal(t, len(`{"@context":"x"}`), entries[1].Size)
+	require.WithinDuration(t, time.Now(), entries[1].StoredAt, time.Second)
+}
+
+type failingLoader struct{}
+
+func (failingLoader) LoadDocument(string) (*ld.RemoteDocument, error) {
+	return nil, errors.New("unreachable")
+}
+
+func TestCountErrors(t *testing.T) {
+	before := testutil.ToFloat64(metrics.DocumentLoadErrors)
+	_, err := CountErrors(failingLoader{}).LoadDocument("https://example.com/schema.json")
+	require.Error(t, err)
+	require.InDelta(t, before+1, testutil.ToFloat64(metrics.DocumentLoadErrors), 0)
+}
@@ -0,0 +1,58 @@
+package lock
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNotAcquired = errors.New("lock is held by another owner")
+	ErrLost        = errors.New("lock is no longer held")
+)
+
+// Locker provides mutual exclusion between service replicas.
+type Locker interface {
+	// Acquire takes the lock for key or returns ErrNotAcquired when
+	// it is held by someone else. The lock expires after ttl.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (*Lease, error)
+}
+
+// Lease is a held lock. Token is a fencing token which grows with every
+// acquisition of the same key.
+type Lease struct {
+	Key     string
+	Token   int64
+	release func(ctx context.Context) error
+	extend  func(ctx context.Context, ttl time.Duration) error
+}
+
+// NewLease returns the lease of a lock acquired by a Locker: release frees
+// the lock and extend keeps it for ttl from now, failing with ErrLost when
+// it is no longer held. Leases of Lockers without extend can't be renewed,
+// e.g. by a leader.Elector.
+func NewLease(
+	key string,
+	token int64,
+	release func(ctx context.Context) error,
+	extend func(ctx context.Context, ttl time.Duration) error,
+) *Lease {
+	return &Lease{Key: key, Token: token, release: release, extend: extend}
+}
+
+// Extend keeps the lock for ttl from now. It returns ErrLost when the lease
+// has expired and the lock may be held by someone else.
+func (l *Lease) Extend(ctx context.Context, ttl time.Duration) error {
+	if l == nil || l.extend == nil {
+		return errors.New("lease can't be extended")
+	}
+	return l.extend(ctx, ttl)
+}
+
+func (l *Lease) Release(ctx context.Context) error {
+	if l == nil || l.release == nil {
+		return nil
+	}
+	return l.release(ctx)
+}
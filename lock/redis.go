@@ -0,0 +1,65 @@
+package lock
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "refresh-service:lock:"
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+var extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+type RedisLocker struct {
+	client redis.UniversalClient
+}
+
+func NewRedisLocker(client redis.UniversalClient) *RedisLocker {
+	return &RedisLocker{client: client}
+}
+
+func (rl *RedisLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lease, error) {
+	lockKey := keyPrefix + key
+	token, err := rl.client.Incr(ctx, lockKey+":fence").Result()
+	if err != nil {
+		return nil, errors.Errorf("failed to get fencing token for '%s': %v", key, err)
+	}
+	value := strconv.FormatInt(token, 10)
+	ok, err := rl.client.SetNX(ctx, lockKey, value, ttl).Result()
+	if err != nil {
+		return nil, errors.Errorf("failed to acquire lock '%s': %v", key, err)
+	}
+	if !ok {
+		return nil, errors.Wrapf(ErrNotAcquired, "key '%s'", key)
+	}
+	release := func(ctx context.Context) error {
+		if err := releaseScript.Run(ctx, rl.client, []string{lockKey}, value).Err(); err != nil {
+			return errors.Errorf("failed to release lock '%s': %v", key, err)
+		}
+		return nil
+	}
+	extend := func(ctx context.Context, ttl time.Duration) error {
+		extended, err := extendScript.Run(ctx, rl.client, []string{lockKey}, value, ttl.Milliseconds()).Int()
+		if err != nil {
+			return errors.Errorf("failed to extend lock '%s': %v", key, err)
+		}
+		if extended == 0 {
+			return errors.Wrapf(ErrLost, "key '%s'", key)
+		}
+		return nil
+	}
+	return NewLease(key, token, release, extend), nil
+}
@@ -0,0 +1,62 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisLocker(t *testing.T) {
+	srv := miniredis.RunT(t)
+	locker := NewRedisLocker(redis.NewClient(&redis.Options{Addr: srv.Addr()}))
+	ctx := context.Background()
+
+	first, err := locker.Acquire(ctx, "issuer:credential", time.Minute)
+	require.NoError(t, err)
+
+	_, err = locker.Acquire(ctx, "issuer:credential", time.Minute)
+	require.ErrorIs(t, err, ErrNotAcquired)
+
+	other, err := locker.Acquire(ctx, "issuer:other", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, other.Release(ctx))
+
+	require.NoError(t, first.Release(ctx))
+	second, err := locker.Acquire(ctx, "issuer:credential", time.Minute)
+	require.NoError(t, err)
+	require.Greater(t, second.Token, first.Token)
+
+	// release of an expired lease must not drop the lock of the new owner
+	srv.FastForward(2 * time.Minute)
+	third, err := locker.Acquire(ctx, "issuer:credential", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, second.Release(ctx))
+	_, err = locker.Acquire(ctx, "issuer:credential", time.Minute)
+	require.ErrorIs(t, err, ErrNotAcquired)
+	require.NoError(t, third.Release(ctx))
+}
+
+func TestRedisLocker_Extend(t *testing.T) {
+	srv := miniredis.RunT(t)
+	locker := NewRedisLocker(redis.NewClient(&redis.Options{Addr: srv.Addr()}))
+	ctx := context.Background()
+
+	lease, err := locker.Acquire(ctx, "leader", time.Minute)
+	require.NoError(t, err)
+	srv.FastForward(50 * time.Second)
+	require.NoError(t, lease.Extend(ctx, time.Minute))
+	srv.FastForward(50 * time.Second)
+	_, err = locker.Acquire(ctx, "leader", time.Minute)
+	require.ErrorIs(t, err, ErrNotAcquired)
+
+	// a lease which expired and was taken over can't be extended
+	srv.FastForward(time.Minute)
+	other, err := locker.Acquire(ctx, "leader", time.Minute)
+	require.NoError(t, err)
+	require.ErrorIs(t, lease.Extend(ctx, time.Minute), ErrLost)
+	require.NoError(t, other.Extend(ctx, time.Minute))
+}
@@ -3,15 +3,32 @@ package logger
 import (
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-var DefaultLogger *zap.SugaredLogger
+var (
+	DefaultLogger *zap.SugaredLogger
+	level         = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+)
 
 // nolint:gochecknoinits // this is the simplest way to initialize the logger
 func init() {
-	logger, err := zap.NewDevelopment()
+	cfg := zap.NewDevelopmentConfig()
+	cfg.Level = level
+	logger, err := cfg.Build()
 	if err != nil {
 		panic(errors.Errorf("failed to initialize the logger: %v", err))
 	}
 	DefaultLogger = logger.Sugar()
 }
+
+// SetLevel changes the minimal level of DefaultLogger, e.g. 'info'.
+func SetLevel(text string) error {
+	return level.UnmarshalText([]byte(text))
+}
+
+// DebugEnabled reports whether debug messages are logged. Use it to skip
+// building expensive debug output.
+func DebugEnabled() bool {
+	return level.Enabled(zapcore.DebugLevel)
+}
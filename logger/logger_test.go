@@ -0,0 +1,17 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetLevel(t *testing.T) {
+	t.Cleanup(func() {
+		require.NoError(t, SetLevel("debug"))
+	})
+	require.True(t, DebugEnabled())
+	require.NoError(t, SetLevel("info"))
+	require.False(t, DebugEnabled())
+	require.Error(t, SetLevel("verbose"))
+}
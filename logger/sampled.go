@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type warningSample struct {
+	logged     int
+	suppressed int
+}
+
+// warningSampler logs the first burst occurrences of each warning format
+// per interval and reports the number of suppressed lines once the
+// interval ends.
+type warningSampler struct {
+	mu       sync.Mutex
+	burst    int
+	interval time.Duration
+	samples  map[string]*warningSample
+	output   func(template string, args ...interface{})
+}
+
+var warnings = newWarningSampler(5, time.Minute, func(template string, args ...interface{}) {
+	DefaultLogger.Warnf(template, args...)
+})
+
+func newWarningSampler(burst int, interval time.Duration,
+	output func(template string, args ...interface{})) *warningSampler {
+	return &warningSampler{
+		burst:    burst,
+		interval: interval,
+		samples:  make(map[string]*warningSample),
+		output:   output,
+	}
+}
+
+// SetWarningSampling configures how many identical warnings are logged
+// per interval. A non-positive burst disables sampling.
+func SetWarningSampling(burst int, interval time.Duration) {
+	warnings.mu.Lock()
+	defer warnings.mu.Unlock()
+	warnings.burst = burst
+	warnings.interval = interval
+}
+
+// SampledWarnf logs a warning, deduplicated by its template.
+func SampledWarnf(template string, args ...interface{}) {
+	warnings.warnf(template, args...)
+}
+
+func (ws *warningSampler) warnf(template string, args ...interface{}) {
+	ws.mu.Lock()
+	if ws.burst <= 0 || ws.interval <= 0 {
+		ws.mu.Unlock()
+		ws.output(template, args...)
+		return
+	}
+	sample, ok := ws.samples[template]
+	if !ok {
+		sample = &warningSample{}
+		ws.samples[template] = sample
+		time.AfterFunc(ws.interval, func() {
+			ws.summarize(template)
+		})
+	}
+	if sample.logged >= ws.burst {
+		sample.suppressed++
+		ws.mu.Unlock()
+		return
+	}
+	sample.logged++
+	ws.mu.Unlock()
+	ws.output(template, args...)
+}
+
+func (ws *warningSampler) summarize(template string) {
+	ws.mu.Lock()
+	sample, ok := ws.samples[template]
+	delete(ws.samples, template)
+	interval := ws.interval
+	ws.mu.Unlock()
+	if !ok || sample.suppressed == 0 {
+		return
+	}
+	ws.output("%s", fmt.Sprintf("suppressed %d more warnings like %q in the last %s",
+		sample.suppressed, template, interval))
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarningSampler(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	output := func(template string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, fmt.Sprintf(template, args...))
+	}
+
+	ws := newWarningSampler(2, 50*time.Millisecond, output)
+	for i := 0; i < 10; i++ {
+		ws.warnf("field %s not found", "balance")
+	}
+	ws.warnf("other warning")
+
+	mu.Lock()
+	require.Equal(t, []string{
+		"field balance not found",
+		"field balance not found",
+		"other warning",
+	}, lines)
+	mu.Unlock()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(lines) == 4
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, `suppressed 8 more warnings like "field %s not found" in the last 50ms`, lines[3])
+}
+
+func TestWarningSampler_Disabled(t *testing.T) {
+	var count int
+	ws := newWarningSampler(0, time.Minute, func(string, ...interface{}) {
+		count++
+	})
+	for i := 0; i < 10; i++ {
+		ws.warnf("warning")
+	}
+	require.Equal(t, 10, count)
+}
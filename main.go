@@ -1,19 +1,60 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	_ "embed"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
-	_ "github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/archive"
+	"github.com/0xPolygonID/refresh-service/audit"
+	"github.com/0xPolygonID/refresh-service/batch"
+	"github.com/0xPolygonID/refresh-service/didresolver"
+	"github.com/0xPolygonID/refresh-service/encryption"
+	"github.com/0xPolygonID/refresh-service/events"
+	"github.com/0xPolygonID/refresh-service/features"
+	"github.com/0xPolygonID/refresh-service/health"
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/jobs"
+	"github.com/0xPolygonID/refresh-service/lambda"
+	"github.com/0xPolygonID/refresh-service/leader"
+	"github.com/0xPolygonID/refresh-service/loadercache"
+	"github.com/0xPolygonID/refresh-service/lock"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/metrics"
 	"github.com/0xPolygonID/refresh-service/packagemanager"
+	"github.com/0xPolygonID/refresh-service/policy"
+	"github.com/0xPolygonID/refresh-service/profile"
+	"github.com/0xPolygonID/refresh-service/providercache"
 	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/reconcile"
+	"github.com/0xPolygonID/refresh-service/reporting"
+	"github.com/0xPolygonID/refresh-service/retention"
+	"github.com/0xPolygonID/refresh-service/schemaregistry"
+	"github.com/0xPolygonID/refresh-service/sdjwt"
+	"github.com/0xPolygonID/refresh-service/secrets"
 	"github.com/0xPolygonID/refresh-service/server"
 	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/encrypted"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/0xPolygonID/refresh-service/storage/postgres"
 	"github.com/iden3/go-schema-processor/v2/loaders"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/piprate/json-gold/ld"
 	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
 )
 
 var (
@@ -35,7 +76,7 @@ func (c *KVstring) Decode(value string) error {
 	contracts := make(map[string]string)
 	pairs := strings.Split(value, delimiter)
 	for _, pair := range pairs {
-		kvpair := strings.Split(pair, "=")
+		kvpair := strings.SplitN(pair, "=", 2)
 		if len(kvpair) != 2 {
 			return errors.Errorf("invalid map item: %q", pair)
 		}
@@ -47,15 +88,141 @@ func (c *KVstring) Decode(value string) error {
 }
 
 type Config struct {
-	SupportedIssuers          KVstring `envconfig:"SUPPORTED_ISSUERS" required:"true"`
-	IPFSGWURL                 string   `envconfig:"IPFS_GATEWAY_URL" default:"https://ipfs.io"`
-	ServerHost                string   `envconfig:"SERVER_HOST" default:":8002"`
-	HTTPConfigPath            string   `envconfig:"HTTP_CONFIG_PATH" default:"config.yaml"`
-	SupportedRPC              KVstring `envconfig:"SUPPORTED_RPC" required:"true"`
-	SupportedStateContracts   KVstring `envconfig:"SUPPORTED_STATE_CONTRACTS" required:"true"`
-	CircuitsFolderPath        string   `envconfig:"CIRCUITS_FOLDER_PATH" default:"keys"`
-	SupportedIssuersBasicAuth KVstring `envconfig:"ISSUERS_BASIC_AUTH"`
-	SupportedCustomDIDMethods string   `envconfig:"SUPPORTED_CUSTOM_DID_METHODS"`
+	SupportedIssuers          KVstring      `envconfig:"SUPPORTED_ISSUERS" required:"true"`
+	IPFSGWURL                 string        `envconfig:"IPFS_GATEWAY_URL" default:"https://ipfs.io"`
+	ServerHost                string        `envconfig:"SERVER_HOST" default:":8002"`
+	HTTPConfigPath            string        `envconfig:"HTTP_CONFIG_PATH" default:"config.yaml"`
+	ProviderTenants           KVstring      `envconfig:"PROVIDER_TENANTS"`
+	ProviderTenantIssuers     KVstring      `envconfig:"PROVIDER_TENANT_ISSUERS"`
+	ProviderTenantRateLimits  KVstring      `envconfig:"PROVIDER_TENANT_RATE_LIMITS"`
+	SupportedRPC              KVstring      `envconfig:"SUPPORTED_RPC" required:"true"`
+	SupportedStateContracts   KVstring      `envconfig:"SUPPORTED_STATE_CONTRACTS" required:"true"`
+	CircuitsFolderPath        string        `envconfig:"CIRCUITS_FOLDER_PATH" default:"keys"`
+	SupportedIssuersBasicAuth KVstring      `envconfig:"ISSUERS_BASIC_AUTH"`
+	IssuersTLSCert            KVstring      `envconfig:"ISSUERS_TLS_CERT"`
+	IssuersTLSKey             KVstring      `envconfig:"ISSUERS_TLS_KEY"`
+	IssuersTLSCA              KVstring      `envconfig:"ISSUERS_TLS_CA"`
+	IssuersNativeRefresh      KVstring      `envconfig:"ISSUERS_NATIVE_REFRESH"`
+	IssuersNodeIdentifier     KVstring      `envconfig:"ISSUERS_NODE_IDENTIFIER"`
+	IssuersSecondaryNodes     KVstring      `envconfig:"ISSUERS_SECONDARY_NODES"`
+	IssuersRecoveryInterval   time.Duration `envconfig:"ISSUERS_RECOVERY_INTERVAL" default:"30s"`
+	IssuersRateLimit          KVstring      `envconfig:"ISSUERS_RATE_LIMIT"`
+	IssuersHeaders            KVstring      `envconfig:"ISSUERS_HEADERS"`
+	IssuersUserAgent          string        `envconfig:"ISSUERS_USER_AGENT"`
+	IssuersMaxResponseBytes   int64         `envconfig:"ISSUERS_MAX_RESPONSE_BYTES" default:"8388608"`
+	IssuersOwnerDIDMethods    KVstring      `envconfig:"ISSUERS_OWNER_DID_METHODS" default:"*=iden3,polygonid"`
+	PresentationAudience      string        `envconfig:"PRESENTATION_AUDIENCE"`
+	OpenID4VCIIssuer          string        `envconfig:"OPENID4VCI_CREDENTIAL_ISSUER"`
+	OpenID4VCIOfferTTL        time.Duration `envconfig:"OPENID4VCI_OFFER_TTL" default:"10m"`
+	OpenID4VCITokenTTL        time.Duration `envconfig:"OPENID4VCI_TOKEN_TTL" default:"5m"`
+	AsyncRefresh              bool          `envconfig:"ASYNC_REFRESH"`
+	AsyncSessionTTL           time.Duration `envconfig:"ASYNC_SESSION_TTL" default:"1h"`
+	SupportedCustomDIDMethods string        `envconfig:"SUPPORTED_CUSTOM_DID_METHODS"`
+	WarningSampleBurst        int           `envconfig:"LOG_WARNING_SAMPLE_BURST" default:"5"`
+	WarningSampleInterval     time.Duration `envconfig:"LOG_WARNING_SAMPLE_INTERVAL" default:"1m"`
+	HealthCheckTimeout        time.Duration `envconfig:"HEALTH_CHECK_TIMEOUT" default:"5s"`
+	HealthReadinessChecks     []string      `envconfig:"HEALTH_READINESS_CHECKS"`
+	HealthCheckCacheTTL       time.Duration `envconfig:"HEALTH_CHECK_CACHE_TTL" default:"5s"`
+	HealthStartupTimeout      time.Duration `envconfig:"HEALTH_STARTUP_TIMEOUT" default:"1s"`
+	HealthStartupChecks       []string      `envconfig:"HEALTH_STARTUP_CHECKS"`
+	HealthLivenessTimeout     time.Duration `envconfig:"HEALTH_LIVENESS_TIMEOUT" default:"1s"`
+	HealthLivenessChecks      []string      `envconfig:"HEALTH_LIVENESS_CHECKS"`
+	HealthLivenessMaxLag      time.Duration `envconfig:"HEALTH_LIVENESS_MAX_LAG" default:"10s"`
+	DatabaseURL               string        `envconfig:"DATABASE_URL"`
+	RedisURL                  string        `envconfig:"REDIS_URL"`
+	EventsURL                 string        `envconfig:"EVENTS_URL"`
+	EventsTopic               string        `envconfig:"EVENTS_TOPIC" default:"refresh-service"`
+	RefreshLockTTL            time.Duration `envconfig:"REFRESH_LOCK_TTL" default:"2m"`
+	RefreshTimeout            time.Duration `envconfig:"REFRESH_TIMEOUT" default:"0s"`
+	RetryBudget               int           `envconfig:"RETRY_BUDGET"`
+	RetryBudgetLatency        time.Duration `envconfig:"RETRY_BUDGET_LATENCY"`
+	RevocationNonceCheck      bool          `envconfig:"REVOCATION_NONCE_CHECK"`
+	QuotaPerOwner             int64         `envconfig:"REFRESH_QUOTA_PER_OWNER"`
+	QuotaPerCredentialType    int64         `envconfig:"REFRESH_QUOTA_PER_CREDENTIAL_TYPE"`
+	QuotaPerCredential        int64         `envconfig:"REFRESH_QUOTA_PER_CREDENTIAL"`
+	QuotaWindow               time.Duration `envconfig:"REFRESH_QUOTA_WINDOW" default:"24h"`
+	JobMaxAttempts            int           `envconfig:"JOB_MAX_ATTEMPTS" default:"5"`
+	JobRetryBaseBackoff       time.Duration `envconfig:"JOB_RETRY_BASE_BACKOFF" default:"10s"`
+	JobRetryMaxBackoff        time.Duration `envconfig:"JOB_RETRY_MAX_BACKOFF" default:"10m"`
+	ReplayProtectionTTL       time.Duration `envconfig:"REPLAY_PROTECTION_TTL" default:"24h"`
+	AgentMaxMessageBytes      int64         `envconfig:"AGENT_MAX_MESSAGE_BYTES" default:"1048576"`
+	ProblemReports            bool          `envconfig:"PROBLEM_REPORTS"`
+	ReportOnlyMessages        bool          `envconfig:"MESSAGE_VALIDATION_REPORT_ONLY"`
+	EncryptionKeys            KVstring      `envconfig:"ENCRYPTION_KEYS"`
+	EncryptionPrimaryKey      string        `envconfig:"ENCRYPTION_PRIMARY_KEY"`
+	AdminToken                string        `envconfig:"ADMIN_TOKEN"`
+	AdminTokens               KVstring      `envconfig:"ADMIN_TOKENS"`
+	AdminServerHost           string        `envconfig:"ADMIN_SERVER_HOST"`
+	AdminAllowedNetworks      []string      `envconfig:"ADMIN_ALLOWED_NETWORKS"`
+	MetricsServerHost         string        `envconfig:"METRICS_SERVER_HOST"`
+	MetricsAllowedNetworks    []string      `envconfig:"METRICS_ALLOWED_NETWORKS"`
+	SLOObjectives             KVstring      `envconfig:"SLO_OBJECTIVES"`
+	SLOWindows                []string      `envconfig:"SLO_WINDOWS"`
+	PprofEnabled              bool          `envconfig:"PPROF_ENABLED"`
+	WebhookToken              string        `envconfig:"WEBHOOK_TOKEN"`
+	WebhookTTL                time.Duration `envconfig:"WEBHOOK_TTL" default:"24h"`
+	CacheInvalidationChannel  string        `envconfig:"PROVIDER_CACHE_INVALIDATION_CHANNEL"`
+	ReconcileInterval         time.Duration `envconfig:"PROVIDER_RECONCILE_INTERVAL"`
+	ReconcileSampleSize       int           `envconfig:"PROVIDER_RECONCILE_SAMPLE_SIZE" default:"10"`
+	RefreshServiceTypes       []string      `envconfig:"REFRESH_SERVICE_TYPES" default:"Iden3RefreshService2023"`
+	RefreshServiceEmitType    string        `envconfig:"REFRESH_SERVICE_EMIT_TYPE"`
+	IssuersStatusType         KVstring      `envconfig:"ISSUERS_CREDENTIAL_STATUS_TYPE"`
+	IssuersDisplayMethod      KVstring      `envconfig:"ISSUERS_DISPLAY_METHOD"`
+	ContextLoadConcurrency    int           `envconfig:"CONTEXT_LOAD_CONCURRENCY" default:"4"`
+	ExpirationSkew            time.Duration `envconfig:"EXPIRATION_SKEW" default:"0s"`
+	ReissueIssuanceDate       string        `envconfig:"REISSUE_ISSUANCE_DATE" default:"now"`
+	RefreshPolicyPath         string        `envconfig:"REFRESH_POLICY_PATH"`
+	SubjectMaxBytes           int           `envconfig:"SUBJECT_MAX_BYTES"`
+	SubjectMaxFields          int           `envconfig:"SUBJECT_MAX_FIELDS"`
+	SubjectMaxDepth           int           `envconfig:"SUBJECT_MAX_DEPTH"`
+	HTTPMaxIdleConnsPerHost   int           `envconfig:"HTTP_MAX_IDLE_CONNS_PER_HOST" default:"32"`
+	HTTPMaxConnsPerHost       int           `envconfig:"HTTP_MAX_CONNS_PER_HOST"`
+	HTTPIdleConnTimeout       time.Duration `envconfig:"HTTP_IDLE_CONN_TIMEOUT" default:"90s"`
+	OutboundSchemes           []string      `envconfig:"OUTBOUND_ALLOWED_SCHEMES" default:"https,http"`
+	OutboundBlockPrivateIPs   bool          `envconfig:"OUTBOUND_BLOCK_PRIVATE_IPS" default:"true"`
+	OutboundAllowedNetworks   []string      `envconfig:"OUTBOUND_ALLOWED_NETWORKS"`
+	BatchWorkers              int           `envconfig:"BATCH_WORKERS" default:"8"`
+	BatchIssuerConcurrency    int           `envconfig:"BATCH_ISSUER_CONCURRENCY" default:"4"`
+	BatchMaxItems             int           `envconfig:"BATCH_MAX_ITEMS" default:"100"`
+	SchemaRegistryURL         string        `envconfig:"SCHEMA_REGISTRY_URL"`
+	SchemaRegistryToken       string        `envconfig:"SCHEMA_REGISTRY_TOKEN"`
+	SchemaRegistryAliases     KVstring      `envconfig:"SCHEMA_REGISTRY_ALIASES"`
+	SchemaRegistryCacheTTL    time.Duration `envconfig:"SCHEMA_REGISTRY_CACHE_TTL" default:"1h"`
+	FeatureFlagsPath          string        `envconfig:"FEATURE_FLAGS_PATH"`
+	FeatureFlagsURL           string        `envconfig:"FEATURE_FLAGS_URL"`
+	FeatureFlagsInterval      time.Duration `envconfig:"FEATURE_FLAGS_RELOAD_INTERVAL" default:"1m"`
+	RouteTimeouts             KVstring      `envconfig:"ROUTE_TIMEOUTS"`
+	SlowRequestThreshold      time.Duration `envconfig:"SLOW_REQUEST_THRESHOLD" default:"5s"`
+	LogLevel                  string        `envconfig:"LOG_LEVEL" default:"info"`
+	Profile                   string        `envconfig:"PROFILE"`
+	ProviderFixturesPath      string        `envconfig:"PROVIDER_FIXTURES_PATH"`
+	FaultInjectionPath        string        `envconfig:"FAULT_INJECTION_PATH"`
+	SDJWTSigningKey           string        `envconfig:"SDJWT_SIGNING_KEY"`
+	SDJWTIssuer               string        `envconfig:"SDJWT_ISSUER"`
+	SDJWTKeyID                string        `envconfig:"SDJWT_KEY_ID"`
+	SDJWTCredentialTypes      KVstring      `envconfig:"SDJWT_CREDENTIAL_TYPES"`
+	SecretsPath               string        `envconfig:"SECRETS_PATH"`
+	SecretsReloadInterval     time.Duration `envconfig:"SECRETS_RELOAD_INTERVAL" default:"1m"`
+	SecretsRotationWindow     time.Duration `envconfig:"SECRETS_ROTATION_WINDOW" default:"15m"`
+	ArchiveEndpoint           string        `envconfig:"ARCHIVE_ENDPOINT" default:"https://s3.amazonaws.com"`
+	ArchiveBucket             string        `envconfig:"ARCHIVE_BUCKET"`
+	ArchiveRegion             string        `envconfig:"ARCHIVE_REGION"`
+	ArchiveAccessKeyID        string        `envconfig:"ARCHIVE_ACCESS_KEY_ID"`
+	ArchiveSecretAccessKey    string        `envconfig:"ARCHIVE_SECRET_ACCESS_KEY"`
+	ArchiveSessionToken       string        `envconfig:"ARCHIVE_SESSION_TOKEN"`
+	ArchivePrefix             string        `envconfig:"ARCHIVE_PREFIX"`
+	ArchiveWindow             time.Duration `envconfig:"ARCHIVE_WINDOW" default:"1h"`
+	ArchiveLookback           time.Duration `envconfig:"ARCHIVE_LOOKBACK" default:"24h"`
+	ArchiveRetention          time.Duration `envconfig:"ARCHIVE_RETENTION"`
+	RetentionPeriods          KVstring      `envconfig:"RETENTION_PERIODS" default:"jobs=720h"`
+	RetentionInterval         time.Duration `envconfig:"RETENTION_INTERVAL" default:"1h"`
+	SentryDSN                 string        `envconfig:"SENTRY_DSN"`
+	SentryEnvironment         string        `envconfig:"SENTRY_ENVIRONMENT" default:"production"`
+	LambdaBasePath            string        `envconfig:"LAMBDA_BASE_PATH"`
+	ServerTLSCert             string        `envconfig:"SERVER_TLS_CERT"`
+	ServerTLSKey              string        `envconfig:"SERVER_TLS_KEY"`
+	ServerH2C                 bool          `envconfig:"SERVER_H2C"`
+	ServerHTTP3               bool          `envconfig:"SERVER_HTTP3"`
 }
 
 func (c *Config) getServerHost() string {
@@ -70,11 +237,460 @@ func (c *Config) getSupportedIssuers() map[string]string {
 	return supportedIssuers
 }
 
+func (c *Config) getHTTPOptions() httpclient.Options {
+	opts := httpclient.DefaultOptions
+	opts.MaxIdleConnsPerHost = c.HTTPMaxIdleConnsPerHost
+	opts.MaxConnsPerHost = c.HTTPMaxConnsPerHost
+	opts.IdleConnTimeout = c.HTTPIdleConnTimeout
+	return opts
+}
+
+// getOutboundGuard protects requests to data providers and to documents
+// referenced by credentials. Issuer nodes are configured by the operator
+// and often run on private networks, so they are not guarded.
+func (c *Config) getOutboundGuard() (*httpclient.Guard, error) {
+	networks, err := httpclient.ParseNetworks(c.OutboundAllowedNetworks)
+	if err != nil {
+		return nil, err
+	}
+	return &httpclient.Guard{
+		Schemes:         c.OutboundSchemes,
+		BlockPrivate:    c.OutboundBlockPrivateIPs,
+		AllowedNetworks: networks,
+	}, nil
+}
+
+func (c *Config) getIssuersTLS() (map[string]*tls.Config, error) {
+	configs := make(map[string]*tls.Config, len(c.IssuersTLSCert))
+	for issuerDID, certFile := range c.IssuersTLSCert {
+		keyFile, ok := c.IssuersTLSKey[issuerDID]
+		if !ok {
+			return nil, errors.Errorf("no client key for issuer '%s'", issuerDID)
+		}
+		cfg, err := service.ClientCertificate{
+			CertFile: certFile,
+			KeyFile:  keyFile,
+			CAFile:   c.IssuersTLSCA[issuerDID],
+		}.LoadTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		configs[issuerDID] = cfg
+	}
+	return configs, nil
+}
+
+func (c *Config) getIssuersRateLimits() (map[string]service.RateLimit, error) {
+	limits := make(map[string]service.RateLimit, len(c.IssuersRateLimit))
+	for issuerDID, value := range c.IssuersRateLimit {
+		limit, err := service.ParseRateLimit(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "issuer '%s'", issuerDID)
+		}
+		limits[issuerDID] = limit
+	}
+	return limits, nil
+}
+
+var tenantNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// providerTenant is the provider registry of a tenant in PROVIDER_TENANTS
+// and the issuers whose credentials it refreshes.
+type providerTenant struct {
+	name      string
+	providers *flexiblehttp.FactoryFlexibleHTTP
+	issuers   []string
+}
+
+type providerTenants []providerTenant
+
+// initProviderTenants loads the provider registry of every tenant in
+// PROVIDER_TENANTS. A tenant gets its own connection pool from newClient,
+// its own rate limit and its own namespace of cache, so a misconfigured or
+// slow provider of one tenant can't hold the connections, request budget or
+// cached fields of another.
+func (c *Config) initProviderTenants(
+	newClient func() *http.Client,
+	cache providercache.Cache,
+	factoryOptions []flexiblehttp.FactoryOption,
+) (providerTenants, error) {
+	issuers := make(map[string][]string, len(c.ProviderTenants))
+	for issuerDID, name := range c.ProviderTenantIssuers {
+		if _, ok := c.ProviderTenants[name]; !ok {
+			return nil, errors.Errorf("issuer '%s' has unknown provider tenant '%s'", issuerDID, name)
+		}
+		issuers[name] = append(issuers[name], issuerDID)
+	}
+	for name := range c.ProviderTenantRateLimits {
+		if _, ok := c.ProviderTenants[name]; !ok {
+			return nil, errors.Errorf("rate limit of unknown provider tenant '%s'", name)
+		}
+	}
+
+	tenants := make(providerTenants, 0, len(c.ProviderTenants))
+	for name, configPath := range c.ProviderTenants {
+		if !tenantNameRe.MatchString(name) {
+			return nil, errors.Errorf("invalid provider tenant name '%s': only letters, digits, '_' and '-' are allowed", name)
+		}
+		client := newClient()
+		if value, ok := c.ProviderTenantRateLimits[name]; ok {
+			limit, err := service.ParseRateLimit(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "provider tenant '%s'", name)
+			}
+			client = httpclient.WithRateLimit(client, limit.Limiter())
+		}
+		options := append(slices.Clone(factoryOptions), flexiblehttp.WithCache(providercache.Namespace(cache, name)))
+		providers, err := flexiblehttp.NewFactoryFlexibleHTTP(configPath, client, options...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "provider tenant '%s'", name)
+		}
+		if len(issuers[name]) == 0 {
+			logger.DefaultLogger.Warnf("provider tenant '%s' has no issuers in PROVIDER_TENANT_ISSUERS", name)
+		}
+		sort.Strings(issuers[name])
+		tenants = append(tenants, providerTenant{name: name, providers: &providers, issuers: issuers[name]})
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].name < tenants[j].name })
+	return tenants, nil
+}
+
+// issuerProviders returns the registry of every issuer of a tenant.
+func (t providerTenants) issuerProviders() map[string]flexiblehttp.FactoryFlexibleHTTP {
+	providers := make(map[string]flexiblehttp.FactoryFlexibleHTTP)
+	for _, tenant := range t {
+		for _, issuerDID := range tenant.issuers {
+			providers[issuerDID] = *tenant.providers
+		}
+	}
+	return providers
+}
+
+// registries returns the default registry followed by the ones of the
+// tenants.
+func (t providerTenants) registries(defaultRegistry *flexiblehttp.FactoryFlexibleHTTP) []*flexiblehttp.FactoryFlexibleHTTP {
+	registries := []*flexiblehttp.FactoryFlexibleHTTP{defaultRegistry}
+	for _, tenant := range t {
+		registries = append(registries, tenant.providers)
+	}
+	return registries
+}
+
+// cacheFlushers adds the provider cache of every tenant to flushers as
+// 'providers:<tenant>'.
+func (t providerTenants) cacheFlushers(flushers map[string]server.CacheFlusher) map[string]server.CacheFlusher {
+	for _, tenant := range t {
+		flushers["providers:"+tenant.name] = server.CacheFlusherFunc(tenant.providers.FlushCache)
+	}
+	return flushers
+}
+
+func (t providerTenants) serverTenants() map[string]server.ProviderTenant {
+	tenants := make(map[string]server.ProviderTenant, len(t))
+	for _, tenant := range t {
+		tenants[tenant.name] = tenant.providers
+	}
+	return tenants
+}
+
+func (c *Config) getSchemaRegistry() schemaRegistryConfig {
+	return schemaRegistryConfig{
+		URL:      c.SchemaRegistryURL,
+		Token:    c.SchemaRegistryToken,
+		Aliases:  c.SchemaRegistryAliases,
+		CacheTTL: c.SchemaRegistryCacheTTL,
+	}
+}
+
+func (c *Config) getRouteTimeouts() (map[string]server.RouteTimeouts, error) {
+	timeouts := make(map[string]server.RouteTimeouts, len(c.RouteTimeouts))
+	for route, value := range c.RouteTimeouts {
+		switch route {
+		case server.RouteRefresh, server.RouteSignedRefresh, server.RoutePresentation,
+			server.RouteOpenID4VCI, server.RouteJobs, server.RouteHealth, server.RouteAdmin, server.RouteWebhook:
+		default:
+			return nil, errors.Errorf("unknown route '%s' in ROUTE_TIMEOUTS", route)
+		}
+		t, err := server.ParseRouteTimeouts(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "route '%s'", route)
+		}
+		timeouts[route] = t
+	}
+	return timeouts, nil
+}
+
+// initSLO serves the burn rates of the refresh availability objectives
+// when any is set.
+func (c *Config) initSLO() error {
+	if len(c.SLOObjectives) == 0 {
+		return nil
+	}
+	objectives, err := metrics.ParseSLOObjectives(c.SLOObjectives)
+	if err != nil {
+		return err
+	}
+	windows, err := metrics.ParseSLOWindows(c.SLOWindows)
+	if err != nil {
+		return err
+	}
+	return metrics.EnableSLO(objectives, windows)
+}
+
+// getRetentionPeriods refuses periods deleting history before it is
+// archived.
+func (c *Config) getRetentionPeriods() (map[string]time.Duration, error) {
+	periods, err := retention.ParsePeriods(c.RetentionPeriods)
+	if err != nil {
+		return nil, errors.Wrap(err, "RETENTION_PERIODS")
+	}
+	if c.ArchiveBucket == "" {
+		return periods, nil
+	}
+	for _, table := range []string{retention.TableRefreshHistory, retention.TableLineage} {
+		if period := periods[table]; period > 0 && period <= c.ArchiveLookback+c.ArchiveWindow {
+			return nil, errors.Errorf("retention of '%s' must be longer than ARCHIVE_LOOKBACK and ARCHIVE_WINDOW", table)
+		}
+	}
+	return periods, nil
+}
+
+// checkProfile returns the settings the strict profile p doesn't allow.
+func (c *Config) checkProfile(p profile.Profile) error {
+	if !p.Strict {
+		return nil
+	}
+	var problems []error
+	if strings.EqualFold(c.LogLevel, "debug") {
+		problems = append(problems, errors.New("LOG_LEVEL: debug logs hold raw credentials"))
+	}
+	for _, scheme := range c.OutboundSchemes {
+		if !strings.EqualFold(scheme, "https") {
+			problems = append(problems, errors.Errorf("OUTBOUND_ALLOWED_SCHEMES: scheme '%s' is not allowed", scheme))
+		}
+	}
+	if c.ProviderFixturesPath != "" {
+		problems = append(problems, errors.New("PROVIDER_FIXTURES_PATH: fixtures are for local development"))
+	}
+	if c.FaultInjectionPath != "" {
+		problems = append(problems, errors.New("FAULT_INJECTION_PATH: faults are for resilience tests"))
+	}
+	urls := map[string]string{
+		"IPFS_GATEWAY_URL":    c.IPFSGWURL,
+		"SCHEMA_REGISTRY_URL": c.SchemaRegistryURL,
+		"FEATURE_FLAGS_URL":   c.FeatureFlagsURL,
+		"ARCHIVE_ENDPOINT":    c.ArchiveEndpoint,
+	}
+	for issuerDID, u := range c.SupportedIssuers {
+		urls["SUPPORTED_ISSUERS "+issuerDID] = u
+	}
+	for chainID, u := range c.SupportedRPC {
+		urls["SUPPORTED_RPC "+chainID] = u
+	}
+	names := make([]string, 0, len(urls))
+	for name := range urls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := profile.RequireTLS(name, urls[name]); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return profile.Problems(p, problems)
+}
+
+// loadProfile applies the defaults of the PROFILE configuration profile to
+// the environment, so they are seen when it is processed.
+func loadProfile() (profile.Profile, error) {
+	p, err := profile.Lookup(os.Getenv("PROFILE"))
+	if err != nil {
+		return p, err
+	}
+	return p, p.Apply(os.LookupEnv, os.Setenv)
+}
+
+func (c *Config) getIssuersHeaders() (map[string]http.Header, error) {
+	headers := make(map[string]http.Header, len(c.IssuersHeaders))
+	for issuerDID, value := range c.IssuersHeaders {
+		h, err := service.ParseHeaders(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "issuer '%s'", issuerDID)
+		}
+		headers[issuerDID] = h
+	}
+	return headers, nil
+}
+
+// getIssuersOwnerDIDMethods returns the owner DID methods per issuer, each
+// one a method the registry resolves.
+func (c *Config) getIssuersOwnerDIDMethods(registry *didresolver.Registry) (map[string][]string, error) {
+	known := registry.Methods()
+	methods := make(map[string][]string, len(c.IssuersOwnerDIDMethods))
+	for issuerDID, value := range c.IssuersOwnerDIDMethods {
+		for _, method := range strings.Split(value, ",") {
+			method = strings.TrimSpace(method)
+			if method == "" {
+				continue
+			}
+			if !slices.Contains(known, method) {
+				return nil, errors.Errorf("issuer '%s': unsupported DID method '%s', supported methods: %s",
+					issuerDID, method, strings.Join(known, ", "))
+			}
+			methods[issuerDID] = append(methods[issuerDID], method)
+		}
+	}
+	return methods, nil
+}
+
+// initSecrets loads secrets from path and keeps reloading them in the
+// background, on every interval and on SIGHUP.
+func initSecrets(path string, interval, window time.Duration) (*secrets.Store, error) {
+	source := secrets.FileSource{Path: path}
+	store := secrets.NewStore(window)
+	if err := store.Reload(context.Background(), source); err != nil {
+		return nil, err
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	reload := make(chan struct{})
+	go func() {
+		for range hup {
+			reload <- struct{}{}
+		}
+	}()
+	go store.Watch(context.Background(), source, interval, reload)
+	return store, nil
+}
+
+// featureFlagsSource returns the source of feature flags, a file or a
+// remote provider, or nil when neither is configured.
+func featureFlagsSource(path, url string) (features.Source, error) {
+	switch {
+	case path != "" && url != "":
+		return nil, errors.New("FEATURE_FLAGS_PATH and FEATURE_FLAGS_URL are exclusive")
+	case path != "":
+		return features.FileSource{Path: path}, nil
+	case url != "":
+		return features.HTTPSource{URL: url, Client: httpclient.NewClient(httpclient.DefaultOptions, 0)}, nil
+	}
+	return nil, nil
+}
+
+// runSingletonJobs runs jobs which must not run on several replicas at
+// once, such as archiving and retention. With Redis they only run on the
+// elected leader, a standby takes over when it stops.
+func runSingletonJobs(redisClient *redis.Client, jobs []func(ctx context.Context)) {
+	lead := func(ctx context.Context) {
+		var wg sync.WaitGroup
+		for _, job := range jobs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				job(ctx)
+			}()
+		}
+		wg.Wait()
+	}
+	if redisClient == nil {
+		go lead(context.Background())
+		return
+	}
+	elector := leader.NewElector(lock.NewRedisLocker(redisClient), "singleton-jobs", leader.WithOnChange(func(isLeader bool) {
+		logger.DefaultLogger.Infow("singleton jobs leadership changed", "leader", isLeader)
+	}))
+	go elector.Run(context.Background(), lead)
+}
+
+// refreshPipelineOptions configures how credentials are reissued. The
+// options are shared by the service and the CLI commands.
+func refreshPipelineOptions(
+	refreshServiceTypes []string,
+	emitType string,
+	statusTypes KVstring,
+	displayMethods KVstring,
+	contextLoadConcurrency int,
+	expirationSkew time.Duration,
+	issuanceDate string,
+	policyPath string,
+	subjectLimits service.SubjectLimits,
+	flags *features.Set,
+) ([]service.RefreshOption, error) {
+	types := make([]verifiable.RefreshServiceType, 0, len(refreshServiceTypes))
+	for _, t := range refreshServiceTypes {
+		types = append(types, verifiable.RefreshServiceType(strings.TrimSpace(t)))
+	}
+	credentialStatusTypes := make(map[string]verifiable.CredentialStatusType, len(statusTypes))
+	for issuerDID, t := range statusTypes {
+		credentialStatusTypes[issuerDID] = verifiable.CredentialStatusType(t)
+	}
+	if err := service.ValidateCredentialStatusTypes(credentialStatusTypes); err != nil {
+		return nil, err
+	}
+	if err := service.ValidateIssuanceDate(issuanceDate); err != nil {
+		return nil, err
+	}
+	issuerDisplayMethods := make(map[string]verifiable.DisplayMethod, len(displayMethods))
+	for issuerDID, value := range displayMethods {
+		method, err := service.ParseDisplayMethod(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "issuer '%s'", issuerDID)
+		}
+		issuerDisplayMethods[issuerDID] = method
+	}
+	var eligibility service.EligibilityPolicy
+	if policyPath != "" {
+		rules, err := policy.Load(policyPath)
+		if err != nil {
+			return nil, err
+		}
+		eligibility = rules
+	}
+	return []service.RefreshOption{
+		service.WithEligibilityPolicy(eligibility),
+		service.WithCredentialStatusTypes(credentialStatusTypes),
+		service.WithDisplayMethods(issuerDisplayMethods),
+		service.WithContextLoadConcurrency(contextLoadConcurrency),
+		service.WithExpirationSkew(expirationSkew),
+		service.WithIssuanceDate(issuanceDate),
+		service.WithSubjectLimits(subjectLimits),
+		service.WithFeatureFlags(flags),
+		service.WithRefreshServiceTypes(types, verifiable.RefreshServiceType(emitType)),
+	}, nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	configProfile, err := loadProfile()
+	if err != nil {
+		log.Fatalf("failed init config profile: %v", err)
+	}
 	var cfg Config
 	if err := envconfig.Process("", &cfg); err != nil {
 		log.Fatalf("failed init config: %v", err)
 	}
+	if err := cfg.checkProfile(configProfile); err != nil {
+		log.Fatalf("failed init config: %v", err)
+	}
+
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		log.Fatalf("failed init log level: %v", err)
+	}
+	logger.SetWarningSampling(cfg.WarningSampleBurst, cfg.WarningSampleInterval)
+
+	if cfg.SentryDSN != "" {
+		sentryReporter, err := reporting.NewSentryReporter(cfg.SentryDSN, cfg.SentryEnvironment)
+		if err != nil {
+			log.Fatalf("failed init error reporting: %v", err)
+		}
+		reporting.DefaultReporter = sentryReporter
+	}
 
 	packageManager, err := packagemanager.NewPackageManager(
 		cfg.SupportedRPC,
@@ -86,51 +702,652 @@ func main() {
 		log.Fatalf("failed init package manager: %v", err)
 	}
 
+	issuerTLS, err := cfg.getIssuersTLS()
+	if err != nil {
+		log.Fatalf("failed init issuer client certificates: %v", err)
+	}
+
+	issuerRateLimits, err := cfg.getIssuersRateLimits()
+	if err != nil {
+		log.Fatalf("failed init issuer rate limits: %v", err)
+	}
+
+	issuerHeaders, err := cfg.getIssuersHeaders()
+	if err != nil {
+		log.Fatalf("failed init issuer headers: %v", err)
+	}
+
+	issuerOptions := []service.IssuerOption{
+		service.WithIssuerTLS(issuerTLS),
+		service.WithNativeRefresh(cfg.IssuersNativeRefresh),
+		service.WithIssuerIdentifiers(cfg.IssuersNodeIdentifier),
+		service.WithSecondaryNodes(cfg.IssuersSecondaryNodes),
+		service.WithRateLimits(issuerRateLimits),
+		service.WithUserAgent(cfg.IssuersUserAgent),
+		service.WithHeaders(issuerHeaders),
+		service.WithMaxResponseBytes(cfg.IssuersMaxResponseBytes),
+	}
+	var (
+		factoryOptions []flexiblehttp.FactoryOption
+		secretStore    *secrets.Store
+	)
+	if cfg.SecretsPath != "" {
+		secretStore, err = initSecrets(cfg.SecretsPath, cfg.SecretsReloadInterval, cfg.SecretsRotationWindow)
+		if err != nil {
+			log.Fatalf("failed init secrets: %v", err)
+		}
+		if _, ok := secretStore.Get(service.IssuersBasicAuthSecret); ok {
+			issuerOptions = append(issuerOptions, service.WithBasicAuthSecrets(secretStore))
+		}
+		factoryOptions = append(factoryOptions, flexiblehttp.WithSecrets(secretStore))
+	}
+
+	var faults *httpclient.FaultInjector
+	if cfg.FaultInjectionPath != "" {
+		faults, err = httpclient.LoadFaults(cfg.FaultInjectionPath)
+		if err != nil {
+			log.Fatalf("failed init fault injection: %v", err)
+		}
+		logger.DefaultLogger.Warnf("injecting faults from '%s' into issuer node and provider requests", cfg.FaultInjectionPath)
+	}
+
+	// issuer nodes and data providers get separate pools, so slow providers
+	// can't hold the connections issuer requests need
 	issuerService := service.NewIssuerService(
 		cfg.getSupportedIssuers(),
 		cfg.SupportedIssuersBasicAuth,
-		nil,
+		faults.Wrap(httpclient.NewClient(cfg.getHTTPOptions(), 0), httpclient.FaultTargetIssuer),
+		issuerOptions...,
 	)
+	if len(cfg.IssuersSecondaryNodes) > 0 {
+		go issuerService.RecoverPrimaryNodes(context.Background(), cfg.IssuersRecoveryInterval)
+	}
+
+	outboundGuard, err := cfg.getOutboundGuard()
+	if err != nil {
+		log.Fatalf("failed init outbound guard: %v", err)
+	}
+	guardedOptions := cfg.getHTTPOptions()
+	guardedOptions.Guard = outboundGuard
 
-	documentLoader, err := initDocumentLoaderWithCache(cfg.IPFSGWURL)
+	documentClient := httpclient.NewClient(guardedOptions, 0)
+	documentLoader, documentCache, err := initDocumentLoaderWithCache(cfg.IPFSGWURL,
+		documentClient, cfg.getSchemaRegistry())
 	if err != nil {
 		log.Fatalf("failed init document loader: %v", err)
 	}
 
+	var redisClient *redis.Client
+	if cfg.RedisURL != "" {
+		redisOptions, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("failed parse redis url: %v", err)
+		}
+		redisClient = redis.NewClient(redisOptions)
+	}
+
+	var cipher encryption.Cipher
+	if len(cfg.EncryptionKeys) > 0 {
+		cipher, err = encryption.NewAESGCM(cfg.EncryptionKeys, cfg.EncryptionPrimaryKey)
+		if err != nil {
+			log.Fatalf("failed init encryption: %v", err)
+		}
+	}
+
+	// jobs which must not run on several replicas at once
+	var singletonJobs []func(ctx context.Context)
+
+	// pushed provider fields must be visible to every replica
+	var providerCache providercache.Cache = providercache.NewMemory()
+	if redisClient != nil {
+		providerCache = providercache.NewRedis(redisClient, cipher)
+	}
+	factoryOptions = append(factoryOptions, flexiblehttp.WithCache(providerCache))
+	if redisClient != nil && cfg.CacheInvalidationChannel != "" {
+		go providercache.Subscribe(context.Background(), redisClient, cfg.CacheInvalidationChannel, providerCache)
+	}
+	if cfg.ReconcileInterval > 0 {
+		reconcileOptions := []reconcile.Option{
+			reconcile.WithInterval(cfg.ReconcileInterval),
+			reconcile.WithSampleSize(cfg.ReconcileSampleSize),
+		}
+		if redisClient != nil {
+			reconcileOptions = append(reconcileOptions, reconcile.WithLocker(lock.NewRedisLocker(redisClient)))
+		}
+		reconciler := reconcile.NewReconciler(reconcileOptions...)
+		factoryOptions = append(factoryOptions, flexiblehttp.WithSampler(reconciler))
+		singletonJobs = append(singletonJobs, reconciler.Run)
+	}
+
+	newProviderClient := func() *http.Client {
+		client := faults.Wrap(httpclient.NewClient(guardedOptions, 0), httpclient.FaultTargetProvider)
+		if cfg.ProviderFixturesPath != "" {
+			client = httpclient.WithFixtures(client, cfg.ProviderFixturesPath)
+		}
+		return client
+	}
 	flexhttp, err := flexiblehttp.NewFactoryFlexibleHTTP(
 		cfg.HTTPConfigPath,
-		nil,
+		newProviderClient(),
+		factoryOptions...,
 	)
 	if err != nil {
 		log.Fatalf("failed init flexiblehttp: %v", err)
 	}
+	providerTenants, err := cfg.initProviderTenants(newProviderClient, providerCache, factoryOptions)
+	if err != nil {
+		log.Fatalf("failed init provider tenants: %v", err)
+	}
+	if configProfile.Strict {
+		if err := flexhttp.Validate(); err != nil {
+			log.Fatalf("failed init flexiblehttp: %v", err)
+		}
+		for _, tenant := range providerTenants {
+			if err := tenant.providers.Validate(); err != nil {
+				log.Fatalf("failed init provider tenant '%s': %v", tenant.name, err)
+			}
+		}
+	}
+
+	var (
+		store          storage.Store
+		refreshOptions []service.RefreshOption
+	)
+	if cfg.DatabaseURL != "" {
+		store, err = postgres.NewStore(context.Background(), cfg.DatabaseURL)
+		if err != nil {
+			log.Fatalf("failed init database: %v", err)
+		}
+		refreshOptions = append(refreshOptions,
+			service.WithHistory(store),
+			service.WithLineage(store),
+			service.WithQuota(store, service.Quota{
+				PerOwner:          cfg.QuotaPerOwner,
+				PerCredentialType: cfg.QuotaPerCredentialType,
+				PerCredential:     cfg.QuotaPerCredential,
+				Window:            cfg.QuotaWindow,
+			}),
+		)
+	}
+
+	if store == nil && os.Getenv(lambda.RuntimeAPIEnv) != "" {
+		// instances of a Lambda function don't share memory, so replay
+		// protection, jobs and sessions would only hold per instance
+		log.Fatal("DATABASE_URL is required in AWS Lambda")
+	}
+
+	if store == nil && (cfg.QuotaPerOwner > 0 || cfg.QuotaPerCredentialType > 0 || cfg.QuotaPerCredential > 0) {
+		log.Fatal("DATABASE_URL is required with the REFRESH_QUOTA_* quotas")
+	}
+
+	if redisClient != nil {
+		refreshOptions = append(refreshOptions,
+			service.WithLocker(lock.NewRedisLocker(redisClient), cfg.RefreshLockTTL),
+		)
+	}
+
+	if cfg.ArchiveBucket != "" {
+		if store == nil {
+			log.Fatal("DATABASE_URL is required with ARCHIVE_BUCKET")
+		}
+		sink := archive.NewS3(archive.S3Config{
+			Endpoint:        cfg.ArchiveEndpoint,
+			Bucket:          cfg.ArchiveBucket,
+			Region:          cfg.ArchiveRegion,
+			AccessKeyID:     cfg.ArchiveAccessKeyID,
+			SecretAccessKey: cfg.ArchiveSecretAccessKey,
+			SessionToken:    cfg.ArchiveSessionToken,
+			Retention:       cfg.ArchiveRetention,
+		}, httpclient.NewClient(cfg.getHTTPOptions(), time.Minute))
+		archiveOptions := []archive.Option{
+			archive.WithPrefix(cfg.ArchivePrefix),
+			archive.WithWindow(cfg.ArchiveWindow),
+			archive.WithLookback(cfg.ArchiveLookback),
+		}
+		if redisClient != nil {
+			archiveOptions = append(archiveOptions, archive.WithLocker(lock.NewRedisLocker(redisClient)))
+		}
+		singletonJobs = append(singletonJobs, archive.NewArchiver(store, sink, archiveOptions...).Run)
+	}
+
+	var publisher events.Publisher
+	if cfg.EventsURL != "" {
+		publisher, err = events.New(cfg.EventsURL, cfg.EventsTopic)
+		if err != nil {
+			log.Fatalf("failed init events: %v", err)
+		}
+		refreshOptions = append(refreshOptions, service.WithEvents(publisher))
+	}
+	// did:web documents are fetched from hosts named by the requester
+	didResolver := didresolver.NewDefaultRegistry(httpclient.NewClient(guardedOptions, 10*time.Second))
+	ownerDIDMethods, err := cfg.getIssuersOwnerDIDMethods(didResolver)
+	if err != nil {
+		log.Fatalf("failed init owner DID methods: %v", err)
+	}
+	refreshOptions = append(refreshOptions,
+		service.WithRetryBudget(cfg.RetryBudget, cfg.RetryBudgetLatency),
+		service.WithRefreshTimeout(cfg.RefreshTimeout),
+		service.WithOwnerDIDMethods(ownerDIDMethods),
+		service.WithIssuerProviders(providerTenants.issuerProviders()),
+	)
+	if cfg.RevocationNonceCheck {
+		if store == nil {
+			log.Fatal("DATABASE_URL is required with REVOCATION_NONCE_CHECK")
+		}
+		refreshOptions = append(refreshOptions, service.WithRevocationNonceCheck())
+	}
+
+	var flags *features.Set
+	flagsSource, err := featureFlagsSource(cfg.FeatureFlagsPath, cfg.FeatureFlagsURL)
+	if err != nil {
+		log.Fatalf("failed init feature flags: %v", err)
+	}
+	if flagsSource != nil {
+		flags, err = features.Load(context.Background(), flagsSource)
+		if err != nil {
+			log.Fatalf("failed init feature flags: %v", err)
+		}
+		if cfg.FeatureFlagsInterval > 0 {
+			go flags.Watch(context.Background(), flagsSource, cfg.FeatureFlagsInterval)
+		}
+	}
+
+	pipelineOptions, err := refreshPipelineOptions(
+		cfg.RefreshServiceTypes,
+		cfg.RefreshServiceEmitType,
+		cfg.IssuersStatusType,
+		cfg.IssuersDisplayMethod,
+		cfg.ContextLoadConcurrency,
+		cfg.ExpirationSkew,
+		cfg.ReissueIssuanceDate,
+		cfg.RefreshPolicyPath,
+		service.SubjectLimits{
+			MaxBytes:  cfg.SubjectMaxBytes,
+			MaxFields: cfg.SubjectMaxFields,
+			MaxDepth:  cfg.SubjectMaxDepth,
+		},
+		flags,
+	)
+	if err != nil {
+		log.Fatalf("failed init refresh pipeline: %v", err)
+	}
+	refreshOptions = append(refreshOptions, pipelineOptions...)
 
 	refreshService := service.NewRefreshService(
 		issuerService,
 		documentLoader,
 		flexhttp,
+		refreshOptions...,
+	)
+
+	// state falls back to process memory when no database is configured
+	var state storage.Store = memory.NewStore()
+	if store != nil {
+		state = store
+	}
+	if cipher != nil {
+		state = encrypted.NewStore(state, cipher)
+	}
+	// reloads picked up by the service are audited along with the admin API
+	configAudit := audit.NewRecorder(state)
+	if secretStore != nil {
+		secretStore.OnChange(configAudit.Secrets(context.Background()))
+	}
+	if flags != nil {
+		flags.OnChange(configAudit.Flags(context.Background()))
+	}
+	jobQueue := jobs.NewQueue(
+		state,
+		refreshService,
+		jobs.WithMaxAttempts(cfg.JobMaxAttempts),
+		jobs.WithBackoff(cfg.JobRetryBaseBackoff, cfg.JobRetryMaxBackoff),
+	)
+	go jobQueue.Run(context.Background())
+
+	retentionPeriods, err := cfg.getRetentionPeriods()
+	if err != nil {
+		log.Fatalf("failed init retention: %v", err)
+	}
+	retentionOptions := []retention.Option{retention.WithInterval(cfg.RetentionInterval)}
+	if redisClient != nil {
+		retentionOptions = append(retentionOptions, retention.WithLocker(lock.NewRedisLocker(redisClient)))
+	}
+	singletonJobs = append(singletonJobs, retention.NewCleaner(state, retentionPeriods, retentionOptions...).Run)
+	runSingletonJobs(redisClient, singletonJobs)
+
+	batchEngine := batch.NewEngine(
+		refreshService,
+		batch.WithWorkers(cfg.BatchWorkers),
+		batch.WithIssuerConcurrency(cfg.BatchIssuerConcurrency),
+		batch.WithRenewal(service.ExpirationOnly),
 	)
 
+	agentOptions := []service.AgentOption{
+		service.WithReplayProtection(state, cfg.ReplayProtectionTTL),
+		service.WithBatchMessages(batchEngine, cfg.BatchMaxItems),
+		service.WithDIDResolver(didResolver),
+		service.WithPresentationAudience(cfg.PresentationAudience),
+	}
+	if cfg.ProblemReports {
+		agentOptions = append(agentOptions, service.WithProblemReports())
+	}
+	if cfg.ReportOnlyMessages {
+		agentOptions = append(agentOptions, service.WithReportOnlyMessageValidation())
+	}
+	if cfg.OpenID4VCIIssuer != "" {
+		agentOptions = append(agentOptions, service.WithOpenID4VCI(state, service.OpenID4VCISettings{
+			CredentialIssuer: cfg.OpenID4VCIIssuer,
+			OfferTTL:         cfg.OpenID4VCIOfferTTL,
+			TokenTTL:         cfg.OpenID4VCITokenTTL,
+		}))
+	}
+	if cfg.AsyncRefresh {
+		agentOptions = append(agentOptions, service.WithAsyncRefresh(jobQueue, state, cfg.AsyncSessionTTL))
+	}
+	if cfg.SDJWTSigningKey != "" {
+		if cfg.SDJWTIssuer == "" {
+			log.Fatal("SDJWT_ISSUER is required with SDJWT_SIGNING_KEY")
+		}
+		sdjwtIssuer, err := sdjwt.LoadIssuer(cfg.SDJWTSigningKey, cfg.SDJWTIssuer, cfg.SDJWTKeyID)
+		if err != nil {
+			log.Fatalf("failed init SD-JWT issuer: %v", err)
+		}
+		agentOptions = append(agentOptions, service.WithSDJWT(sdjwtIssuer, cfg.SDJWTCredentialTypes))
+	}
+
 	agentService := service.NewAgentService(
 		refreshService,
 		packageManager,
+		agentOptions...,
 	)
 
+	healthAggregator := initHealthChecks(
+		cfg.HealthCheckTimeout,
+		issuerService,
+		documentClient,
+		cfg.IPFSGWURL,
+		cfg.getSchemaRegistry(),
+		&flexhttp,
+		providerTenants,
+	)
+	if store != nil {
+		healthAggregator.Register("database", health.CheckerFunc(store.Ping), true)
+	}
+	if redisClient != nil {
+		healthAggregator.Register("redis", health.CheckerFunc(func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}), true)
+	}
+
+	healthAggregator.CacheFor(cfg.HealthCheckCacheTTL)
+	if err := healthAggregator.Select(cfg.HealthReadinessChecks); err != nil {
+		log.Fatalf("failed init readiness checks: %v", err)
+	}
+	startupChecks, livenessChecks, err := cfg.initProbes(documentLoader, providerTenants.registries(&flexhttp))
+	if err != nil {
+		log.Fatalf("failed init health checks: %v", err)
+	}
+
+	routeTimeouts, err := cfg.getRouteTimeouts()
+	if err != nil {
+		log.Fatalf("failed init route timeouts: %v", err)
+	}
+	adminTokens, err := server.ParseAdminTokens(cfg.AdminTokens)
+	if err != nil {
+		log.Fatalf("failed init admin tokens: %v", err)
+	}
+	adminNetworks, err := httpclient.ParseNetworks(cfg.AdminAllowedNetworks)
+	if err != nil {
+		log.Fatalf("failed init admin allowed networks: %v", err)
+	}
+	metricsNetworks, err := httpclient.ParseNetworks(cfg.MetricsAllowedNetworks)
+	if err != nil {
+		log.Fatalf("failed init metrics allowed networks: %v", err)
+	}
+	if cfg.MetricsServerHost == "" && len(metricsNetworks) == 0 {
+		logger.DefaultLogger.Warn("metrics are not served: set METRICS_SERVER_HOST or METRICS_ALLOWED_NETWORKS")
+	}
+	if err := cfg.initSLO(); err != nil {
+		log.Fatalf("failed init SLO metrics: %v", err)
+	}
+	handlerOptions := []server.HandlerOption{
+		server.WithAdminToken(cfg.AdminToken),
+		server.WithAdminTokens(adminTokens...),
+		server.WithMaxMessageBytes(cfg.AgentMaxMessageBytes),
+		server.WithJobs(jobQueue),
+		server.WithBatch(batchEngine, cfg.BatchMaxItems),
+		server.WithWebhook(cfg.WebhookToken, &flexhttp, cfg.WebhookTTL),
+		server.WithProviderCache(providerCache),
+		server.WithCaches(providerTenants.cacheFlushers(map[string]server.CacheFlusher{
+			"documents": documentCache,
+			"providers": server.CacheFlusherFunc(flexhttp.FlushCache),
+		})),
+		server.WithDocumentCache(documentCache),
+		server.WithProviderRegistry(&flexhttp),
+		server.WithProviderTenants(providerTenants.serverTenants()),
+		server.WithConfigAudit(configAudit),
+		server.WithStartupChecks(startupChecks),
+		server.WithLivenessChecks(livenessChecks),
+		server.WithRouteTimeouts(routeTimeouts),
+		server.WithSlowRequestThreshold(cfg.SlowRequestThreshold),
+		server.WithAdminListener(cfg.AdminServerHost),
+		server.WithAdminAllowedNetworks(adminNetworks),
+		server.WithMetricsListener(cfg.MetricsServerHost),
+		server.WithMetricsAllowedNetworks(metricsNetworks),
+		server.WithTLS(cfg.ServerTLSCert, cfg.ServerTLSKey),
+	}
+	if cfg.PprofEnabled {
+		handlerOptions = append(handlerOptions, server.WithPprof())
+	}
+	if cfg.ServerH2C {
+		handlerOptions = append(handlerOptions, server.WithH2C())
+	}
+	if cfg.ServerHTTP3 {
+		handlerOptions = append(handlerOptions, server.WithHTTP3())
+	}
+	if store != nil {
+		handlerOptions = append(handlerOptions, server.WithStatistics(store), server.WithHistory(store))
+	}
+
 	h := server.NewHandlers(
 		agentService,
+		healthAggregator,
+		handlerOptions...,
 	)
 
-	log.Fatal(h.Run(cfg.getServerHost()))
+	served := make(chan error, 1)
+	go func() {
+		served <- cfg.serve(h)
+	}()
+	shutdown, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	var serveErr error
+	select {
+	case serveErr = <-served:
+	case <-shutdown.Done():
+		logger.DefaultLogger.Info("shutting down")
+	}
+	if publisher != nil {
+		closeEvents(publisher, 5*time.Second)
+	}
+	if serveErr != nil {
+		reporting.DefaultReporter.Report(context.Background(), serveErr, 500)
+		reporting.DefaultReporter.Flush(2 * time.Second)
+		log.Fatal(serveErr)
+	}
 }
 
-func initDocumentLoaderWithCache(ipfsGW string) (ld.DocumentLoader, error) {
-	opts := loaders.WithEmbeddedDocumentBytes(
-		w3cSchemaURL, w3cSchemaBody,
-	)
-	memoryCacheEngine, err := loaders.NewMemoryCacheEngine(opts)
+// closeEvents sends the events still buffered by publisher before the
+// service exits, waiting at most timeout for an unreachable bus.
+func closeEvents(publisher events.Publisher, timeout time.Duration) {
+	closed := make(chan error, 1)
+	go func() {
+		closed <- publisher.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			logger.DefaultLogger.Warnf("failed to close events publisher: %v", err)
+		}
+	case <-time.After(timeout):
+		logger.DefaultLogger.Warnf("events publisher not closed within %s, pending events are lost", timeout)
+	}
+}
+
+// serve runs the listeners, or answers the invocations of the Lambda runtime
+// API with the public router when the service runs in an AWS Lambda
+// function.
+func (c *Config) serve(h *server.Handlers) error {
+	runtimeAPI := os.Getenv(lambda.RuntimeAPIEnv)
+	if runtimeAPI == "" {
+		return h.Run(c.getServerHost())
+	}
+	handler, err := h.Handler()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+	return lambda.NewAdapter(handler, lambda.WithBasePath(c.LambdaBasePath)).Start(ctx, runtimeAPI)
+}
+
+// initDocumentLoaderWithCache returns the document loader and its cache,
+// which the admin API can flush.
+func initDocumentLoaderWithCache(ipfsGW string, httpcli *http.Client,
+	registry schemaRegistryConfig) (ld.DocumentLoader, *loadercache.Memory, error) {
+	cache := loadercache.NewMemory()
+	if err := cache.Embed(w3cSchemaURL, w3cSchemaBody); err != nil {
+		return nil, nil, err
+	}
+	var l ld.DocumentLoader = loaders.NewDocumentLoader(nil, ipfsGW,
+		loaders.WithCacheEngine(cache),
+		loaders.WithHTTPClient(httpcli),
+	)
+	if registry.URL != "" {
+		aliases, err := registry.aliases()
+		if err != nil {
+			return nil, nil, err
+		}
+		// the registry is configured by the operator, it is not guarded
+		// like the URLs of credentials
+		l = schemaregistry.NewLoader(
+			schemaregistry.NewHTTPBackend(registry.URL, httpclient.NewClient(httpclient.DefaultOptions, 0), registry.Token),
+			l,
+			schemaregistry.WithAliases(aliases),
+			schemaregistry.WithCache(cache, registry.CacheTTL),
+		)
+	}
+	return loadercache.CountErrors(l), cache, nil
+}
+
+// schemaRegistryConfig is the schema registry documents are resolved from,
+// see the schemaregistry package. Without URL documents are only loaded
+// from their URLs.
+type schemaRegistryConfig struct {
+	URL      string
+	Token    string
+	Aliases  KVstring
+	CacheTTL time.Duration
+}
+
+func (c schemaRegistryConfig) aliases() (map[string]schemaregistry.Ref, error) {
+	aliases := make(map[string]schemaregistry.Ref, len(c.Aliases))
+	for documentURL, value := range c.Aliases {
+		ref, err := schemaregistry.ParseRef(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "alias of '%s'", documentURL)
+		}
+		aliases[documentURL] = ref
+	}
+	return aliases, nil
+}
+
+// initProbes returns the startup and liveness checks. Startup passes once
+// the schemas of the configured credential types are in the document cache,
+// liveness while the heartbeat goroutine is scheduled in time.
+func (c *Config) initProbes(
+	documentLoader ld.DocumentLoader,
+	providers []*flexiblehttp.FactoryFlexibleHTTP,
+) (startup, liveness *health.Aggregator, err error) {
+	warmed := health.NewGate("caches are warming up")
+	startup = health.NewAggregator(c.HealthStartupTimeout)
+	startup.Register("caches", warmed, true)
+	if err := startup.Select(c.HealthStartupChecks); err != nil {
+		return nil, nil, errors.Wrap(err, "startup")
+	}
+	go warmCaches(documentLoader, providers, warmed)
+
+	heartbeat := health.NewHeartbeat(time.Second, c.HealthLivenessMaxLag)
+	liveness = health.NewAggregator(c.HealthLivenessTimeout)
+	liveness.Register("heartbeat", heartbeat, true)
+	if err := liveness.Select(c.HealthLivenessChecks); err != nil {
+		return nil, nil, errors.Wrap(err, "liveness")
+	}
+	go heartbeat.Run(context.Background())
+	return startup, liveness, nil
+}
+
+// warmCaches loads the schemas of the credential types configured in any
+// registry and their contexts into the document cache, as verify-schemas
+// does, then opens warmed. Unreachable documents are only logged, they are
+// loaded again on the first refresh which needs them.
+func warmCaches(documentLoader ld.DocumentLoader, providers []*flexiblehttp.FactoryFlexibleHTTP, warmed *health.Gate) {
+	start := time.Now()
+	warmedTypes := make(map[string]bool)
+	for _, registry := range providers {
+		for _, credentialType := range registry.CredentialTypes() {
+			if warmedTypes[credentialType] {
+				continue
+			}
+			warmedTypes[credentialType] = true
+			for _, problem := range verifyCredentialType(documentLoader, credentialType) {
+				logger.DefaultLogger.Warnf("failed to warm the document cache for '%s': %v", credentialType, problem)
+			}
+		}
+	}
+	warmed.Open()
+	logger.DefaultLogger.Infof("caches warmed in %s", time.Since(start).Round(time.Millisecond))
+}
+
+func initHealthChecks(
+	timeout time.Duration,
+	issuerService *service.IssuerService,
+	documentClient *http.Client,
+	ipfsGW string,
+	registry schemaRegistryConfig,
+	providers *flexiblehttp.FactoryFlexibleHTTP,
+	tenants providerTenants,
+) *health.Aggregator {
+	aggregator := health.NewAggregator(timeout)
+	// the documents of the loader are cached, their origins are probed
+	aggregator.Register("documentLoader:ipfs", health.Reachable(documentClient, ipfsGW), false)
+	if registry.URL != "" {
+		aggregator.Register("documentLoader:schemaRegistry",
+			health.Reachable(httpclient.NewClient(httpclient.DefaultOptions, 0), registry.URL), true)
+	}
+	// one unreachable issuer node must not take the service down for the
+	// other issuers
+	issuerNodes := issuerService.IssuerNodes()
+	for issuerDID := range issuerNodes {
+		issuerDID := issuerDID
+		aggregator.Register("issuer:"+issuerDID, health.CheckerFunc(func(ctx context.Context) error {
+			return issuerService.Ping(ctx, issuerDID)
+		}), len(issuerNodes) == 1)
+	}
+	for _, credentialType := range providers.CredentialTypes() {
+		credentialType := credentialType
+		aggregator.Register("provider:"+credentialType, health.CheckerFunc(func(ctx context.Context) error {
+			return providers.Ping(ctx, credentialType)
+		}), false)
+	}
+	for _, tenant := range tenants {
+		tenant := tenant
+		for _, credentialType := range tenant.providers.CredentialTypes() {
+			credentialType := credentialType
+			aggregator.Register("provider:"+tenant.name+"/"+credentialType, health.CheckerFunc(func(ctx context.Context) error {
+				return tenant.providers.Ping(ctx, credentialType)
+			}), false)
+		}
 	}
-	l := loaders.NewDocumentLoader(nil, ipfsGW, loaders.WithCacheEngine(memoryCacheEngine))
-	return l, nil
+	return aggregator
 }
@@ -0,0 +1,159 @@
+// Package metrics holds the Prometheus metrics of the refresh service.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "refresh_service"
+
+// maxExemplarRequestID keeps the exemplar of a request id within the 128
+// characters OpenMetrics allows for its labels.
+const maxExemplarRequestID = 100
+
+// Registry holds every metric of the service, served by Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration is the latency of served requests by route,
+	// method and status code.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of served HTTP requests.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route", "method", "status"})
+	// HTTPSlowRequests counts requests which took longer than the slow
+	// request threshold, by route.
+	HTTPSlowRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "slow_requests_total",
+		Help:      "HTTP requests slower than the slow request threshold.",
+	}, []string{"route"})
+	// AgentMessageViolations counts agent messages failing the refresh
+	// protocol schema, by offending field.
+	AgentMessageViolations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "agent",
+		Name:      "message_violations_total",
+		Help:      "Agent message fields failing the refresh protocol schema.",
+	}, []string{"field"})
+	// DocumentCacheLookups counts lookups of the JSON-LD document cache by
+	// result: hit, miss or expired.
+	DocumentCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "document_cache",
+		Name:      "lookups_total",
+		Help:      "Lookups of the JSON-LD document cache by result.",
+	}, []string{"result"})
+	// DocumentLoadErrors counts JSON-LD documents which failed to load.
+	DocumentLoadErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "document_loader",
+		Name:      "errors_total",
+		Help:      "JSON-LD documents which failed to load.",
+	})
+	// RefreshDuration is the latency of credential refreshes by credential
+	// type and result, see ObserveRefresh.
+	RefreshDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "refresh",
+		Name:      "duration_seconds",
+		Help:      "Latency of credential refreshes by credential type and result.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"credential_type", "result"})
+	// InjectedFaults counts the faults injected into upstream requests by
+	// target, credential type of the rule and fault.
+	InjectedFaults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "faults",
+		Name:      "injected_total",
+		Help:      "Faults injected into upstream requests.",
+	}, []string{"target", "credential_type", "fault"})
+	// ReconcileChecks counts the sampled subjects whose cached provider
+	// fields were compared with the live provider, by credential type and
+	// result: match, drift, format_error or error.
+	ReconcileChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "reconcile",
+		Name:      "checks_total",
+		Help:      "Cached provider fields compared with the live provider, by result.",
+	}, []string{"credential_type", "result"})
+	// ReconcileDrifts counts the fields whose cached value drifted from the
+	// live provider, by credential type, field and kind of drift.
+	ReconcileDrifts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "reconcile",
+		Name:      "drifts_total",
+		Help:      "Cached provider fields drifted from the live provider.",
+	}, []string{"credential_type", "field", "kind"})
+	// ReconcileLastRun is the time of the last reconciliation run.
+	ReconcileLastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "reconcile",
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Unix time of the last reconciliation run.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HTTPSlowRequests,
+		AgentMessageViolations,
+		DocumentCacheLookups,
+		DocumentLoadErrors,
+		InjectedFaults,
+		RefreshDuration,
+		ReconcileChecks,
+		ReconcileDrifts,
+		ReconcileLastRun,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format, or in the
+// OpenMetrics format with the exemplars of the histograms when the scraper
+// asks for it.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// ObserveHTTPRequest records a served request.
+func ObserveHTTPRequest(ctx context.Context, route, method string, status int, elapsed time.Duration, slow bool) {
+	observe(ctx, HTTPRequestDuration.WithLabelValues(route, method, strconv.Itoa(status)), elapsed.Seconds())
+	if slow {
+		HTTPSlowRequests.WithLabelValues(route).Inc()
+	}
+}
+
+// observe records v with the trace of ctx as exemplar, so a slow bucket
+// leads to a request showing it. The request id stands in for requests
+// without a traceparent.
+func observe(ctx context.Context, observer prometheus.Observer, v float64) {
+	exemplarObserver, ok := observer.(prometheus.ExemplarObserver)
+	if !ok {
+		observer.Observe(v)
+		return
+	}
+	traceID, requestID := correlation.TraceID(ctx), correlation.FromContext(ctx)
+	switch {
+	case traceID != "":
+		exemplarObserver.ObserveWithExemplar(v, prometheus.Labels{"trace_id": traceID})
+	case requestID != "" && len(requestID) <= maxExemplarRequestID:
+		exemplarObserver.ObserveWithExemplar(v, prometheus.Labels{"request_id": requestID})
+	default:
+		observer.Observe(v)
+	}
+}
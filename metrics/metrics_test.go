@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserve_Exemplar(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected map[string]string
+	}{
+		{
+			name: "Trace id",
+			ctx: correlation.WithTraceparent(
+				context.WithValue(context.Background(), middleware.RequestIDKey, "host/abc-000001"),
+				"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+			expected: map[string]string{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"},
+		},
+		{
+			name:     "Request id",
+			ctx:      context.WithValue(context.Background(), middleware.RequestIDKey, "host/abc-000001"),
+			expected: map[string]string{"request_id": "host/abc-000001"},
+		},
+		{
+			name: "Neither",
+			ctx:  context.Background(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test", Buckets: []float64{1}})
+			observe(tt.ctx, histogram, 0.5)
+
+			var m dto.Metric
+			require.NoError(t, histogram.Write(&m))
+			exemplar := m.GetHistogram().GetBucket()[0].GetExemplar()
+			if tt.expected == nil {
+				require.Nil(t, exemplar)
+				return
+			}
+			labels := make(map[string]string)
+			for _, label := range exemplar.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			require.Equal(t, tt.expected, labels)
+		})
+	}
+}
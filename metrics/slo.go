@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of a refresh, see ObserveRefresh. Only server errors spend the
+// error budget, client errors are requests served as they should be.
+const (
+	ResultSuccess     = "success"
+	ResultClientError = "client_error"
+	ResultServerError = "server_error"
+)
+
+// sloResolution is the granularity of the SLO windows.
+const sloResolution = time.Minute
+
+// maxSLOWindow bounds the events kept per credential type.
+const maxSLOWindow = 3 * 24 * time.Hour
+
+// maxSLOWildcardTypes bounds the credential types tracked with the '*'
+// objective. Refreshes of types beyond it are tracked together as
+// sloOtherType, so unexpected types can't grow the series without bound.
+const maxSLOWildcardTypes = 50
+
+const sloOtherType = "other"
+
+// DefaultSLOWindows are the windows of the multiwindow, multi-burn-rate
+// alerts of the Google SRE workbook.
+var DefaultSLOWindows = []time.Duration{
+	5 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 6 * time.Hour, 24 * time.Hour, 3 * 24 * time.Hour,
+}
+
+// slo is the tracker enabled by EnableSLO.
+var slo atomic.Pointer[SLO]
+
+// ObserveRefresh records a refresh of a credential of credentialType, the
+// empty type when it failed before the type was known.
+func ObserveRefresh(ctx context.Context, credentialType, result string, elapsed time.Duration) {
+	if credentialType == "" {
+		credentialType = "unknown"
+	}
+	observe(ctx, RefreshDuration.WithLabelValues(credentialType, result), elapsed.Seconds())
+	if tracker := slo.Load(); tracker != nil {
+		tracker.Record(credentialType, result != ResultServerError, time.Now())
+	}
+}
+
+// EnableSLO tracks the availability objectives of credential types and
+// serves their burn rates over windows with the other metrics.
+func EnableSLO(objectives map[string]float64, windows []time.Duration) error {
+	tracker, err := NewSLO(objectives, windows)
+	if err != nil {
+		return err
+	}
+	if err := Registry.Register(tracker); err != nil {
+		return errors.Wrap(err, "failed to register SLO metrics")
+	}
+	slo.Store(tracker)
+	return nil
+}
+
+// SLO computes the burn rates of the refresh availability objectives of
+// credential types over sliding windows, so alerts are defined on the
+// objective directly. A burn rate of 1 spends the error budget exactly over
+// the SLO period, 14.4 spends 2% of a 30 day budget in an hour.
+//
+// Ratios and rates are computed per replica from the refreshes it served.
+// The refreshes in every window are exported as well, so the ratios of a
+// fleet are computed from their sums.
+type SLO struct {
+	objectives map[string]float64
+	windows    []time.Duration
+	buckets    int64
+
+	mu            sync.Mutex
+	series        map[string]*sloSeries
+	wildcardTypes int
+
+	objectiveDesc  *prometheus.Desc
+	refreshesDesc  *prometheus.Desc
+	goodDesc       *prometheus.Desc
+	errorRatioDesc *prometheus.Desc
+	burnRateDesc   *prometheus.Desc
+}
+
+// sloSeries counts the refreshes of a credential type per minute in a
+// ring, stamps tells the minute a slot holds.
+type sloSeries struct {
+	objective   float64
+	stamps      []int64
+	good, total []int64
+}
+
+// NewSLO returns a tracker of objectives, the availability targets of
+// credential types in (0, 1) with '*' for the types not named. Types
+// without an objective are not tracked, and at most maxSLOWildcardTypes
+// are tracked on their own with '*'.
+func NewSLO(objectives map[string]float64, windows []time.Duration) (*SLO, error) {
+	if len(objectives) == 0 {
+		return nil, errors.New("no SLO objective")
+	}
+	for credentialType, objective := range objectives {
+		if !(objective > 0 && objective < 1) {
+			return nil, errors.Errorf("SLO objective of '%s' is %v, expected a ratio between 0 and 1", credentialType, objective)
+		}
+	}
+	if len(windows) == 0 {
+		windows = DefaultSLOWindows
+	}
+	windows = append([]time.Duration(nil), windows...)
+	sort.Slice(windows, func(i, j int) bool { return windows[i] < windows[j] })
+	for _, window := range windows {
+		if window < sloResolution || window > maxSLOWindow || window%sloResolution != 0 {
+			return nil, errors.Errorf("SLO window %s is not whole minutes between %s and %s", window, sloResolution, maxSLOWindow)
+		}
+	}
+	return &SLO{
+		objectives: objectives,
+		windows:    windows,
+		buckets:    int64(windows[len(windows)-1] / sloResolution),
+		series:     make(map[string]*sloSeries),
+		objectiveDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "slo", "objective"),
+			"Availability objective of the refreshes of a credential type.",
+			[]string{"credential_type"}, nil),
+		refreshesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "slo", "window_refreshes"),
+			"Refreshes in the window.",
+			[]string{"credential_type", "window"}, nil),
+		goodDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "slo", "window_good_refreshes"),
+			"Refreshes in the window which didn't fail by a server error.",
+			[]string{"credential_type", "window"}, nil),
+		errorRatioDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "slo", "error_ratio"),
+			"Ratio of refreshes failed by server errors over the window.",
+			[]string{"credential_type", "window"}, nil),
+		burnRateDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "slo", "burn_rate"),
+			"Error budget burn rate of the refreshes over the window.",
+			[]string{"credential_type", "window"}, nil),
+	}, nil
+}
+
+// ParseSLOObjectives parses objectives given as ratios or percentages,
+// e.g. '0.999' or '99.9%'.
+func ParseSLOObjectives(values map[string]string) (map[string]float64, error) {
+	objectives := make(map[string]float64, len(values))
+	for credentialType, value := range values {
+		percent := len(value) > 0 && value[len(value)-1] == '%'
+		if percent {
+			value = value[:len(value)-1]
+		}
+		objective, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid SLO objective '%s' of '%s'", value, credentialType)
+		}
+		if percent {
+			objective /= 100
+		}
+		objectives[credentialType] = objective
+	}
+	return objectives, nil
+}
+
+// ParseSLOWindows parses windows such as '5m' or '6h', the default
+// windows when values is empty.
+func ParseSLOWindows(values []string) ([]time.Duration, error) {
+	windows := make([]time.Duration, 0, len(values))
+	for _, value := range values {
+		window, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, errors.Errorf("invalid SLO window '%s'", value)
+		}
+		windows = append(windows, window)
+	}
+	if len(windows) == 0 {
+		return DefaultSLOWindows, nil
+	}
+	return windows, nil
+}
+
+// Record counts a refresh of credentialType at now, good unless it failed
+// by a server error.
+func (s *SLO) Record(credentialType string, good bool, now time.Time) {
+	objective, named := s.objectives[credentialType]
+	if !named {
+		var ok bool
+		if objective, ok = s.objectives["*"]; !ok {
+			return
+		}
+	}
+	minute := now.Unix() / int64(sloResolution/time.Second)
+	slot := minute % s.buckets
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	series, ok := s.series[credentialType]
+	if !ok && !named && s.wildcardTypes >= maxSLOWildcardTypes {
+		credentialType = sloOtherType
+		series, ok = s.series[credentialType]
+	}
+	if !ok {
+		series = &sloSeries{
+			objective: objective,
+			stamps:    make([]int64, s.buckets),
+			good:      make([]int64, s.buckets),
+			total:     make([]int64, s.buckets),
+		}
+		s.series[credentialType] = series
+		if !named && credentialType != sloOtherType {
+			s.wildcardTypes++
+		}
+	}
+	if series.stamps[slot] != minute {
+		series.stamps[slot], series.good[slot], series.total[slot] = minute, 0, 0
+	}
+	series.total[slot]++
+	if good {
+		series.good[slot]++
+	}
+}
+
+// count returns the good and all refreshes of series in the window ending
+// with minute.
+func (s *SLO) count(series *sloSeries, window time.Duration, minute int64) (good, total int64) {
+	since := minute - int64(window/sloResolution)
+	for slot, stamp := range series.stamps {
+		if stamp > since && stamp <= minute {
+			good += series.good[slot]
+			total += series.total[slot]
+		}
+	}
+	return good, total
+}
+
+func (s *SLO) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.objectiveDesc
+	ch <- s.refreshesDesc
+	ch <- s.goodDesc
+	ch <- s.errorRatioDesc
+	ch <- s.burnRateDesc
+}
+
+func (s *SLO) Collect(ch chan<- prometheus.Metric) {
+	s.collect(ch, time.Now())
+}
+
+func (s *SLO) collect(ch chan<- prometheus.Metric, now time.Time) {
+	minute := now.Unix() / int64(sloResolution/time.Second)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for credentialType, series := range s.series {
+		ch <- prometheus.MustNewConstMetric(s.objectiveDesc, prometheus.GaugeValue, series.objective, credentialType)
+		for _, window := range s.windows {
+			good, total := s.count(series, window, minute)
+			var ratio float64
+			if total > 0 {
+				ratio = float64(total-good) / float64(total)
+			}
+			label := windowLabel(window)
+			ch <- prometheus.MustNewConstMetric(s.refreshesDesc, prometheus.GaugeValue, float64(total), credentialType, label)
+			ch <- prometheus.MustNewConstMetric(s.goodDesc, prometheus.GaugeValue, float64(good), credentialType, label)
+			ch <- prometheus.MustNewConstMetric(s.errorRatioDesc, prometheus.GaugeValue, ratio, credentialType, label)
+			ch <- prometheus.MustNewConstMetric(s.burnRateDesc, prometheus.GaugeValue,
+				ratio/(1-series.objective), credentialType, label)
+		}
+	}
+}
+
+// windowLabel names a window in its largest whole unit, e.g. '30m', '6h'
+// or '3d'.
+func windowLabel(window time.Duration) string {
+	switch {
+	case window%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", window/(24*time.Hour))
+	case window%time.Hour == 0:
+		return fmt.Sprintf("%dh", window/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", window/time.Minute)
+	}
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+// collectSLO returns the gauges of s at now by name, credential type and
+// window.
+func collectSLO(t *testing.T, s *SLO, now time.Time) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 1000)
+	s.collect(ch, now)
+	close(ch)
+	values := make(map[string]float64)
+	for metric := range ch {
+		var m dto.Metric
+		require.NoError(t, metric.Write(&m))
+		var key string
+		switch {
+		case metric.Desc() == s.objectiveDesc:
+			key = "objective"
+		case metric.Desc() == s.refreshesDesc:
+			key = "refreshes"
+		case metric.Desc() == s.goodDesc:
+			key = "good"
+		case metric.Desc() == s.errorRatioDesc:
+			key = "error_ratio"
+		case metric.Desc() == s.burnRateDesc:
+			key = "burn_rate"
+		}
+		for _, label := range m.GetLabel() {
+			key += "/" + label.GetValue()
+		}
+		values[key] = m.GetGauge().GetValue()
+	}
+	return values
+}
+
+func TestSLO(t *testing.T) {
+	s, err := NewSLO(map[string]float64{"KYC": 0.99, "*": 0.9}, []time.Duration{time.Hour, 5 * time.Minute})
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// an hour ago: 10 refreshes, 5 failed
+	for i := 0; i < 10; i++ {
+		s.Record("KYC", i%2 == 0, now.Add(-50*time.Minute))
+	}
+	// last minutes: 10 refreshes, 1 failed
+	for i := 0; i < 10; i++ {
+		s.Record("KYC", i != 0, now.Add(-time.Minute))
+	}
+	s.Record("Balance", false, now)
+	// out of every window
+	s.Record("KYC", false, now.Add(-2*time.Hour))
+
+	values := collectSLO(t, s, now)
+	require.InDelta(t, 0.99, values["objective/KYC"], 1e-9)
+	require.InDelta(t, 0.1, values["error_ratio/KYC/5m"], 1e-9)
+	require.InDelta(t, 10, values["burn_rate/KYC/5m"], 1e-9)
+	require.InDelta(t, 0.3, values["error_ratio/KYC/1h"], 1e-9)
+	require.InDelta(t, 30, values["burn_rate/KYC/1h"], 1e-9)
+	require.EqualValues(t, 20, values["refreshes/KYC/1h"])
+	require.EqualValues(t, 14, values["good/KYC/1h"])
+
+	// types not named take the '*' objective
+	require.InDelta(t, 0.9, values["objective/Balance"], 1e-9)
+	require.InDelta(t, 10, values["burn_rate/Balance/5m"], 1e-9)
+
+	// windows without refreshes burn nothing
+	values = collectSLO(t, s, now.Add(3*time.Hour))
+	require.Zero(t, values["burn_rate/KYC/1h"])
+}
+
+func TestSLO_Untracked(t *testing.T) {
+	s, err := NewSLO(map[string]float64{"KYC": 0.99}, nil)
+	require.NoError(t, err)
+	s.Record("Balance", false, time.Now())
+	require.Empty(t, collectSLO(t, s, time.Now()))
+}
+
+func TestSLO_WildcardTypes(t *testing.T) {
+	s, err := NewSLO(map[string]float64{"KYC": 0.99, "*": 0.9}, []time.Duration{time.Hour})
+	require.NoError(t, err)
+	now := time.Now()
+	for i := 0; i < maxSLOWildcardTypes+10; i++ {
+		s.Record(fmt.Sprintf("Type%d", i), true, now)
+	}
+	s.Record("KYC", true, now)
+
+	values := collectSLO(t, s, now)
+	require.EqualValues(t, 1, values["refreshes/Type0/1h"])
+	require.NotContains(t, values, fmt.Sprintf("refreshes/Type%d/1h", maxSLOWildcardTypes))
+	require.EqualValues(t, 10, values["refreshes/other/1h"])
+	require.InDelta(t, 0.9, values["objective/other"], 1e-9)
+	// named types are always tracked
+	require.EqualValues(t, 1, values["refreshes/KYC/1h"])
+}
+
+func TestNewSLO_Error(t *testing.T) {
+	tests := []struct {
+		name       string
+		objectives map[string]float64
+		windows    []time.Duration
+	}{
+		{name: "No objective"},
+		{name: "Objective of 1", objectives: map[string]float64{"*": 1}},
+		{name: "Negative objective", objectives: map[string]float64{"*": -0.5}},
+		{name: "Window under a minute", objectives: map[string]float64{"*": 0.99}, windows: []time.Duration{time.Second}},
+		{name: "Window over 3 days", objectives: map[string]float64{"*": 0.99}, windows: []time.Duration{7 * 24 * time.Hour}},
+		{name: "Window of partial minutes", objectives: map[string]float64{"*": 0.99}, windows: []time.Duration{90 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSLO(tt.objectives, tt.windows)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParseSLOObjectives(t *testing.T) {
+	objectives, err := ParseSLOObjectives(map[string]string{"*": "0.99", "KYC": "99.9%"})
+	require.NoError(t, err)
+	require.InDelta(t, 0.99, objectives["*"], 1e-9)
+	require.InDelta(t, 0.999, objectives["KYC"], 1e-9)
+
+	_, err = ParseSLOObjectives(map[string]string{"*": "high"})
+	require.EqualError(t, err, "invalid SLO objective 'high' of '*'")
+}
+
+func TestParseSLOWindows(t *testing.T) {
+	windows, err := ParseSLOWindows([]string{"5m", "1h"})
+	require.NoError(t, err)
+	require.Equal(t, []time.Duration{5 * time.Minute, time.Hour}, windows)
+
+	windows, err = ParseSLOWindows(nil)
+	require.NoError(t, err)
+	require.Equal(t, DefaultSLOWindows, windows)
+
+	_, err = ParseSLOWindows([]string{"soon"})
+	require.EqualError(t, err, "invalid SLO window 'soon'")
+}
+
+func TestWindowLabel(t *testing.T) {
+	require.Equal(t, "5m", windowLabel(5*time.Minute))
+	require.Equal(t, "90m", windowLabel(90*time.Minute))
+	require.Equal(t, "6h", windowLabel(6*time.Hour))
+	require.Equal(t, "3d", windowLabel(72*time.Hour))
+}
@@ -0,0 +1,135 @@
+// Package policy decides which credentials are refreshed with rules
+// written by the operator in CEL, https://github.com/google/cel-spec.
+package policy
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// costLimit bounds the evaluation of a rule, so a rule looping over large
+// subjects can't stall refreshes.
+const costLimit = 100_000
+
+// Rule is a named CEL expression which must be true for a credential to
+// be refreshed. It sees the credential as 'credential', with expirationDate
+// and issuanceDate as timestamps, and the current time as 'now'.
+type Rule struct {
+	Name       string `yaml:"name"`
+	Expression string `yaml:"expression"`
+}
+
+// CEL is an eligibility policy made of CEL rules. A credential is refreshed
+// only when all rules are true.
+type CEL struct {
+	rules []program
+}
+
+type program struct {
+	name string
+	cel.Program
+}
+
+// Load reads the rules from the YAML file at path:
+//
+//	rules:
+//	  - name: expired
+//	    expression: credential.expirationDate <= now
+func Load(path string) (*CEL, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Errorf("failed to read policy: %v", err)
+	}
+	var config struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(raw, &config); err != nil {
+		return nil, errors.Errorf("invalid policy '%s': %v", path, err)
+	}
+	return NewCEL(config.Rules)
+}
+
+// NewCEL compiles rules. Rules which don't compile or don't evaluate to a
+// bool are rejected.
+func NewCEL(rules []Rule) (*CEL, error) {
+	if len(rules) == 0 {
+		return nil, errors.New("policy has no rules")
+	}
+	env, err := cel.NewEnv(
+		cel.Variable("credential", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("now", cel.TimestampType),
+		cel.CrossTypeNumericComparisons(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p := &CEL{rules: make([]program, 0, len(rules))}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return nil, errors.Errorf("policy rule %d has no name", i)
+		}
+		ast, issues := env.Compile(rule.Expression)
+		if issues.Err() != nil {
+			return nil, errors.Errorf("policy rule '%s': %v", rule.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType {
+			return nil, errors.Errorf("policy rule '%s' is '%s', not bool", rule.Name, ast.OutputType())
+		}
+		prg, err := env.Program(ast, cel.CostLimit(costLimit))
+		if err != nil {
+			return nil, errors.Errorf("policy rule '%s': %v", rule.Name, err)
+		}
+		p.rules = append(p.rules, program{name: rule.Name, Program: prg})
+	}
+	return p, nil
+}
+
+// Eligible evaluates the rules in order and returns the first one which is
+// not true. Rules failing to evaluate, e.g. on a missing subject field, deny
+// the refresh.
+func (p *CEL) Eligible(credential *verifiable.W3CCredential, now time.Time) error {
+	vars, err := activation(credential, now)
+	if err != nil {
+		return err
+	}
+	for _, rule := range p.rules {
+		out, _, err := rule.Eval(vars)
+		if err != nil {
+			return errors.Errorf("policy rule '%s': %v", rule.name, err)
+		}
+		if allowed, ok := out.Value().(bool); !ok || !allowed {
+			return errors.Errorf("policy rule '%s' is not satisfied", rule.name)
+		}
+	}
+	return nil
+}
+
+func activation(credential *verifiable.W3CCredential, now time.Time) (map[string]interface{}, error) {
+	raw, err := json.Marshal(credential)
+	if err != nil {
+		return nil, errors.Errorf("invalid credential: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, errors.Errorf("invalid credential: %v", err)
+	}
+	// VCDM 2.0 validity periods are read into the same fields
+	delete(fields, "expirationDate")
+	delete(fields, "issuanceDate")
+	if credential.Expiration != nil {
+		fields["expirationDate"] = *credential.Expiration
+	}
+	if credential.IssuanceDate != nil {
+		fields["issuanceDate"] = *credential.IssuanceDate
+	}
+	return map[string]interface{}{
+		"credential": fields,
+		"now":        now,
+	}, nil
+}
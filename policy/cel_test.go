@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCEL_Eligible(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiration := now.Add(20 * time.Second)
+	credential := &verifiable.W3CCredential{
+		Type:       []string{"VerifiableCredential", "Balance"},
+		Issuer:     "did:iden3:issuer",
+		Expiration: &expiration,
+		CredentialSubject: map[string]interface{}{
+			"id":      "did:iden3:owner",
+			"balance": 150,
+		},
+	}
+
+	tests := []struct {
+		name        string
+		rules       []Rule
+		expectedErr string
+	}{
+		{
+			name: "Expires within grace",
+			rules: []Rule{
+				{Name: "grace", Expression: `credential.expirationDate <= now + duration("30s")`},
+				{Name: "type", Expression: `"Balance" in credential.type`},
+			},
+		},
+		{
+			name: "Not expired",
+			rules: []Rule{
+				{Name: "expired", Expression: `credential.expirationDate <= now`},
+			},
+			expectedErr: "policy rule 'expired' is not satisfied",
+		},
+		{
+			name: "Subject fields and issuer",
+			rules: []Rule{
+				{Name: "balance", Expression: `credential.credentialSubject.balance > 100`},
+				{Name: "issuer", Expression: `credential.issuer.startsWith("did:iden3:")`},
+			},
+		},
+		{
+			name: "Missing field denies",
+			rules: []Rule{
+				{Name: "country", Expression: `credential.credentialSubject.country == "DE"`},
+			},
+			expectedErr: "policy rule 'country': no such key: country",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewCEL(tt.rules)
+			require.NoError(t, err)
+			err = policy.Eligible(credential, now)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestNewCEL_Error(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+	}{
+		{name: "No rules"},
+		{name: "No name", rules: []Rule{{Expression: "true"}}},
+		{name: "Syntax", rules: []Rule{{Name: "broken", Expression: "credential.type &&"}}},
+		{name: "Not bool", rules: []Rule{{Name: "issuer", Expression: "now"}}},
+		{name: "Unknown variable", rules: []Rule{{Name: "owner", Expression: `owner == "did:iden3:owner"`}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCEL(tt.rules)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package profile
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var ErrUnknownProfile = errors.New("unknown configuration profile")
+
+// Profile is a named set of configuration defaults layered under the
+// environment: it only fills in variables which are not set.
+type Profile struct {
+	Name     string
+	Defaults map[string]string
+	// Strict profiles refuse settings which are only fine for local work,
+	// like debug logs or plain HTTP upstreams.
+	Strict bool
+}
+
+var (
+	// Dev runs against a local issuer node, answers data providers from
+	// fixtures when there are any and logs everything.
+	Dev = Profile{
+		Name: "dev",
+		Defaults: map[string]string{
+			"SUPPORTED_ISSUERS":          "*=http://localhost:3001",
+			"SUPPORTED_RPC":              "80002=https://rpc-amoy.polygon.technology",
+			"SUPPORTED_STATE_CONTRACTS":  "80002=0x1a4cC30f2aA0377b0c3bc9848766D90cb4404124",
+			"PROVIDER_FIXTURES_PATH":     "fixtures/providers",
+			"OUTBOUND_BLOCK_PRIVATE_IPS": "false",
+			"PROBLEM_REPORTS":            "true",
+			"LOG_LEVEL":                  "debug",
+		},
+	}
+	// Prod only talks TLS to upstreams, keeps credential data out of logs
+	// and refuses to start on an invalid provider configuration.
+	Prod = Profile{
+		Name: "prod",
+		Defaults: map[string]string{
+			"OUTBOUND_ALLOWED_SCHEMES":   "https",
+			"OUTBOUND_BLOCK_PRIVATE_IPS": "true",
+			"LOG_LEVEL":                  "info",
+		},
+		Strict: true,
+	}
+)
+
+var profiles = map[string]Profile{
+	Dev.Name:  Dev,
+	Prod.Name: Prod,
+}
+
+// Lookup returns the profile called name. No name is the empty profile,
+// which changes nothing.
+func Lookup(name string) (Profile, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return Profile{}, nil
+	}
+	p, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Profile{}, errors.Wrapf(ErrUnknownProfile, "'%s', available profiles: %s", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
+
+// Apply sets every default of p which lookup doesn't find. Variables set
+// to an empty value count as set.
+func (p Profile) Apply(lookup func(key string) (string, bool), set func(key, value string) error) error {
+	keys := make([]string, 0, len(p.Defaults))
+	for k := range p.Defaults {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if _, ok := lookup(k); ok {
+			continue
+		}
+		if err := set(k, p.Defaults[k]); err != nil {
+			return errors.Wrapf(err, "failed to apply '%s' of profile '%s'", k, p.Name)
+		}
+	}
+	return nil
+}
+
+// RequireTLS returns a problem when rawURL is set and isn't an https URL.
+// name is the setting the URL comes from.
+func RequireTLS(name, rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Errorf("%s: invalid url", name)
+	}
+	if !strings.EqualFold(u.Scheme, "https") {
+		return errors.Errorf("%s: '%s' is not an https url", name, u.Redacted())
+	}
+	return nil
+}
+
+// Problems joins the problems found by a strict profile into one error.
+func Problems(p Profile, problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		messages = append(messages, problem.Error())
+	}
+	return errors.Errorf("configuration not allowed by profile '%s': %s", p.Name, strings.Join(messages, "; "))
+}
@@ -0,0 +1,27 @@
+package providercache
+
+import (
+	"context"
+	"time"
+)
+
+// Entry holds credential subject fields of one subject, either from a data
+// provider response or pushed by the upstream system.
+type Entry struct {
+	Fields       map[string]interface{} `json:"fields"`
+	Pushed       bool                   `json:"pushed,omitempty"`
+	ETag         string                 `json:"etag,omitempty"`
+	LastModified string                 `json:"lastModified,omitempty"`
+	StoredAt     time.Time              `json:"storedAt"`
+}
+
+// Cache stores provider fields by credential type and subject key.
+type Cache interface {
+	// Get returns the entry of key, ok is false when there is none or it
+	// has expired.
+	Get(ctx context.Context, credentialType, key string) (entry Entry, ok bool, err error)
+	Set(ctx context.Context, credentialType, key string, entry Entry, ttl time.Duration) error
+	// Invalidate removes the entry of key, or every entry of the credential
+	// type when key is empty, and returns the number of removed entries.
+	Invalidate(ctx context.Context, credentialType, key string) (int64, error)
+}
@@ -0,0 +1,133 @@
+package providercache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/encryption"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	srv := miniredis.RunT(t)
+	cipher, err := encryption.NewAESGCM(map[string]string{"v1": "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}, "v1")
+	require.NoError(t, err)
+
+	caches := map[string]Cache{
+		"memory": NewMemory(),
+		"redis":  NewRedis(redis.NewClient(&redis.Options{Addr: srv.Addr()}), cipher),
+	}
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_, ok, err := cache.Get(ctx, "Balance", "0x1")
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			stored := Entry{Fields: map[string]interface{}{"balance": "10"}, Pushed: true, StoredAt: time.Now().UTC()}
+			require.NoError(t, cache.Set(ctx, "Balance", "0x1", stored, time.Minute))
+
+			entry, ok, err := cache.Get(ctx, "Balance", "0x1")
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.True(t, entry.Pushed)
+			require.Equal(t, stored.Fields, entry.Fields)
+
+			_, ok, err = cache.Get(ctx, "Other", "0x1")
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			require.NoError(t, cache.Set(ctx, "Balance", "0x2", stored, time.Nanosecond))
+			srv.FastForward(time.Second)
+			time.Sleep(time.Millisecond)
+			_, ok, err = cache.Get(ctx, "Balance", "0x2")
+			require.NoError(t, err)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	srv := miniredis.RunT(t)
+	caches := map[string]Cache{
+		"memory": NewMemory(),
+		"redis":  NewRedis(redis.NewClient(&redis.Options{Addr: srv.Addr()}), nil),
+	}
+	const balance = "https://example.com/balance.json-ld#Balance"
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			entry := Entry{Fields: map[string]interface{}{"balance": "10"}}
+			for _, key := range []string{"0x1", "0x2", "0x3"} {
+				require.NoError(t, cache.Set(ctx, balance, key, entry, time.Minute))
+			}
+			require.NoError(t, cache.Set(ctx, "Other", "0x1", entry, time.Minute))
+
+			n, err := cache.Invalidate(ctx, balance, "0x1")
+			require.NoError(t, err)
+			require.Equal(t, int64(1), n)
+			_, ok, err := cache.Get(ctx, balance, "0x1")
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			n, err = cache.Invalidate(ctx, balance, "")
+			require.NoError(t, err)
+			require.Equal(t, int64(2), n)
+			_, ok, err = cache.Get(ctx, balance, "0x2")
+			require.NoError(t, err)
+			require.False(t, ok)
+
+			_, ok, err = cache.Get(ctx, "Other", "0x1")
+			require.NoError(t, err)
+			require.True(t, ok)
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	cache := NewMemory()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, cache.Set(ctx, "Balance", "0x1", Entry{}, time.Minute))
+
+	go Subscribe(ctx, client, "invalidations", cache)
+	require.Eventually(t, func() bool {
+		return len(srv.PubSubChannels("invalidations")) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, client.Publish(ctx, "invalidations", `{"credentialType": "Balance", "key": "0x1"}`).Err())
+	require.Eventually(t, func() bool {
+		_, ok, _ := cache.Get(ctx, "Balance", "0x1")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNamespace(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemory()
+	acme, globex := Namespace(cache, "acme"), Namespace(cache, "globex")
+	entry := Entry{Fields: map[string]interface{}{"balance": "10"}}
+
+	require.NoError(t, acme.Set(ctx, "Balance", "0x1", entry, time.Minute))
+	_, ok, err := acme.Get(ctx, "Balance", "0x1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, ok, err = globex.Get(ctx, "Balance", "0x1")
+	require.NoError(t, err)
+	require.False(t, ok)
+	_, ok, err = cache.Get(ctx, "Balance", "0x1")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	n, err := globex.Invalidate(ctx, "Balance", "")
+	require.NoError(t, err)
+	require.Zero(t, n)
+	n, err = acme.Invalidate(ctx, "Balance", "")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+}
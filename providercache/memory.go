@@ -0,0 +1,65 @@
+package providercache
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+type memoryItem struct {
+	entry     Entry
+	expiresAt time.Time
+}
+
+// Memory is a process local cache for single replica deployments.
+type Memory struct {
+	mu    sync.Mutex
+	items map[string]map[string]memoryItem
+}
+
+func NewMemory() *Memory {
+	return &Memory{items: make(map[string]map[string]memoryItem)}
+}
+
+func (m *Memory) Get(_ context.Context, credentialType, key string) (Entry, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[credentialType][key]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	if time.Now().After(item.expiresAt) {
+		delete(m.items[credentialType], key)
+		return Entry{}, false, nil
+	}
+	entry := item.entry
+	entry.Fields = maps.Clone(entry.Fields)
+	return entry, true, nil
+}
+
+func (m *Memory) Set(_ context.Context, credentialType, key string, entry Entry, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.items[credentialType] == nil {
+		m.items[credentialType] = make(map[string]memoryItem)
+	}
+	entry.Fields = maps.Clone(entry.Fields)
+	m.items[credentialType][key] = memoryItem{entry: entry, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *Memory) Invalidate(_ context.Context, credentialType, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if key == "" {
+		n := int64(len(m.items[credentialType]))
+		delete(m.items, credentialType)
+		return n, nil
+	}
+	if _, ok := m.items[credentialType][key]; !ok {
+		return 0, nil
+	}
+	delete(m.items[credentialType], key)
+	return 1, nil
+}
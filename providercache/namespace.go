@@ -0,0 +1,36 @@
+package providercache
+
+import (
+	"context"
+	"time"
+)
+
+// namespaced keeps the entries of a namespace apart from the ones of other
+// namespaces and of the cache itself.
+type namespaced struct {
+	cache     Cache
+	namespace string
+}
+
+// Namespace returns a view of cache whose entries are kept apart, so
+// registries sharing a cache neither read nor invalidate the entries of each
+// other, even for the same credential type.
+func Namespace(cache Cache, namespace string) Cache {
+	return &namespaced{cache: cache, namespace: namespace}
+}
+
+func (n *namespaced) credentialType(credentialType string) string {
+	return n.namespace + "|" + credentialType
+}
+
+func (n *namespaced) Get(ctx context.Context, credentialType, key string) (Entry, bool, error) {
+	return n.cache.Get(ctx, n.credentialType(credentialType), key)
+}
+
+func (n *namespaced) Set(ctx context.Context, credentialType, key string, entry Entry, ttl time.Duration) error {
+	return n.cache.Set(ctx, n.credentialType(credentialType), key, entry, ttl)
+}
+
+func (n *namespaced) Invalidate(ctx context.Context, credentialType, key string) (int64, error) {
+	return n.cache.Invalidate(ctx, n.credentialType(credentialType), key)
+}
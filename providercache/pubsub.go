@@ -0,0 +1,45 @@
+package providercache
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// Invalidation is a message published by an upstream system when its data
+// changes. An empty key invalidates every subject of the credential type.
+type Invalidation struct {
+	CredentialType string `json:"credentialType"`
+	Key            string `json:"key,omitempty"`
+}
+
+// Subscribe invalidates cache entries on messages published to channel
+// until ctx is canceled.
+func Subscribe(ctx context.Context, client redis.UniversalClient, channel string, cache Cache) {
+	pubsub := client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			var invalidation Invalidation
+			if err := json.Unmarshal([]byte(message.Payload), &invalidation); err != nil || invalidation.CredentialType == "" {
+				logger.DefaultLogger.Warnf("invalid provider cache invalidation message on '%s': %q", channel, message.Payload)
+				continue
+			}
+			n, err := cache.Invalidate(ctx, invalidation.CredentialType, invalidation.Key)
+			if err != nil {
+				logger.DefaultLogger.Errorf("failed to invalidate provider cache: %v", err)
+				continue
+			}
+			logger.DefaultLogger.Infof("invalidated %d provider cache entries of '%s'", n, invalidation.CredentialType)
+		}
+	}
+}
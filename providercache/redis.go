@@ -0,0 +1,109 @@
+package providercache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/encryption"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "refresh-service:provider-cache:"
+
+// Redis shares the cache between service replicas. Entries contain
+// credential subject data and are encrypted when a cipher is set.
+type Redis struct {
+	client redis.UniversalClient
+	cipher encryption.Cipher
+}
+
+func NewRedis(client redis.UniversalClient, cipher encryption.Cipher) *Redis {
+	return &Redis{client: client, cipher: cipher}
+}
+
+func redisKey(credentialType, key string) string {
+	return keyPrefix + credentialType + ":" + key
+}
+
+func (r *Redis) Get(ctx context.Context, credentialType, key string) (Entry, bool, error) {
+	value, err := r.client.Get(ctx, redisKey(credentialType, key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, errors.Errorf("failed to get cache entry: %v", err)
+	}
+	if r.cipher != nil {
+		if value, err = r.cipher.Decrypt(value); err != nil {
+			return Entry{}, false, errors.Errorf("failed to decrypt cache entry: %v", err)
+		}
+	}
+	var entry Entry
+	if err := json.Unmarshal(value, &entry); err != nil {
+		return Entry{}, false, errors.Errorf("invalid cache entry: %v", err)
+	}
+	return entry, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, credentialType, key string, entry Entry, ttl time.Duration) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if r.cipher != nil {
+		if value, err = r.cipher.Encrypt(value); err != nil {
+			return errors.Errorf("failed to encrypt cache entry: %v", err)
+		}
+	}
+	if err := r.client.Set(ctx, redisKey(credentialType, key), value, ttl).Err(); err != nil {
+		return errors.Errorf("failed to set cache entry: %v", err)
+	}
+	return nil
+}
+
+func (r *Redis) Invalidate(ctx context.Context, credentialType, key string) (int64, error) {
+	if key != "" {
+		n, err := r.client.Del(ctx, redisKey(credentialType, key)).Result()
+		if err != nil {
+			return 0, errors.Errorf("failed to invalidate cache entry: %v", err)
+		}
+		return n, nil
+	}
+
+	var removed int64
+	iter := r.client.Scan(ctx, 0, escapeGlob(redisKey(credentialType, ""))+"*", 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) < 100 {
+			continue
+		}
+		n, err := r.client.Del(ctx, keys...).Result()
+		if err != nil {
+			return removed, errors.Errorf("failed to invalidate cache entries: %v", err)
+		}
+		removed += n
+		keys = keys[:0]
+	}
+	if err := iter.Err(); err != nil {
+		return removed, errors.Errorf("failed to scan cache entries: %v", err)
+	}
+	if len(keys) > 0 {
+		n, err := r.client.Del(ctx, keys...).Result()
+		if err != nil {
+			return removed, errors.Errorf("failed to invalidate cache entries: %v", err)
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// escapeGlob escapes credential types, which are URLs, for SCAN patterns.
+func escapeGlob(s string) string {
+	return globReplacer.Replace(s)
+}
+
+var globReplacer = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
@@ -0,0 +1,171 @@
+package flexiblehttp
+
+import (
+	"crypto/md5" //nolint:gosec // required by HTTP Digest
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	authSchemeBasic  = "basic"
+	authSchemeDigest = "digest"
+)
+
+// authSettings authenticate provider requests with HTTP Basic or Digest.
+// Username and password may use secret placeholders.
+type authSettings struct {
+	Scheme   string `yaml:"scheme"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+func (a authSettings) validate() error {
+	switch strings.ToLower(a.Scheme) {
+	case "":
+		if a.Username != "" || a.Password != "" {
+			return errors.New("auth scheme is empty")
+		}
+	case authSchemeBasic, authSchemeDigest:
+		if a.Username == "" {
+			return errors.New("auth username is empty")
+		}
+	default:
+		return errors.Errorf("unsupported auth scheme '%s'", a.Scheme)
+	}
+	return nil
+}
+
+func (a authSettings) digest() bool {
+	return strings.EqualFold(a.Scheme, authSchemeDigest)
+}
+
+func (fh *FlexibleHTTP) setDigestAuth(request *http.Request, challenge map[string]string, previousSecrets bool) error {
+	username, _, err := fh.resolveSecrets(fh.RequestSchema.Auth.Username, previousSecrets)
+	if err != nil {
+		return err
+	}
+	password, _, err := fh.resolveSecrets(fh.RequestSchema.Auth.Password, previousSecrets)
+	if err != nil {
+		return err
+	}
+	authorization, err := digestAuthorization(request, challenge, username, password)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", authorization)
+	return nil
+}
+
+// digestChallenge returns the parameters of the Digest challenge of a 401
+// response.
+func digestChallenge(resp *http.Response) (map[string]string, bool) {
+	for _, challenge := range resp.Header.Values("WWW-Authenticate") {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+		if strings.EqualFold(scheme, "Digest") {
+			return parseAuthParams(params), true
+		}
+	}
+	return nil, false
+}
+
+// parseAuthParams parses comma separated 'key=value' pairs whose values
+// may be quoted.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for s != "" {
+		var key, value string
+		key, s, _ = strings.Cut(s, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		s = strings.TrimLeft(s, " ")
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			s = s[min(i+1, len(s)):]
+			_, s, _ = strings.Cut(s, ",")
+		} else {
+			value, s, _ = strings.Cut(s, ",")
+			value = strings.TrimSpace(value)
+		}
+		if key != "" {
+			params[key] = value
+		}
+	}
+	return params
+}
+
+// digestAuthorization answers a Digest challenge (RFC 7616) for request.
+func digestAuthorization(request *http.Request, challenge map[string]string, username, password string) (string, error) {
+	algorithm := challenge["algorithm"]
+	session := strings.HasSuffix(strings.ToUpper(algorithm), "-SESS")
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", errors.Errorf("unsupported digest algorithm '%s'", algorithm)
+	}
+	h := func(parts ...string) string {
+		d := newHash()
+		_, _ = d.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	realm, nonce := challenge["realm"], challenge["nonce"]
+	uri := request.URL.RequestURI()
+
+	ha1 := h(username, realm, password)
+	if session {
+		ha1 = h(ha1, nonce, cnonce)
+	}
+	ha2 := h(request.Method, uri)
+
+	var qop string
+	for _, q := range strings.Split(challenge["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+	const nc = "00000001"
+	response := h(ha1, nonce, ha2)
+	if qop != "" {
+		response = h(ha1, nonce, nc, cnonce, qop, ha2)
+	}
+
+	authorization := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		quote(username), quote(realm), quote(nonce), quote(uri), response)
+	if algorithm != "" {
+		authorization += ", algorithm=" + algorithm
+	}
+	if qop != "" {
+		authorization += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+	if opaque, ok := challenge["opaque"]; ok {
+		authorization += fmt.Sprintf(`, opaque="%s"`, quote(opaque))
+	}
+	return authorization, nil
+}
+
+func quote(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,113 @@
+package flexiblehttp
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // required by HTTP Digest
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuthParams(t *testing.T) {
+	require.Equal(t, map[string]string{
+		"realm":     "legacy, inc",
+		"qop":       "auth,auth-int",
+		"nonce":     `a"b`,
+		"algorithm": "MD5",
+	}, parseAuthParams(`realm="legacy, inc", qop="auth,auth-int", nonce="a\"b", algorithm=MD5`))
+}
+
+// digestServer accepts requests of user:secret to its realm.
+func digestServer(t *testing.T, algorithm string, newHash func() hash.Hash) *httptest.Server {
+	h := func(parts ...string) string {
+		d := newHash()
+		_, _ = d.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, params, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if scheme != "Digest" {
+			w.Header().Set("WWW-Authenticate",
+				`Digest realm="legacy", qop="auth", nonce="n0nce", opaque="0paque", algorithm=`+algorithm)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		p := parseAuthParams(params)
+		require.Equal(t, "user", p["username"])
+		require.Equal(t, r.URL.RequestURI(), p["uri"])
+		require.Equal(t, "0paque", p["opaque"])
+		ha1 := h("user", "legacy", "secret")
+		expected := h(ha1, "n0nce", p["nc"], p["cnonce"], "auth", h(r.Method, p["uri"]))
+		if p["response"] != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"result": "100"}`))
+	}))
+}
+
+func TestProvide_DigestAuth(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		newHash   func() hash.Hash
+		password  string
+		ok        bool
+	}{
+		{name: "MD5", algorithm: "MD5", newHash: md5.New, password: "{{ secrets.LEGACY_PASSWORD }}", ok: true},
+		{name: "SHA-256", algorithm: "SHA-256", newHash: sha256.New, password: "secret", ok: true},
+		{name: "Wrong password", algorithm: "MD5", newHash: md5.New, password: "guess"},
+	}
+
+	store := secrets.NewStore(time.Minute)
+	store.Update(map[string]string{"LEGACY_PASSWORD": "secret"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := digestServer(t, tt.algorithm, tt.newHash)
+			defer srv.Close()
+
+			fh := FlexibleHTTP{
+				httpcli:  srv.Client(),
+				secrets:  store,
+				Provider: provider{URL: srv.URL + "/balance", Method: http.MethodGet},
+				RequestSchema: requestSchema{
+					Params: map[string]interface{}{"address": "{{ credentialSubject.address }}"},
+					Auth:   authSettings{Scheme: "digest", Username: "user", Password: tt.password},
+				},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			}
+			fields, err := fh.Provide(context.Background(), map[string]interface{}{"address": "0x1"})
+			if !tt.ok {
+				require.ErrorIs(t, err, ErrDataProviderIssue)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, map[string]interface{}{"balance": "100"}, fields)
+		})
+	}
+}
+
+func TestBuildRequest_BasicAuth(t *testing.T) {
+	fh := FlexibleHTTP{
+		Provider: provider{URL: "https://example.com", Method: http.MethodGet},
+		RequestSchema: requestSchema{
+			Auth: authSettings{Scheme: "basic", Username: "user", Password: "pass"},
+		},
+	}
+	request, err := fh.BuildRequest(map[string]interface{}{})
+	require.NoError(t, err)
+	username, password, ok := request.BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "user", username)
+	require.Equal(t, "pass", password)
+}
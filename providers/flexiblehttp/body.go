@@ -0,0 +1,39 @@
+package flexiblehttp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// requestBody fills the body template of the provider. Subject values are
+// escaped when the body is XML, e.g. a SOAP envelope.
+func (fh *FlexibleHTTP) requestBody(data templateData) (io.Reader, error) {
+	if fh.RequestSchema.Body == "" {
+		return http.NoBody, nil
+	}
+	escape := verbatim
+	if strings.Contains(fh.header("Content-Type"), "xml") {
+		escape = func(s string) string {
+			var escaped bytes.Buffer
+			_ = xml.EscapeText(&escaped, []byte(s))
+			return escaped.String()
+		}
+	}
+	body, err := fh.fill(fh.RequestSchema.Body, data, escape)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(body), nil
+}
+
+func (fh *FlexibleHTTP) header(name string) string {
+	for k, v := range fh.RequestSchema.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
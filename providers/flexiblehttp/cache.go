@@ -0,0 +1,145 @@
+package flexiblehttp
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/providercache"
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidPush = errors.New("invalid pushed update")
+
+const defaultCacheKey = "{{ credentialSubject.id }}"
+
+// WithCache serves provider fields from cache: values pushed by upstream
+// systems and, for providers with settings.cacheTTL, earlier responses.
+func WithCache(cache providercache.Cache) FactoryOption {
+	return func(factory *FactoryFlexibleHTTP) {
+		factory.cache = cache
+	}
+}
+
+// Push stores fields pushed by the upstream system for the subject with the
+// cache key, so refreshes use them without calling the provider. Only
+// fields the provider maps can be pushed, they are converted to the type of
+// their property like provider responses and fail with
+// ErrInvalidResponseSchema when they can't be.
+func (factory *FactoryFlexibleHTTP) Push(
+	ctx context.Context,
+	credentialType, key string,
+	fields map[string]interface{},
+	ttl time.Duration,
+) error {
+	if factory.cache == nil {
+		return errors.Wrap(ErrInvalidPush, "provider cache is disabled")
+	}
+	fh, err := factory.ProduceFlexibleHTTP(credentialType)
+	if err != nil {
+		return errors.Wrap(ErrInvalidPush, err.Error())
+	}
+	if key == "" || len(fields) == 0 {
+		return errors.Wrap(ErrInvalidPush, "key and fields are required")
+	}
+	types := fh.fieldTypes()
+	converted := make(map[string]interface{}, len(fields))
+	for field, value := range fields {
+		fieldType, ok := types[field]
+		if !ok {
+			return errors.Wrapf(ErrInvalidPush, "field '%s' is not mapped by the provider", field)
+		}
+		if converted[field], err = castToType(value, fieldType); err != nil {
+			return errors.Wrapf(ErrInvalidResponseSchema, "pushed field '%s': %v", field, err)
+		}
+	}
+	return factory.cache.Set(ctx, credentialType, key, providercache.Entry{
+		Fields:   converted,
+		Pushed:   true,
+		StoredAt: time.Now().UTC(),
+	}, ttl)
+}
+
+// fieldTypes returns the types of the credential subject fields the
+// response schema updates.
+func (fh *FlexibleHTTP) fieldTypes() map[string]string {
+	types := make(map[string]string, len(fh.ResponseSchema.Properties))
+	for _, property := range fh.ResponseSchema.Properties {
+		if p := strings.Split(property.MatchTo, "."); len(p) == 2 {
+			types[p[1]] = property.Type
+		}
+	}
+	for i := range fh.Sources {
+		maps.Copy(types, fh.Sources[i].fieldTypes())
+	}
+	return types
+}
+
+// mappedFields returns the credential subject fields the response schema
+// updates.
+func (fh *FlexibleHTTP) mappedFields() map[string]bool {
+	fields := make(map[string]bool)
+	for field := range fh.fieldTypes() {
+		fields[field] = true
+	}
+	return fields
+}
+
+// MappedFields returns the credential subject fields the provider updates,
+// sorted.
+func (fh *FlexibleHTTP) MappedFields() []string {
+	return slices.Sorted(maps.Keys(fh.mappedFields()))
+}
+
+// cacheKey identifies the subject in the cache, by default its id.
+func (fh *FlexibleHTTP) cacheKey(credentialSubject map[string]interface{}) (string, bool) {
+	template := fh.Settings.CacheKey
+	if template == "" {
+		template = defaultCacheKey
+	}
+	value, err := findPlaceholderValue(template, credentialSubject)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%v", value), true
+}
+
+// validatorRetention is how long responses with validators are kept for
+// conditional requests after they are no longer fresh.
+const validatorRetention = 24 * time.Hour
+
+// cached returns the cached entry of the subject. Cache failures only cost
+// a provider call, so they are logged and treated as a miss.
+func (fh *FlexibleHTTP) cached(ctx context.Context, key string) (providercache.Entry, bool) {
+	entry, ok, err := fh.cache.Get(ctx, fh.credentialType, key)
+	if err != nil {
+		logger.SampledWarnf("failed to read provider cache for '%s': %v", fh.credentialType, err)
+		return providercache.Entry{}, false
+	}
+	return entry, ok
+}
+
+func (fh *FlexibleHTTP) fresh(entry providercache.Entry) bool {
+	return fh.Settings.CacheTTL > 0 && time.Since(entry.StoredAt) < fh.Settings.CacheTTL
+}
+
+// store caches a provider response: for cacheTTL, and longer when it can
+// be revalidated with a conditional request.
+func (fh *FlexibleHTTP) store(ctx context.Context, key string, entry *providercache.Entry) {
+	retention := fh.Settings.CacheTTL
+	if fh.Settings.ConditionalRequests && (entry.ETag != "" || entry.LastModified != "") {
+		retention = max(retention, validatorRetention)
+	}
+	if retention <= 0 {
+		return
+	}
+	stored := *entry
+	stored.Fields = maps.Clone(entry.Fields)
+	if err := fh.cache.Set(ctx, fh.credentialType, key, stored, retention); err != nil {
+		logger.SampledWarnf("failed to write provider cache for '%s': %v", fh.credentialType, err)
+	}
+}
@@ -0,0 +1,157 @@
+package flexiblehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providercache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvide_Cache(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(`{"result": "100"}`))
+	}))
+	defer srv.Close()
+
+	factory := FactoryFlexibleHTTP{
+		configuration: newRegistry(map[string]FlexibleHTTP{
+			"Balance": {
+				Settings: settings{CacheKey: "{{ credentialSubject.address }}"},
+				Provider: provider{URL: srv.URL, Method: http.MethodGet},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			},
+			"Cached": {
+				Settings: settings{CacheTTL: time.Minute},
+				Provider: provider{URL: srv.URL, Method: http.MethodGet},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			},
+		}),
+		httpcli: srv.Client(),
+		cache:   providercache.NewMemory(),
+	}
+	ctx := context.Background()
+
+	err := factory.Push(ctx, "Balance", "0x1", map[string]interface{}{"id": "did:iden3:other"}, time.Minute)
+	require.ErrorIs(t, err, ErrInvalidPush)
+	err = factory.Push(ctx, "Unknown", "0x1", map[string]interface{}{"balance": "1"}, time.Minute)
+	require.ErrorIs(t, err, ErrInvalidPush)
+	require.NoError(t, factory.Push(ctx, "Balance", "0x1", map[string]interface{}{"balance": "5"}, time.Minute))
+
+	balance, err := factory.ProduceFlexibleHTTP("Balance")
+	require.NoError(t, err)
+	fields, err := balance.Provide(ctx, map[string]interface{}{"address": "0x1"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"balance": "5"}, fields)
+	require.Zero(t, calls)
+
+	// responses are not cached without cacheTTL
+	for range 2 {
+		fields, err = balance.Provide(ctx, map[string]interface{}{"address": "0x2"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"balance": "100"}, fields)
+	}
+	require.Equal(t, 2, calls)
+
+	cached, err := factory.ProduceFlexibleHTTP("Cached")
+	require.NoError(t, err)
+	for range 2 {
+		fields, err = cached.Provide(ctx, map[string]interface{}{"id": "did:iden3:owner"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"balance": "100"}, fields)
+	}
+	require.Equal(t, 3, calls)
+}
+
+func TestPush_ConvertsTypes(t *testing.T) {
+	factory := FactoryFlexibleHTTP{
+		configuration: newRegistry(map[string]FlexibleHTTP{
+			"Balance": {
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result":   {Type: "integer", MatchTo: "credentialSubject.balance"},
+					"verified": {Type: "boolean", MatchTo: "credentialSubject.verified"},
+				}},
+			},
+		}),
+		cache: providercache.NewMemory(),
+	}
+
+	tests := []struct {
+		name     string
+		fields   map[string]interface{}
+		expected map[string]interface{}
+		err      error
+	}{
+		{
+			name:     "Converted",
+			fields:   map[string]interface{}{"balance": "5", "verified": "true"},
+			expected: map[string]interface{}{"balance": 5, "verified": true},
+		},
+		{
+			name:     "JSON number",
+			fields:   map[string]interface{}{"balance": float64(5)},
+			expected: map[string]interface{}{"balance": 5},
+		},
+		{name: "Not an integer", fields: map[string]interface{}{"balance": "five"}, err: ErrInvalidResponseSchema},
+		{name: "Object", fields: map[string]interface{}{"balance": map[string]interface{}{}}, err: ErrInvalidResponseSchema},
+		{name: "Not mapped", fields: map[string]interface{}{"age": 5}, err: ErrInvalidPush},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			err := factory.Push(ctx, "Balance", tt.name, tt.fields, time.Minute)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			entry, ok, err := factory.cache.Get(ctx, "Balance", tt.name)
+			require.NoError(t, err)
+			require.True(t, ok)
+			require.Equal(t, tt.expected, entry.Fields)
+		})
+	}
+}
+
+func TestProvide_ConditionalRequest(t *testing.T) {
+	var calls, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"result": "100"}`))
+	}))
+	defer srv.Close()
+
+	fh := FlexibleHTTP{
+		httpcli:        srv.Client(),
+		cache:          providercache.NewMemory(),
+		credentialType: "Balance",
+		Settings:       settings{ConditionalRequests: true},
+		Provider:       provider{URL: srv.URL, Method: http.MethodGet},
+		ResponseSchema: responseSchema{Properties: map[string]matchedField{
+			"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+		}},
+	}
+	subject := map[string]interface{}{"id": "did:iden3:owner"}
+	for range 3 {
+		fields, err := fh.Provide(context.Background(), subject)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"balance": "100"}, fields)
+	}
+	require.Equal(t, 3, calls)
+	require.Equal(t, 2, notModified)
+}
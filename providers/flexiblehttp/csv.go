@@ -0,0 +1,92 @@
+package flexiblehttp
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+type csvSettings struct {
+	Delimiter string `yaml:"delimiter"`
+	KeyColumn string `yaml:"keyColumn"`
+	Key       string `yaml:"key"`
+}
+
+func (c csvSettings) validate() error {
+	if c.KeyColumn == "" {
+		return errors.New("csv keyColumn is empty")
+	}
+	if !isPlaceholder(c.Key) {
+		return errors.Errorf("csv key '%s' is not a credentialSubject placeholder", c.Key)
+	}
+	if utf8.RuneCountInString(c.Delimiter) > 1 {
+		return errors.Errorf("csv delimiter '%s' is not a single character", c.Delimiter)
+	}
+	return nil
+}
+
+// decodeCSVResponse maps the first row whose key column equals the key
+// credential subject field. The response schema properties are column names.
+// Rows are read one at a time, so large files are not held in memory.
+func (fh *FlexibleHTTP) decodeCSVResponse(r io.Reader, credentialSubject map[string]interface{}) (map[string]interface{}, error) {
+	settings := fh.ResponseSchema.CSV
+	if err := settings.validate(); err != nil {
+		return nil, errors.Wrap(ErrInvalidResponseSchema, err.Error())
+	}
+	key, err := findPlaceholderValue(settings.Key, credentialSubject)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidRequestSchema, err.Error())
+	}
+
+	reader := csv.NewReader(r)
+	if settings.Delimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(settings.Delimiter)
+	}
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrapf(ErrDataProviderIssue, "failed to read csv header: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	keyIndex, ok := columns[settings.KeyColumn]
+	if !ok {
+		return nil, errors.Wrapf(ErrInvalidResponseSchema, "not found key column '%s'", settings.KeyColumn)
+	}
+
+	want := fmt.Sprintf("%v", key)
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.Wrapf(ErrInvalidResponseSchema, "no row with '%s' = '%s'", settings.KeyColumn, want)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(ErrDataProviderIssue, "failed to read csv: %v", err)
+		}
+		if strings.TrimSpace(row[keyIndex]) != want {
+			continue
+		}
+		parsedFields := make(map[string]interface{}, len(fh.ResponseSchema.Properties))
+		for column, field := range fh.ResponseSchema.Properties {
+			p := strings.Split(field.MatchTo, ".")
+			if len(p) != 2 {
+				return nil, errors.Wrapf(ErrInvalidResponseSchema, "invalid match field for '%s'", column)
+			}
+			i, ok := columns[column]
+			if !ok {
+				return nil, errors.Wrapf(ErrInvalidResponseSchema, "not found column '%s'", column)
+			}
+			parsedFields[p[1]], err = stringToType(strings.TrimSpace(row[i]), field.Type)
+			if err != nil {
+				return nil, errors.Wrapf(ErrInvalidResponseSchema, "column '%s': %v", column, err)
+			}
+		}
+		return parsedFields, nil
+	}
+}
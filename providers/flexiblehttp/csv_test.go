@@ -0,0 +1,65 @@
+package flexiblehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvide_CSV(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		_, _ = w.Write([]byte("\ufeffaddress;balance;verified\n0x1;10;true\n0x2; 20 ;false\n"))
+	}))
+	defer srv.Close()
+
+	provider := FlexibleHTTP{
+		httpcli:  srv.Client(),
+		Provider: provider{URL: srv.URL, Method: http.MethodGet},
+		ResponseSchema: responseSchema{
+			Type: responseTypeCSV,
+			CSV:  csvSettings{Delimiter: ";", KeyColumn: "address", Key: "{{ credentialSubject.address }}"},
+			Properties: map[string]matchedField{
+				"balance":  {Type: "integer", MatchTo: "credentialSubject.balance"},
+				"verified": {Type: "boolean", MatchTo: "credentialSubject.verified"},
+			},
+		},
+	}
+	require.Empty(t, provider.Validate())
+
+	tests := []struct {
+		name     string
+		address  string
+		expected map[string]interface{}
+	}{
+		{
+			name:     "First row",
+			address:  "0x1",
+			expected: map[string]interface{}{"balance": 10, "verified": true},
+		},
+		{
+			name:     "Trimmed values",
+			address:  "0x2",
+			expected: map[string]interface{}{"balance": 20, "verified": false},
+		},
+		{
+			name:    "No row",
+			address: "0x3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updatedFields, err := provider.Provide(context.Background(), map[string]interface{}{"address": tt.address})
+			if tt.expected == nil {
+				require.ErrorIs(t, err, ErrInvalidResponseSchema)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, updatedFields)
+		})
+	}
+}
@@ -1,42 +1,129 @@
 package flexiblehttp
 
 import (
+	"context"
+	"fmt"
 	"net/http"
-	"os"
+	"net/url"
+	"slices"
+	"sort"
 
+	"github.com/0xPolygonID/refresh-service/providercache"
+	"github.com/0xPolygonID/refresh-service/secrets"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v3"
 )
 
 type FactoryFlexibleHTTP struct {
-	configuration map[string]FlexibleHTTP
+	configuration *registry
+	configPath    string
 	httpcli       *http.Client
+	secrets       *secrets.Store
+	cache         providercache.Cache
+	sampler       Sampler
 }
 
-func NewFactoryFlexibleHTTP(configPath string, httpcli *http.Client) (FactoryFlexibleHTTP, error) {
-	//nolint:gosec // configPath is a constant path in the project
-	f, err := os.ReadFile(configPath)
-	if err != nil {
-		return FactoryFlexibleHTTP{}, err
-	}
+func NewFactoryFlexibleHTTP(configPath string, httpcli *http.Client, opts ...FactoryOption) (FactoryFlexibleHTTP, error) {
 	if httpcli == nil {
 		httpcli = http.DefaultClient
 	}
-	cfgs := make(map[string]FlexibleHTTP)
-	if err := yaml.Unmarshal(f, &cfgs); err != nil {
+	cfgs, err := readConfiguration(configPath, httpcli)
+	if err != nil {
 		return FactoryFlexibleHTTP{}, err
 	}
-	return FactoryFlexibleHTTP{
-		configuration: cfgs,
+	factory := FactoryFlexibleHTTP{
+		configuration: newRegistry(cfgs),
+		configPath:    configPath,
 		httpcli:       httpcli,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(&factory)
+	}
+	return factory, nil
 }
 
 func (factory *FactoryFlexibleHTTP) ProduceFlexibleHTTP(credentialType string) (FlexibleHTTP, error) {
-	fh, ok := factory.configuration[credentialType]
+	fh, ok := factory.configuration.load()[credentialType]
 	if !ok {
 		return FlexibleHTTP{}, errors.Errorf("not found configuration for '%s'", credentialType)
 	}
-	fh.httpcli = factory.httpcli
+	fh.httpcli = fh.client(factory.httpcli)
+	fh.secrets = factory.secrets
+	fh.cache = factory.cache
+	fh.sampler, fh.source = factory.sampler, factory
+	fh.credentialType = credentialType
+	if len(fh.Sources) != 0 {
+		// the composed provider caches the merged fields
+		fh.Sources = slices.Clone(fh.Sources)
+		for i := range fh.Sources {
+			fh.Sources[i].httpcli = fh.Sources[i].client(factory.httpcli)
+			fh.Sources[i].secrets = factory.secrets
+			fh.Sources[i].credentialType = credentialType
+		}
+	}
 	return fh, nil
 }
+
+func (factory *FactoryFlexibleHTTP) CredentialTypes() []string {
+	configs := factory.configuration.load()
+	types := make([]string, 0, len(configs))
+	for t := range configs {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Ping checks that the data provider host for credentialType is reachable.
+// Any HTTP response counts as reachable since provider URLs are templates.
+func (factory *FactoryFlexibleHTTP) Ping(ctx context.Context, credentialType string) error {
+	fh, err := factory.ProduceFlexibleHTTP(credentialType)
+	if err != nil {
+		return err
+	}
+	if err := ping(ctx, fh.httpcli, fh.Provider.URL); err != nil {
+		return err
+	}
+	for _, source := range fh.Sources {
+		if err := ping(ctx, source.httpcli, source.Provider.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ping(ctx context.Context, httpcli *http.Client, providerURL string) error {
+	if providerURL == "" {
+		return nil
+	}
+	u, err := url.Parse(providerURL)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidRequestSchema, "invalid provider url: %v", err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead,
+		fmt.Sprintf("%s://%s/", u.Scheme, u.Host), http.NoBody)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidRequestSchema, "failed to create http request: %v", err)
+	}
+	resp, err := httpcli.Do(request)
+	if err != nil {
+		return errors.Wrapf(ErrDataProviderIssue, "failed http request: %v", err)
+	}
+	return resp.Body.Close()
+}
+
+// FlushCache removes the cached fields of every configured credential type
+// and returns how many entries were removed.
+func (factory *FactoryFlexibleHTTP) FlushCache(ctx context.Context) (int64, error) {
+	if factory.cache == nil {
+		return 0, nil
+	}
+	var total int64
+	for _, credentialType := range factory.CredentialTypes() {
+		n, err := factory.cache.Invalidate(ctx, credentialType, "")
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
@@ -0,0 +1,112 @@
+package flexiblehttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSignatureHeader = "X-Signature"
+	defaultTimestampHeader = "X-Timestamp"
+)
+
+// hmacSettings signs provider requests with a shared secret. The signature
+// is the hex HMAC of '<unix timestamp>.<hex body digest>'.
+type hmacSettings struct {
+	Secret          string `yaml:"secret"`
+	Algorithm       string `yaml:"algorithm"`
+	Header          string `yaml:"header"`
+	TimestampHeader string `yaml:"timestampHeader"`
+	// ClockSkew is the tolerated difference to the provider clock. A signed
+	// request rejected by a provider whose Date header is further off is
+	// signed once more with the provider time.
+	ClockSkew time.Duration `yaml:"clockSkew"`
+}
+
+func (s hmacSettings) enabled() bool {
+	return s.Secret != ""
+}
+
+func (s hmacSettings) validate() error {
+	if s.Secret == "" && (s.Algorithm != "" || s.Header != "" || s.TimestampHeader != "" || s.ClockSkew != 0) {
+		return errors.New("hmac secret is empty")
+	}
+	if _, err := s.hash(); err != nil {
+		return err
+	}
+	if s.ClockSkew < 0 {
+		return errors.New("hmac clockSkew is negative")
+	}
+	return nil
+}
+
+func (s hmacSettings) hash() (func() hash.Hash, error) {
+	switch s.Algorithm {
+	case "", "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, errors.Errorf("unsupported hmac algorithm '%s'", s.Algorithm)
+	}
+}
+
+// sign sets the timestamp and signature headers of request.
+func (s hmacSettings) sign(request *http.Request, secret string, signedAt time.Time) error {
+	newHash, err := s.hash()
+	if err != nil {
+		return err
+	}
+	digest := newHash()
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(digest, body)
+		_ = body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	mac := hmac.New(newHash, []byte(secret))
+	_, _ = mac.Write([]byte(timestamp + "." + hex.EncodeToString(digest.Sum(nil))))
+
+	timestampHeader, signatureHeader := s.TimestampHeader, s.Header
+	if timestampHeader == "" {
+		timestampHeader = defaultTimestampHeader
+	}
+	if signatureHeader == "" {
+		signatureHeader = defaultSignatureHeader
+	}
+	request.Header.Set(timestampHeader, timestamp)
+	request.Header.Set(signatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// providerTime returns the provider clock from the Date header of a
+// rejected response when it differs from ours by more than ClockSkew.
+func (s hmacSettings) providerTime(resp *http.Response) (time.Time, bool) {
+	if s.ClockSkew <= 0 {
+		return time.Time{}, false
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	if offset := time.Until(date); offset > s.ClockSkew || offset < -s.ClockSkew {
+		return date, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,75 @@
+package flexiblehttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvide_HMAC(t *testing.T) {
+	// the provider clock is an hour ahead and allows a minute of skew
+	providerNow := time.Now().Add(time.Hour)
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Date", providerNow.UTC().Format(http.TimeFormat))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		timestamp := r.Header.Get("X-Request-Time")
+		digest := sha256.Sum256(body)
+		mac := hmac.New(sha256.New, []byte("shared"))
+		_, _ = mac.Write([]byte(timestamp + "." + hex.EncodeToString(digest[:])))
+		if r.Header.Get("X-Signature") != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		require.NoError(t, err)
+		if d := providerNow.Sub(time.Unix(unix, 0)); d > time.Minute || d < -time.Minute {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"result": "100"}`))
+	}))
+	defer srv.Close()
+
+	store := secrets.NewStore(time.Minute)
+	store.Update(map[string]string{"PROVIDER_HMAC": "shared"})
+
+	fh := FlexibleHTTP{
+		httpcli:  srv.Client(),
+		secrets:  store,
+		Provider: provider{URL: srv.URL, Method: http.MethodPost},
+		RequestSchema: requestSchema{
+			Body: `{"address": "{{ credentialSubject.address }}"}`,
+			HMAC: hmacSettings{
+				Secret:          "{{ secrets.PROVIDER_HMAC }}",
+				TimestampHeader: "X-Request-Time",
+			},
+		},
+		ResponseSchema: responseSchema{Properties: map[string]matchedField{
+			"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+		}},
+	}
+	subject := map[string]interface{}{"address": "0x1"}
+
+	_, err := fh.Provide(context.Background(), subject)
+	require.ErrorIs(t, err, ErrDataProviderIssue)
+	require.Equal(t, 1, calls)
+
+	fh.RequestSchema.HMAC.ClockSkew = 5 * time.Minute
+	fields, err := fh.Provide(context.Background(), subject)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"balance": "100"}, fields)
+	require.Equal(t, 3, calls)
+}
@@ -1,7 +1,8 @@
 package flexiblehttp
 
 import (
-	"fmt"
+	"context"
+	"io"
 	"math/big"
 	"net/http"
 	"net/url"
@@ -9,8 +10,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/0xPolygonID/refresh-service/codec"
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/providercache"
+	"github.com/0xPolygonID/refresh-service/secrets"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -21,21 +26,59 @@ var (
 
 type settings struct {
 	TimeExpiration time.Duration `yaml:"timeExpiration"`
+	CacheTTL       time.Duration `yaml:"cacheTTL"`
+	CacheKey       string        `yaml:"cacheKey"`
+	// ConditionalRequests revalidates cached responses with their ETag and
+	// Last-Modified validators once cacheTTL has passed.
+	ConditionalRequests bool `yaml:"conditionalRequests"`
+	// MerklizedRootPosition and SubjectPosition pin the core claim slots of
+	// reissued credentials, 'index' or 'value'. By default the slots of the
+	// original credential are kept.
+	MerklizedRootPosition string `yaml:"merklizedRootPosition"`
+	SubjectPosition       string `yaml:"subjectPosition"`
+	// ExpirationOnly reissues credentials with their subject unchanged and
+	// only a new expiration. The data provider is not called.
+	ExpirationOnly bool `yaml:"expirationOnly"`
+	// SelectiveFields lets refresh requests name the subject fields to
+	// refresh. Only those fields are requested and merged.
+	SelectiveFields bool `yaml:"selectiveFields"`
+	// FeatureFlag names the feature flag which must be enabled for the
+	// issuer or credential to use this provider.
+	FeatureFlag string `yaml:"featureFlag"`
+	// IssuanceDate is the issuance date of reissued credentials: 'now',
+	// 'original' or 'provider'. By default the service decides.
+	IssuanceDate string `yaml:"issuanceDate"`
+	// IssuanceDateField is the subject field holding the issuance date with
+	// IssuanceDate 'provider'. It is not merged into the subject.
+	IssuanceDateField string `yaml:"issuanceDateField"`
 }
 
 type provider struct {
-	URL    string `yaml:"url"`
-	Method string `yaml:"method"`
+	URL    string      `yaml:"url"`
+	Method string      `yaml:"method"`
+	TLS    providerTLS `yaml:"tls"`
 }
 
 type requestSchema struct {
-	Params  map[string]string `yaml:"params"`
-	Headers map[string]string `yaml:"headers"`
+	Params  map[string]interface{} `yaml:"params"`
+	Headers map[string]string      `yaml:"headers"`
+	Body    string                 `yaml:"body"`
+	OData   odataSettings          `yaml:"odata"`
+	HMAC    hmacSettings           `yaml:"hmac"`
+	Auth    authSettings           `yaml:"auth"`
 }
 
+const (
+	responseTypeJSON  = "json"
+	responseTypeXML   = "xml"
+	responseTypeCSV   = "csv"
+	responseTypeOData = "odata"
+)
+
 type responseSchema struct {
 	Type       string                  `yaml:"type"`
 	Properties map[string]matchedField `yaml:"properties"`
+	CSV        csvSettings             `yaml:"csv"`
 }
 
 type matchedField struct {
@@ -45,35 +88,136 @@ type matchedField struct {
 
 type FlexibleHTTP struct {
 	httpcli        *http.Client
+	tlsClient      *http.Client
+	secrets        *secrets.Store
+	cache          providercache.Cache
+	sampler        Sampler
+	source         *FactoryFlexibleHTTP
+	credentialType string
+	templates      templates
 	Settings       settings       `yaml:"settings"`
 	Provider       provider       `yaml:"provider"`
 	RequestSchema  requestSchema  `yaml:"requestSchema"`
 	ResponseSchema responseSchema `yaml:"responseSchema"`
+	// Sources route the fields of the credential type to several providers.
+	Sources []FlexibleHTTP `yaml:"sources"`
 }
 
-func (fh *FlexibleHTTP) Provide(credentialSubject map[string]interface{}) (map[string]interface{}, error) {
-	req, err := fh.BuildRequest(credentialSubject)
+func (fh *FlexibleHTTP) Provide(ctx context.Context, credentialSubject map[string]interface{}) (map[string]interface{}, error) {
+	key, ok := fh.cacheKey(credentialSubject)
+	if fh.cache == nil || !ok {
+		entry, err := fh.provide(ctx, credentialSubject, nil)
+		if err != nil {
+			return nil, err
+		}
+		return entry.Fields, nil
+	}
+
+	fh.sample(key, credentialSubject)
+	cached, ok := fh.cached(ctx, key)
+	if ok && (cached.Pushed || fh.fresh(cached)) {
+		return cached.Fields, nil
+	}
+	var conditional *providercache.Entry
+	if ok && fh.Settings.ConditionalRequests && (cached.ETag != "" || cached.LastModified != "") {
+		conditional = &cached
+	}
+	entry, err := fh.provide(ctx, credentialSubject, conditional)
 	if err != nil {
-		return nil, errors.Wrap(ErrInvalidRequestSchema, err.Error())
+		return nil, err
 	}
+	fh.store(ctx, key, entry)
+	return entry.Fields, nil
+}
 
-	resp, err := fh.httpcli.Do(req)
+// provide calls the data provider. With conditional set, the request carries
+// its validators and a 304 response keeps its fields.
+func (fh *FlexibleHTTP) provide(
+	ctx context.Context,
+	credentialSubject map[string]interface{},
+	conditional *providercache.Entry,
+) (*providercache.Entry, error) {
+	if len(fh.Sources) != 0 {
+		return fh.provideSources(ctx, credentialSubject)
+	}
+	resp, err := fh.do(ctx, credentialSubject, false, conditional, time.Time{})
 	if err != nil {
-		return nil, errors.Wrapf(ErrDataProviderIssue,
-			"failed http request: %v", err)
+		return nil, err
+	}
+	if rejected(resp) {
+		// the provider may not have picked up a rotated secret yet
+		previous, err := fh.do(ctx, credentialSubject, true, conditional, time.Time{})
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+		if previous != nil {
+			_ = resp.Body.Close()
+			resp = previous
+		}
+	}
+	if rejected(resp) && fh.RequestSchema.HMAC.enabled() {
+		// the signature timestamp may be outside the provider window
+		if signedAt, ok := fh.RequestSchema.HMAC.providerTime(resp); ok {
+			if done, ok := httpclient.Retry(ctx); ok {
+				resigned, err := fh.do(ctx, credentialSubject, false, conditional, signedAt)
+				done()
+				_ = resp.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				resp = resigned
+			}
+		}
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
+	entry := &providercache.Entry{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		StoredAt:     time.Now().UTC(),
+	}
+	if resp.StatusCode == http.StatusNotModified && conditional != nil {
+		// no field changes since the cached response
+		entry.Fields = conditional.Fields
+		if entry.ETag == "" {
+			entry.ETag = conditional.ETag
+		}
+		if entry.LastModified == "" {
+			entry.LastModified = conditional.LastModified
+		}
+		return entry, nil
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, errors.Wrapf(ErrDataProviderIssue,
-			"unexpected status code '%d'", resp.StatusCode)
+		return nil, httpclient.Throttle(resp, errors.Wrapf(ErrDataProviderIssue,
+			"unexpected status code '%d'", resp.StatusCode))
+	}
+	entry.Fields, err = fh.decodeBody(resp.Body, credentialSubject)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (fh *FlexibleHTTP) decodeBody(body io.Reader, credentialSubject map[string]interface{}) (map[string]interface{}, error) {
+	switch fh.ResponseSchema.Type {
+	case responseTypeXML:
+		return fh.decodeXMLResponse(body)
+	case responseTypeCSV:
+		return fh.decodeCSVResponse(body, credentialSubject)
 	}
 	response := map[string]interface{}{}
-	if err := yaml.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err := codec.Decode(body, &response); err != nil {
 		return nil, errors.Wrapf(ErrDataProviderIssue, "failed to decode response: %v", err)
 	}
+	if fh.ResponseSchema.Type == responseTypeOData {
+		var err error
+		if response, err = unwrapOData(response); err != nil {
+			return nil, errors.Wrap(ErrInvalidResponseSchema, err.Error())
+		}
+	}
 
 	decodedResponse, err := fh.DecodeResponse(response)
 	if err != nil {
@@ -83,55 +227,197 @@ func (fh *FlexibleHTTP) Provide(credentialSubject map[string]interface{}) (map[s
 	return decodedResponse, nil
 }
 
-func (fh *FlexibleHTTP) BuildRequest(credentialSubject map[string]interface{}) (*http.Request, error) {
-	u, err := url.Parse(fh.Provider.URL)
+// do sends the provider request. With previousSecrets set it is a retry and
+// returns a nil response when the request does not use any rotated secret
+// or the retry budget of ctx is spent.
+func (fh *FlexibleHTTP) do(
+	ctx context.Context,
+	credentialSubject map[string]interface{},
+	previousSecrets bool,
+	conditional *providercache.Entry,
+	signedAt time.Time,
+) (*http.Response, error) {
+	if signedAt.IsZero() {
+		signedAt = time.Now()
+	}
+	newRequest := func() (*http.Request, bool, error) {
+		req, rotated, err := fh.buildRequest(credentialSubject, previousSecrets, signedAt)
+		if err != nil {
+			return nil, false, errors.Wrap(ErrInvalidRequestSchema, err.Error())
+		}
+		req = req.WithContext(ctx)
+		if conditional != nil {
+			if conditional.ETag != "" {
+				req.Header.Set("If-None-Match", conditional.ETag)
+			}
+			if conditional.LastModified != "" {
+				req.Header.Set("If-Modified-Since", conditional.LastModified)
+			}
+		}
+		correlation.SetHeader(ctx, req)
+		return req, rotated, nil
+	}
+
+	req, rotated, err := newRequest()
 	if err != nil {
 		return nil, err
 	}
-
-	urlParts := strings.Split(u.Path, "/")
-	for i, part := range urlParts {
-		if isPlaceholder(part) {
-			value, err := findPlaceholderValue(part, credentialSubject)
-			if err != nil {
-				return nil, err
-			}
-			urlParts[i] = fmt.Sprintf("%v", value)
+	if previousSecrets {
+		if !rotated {
+			return nil, nil
+		}
+		done, ok := httpclient.Retry(ctx)
+		if !ok {
+			return nil, nil
 		}
+		defer done()
+	}
+	resp, err := fh.httpcli.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(ErrDataProviderIssue,
+			"failed http request: %v", err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized || !fh.RequestSchema.Auth.digest() {
+		return resp, nil
+	}
+	challenge, ok := digestChallenge(resp)
+	if !ok {
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+
+	// answer the Digest challenge with a fresh copy of the request
+	req, _, err = newRequest()
+	if err != nil {
+		return nil, err
+	}
+	if err := fh.setDigestAuth(req, challenge, previousSecrets); err != nil {
+		return nil, errors.Wrap(ErrInvalidRequestSchema, err.Error())
+	}
+	resp, err = fh.httpcli.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(ErrDataProviderIssue,
+			"failed http request: %v", err)
+	}
+	return resp, nil
+}
+
+func (fh *FlexibleHTTP) BuildRequest(credentialSubject map[string]interface{}) (*http.Request, error) {
+	request, _, err := fh.buildRequest(credentialSubject, false, time.Now())
+	return request, err
+}
+
+func rejected(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+}
+
+func (fh *FlexibleHTTP) buildRequest(
+	credentialSubject map[string]interface{},
+	previousSecrets bool,
+	signedAt time.Time,
+) (
+	request *http.Request,
+	rotated bool,
+	err error,
+) {
+	resolve := func(v string) (string, error) {
+		resolved, r, err := fh.resolveSecrets(v, previousSecrets)
+		rotated = rotated || r
+		return resolved, err
+	}
+
+	data := templateData{
+		credentialSubject: credentialSubject,
+		secret: func(name string) (string, error) {
+			value, r, err := fh.secret(name, previousSecrets)
+			rotated = rotated || r
+			return value, err
+		},
+	}
+
+	rawURL, err := fh.fill(fh.Provider.URL, data, escapePathSegment)
+	if err != nil {
+		return nil, false, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, false, err
 	}
-	u.Path = strings.Join(urlParts, "/")
 
 	q := u.Query()
 	for argK, argV := range fh.RequestSchema.Params {
-		if isPlaceholder(argV) {
-			value, err := findPlaceholderValue(argV, credentialSubject)
-			if err != nil {
-				return nil, err
-			}
-			argV = fmt.Sprintf("%v", value)
+		if err := addParam(q, argK, argV, fh.compiled, data); err != nil {
+			return nil, false, err
 		}
-		q.Add(argK, argV)
 	}
 	u.RawQuery = q.Encode()
+	if fh.ResponseSchema.Type == responseTypeOData {
+		options, err := fh.odataQuery(data)
+		if err != nil {
+			return nil, false, err
+		}
+		if u.RawQuery != "" {
+			options = u.RawQuery + "&" + options
+		}
+		u.RawQuery = options
+	}
+
+	body, err := fh.requestBody(data)
+	if err != nil {
+		return nil, false, err
+	}
 
-	request, err := http.NewRequest(
+	request, err = http.NewRequest(
 		fh.Provider.Method,
 		u.String(),
-		http.NoBody,
+		body,
 	)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	for headerK, headerV := range fh.RequestSchema.Headers {
+		headerV, err = fh.fill(headerV, data, verbatim)
+		if err != nil {
+			return nil, false, err
+		}
 		request.Header.Add(headerK, headerV)
 	}
+	if fh.ResponseSchema.Type == responseTypeOData && request.Header.Get("Accept") == "" {
+		request.Header.Set("Accept", "application/json")
+	}
+	if auth := fh.RequestSchema.Auth; auth.Scheme != "" {
+		username, err := resolve(auth.Username)
+		if err != nil {
+			return nil, false, err
+		}
+		password, err := resolve(auth.Password)
+		if err != nil {
+			return nil, false, err
+		}
+		// Digest credentials are sent in answer to the provider challenge
+		if !auth.digest() {
+			request.SetBasicAuth(username, password)
+		}
+	}
+	if fh.RequestSchema.HMAC.enabled() {
+		secret, err := resolve(fh.RequestSchema.HMAC.Secret)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := fh.RequestSchema.HMAC.sign(request, secret, signedAt); err != nil {
+			return nil, false, err
+		}
+	}
 
-	return request, nil
+	return request, rotated, nil
 }
 
 func (fh *FlexibleHTTP) DecodeResponse(response map[string]interface{}) (map[string]interface{}, error) {
 	parsedFields := make(map[string]interface{})
+	root := response
 	for propertyKey, propertyValue := range fh.ResponseSchema.Properties {
+		// every property is resolved from the top of the response
+		response := root
 		parts := strings.Split(propertyKey, ".")
 		for i, part := range parts {
 			tragetKey, targetIndex := processKey(part)
@@ -2,12 +2,18 @@ package flexiblehttp
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/0xPolygonID/refresh-service/secrets"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestNewBuilder(t *testing.T) {
@@ -147,6 +153,127 @@ func TestDecodeResponse(t *testing.T) {
 	}
 }
 
+func TestDecodeResponse_NestedProperties(t *testing.T) {
+	provider := &FlexibleHTTP{
+		ResponseSchema: responseSchema{
+			Properties: map[string]matchedField{
+				"wallet.balance":  {Type: "string", MatchTo: "credentialSubject.balance"},
+				"wallet.currency": {Type: "string", MatchTo: "credentialSubject.currency"},
+				"account":         {Type: "string", MatchTo: "credentialSubject.account"},
+			},
+		},
+	}
+	response := map[string]interface{}{
+		"account": "0x1",
+		"wallet": map[string]interface{}{
+			"balance":  "1200145884000",
+			"currency": "ETH",
+		},
+	}
+	// properties are resolved in any order, each from the top of the
+	// response
+	for i := 0; i < 10; i++ {
+		updatedFields, err := provider.DecodeResponse(response)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"account":  "0x1",
+			"balance":  "1200145884000",
+			"currency": "ETH",
+		}, updatedFields)
+	}
+}
+
+// TestDecodeBody_SameAsYAML checks that responses decoded with the JSON codec
+// give the same fields as with the YAML decoder responses were decoded with
+// before, for the provider configurations of the test vectors and the value
+// types of response schemas.
+func TestDecodeBody_SameAsYAML(t *testing.T) {
+	factory, err := NewFactoryFlexibleHTTP("./testvectors/balance.yaml", nil)
+	require.NoError(t, err)
+	vector := func(credentialType string) *FlexibleHTTP {
+		provider, err := factory.ProduceFlexibleHTTP(
+			"https://raw.githubusercontent.com/iden3/claim-schema-vocab/main/schemas/json-ld/balance.json-ld#" + credentialType)
+		require.NoError(t, err)
+		return &provider
+	}
+	typed := &FlexibleHTTP{
+		ResponseSchema: responseSchema{
+			Type: responseTypeJSON,
+			Properties: map[string]matchedField{
+				"name":          {Type: "string", MatchTo: "credentialSubject.name"},
+				"verified":      {Type: "boolean", MatchTo: "credentialSubject.verified"},
+				"score":         {Type: "float", MatchTo: "credentialSubject.score"},
+				"level":         {Type: "integer", MatchTo: "credentialSubject.level"},
+				"data.tags[1]":  {Type: "string", MatchTo: "credentialSubject.tag"},
+				"data.verified": {Type: "string", MatchTo: "credentialSubject.verifiedText"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		provider *FlexibleHTTP
+		body     string
+	}{
+		{
+			name:     "One level response",
+			provider: vector("Balance"),
+			body:     `{"status": "1", "message": "OK", "result": "1200145884000"}`,
+		},
+		{
+			name:     "Embeded json in response",
+			provider: vector("DeepEmbeded"),
+			body:     `{"wallet": {"eth": {"balance": "1200145884000"}}}`,
+		},
+		{
+			name:     "Embeded array of objects in response",
+			provider: vector("EmbededArray"),
+			body:     `{"wallet": {"eth": [{"balance": "1200145884000"}, {"balance": "0"}]}}`,
+		},
+		{
+			name:     "Embeded array of values in response",
+			provider: vector("EmbededValuesArray"),
+			body:     `{"wallet": {"eth": ["1200145884000"]}}`,
+		},
+		{
+			name:     "Value types",
+			provider: typed,
+			body: `{"name": "Jos\u00e9 \"J\" Müller", "verified": true, "score": 97.5, "level": "3",
+				"data": {"tags": ["a", "b/c"], "verified": false}, "extra": null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response map[string]interface{}
+			require.NoError(t, yaml.Unmarshal([]byte(tt.body), &response))
+			expected, err := tt.provider.DecodeResponse(response)
+			require.NoError(t, err)
+
+			fields, err := tt.provider.decodeBody(bytes.NewReader([]byte(tt.body)), nil)
+			require.NoError(t, err)
+			require.Equal(t, expected, fields)
+		})
+	}
+
+	// YAML decoded integral numbers as int, which no response schema type
+	// accepted; JSON decodes every number as float64.
+	level := &FlexibleHTTP{ResponseSchema: responseSchema{
+		Type:       responseTypeJSON,
+		Properties: map[string]matchedField{"level": {Type: "integer", MatchTo: "credentialSubject.level"}},
+	}}
+	var response map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(`{"level": 3}`), &response))
+	_, err = level.DecodeResponse(response)
+	require.EqualError(t, err, "invalid type 'int' from JSON response")
+	fields, err := level.decodeBody(bytes.NewReader([]byte(`{"level": 3}`)), nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"level": 3}, fields)
+
+	// bodies which are YAML but not JSON are no longer accepted
+	_, err = vector("Balance").decodeBody(bytes.NewReader([]byte("result: '1200145884000'")), nil)
+	require.ErrorIs(t, err, ErrDataProviderIssue)
+}
+
 func TestCastToType(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -271,3 +398,63 @@ func TestCastToType_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestProvide_ForwardsRequestID(t *testing.T) {
+	var receivedID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedID = r.Header.Get(middleware.RequestIDHeader)
+		_, _ = w.Write([]byte(`{"result": "100"}`))
+	}))
+	defer srv.Close()
+
+	provider := FlexibleHTTP{
+		httpcli:  srv.Client(),
+		Provider: provider{URL: srv.URL, Method: http.MethodGet},
+		ResponseSchema: responseSchema{
+			Properties: map[string]matchedField{
+				"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+			},
+		},
+	}
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-42")
+	updatedFields, err := provider.Provide(ctx, map[string]interface{}{})
+	require.NoError(t, err)
+	require.Equal(t, "req-42", receivedID)
+	require.Equal(t, map[string]interface{}{"balance": "100"}, updatedFields)
+}
+
+func TestProvide_RotatedSecret(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer old" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"result": "100"}`))
+	}))
+	defer srv.Close()
+
+	store := secrets.NewStore(time.Minute)
+	store.Update(map[string]string{"PROVIDER_TOKEN": "old"})
+	store.Update(map[string]string{"PROVIDER_TOKEN": "new"})
+
+	provider := FlexibleHTTP{
+		httpcli:  srv.Client(),
+		secrets:  store,
+		Provider: provider{URL: srv.URL, Method: http.MethodGet},
+		RequestSchema: requestSchema{
+			Headers: map[string]string{"Authorization": "Bearer {{ secrets.PROVIDER_TOKEN }}"},
+		},
+		ResponseSchema: responseSchema{
+			Properties: map[string]matchedField{
+				"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+			},
+		},
+	}
+	updatedFields, err := provider.Provide(context.Background(), map[string]interface{}{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"balance": "100"}, updatedFields)
+
+	req, err := provider.BuildRequest(map[string]interface{}{})
+	require.NoError(t, err)
+	require.Equal(t, "Bearer new", req.Header.Get("Authorization"))
+}
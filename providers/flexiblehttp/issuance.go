@@ -0,0 +1,40 @@
+package flexiblehttp
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Issuance dates of reissued credentials, see settings.IssuanceDate.
+const (
+	// IssuanceDateNow issues the credential at the time of the refresh.
+	IssuanceDateNow = "now"
+	// IssuanceDateOriginal keeps the issuance date of the original
+	// credential.
+	IssuanceDateOriginal = "original"
+	// IssuanceDateProvider takes the issuance date from the subject field
+	// of the provider response named by settings.IssuanceDateField.
+	IssuanceDateProvider = "provider"
+)
+
+func (s settings) validateIssuanceDate(mapped map[string]bool) error {
+	switch s.IssuanceDate {
+	case "", IssuanceDateNow, IssuanceDateOriginal:
+		if s.IssuanceDateField != "" {
+			return errors.Errorf("issuance date field '%s' is only used with issuance date '%s'",
+				s.IssuanceDateField, IssuanceDateProvider)
+		}
+	case IssuanceDateProvider:
+		if s.ExpirationOnly {
+			return errors.Errorf("issuance date '%s' needs a provider, the type is renewed without one",
+				IssuanceDateProvider)
+		}
+		if !mapped[s.IssuanceDateField] {
+			return errors.Errorf("issuance date field '%s' is not a credentialSubject field the provider matches",
+				s.IssuanceDateField)
+		}
+	default:
+		return errors.Errorf("issuance date '%s' is not '%s', '%s' or '%s'",
+			s.IssuanceDate, IssuanceDateNow, IssuanceDateOriginal, IssuanceDateProvider)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package flexiblehttp
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type odataSettings struct {
+	Filter string   `yaml:"filter"`
+	Select []string `yaml:"select"`
+}
+
+// odataQuery builds the $filter, $select and $top system query options.
+// Subject values are quoted as OData string literals, and by default only
+// the response properties are selected.
+func (fh *FlexibleHTTP) odataQuery(data templateData) (string, error) {
+	settings := fh.RequestSchema.OData
+	var options []string
+	if settings.Filter != "" {
+		filter, err := fh.fill(settings.Filter, data, func(s string) string {
+			return strings.ReplaceAll(s, "'", "''")
+		})
+		if err != nil {
+			return "", err
+		}
+		options = append(options, "$filter="+odataEscape(filter))
+	}
+	selected := settings.Select
+	if len(selected) == 0 {
+		selected = fh.odataProperties()
+	}
+	if len(selected) > 0 {
+		options = append(options, "$select="+odataEscape(strings.Join(selected, ",")))
+	}
+	// one entity is mapped, there is no need to read more
+	options = append(options, "$top=1")
+	return strings.Join(options, "&"), nil
+}
+
+// odataEscape encodes a system query option value. Spaces are encoded as
+// %20, some OData servers don't decode '+' in $filter.
+func odataEscape(v string) string {
+	return strings.ReplaceAll(url.QueryEscape(v), "+", "%20")
+}
+
+// odataProperties returns the entity properties the response schema reads.
+func (fh *FlexibleHTTP) odataProperties() []string {
+	set := make(map[string]bool, len(fh.ResponseSchema.Properties))
+	for key := range fh.ResponseSchema.Properties {
+		name, _ := processKey(strings.Split(key, ".")[0])
+		set[name] = true
+	}
+	properties := make([]string, 0, len(set))
+	for name := range set {
+		properties = append(properties, name)
+	}
+	sort.Strings(properties)
+	return properties
+}
+
+// unwrapOData returns the first entity of an OData v4 ('value') or v2
+// ('d.results' or 'd') response envelope.
+func unwrapOData(response map[string]interface{}) (map[string]interface{}, error) {
+	envelope := response
+	if d, ok := response["d"].(map[string]interface{}); ok {
+		if _, ok := d["results"]; !ok {
+			return d, nil
+		}
+		envelope = map[string]interface{}{"value": d["results"]}
+	}
+	value, ok := envelope["value"]
+	if !ok {
+		// a single entity addressed by key
+		return response, nil
+	}
+	entities, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("odata value is not a collection")
+	}
+	if len(entities) == 0 {
+		return nil, errors.New("odata response has no entities")
+	}
+	entity, ok := entities[0].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("odata entity is not an object")
+	}
+	return entity, nil
+}
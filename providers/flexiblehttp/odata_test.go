@@ -0,0 +1,79 @@
+package flexiblehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvide_OData(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{
+			name:     "OData v4",
+			response: `{"@odata.context": "$metadata#Accounts", "value": [{"Balance": 10, "Owner": {"Name": "O'Brien"}}]}`,
+		},
+		{
+			name:     "OData v2 collection",
+			response: `{"d": {"results": [{"Balance": 10, "Owner": {"Name": "O'Brien"}}]}}`,
+		},
+		{
+			name:     "OData v2 entity",
+			response: `{"d": {"Balance": 10, "Owner": {"Name": "O'Brien"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query, accept string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query, accept = r.URL.RawQuery, r.Header.Get("Accept")
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			provider := FlexibleHTTP{
+				httpcli:  srv.Client(),
+				Provider: provider{URL: srv.URL + "/Accounts", Method: http.MethodGet},
+				RequestSchema: requestSchema{
+					OData: odataSettings{Filter: "Owner/Name eq '{{ credentialSubject.owner }}'"},
+				},
+				ResponseSchema: responseSchema{
+					Type: responseTypeOData,
+					Properties: map[string]matchedField{
+						"Balance":    {Type: "integer", MatchTo: "credentialSubject.balance"},
+						"Owner.Name": {Type: "string", MatchTo: "credentialSubject.owner"},
+					},
+				},
+			}
+			updatedFields, err := provider.Provide(context.Background(), map[string]interface{}{"owner": "O'Brien & Co"})
+			require.NoError(t, err)
+			require.Equal(t, map[string]interface{}{"balance": 10, "owner": "O'Brien"}, updatedFields)
+			require.Equal(t, "$filter=Owner%2FName%20eq%20%27O%27%27Brien%20%26%20Co%27&$select=Balance%2COwner&$top=1", query)
+			require.Equal(t, "application/json", accept)
+		})
+	}
+}
+
+func TestProvide_ODataNoEntities(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"value": []}`))
+	}))
+	defer srv.Close()
+
+	provider := FlexibleHTTP{
+		httpcli:  srv.Client(),
+		Provider: provider{URL: srv.URL, Method: http.MethodGet},
+		ResponseSchema: responseSchema{
+			Type:       responseTypeOData,
+			Properties: map[string]matchedField{"Balance": {Type: "integer", MatchTo: "credentialSubject.balance"}},
+		},
+	}
+	_, err := provider.Provide(context.Background(), map[string]interface{}{})
+	require.ErrorIs(t, err, ErrInvalidResponseSchema)
+}
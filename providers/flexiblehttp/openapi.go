@@ -0,0 +1,665 @@
+package flexiblehttp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// maxScaffoldDepth bounds how deep response objects are mapped.
+const maxScaffoldDepth = 6
+
+var (
+	openAPIPathParameter = regexp.MustCompile(`\{([^{}]+)\}`)
+	invalidFieldChars    = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// OpenAPIOptions select the operation of an OpenAPI document a provider
+// configuration is scaffolded for.
+type OpenAPIOptions struct {
+	// CredentialType is the key of the configuration.
+	CredentialType string
+	// Path and Method select the operation. They may be left empty when
+	// the document has a single GET operation.
+	Path   string
+	Method string
+	// Server is the base URL of the provider, the first server of the
+	// document by default.
+	Server string
+}
+
+type openAPIDocument struct {
+	OpenAPI string `yaml:"openapi"`
+	Info    struct {
+		Title   string `yaml:"title"`
+		Version string `yaml:"version"`
+	} `yaml:"info"`
+	Servers []struct {
+		URL       string `yaml:"url"`
+		Variables map[string]struct {
+			Default string `yaml:"default"`
+		} `yaml:"variables"`
+	} `yaml:"servers"`
+	Paths      map[string]openAPIPathItem `yaml:"paths"`
+	Security   []map[string][]string      `yaml:"security"`
+	Components struct {
+		Schemas         map[string]*openAPISchema        `yaml:"schemas"`
+		Parameters      map[string]openAPIParameter      `yaml:"parameters"`
+		Responses       map[string]openAPIResponse       `yaml:"responses"`
+		SecuritySchemes map[string]openAPISecurityScheme `yaml:"securitySchemes"`
+		RequestBodies   map[string]openAPIRequestBody    `yaml:"requestBodies"`
+	} `yaml:"components"`
+}
+
+type openAPIPathItem struct {
+	Parameters []openAPIParameter `yaml:"parameters"`
+	Get        *openAPIOperation  `yaml:"get"`
+	Post       *openAPIOperation  `yaml:"post"`
+	Put        *openAPIOperation  `yaml:"put"`
+}
+
+type openAPIOperation struct {
+	Parameters  []openAPIParameter         `yaml:"parameters"`
+	RequestBody *openAPIRequestBody        `yaml:"requestBody"`
+	Responses   map[string]openAPIResponse `yaml:"responses"`
+	Security    *[]map[string][]string     `yaml:"security"`
+}
+
+type openAPIParameter struct {
+	Ref      string         `yaml:"$ref"`
+	Name     string         `yaml:"name"`
+	In       string         `yaml:"in"`
+	Required bool           `yaml:"required"`
+	Schema   *openAPISchema `yaml:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Ref     string                      `yaml:"$ref"`
+	Content map[string]openAPIMediaType `yaml:"content"`
+}
+
+type openAPIResponse struct {
+	Ref     string                      `yaml:"$ref"`
+	Content map[string]openAPIMediaType `yaml:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `yaml:"schema"`
+}
+
+type openAPISecurityScheme struct {
+	Type   string `yaml:"type"`
+	Scheme string `yaml:"scheme"`
+	In     string `yaml:"in"`
+	Name   string `yaml:"name"`
+}
+
+type openAPISchema struct {
+	Ref        string                    `yaml:"$ref"`
+	Type       openAPIType               `yaml:"type"`
+	Properties map[string]*openAPISchema `yaml:"properties"`
+	Required   []string                  `yaml:"required"`
+	Items      *openAPISchema            `yaml:"items"`
+	AllOf      []*openAPISchema          `yaml:"allOf"`
+	Default    interface{}               `yaml:"default"`
+}
+
+// openAPIType is the type of a schema, a list of types in OpenAPI 3.1.
+type openAPIType string
+
+func (t *openAPIType) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = openAPIType(node.Value)
+		return nil
+	}
+	var types []string
+	if err := node.Decode(&types); err != nil {
+		return err
+	}
+	for _, v := range types {
+		if v != "null" {
+			*t = openAPIType(v)
+			return nil
+		}
+	}
+	return nil
+}
+
+// ScaffoldOpenAPI generates a draft provider configuration for an operation
+// of an OpenAPI 3 document, in JSON or YAML. Path parameters and required
+// query parameters become credentialSubject placeholders, security schemes
+// secret placeholders and the fields of the JSON response properties
+// matched to credentialSubject fields of the same name. The draft is meant
+// to be reviewed: matches, types and placeholders are guesses.
+func ScaffoldOpenAPI(spec []byte, opts OpenAPIOptions) ([]byte, error) {
+	var doc openAPIDocument
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		return nil, errors.Errorf("failed to parse OpenAPI document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, errors.Errorf("unsupported OpenAPI version '%s', only OpenAPI 3 documents are supported", doc.OpenAPI)
+	}
+	if opts.CredentialType == "" {
+		return nil, errors.New("credential type is required")
+	}
+	path, method, item, operation, err := doc.operation(opts.Path, opts.Method)
+	if err != nil {
+		return nil, err
+	}
+	server, err := doc.server(opts.Server)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &scaffold{doc: &doc, fields: map[string]bool{}}
+	providerURL, err := s.url(server, path)
+	if err != nil {
+		return nil, err
+	}
+	parameters := append(append([]openAPIParameter{}, item.Parameters...), operation.Parameters...)
+	params := map[string]interface{}{}
+	headers := map[string]string{}
+	for _, p := range parameters {
+		p, err := doc.parameter(p)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case p.In == "query" && p.Schema != nil && p.Schema.Default != nil:
+			params[p.Name] = p.Schema.Default
+		case p.In == "query" && p.Required:
+			params[p.Name] = subjectPlaceholder(s.input(p.Name))
+		case p.In == "header" && p.Required:
+			s.notes = append(s.notes, fmt.Sprintf("header '%s' is required, set a constant or a secret", p.Name))
+		case p.In == "query" || p.In == "header":
+			s.skipped = append(s.skipped, p.Name)
+		}
+	}
+	auth, err := s.security(operation, params, headers)
+	if err != nil {
+		return nil, err
+	}
+	body, err := s.body(operation, headers)
+	if err != nil {
+		return nil, err
+	}
+	properties, err := s.response(operation)
+	if err != nil {
+		return nil, err
+	}
+
+	requestSchema := map[string]interface{}{}
+	if len(params) > 0 {
+		requestSchema["params"] = params
+	}
+	if len(headers) > 0 {
+		requestSchema["headers"] = headers
+	}
+	if body != "" {
+		requestSchema["body"] = body
+	}
+	if auth != nil {
+		requestSchema["auth"] = auth
+	}
+	cfg := map[string]interface{}{
+		"provider": map[string]interface{}{
+			"url":    providerURL,
+			"method": method,
+		},
+		"responseSchema": map[string]interface{}{
+			"type":       responseTypeJSON,
+			"properties": properties,
+		},
+	}
+	if len(requestSchema) > 0 {
+		cfg["requestSchema"] = requestSchema
+	}
+	out := bytes.NewBufferString(s.header(path, method))
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]interface{}{opts.CredentialType: cfg}); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// operation returns the selected operation, or the only GET operation of
+// the document.
+func (doc *openAPIDocument) operation(path, method string) (string, string, openAPIPathItem, *openAPIOperation, error) {
+	method = strings.ToUpper(method)
+	if path == "" {
+		var candidates []string
+		for p, item := range doc.Paths {
+			if item.Get != nil && (method == "" || method == http.MethodGet) {
+				candidates = append(candidates, p)
+			}
+		}
+		sort.Strings(candidates)
+		if len(candidates) != 1 {
+			return "", "", openAPIPathItem{}, nil, errors.Errorf(
+				"the document has %d GET operations, select one with its path: %v", len(candidates), candidates)
+		}
+		path = candidates[0]
+	}
+	item, ok := doc.Paths[path]
+	if !ok {
+		return "", "", openAPIPathItem{}, nil, errors.Errorf("path '%s' is not in the document", path)
+	}
+	operations := map[string]*openAPIOperation{
+		http.MethodGet:  item.Get,
+		http.MethodPost: item.Post,
+		http.MethodPut:  item.Put,
+	}
+	if method == "" {
+		method = http.MethodGet
+		if item.Get == nil && item.Post != nil {
+			method = http.MethodPost
+		}
+	}
+	operation := operations[method]
+	if operation == nil {
+		return "", "", openAPIPathItem{}, nil, errors.Errorf("path '%s' has no %s operation", path, method)
+	}
+	return path, method, item, operation, nil
+}
+
+func (doc *openAPIDocument) server(server string) (string, error) {
+	if server == "" {
+		if len(doc.Servers) == 0 {
+			return "", errors.New("the document has no servers, set the server URL")
+		}
+		server = doc.Servers[0].URL
+		for name, variable := range doc.Servers[0].Variables {
+			server = strings.ReplaceAll(server, "{"+name+"}", variable.Default)
+		}
+	}
+	if !strings.HasPrefix(server, "http://") && !strings.HasPrefix(server, "https://") {
+		return "", errors.Errorf("server '%s' is not an absolute URL, set the server URL", server)
+	}
+	return strings.TrimSuffix(server, "/"), nil
+}
+
+func (doc *openAPIDocument) parameter(p openAPIParameter) (openAPIParameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	name, err := componentName(p.Ref, "parameters")
+	if err != nil {
+		return p, err
+	}
+	resolved, ok := doc.Components.Parameters[name]
+	if !ok {
+		return p, errors.Errorf("parameter '%s' is not in the document", p.Ref)
+	}
+	return resolved, nil
+}
+
+// schema resolves the $ref and merges the allOf of s.
+func (doc *openAPIDocument) schema(s *openAPISchema, seen map[string]bool) (*openAPISchema, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if s.Ref != "" {
+		if seen[s.Ref] {
+			return nil, nil
+		}
+		name, err := componentName(s.Ref, "schemas")
+		if err != nil {
+			return nil, err
+		}
+		resolved, ok := doc.Components.Schemas[name]
+		if !ok {
+			return nil, errors.Errorf("schema '%s' is not in the document", s.Ref)
+		}
+		seen[s.Ref] = true
+		defer delete(seen, s.Ref)
+		return doc.schema(resolved, seen)
+	}
+	if len(s.AllOf) == 0 {
+		return s, nil
+	}
+	merged := &openAPISchema{Type: s.Type, Properties: map[string]*openAPISchema{}, Required: s.Required}
+	for name, property := range s.Properties {
+		merged.Properties[name] = property
+	}
+	for _, part := range s.AllOf {
+		part, err := doc.schema(part, seen)
+		if err != nil {
+			return nil, err
+		}
+		if part == nil {
+			continue
+		}
+		if merged.Type == "" {
+			merged.Type = part.Type
+		}
+		for name, property := range part.Properties {
+			merged.Properties[name] = property
+		}
+		merged.Required = append(merged.Required, part.Required...)
+	}
+	return merged, nil
+}
+
+func componentName(ref, kind string) (string, error) {
+	name, ok := strings.CutPrefix(ref, "#/components/"+kind+"/")
+	if !ok {
+		return "", errors.Errorf("unsupported reference '%s', only local components are supported", ref)
+	}
+	return name, nil
+}
+
+// jsonContent returns the schema of the JSON media type of content.
+func jsonContent(content map[string]openAPIMediaType) (*openAPISchema, bool) {
+	types := make([]string, 0, len(content))
+	for t := range content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		mediaType := strings.TrimSpace(strings.Split(t, ";")[0])
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			return content[t].Schema, true
+		}
+	}
+	return nil, false
+}
+
+type scaffold struct {
+	doc *openAPIDocument
+	// fields are the credentialSubject fields used so far, response fields
+	// don't reuse them.
+	fields  map[string]bool
+	skipped []string
+	notes   []string
+}
+
+func (s *scaffold) url(server, path string) (string, error) {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		match := openAPIPathParameter.FindStringSubmatch(segment)
+		if match == nil {
+			continue
+		}
+		if match[0] != segment {
+			return "", errors.Errorf("path parameter '%s' is not a whole path segment", match[1])
+		}
+		segments[i] = subjectPlaceholder(s.input(match[1]))
+	}
+	return server + strings.Join(segments, "/"), nil
+}
+
+// security adds the credentials of the first security requirement of the
+// operation as secret placeholders.
+func (s *scaffold) security(operation *openAPIOperation, params map[string]interface{}, headers map[string]string) (map[string]string, error) {
+	requirements := s.doc.Security
+	if operation.Security != nil {
+		requirements = *operation.Security
+	}
+	if len(requirements) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(requirements[0]))
+	for name := range requirements[0] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var auth map[string]string
+	for _, name := range names {
+		scheme, ok := s.doc.Components.SecuritySchemes[name]
+		if !ok {
+			return nil, errors.Errorf("security scheme '%s' is not in the document", name)
+		}
+		secret := "{{ secrets." + secretNameOf(name) + " }}"
+		switch {
+		case scheme.Type == "apiKey" && scheme.In == "query":
+			params[scheme.Name] = secret
+		case scheme.Type == "apiKey" && scheme.In == "header":
+			headers[scheme.Name] = secret
+		case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, authSchemeBasic),
+			scheme.Type == "http" && strings.EqualFold(scheme.Scheme, authSchemeDigest):
+			auth = map[string]string{
+				"scheme":   strings.ToLower(scheme.Scheme),
+				"username": "{{ secrets." + secretNameOf(name) + "_USERNAME }}",
+				"password": "{{ secrets." + secretNameOf(name) + "_PASSWORD }}",
+			}
+		case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"),
+			scheme.Type == "oauth2", scheme.Type == "openIdConnect":
+			headers["Authorization"] = "Bearer " + secret
+			if scheme.Type != "http" {
+				s.notes = append(s.notes, fmt.Sprintf("security scheme '%s' is %s, the token is read from a secret", name, scheme.Type))
+			}
+		default:
+			s.notes = append(s.notes, fmt.Sprintf("security scheme '%s' of type '%s' is not mapped", name, scheme.Type))
+		}
+	}
+	return auth, nil
+}
+
+// body returns a JSON body template with a placeholder for every scalar
+// property of the request body, the required ones when some are.
+func (s *scaffold) body(operation *openAPIOperation, headers map[string]string) (string, error) {
+	requestBody := operation.RequestBody
+	if requestBody == nil {
+		return "", nil
+	}
+	if requestBody.Ref != "" {
+		name, err := componentName(requestBody.Ref, "requestBodies")
+		if err != nil {
+			return "", err
+		}
+		resolved, ok := s.doc.Components.RequestBodies[name]
+		if !ok {
+			return "", errors.Errorf("request body '%s' is not in the document", requestBody.Ref)
+		}
+		requestBody = &resolved
+	}
+	schema, ok := jsonContent(requestBody.Content)
+	if !ok {
+		s.notes = append(s.notes, "the request body is not JSON, write the body template")
+		return "", nil
+	}
+	schema, err := s.doc.schema(schema, map[string]bool{})
+	if err != nil || schema == nil {
+		return "", err
+	}
+	names := schema.Required
+	if len(names) == 0 {
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var members []string
+	for _, name := range names {
+		property, err := s.doc.schema(schema.Properties[name], map[string]bool{})
+		if err != nil {
+			return "", err
+		}
+		if property == nil {
+			continue
+		}
+		value := subjectPlaceholder(s.input(name))
+		switch property.Type {
+		case "string":
+			value = `"` + value + `"`
+		case "integer", "number", "boolean":
+		default:
+			s.notes = append(s.notes, fmt.Sprintf("request body property '%s' is not a scalar, it is not mapped", name))
+			continue
+		}
+		members = append(members, fmt.Sprintf("%q: %s", name, value))
+	}
+	headers["Content-Type"] = "application/json"
+	return "{" + strings.Join(members, ", ") + "}", nil
+}
+
+// response maps the scalar properties of the JSON response of the first
+// 2xx status of the operation.
+func (s *scaffold) response(operation *openAPIOperation) (map[string]interface{}, error) {
+	statuses := make([]string, 0, len(operation.Responses))
+	for status := range operation.Responses {
+		if strings.HasPrefix(status, "2") {
+			statuses = append(statuses, status)
+		}
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		response := operation.Responses[status]
+		if response.Ref != "" {
+			name, err := componentName(response.Ref, "responses")
+			if err != nil {
+				return nil, err
+			}
+			resolved, ok := s.doc.Components.Responses[name]
+			if !ok {
+				return nil, errors.Errorf("response '%s' is not in the document", response.Ref)
+			}
+			response = resolved
+		}
+		schema, ok := jsonContent(response.Content)
+		if !ok {
+			continue
+		}
+		properties := map[string]interface{}{}
+		if err := s.mapProperties(properties, nil, schema, map[string]bool{}); err != nil {
+			return nil, err
+		}
+		if len(properties) == 0 {
+			return nil, errors.Errorf("the %s response has no scalar properties to map", status)
+		}
+		return properties, nil
+	}
+	return nil, errors.New("the operation has no JSON 2xx response")
+}
+
+func (s *scaffold) mapProperties(properties map[string]interface{}, path []string, schema *openAPISchema, seen map[string]bool) error {
+	if len(path) > maxScaffoldDepth {
+		return nil
+	}
+	schema, err := s.doc.schema(schema, seen)
+	if err != nil || schema == nil {
+		return err
+	}
+	switch schema.Type {
+	case "object", "":
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := s.mapProperties(properties, append(path[:len(path):len(path)], name), schema.Properties[name], seen); err != nil {
+				return err
+			}
+		}
+	case "array":
+		if len(path) == 0 {
+			return errors.New("the response is a list, only JSON objects can be mapped")
+		}
+		indexed := append(append([]string{}, path[:len(path)-1]...), path[len(path)-1]+"[0]")
+		return s.mapProperties(properties, indexed, schema.Items, seen)
+	case "string", "integer", "number", "boolean":
+		if len(path) == 0 {
+			return nil
+		}
+		properties[strings.Join(path, ".")] = map[string]string{
+			"type":  string(schema.Type),
+			"match": "credentialSubject." + s.field(path...),
+		}
+	}
+	return nil
+}
+
+// input returns the credentialSubject field a request parameter is filled
+// with. Parameters of the same name share it.
+func (s *scaffold) input(name string) string {
+	field := fieldName(name)
+	s.fields[field] = true
+	return field
+}
+
+// field returns a credentialSubject field name for the last of names, or
+// for more of them when it is taken already, e.g. 'ownerName'.
+func (s *scaffold) field(names ...string) string {
+	var field string
+	for i := len(names) - 1; i >= 0; i-- {
+		name := fieldName(strings.Split(names[i], "[")[0])
+		if field == "" {
+			field = name
+		} else {
+			field = name + upperFirst(field)
+		}
+		if !s.fields[field] || i == 0 {
+			break
+		}
+	}
+	for candidate, i := field, 2; ; i++ {
+		if !s.fields[candidate] {
+			s.fields[candidate] = true
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%d", field, i)
+	}
+}
+
+func (s *scaffold) header(path, method string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Draft generated from %s %s, %s %s.\n", s.doc.Info.Title, s.doc.Info.Version, method, path)
+	b.WriteString("# Review the credentialSubject matches and types, then check it with\n")
+	b.WriteString("# `refresh-service test-provider`.\n")
+	if len(s.skipped) > 0 {
+		sort.Strings(s.skipped)
+		fmt.Fprintf(&b, "# Optional parameters not mapped: %s.\n", strings.Join(s.skipped, ", "))
+	}
+	for _, note := range s.notes {
+		fmt.Fprintf(&b, "# Note: %s.\n", note)
+	}
+	return b.String()
+}
+
+func fieldName(name string) string {
+	return strings.Trim(invalidFieldChars.ReplaceAllString(name, "_"), "_")
+}
+
+func subjectPlaceholder(field string) string {
+	return "{{ credentialSubject." + field + " }}"
+}
+
+// secretNameOf turns a security scheme name into a secret name, e.g.
+// 'apiKeyAuth' into 'API_KEY_AUTH'.
+func secretNameOf(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			b.WriteByte('_')
+			b.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToUpper(r))
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,193 @@
+package flexiblehttp
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestScaffoldOpenAPI(t *testing.T) {
+	spec, err := os.ReadFile("./testvectors/openapi.yaml")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		opts               OpenAPIOptions
+		expectedURL        string
+		expectedMethod     string
+		expectedParams     map[string]interface{}
+		expectedHeaders    map[string]string
+		expectedBody       string
+		expectedProperties map[string]matchedField
+	}{
+		{
+			name:           "Only GET operation",
+			opts:           OpenAPIOptions{CredentialType: "Balance"},
+			expectedURL:    "https://eu.wallets.example.com/v1/wallets/{{ credentialSubject.address }}/balance",
+			expectedMethod: "GET",
+			expectedParams: map[string]interface{}{
+				"currency": "{{ credentialSubject.currency }}",
+				"format":   "json",
+				"apikey":   "{{ secrets.API_KEY_AUTH }}",
+			},
+			expectedProperties: map[string]matchedField{
+				"address":          {Type: "string", MatchTo: "credentialSubject.address2"},
+				"amount":           {Type: "number", MatchTo: "credentialSubject.amount"},
+				"owner.name":       {Type: "string", MatchTo: "credentialSubject.name"},
+				"owner.verified":   {Type: "boolean", MatchTo: "credentialSubject.verified"},
+				"tokens[0].symbol": {Type: "string", MatchTo: "credentialSubject.symbol"},
+			},
+		},
+		{
+			name:           "POST operation",
+			opts:           OpenAPIOptions{CredentialType: "Wallet", Path: "/wallets", Method: "post", Server: "https://sandbox.example.com/"},
+			expectedURL:    "https://sandbox.example.com/wallets",
+			expectedMethod: "POST",
+			expectedHeaders: map[string]string{
+				"Authorization": "Bearer {{ secrets.BEARER_AUTH }}",
+				"Content-Type":  "application/json",
+			},
+			expectedBody: `{"address": "{{ credentialSubject.address }}", "chainId": {{ credentialSubject.chainId }}}`,
+			expectedProperties: map[string]matchedField{
+				"address":        {Type: "string", MatchTo: "credentialSubject.address2"},
+				"owner.name":     {Type: "string", MatchTo: "credentialSubject.name"},
+				"owner.verified": {Type: "boolean", MatchTo: "credentialSubject.verified"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := ScaffoldOpenAPI(spec, tt.opts)
+			require.NoError(t, err)
+			cfgs := map[string]FlexibleHTTP{}
+			require.NoError(t, yaml.Unmarshal(out, &cfgs))
+			fh, ok := cfgs[tt.opts.CredentialType]
+			require.True(t, ok)
+			require.Empty(t, fh.Validate())
+
+			require.Equal(t, tt.expectedURL, fh.Provider.URL)
+			require.Equal(t, tt.expectedMethod, fh.Provider.Method)
+			if tt.expectedParams == nil {
+				require.Empty(t, fh.RequestSchema.Params)
+			} else {
+				require.Equal(t, tt.expectedParams, fh.RequestSchema.Params)
+			}
+			if tt.expectedHeaders == nil {
+				require.Empty(t, fh.RequestSchema.Headers)
+			} else {
+				require.Equal(t, tt.expectedHeaders, fh.RequestSchema.Headers)
+			}
+			require.Equal(t, tt.expectedBody, fh.RequestSchema.Body)
+			require.Equal(t, tt.expectedProperties, fh.ResponseSchema.Properties)
+		})
+	}
+}
+
+func TestScaffoldOpenAPI_Error(t *testing.T) {
+	spec, err := os.ReadFile("./testvectors/openapi.yaml")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		spec        string
+		opts        OpenAPIOptions
+		expectedErr string
+	}{
+		{
+			name:        "Swagger 2.0",
+			spec:        `{"swagger": "2.0", "paths": {}}`,
+			opts:        OpenAPIOptions{CredentialType: "Balance"},
+			expectedErr: "unsupported OpenAPI version '', only OpenAPI 3 documents are supported",
+		},
+		{
+			name:        "No credential type",
+			spec:        string(spec),
+			expectedErr: "credential type is required",
+		},
+		{
+			name:        "Unknown path",
+			spec:        string(spec),
+			opts:        OpenAPIOptions{CredentialType: "Balance", Path: "/users"},
+			expectedErr: "path '/users' is not in the document",
+		},
+		{
+			name:        "No operation for method",
+			spec:        string(spec),
+			opts:        OpenAPIOptions{CredentialType: "Balance", Path: "/wallets", Method: "PUT"},
+			expectedErr: "path '/wallets' has no PUT operation",
+		},
+		{
+			name: "Several GET operations",
+			spec: `openapi: 3.1.0
+servers: [{url: "https://api.example.com"}]
+paths:
+  /a: {get: {responses: {}}}
+  /b: {get: {responses: {}}}
+`,
+			opts:        OpenAPIOptions{CredentialType: "Balance"},
+			expectedErr: "the document has 2 GET operations, select one with its path: [/a /b]",
+		},
+		{
+			name: "Relative server",
+			spec: `openapi: 3.1.0
+servers: [{url: /v1}]
+paths:
+  /a: {get: {responses: {}}}
+`,
+			opts:        OpenAPIOptions{CredentialType: "Balance"},
+			expectedErr: "server '/v1' is not an absolute URL, set the server URL",
+		},
+		{
+			name: "Partial path segment",
+			spec: `openapi: 3.1.0
+servers: [{url: "https://api.example.com"}]
+paths:
+  /users/id-{id}: {get: {responses: {}}}
+`,
+			opts:        OpenAPIOptions{CredentialType: "Balance"},
+			expectedErr: "path parameter 'id' is not a whole path segment",
+		},
+		{
+			name: "List response",
+			spec: `openapi: 3.1.0
+servers: [{url: "https://api.example.com"}]
+paths:
+  /a:
+    get:
+      responses:
+        '200':
+          content:
+            application/json:
+              schema: {type: array, items: {type: string}}
+`,
+			opts:        OpenAPIOptions{CredentialType: "Balance"},
+			expectedErr: "the response is a list, only JSON objects can be mapped",
+		},
+		{
+			name: "No JSON response",
+			spec: `openapi: 3.1.0
+servers: [{url: "https://api.example.com"}]
+paths:
+  /a:
+    get:
+      responses:
+        '200':
+          content:
+            text/csv:
+              schema: {type: string}
+`,
+			opts:        OpenAPIOptions{CredentialType: "Balance"},
+			expectedErr: "the operation has no JSON 2xx response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ScaffoldOpenAPI([]byte(tt.spec), tt.opts)
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
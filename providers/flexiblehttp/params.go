@@ -0,0 +1,92 @@
+package flexiblehttp
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// addParam adds a requestSchema param to the query. Lists become repeated
+// params and maps bracketed keys, e.g. 'filter[status]'. String values are
+// constants or contain '{{ credentialSubject.field }}' placeholders; a value
+// that is only a placeholder of a list field is repeated as well.
+func addParam(
+	q url.Values,
+	key string,
+	value interface{},
+	compiled func(string) (*template, error),
+	data templateData,
+) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := addParam(q, key+"["+k+"]", v[k], compiled, data); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := addParam(q, key, item, compiled, data); err != nil {
+				return err
+			}
+		}
+	case string:
+		t, err := compiled(v)
+		if err != nil {
+			return err
+		}
+		if field, ok := t.field(); ok {
+			subjectValue, err := data.value(field)
+			if err != nil {
+				return err
+			}
+			if values, ok := subjectValue.([]interface{}); ok {
+				for _, item := range values {
+					q.Add(key, fmt.Sprintf("%v", item))
+				}
+				return nil
+			}
+			q.Add(key, fmt.Sprintf("%v", subjectValue))
+			return nil
+		}
+		filled, err := t.execute(data, verbatim)
+		if err != nil {
+			return err
+		}
+		q.Add(key, filled)
+	case nil:
+		q.Add(key, "")
+	default:
+		q.Add(key, fmt.Sprintf("%v", v))
+	}
+	return nil
+}
+
+// validateParam checks that a param only nests maps, lists and scalars.
+func validateParam(key string, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if err := validateParam(key+"["+k+"]", item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.([]interface{}); ok {
+				return errors.Errorf("param '%s': nested lists are not supported", key)
+			}
+			if err := validateParam(key, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package flexiblehttp
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildRequest_Params(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   string
+		expected url.Values
+	}{
+		{
+			name: "Constants and placeholders",
+			params: `
+address: "{{ credentialSubject.address }}"
+page: 1
+verbose: true
+label: "wallet-{{ credentialSubject.address }}"`,
+			expected: url.Values{
+				"address": {"0x1"},
+				"page":    {"1"},
+				"verbose": {"true"},
+				"label":   {"wallet-0x1"},
+			},
+		},
+		{
+			name: "Repeated params",
+			params: `
+field: [balance, nonce]
+chain[]: "{{ credentialSubject.chains }}"`,
+			expected: url.Values{
+				"field":   {"balance", "nonce"},
+				"chain[]": {"1", "137"},
+			},
+		},
+		{
+			name: "Nested keys",
+			params: `
+filter:
+  owner: "{{ credentialSubject.address }}"
+  status: [active, pending]
+  range:
+    from: 0`,
+			expected: url.Values{
+				"filter[owner]":       {"0x1"},
+				"filter[status]":      {"active", "pending"},
+				"filter[range][from]": {"0"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fh := FlexibleHTTP{Provider: provider{URL: "https://example.com/api", Method: "GET"}}
+			require.NoError(t, yaml.Unmarshal([]byte(tt.params), &fh.RequestSchema.Params))
+			for key, value := range fh.RequestSchema.Params {
+				require.NoError(t, validateParam(key, value))
+			}
+
+			request, err := fh.BuildRequest(map[string]interface{}{
+				"address": "0x1",
+				"chains":  []interface{}{1, 137},
+			})
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, request.URL.Query())
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package flexiblehttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+)
+
+// Kinds of drift between cached and live provider fields.
+const (
+	// DriftValue is a field whose value changed.
+	DriftValue = "value"
+	// DriftType is a field whose JSON type changed, e.g. a number sent as
+	// a string.
+	DriftType = "type"
+	// DriftMissing is a cached field the live response doesn't have.
+	DriftMissing = "missing"
+	// DriftAdded is a live field the cached fields don't have.
+	DriftAdded = "added"
+)
+
+// Drift is a field of a subject whose cached value differs from the one of
+// the live provider response.
+type Drift struct {
+	Field  string      `json:"field"`
+	Kind   string      `json:"kind"`
+	Cached interface{} `json:"cached,omitempty"`
+	Live   interface{} `json:"live,omitempty"`
+}
+
+// Sampler is told about the subjects whose provider fields are cached, so
+// they can be reconciled later. source is the registry serving them.
+type Sampler interface {
+	Sample(source *FactoryFlexibleHTTP, credentialType, key string, credentialSubject map[string]interface{})
+}
+
+// WithSampler tells sampler about the subjects of providers caching their
+// responses.
+func WithSampler(sampler Sampler) FactoryOption {
+	return func(factory *FactoryFlexibleHTTP) {
+		factory.sampler = sampler
+	}
+}
+
+// sample tells the sampler about a subject served through the cache.
+func (fh *FlexibleHTTP) sample(key string, credentialSubject map[string]interface{}) {
+	if fh.sampler == nil || (fh.Settings.CacheTTL <= 0 && !fh.Settings.ConditionalRequests) {
+		return
+	}
+	fh.sampler.Sample(fh.source, fh.credentialType, key, credentialSubject)
+}
+
+// Reconcile calls the data provider for a subject and compares the response
+// with the cached fields, without updating the cache. ok is false when
+// nothing is cached for the subject, or only fields pushed by the upstream
+// system, which the provider isn't expected to match yet. Live responses
+// failing the response schema fail with ErrInvalidResponseSchema.
+func (factory *FactoryFlexibleHTTP) Reconcile(
+	ctx context.Context,
+	credentialType string,
+	credentialSubject map[string]interface{},
+) (drifts []Drift, ok bool, err error) {
+	fh, err := factory.ProduceFlexibleHTTP(credentialType)
+	if err != nil {
+		return nil, false, err
+	}
+	key, ok := fh.cacheKey(credentialSubject)
+	if fh.cache == nil || !ok {
+		return nil, false, nil
+	}
+	cached, ok := fh.cached(ctx, key)
+	if !ok || cached.Pushed {
+		return nil, false, nil
+	}
+	live, err := fh.provide(ctx, credentialSubject, nil)
+	if err != nil {
+		return nil, true, err
+	}
+	return compareFields(cached.Fields, live.Fields), true, nil
+}
+
+// compareFields returns the drifts of live from cached, by field name.
+func compareFields(cached, live map[string]interface{}) []Drift {
+	fields := slices.Collect(maps.Keys(cached))
+	for field := range live {
+		if _, ok := cached[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	var drifts []Drift
+	for _, field := range fields {
+		cachedValue, inCache := cached[field]
+		liveValue, inLive := live[field]
+		switch {
+		case !inLive:
+			drifts = append(drifts, Drift{Field: field, Kind: DriftMissing, Cached: cachedValue})
+		case !inCache:
+			drifts = append(drifts, Drift{Field: field, Kind: DriftAdded, Live: liveValue})
+		case jsonKind(cachedValue) != jsonKind(liveValue):
+			drifts = append(drifts, Drift{Field: field, Kind: DriftType, Cached: cachedValue, Live: liveValue})
+		case !sameJSON(cachedValue, liveValue):
+			drifts = append(drifts, Drift{Field: field, Kind: DriftValue, Cached: cachedValue, Live: liveValue})
+		}
+	}
+	return drifts
+}
+
+// jsonKind is the JSON type of a field value. Cached fields went through
+// JSON, so every number is one kind.
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "other"
+	}
+}
+
+// sameJSON compares values by their JSON form, so 1 and 1.0 are equal.
+func sameJSON(a, b interface{}) bool {
+	rawA, errA := json.Marshal(a)
+	rawB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(rawA, rawB)
+}
@@ -0,0 +1,135 @@
+package flexiblehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providercache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		cached   map[string]interface{}
+		live     map[string]interface{}
+		expected []Drift
+	}{
+		{
+			name:   "Match",
+			cached: map[string]interface{}{"balance": float64(100), "tags": []interface{}{"a"}},
+			live:   map[string]interface{}{"balance": 100, "tags": []interface{}{"a"}},
+		},
+		{
+			name:     "Value",
+			cached:   map[string]interface{}{"balance": "100"},
+			live:     map[string]interface{}{"balance": "150"},
+			expected: []Drift{{Field: "balance", Kind: DriftValue, Cached: "100", Live: "150"}},
+		},
+		{
+			name:     "Type",
+			cached:   map[string]interface{}{"balance": "100"},
+			live:     map[string]interface{}{"balance": 100},
+			expected: []Drift{{Field: "balance", Kind: DriftType, Cached: "100", Live: 100}},
+		},
+		{
+			name:   "Missing and added",
+			cached: map[string]interface{}{"balance": "100"},
+			live:   map[string]interface{}{"amount": "100"},
+			expected: []Drift{
+				{Field: "amount", Kind: DriftAdded, Live: "100"},
+				{Field: "balance", Kind: DriftMissing, Cached: "100"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, compareFields(tt.cached, tt.live))
+		})
+	}
+}
+
+type recordingSampler struct {
+	keys []string
+}
+
+func (s *recordingSampler) Sample(_ *FactoryFlexibleHTTP, credentialType, key string, _ map[string]interface{}) {
+	s.keys = append(s.keys, credentialType+"|"+key)
+}
+
+func TestFactoryFlexibleHTTP_Reconcile(t *testing.T) {
+	response := `{"result": "100"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(response))
+	}))
+	defer srv.Close()
+
+	sampler := &recordingSampler{}
+	factory := FactoryFlexibleHTTP{
+		configuration: newRegistry(map[string]FlexibleHTTP{
+			"Balance": {
+				Settings: settings{CacheTTL: time.Minute},
+				Provider: provider{URL: srv.URL, Method: http.MethodGet},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			},
+			"Uncached": {
+				Provider: provider{URL: srv.URL, Method: http.MethodGet},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			},
+		}),
+		httpcli: srv.Client(),
+		cache:   providercache.NewMemory(),
+		sampler: sampler,
+	}
+	ctx := context.Background()
+	subject := map[string]interface{}{"id": "did:iden3:owner"}
+
+	_, ok, err := factory.Reconcile(ctx, "Balance", subject)
+	require.NoError(t, err)
+	require.False(t, ok, "nothing cached yet")
+
+	for _, credentialType := range []string{"Balance", "Uncached"} {
+		fh, err := factory.ProduceFlexibleHTTP(credentialType)
+		require.NoError(t, err)
+		_, err = fh.Provide(ctx, subject)
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{"Balance|did:iden3:owner"}, sampler.keys)
+
+	drifts, ok, err := factory.Reconcile(ctx, "Balance", subject)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Empty(t, drifts)
+
+	response = `{"result": "150"}`
+	drifts, ok, err = factory.Reconcile(ctx, "Balance", subject)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, []Drift{{Field: "balance", Kind: DriftValue, Cached: "100", Live: "150"}}, drifts)
+
+	// the cache is not updated by reconciliation
+	fh, err := factory.ProduceFlexibleHTTP("Balance")
+	require.NoError(t, err)
+	fields, err := fh.Provide(ctx, subject)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"balance": "100"}, fields)
+
+	response = `{"amount": "150"}`
+	_, ok, err = factory.Reconcile(ctx, "Balance", subject)
+	require.ErrorIs(t, err, ErrInvalidResponseSchema)
+	require.True(t, ok)
+
+	// pushed fields are not expected to match the provider
+	require.NoError(t, factory.Push(ctx, "Balance", "did:iden3:owner", map[string]interface{}{"balance": "5"}, time.Minute))
+	_, ok, err = factory.Reconcile(ctx, "Balance", subject)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
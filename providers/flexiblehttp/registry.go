@@ -0,0 +1,128 @@
+package flexiblehttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// registry holds the provider configurations by credential type. Copies of
+// a factory share it, so a reload is seen by all of them at once.
+type registry struct {
+	configs atomic.Pointer[map[string]FlexibleHTTP]
+}
+
+func newRegistry(configs map[string]FlexibleHTTP) *registry {
+	r := &registry{}
+	r.configs.Store(&configs)
+	return r
+}
+
+func (r *registry) load() map[string]FlexibleHTTP {
+	if r == nil {
+		return nil
+	}
+	if configs := r.configs.Load(); configs != nil {
+		return *configs
+	}
+	return nil
+}
+
+// ConfigError lists the problems of a provider configuration which was not
+// applied, per credential type.
+type ConfigError struct {
+	Problems map[string][]string
+}
+
+func (e *ConfigError) Error() string {
+	types := make([]string, 0, len(e.Problems))
+	for t := range e.Problems {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	parts := make([]string, 0, len(types))
+	for _, t := range types {
+		parts = append(parts, fmt.Sprintf("%s: %s", t, strings.Join(e.Problems[t], "; ")))
+	}
+	return "invalid provider configuration: " + strings.Join(parts, ", ")
+}
+
+// readConfiguration reads the provider configurations of configPath.
+func readConfiguration(configPath string, httpcli *http.Client) (map[string]FlexibleHTTP, error) {
+	//nolint:gosec // configPath is a constant path in the project
+	f, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	cfgs := make(map[string]FlexibleHTTP)
+	if err := yaml.Unmarshal(f, &cfgs); err != nil {
+		return nil, err
+	}
+	if err := loadTLSClients(cfgs, httpcli); err != nil {
+		return nil, err
+	}
+	if err := loadTemplates(cfgs); err != nil {
+		return nil, err
+	}
+	return cfgs, nil
+}
+
+// Reload reads the provider configuration file again and swaps it in for
+// all copies of the factory. Nothing is applied when the file can't be
+// read or any credential type is invalid, the problems are returned as a
+// ConfigError then. It returns the credential types now configured.
+func (factory *FactoryFlexibleHTTP) Reload() ([]string, error) {
+	cfgs, err := readConfiguration(factory.configPath, factory.httpcli)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read provider configuration '%s'", factory.configPath)
+	}
+	if err := validateConfiguration(cfgs); err != nil {
+		return nil, err
+	}
+	factory.configuration.configs.Store(&cfgs)
+	return factory.CredentialTypes(), nil
+}
+
+// Validate checks every configured credential type and returns their
+// problems as a ConfigError.
+func (factory *FactoryFlexibleHTTP) Validate() error {
+	return validateConfiguration(factory.configuration.load())
+}
+
+// Fingerprints returns a hash of the configuration of every credential
+// type, so changes can be told without keeping the configuration, which
+// may hold credentials.
+func (factory *FactoryFlexibleHTTP) Fingerprints() map[string]string {
+	configs := factory.configuration.load()
+	fingerprints := make(map[string]string, len(configs))
+	for credentialType, cfg := range configs {
+		b, err := yaml.Marshal(cfg)
+		if err != nil {
+			b = []byte(err.Error())
+		}
+		sum := sha256.Sum256(b)
+		fingerprints[credentialType] = hex.EncodeToString(sum[:])
+	}
+	return fingerprints
+}
+
+func validateConfiguration(cfgs map[string]FlexibleHTTP) error {
+	problems := make(map[string][]string)
+	for credentialType, cfg := range cfgs {
+		for _, problem := range cfg.Validate() {
+			problems[credentialType] = append(problems[credentialType], problem.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package flexiblehttp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const balanceConfig = `
+Balance:
+  provider:
+    url: http://localhost/balance
+  responseSchema:
+    properties:
+      result:
+        type: string
+        match: credentialSubject.balance
+`
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name             string
+		config           string
+		expectedTypes    []string
+		expectedProblems []string
+		expectedErr      bool
+	}{
+		{
+			name: "Valid configuration",
+			config: balanceConfig + `
+KYCAge:
+  provider:
+    url: http://localhost/age
+  responseSchema:
+    properties:
+      age:
+        type: number
+        match: credentialSubject.age
+`,
+			expectedTypes: []string{"Balance", "KYCAge"},
+		},
+		{
+			name: "Invalid credential type",
+			config: balanceConfig + `
+KYCAge:
+  provider:
+    method: DELETE
+  responseSchema:
+    properties:
+      age:
+        type: number
+        match: credentialSubject.age
+`,
+			expectedProblems: []string{"KYCAge"},
+		},
+		{
+			name: "Invalid template",
+			config: balanceConfig + `
+KYCAge:
+  provider:
+    url: http://localhost/age/{{ credentialSubject.id
+  responseSchema:
+    properties:
+      age:
+        type: number
+        match: credentialSubject.age
+`,
+			expectedProblems: []string{"KYCAge"},
+		},
+		{
+			name:        "Malformed YAML",
+			config:      "Balance: [",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(config, []byte(balanceConfig), 0o600))
+			factory, err := NewFactoryFlexibleHTTP(config, nil)
+			require.NoError(t, err)
+			// copies share the registry, like the refresh service's one
+			shared := factory
+
+			require.NoError(t, os.WriteFile(config, []byte(tt.config), 0o600))
+			types, err := factory.Reload()
+			if tt.expectedErr || tt.expectedProblems != nil {
+				require.Error(t, err)
+				var configErr *ConfigError
+				require.Equal(t, tt.expectedProblems != nil, errors.As(err, &configErr))
+				if configErr != nil {
+					for _, credentialType := range tt.expectedProblems {
+						require.NotEmpty(t, configErr.Problems[credentialType])
+					}
+					require.Len(t, configErr.Problems, len(tt.expectedProblems))
+				}
+				// the previous configuration is kept
+				require.Equal(t, []string{"Balance"}, shared.CredentialTypes())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedTypes, types)
+			require.Equal(t, tt.expectedTypes, shared.CredentialTypes())
+		})
+	}
+}
+
+func TestFactoryValidate(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(balanceConfig), 0o600))
+	factory, err := NewFactoryFlexibleHTTP(config, nil)
+	require.NoError(t, err)
+	require.NoError(t, factory.Validate())
+
+	require.NoError(t, os.WriteFile(config, []byte(balanceConfig+`
+KYCAge:
+  provider:
+    url: http://localhost/age
+`), 0o600))
+	factory, err = NewFactoryFlexibleHTTP(config, nil)
+	require.NoError(t, err)
+	var configErr *ConfigError
+	require.ErrorAs(t, factory.Validate(), &configErr)
+	require.Len(t, configErr.Problems, 1)
+	require.NotEmpty(t, configErr.Problems["KYCAge"])
+}
+
+func TestFactoryFingerprints(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(balanceConfig), 0o600))
+	factory, err := NewFactoryFlexibleHTTP(config, nil)
+	require.NoError(t, err)
+	before := factory.Fingerprints()
+	require.Len(t, before, 1)
+
+	_, err = factory.Reload()
+	require.NoError(t, err)
+	require.Equal(t, before, factory.Fingerprints())
+
+	changed := strings.Replace(balanceConfig, "http://localhost/balance", "http://localhost/v2/balance", 1)
+	require.NoError(t, os.WriteFile(config, []byte(changed), 0o600))
+	_, err = factory.Reload()
+	require.NoError(t, err)
+	require.NotEqual(t, before["Balance"], factory.Fingerprints()["Balance"])
+}
@@ -0,0 +1,68 @@
+package flexiblehttp
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providercache"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// provideSources calls every source of a routed credential type
+// concurrently and merges the fields they return.
+func (fh *FlexibleHTTP) provideSources(ctx context.Context, credentialSubject map[string]interface{}) (*providercache.Entry, error) {
+	results := make([]map[string]interface{}, len(fh.Sources))
+	g, ctx := errgroup.WithContext(ctx)
+	for i := range fh.Sources {
+		source := fh.Sources[i]
+		g.Go(func() error {
+			entry, err := source.provide(ctx, credentialSubject, nil)
+			if err != nil {
+				return errors.WithMessagef(err, "source %d", i)
+			}
+			results[i] = entry.Fields
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+	for _, result := range results {
+		maps.Copy(fields, result)
+	}
+	return &providercache.Entry{Fields: fields, StoredAt: time.Now().UTC()}, nil
+}
+
+// validateSources checks a routed credential type: the sources are
+// complete providers and every field comes from exactly one of them.
+func (fh *FlexibleHTTP) validateSources() []error {
+	var problems []error
+	if fh.Provider.URL != "" || len(fh.ResponseSchema.Properties) != 0 {
+		problems = append(problems, errors.Wrap(ErrInvalidRequestSchema,
+			"provider and responseSchema are set by the sources"))
+	}
+	routedBy := map[string]int{}
+	for i := range fh.Sources {
+		source := &fh.Sources[i]
+		if len(source.Sources) != 0 {
+			problems = append(problems, errors.Wrapf(ErrInvalidRequestSchema, "source %d: nested sources", i))
+			continue
+		}
+		for _, problem := range source.Validate() {
+			problems = append(problems, errors.WithMessagef(problem, "source %d", i))
+		}
+		for field := range source.mappedFields() {
+			if other, ok := routedBy[field]; ok {
+				problems = append(problems, errors.Wrapf(ErrInvalidResponseSchema,
+					"field '%s' is provided by sources %d and %d", field, other, i))
+				continue
+			}
+			routedBy[field] = i
+		}
+	}
+	return problems
+}
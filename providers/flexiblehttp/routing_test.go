@@ -0,0 +1,134 @@
+package flexiblehttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvide_Sources(t *testing.T) {
+	scores := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"score": 42}`))
+	}))
+	defer scores.Close()
+	tiers := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") != "did:iden3:owner" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"tier": "gold"}`))
+	}))
+	defer tiers.Close()
+
+	factory := FactoryFlexibleHTTP{
+		configuration: newRegistry(map[string]FlexibleHTTP{
+			"Membership": {Sources: []FlexibleHTTP{
+				{
+					Provider: provider{URL: scores.URL, Method: http.MethodGet},
+					ResponseSchema: responseSchema{Properties: map[string]matchedField{
+						"score": {Type: "integer", MatchTo: "credentialSubject.score"},
+					}},
+				},
+				{
+					Provider:      provider{URL: tiers.URL, Method: http.MethodGet},
+					RequestSchema: requestSchema{Params: map[string]interface{}{"id": "{{ credentialSubject.id }}"}},
+					ResponseSchema: responseSchema{Properties: map[string]matchedField{
+						"tier": {Type: "string", MatchTo: "credentialSubject.tier"},
+					}},
+				},
+			}},
+		}),
+		httpcli: http.DefaultClient,
+	}
+	fh, err := factory.ProduceFlexibleHTTP("Membership")
+	require.NoError(t, err)
+	require.Empty(t, fh.Validate())
+	require.Equal(t, map[string]bool{"score": true, "tier": true}, fh.mappedFields())
+
+	fields, err := fh.Provide(context.Background(), map[string]interface{}{"id": "did:iden3:owner"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"score": 42, "tier": "gold"}, fields)
+
+	_, err = fh.Provide(context.Background(), map[string]interface{}{"id": "did:iden3:other"})
+	require.ErrorIs(t, err, ErrDataProviderIssue)
+}
+
+func TestValidate_Sources(t *testing.T) {
+	source := FlexibleHTTP{
+		Provider: provider{URL: "https://example.com", Method: "GET"},
+		ResponseSchema: responseSchema{Properties: map[string]matchedField{
+			"score": {Type: "integer", MatchTo: "credentialSubject.score"},
+		}},
+	}
+	fh := FlexibleHTTP{
+		Provider: provider{URL: "https://example.com"},
+		Sources:  []FlexibleHTTP{source, source, {Sources: []FlexibleHTTP{source}}},
+	}
+	// provider set, score routed twice and nested sources
+	require.Len(t, fh.Validate(), 3)
+}
+
+func TestSelect(t *testing.T) {
+	score := FlexibleHTTP{
+		Provider: provider{URL: "https://scores.example.com", Method: http.MethodGet},
+		ResponseSchema: responseSchema{Properties: map[string]matchedField{
+			"score": {Type: "integer", MatchTo: "credentialSubject.score"},
+		}},
+	}
+	tier := FlexibleHTTP{
+		Provider: provider{URL: "https://tiers.example.com", Method: http.MethodGet},
+		ResponseSchema: responseSchema{Properties: map[string]matchedField{
+			"tier": {Type: "string", MatchTo: "credentialSubject.tier"},
+		}},
+	}
+
+	tests := []struct {
+		name            string
+		fh              FlexibleHTTP
+		fields          []string
+		expectedSources []string
+		expectedErr     error
+	}{
+		{
+			name:   "Single provider",
+			fh:     score,
+			fields: []string{"score"},
+		},
+		{
+			name:            "One of the sources",
+			fh:              FlexibleHTTP{Sources: []FlexibleHTTP{score, tier}},
+			fields:          []string{"tier"},
+			expectedSources: []string{"https://tiers.example.com"},
+		},
+		{
+			name:            "Every source",
+			fh:              FlexibleHTTP{Sources: []FlexibleHTTP{score, tier}},
+			fields:          []string{"score", "tier"},
+			expectedSources: []string{"https://scores.example.com", "https://tiers.example.com"},
+		},
+		{
+			name:        "Field not provided",
+			fh:          FlexibleHTTP{Sources: []FlexibleHTTP{score, tier}},
+			fields:      []string{"name"},
+			expectedErr: ErrInvalidRequestSchema,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := tt.fh.Select(tt.fields)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			var sources []string
+			for _, source := range selected.Sources {
+				sources = append(sources, source.Provider.URL)
+			}
+			require.Equal(t, tt.expectedSources, sources)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package flexiblehttp
+
+import (
+	"regexp"
+
+	"github.com/0xPolygonID/refresh-service/secrets"
+	"github.com/pkg/errors"
+)
+
+var secretPlaceholder = regexp.MustCompile(`\{\{\s*secrets\.([A-Za-z0-9_.-]+)\s*\}\}`)
+
+type FactoryOption func(*FactoryFlexibleHTTP)
+
+// WithSecrets resolves '{{ secrets.NAME }}' placeholders in provider URLs,
+// params and headers from a rotatable secret store.
+func WithSecrets(store *secrets.Store) FactoryOption {
+	return func(factory *FactoryFlexibleHTTP) {
+		factory.secrets = store
+	}
+}
+
+// resolveSecrets replaces secret placeholders in v. With previous set, the
+// values replaced by the last rotation are used where available; rotated
+// reports whether any of them was.
+func (fh *FlexibleHTTP) resolveSecrets(v string, previous bool) (resolved string, rotated bool, err error) {
+	if !secretPlaceholder.MatchString(v) {
+		return v, false, nil
+	}
+	resolved = secretPlaceholder.ReplaceAllStringFunc(v, func(match string) string {
+		value, r, e := fh.secret(secretPlaceholder.FindStringSubmatch(match)[1], previous)
+		rotated = rotated || r
+		if e != nil {
+			err = e
+		}
+		return value
+	})
+	return resolved, rotated, err
+}
+
+// secret returns the value of the secret name, or with previous set the
+// value replaced by its last rotation where available.
+func (fh *FlexibleHTTP) secret(name string, previous bool) (value string, rotated bool, err error) {
+	if fh.secrets == nil {
+		return "", false, errors.New("secret placeholders require a secret store")
+	}
+	if previous {
+		if value, ok := fh.secrets.Previous(name); ok {
+			return value, true, nil
+		}
+	}
+	value, ok := fh.secrets.Get(name)
+	if !ok {
+		return "", false, errors.Errorf("secret '%s' not found", name)
+	}
+	return value, false, nil
+}
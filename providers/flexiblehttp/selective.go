@@ -0,0 +1,35 @@
+package flexiblehttp
+
+import (
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// Select narrows the provider to the credential subject fields of a
+// selective refresh. Sources providing none of the fields are not called.
+// Their responses would be partial, so a narrowed provider is not cached.
+func (fh *FlexibleHTTP) Select(fields []string) (FlexibleHTTP, error) {
+	mapped := fh.mappedFields()
+	for _, field := range fields {
+		if !mapped[field] {
+			return FlexibleHTTP{}, errors.Wrapf(ErrInvalidRequestSchema, "field '%s' is not provided", field)
+		}
+	}
+	selected := *fh
+	if len(fh.Sources) == 0 {
+		return selected, nil
+	}
+	sources := make([]FlexibleHTTP, 0, len(fh.Sources))
+	for i := range fh.Sources {
+		provided := fh.Sources[i].mappedFields()
+		if slices.ContainsFunc(fields, func(field string) bool { return provided[field] }) {
+			sources = append(sources, fh.Sources[i])
+		}
+	}
+	if len(sources) < len(fh.Sources) {
+		selected.Sources = sources
+		selected.cache = nil
+	}
+	return selected, nil
+}
@@ -0,0 +1,277 @@
+package flexiblehttp
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	templatePlaceholder = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+	subjectFieldName    = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	secretName          = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+)
+
+type templatePartKind int
+
+const (
+	literalPart templatePartKind = iota
+	subjectPart
+	secretPart
+)
+
+type templatePart struct {
+	kind templatePartKind
+	// value is the literal text, the subject field or the secret name.
+	value string
+}
+
+// template is a provider URL, param, header, body or OData filter template
+// split into literal text and '{{ credentialSubject.field }}' and
+// '{{ secrets.NAME }}' placeholders. Templates are compiled when the
+// configuration is loaded and only filled per request.
+type template struct {
+	parts []templatePart
+	size  int
+}
+
+func compileTemplate(src string) (*template, error) {
+	t := &template{size: len(src)}
+	last := 0
+	for _, loc := range templatePlaceholder.FindAllStringSubmatchIndex(src, -1) {
+		if err := t.literal(src[last:loc[0]]); err != nil {
+			return nil, err
+		}
+		namespace, name, _ := strings.Cut(strings.TrimSpace(src[loc[2]:loc[3]]), ".")
+		switch {
+		case namespace == "credentialSubject" && subjectFieldName.MatchString(name):
+			t.parts = append(t.parts, templatePart{kind: subjectPart, value: name})
+		case namespace == "secrets" && secretName.MatchString(name):
+			t.parts = append(t.parts, templatePart{kind: secretPart, value: name})
+		default:
+			return nil, errors.Errorf("unsupported placeholder '%s'", src[loc[0]:loc[1]])
+		}
+		last = loc[1]
+	}
+	if err := t.literal(src[last:]); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *template) literal(text string) error {
+	if i := strings.Index(text, "{{"); i != -1 {
+		return errors.Errorf("unclosed placeholder '%s'", text[i:])
+	}
+	if text != "" {
+		t.parts = append(t.parts, templatePart{kind: literalPart, value: text})
+	}
+	return nil
+}
+
+// field returns the subject field of a template which is only one subject
+// placeholder.
+func (t *template) field() (string, bool) {
+	if len(t.parts) != 1 || t.parts[0].kind != subjectPart {
+		return "", false
+	}
+	return t.parts[0].value, true
+}
+
+func (t *template) hasField() bool {
+	for _, part := range t.parts {
+		if part.kind == subjectPart {
+			return true
+		}
+	}
+	return false
+}
+
+// pathSegments checks that the subject placeholders of a URL template are
+// whole path segments, e.g. 'https://host/users/{{ credentialSubject.id }}'.
+func (t *template) pathSegments() error {
+	query := false
+	for i, part := range t.parts {
+		switch part.kind {
+		case literalPart:
+			query = query || strings.ContainsAny(part.value, "?#")
+		case subjectPart:
+			before := i > 0 && t.parts[i-1].kind == literalPart &&
+				strings.HasSuffix(t.parts[i-1].value, "/") && !strings.HasSuffix(t.parts[i-1].value, "//")
+			after := i+1 == len(t.parts) ||
+				t.parts[i+1].kind == literalPart && strings.IndexAny(t.parts[i+1].value, "/?#") == 0
+			if query || !before || !after {
+				return errors.Errorf("placeholder 'credentialSubject.%s' is not a whole path segment", part.value)
+			}
+		}
+	}
+	return nil
+}
+
+// templateData fills the placeholders of the templates of one request.
+type templateData struct {
+	credentialSubject map[string]interface{}
+	secret            func(name string) (string, error)
+}
+
+func (data templateData) value(field string) (interface{}, error) {
+	v, ok := data.credentialSubject[field]
+	if !ok {
+		return nil, errors.Errorf("not found value for placeholder: 'credentialSubject.%s'", field)
+	}
+	return v, nil
+}
+
+// execute fills the template. Subject values are escaped for where the
+// template is used, secret values are used as they are.
+func (t *template) execute(data templateData, escape func(string) string) (string, error) {
+	var b strings.Builder
+	b.Grow(t.size)
+	for _, part := range t.parts {
+		switch part.kind {
+		case literalPart:
+			b.WriteString(part.value)
+		case subjectPart:
+			v, err := data.value(part.value)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(escape(fmt.Sprintf("%v", v)))
+		case secretPart:
+			if data.secret == nil {
+				return "", errors.New("secret placeholders require a secret store")
+			}
+			v, err := data.secret(part.value)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+		}
+	}
+	return b.String(), nil
+}
+
+func verbatim(s string) string {
+	return s
+}
+
+// escapePathSegment escapes a subject value as url.URL does for a path, so
+// a value with '/' spans several segments.
+func escapePathSegment(s string) string {
+	return (&url.URL{Path: s}).EscapedPath()
+}
+
+// templates are the compiled templates of a provider by their source.
+type templates map[string]*template
+
+// compiled returns the template of src. Providers which were not loaded
+// from a configuration file compile their templates on use.
+func (fh *FlexibleHTTP) compiled(src string) (*template, error) {
+	if t, ok := fh.templates[src]; ok {
+		return t, nil
+	}
+	return compileTemplate(src)
+}
+
+func (fh *FlexibleHTTP) fill(src string, data templateData, escape func(string) string) (string, error) {
+	t, err := fh.compiled(src)
+	if err != nil {
+		return "", err
+	}
+	return t.execute(data, escape)
+}
+
+// compileTemplates compiles the URL, params, headers, body and OData filter
+// of the provider. Sources are compiled on their own.
+func (fh *FlexibleHTTP) compileTemplates() (templates, []error) {
+	compiled := make(templates)
+	var problems []error
+	compile := func(what, src string) *template {
+		if t, ok := compiled[src]; ok {
+			return t
+		}
+		t, err := compileTemplate(src)
+		if err != nil {
+			problems = append(problems, errors.Wrapf(ErrInvalidRequestSchema, "%s: %v", what, err))
+			return nil
+		}
+		compiled[src] = t
+		return t
+	}
+
+	if t := compile("url", fh.Provider.URL); t != nil {
+		if err := t.pathSegments(); err != nil {
+			problems = append(problems, errors.Wrapf(ErrInvalidRequestSchema, "url: %v", err))
+		}
+	}
+	keys := make([]string, 0, len(fh.RequestSchema.Params))
+	for key := range fh.RequestSchema.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		walkParam(key, fh.RequestSchema.Params[key], func(key, src string) {
+			compile(fmt.Sprintf("param '%s'", key), src)
+		})
+	}
+	keys = keys[:0]
+	for key := range fh.RequestSchema.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if t := compile(fmt.Sprintf("header '%s'", key), fh.RequestSchema.Headers[key]); t != nil && t.hasField() {
+			problems = append(problems, errors.Wrapf(ErrInvalidRequestSchema,
+				"header '%s': credentialSubject placeholders are not supported", key))
+		}
+	}
+	compile("body", fh.RequestSchema.Body)
+	compile("odata filter", fh.RequestSchema.OData.Filter)
+	return compiled, problems
+}
+
+// walkParam calls fn with every string of a param.
+func walkParam(key string, value interface{}, fn func(key, src string)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			walkParam(key+"["+k+"]", item, fn)
+		}
+	case []interface{}:
+		for _, item := range v {
+			walkParam(key, item, fn)
+		}
+	case string:
+		fn(key, v)
+	}
+}
+
+// loadTemplates compiles the templates of the providers, so template
+// errors are reported when the configuration is loaded.
+func loadTemplates(cfgs map[string]FlexibleHTTP) error {
+	problems := make(map[string][]string)
+	for credentialType, fh := range cfgs {
+		var errs []error
+		fh.templates, errs = fh.compileTemplates()
+		for _, err := range errs {
+			problems[credentialType] = append(problems[credentialType], err.Error())
+		}
+		for i := range fh.Sources {
+			source := &fh.Sources[i]
+			source.templates, errs = source.compileTemplates()
+			for _, err := range errs {
+				problems[credentialType] = append(problems[credentialType],
+					errors.WithMessagef(err, "source %d", i).Error())
+			}
+		}
+		cfgs[credentialType] = fh
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
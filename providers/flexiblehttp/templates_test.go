@@ -0,0 +1,138 @@
+package flexiblehttp
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:     "Literal",
+			src:      `{"status": "active"}`,
+			expected: `{"status": "active"}`,
+		},
+		{
+			name:     "Subject and secret placeholders",
+			src:      "{{ credentialSubject.address }}:{{secrets.API_KEY}}",
+			expected: "0x01:key",
+		},
+		{
+			name:        "Unsupported placeholder",
+			src:         "{{ credentialSubject }}",
+			expectedErr: "unsupported placeholder '{{ credentialSubject }}'",
+		},
+		{
+			name:        "Unknown namespace",
+			src:         "{{ env.API_KEY }}",
+			expectedErr: "unsupported placeholder '{{ env.API_KEY }}'",
+		},
+		{
+			name:        "Unclosed placeholder",
+			src:         "id={{ credentialSubject.id",
+			expectedErr: "unclosed placeholder '{{ credentialSubject.id'",
+		},
+	}
+
+	data := templateData{
+		credentialSubject: map[string]interface{}{"address": "0x01"},
+		secret: func(name string) (string, error) {
+			require.Equal(t, "API_KEY", name)
+			return "key", nil
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := compileTemplate(tt.src)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			filled, err := template.execute(data, verbatim)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, filled)
+		})
+	}
+}
+
+func TestCompileTemplates(t *testing.T) {
+	tests := []struct {
+		name             string
+		provider         provider
+		requestSchema    requestSchema
+		expectedProblems []string
+	}{
+		{
+			name:     "Valid templates",
+			provider: provider{URL: "https://{{ secrets.HOST }}/users/{{ credentialSubject.id }}?format=json"},
+			requestSchema: requestSchema{
+				Params:  map[string]interface{}{"filter": map[string]interface{}{"id": "{{ credentialSubject.id }}"}},
+				Headers: map[string]string{"Authorization": "Bearer {{ secrets.TOKEN }}"},
+				Body:    `{"id": "{{ credentialSubject.id }}"}`,
+			},
+		},
+		{
+			name:     "Placeholder in URL host",
+			provider: provider{URL: "https://{{ credentialSubject.host }}/users"},
+			expectedProblems: []string{
+				"url: placeholder 'credentialSubject.host' is not a whole path segment: invalid request schema",
+			},
+		},
+		{
+			name:     "Placeholder in part of a path segment",
+			provider: provider{URL: "https://localhost/users/id-{{ credentialSubject.id }}"},
+			expectedProblems: []string{
+				"url: placeholder 'credentialSubject.id' is not a whole path segment: invalid request schema",
+			},
+		},
+		{
+			name:     "Invalid param and header",
+			provider: provider{URL: "https://localhost/users"},
+			requestSchema: requestSchema{
+				Params:  map[string]interface{}{"ids": []interface{}{"{{ credentialSubject.id "}},
+				Headers: map[string]string{"X-User": "{{ credentialSubject.id }}"},
+			},
+			expectedProblems: []string{
+				"param 'ids': unclosed placeholder '{{ credentialSubject.id ': invalid request schema",
+				"header 'X-User': credentialSubject placeholders are not supported: invalid request schema",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fh := FlexibleHTTP{Provider: tt.provider, RequestSchema: tt.requestSchema}
+			compiled, problems := fh.compileTemplates()
+			var messages []string
+			for _, problem := range problems {
+				require.True(t, errors.Is(problem, ErrInvalidRequestSchema))
+				messages = append(messages, problem.Error())
+			}
+			require.Equal(t, tt.expectedProblems, messages)
+			if len(tt.expectedProblems) == 0 {
+				require.Contains(t, compiled, tt.provider.URL)
+				require.Contains(t, compiled, tt.requestSchema.Body)
+			}
+		})
+	}
+}
+
+func TestBuildRequest_PathValues(t *testing.T) {
+	fh := FlexibleHTTP{Provider: provider{URL: "https://localhost/users/{{ credentialSubject.id }}/balance"}}
+	fh.templates, _ = fh.compileTemplates()
+	request, err := fh.BuildRequest(map[string]interface{}{"id": "a b/c?d"})
+	require.NoError(t, err)
+	require.Equal(t, "/users/a b/c?d/balance", request.URL.Path)
+	require.Equal(t, "https://localhost/users/a%20b/c%3Fd/balance", request.URL.String())
+
+	_, err = fh.BuildRequest(map[string]interface{}{})
+	require.EqualError(t, err, "not found value for placeholder: 'credentialSubject.id'")
+}
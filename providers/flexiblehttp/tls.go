@@ -0,0 +1,82 @@
+package flexiblehttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/pkg/errors"
+)
+
+// providerTLS verifies providers using a private CA. InsecureSkipVerify
+// disables verification and is meant for development only.
+type providerTLS struct {
+	CAFile             string `yaml:"caFile"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+func (t providerTLS) enabled() bool {
+	return t.CAFile != "" || t.InsecureSkipVerify
+}
+
+// tlsClient returns a copy of httpcli verifying the provider with its TLS
+// settings.
+func (t providerTLS) tlsClient(httpcli *http.Client, credentialType string) (*http.Client, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.CAFile != "" {
+		//nolint:gosec // CA path comes from the provider configuration
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, errors.Errorf("failed to read CA file '%s': %v", t.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in CA file '%s'", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if t.InsecureSkipVerify {
+		logger.DefaultLogger.Warnf("⚠️ TLS verification is DISABLED for the provider of '%s'. "+
+			"insecureSkipVerify is for development only, never use it in production", credentialType)
+		//nolint:gosec // explicit development escape hatch
+		cfg.InsecureSkipVerify = true
+	}
+
+	return httpclient.WithTLSConfig(httpcli, cfg), nil
+}
+
+// loadTLSClients creates the clients of providers with TLS settings.
+func loadTLSClients(cfgs map[string]FlexibleHTTP, httpcli *http.Client) error {
+	for credentialType, fh := range cfgs {
+		if fh.Provider.TLS.enabled() {
+			client, err := fh.Provider.TLS.tlsClient(httpcli, credentialType)
+			if err != nil {
+				return errors.Wrapf(err, "provider of '%s'", credentialType)
+			}
+			fh.tlsClient = client
+		}
+		for i := range fh.Sources {
+			source := &fh.Sources[i]
+			if !source.Provider.TLS.enabled() {
+				continue
+			}
+			client, err := source.Provider.TLS.tlsClient(httpcli, credentialType)
+			if err != nil {
+				return errors.Wrapf(err, "source %d of '%s'", i, credentialType)
+			}
+			source.tlsClient = client
+		}
+		cfgs[credentialType] = fh
+	}
+	return nil
+}
+
+func (fh *FlexibleHTTP) client(httpcli *http.Client) *http.Client {
+	if fh.tlsClient != nil {
+		return fh.tlsClient
+	}
+	return httpcli
+}
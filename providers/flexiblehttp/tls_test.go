@@ -0,0 +1,87 @@
+package flexiblehttp
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvide_ProviderTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"result": "100"}`))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600))
+
+	tests := []struct {
+		name        string
+		tls         string
+		expectedErr error
+	}{
+		{
+			name:        "System roots",
+			expectedErr: ErrDataProviderIssue,
+		},
+		{
+			name: "Private CA",
+			tls:  fmt.Sprintf("tls: {caFile: %s}", caFile),
+		},
+		{
+			name: "Insecure skip verify",
+			tls:  "tls: {insecureSkipVerify: true}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := filepath.Join(dir, "config.yaml")
+			require.NoError(t, os.WriteFile(config, []byte(fmt.Sprintf(`
+Balance:
+  provider:
+    url: %s
+    method: GET
+    %s
+  responseSchema:
+    properties:
+      result:
+        type: string
+        match: credentialSubject.balance
+`, srv.URL, tt.tls)), 0o600))
+
+			factory, err := NewFactoryFlexibleHTTP(config, &http.Client{})
+			require.NoError(t, err)
+			fh, err := factory.ProduceFlexibleHTTP("Balance")
+			require.NoError(t, err)
+			fields, err := fh.Provide(context.Background(), map[string]interface{}{})
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, map[string]interface{}{"balance": "100"}, fields)
+		})
+	}
+}
+
+func TestNewFactoryFlexibleHTTP_InvalidCAFile(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(`
+Balance:
+  provider:
+    url: https://example.com
+    tls: {caFile: /missing/ca.pem}
+`), 0o600))
+	_, err := NewFactoryFlexibleHTTP(config, nil)
+	require.ErrorContains(t, err, "/missing/ca.pem")
+}
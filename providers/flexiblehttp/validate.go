@@ -0,0 +1,115 @@
+package flexiblehttp
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var supportedResponseTypes = map[string]bool{
+	"string":  true,
+	"integer": true,
+	"double":  true,
+	"number":  true,
+	"float":   true,
+	"boolean": true,
+	"bool":    true,
+}
+
+// Validate checks the provider configuration without calling the provider
+// and returns every problem found.
+func (fh *FlexibleHTTP) Validate() []error {
+	var problems []error
+	if err := fh.Settings.validateIssuanceDate(fh.mappedFields()); err != nil {
+		problems = append(problems, errors.Wrap(ErrInvalidRequestSchema, err.Error()))
+	}
+	if len(fh.Sources) != 0 {
+		return append(problems, fh.validateSources()...)
+	}
+	if fh.Provider.URL == "" && !fh.Settings.ExpirationOnly {
+		problems = append(problems, errors.Wrap(ErrInvalidRequestSchema, "provider url is empty"))
+	}
+	switch strings.ToUpper(fh.Provider.Method) {
+	case "", http.MethodGet, http.MethodPost, http.MethodPut:
+	default:
+		problems = append(problems, errors.Wrapf(ErrInvalidRequestSchema,
+			"unsupported method '%s'", fh.Provider.Method))
+	}
+	if fh.Settings.CacheKey != "" && !isPlaceholder(fh.Settings.CacheKey) {
+		problems = append(problems, errors.Wrapf(ErrInvalidRequestSchema,
+			"cache key '%s' is not a credentialSubject placeholder", fh.Settings.CacheKey))
+	}
+	if !isClaimPosition(fh.Settings.MerklizedRootPosition) {
+		problems = append(problems, errors.Wrapf(ErrInvalidRequestSchema,
+			"merklized root position '%s' is not 'index' or 'value'", fh.Settings.MerklizedRootPosition))
+	}
+	if !isClaimPosition(fh.Settings.SubjectPosition) {
+		problems = append(problems, errors.Wrapf(ErrInvalidRequestSchema,
+			"subject position '%s' is not 'index' or 'value'", fh.Settings.SubjectPosition))
+	}
+	for key, value := range fh.RequestSchema.Params {
+		if err := validateParam(key, value); err != nil {
+			problems = append(problems, errors.Wrap(ErrInvalidRequestSchema, err.Error()))
+		}
+	}
+	_, templateProblems := fh.compileTemplates()
+	problems = append(problems, templateProblems...)
+	if err := fh.RequestSchema.Auth.validate(); err != nil {
+		problems = append(problems, errors.Wrap(ErrInvalidRequestSchema, err.Error()))
+	}
+	if err := fh.RequestSchema.HMAC.validate(); err != nil {
+		problems = append(problems, errors.Wrap(ErrInvalidRequestSchema, err.Error()))
+	}
+	switch fh.ResponseSchema.Type {
+	case "", responseTypeJSON, responseTypeXML, responseTypeOData:
+	case responseTypeCSV:
+		if err := fh.ResponseSchema.CSV.validate(); err != nil {
+			problems = append(problems, errors.Wrap(ErrInvalidResponseSchema, err.Error()))
+		}
+	default:
+		problems = append(problems, errors.Wrapf(ErrInvalidResponseSchema,
+			"unsupported response type '%s'", fh.ResponseSchema.Type))
+	}
+	if len(fh.ResponseSchema.Properties) == 0 && !fh.Settings.ExpirationOnly {
+		problems = append(problems, errors.Wrap(ErrInvalidResponseSchema, "no response properties"))
+	}
+
+	keys := make([]string, 0, len(fh.ResponseSchema.Properties))
+	for k := range fh.ResponseSchema.Properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field := fh.ResponseSchema.Properties[key]
+		switch fh.ResponseSchema.Type {
+		case responseTypeXML:
+			if _, _, err := compileXPath(key); err != nil {
+				problems = append(problems, errors.Wrapf(ErrInvalidResponseSchema, "property '%s': %v", key, err))
+			}
+		case responseTypeCSV:
+			// properties are column names
+		default:
+			for _, part := range strings.Split(key, ".") {
+				if k, _ := processKey(part); k == "" {
+					problems = append(problems, errors.Wrapf(ErrInvalidResponseSchema,
+						"property '%s': invalid key '%s'", key, part))
+				}
+			}
+		}
+		if !supportedResponseTypes[field.Type] {
+			problems = append(problems, errors.Wrapf(ErrInvalidResponseSchema,
+				"property '%s': unsupported type '%s'", key, field.Type))
+		}
+		if p := strings.Split(field.MatchTo, "."); len(p) != 2 || p[0] != "credentialSubject" || p[1] == "" {
+			problems = append(problems, errors.Wrapf(ErrInvalidResponseSchema,
+				"property '%s': match '%s' is not a credentialSubject field", key, field.MatchTo))
+		}
+	}
+	return problems
+}
+
+func isClaimPosition(position string) bool {
+	return position == "" || position == "index" || position == "value"
+}
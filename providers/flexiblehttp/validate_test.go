@@ -0,0 +1,122 @@
+package flexiblehttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name             string
+		config           FlexibleHTTP
+		expectedProblems int
+	}{
+		{
+			name: "Valid",
+			config: FlexibleHTTP{
+				Provider: provider{URL: "https://example.com", Method: "GET"},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"wallet.eth[0].balance": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			},
+		},
+		{
+			name:             "Empty",
+			expectedProblems: 2,
+		},
+		{
+			name:   "Expiration only",
+			config: FlexibleHTTP{Settings: settings{ExpirationOnly: true}},
+		},
+		{
+			name: "Invalid properties",
+			config: FlexibleHTTP{
+				Provider: provider{URL: "https://example.com", Method: "PATCH"},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result":   {Type: "uint", MatchTo: "credentialSubject.balance"},
+					"a..b":     {Type: "string", MatchTo: "credentialSubject.b"},
+					"response": {Type: "string", MatchTo: "balance"},
+				}},
+			},
+			expectedProblems: 4,
+		},
+		{
+			name: "Invalid hmac",
+			config: FlexibleHTTP{
+				Provider:      provider{URL: "https://example.com", Method: "POST"},
+				RequestSchema: requestSchema{HMAC: hmacSettings{Secret: "shared", Algorithm: "md5"}},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			},
+			expectedProblems: 1,
+		},
+		{
+			name: "Invalid claim positions",
+			config: FlexibleHTTP{
+				Settings: settings{MerklizedRootPosition: "none", SubjectPosition: "value"},
+				Provider: provider{URL: "https://example.com", Method: "GET"},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			},
+			expectedProblems: 1,
+		},
+		{
+			name: "Provider issuance date",
+			config: FlexibleHTTP{
+				Settings: settings{IssuanceDate: IssuanceDateProvider, IssuanceDateField: "verifiedAt"},
+				Provider: provider{URL: "https://example.com", Method: "GET"},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result":      {Type: "string", MatchTo: "credentialSubject.balance"},
+					"verified_at": {Type: "integer", MatchTo: "credentialSubject.verifiedAt"},
+				}},
+			},
+		},
+		{
+			name: "Unmatched issuance date field",
+			config: FlexibleHTTP{
+				Settings: settings{IssuanceDate: IssuanceDateProvider, IssuanceDateField: "verifiedAt"},
+				Provider: provider{URL: "https://example.com", Method: "GET"},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			},
+			expectedProblems: 1,
+		},
+		{
+			name:             "Provider issuance date without provider",
+			config:           FlexibleHTTP{Settings: settings{ExpirationOnly: true, IssuanceDate: IssuanceDateProvider}},
+			expectedProblems: 1,
+		},
+		{
+			name: "Invalid issuance date",
+			config: FlexibleHTTP{
+				Settings: settings{IssuanceDate: "yesterday"},
+				Provider: provider{URL: "https://example.com", Method: "GET"},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			},
+			expectedProblems: 1,
+		},
+		{
+			name: "Issuance date field without provider issuance date",
+			config: FlexibleHTTP{
+				Settings: settings{IssuanceDate: IssuanceDateOriginal, IssuanceDateField: "balance"},
+				Provider: provider{URL: "https://example.com", Method: "GET"},
+				ResponseSchema: responseSchema{Properties: map[string]matchedField{
+					"result": {Type: "string", MatchTo: "credentialSubject.balance"},
+				}},
+			},
+			expectedProblems: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Len(t, tt.config.Validate(), tt.expectedProblems)
+		})
+	}
+}
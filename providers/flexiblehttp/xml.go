@@ -0,0 +1,195 @@
+package flexiblehttp
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// xmlNode is an element of a decoded XML response. Names are local names,
+// namespace prefixes are dropped, so paths don't depend on the prefixes a
+// SOAP server picks.
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	text     strings.Builder
+	children []*xmlNode
+}
+
+func decodeXML(r io.Reader) (*xmlNode, error) {
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, attr := range t.Attr {
+				node.attrs[attr.Name.Local] = attr.Value
+			}
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			parent.text.Write(t)
+		}
+	}
+	if len(root.children) == 0 {
+		return nil, errors.New("empty XML document")
+	}
+	return root, nil
+}
+
+// xpathStep is one step of the supported XPath subset: an element name or
+// '*' with an optional 1-based position, or a final '@attribute' or 'text()'.
+type xpathStep struct {
+	name      string
+	position  int
+	attribute string
+	text      bool
+}
+
+// compileXPath parses absolute paths like '/Envelope/Body/Response/Balance',
+// '//Balance[2]' and '//Account/@currency'. A leading '//' searches the
+// first step among all descendants.
+func compileXPath(expr string) (steps []xpathStep, descendant bool, err error) {
+	switch {
+	case strings.HasPrefix(expr, "//"):
+		descendant = true
+		expr = expr[2:]
+	case strings.HasPrefix(expr, "/"):
+		expr = expr[1:]
+	default:
+		return nil, false, errors.Errorf("xpath '%s' is not absolute", expr)
+	}
+	parts := strings.Split(expr, "/")
+	for i, part := range parts {
+		last := i == len(parts)-1
+		switch {
+		case part == "":
+			return nil, false, errors.Errorf("xpath '%s' has an empty step", expr)
+		case part == "text()" && last:
+			steps = append(steps, xpathStep{text: true})
+		case strings.HasPrefix(part, "@") && last && len(part) > 1:
+			steps = append(steps, xpathStep{attribute: part[1:]})
+		default:
+			step := xpathStep{name: part}
+			if start := strings.Index(part, "["); start != -1 {
+				if !strings.HasSuffix(part, "]") {
+					return nil, false, errors.Errorf("invalid step '%s'", part)
+				}
+				position, err := strconv.Atoi(part[start+1 : len(part)-1])
+				if err != nil || position < 1 {
+					return nil, false, errors.Errorf("invalid position in step '%s'", part)
+				}
+				step.name, step.position = part[:start], position
+			}
+			if step.name == "" || strings.ContainsAny(step.name, "()@[]") {
+				return nil, false, errors.Errorf("unsupported step '%s'", part)
+			}
+			steps = append(steps, step)
+		}
+	}
+	return steps, descendant, nil
+}
+
+// evaluate returns the text of the first node expr selects.
+func (n *xmlNode) evaluate(expr string) (string, error) {
+	steps, descendant, err := compileXPath(expr)
+	if err != nil {
+		return "", err
+	}
+	nodes := []*xmlNode{n}
+	for i, step := range steps {
+		switch {
+		case step.attribute != "":
+			v, ok := nodes[0].attrs[step.attribute]
+			if !ok {
+				return "", errors.Errorf("attribute '%s' not found", step.attribute)
+			}
+			return v, nil
+		case step.text:
+			return strings.TrimSpace(nodes[0].text.String()), nil
+		}
+
+		var next []*xmlNode
+		for _, node := range nodes {
+			var candidates []*xmlNode
+			if i == 0 && descendant {
+				candidates = node.descendants(step.name)
+			} else {
+				candidates = node.childrenNamed(step.name)
+			}
+			if step.position > 0 {
+				if step.position > len(candidates) {
+					continue
+				}
+				candidates = candidates[step.position-1 : step.position]
+			}
+			next = append(next, candidates...)
+		}
+		if len(next) == 0 {
+			return "", errors.Errorf("no node for step '%s'", step.name)
+		}
+		nodes = next
+	}
+	return strings.TrimSpace(nodes[0].text.String()), nil
+}
+
+func (n *xmlNode) childrenNamed(name string) []*xmlNode {
+	var nodes []*xmlNode
+	for _, child := range n.children {
+		if name == "*" || child.name == name {
+			nodes = append(nodes, child)
+		}
+	}
+	return nodes
+}
+
+func (n *xmlNode) descendants(name string) []*xmlNode {
+	var nodes []*xmlNode
+	for _, child := range n.children {
+		if name == "*" || child.name == name {
+			nodes = append(nodes, child)
+		}
+		nodes = append(nodes, child.descendants(name)...)
+	}
+	return nodes
+}
+
+// decodeXMLResponse maps the response to credential subject fields. The
+// response schema properties are XPath expressions.
+func (fh *FlexibleHTTP) decodeXMLResponse(r io.Reader) (map[string]interface{}, error) {
+	document, err := decodeXML(r)
+	if err != nil {
+		return nil, errors.Wrapf(ErrDataProviderIssue, "failed to decode response: %v", err)
+	}
+	parsedFields := make(map[string]interface{}, len(fh.ResponseSchema.Properties))
+	for expr, field := range fh.ResponseSchema.Properties {
+		p := strings.Split(field.MatchTo, ".")
+		if len(p) != 2 {
+			return nil, errors.Wrapf(ErrInvalidResponseSchema, "invalid match field for '%s'", expr)
+		}
+		value, err := document.evaluate(expr)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidResponseSchema, "'%s': %v", expr, err)
+		}
+		parsedFields[p[1]], err = stringToType(value, field.Type)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidResponseSchema, "'%s': %v", expr, err)
+		}
+	}
+	return parsedFields, nil
+}
@@ -0,0 +1,93 @@
+package flexiblehttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const soapResponse = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:reg="urn:registry">
+  <soap:Body>
+    <reg:GetPersonResponse>
+      <reg:Person status="active">
+        <reg:Name> Alice </reg:Name>
+        <reg:Age>42</reg:Age>
+      </reg:Person>
+      <reg:Person status="inactive">
+        <reg:Name>Bob</reg:Name>
+      </reg:Person>
+    </reg:GetPersonResponse>
+  </soap:Body>
+</soap:Envelope>`
+
+func TestXPath(t *testing.T) {
+	document, err := decodeXML(strings.NewReader(soapResponse))
+	require.NoError(t, err)
+
+	tests := []struct {
+		expr     string
+		expected string
+		err      bool
+	}{
+		{expr: "/Envelope/Body/GetPersonResponse/Person/Name", expected: "Alice"},
+		{expr: "//Person[2]/Name", expected: "Bob"},
+		{expr: "//Person[2]/@status", expected: "inactive"},
+		{expr: "//Age/text()", expected: "42"},
+		{expr: "/Envelope/*/GetPersonResponse/Person/Age", expected: "42"},
+		{expr: "//Person[3]/Name", err: true},
+		{expr: "//Person/@missing", err: true},
+		{expr: "Envelope/Body", err: true},
+		{expr: "//Person[0]", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			value, err := document.evaluate(tt.expr)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestProvide_SOAP(t *testing.T) {
+	var requestBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		requestBody = string(b)
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(soapResponse))
+	}))
+	defer srv.Close()
+
+	provider := FlexibleHTTP{
+		httpcli:  srv.Client(),
+		Provider: provider{URL: srv.URL, Method: http.MethodPost},
+		RequestSchema: requestSchema{
+			Headers: map[string]string{"Content-Type": "text/xml; charset=utf-8"},
+			Body:    `<Envelope><Body><GetPerson><Id>{{ credentialSubject.personId }}</Id></GetPerson></Body></Envelope>`,
+		},
+		ResponseSchema: responseSchema{
+			Type: responseTypeXML,
+			Properties: map[string]matchedField{
+				"//Person[1]/Name": {Type: "string", MatchTo: "credentialSubject.name"},
+				"//Person[1]/Age":  {Type: "integer", MatchTo: "credentialSubject.age"},
+			},
+		},
+	}
+	require.Empty(t, provider.Validate())
+
+	updatedFields, err := provider.Provide(context.Background(), map[string]interface{}{"personId": "a&b"})
+	require.NoError(t, err)
+	require.Equal(t, `<Envelope><Body><GetPerson><Id>a&amp;b</Id></GetPerson></Body></Envelope>`, requestBody)
+	require.Equal(t, map[string]interface{}{"name": "Alice", "age": 42}, updatedFields)
+}
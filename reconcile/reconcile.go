@@ -0,0 +1,225 @@
+// Package reconcile compares the provider fields cached for sampled
+// subjects with the live data providers, catching providers which silently
+// changed their data or response format while cached values are served.
+package reconcile
+
+import (
+	"context"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/lock"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/metrics"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/pkg/errors"
+)
+
+// Results of a check.
+const (
+	ResultMatch       = "match"
+	ResultDrift       = "drift"
+	ResultFormatError = "format_error"
+	ResultError       = "error"
+)
+
+const lockKey = "reconcile"
+
+type Option func(*Reconciler)
+
+// WithInterval sets how often the sampled subjects are reconciled.
+func WithInterval(interval time.Duration) Option {
+	return func(r *Reconciler) {
+		if interval > 0 {
+			r.interval = interval
+		}
+	}
+}
+
+// WithSampleSize sets how many subjects of each credential type are
+// reconciled per run.
+func WithSampleSize(size int) Option {
+	return func(r *Reconciler) {
+		if size > 0 {
+			r.sampleSize = size
+		}
+	}
+}
+
+// WithLocker lets only one replica reconcile at a time, so providers are
+// not called by every replica.
+func WithLocker(locker lock.Locker) Option {
+	return func(r *Reconciler) {
+		r.locker = locker
+	}
+}
+
+// Check is the reconciliation of a sampled subject.
+type Check struct {
+	CredentialType string
+	Result         string
+	Drifts         []flexiblehttp.Drift
+	Err            error
+}
+
+// Reconciler samples the subjects served from the provider cache, see
+// flexiblehttp.WithSampler, and compares their cached fields with the live
+// providers on every interval. Each credential type keeps a sample of the
+// subjects served since the last run, of at most the sample size.
+type Reconciler struct {
+	interval   time.Duration
+	sampleSize int
+	locker     lock.Locker
+
+	mu         sync.Mutex
+	reservoirs map[string]*reservoir
+}
+
+// reservoir is a sample of the subjects of a credential type, seen counts
+// the subjects offered to it. Sampled subjects are not offered again, the
+// others are as often as they are served, so busy subjects are more likely
+// to be sampled.
+type reservoir struct {
+	seen    int
+	samples []sample
+	keys    map[string]int
+}
+
+type sample struct {
+	source  *flexiblehttp.FactoryFlexibleHTTP
+	key     string
+	subject map[string]interface{}
+}
+
+func NewReconciler(opts ...Option) *Reconciler {
+	r := &Reconciler{
+		interval:   time.Hour,
+		sampleSize: 10,
+		reservoirs: make(map[string]*reservoir),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Sample offers a subject served from the provider cache of source to the
+// sample of credentialType.
+func (r *Reconciler) Sample(
+	source *flexiblehttp.FactoryFlexibleHTTP,
+	credentialType, key string,
+	credentialSubject map[string]interface{},
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res, ok := r.reservoirs[credentialType]
+	if !ok {
+		res = &reservoir{keys: make(map[string]int)}
+		r.reservoirs[credentialType] = res
+	}
+	if _, ok := res.keys[key]; ok {
+		return
+	}
+	res.seen++
+	slot := len(res.samples)
+	if slot >= r.sampleSize {
+		if slot = rand.IntN(res.seen); slot >= r.sampleSize {
+			return
+		}
+		delete(res.keys, res.samples[slot].key)
+	}
+	s := sample{source: source, key: key, subject: maps.Clone(credentialSubject)}
+	if slot == len(res.samples) {
+		res.samples = append(res.samples, s)
+	} else {
+		res.samples[slot] = s
+	}
+	res.keys[key] = slot
+}
+
+// Run reconciles on every interval until ctx ends.
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		checks, err := r.Reconcile(ctx, time.Now())
+		if err != nil {
+			logger.DefaultLogger.Errorf("failed to reconcile provider cache: %v", err)
+		}
+		for _, check := range checks {
+			switch check.Result {
+			case ResultDrift:
+				for _, drift := range check.Drifts {
+					logger.DefaultLogger.Warnw("cached provider field drifted from the provider",
+						"credentialType", check.CredentialType, "field", drift.Field, "kind", drift.Kind)
+				}
+			case ResultFormatError, ResultError:
+				logger.DefaultLogger.Warnw("failed to reconcile provider cache",
+					"credentialType", check.CredentialType, "error", check.Err)
+			}
+		}
+	}
+}
+
+// Reconcile compares the subjects sampled since the last run with the live
+// providers and starts a new sample. Subjects no longer cached are not
+// checked. It is a no-op when another replica holds the lock, the sample
+// is kept for the next run.
+func (r *Reconciler) Reconcile(ctx context.Context, now time.Time) ([]Check, error) {
+	if r.locker != nil {
+		lease, err := r.locker.Acquire(ctx, lockKey, r.interval)
+		if errors.Is(err, lock.ErrNotAcquired) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = lease.Release(context.Background())
+		}()
+	}
+
+	r.mu.Lock()
+	reservoirs := r.reservoirs
+	r.reservoirs = make(map[string]*reservoir)
+	r.mu.Unlock()
+
+	var checks []Check
+	for _, credentialType := range slices.Sorted(maps.Keys(reservoirs)) {
+		typeCtx := httpclient.WithCredentialType(ctx, credentialType)
+		for _, s := range reservoirs[credentialType].samples {
+			if ctx.Err() != nil {
+				return checks, ctx.Err()
+			}
+			drifts, ok, err := s.source.Reconcile(typeCtx, credentialType, s.subject)
+			if !ok {
+				continue
+			}
+			check := Check{CredentialType: credentialType, Result: ResultMatch, Drifts: drifts, Err: err}
+			switch {
+			case errors.Is(err, flexiblehttp.ErrInvalidResponseSchema):
+				check.Result = ResultFormatError
+			case err != nil:
+				check.Result = ResultError
+			case len(drifts) > 0:
+				check.Result = ResultDrift
+			}
+			metrics.ReconcileChecks.WithLabelValues(credentialType, check.Result).Inc()
+			for _, drift := range drifts {
+				metrics.ReconcileDrifts.WithLabelValues(credentialType, drift.Field, drift.Kind).Inc()
+			}
+			checks = append(checks, check)
+		}
+	}
+	metrics.ReconcileLastRun.Set(float64(now.Unix()))
+	return checks, nil
+}
@@ -0,0 +1,89 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providercache"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciler_Sample(t *testing.T) {
+	r := NewReconciler(WithSampleSize(3))
+	for i := range 100 {
+		key := fmt.Sprintf("did:iden3:%d", i)
+		r.Sample(nil, "Balance", key, map[string]interface{}{"id": key})
+	}
+	r.Sample(nil, "KYCAge", "did:iden3:0", map[string]interface{}{"id": "did:iden3:0"})
+
+	balance := r.reservoirs["Balance"]
+	require.Equal(t, 100, balance.seen)
+	require.Len(t, balance.samples, 3)
+	require.Len(t, balance.keys, 3)
+	for key, slot := range balance.keys {
+		require.Equal(t, key, balance.samples[slot].key)
+		// sampled subjects are not offered again
+		r.Sample(nil, "Balance", key, map[string]interface{}{"id": key})
+	}
+	require.Equal(t, 100, balance.seen)
+	require.Len(t, r.reservoirs["KYCAge"].samples, 1)
+}
+
+func TestReconciler_Reconcile(t *testing.T) {
+	responses := map[string]string{"owner": `{"result": "100"}`, "other": `{"result": "7"}`, "third": `{"result": "1"}`}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(responses[strings.TrimPrefix(r.URL.Path, "/")]))
+	}))
+	defer srv.Close()
+
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(strings.TrimSpace(`
+Balance:
+  settings:
+    cacheTTL: 1h
+  provider:
+    url: `+srv.URL+`/{{ credentialSubject.id }}
+  responseSchema:
+    properties:
+      result:
+        type: string
+        match: credentialSubject.balance
+`)), 0o600))
+	reconciler := NewReconciler()
+	factory, err := flexiblehttp.NewFactoryFlexibleHTTP(config, srv.Client(),
+		flexiblehttp.WithCache(providercache.NewMemory()), flexiblehttp.WithSampler(reconciler))
+	require.NoError(t, err)
+	fh, err := factory.ProduceFlexibleHTTP("Balance")
+	require.NoError(t, err)
+	ctx := context.Background()
+	for _, id := range []string{"owner", "other", "third"} {
+		_, err = fh.Provide(ctx, map[string]interface{}{"id": id})
+		require.NoError(t, err)
+	}
+
+	// the provider renamed its field for some subjects
+	responses["owner"] = `{"result": "150"}`
+	responses["other"] = `{"balance": "7"}`
+	checks, err := reconciler.Reconcile(ctx, time.Now())
+	require.NoError(t, err)
+	require.Len(t, checks, 3)
+	results := make(map[string]int)
+	for _, check := range checks {
+		require.Equal(t, "Balance", check.CredentialType)
+		results[check.Result]++
+	}
+	require.Equal(t, map[string]int{ResultMatch: 1, ResultDrift: 1, ResultFormatError: 1}, results)
+
+	// every run starts a new sample
+	checks, err = reconciler.Reconcile(ctx, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, checks)
+}
@@ -0,0 +1,77 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Reporter sends failures to an external error sink.
+type Reporter interface {
+	// Report sends err grouped by the refresh service error code.
+	Report(ctx context.Context, err error, code int)
+	// Recover sends a value recovered from a panic.
+	Recover(ctx context.Context, recovered interface{})
+	Flush(timeout time.Duration) bool
+}
+
+var DefaultReporter Reporter = nopReporter{}
+
+type nopReporter struct{}
+
+func (nopReporter) Report(context.Context, error, int) {}
+
+func (nopReporter) Recover(context.Context, interface{}) {}
+
+func (nopReporter) Flush(time.Duration) bool { return true }
+
+var (
+	didPattern         = regexp.MustCompile(`did:[a-z0-9]+:[A-Za-z0-9:._%-]+`)
+	subjectPattern     = regexp.MustCompile(`(?s)(credentialSubject|subject)(["']?\s*[:=]\s*)(map\[.*?\]|\{.*?\})`)
+	sensitiveFieldKeys = []string{
+		"credential",
+		"credentialsubject",
+		"subject",
+		"vc",
+		"body",
+		"envelope",
+		"authorization",
+	}
+)
+
+const redacted = "[redacted]"
+
+// Scrub removes DIDs and credential subject dumps from s.
+func Scrub(s string) string {
+	s = subjectPattern.ReplaceAllString(s, "${1}${2}"+redacted)
+	return didPattern.ReplaceAllString(s, "did:"+redacted)
+}
+
+// ScrubFields replaces values of keys which may hold credential data.
+func ScrubFields(fields map[string]interface{}) {
+	for k, v := range fields {
+		if isSensitiveKey(k) {
+			fields[k] = redacted
+			continue
+		}
+		if s, ok := v.(string); ok {
+			fields[k] = Scrub(s)
+		}
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveFieldKeys {
+		if key == sensitive {
+			return true
+		}
+	}
+	return false
+}
+
+func fingerprint(code int) []string {
+	return []string{"refresh-service", fmt.Sprintf("code-%d", code)}
+}
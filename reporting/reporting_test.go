@@ -0,0 +1,56 @@
+package reporting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrub(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "DID in error message",
+			input:    "credential 'abc': not owner did:polygonid:polygon:amoy:2qHYafoww8yJcMhXk5jvgL33QuDGaasaqwjjVUXDP1",
+			expected: "credential 'abc': not owner did:[redacted]",
+		},
+		{
+			name:     "Subject map dump",
+			input:    "subject: map[balance:100 id:x] failed",
+			expected: "subject: [redacted] failed",
+		},
+		{
+			name:     "Subject JSON dump",
+			input:    `"credentialSubject": {"birthday": 19960424}`,
+			expected: `"credentialSubject": [redacted]`,
+		},
+		{
+			name:     "Nothing to scrub",
+			input:    "unexpected status code '500'",
+			expected: "unexpected status code '500'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, Scrub(tt.input))
+		})
+	}
+}
+
+func TestScrubFields(t *testing.T) {
+	fields := map[string]interface{}{
+		"credentialSubject": map[string]interface{}{"balance": 1},
+		"issuer":            "did:iden3:polygon:amoy:xFZ5sUp9y7cHfkz3pAk1Z4HNQTvCWQsTRBHk8Pjnq",
+		"code":              3001,
+	}
+	ScrubFields(fields)
+	require.Equal(t, map[string]interface{}{
+		"credentialSubject": "[redacted]",
+		"issuer":            "did:[redacted]",
+		"code":              3001,
+	}, fields)
+}
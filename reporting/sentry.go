@@ -0,0 +1,73 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/pkg/errors"
+)
+
+type SentryReporter struct{}
+
+func NewSentryReporter(dsn, environment string) (*SentryReporter, error) {
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:            dsn,
+		Environment:    environment,
+		SendDefaultPII: false,
+		BeforeSend:     scrubEvent,
+	})
+	if err != nil {
+		return nil, errors.Errorf("failed to init sentry client: %v", err)
+	}
+	return &SentryReporter{}, nil
+}
+
+func (sr *SentryReporter) Report(ctx context.Context, err error, code int) {
+	hub := hubFromContext(ctx)
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("code", strconv.Itoa(code))
+		scope.SetFingerprint(fingerprint(code))
+		hub.CaptureException(err)
+	})
+}
+
+func (sr *SentryReporter) Recover(ctx context.Context, recovered interface{}) {
+	hub := hubFromContext(ctx)
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("code", "panic")
+		scope.SetFingerprint([]string{"refresh-service", "panic", fmt.Sprintf("%T", recovered)})
+		hub.RecoverWithContext(ctx, recovered)
+	})
+}
+
+func (sr *SentryReporter) Flush(timeout time.Duration) bool {
+	return sentry.Flush(timeout)
+}
+
+func hubFromContext(ctx context.Context) *sentry.Hub {
+	if hub := sentry.GetHubFromContext(ctx); hub != nil {
+		return hub
+	}
+	return sentry.CurrentHub().Clone()
+}
+
+func scrubEvent(event *sentry.Event, _ *sentry.EventHint) *sentry.Event {
+	event.Message = Scrub(event.Message)
+	for i := range event.Exception {
+		event.Exception[i].Value = Scrub(event.Exception[i].Value)
+	}
+	if event.Request != nil {
+		event.Request.Data = ""
+		event.Request.Cookies = ""
+		delete(event.Request.Headers, "Authorization")
+	}
+	ScrubFields(event.Extra)
+	for _, b := range event.Breadcrumbs {
+		b.Message = Scrub(b.Message)
+		ScrubFields(b.Data)
+	}
+	return event
+}
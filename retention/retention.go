@@ -0,0 +1,157 @@
+package retention
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/lock"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/pkg/errors"
+)
+
+// Tables retention periods can be set for.
+const (
+	TableRefreshHistory  = "refresh_history"
+	TableLineage         = "lineage"
+	TableJobs            = "jobs"
+	TableIdempotencyKeys = "idempotency_keys"
+)
+
+const lockKey = "retention"
+
+// ParsePeriods parses retention periods keyed by table, e.g.
+// {"jobs": "720h"}.
+func ParsePeriods(values map[string]string) (map[string]time.Duration, error) {
+	periods := make(map[string]time.Duration, len(values))
+	for table, value := range values {
+		switch table {
+		case TableRefreshHistory, TableLineage, TableJobs, TableIdempotencyKeys:
+		default:
+			return nil, errors.Errorf("unknown table '%s'", table)
+		}
+		period, err := time.ParseDuration(value)
+		if err != nil || period < 0 {
+			return nil, errors.Errorf("invalid retention '%s' of table '%s'", value, table)
+		}
+		periods[table] = period
+	}
+	return periods, nil
+}
+
+type Option func(*Cleaner)
+
+// WithInterval sets how often the cleanup runs.
+func WithInterval(interval time.Duration) Option {
+	return func(c *Cleaner) {
+		if interval > 0 {
+			c.interval = interval
+		}
+	}
+}
+
+// WithLocker lets only one replica clean up at a time.
+func WithLocker(locker lock.Locker) Option {
+	return func(c *Cleaner) {
+		c.locker = locker
+	}
+}
+
+// Cleaner deletes persisted state older than its retention period, so the
+// database doesn't grow unbounded. Tables without a period, or with a zero
+// one, are kept forever, except idempotency keys: they are always deleted
+// once expired, their period is kept on top.
+type Cleaner struct {
+	store    storage.Retention
+	periods  map[string]time.Duration
+	interval time.Duration
+	locker   lock.Locker
+}
+
+func NewCleaner(store storage.Retention, periods map[string]time.Duration, opts ...Option) *Cleaner {
+	c := &Cleaner{
+		store:    store,
+		periods:  periods,
+		interval: time.Hour,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run cleans up on every interval until ctx ends.
+func (c *Cleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		deleted, err := c.Clean(ctx, time.Now())
+		if err != nil {
+			logger.DefaultLogger.Errorf("failed to clean up persisted state: %v", err)
+		}
+		for _, table := range sortedTables(deleted) {
+			logger.DefaultLogger.Infow("deleted records past retention", "table", table, "count", deleted[table])
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Clean deletes the records past retention at now and returns the number
+// of deleted records by table. A failing table doesn't stop the others,
+// the first error is returned.
+func (c *Cleaner) Clean(ctx context.Context, now time.Time) (map[string]int64, error) {
+	if c.locker != nil {
+		lease, err := c.locker.Acquire(ctx, lockKey, c.interval)
+		if errors.Is(err, lock.ErrNotAcquired) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = lease.Release(context.Background())
+		}()
+	}
+
+	deletes := []struct {
+		table  string
+		delete func(ctx context.Context, before time.Time) (int64, error)
+	}{
+		{table: TableRefreshHistory, delete: c.store.DeleteRefreshes},
+		{table: TableLineage, delete: c.store.DeleteLineage},
+		{table: TableJobs, delete: c.store.DeleteFinishedJobs},
+		{table: TableIdempotencyKeys, delete: c.store.DeleteExpiredIdempotency},
+	}
+	var (
+		deleted  = make(map[string]int64)
+		firstErr error
+	)
+	for _, d := range deletes {
+		period := c.periods[d.table]
+		if period == 0 && d.table != TableIdempotencyKeys {
+			continue
+		}
+		count, err := d.delete(ctx, now.Add(-period))
+		if count > 0 {
+			deleted[d.table] = count
+		}
+		if err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "table '%s'", d.table)
+		}
+	}
+	return deleted, firstErr
+}
+
+func sortedTables(deleted map[string]int64) []string {
+	tables := make([]string, 0, len(deleted))
+	for table := range deleted {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
@@ -0,0 +1,104 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePeriods(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string]string
+		expected map[string]time.Duration
+		err      string
+	}{
+		{
+			name:     "Valid",
+			values:   map[string]string{"jobs": "720h", "refresh_history": "0s"},
+			expected: map[string]time.Duration{"jobs": 720 * time.Hour, "refresh_history": 0},
+		},
+		{name: "Unknown table", values: map[string]string{"users": "1h"}, err: "unknown table 'users'"},
+		{name: "Invalid period", values: map[string]string{"lineage": "a year"}, err: "invalid retention 'a year' of table 'lineage'"},
+		{name: "Negative period", values: map[string]string{"jobs": "-1h"}, err: "invalid retention '-1h' of table 'jobs'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			periods, err := ParsePeriods(tt.values)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, periods)
+		})
+	}
+}
+
+func TestClean(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	store := memory.NewStore()
+	for _, age := range []time.Duration{48 * time.Hour, time.Hour} {
+		require.NoError(t, store.SaveRefresh(ctx, storage.RefreshRecord{CredentialID: age.String(), CreatedAt: now.Add(-age)}))
+		require.NoError(t, store.SaveLineage(ctx, storage.LineageRecord{CredentialID: age.String(), CreatedAt: now.Add(-age)}))
+	}
+	require.NoError(t, store.SaveJob(ctx, storage.Job{ID: "done", Status: storage.JobStatusSucceeded}))
+	require.NoError(t, store.SaveJob(ctx, storage.Job{ID: "pending", Status: storage.JobStatusPending}))
+	_, err := store.PutIdempotency(ctx, storage.IdempotencyRecord{Key: "expired", ExpiresAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	_, err = store.PutIdempotency(ctx, storage.IdempotencyRecord{Key: "valid", ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+
+	cleaner := NewCleaner(store, map[string]time.Duration{
+		TableRefreshHistory: 24 * time.Hour,
+		// jobs finished just now are kept for a minute
+		TableJobs: time.Minute,
+	})
+	deleted, err := cleaner.Clean(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{TableRefreshHistory: 1, TableIdempotencyKeys: 1}, deleted)
+
+	deleted, err = cleaner.Clean(ctx, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{TableJobs: 1}, deleted)
+
+	refreshes, err := store.ListRefreshes(ctx, storage.RefreshFilter{})
+	require.NoError(t, err)
+	require.Len(t, refreshes, 1)
+	require.Equal(t, "1h0m0s", refreshes[0].CredentialID)
+	// lineage has no period and is kept
+	lineage, err := store.ListLineage(ctx, now.Add(-72*time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, lineage, 2)
+	_, err = store.GetJob(ctx, "pending")
+	require.NoError(t, err)
+	_, err = store.GetIdempotency(ctx, "valid")
+	require.NoError(t, err)
+}
+
+type failingStore struct {
+	storage.Retention
+}
+
+func (failingStore) DeleteRefreshes(context.Context, time.Time) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (failingStore) DeleteExpiredIdempotency(context.Context, time.Time) (int64, error) {
+	return 3, nil
+}
+
+func TestClean_Error(t *testing.T) {
+	cleaner := NewCleaner(failingStore{}, map[string]time.Duration{TableRefreshHistory: time.Hour})
+	deleted, err := cleaner.Clean(context.Background(), time.Now())
+	require.EqualError(t, err, "table 'refresh_history': connection refused")
+	// the other tables are still cleaned up
+	require.Equal(t, map[string]int64{TableIdempotencyKeys: 3}, deleted)
+}
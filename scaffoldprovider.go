@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/pkg/errors"
+)
+
+// scaffoldProvider prints a draft provider configuration for an operation
+// of the OpenAPI document of an upstream.
+func scaffoldProvider(args []string) error {
+	fs := flag.NewFlagSet("scaffold-provider", flag.ContinueOnError)
+	spec := fs.String("openapi", "", "OpenAPI 3 document of the upstream, a file or an http(s) URL")
+	credentialType := fs.String("type", "", "credential type of the provider configuration")
+	path := fs.String("path", "", "path of the operation, needed when the document has several GET operations")
+	method := fs.String("method", "", "method of the operation, GET by default")
+	server := fs.String("server", "", "base URL of the provider, the first server of the document by default")
+	out := fs.String("out", "", "file to write the configuration to, stdout by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *spec == "" || *credentialType == "" {
+		fs.Usage()
+		return errors.New("--openapi and --type are required")
+	}
+	if _, err := loadCommandConfig(); err != nil {
+		return err
+	}
+
+	document, err := readOpenAPI(*spec)
+	if err != nil {
+		return errors.Errorf("failed to read OpenAPI document: %v", err)
+	}
+	cfg, err := flexiblehttp.ScaffoldOpenAPI(document, flexiblehttp.OpenAPIOptions{
+		CredentialType: *credentialType,
+		Path:           *path,
+		Method:         *method,
+		Server:         *server,
+	})
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(cfg)
+		return err
+	}
+	if err := os.WriteFile(*out, cfg, 0o600); err != nil {
+		return errors.Errorf("failed to write provider configuration: %v", err)
+	}
+	printf("provider configuration written to %s\n", *out)
+	return nil
+}
+
+func readOpenAPI(spec string) ([]byte, error) {
+	if !strings.HasPrefix(spec, "http://") && !strings.HasPrefix(spec, "https://") {
+		//nolint:gosec // the path is given by the operator
+		return os.ReadFile(spec)
+	}
+	request, err := http.NewRequestWithContext(context.Background(), http.MethodGet, spec, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpclient.NewClient(httpclient.DefaultOptions, 0).Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status code '%d'", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+}
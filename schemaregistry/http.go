@@ -0,0 +1,73 @@
+package schemaregistry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxDocumentSize caps the documents read from the schema registry.
+const maxDocumentSize = 10 * 1024 * 1024
+
+// HTTPBackend resolves documents with the HTTP API of the schema registry:
+// GET <base url>/schemas/<id>/versions/<version>, with 'latest' for the
+// latest version.
+type HTTPBackend struct {
+	baseURL string
+	httpcli *http.Client
+	token   string
+}
+
+// NewHTTPBackend returns the backend of the schema registry at baseURL.
+// A non-empty token is sent as a bearer token.
+func NewHTTPBackend(baseURL string, httpcli *http.Client, token string) *HTTPBackend {
+	if httpcli == nil {
+		httpcli = http.DefaultClient
+	}
+	return &HTTPBackend{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		httpcli: httpcli,
+		token:   token,
+	}
+}
+
+func (b *HTTPBackend) Resolve(ctx context.Context, ref Ref) ([]byte, error) {
+	version := ref.Version
+	if version == "" {
+		version = "latest"
+	}
+	u := fmt.Sprintf("%s/schemas/%s/versions/%s", b.baseURL, url.PathEscape(ref.ID), url.PathEscape(version))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/ld+json, application/json")
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	resp, err := b.httpcli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errors.Wrapf(ErrNotFound, "'%s'", ref)
+	case resp.StatusCode != http.StatusOK:
+		return nil, errors.Errorf("schema registry returned status code '%d'", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxDocumentSize {
+		return nil, errors.Errorf("schema registry document '%s' is larger than %d bytes", ref, maxDocumentSize)
+	}
+	return body, nil
+}
@@ -0,0 +1,145 @@
+// Package schemaregistry resolves JSON-LD schemas and contexts from a
+// schema registry service by id and version instead of their URLs.
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/loaders"
+	"github.com/piprate/json-gold/ld"
+	"github.com/pkg/errors"
+)
+
+// Scheme of URIs resolved by the schema registry, e.g.
+// 'schema-registry:KYCAgeCredential@1.2.0'.
+const Scheme = "schema-registry"
+
+// ErrNotFound is returned by backends for unknown documents.
+var ErrNotFound = errors.New("document not found in schema registry")
+
+// Ref identifies a document of the schema registry. An empty Version is the
+// latest version.
+type Ref struct {
+	ID      string
+	Version string
+}
+
+// ParseRef parses a reference in the 'id[@version]' format.
+func ParseRef(value string) (Ref, error) {
+	id, version, hasVersion := strings.Cut(strings.TrimSpace(value), "@")
+	if id == "" || (hasVersion && version == "") {
+		return Ref{}, errors.Errorf("invalid schema registry reference '%s': expected 'id[@version]'", value)
+	}
+	return Ref{ID: id, Version: version}, nil
+}
+
+func (r Ref) String() string {
+	if r.Version == "" {
+		return r.ID
+	}
+	return r.ID + "@" + r.Version
+}
+
+// Backend resolves documents of the schema registry.
+type Backend interface {
+	Resolve(ctx context.Context, ref Ref) ([]byte, error)
+}
+
+// Loader is an ld.DocumentLoader resolving schema-registry: URIs and
+// aliased URLs through a Backend and any other URL through the next loader.
+type Loader struct {
+	backend Backend
+	next    ld.DocumentLoader
+	aliases map[string]Ref
+	cache   loaders.CacheEngine
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+type Option func(*Loader)
+
+// WithAliases resolves the documents of URLs, e.g. the schema URLs of
+// issued credentials, from the schema registry.
+func WithAliases(aliases map[string]Ref) Option {
+	return func(l *Loader) {
+		l.aliases = aliases
+	}
+}
+
+// WithCache keeps resolved documents in cache for ttl.
+func WithCache(cache loaders.CacheEngine, ttl time.Duration) Option {
+	return func(l *Loader) {
+		l.cache = cache
+		l.ttl = ttl
+	}
+}
+
+// WithTimeout bounds a request to the schema registry. It defaults to 30s.
+func WithTimeout(timeout time.Duration) Option {
+	return func(l *Loader) {
+		l.timeout = timeout
+	}
+}
+
+func NewLoader(backend Backend, next ld.DocumentLoader, opts ...Option) *Loader {
+	l := &Loader{
+		backend: backend,
+		next:    next,
+		timeout: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func (l *Loader) LoadDocument(u string) (*ld.RemoteDocument, error) {
+	ref, ok, err := l.ref(u)
+	if err != nil {
+		return nil, ld.NewJsonLdError(ld.LoadingDocumentFailed, err)
+	}
+	if !ok {
+		return l.next.LoadDocument(u)
+	}
+
+	if l.cache != nil {
+		doc, expireTime, err := l.cache.Get(u)
+		if err == nil && expireTime.After(time.Now()) {
+			return doc, nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+	raw, err := l.backend.Resolve(ctx, ref)
+	if err != nil {
+		return nil, ld.NewJsonLdError(ld.LoadingDocumentFailed,
+			errors.Wrapf(err, "failed to resolve '%s' as '%s'", u, ref))
+	}
+	doc, err := ld.DocumentFromReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ld.NewJsonLdError(ld.LoadingDocumentFailed,
+			errors.Wrapf(err, "schema registry document '%s' is not JSON", ref))
+	}
+	// the document keeps the URL it was requested by, so relative
+	// references resolve as for a loaded one
+	document := &ld.RemoteDocument{DocumentURL: u, Document: doc}
+	if l.cache != nil {
+		if err := l.cache.Set(u, document, time.Now().Add(l.ttl)); err != nil {
+			return nil, ld.NewJsonLdError(ld.LoadingDocumentFailed, err)
+		}
+	}
+	return document, nil
+}
+
+// ref returns the schema registry reference of u, if it has one.
+func (l *Loader) ref(u string) (Ref, bool, error) {
+	if value, ok := strings.CutPrefix(u, Scheme+":"); ok {
+		ref, err := ParseRef(value)
+		return ref, err == nil, err
+	}
+	ref, ok := l.aliases[u]
+	return ref, ok, nil
+}
@@ -0,0 +1,143 @@
+package schemaregistry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/loadercache"
+	"github.com/piprate/json-gold/ld"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type nextLoader struct {
+	loaded []string
+}
+
+func (l *nextLoader) LoadDocument(u string) (*ld.RemoteDocument, error) {
+	l.loaded = append(l.loaded, u)
+	return &ld.RemoteDocument{DocumentURL: u, Document: map[string]interface{}{"from": "url"}}, nil
+}
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    Ref
+		expectedErr bool
+	}{
+		{name: "Versioned", value: "KYCAgeCredential@1.2.0", expected: Ref{ID: "KYCAgeCredential", Version: "1.2.0"}},
+		{name: "Latest", value: "KYCAgeCredential", expected: Ref{ID: "KYCAgeCredential"}},
+		{name: "Empty version", value: "KYCAgeCredential@", expectedErr: true},
+		{name: "Empty id", value: "@1.2.0", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := ParseRef(tt.value)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, ref)
+			require.Equal(t, tt.value, ref.String())
+		})
+	}
+}
+
+func TestLoader(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/schemas/KYCAgeCredential/versions/1.2.0", "/schemas/KYCAgeCredential/versions/latest":
+			_, _ = w.Write([]byte(`{"@context": {"from": "registry"}}`))
+		case "/schemas/Broken/versions/latest":
+			_, _ = w.Write([]byte(`not json`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	const aliased = "https://schemas.example.com/kyc-v1.jsonld"
+	tests := []struct {
+		name             string
+		url              string
+		expectedRequests []string
+		expectedNext     []string
+		expectedErr      bool
+		expectedErrIs    error
+	}{
+		{
+			name:             "Versioned reference",
+			url:              "schema-registry:KYCAgeCredential@1.2.0",
+			expectedRequests: []string{"/schemas/KYCAgeCredential/versions/1.2.0"},
+		},
+		{
+			name:             "Latest version",
+			url:              "schema-registry:KYCAgeCredential",
+			expectedRequests: []string{"/schemas/KYCAgeCredential/versions/latest"},
+		},
+		{
+			name:             "Aliased URL",
+			url:              aliased,
+			expectedRequests: []string{"/schemas/KYCAgeCredential/versions/1.2.0"},
+		},
+		{
+			name:         "Other URL",
+			url:          "https://www.w3.org/2018/credentials/v1",
+			expectedNext: []string{"https://www.w3.org/2018/credentials/v1"},
+		},
+		{
+			name:             "Unknown document",
+			url:              "schema-registry:Unknown@1.0.0",
+			expectedRequests: []string{"/schemas/Unknown/versions/1.0.0"},
+			expectedErr:      true,
+			expectedErrIs:    ErrNotFound,
+		},
+		{
+			name:             "Malformed document",
+			url:              "schema-registry:Broken",
+			expectedRequests: []string{"/schemas/Broken/versions/latest"},
+			expectedErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			next := &nextLoader{}
+			loader := NewLoader(NewHTTPBackend(srv.URL+"/", nil, "secret"), next,
+				WithAliases(map[string]Ref{aliased: {ID: "KYCAgeCredential", Version: "1.2.0"}}),
+				WithCache(loadercache.NewMemory(), time.Hour),
+			)
+
+			doc, err := loader.LoadDocument(tt.url)
+			require.Equal(t, tt.expectedRequests, requests)
+			require.Equal(t, tt.expectedNext, next.loaded)
+			if tt.expectedErr {
+				require.Error(t, err)
+				if tt.expectedErrIs != nil {
+					require.True(t, errors.Is(err, tt.expectedErrIs), err.Error())
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.url, doc.DocumentURL)
+			if tt.expectedNext != nil {
+				return
+			}
+			require.Equal(t, map[string]interface{}{"@context": map[string]interface{}{"from": "registry"}}, doc.Document)
+
+			// the second load is served by the cache
+			_, err = loader.LoadDocument(tt.url)
+			require.NoError(t, err)
+			require.Len(t, requests, 1)
+		})
+	}
+}
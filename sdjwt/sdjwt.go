@@ -0,0 +1,167 @@
+package sdjwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MediaType is the JWT typ of SD-JWT VCs.
+	MediaType = "dc+sd-jwt"
+	hashAlg   = "sha-256"
+	saltSize  = 16
+)
+
+var b64 = base64.RawURLEncoding
+
+// Issuer converts W3C credentials into SD-JWT VCs signed with an ES256 key.
+// Every credential subject field except the subject id becomes a
+// selectively disclosable claim.
+type Issuer struct {
+	key    *ecdsa.PrivateKey
+	issuer string
+	keyID  string
+	now    func() time.Time
+}
+
+func NewIssuer(key *ecdsa.PrivateKey, issuer, keyID string) (*Issuer, error) {
+	if key.Curve != elliptic.P256() {
+		return nil, errors.New("SD-JWT signing key must be a P-256 key")
+	}
+	return &Issuer{key: key, issuer: issuer, keyID: keyID, now: time.Now}, nil
+}
+
+// LoadIssuer reads a PEM encoded P-256 private key in SEC 1 or PKCS #8 form.
+func LoadIssuer(keyFile, issuer, keyID string) (*Issuer, error) {
+	//nolint:gosec // key path comes from the service configuration
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Errorf("failed to read SD-JWT signing key '%s': %v", keyFile, err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.Errorf("no PEM data found in '%s'", keyFile)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		pkcs8, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if pkcs8Err != nil {
+			return nil, errors.Errorf("failed to parse SD-JWT signing key: %v", err)
+		}
+		var ok bool
+		if key, ok = pkcs8.(*ecdsa.PrivateKey); !ok {
+			return nil, errors.New("SD-JWT signing key is not an ECDSA key")
+		}
+	}
+	return NewIssuer(key, issuer, keyID)
+}
+
+// Issue returns the compact SD-JWT VC with all disclosures:
+// '<issuer-signed JWT>~<disclosure>~...~'.
+func (i *Issuer) Issue(vc *verifiable.W3CCredential, vct string) (string, error) {
+	claims := map[string]interface{}{
+		"iss":     i.issuer,
+		"iat":     i.now().Unix(),
+		"vct":     vct,
+		"_sd_alg": hashAlg,
+	}
+	if vc.Expiration != nil {
+		claims["exp"] = vc.Expiration.Unix()
+	}
+	if vc.IssuanceDate != nil {
+		claims["nbf"] = vc.IssuanceDate.Unix()
+	}
+	if sub, ok := vc.CredentialSubject["id"].(string); ok {
+		claims["sub"] = sub
+	}
+
+	names := make([]string, 0, len(vc.CredentialSubject))
+	for name := range vc.CredentialSubject {
+		if name == "id" || name == "type" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	disclosures := make([]string, 0, len(names))
+	digests := make([]string, 0, len(names))
+	for _, name := range names {
+		disclosure, err := newDisclosure(name, vc.CredentialSubject[name])
+		if err != nil {
+			return "", err
+		}
+		disclosures = append(disclosures, disclosure)
+		digests = append(digests, digest(disclosure))
+	}
+	// sorted digests don't reveal the original claim order
+	sort.Strings(digests)
+	claims["_sd"] = digests
+
+	jwt, err := i.sign(claims)
+	if err != nil {
+		return "", err
+	}
+	return jwt + "~" + strings.Join(append(disclosures, ""), "~"), nil
+}
+
+func (i *Issuer) sign(claims map[string]interface{}) (string, error) {
+	header := map[string]string{"alg": "ES256", "typ": MediaType}
+	if i.keyID != "" {
+		header["kid"] = i.keyID
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.Errorf("failed to serialize SD-JWT claims: %v", err)
+	}
+	signingInput := b64.EncodeToString(headerJSON) + "." + b64.EncodeToString(claimsJSON)
+	hash := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, i.key, hash[:])
+	if err != nil {
+		return "", errors.Errorf("failed to sign SD-JWT: %v", err)
+	}
+	return signingInput + "." + b64.EncodeToString(joinSignature(r, s)), nil
+}
+
+// joinSignature encodes an ES256 signature as fixed size r || s.
+func joinSignature(r, s *big.Int) []byte {
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig
+}
+
+func newDisclosure(name string, value interface{}) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", errors.Errorf("failed to generate disclosure salt: %v", err)
+	}
+	disclosure, err := json.Marshal([]interface{}{b64.EncodeToString(salt), name, value})
+	if err != nil {
+		return "", errors.Errorf("failed to serialize disclosure '%s': %v", name, err)
+	}
+	return b64.EncodeToString(disclosure), nil
+}
+
+func digest(disclosure string) string {
+	sum := sha256.Sum256([]byte(disclosure))
+	return b64.EncodeToString(sum[:])
+}
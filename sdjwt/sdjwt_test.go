@@ -0,0 +1,78 @@
+package sdjwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssue(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	issuer, err := NewIssuer(key, "https://refresh.example.com", "key-1")
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	issuer.now = func() time.Time { return now }
+
+	expiration := now.Add(time.Hour)
+	vc := &verifiable.W3CCredential{
+		Expiration: &expiration,
+		CredentialSubject: map[string]interface{}{
+			"id":      "did:iden3:polygon:amoy:x6x5sor7zpxUwajVSoHGg8aAhoHNoAW1xFDTPCF49",
+			"type":    "BalanceCredential",
+			"balance": 100.0,
+			"address": "0x1",
+		},
+	}
+	token, err := issuer.Issue(vc, "https://example.com/vct/balance")
+	require.NoError(t, err)
+
+	parts := strings.Split(token, "~")
+	require.Len(t, parts, 4)
+	require.Empty(t, parts[3])
+
+	jwtParts := strings.Split(parts[0], ".")
+	require.Len(t, jwtParts, 3)
+	var header map[string]string
+	require.NoError(t, json.Unmarshal(mustDecode(t, jwtParts[0]), &header))
+	require.Equal(t, map[string]string{"alg": "ES256", "typ": MediaType, "kid": "key-1"}, header)
+
+	sig := mustDecode(t, jwtParts[2])
+	hash := sha256.Sum256([]byte(jwtParts[0] + "." + jwtParts[1]))
+	require.True(t, ecdsa.Verify(&key.PublicKey, hash[:],
+		new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])))
+
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(mustDecode(t, jwtParts[1]), &claims))
+	require.Equal(t, "https://refresh.example.com", claims["iss"])
+	require.Equal(t, "https://example.com/vct/balance", claims["vct"])
+	require.Equal(t, vc.CredentialSubject["id"], claims["sub"])
+	require.InDelta(t, float64(expiration.Unix()), claims["exp"], 0)
+	require.NotContains(t, claims, "balance")
+
+	disclosed := map[string]interface{}{}
+	for _, disclosure := range parts[1:3] {
+		require.Contains(t, claims["_sd"], digest(disclosure))
+		var d []interface{}
+		require.NoError(t, json.Unmarshal(mustDecode(t, disclosure), &d))
+		require.Len(t, d, 3)
+		disclosed[d[1].(string)] = d[2]
+	}
+	require.Equal(t, map[string]interface{}{"balance": 100.0, "address": "0x1"}, disclosed)
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := b64.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
@@ -0,0 +1,72 @@
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FileSource reads secrets from a file with KEY=VALUE lines, or from a
+// directory with one file per secret as mounted by Kubernetes.
+type FileSource struct {
+	Path string
+}
+
+func (fs FileSource) Load(_ context.Context) (map[string]string, error) {
+	info, err := os.Stat(fs.Path)
+	if err != nil {
+		return nil, errors.Errorf("failed to read secrets '%s': %v", fs.Path, err)
+	}
+	if info.IsDir() {
+		return loadDir(fs.Path)
+	}
+	//nolint:gosec // secrets path comes from the service configuration
+	content, err := os.ReadFile(fs.Path)
+	if err != nil {
+		return nil, errors.Errorf("failed to read secrets '%s': %v", fs.Path, err)
+	}
+	return parseLines(content)
+}
+
+func loadDir(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Errorf("failed to read secrets '%s': %v", dir, err)
+	}
+	values := make(map[string]string, len(entries))
+	for _, e := range entries {
+		// skip kubernetes '..data' symlinks and hidden files
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		//nolint:gosec // secrets path comes from the service configuration
+		content, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, errors.Errorf("failed to read secret '%s': %v", e.Name(), err)
+		}
+		values[e.Name()] = strings.TrimSpace(string(content))
+	}
+	return values, nil
+}
+
+func parseLines(content []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid secret on line %d", n)
+		}
+		values[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+	}
+	return values, scanner.Err()
+}
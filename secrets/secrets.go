@@ -0,0 +1,150 @@
+package secrets
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+)
+
+// Source loads the current secret values from a secret backend.
+type Source interface {
+	Load(ctx context.Context) (map[string]string, error)
+}
+
+type previousValue struct {
+	value   string
+	expires time.Time
+}
+
+// Store holds secrets which can be rotated at runtime. When a secret
+// changes, its previous value stays available for the rotation window so
+// requests can fall back to it until the remote side picks up the new one.
+type Store struct {
+	mu       sync.RWMutex
+	window   time.Duration
+	current  map[string]string
+	previous map[string]previousValue
+	onChange func(added, removed, changed []string)
+	now      func() time.Time
+}
+
+func NewStore(window time.Duration) *Store {
+	return &Store{
+		window:   window,
+		current:  make(map[string]string),
+		previous: make(map[string]previousValue),
+		now:      time.Now,
+	}
+}
+
+func (s *Store) Get(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.current[name]
+	return v, ok
+}
+
+// Previous returns the value replaced by the last rotation of name while
+// the rotation window is open.
+func (s *Store) Previous(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.previous[name]
+	if !ok || s.now().After(p.expires) {
+		return "", false
+	}
+	return p.value, true
+}
+
+// OnChange calls fn with the names of the secrets an update added, removed
+// or changed. The values are never passed on.
+func (s *Store) OnChange(fn func(added, removed, changed []string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// Update replaces all secrets with values.
+func (s *Store) Update(values map[string]string) {
+	s.mu.Lock()
+	added, removed, changed := diffNames(s.current, values)
+	onChange := s.onChange
+	s.update(values)
+	s.mu.Unlock()
+
+	if onChange != nil && len(added)+len(removed)+len(changed) > 0 {
+		onChange(added, removed, changed)
+	}
+}
+
+func (s *Store) update(values map[string]string) {
+	expires := s.now().Add(s.window)
+	for name, old := range s.current {
+		if v, ok := values[name]; !ok || v != old {
+			s.previous[name] = previousValue{value: old, expires: expires}
+		}
+	}
+	for name, p := range s.previous {
+		if s.now().After(p.expires) {
+			delete(s.previous, name)
+		}
+	}
+	current := make(map[string]string, len(values))
+	for k, v := range values {
+		current[k] = v
+	}
+	s.current = current
+}
+
+// diffNames returns the sorted names which are only in after, only in
+// before and in both with different values.
+func diffNames(before, after map[string]string) (added, removed, changed []string) {
+	for name, v := range after {
+		old, ok := before[name]
+		switch {
+		case !ok:
+			added = append(added, name)
+		case old != v:
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
+
+// Reload loads secrets from source. On failure the current secrets are kept.
+func (s *Store) Reload(ctx context.Context, source Source) error {
+	values, err := source.Load(ctx)
+	if err != nil {
+		return err
+	}
+	s.Update(values)
+	return nil
+}
+
+// Watch reloads secrets every interval and whenever reload receives a value.
+func (s *Store) Watch(ctx context.Context, source Source, interval time.Duration, reload <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-reload:
+		}
+		if err := s.Reload(ctx, source); err != nil {
+			logger.DefaultLogger.Errorf("failed to reload secrets, keeping current values: %v", err)
+		}
+	}
+}
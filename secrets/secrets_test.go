@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_RotationWindow(t *testing.T) {
+	now := time.Now()
+	s := NewStore(time.Minute)
+	s.now = func() time.Time { return now }
+
+	s.Update(map[string]string{"API_KEY": "old", "OTHER": "same"})
+	_, ok := s.Previous("API_KEY")
+	require.False(t, ok)
+
+	s.Update(map[string]string{"API_KEY": "new", "OTHER": "same"})
+	v, ok := s.Get("API_KEY")
+	require.True(t, ok)
+	require.Equal(t, "new", v)
+	v, ok = s.Previous("API_KEY")
+	require.True(t, ok)
+	require.Equal(t, "old", v)
+	_, ok = s.Previous("OTHER")
+	require.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = s.Previous("API_KEY")
+	require.False(t, ok)
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "secrets.env")
+	require.NoError(t, os.WriteFile(file, []byte(
+		"# issuer credentials\nISSUERS_BASIC_AUTH=*=user:pass\nAPI_KEY=\"abc=\"\n"), 0o600))
+
+	values, err := FileSource{Path: file}.Load(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"ISSUERS_BASIC_AUTH": "*=user:pass",
+		"API_KEY":            "abc=",
+	}, values)
+
+	mounted := filepath.Join(dir, "mounted")
+	require.NoError(t, os.Mkdir(mounted, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(mounted, "API_KEY"), []byte("xyz\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(mounted, "..data"), []byte("ignored"), 0o600))
+
+	values, err = FileSource{Path: mounted}.Load(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"API_KEY": "xyz"}, values)
+}
+
+func TestStore_OnChange(t *testing.T) {
+	s := NewStore(time.Minute)
+	s.Update(map[string]string{"API_KEY": "old", "OTHER": "same", "GONE": "x"})
+
+	var added, removed, changed []string
+	calls := 0
+	s.OnChange(func(a, r, c []string) {
+		calls++
+		added, removed, changed = a, r, c
+	})
+
+	s.Update(map[string]string{"API_KEY": "new", "OTHER": "same", "NEW": "y"})
+	require.Equal(t, 1, calls)
+	require.Equal(t, []string{"NEW"}, added)
+	require.Equal(t, []string{"GONE"}, removed)
+	require.Equal(t, []string{"API_KEY"}, changed)
+
+	s.Update(map[string]string{"API_KEY": "new", "OTHER": "same", "NEW": "y"})
+	require.Equal(t, 1, calls)
+}
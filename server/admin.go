@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+)
+
+type HandlerOption func(*Handlers)
+
+// WithAdminToken enables the admin API protected by a bearer token with
+// the admin role.
+func WithAdminToken(token string) HandlerOption {
+	return func(h *Handlers) {
+		if token != "" {
+			h.adminTokens = append(h.adminTokens, AdminToken{Name: "admin", Role: RoleAdmin, Token: token})
+		}
+	}
+}
+
+func WithStatistics(statistics storage.Statistics) HandlerOption {
+	return func(h *Handlers) {
+		h.statistics = statistics
+	}
+}
+
+func (h *Handlers) adminRouter() http.Handler {
+	router := chi.NewRouter()
+	router.Use(h.adminAuth)
+	var (
+		viewer   = router.With(requireRole(RoleViewer))
+		operator = router.With(requireRole(RoleOperator))
+		admin    = router.With(requireRole(RoleAdmin))
+	)
+	if h.health != nil {
+		viewer.Get("/health", h.healthReport)
+	}
+	if h.statistics != nil {
+		viewer.Get("/stats", h.refreshStats)
+	}
+	if h.history != nil {
+		viewer.Get("/history/export", h.exportHistory)
+	}
+	if h.jobs != nil {
+		operator.Post("/jobs", h.enqueueJob)
+		viewer.Get("/jobs/dead", h.deadLetters)
+		viewer.Get("/jobs/{id}", h.getJob)
+		viewer.Get("/jobs/{id}/watch", h.watchJob)
+		operator.Post("/jobs/{id}/requeue", h.requeueJob)
+	}
+	if h.batch != nil {
+		operator.Post("/batch", h.refreshBatch)
+		operator.Post("/batch/stream", h.streamBatch)
+	}
+	if h.providerCache != nil {
+		operator.Delete("/provider-cache", h.invalidateProviderCache)
+	}
+	if len(h.caches) != 0 {
+		operator.Post("/caches/flush", h.flushCaches)
+	}
+	if h.documentCache != nil {
+		viewer.Get("/caches/documents", h.listDocumentCache)
+	}
+	if h.providerRegistry != nil {
+		admin.Post("/providers/reload", h.reloadProviders)
+	}
+	if h.agentService.OpenID4VCIEnabled() {
+		operator.Post("/openid4vci/offers", h.createCredentialOffer)
+	}
+	if h.configAudit != nil {
+		viewer.Get("/audit/config", h.listConfigChanges)
+	}
+	return router
+}
+
+func bearerAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeJSON(w, http.StatusUnauthorized, jsonError{
+					Code: http.StatusUnauthorized,
+					Err:  "invalid token",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+var defaultStatsWindows = []string{"1h", "24h", "7d"}
+
+// maxStatsWindows and maxTopErrors bound the aggregations of one stats
+// request, each window scans the refresh history again.
+const (
+	maxStatsWindows = 5
+	maxTopErrors    = 50
+)
+
+func (h *Handlers) refreshStats(w http.ResponseWriter, r *http.Request) {
+	windows := r.URL.Query()["window"]
+	if len(windows) == 0 {
+		windows = defaultStatsWindows
+	}
+	if len(windows) > maxStatsWindows {
+		writeJSON(w, http.StatusBadRequest, jsonError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Sprintf("at most %d windows are allowed", maxStatsWindows),
+		})
+		return
+	}
+	topErrors := 5
+	if v := r.URL.Query().Get("topErrors"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxTopErrors {
+			writeJSON(w, http.StatusBadRequest, jsonError{
+				Code: http.StatusBadRequest,
+				Err:  fmt.Sprintf("topErrors must be an integer between 1 and %d", maxTopErrors),
+			})
+			return
+		}
+		topErrors = n
+	}
+
+	response := make(map[string]storage.RefreshStats, len(windows))
+	now := time.Now()
+	for _, window := range windows {
+		d, err := parseWindow(window)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, jsonError{
+				Code: http.StatusBadRequest,
+				Err:  err.Error(),
+			})
+			return
+		}
+		stats, err := h.statistics.RefreshStats(r.Context(), now.Add(-d), topErrors)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		response[window] = stats
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"windows": response,
+	})
+}
+
+// parseWindow parses a duration which may also be given in days, e.g. '7d'.
+func parseWindow(window string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(window, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, errors.Errorf("invalid window '%s'", window)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return 0, errors.Errorf("invalid window '%s'", window)
+	}
+	return d, nil
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type staticStatistics struct {
+	froms []time.Time
+}
+
+func (s *staticStatistics) RefreshStats(_ context.Context, from time.Time, _ int) (storage.RefreshStats, error) {
+	s.froms = append(s.froms, from)
+	return storage.RefreshStats{From: from, Total: 10, Succeeded: 9, SuccessRate: 0.9}, nil
+}
+
+func TestAdminStats(t *testing.T) {
+	stats := &staticStatistics{}
+	h := NewHandlers(nil, nil, WithAdminToken("secret"), WithStatistics(stats))
+	router := h.adminRouter()
+
+	tests := []struct {
+		name            string
+		token           string
+		query           string
+		expectedCode    int
+		expectedWindows []string
+	}{
+		{
+			name:         "Missing token",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Invalid token",
+			token:        "wrong",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:            "Default windows",
+			token:           "secret",
+			expectedCode:    http.StatusOK,
+			expectedWindows: []string{"1h", "24h", "7d"},
+		},
+		{
+			name:            "Custom window",
+			token:           "secret",
+			query:           "?window=30m",
+			expectedCode:    http.StatusOK,
+			expectedWindows: []string{"30m"},
+		},
+		{
+			name:         "Invalid window",
+			token:        "secret",
+			query:        "?window=yesterday",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Too many windows",
+			token:        "secret",
+			query:        "?window=1h&window=2h&window=3h&window=4h&window=5h&window=6h",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:            "Top errors",
+			token:           "secret",
+			query:           "?window=1h&topErrors=50",
+			expectedCode:    http.StatusOK,
+			expectedWindows: []string{"1h"},
+		},
+		{
+			name:         "Too many top errors",
+			token:        "secret",
+			query:        "?topErrors=51",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/stats"+tt.query, http.NoBody)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Windows map[string]storage.RefreshStats `json:"windows"`
+			}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			require.Len(t, response.Windows, len(tt.expectedWindows))
+			for _, w := range tt.expectedWindows {
+				require.Equal(t, int64(10), response.Windows[w].Total)
+			}
+		})
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	d, err := parseWindow("7d")
+	require.NoError(t, err)
+	require.Equal(t, 7*24*time.Hour, d)
+
+	d, err = parseWindow("90m")
+	require.NoError(t, err)
+	require.Equal(t, 90*time.Minute, d)
+
+	_, err = parseWindow("-1h")
+	require.Error(t, err)
+	_, err = parseWindow("0d")
+	require.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/0xPolygonID/refresh-service/audit"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// WithConfigAudit records the configuration changes made through the admin
+// API in recorder and lists them at /admin/audit/config.
+func WithConfigAudit(recorder *audit.Recorder) HandlerOption {
+	return func(h *Handlers) {
+		h.configAudit = recorder
+	}
+}
+
+// listConfigChanges answers the configuration changes matching the query,
+// newest first.
+func (h *Handlers) listConfigChanges(w http.ResponseWriter, r *http.Request) {
+	filter, err := configChangeFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, jsonError{
+			Code: http.StatusBadRequest,
+			Err:  err.Error(),
+		})
+		return
+	}
+	changes, err := h.configAudit.List(r.Context(), filter)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if changes == nil {
+		changes = []storage.ConfigChange{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"changes": changes})
+}
+
+func configChangeFilter(r *http.Request) (storage.ConfigChangeFilter, error) {
+	query := r.URL.Query()
+	filter := storage.ConfigChangeFilter{
+		Target: query.Get("target"),
+		Actor:  query.Get("actor"),
+		Limit:  defaultAuditLimit,
+	}
+	var err error
+	if filter.From, err = parseTime(query.Get("from")); err != nil {
+		return filter, errors.Wrap(err, "from")
+	}
+	if filter.To, err = parseTime(query.Get("to")); err != nil {
+		return filter, errors.Wrap(err, "to")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, errors.New("from must be before to")
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			return filter, errors.Errorf("limit must be an integer between 1 and %d", maxAuditLimit)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/audit"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadProviders_Audit(t *testing.T) {
+	reloads := 0
+	registry := mockProviderRegistry{
+		types: []string{"Balance"},
+		fingerprints: func() map[string]string {
+			reloads++
+			if reloads == 1 {
+				return map[string]string{"Balance": "a", "KYCAge": "b"}
+			}
+			return map[string]string{"Balance": "c"}
+		},
+	}
+	store := memory.NewStore()
+	tokens, err := ParseAdminTokens(map[string]string{"alice": "admin:alice-token"})
+	require.NoError(t, err)
+	h := NewHandlers(nil, nil, WithAdminTokens(tokens...), WithProviderRegistry(registry),
+		WithConfigAudit(audit.NewRecorder(store)))
+
+	req := httptest.NewRequest(http.MethodPost, "/providers/reload", http.NoBody)
+	req.Header.Set("Authorization", "Bearer alice-token")
+	rec := httptest.NewRecorder()
+	h.adminRouter().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	changes, err := store.ListConfigChanges(context.Background(), storage.ConfigChangeFilter{})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, "alice", changes[0].Actor)
+	require.Equal(t, audit.TargetProviders, changes[0].Target)
+	require.Equal(t, audit.SourceAdminAPI, changes[0].Source)
+	require.Equal(t, []string{"KYCAge"}, changes[0].Removed)
+	require.Equal(t, []string{"Balance"}, changes[0].Changed)
+}
+
+func TestListConfigChanges(t *testing.T) {
+	store := memory.NewStore()
+	ctx := context.Background()
+	require.NoError(t, store.SaveConfigChange(ctx, storage.ConfigChange{
+		Actor: "alice", Target: audit.TargetProviders, Source: audit.SourceAdminAPI, Changed: []string{"Balance"},
+	}))
+	require.NoError(t, store.SaveConfigChange(ctx, storage.ConfigChange{
+		Actor: audit.ActorSystem, Target: audit.TargetFeatureFlags, Source: audit.SourceReload, Added: []string{"cache"},
+	}))
+	h := NewHandlers(nil, nil, WithAdminToken("secret"), WithConfigAudit(audit.NewRecorder(store)))
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedCode    int
+		expectedTargets []string
+	}{
+		{
+			name:            "All",
+			expectedCode:    http.StatusOK,
+			expectedTargets: []string{audit.TargetFeatureFlags, audit.TargetProviders},
+		},
+		{
+			name:            "By target",
+			query:           "?target=" + audit.TargetProviders,
+			expectedCode:    http.StatusOK,
+			expectedTargets: []string{audit.TargetProviders},
+		},
+		{
+			name:            "By actor",
+			query:           "?actor=bob",
+			expectedCode:    http.StatusOK,
+			expectedTargets: []string{},
+		},
+		{
+			name:         "Invalid limit",
+			query:        "?limit=5000",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid time",
+			query:        "?from=yesterday",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/audit/config"+tt.query, http.NoBody)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			h.adminRouter().ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Changes []storage.ConfigChange `json:"changes"`
+			}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			targets := []string{}
+			for _, c := range response.Changes {
+				targets = append(targets, c.Target)
+			}
+			require.Equal(t, tt.expectedTargets, targets)
+		})
+	}
+}
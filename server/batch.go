@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/0xPolygonID/refresh-service/batch"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+)
+
+// WithBatch enables bulk refresh through the admin API. A request may hold
+// at most maxItems credentials.
+func WithBatch(engine *batch.Engine, maxItems int) HandlerOption {
+	return func(h *Handlers) {
+		h.batch = engine
+		h.batchMaxItems = maxItems
+	}
+}
+
+type batchRequest struct {
+	Items []batch.Item `json:"items"`
+}
+
+type batchItemResult struct {
+	batch.Item
+	Credential *verifiable.W3CCredential `json:"credential,omitempty"`
+	Error      *jsonError                `json:"error,omitempty"`
+}
+
+func newBatchItemResult(result batch.Result) batchItemResult {
+	itemResult := batchItemResult{Item: result.Item, Credential: result.Credential}
+	if result.Err != nil {
+		itemResult.Error = newJSONError(result.Err)
+	}
+	return itemResult
+}
+
+func (h *Handlers) decodeBatch(w http.ResponseWriter, r *http.Request) ([]batch.Item, bool) {
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024*1024)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, jsonError{Code: http.StatusBadRequest, Err: err.Error()})
+		return nil, false
+	}
+	if len(req.Items) == 0 || len(req.Items) > h.batchMaxItems {
+		writeJSON(w, http.StatusBadRequest, jsonError{
+			Code: http.StatusBadRequest,
+			Err:  fmt.Sprintf("a batch must have from 1 to %d items", h.batchMaxItems),
+		})
+		return nil, false
+	}
+	for _, item := range req.Items {
+		if item.Issuer == "" || item.Owner == "" || item.CredentialID == "" {
+			writeJSON(w, http.StatusBadRequest, jsonError{
+				Code: http.StatusBadRequest,
+				Err:  "issuer, owner and credentialId are required for every item",
+			})
+			return nil, false
+		}
+	}
+	return req.Items, true
+}
+
+func (h *Handlers) refreshBatch(w http.ResponseWriter, r *http.Request) {
+	items, ok := h.decodeBatch(w, r)
+	if !ok {
+		return
+	}
+	results := h.batch.Process(r.Context(), items)
+	response := make([]batchItemResult, 0, len(results))
+	for _, result := range results {
+		response = append(response, newBatchItemResult(result))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// batchProgress is the event of an item of a streamed batch.
+type batchProgress struct {
+	Index int `json:"index"`
+	batchItemResult
+}
+
+type batchSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// streamBatch refreshes a batch like refreshBatch but sends every item as a
+// Server-Sent Event as soon as it is done, followed by a summary.
+func (h *Handlers) streamBatch(w http.ResponseWriter, r *http.Request) {
+	items, ok := h.decodeBatch(w, r)
+	if !ok {
+		return
+	}
+	in := make(chan batch.Item, len(items))
+	for _, item := range items {
+		in <- item
+	}
+	close(in)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// keep reverse proxies from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	summary := batchSummary{Total: len(items)}
+	for result := range h.batch.Stream(r.Context(), in) {
+		if result.Err != nil {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+		progress := batchProgress{Index: result.Index, batchItemResult: newBatchItemResult(result)}
+		if err := writeEvent(w, rc, "result", strconv.Itoa(result.Index), progress); err != nil {
+			logger.DefaultLogger.Warnf("failed to stream batch result: %v", err)
+			return
+		}
+	}
+	if err := writeEvent(w, rc, "done", "", summary); err != nil {
+		logger.DefaultLogger.Warnf("failed to stream batch summary: %v", err)
+	}
+}
+
+// writeEvent writes a Server-Sent Event with data encoded as JSON.
+func writeEvent(w io.Writer, rc *http.ResponseController, event, id string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
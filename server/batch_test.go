@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/batch"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type stubRefresher struct{}
+
+func (stubRefresher) Process(_ context.Context, _, _, id string) (*verifiable.W3CCredential, error) {
+	if id == "fail" {
+		return nil, errors.Wrap(service.ErrCredentialNotUpdatable, "expired")
+	}
+	return &verifiable.W3CCredential{ID: id}, nil
+}
+
+func TestRefreshBatch(t *testing.T) {
+	h := NewHandlers(nil, nil, WithAdminToken("secret"), WithBatch(batch.NewEngine(stubRefresher{}), 2))
+	router := h.adminRouter()
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedIDs  []string
+	}{
+		{
+			name:         "Empty batch",
+			body:         `{"items": []}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Too many items",
+			body: `{"items": [
+				{"issuer": "i", "owner": "o", "credentialId": "1"},
+				{"issuer": "i", "owner": "o", "credentialId": "2"},
+				{"issuer": "i", "owner": "o", "credentialId": "3"}]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Missing credential id",
+			body:         `{"items": [{"issuer": "i", "owner": "o"}]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Partial failure",
+			body: `{"items": [
+				{"issuer": "i", "owner": "o", "credentialId": "fail"},
+				{"issuer": "i", "owner": "o", "credentialId": "2"}]}`,
+			expectedCode: http.StatusOK,
+			expectedIDs:  []string{"fail", "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var response []batchItemResult
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			require.Len(t, response, len(tt.expectedIDs))
+			for i, id := range tt.expectedIDs {
+				require.Equal(t, id, response[i].CredentialID)
+			}
+			require.NotNil(t, response[0].Error)
+			require.Equal(t, int(service.CodeCredentialNotUpdatable), response[0].Error.Code)
+			require.Equal(t, "2", response[1].Credential.ID)
+		})
+	}
+}
+
+func TestStreamBatch(t *testing.T) {
+	h := NewHandlers(nil, nil, WithAdminToken("secret"), WithBatch(batch.NewEngine(stubRefresher{}), 3))
+	body := `{"items": [
+		{"issuer": "i", "owner": "o", "credentialId": "1"},
+		{"issuer": "i", "owner": "o", "credentialId": "fail"},
+		{"issuer": "i", "owner": "o", "credentialId": "3"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/batch/stream", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.adminRouter().ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+	require.True(t, rec.Flushed)
+
+	events := strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n")
+	require.Len(t, events, 4)
+	ids := map[string]string{}
+	for _, event := range events[:3] {
+		lines := strings.Split(event, "\n")
+		require.Len(t, lines, 3)
+		require.Equal(t, "event: result", lines[1])
+		var progress batchProgress
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &progress))
+		require.Equal(t, lines[0], fmt.Sprintf("id: %d", progress.Index))
+		ids[progress.CredentialID] = lines[0]
+		if progress.CredentialID == "fail" {
+			require.Equal(t, int(service.CodeCredentialNotUpdatable), progress.Error.Code)
+		} else {
+			require.Equal(t, progress.CredentialID, progress.Credential.ID)
+		}
+	}
+	require.Equal(t, map[string]string{"1": "id: 0", "fail": "id: 1", "3": "id: 2"}, ids)
+	require.Equal(t, "event: done\ndata: {\"total\":3,\"succeeded\":2,\"failed\":1}", events[3])
+}
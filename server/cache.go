@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/loadercache"
+)
+
+// ProviderCache is the cache of data provider fields.
+type ProviderCache interface {
+	Invalidate(ctx context.Context, credentialType, key string) (int64, error)
+}
+
+// WithProviderCache enables invalidation of cached provider fields through
+// the admin API.
+func WithProviderCache(cache ProviderCache) HandlerOption {
+	return func(h *Handlers) {
+		h.providerCache = cache
+	}
+}
+
+func (h *Handlers) invalidateProviderCache(w http.ResponseWriter, r *http.Request) {
+	credentialType := r.URL.Query().Get("credentialType")
+	if credentialType == "" {
+		writeJSON(w, http.StatusBadRequest, jsonError{
+			Code: http.StatusBadRequest,
+			Err:  "credentialType is required",
+		})
+		return
+	}
+	n, err := h.providerCache.Invalidate(r.Context(), credentialType, r.URL.Query().Get("key"))
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int64{"invalidated": n})
+}
+
+// CacheFlusher is a cache which can be emptied through the admin API. Flush
+// returns the number of removed entries.
+type CacheFlusher interface {
+	Flush(ctx context.Context) (int64, error)
+}
+
+// CacheFlusherFunc adapts a function to CacheFlusher.
+type CacheFlusherFunc func(ctx context.Context) (int64, error)
+
+func (f CacheFlusherFunc) Flush(ctx context.Context) (int64, error) {
+	return f(ctx)
+}
+
+// WithCaches enables flushing caches by name through the admin API.
+func WithCaches(caches map[string]CacheFlusher) HandlerOption {
+	return func(h *Handlers) {
+		h.caches = caches
+	}
+}
+
+func (h *Handlers) flushCaches(w http.ResponseWriter, r *http.Request) {
+	names := r.URL.Query()["cache"]
+	if len(names) == 0 {
+		for name := range h.caches {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		if _, ok := h.caches[name]; !ok {
+			writeJSON(w, http.StatusBadRequest, jsonError{
+				Code: http.StatusBadRequest,
+				Err:  "unknown cache '" + name + "'",
+			})
+			return
+		}
+	}
+	flushed := make(map[string]int64, len(names))
+	for _, name := range names {
+		n, err := h.caches[name].Flush(r.Context())
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		flushed[name] = n
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"flushed": flushed})
+}
+
+// DocumentCache lists the cached JSON-LD documents of the document loader.
+type DocumentCache interface {
+	Entries() []loadercache.Entry
+}
+
+// WithDocumentCache enables listing cached JSON-LD documents through the
+// admin API.
+func WithDocumentCache(cache DocumentCache) HandlerOption {
+	return func(h *Handlers) {
+		h.documentCache = cache
+	}
+}
+
+type documentCacheEntry struct {
+	loadercache.Entry
+	// AgeSeconds is the time since the document was stored.
+	AgeSeconds int64 `json:"ageSeconds"`
+	Expired    bool  `json:"expired"`
+}
+
+func (h *Handlers) listDocumentCache(w http.ResponseWriter, _ *http.Request) {
+	now := time.Now()
+	entries := h.documentCache.Entries()
+	documents := make([]documentCacheEntry, 0, len(entries))
+	var size int
+	for _, entry := range entries {
+		size += entry.Size
+		documents = append(documents, documentCacheEntry{
+			Entry:      entry,
+			AgeSeconds: int64(now.Sub(entry.StoredAt).Seconds()),
+			Expired:    !entry.Embedded && !entry.ExpiresAt.After(now),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"documents": documents,
+		"size":      size,
+	})
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/loadercache"
+	"github.com/0xPolygonID/refresh-service/providercache"
+	"github.com/piprate/json-gold/ld"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidateProviderCache(t *testing.T) {
+	cache := providercache.NewMemory()
+	ctx := context.Background()
+	for _, key := range []string{"0x1", "0x2"} {
+		require.NoError(t, cache.Set(ctx, "Balance", key, providercache.Entry{}, time.Minute))
+	}
+	h := NewHandlers(nil, nil, WithAdminToken("secret"), WithProviderCache(cache))
+	router := h.adminRouter()
+
+	tests := []struct {
+		name                string
+		query               string
+		expectedCode        int
+		expectedInvalidated int64
+	}{
+		{
+			name:         "Missing credential type",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:                "One subject",
+			query:               "?credentialType=Balance&key=0x1",
+			expectedCode:        http.StatusOK,
+			expectedInvalidated: 1,
+		},
+		{
+			name:                "Credential type",
+			query:               "?credentialType=Balance",
+			expectedCode:        http.StatusOK,
+			expectedInvalidated: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/provider-cache"+tt.query, http.NoBody)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			var response map[string]int64
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			require.Equal(t, tt.expectedInvalidated, response["invalidated"])
+		})
+	}
+}
+
+func TestFlushCaches(t *testing.T) {
+	flushes := map[string]int{}
+	flusher := func(name string, n int64) CacheFlusher {
+		return CacheFlusherFunc(func(context.Context) (int64, error) {
+			flushes[name]++
+			return n, nil
+		})
+	}
+	h := NewHandlers(nil, nil, WithAdminToken("secret"), WithCaches(map[string]CacheFlusher{
+		"documents": flusher("documents", 3),
+		"providers": flusher("providers", 5),
+	}))
+	router := h.adminRouter()
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedCode    int
+		expectedFlushed map[string]int64
+	}{
+		{
+			name:            "All caches",
+			expectedCode:    http.StatusOK,
+			expectedFlushed: map[string]int64{"documents": 3, "providers": 5},
+		},
+		{
+			name:            "One cache",
+			query:           "?cache=providers",
+			expectedCode:    http.StatusOK,
+			expectedFlushed: map[string]int64{"providers": 5},
+		},
+		{
+			name:         "Unknown cache",
+			query:        "?cache=documents&cache=claims",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clear(flushes)
+			req := httptest.NewRequest(http.MethodPost, "/caches/flush"+tt.query, http.NoBody)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode != http.StatusOK {
+				// nothing is flushed when a cache is unknown
+				require.Empty(t, flushes)
+				return
+			}
+			var response struct {
+				Flushed map[string]int64 `json:"flushed"`
+			}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			require.Equal(t, tt.expectedFlushed, response.Flushed)
+		})
+	}
+}
+
+func TestListDocumentCache(t *testing.T) {
+	cache := loadercache.NewMemory()
+	require.NoError(t, cache.Embed("https://www.w3.org/2018/credentials/v1", []byte(`{"@context": {}}`)))
+	require.NoError(t, cache.Set("https://example.com/stale", &ld.RemoteDocument{}, time.Now().Add(-time.Minute)))
+	h := NewHandlers(nil, nil, WithAdminToken("secret"), WithDocumentCache(cache))
+
+	req := httptest.NewRequest(http.MethodGet, "/caches/documents", http.NoBody)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.adminRouter().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response struct {
+		Documents []documentCacheEntry `json:"documents"`
+		Size      int                  `json:"size"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+	require.Len(t, response.Documents, 2)
+	require.Equal(t, "https://example.com/stale", response.Documents[0].URL)
+	require.True(t, response.Documents[0].Expired)
+	require.Equal(t, "https://www.w3.org/2018/credentials/v1", response.Documents[1].URL)
+	require.True(t, response.Documents[1].Embedded)
+	require.False(t, response.Documents[1].Expired)
+	require.Equal(t, response.Documents[0].Size+response.Documents[1].Size, response.Size)
+}
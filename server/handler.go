@@ -1,12 +1,18 @@
 package server
 
 import (
-	"io"
+	"net"
 	"net/http"
 	"time"
 
-	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/audit"
+	"github.com/0xPolygonID/refresh-service/batch"
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/0xPolygonID/refresh-service/health"
+	"github.com/0xPolygonID/refresh-service/jobs"
+	"github.com/0xPolygonID/refresh-service/metrics"
 	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/0xPolygonID/refresh-service/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/pkg/errors"
@@ -15,18 +21,89 @@ import (
 
 type Handlers struct {
 	agentService *service.AgentService
+	health       *health.Aggregator
+	startup      *health.Aggregator
+	liveness     *health.Aggregator
+	adminTokens  []AdminToken
+	statistics   storage.Statistics
+	history      storage.RefreshHistory
+	jobs         *jobs.Queue
+
+	maxMessageBytes int64
+
+	batch         *batch.Engine
+	batchMaxItems int
+
+	webhookToken    string
+	providerUpdates ProviderUpdates
+	webhookTTL      time.Duration
+	providerCache   ProviderCache
+	caches          map[string]CacheFlusher
+	documentCache   DocumentCache
+
+	providerRegistry ProviderRegistry
+	providerTenants  map[string]ProviderTenant
+	configAudit      *audit.Recorder
+
+	routeTimeouts        map[string]RouteTimeouts
+	slowRequestThreshold time.Duration
+
+	adminAddr       string
+	metricsAddr     string
+	adminNetworks   []*net.IPNet
+	metricsNetworks []*net.IPNet
+	pprof           bool
+	public          publicListener
 }
 
 func NewHandlers(
 	agentService *service.AgentService,
+	healthAggregator *health.Aggregator,
+	opts ...HandlerOption,
 ) *Handlers {
-	return &Handlers{
-		agentService: agentService,
+	h := &Handlers{
+		agentService:    agentService,
+		health:          healthAggregator,
+		maxMessageBytes: defaultMaxMessageBytes,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 func (h *Handlers) Run(host string) error {
+	servers, err := h.listeners(host)
+	if err != nil {
+		return err
+	}
+	public := h.public
+	public.addr = host
+	if err := public.validate(); err != nil {
+		return err
+	}
+	return servers.serve(public)
+}
+
+// Handler returns the public router without serving it, e.g. to invoke it
+// from an AWS Lambda function. The admin API and the metrics are left out
+// when they are moved to their own listener.
+func (h *Handlers) Handler() (http.Handler, error) {
+	servers, err := h.listeners("")
+	if err != nil {
+		return nil, err
+	}
+	return servers[""], nil
+}
+
+// listeners builds the routers of the public listener on host and of the
+// admin and metrics listeners.
+func (h *Handlers) listeners(host string) (listeners, error) {
+	if h.pprof && (h.metricsAddr == "" || h.metricsAddr == host) {
+		return nil, errors.New("pprof is only served on a separate metrics listener")
+	}
 	router := chi.NewRouter()
+	servers := listeners{host: router}
 	// Basic CORS
 	corsMiddleware := cors.New(cors.Options{
 		AllowedOrigins: []string{"localhost", "127.0.0.1", "*"},
@@ -36,45 +113,48 @@ func (h *Handlers) Run(host string) error {
 		AllowCredentials: true,
 	})
 	router.Use(corsMiddleware.Handler)
+	router.Use(rememberPeer)
 	router.Use(middleware.RequestID)
+	router.Use(correlation.Traceparent)
 	router.Use(middleware.RealIP)
 	router.Use(zapContextLogger)
 	router.Use(middleware.Recoverer)
+	router.Use(reportPanics)
 
-	router.Post("/", func(w http.ResponseWriter, r *http.Request) {
-		envelope, err := io.ReadAll(io.LimitReader(r.Body, 1024*1024))
-		if err != nil {
-			logger.DefaultLogger.Errorf("failed to read request body: %v", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+	router.With(h.route(RouteRefresh), credentialFormat).Post("/", h.refresh)
+	router.With(h.route(RouteSignedRefresh), credentialFormat).Post("/eip712", h.signedRefresh)
+	router.With(h.route(RoutePresentation), credentialFormat).Post("/presentation", h.presentationRefresh)
 
-		response, err := h.agentService.Process(r.Context(), envelope)
-		if err != nil {
-			handleError(w, err)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_, err = w.Write(response)
-		if err != nil {
-			logger.DefaultLogger.Errorf("failed to write response: %v", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	})
+	if h.agentService.OpenID4VCIEnabled() {
+		router.With(h.route(RouteOpenID4VCI)).Group(h.openID4VCIRoutes)
+	}
+	if h.agentService.AsyncRefreshEnabled() {
+		router.With(h.route(RouteJobs)).Group(h.sessionJobRoutes)
+	}
 
 	router.Get("/mock", func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		_, _ = w.Write([]byte(`{"string": "I'm mock refresh service"}`))
 	})
 
-	logger.DefaultLogger.Infof("Server starting on host '%s'", host)
-	httpServer := &http.Server{
-		Addr:              host,
-		Handler:           router,
-		ReadHeaderTimeout: 10 * time.Second,
+	router.With(h.route(RouteHealth)).Get("/health/startup", h.startupProbe)
+	router.With(h.route(RouteHealth)).Get("/health/live", h.livenessProbe)
+	router.With(h.route(RouteHealth)).Get("/health/ready", h.readinessProbe)
+	// the public listener only serves metrics to the allowed networks
+	if h.metricsAddr != "" || len(h.metricsNetworks) != 0 {
+		metricsRouter := servers.router(h.metricsAddr, host).With(allowNetworks(h.metricsNetworks))
+		metricsRouter.Get("/metrics", metrics.Handler().ServeHTTP)
+		if h.pprof {
+			metricsRouter.Mount("/debug", middleware.Profiler())
+		}
+	}
+	if len(h.adminTokens) != 0 {
+		servers.router(h.adminAddr, host).
+			With(h.route(RouteAdmin), allowNetworks(h.adminNetworks)).
+			Mount("/admin", h.adminRouter())
+	}
+	if h.webhookToken != "" && h.providerUpdates != nil {
+		router.With(h.route(RouteWebhook), bearerAuth(h.webhookToken)).Post("/webhooks/provider", h.pushProviderUpdate)
 	}
-	return errors.WithStack(httpServer.ListenAndServe())
+	return servers, nil
 }
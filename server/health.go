@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/0xPolygonID/refresh-service/health"
+	"github.com/0xPolygonID/refresh-service/logger"
+)
+
+// WithStartupChecks serves the checks which pass once the service has
+// started, e.g. warmed its caches, on /health/startup. The endpoint always
+// answers 200 without them.
+func WithStartupChecks(checks *health.Aggregator) HandlerOption {
+	return func(h *Handlers) {
+		h.startup = checks
+	}
+}
+
+// WithLivenessChecks serves the checks telling whether the process is
+// healthy, and must be restarted otherwise, on /health/live. The endpoint
+// always answers 200 without them.
+func WithLivenessChecks(checks *health.Aggregator) HandlerOption {
+	return func(h *Handlers) {
+		h.liveness = checks
+	}
+}
+
+func (h *Handlers) startupProbe(w http.ResponseWriter, r *http.Request) {
+	probe(w, r, h.startup)
+}
+
+func (h *Handlers) livenessProbe(w http.ResponseWriter, r *http.Request) {
+	probe(w, r, h.liveness)
+}
+
+func (h *Handlers) readinessProbe(w http.ResponseWriter, r *http.Request) {
+	probe(w, r, h.health)
+}
+
+// healthReport answers the readiness report with the errors of the
+// dependencies, which the public probes leave out.
+func (h *Handlers) healthReport(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, h.health.Check(r.Context()))
+}
+
+// probe answers 503 when a critical check of checks fails. The probes are
+// public, the errors of the checks are left out.
+func probe(w http.ResponseWriter, r *http.Request, checks *health.Aggregator) {
+	if checks == nil {
+		writeJSON(w, http.StatusOK, map[string]string{"status": health.StatusUp})
+		return
+	}
+	writeReport(w, checks.Check(r.Context()).WithoutErrors())
+}
+
+func writeReport(w http.ResponseWriter, report health.Report) {
+	httpCode := http.StatusOK
+	if report.Status == health.StatusDown {
+		httpCode = http.StatusServiceUnavailable
+	}
+	writeJSON(w, httpCode, report)
+}
+
+func writeJSON(w http.ResponseWriter, httpCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.DefaultLogger.Errorf("failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/health"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbes(t *testing.T) {
+	warmed := health.NewGate("caches are warming up")
+	startup := health.NewAggregator(time.Second)
+	startup.Register("caches", warmed, true)
+	liveness := health.NewAggregator(time.Second)
+	liveness.Register("heartbeat", health.CheckerFunc(func(context.Context) error { return nil }), true)
+	h := NewHandlers(nil, nil, WithStartupChecks(startup), WithLivenessChecks(liveness))
+
+	probe := func(handler http.HandlerFunc) int {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Code
+	}
+	require.Equal(t, http.StatusServiceUnavailable, probe(h.startupProbe))
+	require.Equal(t, http.StatusOK, probe(h.livenessProbe))
+	warmed.Open()
+	require.Equal(t, http.StatusOK, probe(h.startupProbe))
+
+	h = NewHandlers(nil, nil)
+	require.Equal(t, http.StatusOK, probe(h.startupProbe))
+	require.Equal(t, http.StatusOK, probe(h.livenessProbe))
+}
+
+func TestReadinessProbe_Errors(t *testing.T) {
+	readiness := health.NewAggregator(time.Second)
+	readiness.Register("issuer:did:example", health.CheckerFunc(func(context.Context) error {
+		return errors.New("dial tcp 10.0.0.1:443: connection refused")
+	}), true)
+	h := NewHandlers(nil, readiness, WithAdminToken("secret"))
+
+	tests := []struct {
+		name          string
+		handler       http.Handler
+		path          string
+		expectedError string
+	}{
+		{
+			name:    "Public probe",
+			handler: http.HandlerFunc(h.readinessProbe),
+			path:    "/health/ready",
+		},
+		{
+			name:          "Admin report",
+			handler:       h.adminRouter(),
+			path:          "/health",
+			expectedError: "dial tcp 10.0.0.1:443: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, http.NoBody)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+			var report health.Report
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+			require.Equal(t, health.StatusDown, report.Dependencies["issuer:did:example"].Status)
+			require.Equal(t, tt.expectedError, report.Dependencies["issuer:did:example"].Error)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultExportLimit = 10_000
+	maxExportLimit     = 100_000
+)
+
+// WithHistory enables exporting the refresh history through the admin API.
+func WithHistory(history storage.RefreshHistory) HandlerOption {
+	return func(h *Handlers) {
+		h.history = history
+	}
+}
+
+var historyColumns = []string{
+	"id", "requestId", "issuer", "owner", "credentialId", "refreshedId", "credentialType",
+	"status", "errorCode", "error", "durationMs", "createdAt",
+}
+
+// exportHistory answers the refresh history matching the query as JSON or,
+// with format=csv or an Accept of text/csv, as CSV. Records are newest
+// first; when more than limit match, the oldest are left out and the
+// export is marked truncated.
+func (h *Handlers) exportHistory(w http.ResponseWriter, r *http.Request) {
+	filter, err := historyFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, jsonError{
+			Code: http.StatusBadRequest,
+			Err:  err.Error(),
+		})
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			format = "csv"
+		}
+	}
+	if format != "json" && format != "csv" {
+		writeJSON(w, http.StatusBadRequest, jsonError{
+			Code: http.StatusBadRequest,
+			Err:  "format must be 'json' or 'csv'",
+		})
+		return
+	}
+
+	limit := filter.Limit
+	filter.Limit++
+	records, err := h.history.ListRefreshes(r.Context(), filter)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	truncated := len(records) > limit
+	if truncated {
+		records = records[:limit]
+	}
+
+	if format == "json" {
+		if records == nil {
+			records = []storage.RefreshRecord{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"records":   records,
+			"truncated": truncated,
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="refresh-history.csv"`)
+	w.Header().Set("X-Export-Truncated", strconv.FormatBool(truncated))
+	w.WriteHeader(http.StatusOK)
+	if err := writeHistoryCSV(w, records); err != nil {
+		logger.DefaultLogger.Errorf("failed to write response: %v", err)
+	}
+}
+
+func historyFilter(r *http.Request) (storage.RefreshFilter, error) {
+	query := r.URL.Query()
+	filter := storage.RefreshFilter{
+		Issuer:         query.Get("issuer"),
+		Owner:          query.Get("owner"),
+		CredentialType: query.Get("credentialType"),
+		Status:         query.Get("status"),
+		Limit:          defaultExportLimit,
+	}
+	switch filter.Status {
+	case "", storage.RefreshStatusSucceeded, storage.RefreshStatusFailed:
+	default:
+		return filter, errors.Errorf("status must be '%s' or '%s'", storage.RefreshStatusSucceeded, storage.RefreshStatusFailed)
+	}
+	var err error
+	if filter.From, err = parseTime(query.Get("from")); err != nil {
+		return filter, errors.Wrap(err, "from")
+	}
+	if filter.To, err = parseTime(query.Get("to")); err != nil {
+		return filter, errors.Wrap(err, "to")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, errors.New("from must be before to")
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxExportLimit {
+			return filter, errors.Errorf("limit must be an integer between 1 and %d", maxExportLimit)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time '%s', expected RFC 3339", value)
+	}
+	return t, nil
+}
+
+func writeHistoryCSV(w http.ResponseWriter, records []storage.RefreshRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(historyColumns); err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := []string{
+			strconv.FormatInt(record.ID, 10),
+			record.RequestID,
+			record.Issuer,
+			record.Owner,
+			record.CredentialID,
+			record.RefreshedID,
+			record.CredentialType,
+			record.Status,
+			strconv.Itoa(record.ErrorCode),
+			record.Error,
+			strconv.FormatInt(record.Duration.Milliseconds(), 10),
+			record.CreatedAt.UTC().Format(time.RFC3339Nano),
+		}
+		for i := range row {
+			row[i] = escapeFormula(row[i])
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// escapeFormula keeps spreadsheets from evaluating values starting like a
+// formula, e.g. error messages quoting provider responses.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportHistory(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	start := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	for i, r := range []storage.RefreshRecord{
+		{Issuer: "did:issuer:a", CredentialType: "Balance", Status: storage.RefreshStatusSucceeded},
+		{Issuer: "did:issuer:a", CredentialType: "KYCAge", Status: storage.RefreshStatusFailed,
+			ErrorCode: 2000, Error: "=HYPERLINK(\"http://evil\")"},
+		{Issuer: "did:issuer:b", CredentialType: "Balance", Status: storage.RefreshStatusSucceeded},
+	} {
+		r.CredentialID = string(rune('1' + i))
+		r.CreatedAt = start.Add(time.Duration(i) * time.Hour)
+		r.Duration = 1500 * time.Millisecond
+		require.NoError(t, store.SaveRefresh(ctx, r))
+	}
+	router := NewHandlers(nil, nil, WithAdminToken("secret"), WithHistory(store)).adminRouter()
+
+	tests := []struct {
+		name          string
+		query         string
+		accept        string
+		expectedCode  int
+		expectedIDs   []string
+		expectedTrunc bool
+		csv           bool
+	}{
+		{name: "All", expectedCode: http.StatusOK, expectedIDs: []string{"3", "2", "1"}},
+		{name: "By issuer", query: "?issuer=did:issuer:a", expectedCode: http.StatusOK, expectedIDs: []string{"2", "1"}},
+		{name: "By type", query: "?credentialType=Balance", expectedCode: http.StatusOK, expectedIDs: []string{"3", "1"}},
+		{
+			name:         "By time range",
+			query:        "?from=2024-01-31T10:30:00Z&to=2024-01-31T12:00:00Z",
+			expectedCode: http.StatusOK,
+			expectedIDs:  []string{"2"},
+		},
+		{name: "Truncated", query: "?limit=2", expectedCode: http.StatusOK, expectedIDs: []string{"3", "2"}, expectedTrunc: true},
+		{name: "No records", query: "?issuer=did:issuer:c", expectedCode: http.StatusOK, expectedIDs: []string{}},
+		{name: "CSV", query: "?format=csv&status=failed", expectedCode: http.StatusOK, expectedIDs: []string{"2"}, csv: true},
+		{name: "CSV by Accept", accept: "text/csv", expectedCode: http.StatusOK, expectedIDs: []string{"3", "2", "1"}, csv: true},
+		{name: "Invalid format", query: "?format=xml", expectedCode: http.StatusBadRequest},
+		{name: "Invalid status", query: "?status=pending", expectedCode: http.StatusBadRequest},
+		{name: "Invalid time", query: "?from=yesterday", expectedCode: http.StatusBadRequest},
+		{name: "Empty range", query: "?from=2024-01-31T12:00:00Z&to=2024-01-31T10:00:00Z", expectedCode: http.StatusBadRequest},
+		{name: "Invalid limit", query: "?limit=1000000", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/history/export"+tt.query, http.NoBody)
+			req.Header.Set("Authorization", "Bearer secret")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			ids := []string{}
+			if tt.csv {
+				require.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+				rows, err := csv.NewReader(rec.Body).ReadAll()
+				require.NoError(t, err)
+				require.Equal(t, historyColumns, rows[0])
+				for _, row := range rows[1:] {
+					ids = append(ids, row[4])
+					require.Equal(t, "1500", row[10])
+					if row[7] == storage.RefreshStatusFailed {
+						require.Equal(t, "'=HYPERLINK(\"http://evil\")", row[9])
+					}
+				}
+				require.Equal(t, tt.expectedIDs, ids)
+				return
+			}
+			var response struct {
+				Records   []storage.RefreshRecord `json:"records"`
+				Truncated bool                    `json:"truncated"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			require.NotNil(t, response.Records)
+			for _, r := range response.Records {
+				ids = append(ids, r.CredentialID)
+			}
+			require.Equal(t, tt.expectedIDs, ids)
+			require.Equal(t, tt.expectedTrunc, response.Truncated)
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/jobs"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+func WithJobs(queue *jobs.Queue) HandlerOption {
+	return func(h *Handlers) {
+		h.jobs = queue
+	}
+}
+
+type enqueueRequest struct {
+	Issuer       string `json:"issuer"`
+	Owner        string `json:"owner"`
+	CredentialID string `json:"credentialId"`
+}
+
+func (h *Handlers) enqueueJob(w http.ResponseWriter, r *http.Request) {
+	var req enqueueRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, jsonError{Code: http.StatusBadRequest, Err: err.Error()})
+		return
+	}
+	if req.Issuer == "" || req.Owner == "" || req.CredentialID == "" {
+		writeJSON(w, http.StatusBadRequest, jsonError{
+			Code: http.StatusBadRequest,
+			Err:  "issuer, owner and credentialId are required",
+		})
+		return
+	}
+	job, err := h.jobs.Enqueue(r.Context(), req.Issuer, req.Owner, req.CredentialID)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (h *Handlers) getJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		handleJobError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (h *Handlers) deadLetters(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	dead, err := h.jobs.DeadLetters(r.Context(), limit)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	if dead == nil {
+		dead = []storage.Job{}
+	}
+	writeJSON(w, http.StatusOK, dead)
+}
+
+func (h *Handlers) requeueJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.jobs.Requeue(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		handleJobError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+var jobUpgrader = websocket.Upgrader{}
+
+const (
+	jobWriteTimeout = 10 * time.Second
+	jobPingInterval = 30 * time.Second
+)
+
+// watchJob streams the job over a WebSocket: its current state, then every
+// status transition until it is finished. A succeeded job carries the
+// credential in its result.
+func (h *Handlers) watchJob(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	updates, err := h.jobs.Watch(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		handleJobError(w, r, err)
+		return
+	}
+	streamJob(w, r, cancel, updates, nil)
+}
+
+// streamJob upgrades the request to a WebSocket and sends the job updates
+// until they are closed or the client goes away, which cancels the watch.
+func streamJob(w http.ResponseWriter, r *http.Request, cancel context.CancelFunc,
+	updates <-chan storage.Job, responseHeader http.Header) {
+	conn, err := jobUpgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		// the upgrader has answered the request
+		return
+	}
+	defer conn.Close()
+
+	go func() {
+		// control frames are handled while reading, a failed read means
+		// the client has gone
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(jobPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case job, ok := <-updates:
+			if !ok {
+				message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+				_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(jobWriteTimeout))
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(jobWriteTimeout))
+			if err := conn.WriteJSON(job); err != nil {
+				logger.DefaultLogger.Warnf("failed to send job '%s' update: %v", job.ID, err)
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(jobWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sessionJobRoutes let wallets refresh credentials in the background. The
+// results of the jobs are fetched with the owner session token returned
+// when they were enqueued.
+func (h *Handlers) sessionJobRoutes(router chi.Router) {
+	router.Post("/jobs", h.enqueueRefresh)
+	router.Get("/jobs/{id}", h.getSessionJob)
+	router.Get("/jobs/{id}/watch", h.watchSessionJob)
+}
+
+func (h *Handlers) enqueueRefresh(w http.ResponseWriter, r *http.Request) {
+	envelope, ok := h.readMessage(w, r)
+	if !ok {
+		return
+	}
+	response, err := h.agentService.EnqueueRefresh(r.Context(), envelope)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusAccepted, response)
+}
+
+func (h *Handlers) getSessionJob(w http.ResponseWriter, r *http.Request) {
+	sessionToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || sessionToken == "" {
+		missingSessionToken(w)
+		return
+	}
+	job, err := h.agentService.SessionJob(r.Context(), sessionToken, chi.URLParam(r, "id"))
+	if err != nil {
+		handleJobError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, job)
+}
+
+// sessionTokenProtocol prefixes the owner session token offered as a
+// WebSocket subprotocol, since browsers can't set headers on WebSockets.
+const sessionTokenProtocol = "bearer."
+
+// watchSessionJob streams a job of the owner session like watchJob. The
+// session token is taken from the Authorization header or from a
+// subprotocol "bearer.<token>", which is then selected in the response.
+func (h *Handlers) watchSessionJob(w http.ResponseWriter, r *http.Request) {
+	var responseHeader http.Header
+	sessionToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		for _, protocol := range websocket.Subprotocols(r) {
+			if token, found := strings.CutPrefix(protocol, sessionTokenProtocol); found {
+				sessionToken = token
+				responseHeader = http.Header{"Sec-Websocket-Protocol": {protocol}}
+				break
+			}
+		}
+	}
+	if sessionToken == "" {
+		missingSessionToken(w)
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	updates, err := h.agentService.WatchSessionJob(ctx, sessionToken, chi.URLParam(r, "id"))
+	if err != nil {
+		handleJobError(w, r, err)
+		return
+	}
+	streamJob(w, r, cancel, updates, responseHeader)
+}
+
+func missingSessionToken(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeJSON(w, http.StatusUnauthorized, jsonError{
+		Code: http.StatusUnauthorized,
+		Err:  "the owner session token is required",
+	})
+}
+
+func handleJobError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, jsonError{Code: http.StatusNotFound, Err: err.Error()})
+	case errors.Is(err, jobs.ErrJobNotDead):
+		writeJSON(w, http.StatusConflict, jsonError{Code: http.StatusConflict, Err: err.Error()})
+	default:
+		handleError(w, r, err)
+	}
+}
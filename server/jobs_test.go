@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/jobs"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+type refreshedCredential struct{}
+
+func (refreshedCredential) Process(context.Context, string, string, string) (*verifiable.W3CCredential, error) {
+	return &verifiable.W3CCredential{ID: "urn:uuid:refreshed"}, nil
+}
+
+func TestWatchJob(t *testing.T) {
+	queue := jobs.NewQueue(memory.NewStore(), refreshedCredential{})
+	h := NewHandlers(nil, nil, WithAdminToken("secret"), WithJobs(queue))
+	srv := httptest.NewServer(h.adminRouter())
+	defer srv.Close()
+
+	job, err := queue.Enqueue(context.Background(), "issuer", "owner", "credential")
+	require.NoError(t, err)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/jobs/"
+	header := http.Header{"Authorization": {"Bearer secret"}}
+
+	_, resp, err := websocket.DefaultDialer.Dial(url+"missing/watch", header)
+	require.Error(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	conn, resp, err := websocket.DefaultDialer.Dial(url+job.ID+"/watch", header)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	var update storage.Job
+	require.NoError(t, conn.ReadJSON(&update))
+	require.Equal(t, storage.JobStatusPending, update.Status)
+
+	queue.RunOnce(context.Background())
+	for update.Status != storage.JobStatusSucceeded {
+		require.NoError(t, conn.ReadJSON(&update))
+	}
+	require.Contains(t, string(update.Result), "urn:uuid:refreshed")
+
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+}
+
+func TestGetSessionJob(t *testing.T) {
+	store := memory.NewStore()
+	queue := jobs.NewQueue(store, refreshedCredential{})
+	agent := service.NewAgentService(nil, nil, service.WithAsyncRefresh(queue, store, time.Hour))
+	h := NewHandlers(agent, nil)
+	router := chi.NewRouter()
+	router.Group(h.sessionJobRoutes)
+
+	job, err := queue.Enqueue(context.Background(), "issuer", "owner", "credential")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		header       string
+		expectedCode int
+		expectedErr  int
+	}{
+		{
+			name:         "Missing session token",
+			expectedCode: http.StatusUnauthorized,
+			expectedErr:  http.StatusUnauthorized,
+		},
+		{
+			name:         "Unknown session token",
+			header:       "Bearer unknown",
+			expectedCode: http.StatusUnauthorized,
+			expectedErr:  service.CodeInvalidOwnerSession,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, http.NoBody)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
+			var body jsonError
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+			require.Equal(t, tt.expectedErr, body.Code)
+		})
+	}
+}
+
+func TestWatchSessionJob(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	queue := jobs.NewQueue(store, refreshedCredential{})
+	agent := service.NewAgentService(nil, nil, service.WithAsyncRefresh(queue, store, time.Hour))
+	h := NewHandlers(agent, nil)
+	router := chi.NewRouter()
+	router.Group(h.sessionJobRoutes)
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	// an owner session as opened by a refresh message
+	sum := sha256.Sum256([]byte("token"))
+	session := hex.EncodeToString(sum[:])
+	_, err := store.PutIdempotency(ctx, storage.IdempotencyRecord{
+		Key:       "async:session:" + session,
+		Response:  []byte(`{"owner":"owner"}`),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	job, err := queue.EnqueueForSession(ctx, session, "issuer", "owner", "credential")
+	require.NoError(t, err)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/jobs/" + job.ID + "/watch"
+	tests := []struct {
+		name             string
+		header           http.Header
+		subprotocols     []string
+		expectedCode     int
+		expectedProtocol string
+	}{
+		{
+			name:         "Missing session token",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Unknown session token",
+			subprotocols: []string{"bearer.unknown"},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "Authorization header",
+			header:       http.Header{"Authorization": {"Bearer token"}},
+			expectedCode: http.StatusSwitchingProtocols,
+		},
+		{
+			name:             "Subprotocol",
+			subprotocols:     []string{"json", "bearer.token"},
+			expectedCode:     http.StatusSwitchingProtocols,
+			expectedProtocol: "bearer.token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := websocket.Dialer{Subprotocols: tt.subprotocols}
+			conn, resp, err := dialer.Dial(url, tt.header)
+			require.Equal(t, tt.expectedCode, resp.StatusCode)
+			if tt.expectedCode != http.StatusSwitchingProtocols {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer conn.Close()
+			require.Equal(t, tt.expectedProtocol, conn.Subprotocol())
+
+			var update storage.Job
+			require.NoError(t, conn.ReadJSON(&update))
+			require.Equal(t, job.ID, update.ID)
+		})
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer token"}})
+	require.NoError(t, err)
+	defer conn.Close()
+	queue.RunOnce(ctx)
+	var update storage.Job
+	for update.Status != storage.JobStatusSucceeded {
+		require.NoError(t, conn.ReadJSON(&update))
+	}
+	require.Contains(t, string(update.Result), "urn:uuid:refreshed")
+}
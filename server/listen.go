@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Listener addresses, besides TCP host:port ones.
+const (
+	// unixPrefix is followed by the path of a Unix socket.
+	unixPrefix = "unix:"
+	// systemdAddr selects the sockets passed by systemd socket activation,
+	// or the ones with a name with 'systemd:<name>'.
+	systemdAddr = "systemd"
+)
+
+// firstActivatedFD is the first file descriptor systemd passes sockets on.
+const firstActivatedFD = 3
+
+// listen opens the listeners of addr: a Unix socket for 'unix:<path>', the
+// sockets passed by systemd for 'systemd' or 'systemd:<name>', a TCP socket
+// otherwise. A stale Unix socket left by a previous run is replaced.
+func listen(addr string) ([]net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, unixPrefix):
+		path := strings.TrimPrefix(addr, unixPrefix)
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, errors.Errorf("failed to remove stale socket: %v", err)
+			}
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	case addr == systemdAddr || strings.HasPrefix(addr, systemdAddr+":"):
+		name := strings.TrimPrefix(strings.TrimPrefix(addr, systemdAddr), ":")
+		sockets, err := systemdSockets()
+		if err != nil {
+			return nil, err
+		}
+		var listeners []net.Listener
+		for _, socket := range sockets {
+			if name == "" || socket.name == name {
+				listeners = append(listeners, socket.listener)
+			}
+		}
+		if len(listeners) == 0 {
+			return nil, errors.Errorf("no socket named '%s' passed by systemd", name)
+		}
+		return listeners, nil
+	default:
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	}
+}
+
+type activatedSocket struct {
+	name     string
+	listener net.Listener
+}
+
+// systemdSockets are the sockets passed to the process by systemd socket
+// activation, read once from LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES.
+var systemdSockets = sync.OnceValues(func() ([]activatedSocket, error) {
+	return activatedSockets(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"), firstActivatedFD)
+})
+
+func activatedSockets(pid, fds, names string, first int) ([]activatedSocket, error) {
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, errors.New("no sockets passed by systemd")
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 1 {
+		return nil, errors.Errorf("invalid LISTEN_FDS '%s'", fds)
+	}
+	fdNames := strings.Split(names, ":")
+	sockets := make([]activatedSocket, 0, n)
+	for i := 0; i < n; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(first+i)
+		if i < len(fdNames) && fdNames[i] != "" {
+			name = fdNames[i]
+		}
+		file := os.NewFile(uintptr(first+i), name)
+		l, err := net.FileListener(file)
+		// the listener has its own copy of the descriptor
+		_ = file.Close()
+		if err != nil {
+			return nil, errors.Errorf("socket '%s' passed by systemd: %v", name, err)
+		}
+		sockets = append(sockets, activatedSocket{name: name, listener: l})
+	}
+	return sockets, nil
+}
+
+type unixPeerKey struct{}
+
+// connContext marks the requests of connections on Unix sockets, whose
+// peers have no address.
+func connContext(ctx context.Context, conn net.Conn) context.Context {
+	if _, ok := conn.(*net.UnixConn); ok {
+		return context.WithValue(ctx, unixPeerKey{}, true)
+	}
+	return ctx
+}
+
+func unixPeer(ctx context.Context) bool {
+	unix, _ := ctx.Value(unixPeerKey{}).(bool)
+	return unix
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListen_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refresh.sock")
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	listeners, err := listen(unixPrefix + path)
+	require.NoError(t, err)
+	require.Len(t, listeners, 1)
+
+	networks, err := httpclient.ParseNetworks([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	srv := &http.Server{
+		Handler: allowNetworks(networks)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})),
+		ConnContext: connContext,
+	}
+	go func() { _ = srv.Serve(listeners[0]) }()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://refresh/metrics")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestActivatedSockets(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		name          string
+		pid           string
+		fds           string
+		names         string
+		expectedNames []string
+		expectedErr   string
+	}{
+		{name: "Named socket", pid: pid, fds: "1", names: "public", expectedNames: []string{"public"}},
+		{name: "Unnamed socket", pid: pid, fds: "1", expectedNames: []string{"LISTEN_FD_"}},
+		{name: "Other process", pid: "1", fds: "1", expectedErr: "no sockets passed by systemd"},
+		{name: "Invalid count", pid: pid, fds: "none", expectedErr: "invalid LISTEN_FDS 'none'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tcp, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			defer tcp.Close()
+			file, err := tcp.(*net.TCPListener).File()
+			require.NoError(t, err)
+			defer file.Close()
+			fd := int(file.Fd())
+
+			sockets, err := activatedSockets(tt.pid, tt.fds, tt.names, fd)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, sockets, len(tt.expectedNames))
+			defer sockets[0].listener.Close()
+			expected := tt.expectedNames[0]
+			if expected == "LISTEN_FD_" {
+				expected += strconv.Itoa(fd)
+			}
+			require.Equal(t, expected, sockets[0].name)
+			require.Equal(t, tcp.Addr().String(), sockets[0].listener.Addr().String())
+		})
+	}
+}
+
+func TestListen_SystemdWithoutActivation(t *testing.T) {
+	_, err := listen("systemd:public")
+	require.EqualError(t, err, "no sockets passed by systemd")
+}
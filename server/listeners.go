@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/pkg/errors"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// WithAdminListener serves the admin API on addr instead of the public
+// listener.
+func WithAdminListener(addr string) HandlerOption {
+	return func(h *Handlers) {
+		h.adminAddr = addr
+	}
+}
+
+// WithMetricsListener serves the metrics, and pprof when enabled, on addr
+// instead of the public listener.
+func WithMetricsListener(addr string) HandlerOption {
+	return func(h *Handlers) {
+		h.metricsAddr = addr
+	}
+}
+
+// WithAdminAllowedNetworks answers admin API requests only from peers in
+// networks. All peers are allowed when networks is empty.
+func WithAdminAllowedNetworks(networks []*net.IPNet) HandlerOption {
+	return func(h *Handlers) {
+		h.adminNetworks = networks
+	}
+}
+
+// WithMetricsAllowedNetworks answers metrics and pprof requests only from
+// peers in networks. All peers are allowed when networks is empty.
+func WithMetricsAllowedNetworks(networks []*net.IPNet) HandlerOption {
+	return func(h *Handlers) {
+		h.metricsNetworks = networks
+	}
+}
+
+// WithPprof serves the runtime profiles under /debug/pprof on the metrics
+// listener, which must be separate from the public one.
+func WithPprof() HandlerOption {
+	return func(h *Handlers) {
+		h.pprof = true
+	}
+}
+
+type peerKey struct{}
+
+// rememberPeer keeps the address of the connected peer before RealIP
+// replaces it with the forwarded address, which clients can spoof.
+func rememberPeer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), peerKey{}, r.RemoteAddr)))
+	})
+}
+
+func peerIP(r *http.Request) net.IP {
+	addr, ok := r.Context().Value(peerKey{}).(string)
+	if !ok {
+		addr = r.RemoteAddr
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.ParseIP(host)
+}
+
+// allowNetworks rejects requests of peers outside networks with 403. Peers
+// on Unix sockets are allowed, the permissions of the socket restrict them.
+func allowNetworks(networks []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(networks) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if unixPeer(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ip := peerIP(r); ip != nil {
+				for _, network := range networks {
+					if network.Contains(ip) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			writeJSON(w, http.StatusForbidden, jsonError{
+				Code: http.StatusForbidden,
+				Err:  "address not allowed",
+			})
+		})
+	}
+}
+
+// listeners are the routers by address. The public router is created by
+// Run, the others when a surface is moved to its own address.
+type listeners map[string]chi.Router
+
+func (l listeners) router(addr, public string) chi.Router {
+	if addr == "" {
+		addr = public
+	}
+	if router, ok := l[addr]; ok {
+		return router
+	}
+	router := chi.NewRouter()
+	router.Use(rememberPeer)
+	router.Use(middleware.RequestID)
+	router.Use(zapContextLogger)
+	router.Use(middleware.Recoverer)
+	router.Use(reportPanics)
+	l[addr] = router
+	return router
+}
+
+// serve runs a server per listener and returns the first error. The sockets
+// of all listeners, and the UDP sockets of HTTP/3, are opened before any is
+// served, and closed again when one of them can't be opened.
+func (l listeners) serve(public publicListener) error {
+	sockets := make(map[string][]net.Listener, len(l))
+	count := 0
+	var (
+		quicServer  *http3.Server
+		quicSockets []net.PacketConn
+	)
+	for addr, router := range l {
+		lns, err := listen(addr)
+		if err == nil {
+			sockets[addr] = lns
+			count += len(lns)
+			if addr == public.addr {
+				quicServer, quicSockets, err = public.listenQUIC(router, lns)
+			}
+		}
+		if err != nil {
+			for _, opened := range sockets {
+				for _, ln := range opened {
+					_ = ln.Close()
+				}
+			}
+			for _, conn := range quicSockets {
+				_ = conn.Close()
+			}
+			return errors.Wrapf(err, "listener '%s'", addr)
+		}
+	}
+	errs := make(chan error, count+len(quicSockets))
+	for addr, router := range l {
+		isPublic := addr == public.addr
+		handler := http.Handler(router)
+		if isPublic && quicServer != nil {
+			handler = announceHTTP3(quicServer, router)
+			for _, conn := range quicSockets {
+				logger.DefaultLogger.Infof("HTTP/3 server starting on host '%s' (%s)", addr, conn.LocalAddr())
+				go func(addr string, conn net.PacketConn) {
+					errs <- errors.Wrapf(quicServer.Serve(conn), "listener '%s'", addr)
+				}(addr, conn)
+			}
+		}
+		httpServer := public.server(handler, isPublic)
+		for _, ln := range sockets[addr] {
+			logger.DefaultLogger.Infof("Server starting on host '%s' (%s)", addr, ln.Addr())
+			go func(addr string, ln net.Listener) {
+				errs <- errors.Wrapf(public.serve(httpServer, ln, isPublic), "listener '%s'", addr)
+			}(addr, ln)
+		}
+	}
+	return <-errs
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowNetworks(t *testing.T) {
+	networks, err := httpclient.ParseNetworks([]string{"10.0.0.0/8", "::1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		networks     bool
+		remoteAddr   string
+		forwardedFor string
+		expectedCode int
+	}{
+		{name: "Allowed network", networks: true, remoteAddr: "10.1.2.3:41000", expectedCode: http.StatusOK},
+		{name: "Allowed address", networks: true, remoteAddr: "[::1]:41000", expectedCode: http.StatusOK},
+		{name: "Other network", networks: true, remoteAddr: "203.0.113.5:41000", expectedCode: http.StatusForbidden},
+		{
+			name:         "Spoofed forwarded address",
+			networks:     true,
+			remoteAddr:   "203.0.113.5:41000",
+			forwardedFor: "10.0.0.1",
+			expectedCode: http.StatusForbidden,
+		},
+		{name: "No allowlist", remoteAddr: "203.0.113.5:41000", expectedCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := chi.NewRouter()
+			router.Use(rememberPeer)
+			router.Use(middleware.RealIP)
+			allowed := networks
+			if !tt.networks {
+				allowed = nil
+			}
+			router.With(allowNetworks(allowed)).Get("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
+
+func TestListeners(t *testing.T) {
+	public := chi.NewRouter()
+	servers := listeners{":8002": public}
+	require.Equal(t, public, servers.router("", ":8002"))
+	require.Equal(t, public, servers.router(":8002", ":8002"))
+
+	internal := servers.router(":9090", ":8002")
+	require.NotEqual(t, public, internal)
+	require.Equal(t, internal, servers.router(":9090", ":8002"))
+	require.Len(t, servers, 2)
+}
+
+func TestListeners_ServeClosesOnFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	require.NoError(t, ln.Close())
+
+	// whichever address is opened first, the other one is taken
+	servers := listeners{"127.0.0.1:" + port: chi.NewRouter(), "0.0.0.0:" + port: chi.NewRouter()}
+	require.ErrorContains(t, servers.serve(publicListener{}), "address already in use")
+
+	ln, err = net.Listen("tcp", "127.0.0.1:"+port)
+	require.NoError(t, err)
+	require.NoError(t, ln.Close())
+}
+
+func TestRun_PprofOnPublicListener(t *testing.T) {
+	h := NewHandlers(nil, nil, WithPprof())
+	require.EqualError(t, h.Run(":0"), "pprof is only served on a separate metrics listener")
+	h = NewHandlers(nil, nil, WithPprof(), WithMetricsListener(":0"))
+	require.EqualError(t, h.Run(":0"), "pprof is only served on a separate metrics listener")
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []HandlerOption
+		expectedCode int
+	}{
+		{name: "Metrics not served on the public router", expectedCode: http.StatusNotFound},
+		{
+			name:         "Metrics on the public router for allowed networks",
+			opts:         []HandlerOption{WithMetricsAllowedNetworks(testNetworks(t, "192.0.2.0/24"))},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Metrics on the public router for other networks",
+			opts:         []HandlerOption{WithMetricsAllowedNetworks(testNetworks(t, "10.0.0.0/8"))},
+			expectedCode: http.StatusForbidden,
+		},
+		{name: "Metrics on their own listener", opts: []HandlerOption{WithMetricsListener(":9090")}, expectedCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, err := NewHandlers(nil, nil, tt.opts...).Handler()
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mock", http.NoBody))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+			require.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
+
+func testNetworks(t *testing.T, values ...string) []*net.IPNet {
+	t.Helper()
+	networks, err := httpclient.ParseNetworks(values)
+	require.NoError(t, err)
+	return networks
+}
@@ -2,10 +2,15 @@ package server
 
 import (
 	"fmt"
+	"mime"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/0xPolygonID/refresh-service/correlation"
 	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/reporting"
+	"github.com/0xPolygonID/refresh-service/service"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
@@ -20,10 +25,42 @@ func zapContextLogger(next http.Handler) http.Handler {
 				"path", r.URL.Path,
 				"remoteAddr", r.RemoteAddr,
 				"responseTime", fmt.Sprintf("%d ms", time.Since(t1).Milliseconds()),
-				"status", ww.Status())
+				"status", ww.Status(),
+				"requestId", correlation.FromContext(r.Context()))
 		}()
 
 		next.ServeHTTP(ww, r)
 	}
 	return http.HandlerFunc(fn)
 }
+
+func reportPanics(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rvr := recover(); rvr != nil {
+				if rvr != http.ErrAbortHandler { //nolint:errorlint // panic value is compared as in chi Recoverer
+					reporting.DefaultReporter.Recover(r.Context(), rvr)
+				}
+				panic(rvr)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// credentialFormat passes the wallet preference for JWT credentials from the
+// Accept header to the agent service.
+func credentialFormat(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+			if err == nil && mediaType == service.MediaTypeJWTVC {
+				r = r.WithContext(service.WithPreferredFormat(r.Context(), service.MediaTypeJWTVC))
+				break
+			}
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+)
+
+// oauthError is the OAuth 2.0 error response of the OpenID4VCI token and
+// credential endpoints.
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (h *Handlers) openID4VCIRoutes(router chi.Router) {
+	router.Get("/.well-known/openid-credential-issuer", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, h.agentService.CredentialIssuerMetadata())
+	})
+	router.Get("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, h.agentService.AuthorizationServerMetadata())
+	})
+	router.Post("/openid4vci/token", h.openID4VCIToken)
+	router.Post("/openid4vci/credential", h.openID4VCICredential)
+}
+
+func (h *Handlers) openID4VCIToken(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 16*1024)
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_request", Description: err.Error()})
+		return
+	}
+	if grantType := r.PostForm.Get("grant_type"); grantType != service.PreAuthorizedCodeGrantType {
+		writeJSON(w, http.StatusBadRequest, oauthError{
+			Error:       "unsupported_grant_type",
+			Description: "only the pre-authorized code grant is supported",
+		})
+		return
+	}
+	token, err := h.agentService.ExchangePreAuthorizedCode(r.Context(),
+		r.PostForm.Get("pre-authorized_code"), r.PostForm.Get("tx_code"))
+	if err != nil {
+		h.openID4VCIError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, token)
+}
+
+func (h *Handlers) openID4VCICredential(w http.ResponseWriter, r *http.Request) {
+	accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || accessToken == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, oauthError{Error: "invalid_token"})
+		return
+	}
+	var req service.CredentialRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, oauthError{Error: "invalid_credential_request", Description: err.Error()})
+		return
+	}
+	credential, err := h.agentService.IssueOpenID4VCICredential(r.Context(), accessToken, req)
+	if err != nil {
+		h.openID4VCIError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, credential)
+}
+
+// openID4VCIError answers protocol errors in the OAuth format. Errors of
+// the refresh itself are answered as on the other refresh routes.
+func (h *Handlers) openID4VCIError(w http.ResponseWriter, r *http.Request, err error) {
+	var body oauthError
+	switch service.ErrorCode(err) {
+	case service.CodeInvalidGrant:
+		body.Error = "invalid_grant"
+	case service.CodeInvalidAccessToken:
+		body.Error = "invalid_token"
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	case service.CodeUnsupportedFormat:
+		body.Error = "unsupported_credential_format"
+	default:
+		handleError(w, r, err)
+		return
+	}
+	body.Description = err.Error()
+	writeJSON(w, logError(r, err), body)
+}
+
+func (h *Handlers) createCredentialOffer(w http.ResponseWriter, r *http.Request) {
+	var req service.CredentialOfferRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		handleError(w, r, errors.Wrapf(service.ErrInvalidProtocolMessage, "failed to decode request: %v", err))
+		return
+	}
+	offer, err := h.agentService.CreateCredentialOffer(r.Context(), req)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, offer)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenID4VCIRoutes(t *testing.T) {
+	as := service.NewAgentService(nil, nil, service.WithOpenID4VCI(memory.NewStore(), service.OpenID4VCISettings{
+		CredentialIssuer: "https://refresh.example.com",
+	}))
+	router := chi.NewRouter()
+	NewHandlers(as, nil).openID4VCIRoutes(router)
+
+	form := func(values url.Values) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/openid4vci/token", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+	tests := []struct {
+		name           string
+		request        *http.Request
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Issuer metadata",
+			request:        httptest.NewRequest(http.MethodGet, "/.well-known/openid-credential-issuer", http.NoBody),
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"credential_issuer": "https://refresh.example.com",
+				"credential_endpoint": "https://refresh.example.com/openid4vci/credential",
+				"credential_configurations_supported": {"iden3_w3c": {"format": "ldp_vc"}, "iden3_jwt_vc_json": {"format": "jwt_vc_json"}}}`,
+		},
+		{
+			name:           "Authorization grant",
+			request:        form(url.Values{"grant_type": {"authorization_code"}, "code": {"abc"}}),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "unsupported_grant_type", "error_description": "only the pre-authorized code grant is supported"}`,
+		},
+		{
+			name: "Unknown pre-authorized code",
+			request: form(url.Values{
+				"grant_type":          {service.PreAuthorizedCodeGrantType},
+				"pre-authorized_code": {"abc"},
+			}),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error": "invalid_grant", "error_description": "unknown or expired: invalid grant"}`,
+		},
+		{
+			name:           "Credential without token",
+			request:        httptest.NewRequest(http.MethodPost, "/openid4vci/credential", strings.NewReader(`{}`)),
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error": "invalid_token"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, tt.request)
+			require.Equal(t, tt.expectedStatus, rec.Code)
+			require.JSONEq(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
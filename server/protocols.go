@@ -0,0 +1,132 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// WithTLS serves the public listener over TLS with the PEM certificate and
+// key in certFile and keyFile. Clients negotiate HTTP/2 or HTTP/1.1 with
+// ALPN.
+func WithTLS(certFile, keyFile string) HandlerOption {
+	return func(h *Handlers) {
+		h.public.certFile, h.public.keyFile = certFile, keyFile
+	}
+}
+
+// WithH2C accepts HTTP/2 without TLS (h2c, with prior knowledge or an
+// upgrade) on the public listener, besides HTTP/1.1, e.g. from a reverse
+// proxy or load balancer speaking HTTP/2 to its backends. It is ignored
+// with TLS.
+func WithH2C() HandlerOption {
+	return func(h *Handlers) {
+		h.public.h2c = true
+	}
+}
+
+// WithHTTP3 serves HTTP/3 over QUIC besides the TLS listener, on the UDP
+// ports with the numbers of its TCP ports. Responses over TCP announce it in
+// Alt-Svc, so clients switch to it for their next requests. It needs TLS.
+func WithHTTP3() HandlerOption {
+	return func(h *Handlers) {
+		h.public.http3 = true
+	}
+}
+
+// publicListener are the protocols of the public listener. The admin and
+// metrics listeners serve HTTP/1.1 without TLS when they have their own
+// address.
+type publicListener struct {
+	addr              string
+	certFile, keyFile string
+	h2c               bool
+	http3             bool
+}
+
+func (p publicListener) validate() error {
+	if (p.certFile == "") != (p.keyFile == "") {
+		return errors.New("the TLS certificate and key are set together")
+	}
+	if p.http3 && !p.tls() {
+		return errors.New("HTTP/3 needs the TLS certificate and key")
+	}
+	return nil
+}
+
+func (p publicListener) tls() bool {
+	return p.certFile != ""
+}
+
+// server returns the server of a listener, the public one when public.
+func (p publicListener) server(handler http.Handler, public bool) *http.Server {
+	if public && p.h2c && !p.tls() {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ConnContext:       connContext,
+	}
+}
+
+// serve serves srv on ln, over TLS for the public listener when it has a
+// certificate.
+func (p publicListener) serve(srv *http.Server, ln net.Listener, public bool) error {
+	if public && p.tls() {
+		return srv.ServeTLS(ln, p.certFile, p.keyFile)
+	}
+	return srv.Serve(ln)
+}
+
+// listenQUIC opens a UDP socket for HTTP/3 on the address of every TCP
+// socket in lns and returns the HTTP/3 server of handler, or nil without
+// HTTP/3. Unix sockets have no HTTP/3 counterpart.
+func (p publicListener) listenQUIC(handler http.Handler, lns []net.Listener) (*http3.Server, []net.PacketConn, error) {
+	if !p.http3 {
+		return nil, nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to load the TLS certificate")
+	}
+	var conns []net.PacketConn
+	for _, ln := range lns {
+		addr, ok := ln.Addr().(*net.TCPAddr)
+		if !ok {
+			continue
+		}
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: addr.IP, Port: addr.Port, Zone: addr.Zone})
+		if err != nil {
+			for _, opened := range conns {
+				_ = opened.Close()
+			}
+			return nil, nil, errors.Wrap(err, "failed to listen for HTTP/3")
+		}
+		conns = append(conns, conn)
+	}
+	srv := &http3.Server{
+		Handler: handler,
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS13,
+		}),
+	}
+	return srv, conns, nil
+}
+
+// announceHTTP3 advertises srv in the Alt-Svc header of the responses of
+// next.
+func announceHTTP3(srv *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fails only before srv listens, when there is nothing to announce
+		_ = srv.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
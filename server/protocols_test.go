@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+// writeServerCertificate writes a self-signed certificate for 127.0.0.1 and
+// its key to dir.
+func writeServerCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "refresh-service"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestPublicListener(t *testing.T) {
+	certFile, keyFile := writeServerCertificate(t, t.TempDir())
+	h2cTransport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	tlsTransport := &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // self-signed test certificate
+		ForceAttemptHTTP2: true,
+	}
+
+	tests := []struct {
+		name          string
+		listener      publicListener
+		public        bool
+		scheme        string
+		transport     http.RoundTripper
+		expectedProto string
+		expectedErr   bool
+	}{
+		{
+			name:          "HTTP/1.1",
+			public:        true,
+			scheme:        "http",
+			transport:     &http.Transport{},
+			expectedProto: "HTTP/1.1",
+		},
+		{
+			name:          "h2c",
+			listener:      publicListener{h2c: true},
+			public:        true,
+			scheme:        "http",
+			transport:     h2cTransport,
+			expectedProto: "HTTP/2.0",
+		},
+		{
+			name:        "h2c on the admin listener",
+			listener:    publicListener{h2c: true},
+			scheme:      "http",
+			transport:   h2cTransport,
+			expectedErr: true,
+		},
+		{
+			name:          "TLS",
+			listener:      publicListener{certFile: certFile, keyFile: keyFile},
+			public:        true,
+			scheme:        "https",
+			transport:     tlsTransport,
+			expectedProto: "HTTP/2.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			srv := tt.listener.server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(r.Proto))
+			}), tt.public)
+			go func() { _ = tt.listener.serve(srv, ln, tt.public) }()
+			defer srv.Close()
+
+			resp, err := (&http.Client{Transport: tt.transport, Timeout: 5 * time.Second}).
+				Get(tt.scheme + "://" + ln.Addr().String() + "/")
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.expectedProto, resp.Proto)
+		})
+	}
+}
+
+func TestPublicListener_Validate(t *testing.T) {
+	require.NoError(t, publicListener{}.validate())
+	require.NoError(t, publicListener{certFile: "server.crt", keyFile: "server.key"}.validate())
+	require.EqualError(t, publicListener{certFile: "server.crt"}.validate(), "the TLS certificate and key are set together")
+	require.NoError(t, publicListener{certFile: "server.crt", keyFile: "server.key", http3: true}.validate())
+	require.EqualError(t, publicListener{http3: true}.validate(), "HTTP/3 needs the TLS certificate and key")
+}
+
+func TestPublicListener_HTTP3(t *testing.T) {
+	certFile, keyFile := writeServerCertificate(t, t.TempDir())
+	listener := publicListener{certFile: certFile, keyFile: keyFile, http3: true}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	quicServer, conns, err := listener.listenQUIC(handler, []net.Listener{ln})
+	require.NoError(t, err)
+	require.Len(t, conns, 1)
+	require.Equal(t, ln.Addr().String(), conns[0].LocalAddr().String())
+	go func() { _ = quicServer.Serve(conns[0]) }()
+	defer quicServer.Close()
+	srv := listener.server(announceHTTP3(quicServer, handler), true)
+	go func() { _ = listener.serve(srv, ln, true) }()
+	defer srv.Close()
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true} //nolint:gosec // self-signed test certificate
+	url := "https://" + ln.Addr().String() + "/"
+
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}, Timeout: 5 * time.Second}).Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	require.NoError(t, err)
+	require.Contains(t, resp.Header.Get("Alt-Svc"), `h3=":`+port+`"`)
+
+	transport := &http3.Transport{TLSClientConfig: tlsConfig}
+	defer transport.Close()
+	resp, err = (&http.Client{Transport: transport, Timeout: 5 * time.Second}).Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "HTTP/3.0", resp.Proto)
+}
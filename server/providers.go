@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/0xPolygonID/refresh-service/audit"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/pkg/errors"
+)
+
+// ProviderRegistry is the provider configuration which can be reloaded
+// through the admin API. Reload returns the credential types configured
+// after the reload. Fingerprints identify the configuration of every
+// credential type, so reloads can be audited.
+type ProviderRegistry interface {
+	Reload() ([]string, error)
+	Fingerprints() map[string]string
+}
+
+// WithProviderRegistry enables reloading the provider configuration
+// through the admin API.
+func WithProviderRegistry(registry ProviderRegistry) HandlerOption {
+	return func(h *Handlers) {
+		h.providerRegistry = registry
+	}
+}
+
+type providerConfigError struct {
+	jsonError
+	Problems map[string][]string `json:"problems"`
+}
+
+func (h *Handlers) reloadProviders(w http.ResponseWriter, r *http.Request) {
+	name, tenant, ok := h.providerTenant(w, r)
+	if !ok {
+		return
+	}
+	registry := h.providerRegistry
+	if tenant != nil {
+		registry = tenant
+	}
+	before := registry.Fingerprints()
+	types, err := registry.Reload()
+	var configErr *flexiblehttp.ConfigError
+	switch {
+	case errors.As(err, &configErr):
+		writeJSON(w, http.StatusUnprocessableEntity, providerConfigError{
+			jsonError: jsonError{
+				Code: http.StatusUnprocessableEntity,
+				Err:  "provider configuration is invalid, the current one is kept",
+			},
+			Problems: configErr.Problems,
+		})
+		return
+	case err != nil:
+		handleError(w, r, err)
+		return
+	}
+	principal, _ := PrincipalFromContext(r.Context())
+	h.configAudit.Providers(r.Context(), principal.Name, audit.SourceAdminAPI,
+		tenantFingerprints(name, before), tenantFingerprints(name, registry.Fingerprints()))
+	writeJSON(w, http.StatusOK, map[string][]string{"credentialTypes": types})
+}
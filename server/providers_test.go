@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type mockProviderRegistry struct {
+	types        []string
+	err          error
+	fingerprints func() map[string]string
+}
+
+func (m mockProviderRegistry) Reload() ([]string, error) {
+	return m.types, m.err
+}
+
+func (m mockProviderRegistry) Fingerprints() map[string]string {
+	if m.fingerprints == nil {
+		return nil
+	}
+	return m.fingerprints()
+}
+
+func TestReloadProviders(t *testing.T) {
+	tests := []struct {
+		name             string
+		registry         mockProviderRegistry
+		expectedCode     int
+		expectedTypes    []string
+		expectedProblems map[string][]string
+	}{
+		{
+			name:          "Reloaded",
+			registry:      mockProviderRegistry{types: []string{"Balance", "KYCAge"}},
+			expectedCode:  http.StatusOK,
+			expectedTypes: []string{"Balance", "KYCAge"},
+		},
+		{
+			name: "Invalid configuration",
+			registry: mockProviderRegistry{err: &flexiblehttp.ConfigError{
+				Problems: map[string][]string{"KYCAge": {"unsupported method 'DELETE'"}},
+			}},
+			expectedCode:     http.StatusUnprocessableEntity,
+			expectedProblems: map[string][]string{"KYCAge": {"unsupported method 'DELETE'"}},
+		},
+		{
+			name:         "Unreadable configuration",
+			registry:     mockProviderRegistry{err: errors.New("failed to read provider configuration")},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandlers(nil, nil, WithAdminToken("secret"), WithProviderRegistry(tt.registry))
+			req := httptest.NewRequest(http.MethodPost, "/providers/reload", http.NoBody)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			h.adminRouter().ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
+
+			var response struct {
+				CredentialTypes []string            `json:"credentialTypes"`
+				Problems        map[string][]string `json:"problems"`
+			}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+			require.Equal(t, tt.expectedTypes, response.CredentialTypes)
+			require.Equal(t, tt.expectedProblems, response.Problems)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Role grants access to the admin API. Every role includes the ones below
+// it.
+type Role int
+
+const (
+	// RoleViewer reads statistics, history, jobs and caches.
+	RoleViewer Role = iota + 1
+	// RoleOperator also enqueues and requeues jobs, runs batches and
+	// flushes caches.
+	RoleOperator
+	// RoleAdmin also changes the configuration of the service.
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleViewer:   "viewer",
+	RoleOperator: "operator",
+	RoleAdmin:    "admin",
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// ParseRole parses a role name, e.g. 'viewer'.
+func ParseRole(name string) (Role, error) {
+	for role, n := range roleNames {
+		if n == name {
+			return role, nil
+		}
+	}
+	return 0, errors.Errorf("unknown role '%s', roles are viewer, operator and admin", name)
+}
+
+// AdminToken is a bearer token of the admin API. Name identifies who holds
+// it.
+type AdminToken struct {
+	Name  string
+	Role  Role
+	Token string
+}
+
+// ParseAdminTokens parses tokens keyed by name in the 'role:token' format.
+func ParseAdminTokens(values map[string]string) ([]AdminToken, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tokens := make([]AdminToken, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, name := range names {
+		roleName, token, ok := strings.Cut(values[name], ":")
+		if !ok || token == "" {
+			return nil, errors.Errorf("admin token '%s' is not in the 'role:token' format", name)
+		}
+		role, err := ParseRole(roleName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "admin token '%s'", name)
+		}
+		if seen[token] {
+			return nil, errors.Errorf("admin token '%s' is used by another name", name)
+		}
+		seen[token] = true
+		tokens = append(tokens, AdminToken{Name: name, Role: role, Token: token})
+	}
+	return tokens, nil
+}
+
+// WithAdminTokens enables the admin API for tokens with their own role.
+func WithAdminTokens(tokens ...AdminToken) HandlerOption {
+	return func(h *Handlers) {
+		h.adminTokens = append(h.adminTokens, tokens...)
+	}
+}
+
+// Principal is the holder of the token of an admin request.
+type Principal struct {
+	Name string
+	Role Role
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal of an admin request.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// adminAuth identifies the principal of the bearer token. Every token is
+// compared, so the time taken doesn't tell which one matched.
+func (h *Handlers) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		var (
+			principal Principal
+			found     bool
+		)
+		for _, token := range h.adminTokens {
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token.Token)) == 1 {
+				principal, found = Principal{Name: token.Name, Role: token.Role}, true
+			}
+		}
+		if !ok || !found {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, jsonError{
+				Code: http.StatusUnauthorized,
+				Err:  "invalid token",
+			})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+// requireRole rejects admin requests of principals below role.
+func requireRole(role Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := PrincipalFromContext(r.Context())
+			if principal.Role < role {
+				writeJSON(w, http.StatusForbidden, jsonError{
+					Code: http.StatusForbidden,
+					Err:  "the " + role.String() + " role is required",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
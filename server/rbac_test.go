@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAdminTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string]string
+		expected []AdminToken
+		err      string
+	}{
+		{
+			name:   "Valid",
+			values: map[string]string{"support": "viewer:t1", "oncall": "operator:t2:with:colons"},
+			expected: []AdminToken{
+				{Name: "oncall", Role: RoleOperator, Token: "t2:with:colons"},
+				{Name: "support", Role: RoleViewer, Token: "t1"},
+			},
+		},
+		{name: "Missing token", values: map[string]string{"support": "viewer"}, err: "admin token 'support' is not in the 'role:token' format"},
+		{name: "Unknown role", values: map[string]string{"support": "root:t1"}, err: "admin token 'support': unknown role 'root', roles are viewer, operator and admin"},
+		{name: "Shared token", values: map[string]string{"a": "viewer:t1", "b": "admin:t1"}, err: "admin token 'b' is used by another name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := ParseAdminTokens(tt.values)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, tokens)
+		})
+	}
+}
+
+func TestAdminRoles(t *testing.T) {
+	h := NewHandlers(nil, nil,
+		WithAdminToken("root"),
+		WithAdminTokens(
+			AdminToken{Name: "support", Role: RoleViewer, Token: "view"},
+			AdminToken{Name: "oncall", Role: RoleOperator, Token: "operate"},
+		),
+		WithStatistics(&staticStatistics{}),
+		WithCaches(map[string]CacheFlusher{"documents": CacheFlusherFunc(func(context.Context) (int64, error) {
+			return 0, nil
+		})}),
+		WithProviderRegistry(mockProviderRegistry{types: []string{"Balance"}}),
+	)
+	router := h.adminRouter()
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		method        string
+		path          string
+		expectedCode  int
+	}{
+		{name: "Viewer reads", token: "view", method: http.MethodGet, path: "/stats", expectedCode: http.StatusOK},
+		{name: "Viewer can't flush", token: "view", method: http.MethodPost, path: "/caches/flush", expectedCode: http.StatusForbidden},
+		{name: "Operator flushes", token: "operate", method: http.MethodPost, path: "/caches/flush", expectedCode: http.StatusOK},
+		{name: "Operator reads", token: "operate", method: http.MethodGet, path: "/stats", expectedCode: http.StatusOK},
+		{name: "Operator can't reload", token: "operate", method: http.MethodPost, path: "/providers/reload", expectedCode: http.StatusForbidden},
+		{name: "Admin reloads", token: "root", method: http.MethodPost, path: "/providers/reload", expectedCode: http.StatusOK},
+		{name: "Unknown token", token: "guess", method: http.MethodGet, path: "/stats", expectedCode: http.StatusUnauthorized},
+		{name: "Missing Bearer prefix", authorization: "root", method: http.MethodGet, path: "/stats", expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, http.NoBody)
+			authorization := "Bearer " + tt.token
+			if tt.authorization != "" {
+				authorization = tt.authorization
+			}
+			req.Header.Set("Authorization", authorization)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+		})
+	}
+}
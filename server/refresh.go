@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/pkg/errors"
+)
+
+// defaultMaxMessageBytes bounds agent messages unless configured.
+const defaultMaxMessageBytes = 1 << 20
+
+// WithMaxMessageBytes bounds the size of agent messages. Larger messages
+// are answered 413 as soon as the limit is read, so a request never holds
+// more than the limit.
+func WithMaxMessageBytes(limit int64) HandlerOption {
+	return func(h *Handlers) {
+		if limit > 0 {
+			h.maxMessageBytes = limit
+		}
+	}
+}
+
+func (h *Handlers) refresh(w http.ResponseWriter, r *http.Request) {
+	envelope, ok := h.readMessage(w, r)
+	if !ok {
+		return
+	}
+
+	response, err := h.agentService.Process(r.Context(), envelope)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(response)
+	if err != nil {
+		logger.DefaultLogger.Errorf("failed to write response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// readMessage reads the agent message in the request body, answering the
+// request when it can't be read.
+func (h *Handlers) readMessage(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	envelope, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxMessageBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, jsonError{
+			Code: http.StatusRequestEntityTooLarge,
+			Err:  fmt.Sprintf("message is larger than %d bytes", tooLarge.Limit),
+		})
+		return nil, false
+	}
+	if err != nil {
+		logger.DefaultLogger.Errorf("failed to read request body: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return envelope, true
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresh_MessageTooLarge(t *testing.T) {
+	h := NewHandlers(nil, nil, WithMaxMessageBytes(1024))
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(make([]byte, 2048)))
+	rec := httptest.NewRecorder()
+	h.refresh(rec, req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	require.JSONEq(t, `{"code": 413, "error": "message is larger than 1024 bytes"}`, rec.Body.String())
+}
+
+// BenchmarkRefresh_MessageTooLarge reports the bytes a request reads for
+// messages over the limit, which stay at the limit however large the
+// message is.
+func BenchmarkRefresh_MessageTooLarge(b *testing.B) {
+	h := NewHandlers(nil, nil)
+	for _, size := range []int{2 << 20, 16 << 20} {
+		message := make([]byte, size)
+		b.Run(byteSize(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(message))
+				rec := httptest.NewRecorder()
+				h.refresh(rec, req)
+				if rec.Code != http.StatusRequestEntityTooLarge {
+					b.Fatalf("unexpected status %d", rec.Code)
+				}
+			}
+		})
+	}
+}
+
+func byteSize(n int) string {
+	return strconv.Itoa(n>>20) + "MB"
+}
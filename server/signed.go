@@ -0,0 +1,37 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/pkg/errors"
+)
+
+func (h *Handlers) signedRefresh(w http.ResponseWriter, r *http.Request) {
+	var req service.SignedRefreshRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		handleError(w, r, errors.Wrapf(service.ErrInvalidProtocolMessage, "failed to decode request: %v", err))
+		return
+	}
+	result, err := h.agentService.ProcessSigned(r.Context(), req)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handlers) presentationRefresh(w http.ResponseWriter, r *http.Request) {
+	var req service.PresentationRefreshRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 256*1024)).Decode(&req); err != nil {
+		handleError(w, r, errors.Wrapf(service.ErrInvalidProtocolMessage, "failed to decode request: %v", err))
+		return
+	}
+	result, err := h.agentService.ProcessPresentation(r.Context(), req)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
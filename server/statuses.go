@@ -2,10 +2,13 @@ package server
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/0xPolygonID/refresh-service/correlation"
 	"github.com/0xPolygonID/refresh-service/logger"
-	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/reporting"
 	"github.com/0xPolygonID/refresh-service/service"
 	"github.com/pkg/errors"
 )
@@ -13,66 +16,135 @@ import (
 type jsonError struct {
 	Code int    `json:"code"`
 	Err  string `json:"error"`
+	// RetryAfter is the delay in seconds a throttling upstream asked for.
+	RetryAfter int `json:"retryAfter,omitempty"`
+	// Violations are the fields of an agent message failing the refresh
+	// protocol schema.
+	Violations []string `json:"violations,omitempty"`
 }
 
-func handleError(w http.ResponseWriter, err error) {
-	var (
-		message  string
-		code     int
-		httpCode int
-	)
-	switch {
-	case errors.Is(err, flexiblehttp.ErrInvalidRequestSchema):
-		code = 1000
+func newJSONError(err error) *jsonError {
+	jsonErr := &jsonError{Code: service.ErrorCode(err), Err: err.Error()}
+	if delay, ok := service.RetryAfter(err); ok {
+		jsonErr.RetryAfter = int(math.Ceil(delay.Seconds()))
+	}
+	var validation *service.MessageValidationError
+	if errors.As(err, &validation) {
+		jsonErr.Violations = validation.Fields
+	}
+	return jsonErr
+}
+
+// nolint:gocritic // clear with named return
+func errorCode(err error) (code, httpCode int, message string) {
+	code = service.ErrorCode(err)
+	switch code {
+	case service.CodeInvalidRequestSchema:
 		message = "check request schema in provider configuration file"
 		httpCode = http.StatusInternalServerError
-	case errors.Is(err, flexiblehttp.ErrInvalidResponseSchema):
-		code = 1001
+	case service.CodeInvalidResponseSchema:
 		message = "check response schema in provider configuration file"
 		httpCode = http.StatusInternalServerError
-	case errors.Is(err, flexiblehttp.ErrDataProviderIssue):
-		code = 1002
+	case service.CodeDataProviderIssue:
 		message = "check data provider to be available"
 		httpCode = http.StatusInternalServerError
 
-	case errors.Is(err, service.ErrInvalidProtocolMessage):
-		code = 2000
+	case service.CodeInvalidProtocolMessage,
+		service.CodeInvalidProtocolResponse:
+		httpCode = http.StatusBadRequest
+	case service.CodeReplayedMessage:
+		httpCode = http.StatusConflict
+		message = "send a new refresh message instead of replaying a processed one"
+	case service.CodeInvalidOwnershipProof:
+		httpCode = http.StatusUnauthorized
+		message = "sign the refresh request with an Ethereum key controlling the owner DID"
+	case service.CodeInvalidGrant:
 		httpCode = http.StatusBadRequest
-	case errors.Is(err, service.ErrInvalidProtocolResponse):
-		code = 2001
+		message = "pre-authorized codes are redeemed once before they expire, create a new credential offer"
+	case service.CodeInvalidAccessToken:
+		httpCode = http.StatusUnauthorized
+		message = "access tokens obtain one credential before they expire, redeem a new credential offer"
+	case service.CodeUnsupportedFormat:
 		httpCode = http.StatusBadRequest
+	case service.CodeInvalidOwnerSession:
+		httpCode = http.StatusUnauthorized
+		message = "owner sessions fetch the jobs they started before they expire, start a new background refresh"
 
-	case errors.Is(err, service.ErrIssuerNotSupported):
-		code = 3000
+	case service.CodeIssuerNotSupported:
 		httpCode = http.StatusNotFound
 		message = "check issuer node in refresh service configuration file"
-	case errors.Is(err, service.ErrGetClaim):
-		code = 3001
-		httpCode = http.StatusInternalServerError
-	case errors.Is(err, service.ErrCreateClaim):
-		code = 3002
+	case service.CodeGetClaim,
+		service.CodeCreateClaim:
 		httpCode = http.StatusInternalServerError
+	case service.CodeOwnerMethodNotSupported:
+		httpCode = http.StatusUnprocessableEntity
+		message = "the issuer node doesn't accept owners of this DID method, check ISSUERS_OWNER_DID_METHODS"
 
-	case errors.Is(err, service.ErrCredentialNotUpdatable):
-		code = 4000
+	case service.CodeCredentialNotUpdatable:
 		httpCode = http.StatusBadRequest
 		message = "check that the credential you are trying to update has refreshService and the updatable flag is true"
+	case service.CodeRefreshInProgress:
+		httpCode = http.StatusConflict
+		message = "the credential is being refreshed by another request, retry later"
+	case service.CodeQuotaExceeded:
+		httpCode = http.StatusTooManyRequests
+		message = "the owner reached the refresh quota, retry when the quota window ends"
+	case service.CodeRefreshTimeout:
+		httpCode = http.StatusGatewayTimeout
+		message = "check the latency of the stage named in the error or raise REFRESH_TIMEOUT"
+	case service.CodeSubjectTooLarge:
+		httpCode = http.StatusUnprocessableEntity
+		message = "check the data provider response or raise the SUBJECT_MAX_* limits"
+	case service.CodeRevocationNonceConflict:
+		httpCode = http.StatusConflict
+		message = "the credential was revoked or already reissued, refresh the latest credential"
 	default:
-		code = 500
 		httpCode = http.StatusInternalServerError
 	}
+	return code, httpCode, message
+}
 
-	logger.DefaultLogger.Error(err)
-	if message != "" {
-		logger.DefaultLogger.Info("possible solution: ", message)
+func handleError(w http.ResponseWriter, r *http.Request, err error) {
+	httpCode := logError(r, err)
+
+	var problem *service.ProblemReportError
+	if errors.As(err, &problem) {
+		// the agent answered the failed message with a problem-report
+		if delay, ok := service.RetryAfter(err); ok {
+			httpCode = http.StatusServiceUnavailable
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(httpCode)
+		if _, err := w.Write(problem.Report); err != nil {
+			logger.DefaultLogger.Errorf("failed to write response: %v", err)
+		}
+		return
 	}
 
+	body := newJSONError(err)
+	if body.RetryAfter > 0 {
+		// the upstream is throttling, the client should come back later
+		httpCode = http.StatusServiceUnavailable
+		w.Header().Set("Retry-After", strconv.Itoa(body.RetryAfter))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(httpCode)
-	if err := json.NewEncoder(w).Encode(jsonError{
-		Code: code,
-		Err:  err.Error(),
-	}); err != nil {
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		logger.DefaultLogger.Errorf("failed to write response: %v", err)
 	}
 }
+
+// logError logs and reports err and returns its HTTP status code.
+func logError(r *http.Request, err error) int {
+	code, httpCode, message := errorCode(err)
+
+	logger.DefaultLogger.Errorw(err.Error(), "code", code, "requestId", correlation.FromContext(r.Context()))
+	if message != "" {
+		logger.DefaultLogger.Info("possible solution: ", message)
+	}
+	if httpCode >= http.StatusInternalServerError {
+		reporting.DefaultReporter.Report(r.Context(), err, code)
+	}
+	return httpCode
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleError_RetryAfter(t *testing.T) {
+	tests := []struct {
+		name               string
+		err                error
+		expectedCode       int
+		expectedRetryAfter string
+		expectedBody       string
+	}{
+		{
+			name: "Throttled issuer node",
+			err: &httpclient.ThrottledError{
+				Err:        errors.Wrap(service.ErrGetClaim, "invalid status code: '429'"),
+				StatusCode: http.StatusTooManyRequests,
+				RetryAfter: 1500 * time.Millisecond,
+			},
+			expectedCode:       http.StatusServiceUnavailable,
+			expectedRetryAfter: "2",
+			expectedBody: `{"code": 3001, "retryAfter": 2,
+				"error": "invalid status code: '429': failed to get claim, retry after 1.5s"}`,
+		},
+		{
+			name:         "Issuer node failure",
+			err:          errors.Wrap(service.ErrGetClaim, "invalid status code: '500'"),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"code": 3001, "error": "invalid status code: '500': failed to get claim"}`,
+		},
+		{
+			name: "Problem report",
+			err: &service.ProblemReportError{
+				Err:    errors.Wrap(service.ErrCredentialNotUpdatable, "credential '1'"),
+				Report: []byte(`{"type": "https://didcomm.org/report-problem/2.0/problem-report"}`),
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"type": "https://didcomm.org/report-problem/2.0/problem-report"}`,
+		},
+		{
+			name:         "Invalid agent message",
+			err:          &service.MessageValidationError{Fields: []string{"from: missing", "body.id: missing"}},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"code": 2000, "violations": ["from: missing", "body.id: missing"],
+				"error": "invalid protocol message: from: missing; body.id: missing"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handleError(rr, httptest.NewRequest(http.MethodPost, "/", http.NoBody), tt.err)
+			require.Equal(t, tt.expectedCode, rr.Code)
+			require.Equal(t, tt.expectedRetryAfter, rr.Header().Get("Retry-After"))
+			require.JSONEq(t, tt.expectedBody, rr.Body.String())
+		})
+	}
+}
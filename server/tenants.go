@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+)
+
+// ProviderTenant is the provider registry of a tenant, reloaded and pushed
+// to apart from the default one.
+type ProviderTenant interface {
+	ProviderRegistry
+	ProviderUpdates
+}
+
+// WithProviderTenants lets the provider reload and the provider webhook
+// target the registry of a tenant with the tenant query parameter.
+func WithProviderTenants(tenants map[string]ProviderTenant) HandlerOption {
+	return func(h *Handlers) {
+		h.providerTenants = tenants
+	}
+}
+
+// providerTenant returns the tenant named by the tenant query parameter of
+// r, nil without it. It writes the error response when the tenant is
+// unknown.
+func (h *Handlers) providerTenant(w http.ResponseWriter, r *http.Request) (name string, tenant ProviderTenant, ok bool) {
+	name = r.URL.Query().Get("tenant")
+	if name == "" {
+		return "", nil, true
+	}
+	tenant, ok = h.providerTenants[name]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, jsonError{
+			Code: http.StatusNotFound,
+			Err:  "unknown provider tenant '" + name + "'",
+		})
+		return "", nil, false
+	}
+	return name, tenant, true
+}
+
+// tenantFingerprints prefixes the credential types of fingerprints with
+// tenant, so the audit log tells the registries apart.
+func tenantFingerprints(tenant string, fingerprints map[string]string) map[string]string {
+	if tenant == "" {
+		return fingerprints
+	}
+	prefixed := make(map[string]string, len(fingerprints))
+	for credentialType, fingerprint := range fingerprints {
+		prefixed[tenant+"/"+credentialType] = fingerprint
+	}
+	return prefixed
+}
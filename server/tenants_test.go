@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockProviderTenant struct {
+	mockProviderRegistry
+	*recordingUpdates
+}
+
+func TestProviderTenants(t *testing.T) {
+	acme := mockProviderTenant{
+		mockProviderRegistry: mockProviderRegistry{types: []string{"KYCAge"}},
+		recordingUpdates:     &recordingUpdates{},
+	}
+	defaultUpdates := &recordingUpdates{}
+	h := NewHandlers(nil, nil,
+		WithAdminToken("secret"),
+		WithProviderRegistry(mockProviderRegistry{types: []string{"Balance"}}),
+		WithWebhook("secret", defaultUpdates, time.Hour),
+		WithProviderTenants(map[string]ProviderTenant{"acme": acme}),
+	)
+
+	tests := []struct {
+		name         string
+		handler      http.Handler
+		target       string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Reload the default registry",
+			handler:      h.adminRouter(),
+			target:       "/providers/reload",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"credentialTypes":["Balance"]}`,
+		},
+		{
+			name:         "Reload a tenant",
+			handler:      h.adminRouter(),
+			target:       "/providers/reload?tenant=acme",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"credentialTypes":["KYCAge"]}`,
+		},
+		{
+			name:         "Reload an unknown tenant",
+			handler:      h.adminRouter(),
+			target:       "/providers/reload?tenant=globex",
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"code":404,"error":"unknown provider tenant 'globex'"}`,
+		},
+		{
+			name:         "Push to a tenant",
+			handler:      http.HandlerFunc(h.pushProviderUpdate),
+			target:       "/webhooks/provider?tenant=acme",
+			body:         `{"credentialType": "Balance", "key": "0x1", "fields": {"balance": "1"}}`,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Push to an unknown tenant",
+			handler:      http.HandlerFunc(h.pushProviderUpdate),
+			target:       "/webhooks/provider?tenant=globex",
+			body:         `{"credentialType": "Balance", "key": "0x1", "fields": {"balance": "1"}}`,
+			expectedCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedBody != "" {
+				require.JSONEq(t, tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+	require.Len(t, acme.ttls, 1)
+	require.Empty(t, defaultUpdates.ttls)
+}
+
+func TestTenantFingerprints(t *testing.T) {
+	fingerprints := map[string]string{"Balance": "abc"}
+	require.Equal(t, fingerprints, tenantFingerprints("", fingerprints))
+	require.Equal(t, map[string]string{"acme/Balance": "abc"}, tenantFingerprints("acme", fingerprints))
+}
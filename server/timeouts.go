@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/metrics"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/pkg/errors"
+)
+
+// Routes with their own timeouts and metrics.
+const (
+	RouteRefresh       = "refresh"
+	RouteSignedRefresh = "eip712"
+	RoutePresentation  = "presentation"
+	RouteOpenID4VCI    = "openid4vci"
+	RouteJobs          = "jobs"
+	RouteHealth        = "health"
+	RouteAdmin         = "admin"
+	RouteWebhook       = "webhook"
+)
+
+// RouteTimeouts bound the time a route may take. Zero durations are not
+// enforced.
+type RouteTimeouts struct {
+	// Read is the time allowed to read the request body.
+	Read time.Duration
+	// Write is the time allowed until the response is written.
+	Write time.Duration
+	// Handler is the deadline of the request context, which ends calls to
+	// issuer nodes and data providers still running.
+	Handler time.Duration
+}
+
+// ParseRouteTimeouts parses route timeouts in the 'read:write:handler'
+// format, e.g. '5s:30s:25s'. Empty parts are not enforced, '::10s' only sets
+// the handler timeout.
+func ParseRouteTimeouts(value string) (RouteTimeouts, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 3 {
+		return RouteTimeouts{}, errors.Errorf("invalid route timeouts '%s': expected 'read:write:handler'", value)
+	}
+	durations := make([]time.Duration, len(parts))
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil || d < 0 {
+			return RouteTimeouts{}, errors.Errorf("invalid route timeouts '%s': '%s' is not a positive duration", value, part)
+		}
+		durations[i] = d
+	}
+	return RouteTimeouts{Read: durations[0], Write: durations[1], Handler: durations[2]}, nil
+}
+
+// WithRouteTimeouts sets the timeouts of routes by name, e.g. RouteRefresh.
+func WithRouteTimeouts(timeouts map[string]RouteTimeouts) HandlerOption {
+	return func(h *Handlers) {
+		h.routeTimeouts = timeouts
+	}
+}
+
+// WithSlowRequestThreshold logs and counts requests taking at least
+// threshold. Zero disables it.
+func WithSlowRequestThreshold(threshold time.Duration) HandlerOption {
+	return func(h *Handlers) {
+		h.slowRequestThreshold = threshold
+	}
+}
+
+// route applies the timeouts of the named route and records its latency.
+func (h *Handlers) route(name string) func(http.Handler) http.Handler {
+	timeouts := h.routeTimeouts[name]
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rc := http.NewResponseController(w)
+			// deadlines are kept by the connection for the next request,
+			// they are set and cleared per request. Writers which don't
+			// support them, like hijacked connections, are skipped.
+			if timeouts.Read > 0 {
+				_ = rc.SetReadDeadline(start.Add(timeouts.Read))
+				defer func() { _ = rc.SetReadDeadline(time.Time{}) }()
+			}
+			if timeouts.Write > 0 {
+				_ = rc.SetWriteDeadline(start.Add(timeouts.Write))
+				defer func() { _ = rc.SetWriteDeadline(time.Time{}) }()
+			}
+			if timeouts.Handler > 0 {
+				ctx, cancel := context.WithTimeout(r.Context(), timeouts.Handler)
+				defer cancel()
+				r = r.WithContext(ctx)
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			elapsed := time.Since(start)
+			slow := h.slowRequestThreshold > 0 && elapsed >= h.slowRequestThreshold
+			metrics.ObserveHTTPRequest(r.Context(), name, r.Method, ww.Status(), elapsed, slow)
+			if slow {
+				logger.DefaultLogger.Warnw("slow http request",
+					"route", name,
+					"method", r.Method,
+					"path", r.URL.Path,
+					"responseTime", fmt.Sprintf("%d ms", elapsed.Milliseconds()),
+					"status", ww.Status(),
+					"requestId", correlation.FromContext(r.Context()))
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
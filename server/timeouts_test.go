@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRouteTimeouts(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    RouteTimeouts
+		expectedErr bool
+	}{
+		{
+			name:     "All timeouts",
+			value:    "5s:30s:25s",
+			expected: RouteTimeouts{Read: 5 * time.Second, Write: 30 * time.Second, Handler: 25 * time.Second},
+		},
+		{
+			name:     "Handler timeout only",
+			value:    "::10s",
+			expected: RouteTimeouts{Handler: 10 * time.Second},
+		},
+		{
+			name:        "Missing parts",
+			value:       "5s:30s",
+			expectedErr: true,
+		},
+		{
+			name:        "Invalid duration",
+			value:       "5s:soon:25s",
+			expectedErr: true,
+		},
+		{
+			name:        "Negative duration",
+			value:       "-5s::",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeouts, err := ParseRouteTimeouts(tt.value)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, timeouts)
+		})
+	}
+}
+
+func TestRoute(t *testing.T) {
+	tests := []struct {
+		name             string
+		route            string
+		timeouts         map[string]RouteTimeouts
+		threshold        time.Duration
+		latency          time.Duration
+		expectedDeadline bool
+		expectedSlow     float64
+	}{
+		{
+			name:             "Handler timeout",
+			route:            "test-handler-timeout",
+			timeouts:         map[string]RouteTimeouts{"test-handler-timeout": {Handler: time.Minute}},
+			expectedDeadline: true,
+		},
+		{
+			name:     "Timeout of another route",
+			route:    "test-other-route",
+			timeouts: map[string]RouteTimeouts{RouteAdmin: {Handler: time.Minute}},
+		},
+		{
+			name:         "Slow request",
+			route:        "test-slow",
+			threshold:    10 * time.Millisecond,
+			latency:      20 * time.Millisecond,
+			expectedSlow: 1,
+		},
+		{
+			name:      "Fast request",
+			route:     "test-fast",
+			threshold: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandlers(nil, nil, WithRouteTimeouts(tt.timeouts), WithSlowRequestThreshold(tt.threshold))
+			var hasDeadline bool
+			handler := h.route(tt.route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, hasDeadline = r.Context().Deadline()
+				time.Sleep(tt.latency)
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", http.NoBody))
+			require.Equal(t, http.StatusAccepted, rec.Code)
+			require.Equal(t, tt.expectedDeadline, hasDeadline)
+			require.InDelta(t, tt.expectedSlow, testutil.ToFloat64(metrics.HTTPSlowRequests.WithLabelValues(tt.route)), 0)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/pkg/errors"
+)
+
+// ProviderUpdates stores subject fields pushed by upstream systems.
+type ProviderUpdates interface {
+	Push(ctx context.Context, credentialType, key string, fields map[string]interface{}, ttl time.Duration) error
+}
+
+// WithWebhook enables the endpoint where upstream systems push updated
+// subject fields, protected by a bearer token. Pushed fields are used for
+// ttl unless the push sets its own.
+func WithWebhook(token string, updates ProviderUpdates, ttl time.Duration) HandlerOption {
+	return func(h *Handlers) {
+		h.webhookToken = token
+		h.providerUpdates = updates
+		h.webhookTTL = ttl
+	}
+}
+
+type providerUpdate struct {
+	CredentialType string                 `json:"credentialType"`
+	Key            string                 `json:"key"`
+	Fields         map[string]interface{} `json:"fields"`
+	TTL            string                 `json:"ttl,omitempty"`
+}
+
+func (h *Handlers) pushProviderUpdate(w http.ResponseWriter, r *http.Request) {
+	_, tenant, ok := h.providerTenant(w, r)
+	if !ok {
+		return
+	}
+	updates := h.providerUpdates
+	if tenant != nil {
+		updates = tenant
+	}
+	var req providerUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, jsonError{Code: http.StatusBadRequest, Err: err.Error()})
+		return
+	}
+	ttl := h.webhookTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, jsonError{
+				Code: http.StatusBadRequest,
+				Err:  "ttl must be a positive duration",
+			})
+			return
+		}
+		ttl = d
+	}
+	err := updates.Push(r.Context(), req.CredentialType, req.Key, req.Fields, ttl)
+	if errors.Is(err, flexiblehttp.ErrInvalidPush) || errors.Is(err, flexiblehttp.ErrInvalidResponseSchema) {
+		writeJSON(w, http.StatusBadRequest, jsonError{Code: http.StatusBadRequest, Err: err.Error()})
+		return
+	}
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingUpdates struct {
+	ttls []time.Duration
+}
+
+func (u *recordingUpdates) Push(_ context.Context, credentialType, _ string, _ map[string]interface{}, ttl time.Duration) error {
+	if credentialType != "Balance" {
+		return errors.Wrap(flexiblehttp.ErrInvalidPush, "unknown credential type")
+	}
+	u.ttls = append(u.ttls, ttl)
+	return nil
+}
+
+func TestPushProviderUpdate(t *testing.T) {
+	updates := &recordingUpdates{}
+	h := NewHandlers(nil, nil, WithWebhook("secret", updates, time.Hour))
+	handler := bearerAuth(h.webhookToken)(http.HandlerFunc(h.pushProviderUpdate))
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		body          string
+		expectedCode  int
+		expectedTTL   time.Duration
+	}{
+		{
+			name:         "Invalid token",
+			token:        "wrong",
+			body:         `{"credentialType": "Balance", "key": "0x1", "fields": {"balance": "1"}}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "Missing Bearer prefix",
+			authorization: "secret",
+			body:          `{"credentialType": "Balance", "key": "0x1", "fields": {"balance": "1"}}`,
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:         "Default ttl",
+			token:        "secret",
+			body:         `{"credentialType": "Balance", "key": "0x1", "fields": {"balance": "1"}}`,
+			expectedCode: http.StatusNoContent,
+			expectedTTL:  time.Hour,
+		},
+		{
+			name:         "Custom ttl",
+			token:        "secret",
+			body:         `{"credentialType": "Balance", "key": "0x1", "fields": {"balance": "1"}, "ttl": "10m"}`,
+			expectedCode: http.StatusNoContent,
+			expectedTTL:  10 * time.Minute,
+		},
+		{
+			name:         "Invalid ttl",
+			token:        "secret",
+			body:         `{"credentialType": "Balance", "key": "0x1", "fields": {"balance": "1"}, "ttl": "-1m"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Rejected push",
+			token:        "secret",
+			body:         `{"credentialType": "Other", "key": "0x1", "fields": {"balance": "1"}}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates.ttls = nil
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/provider", strings.NewReader(tt.body))
+			authorization := "Bearer " + tt.token
+			if tt.authorization != "" {
+				authorization = tt.authorization
+			}
+			req.Header.Set("Authorization", authorization)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedTTL != 0 {
+				require.Equal(t, []time.Duration{tt.expectedTTL}, updates.ttls)
+			}
+		})
+	}
+}
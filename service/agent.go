@@ -4,8 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"strings"
+	"time"
 
+	"github.com/0xPolygonID/refresh-service/sdjwt"
+	"github.com/0xPolygonID/refresh-service/storage"
 	"github.com/google/uuid"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
 	"github.com/iden3/iden3comm/v2"
 	"github.com/iden3/iden3comm/v2/packers"
 	iden3Protocol "github.com/iden3/iden3comm/v2/protocol"
@@ -17,17 +21,46 @@ var (
 	ErrInvalidProtocolResponse = errors.New("invalid protocol response")
 )
 
+// issuanceMessageBody extends the issuance response body with the optional
+// JWT and SD-JWT forms of the credential. Wallets which don't know these
+// fields ignore them.
+type issuanceMessageBody struct {
+	Credential json.RawMessage `json:"credential"`
+	JWT        string          `json:"jwt,omitempty"`
+	SDJWT      string          `json:"sdJwt,omitempty"`
+}
+
 type AgentService struct {
-	refreshService *RefreshService
-	packageManager *iden3comm.PackageManager
+	refreshService       *RefreshService
+	packageManager       *iden3comm.PackageManager
+	processedMessages    storage.Idempotency
+	replayTTL            time.Duration
+	sdjwtIssuer          *sdjwt.Issuer
+	sdjwtTypes           map[string]string
+	problemReports       bool
+	reportOnlyMessages   bool
+	batch                BatchRefresher
+	batchMaxItems        int
+	didResolver          DIDResolver
+	presentationAudience string
+	openID4VCI           *OpenID4VCISettings
+	openID4VCIStore      storage.Idempotency
+	asyncJobs            SessionJobs
+	asyncSessions        storage.Idempotency
+	asyncSessionTTL      time.Duration
 }
 
 func NewAgentService(refreshService *RefreshService,
-	packageManager *iden3comm.PackageManager) *AgentService {
-	return &AgentService{
+	packageManager *iden3comm.PackageManager, opts ...AgentOption) *AgentService {
+	as := &AgentService{
 		refreshService: refreshService,
 		packageManager: packageManager,
+		didResolver:    defaultDIDResolver(),
 	}
+	for _, opt := range opts {
+		opt(as)
+	}
+	return as
 }
 
 func (as *AgentService) Process(ctx context.Context, envelop []byte) (
@@ -36,17 +69,33 @@ func (as *AgentService) Process(ctx context.Context, envelop []byte) (
 	if err != nil {
 		return nil, errors.Wrapf(ErrInvalidProtocolMessage, "failed to unpack message: %v", err)
 	}
-	if err := verifyMessageAttributes(message); err != nil {
-		return nil, errors.Wrapf(ErrInvalidProtocolMessage, "failed to verify message attributes: %v", err)
+	if err := as.checkMessage(message); err != nil {
+		return nil, err
+	}
+	response, err := as.respond(ctx, message)
+	if err != nil && as.problemReports {
+		return nil, as.problemReport(message, err)
+	}
+	return response, err
+}
+
+// respond processes an unpacked message and returns the packed response.
+func (as *AgentService) respond(ctx context.Context, message *iden3comm.BasicMessage) ([]byte, error) {
+	if err := as.checkReplay(ctx, message); err != nil {
+		return nil, err
 	}
 
 	switch message.Type {
 	case iden3Protocol.CredentialRefreshMessageType:
-		var bodyMessage iden3Protocol.CredentialRefreshMessageBody
+		var bodyMessage refreshMessageBody
 		err := json.Unmarshal(message.Body, &bodyMessage)
 		if err != nil {
 			return nil, errors.Wrapf(ErrInvalidProtocolMessage, "failed to unmarshal body: %v", err)
 		}
+		ctx = SelectFields(ctx, bodyMessage.Fields)
+		if len(bodyMessage.IDs) != 0 {
+			return as.respondBatch(ctx, message, bodyMessage.IDs)
+		}
 
 		refreshed, err := as.refreshService.Process(
 			ctx,
@@ -57,41 +106,61 @@ func (as *AgentService) Process(ctx context.Context, envelop []byte) (
 		if err != nil {
 			return nil, err
 		}
-
-		issuenceResponse := iden3Protocol.CredentialIssuanceMessage{
-			ID:       uuid.New().String(),
-			Type:     iden3Protocol.CredentialIssuanceResponseMessageType,
-			ThreadID: message.ThreadID,
-			Body: iden3Protocol.IssuanceMessageBody{
-				Credential: *refreshed,
-			},
-			From: message.To,
-			To:   message.From,
-		}
-		payload, err := json.Marshal(issuenceResponse)
+		body, err := as.issuanceBody(ctx, message.To, refreshed)
 		if err != nil {
-			return nil, errors.Wrap(ErrInvalidProtocolResponse, err.Error())
-		}
-
-		envelop, err := as.packageManager.Pack(packers.MediaTypePlainMessage, payload, nil)
-		if err != nil {
-			return nil, errors.Wrapf(ErrInvalidProtocolResponse, "failed pack message: %v", err)
+			return nil, err
 		}
-
-		return envelop, nil
+		return as.packIssuance(message, body)
 	default:
 		return nil, errors.Errorf("unknown message type '%s'", message.Type)
 	}
 }
 
-func verifyMessageAttributes(message *iden3comm.BasicMessage) error {
-	if message.From == "" {
-		return errors.New("missing 'from' field in message")
+// issuanceBody returns the issuance response body of a refreshed credential.
+func (as *AgentService) issuanceBody(ctx context.Context, issuerDID string,
+	refreshed *verifiable.W3CCredential) (*issuanceMessageBody, error) {
+	result, err := as.newRefreshResult(refreshed)
+	if err != nil {
+		return nil, err
+	}
+	if err := as.attachJWT(ctx, issuerDID, result); err != nil {
+		return nil, err
+	}
+	credential, err := MarshalCredential(result.Credential)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidProtocolResponse, err.Error())
+	}
+	return &issuanceMessageBody{
+		Credential: credential,
+		JWT:        result.JWT,
+		SDJWT:      result.SDJWT,
+	}, nil
+}
+
+// packIssuance packs body as the issuance response to message.
+func (as *AgentService) packIssuance(message *iden3comm.BasicMessage, body interface{}) ([]byte, error) {
+	rawBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidProtocolResponse, err.Error())
 	}
-	if message.To == "" {
-		return errors.New("missing 'to' field in message")
+	issuenceResponse := iden3comm.BasicMessage{
+		ID:       uuid.New().String(),
+		Type:     iden3Protocol.CredentialIssuanceResponseMessageType,
+		ThreadID: message.ThreadID,
+		Body:     rawBody,
+		From:     message.To,
+		To:       message.From,
+	}
+	payload, err := json.Marshal(issuenceResponse)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidProtocolResponse, err.Error())
+	}
+
+	envelop, err := as.packageManager.Pack(packers.MediaTypePlainMessage, payload, nil)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidProtocolResponse, "failed pack message: %v", err)
 	}
-	return nil
+	return envelop, nil
 }
 
 /*
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/iden3/iden3comm/v2"
+	"github.com/pkg/errors"
+)
+
+// refreshMessageBody is the body of a refresh message. Besides the single
+// credential id of the protocol, wallets may send the ids of several
+// credentials of the same issuer in IDs and limit the refresh to some
+// subject fields with Fields.
+type refreshMessageBody struct {
+	ID     string   `json:"id"`
+	IDs    []string `json:"ids,omitempty"`
+	Reason string   `json:"reason"`
+	// Fields names the subject fields to refresh, all of them by default.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// BatchResult is the outcome of one credential of a batch refresh.
+type BatchResult struct {
+	Credential *verifiable.W3CCredential
+	Err        error
+}
+
+// BatchRefresher refreshes several credentials of an owner at one issuer.
+// Results are returned in the order of ids, a failed credential doesn't
+// fail the others.
+type BatchRefresher interface {
+	RefreshCredentials(ctx context.Context, issuer, owner string, ids []string) []BatchResult
+}
+
+// WithBatchMessages accepts refresh messages carrying up to maxItems
+// credential ids, refreshed by refresher.
+func WithBatchMessages(refresher BatchRefresher, maxItems int) AgentOption {
+	return func(as *AgentService) {
+		as.batch = refresher
+		as.batchMaxItems = maxItems
+	}
+}
+
+// batchIssuanceBody is the issuance response body of a batch refresh
+// message. Credentials are listed in the order of the requested ids.
+type batchIssuanceBody struct {
+	Credentials []batchIssuanceItem `json:"credentials"`
+}
+
+type batchIssuanceItem struct {
+	// ID is the credential id as it was requested.
+	ID         string              `json:"id"`
+	Credential json.RawMessage     `json:"credential,omitempty"`
+	JWT        string              `json:"jwt,omitempty"`
+	SDJWT      string              `json:"sdJwt,omitempty"`
+	Error      *batchIssuanceError `json:"error,omitempty"`
+}
+
+type batchIssuanceError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (as *AgentService) respondBatch(ctx context.Context, message *iden3comm.BasicMessage, ids []string) ([]byte, error) {
+	if err := as.checkBatch(message, ids); err != nil {
+		return nil, err
+	}
+	credentialIDs := make([]string, len(ids))
+	for i, id := range ids {
+		credentialIDs[i] = convertID(id)
+	}
+
+	results := as.batch.RefreshCredentials(ctx, message.To, message.From, credentialIDs)
+	body := batchIssuanceBody{Credentials: make([]batchIssuanceItem, len(results))}
+	for i, result := range results {
+		body.Credentials[i] = as.batchIssuanceItem(ctx, message.To, ids[i], result)
+	}
+	return as.packIssuance(message, body)
+}
+
+func (as *AgentService) batchIssuanceItem(ctx context.Context, issuerDID, id string, result BatchResult) batchIssuanceItem {
+	err := result.Err
+	if err == nil {
+		var body *issuanceMessageBody
+		if body, err = as.issuanceBody(ctx, issuerDID, result.Credential); err == nil {
+			return batchIssuanceItem{ID: id, Credential: body.Credential, JWT: body.JWT, SDJWT: body.SDJWT}
+		}
+	}
+	return batchIssuanceItem{ID: id, Error: &batchIssuanceError{Code: ErrorCode(err), Message: err.Error()}}
+}
+
+// checkBatch checks that message may refresh the credentials ids at once.
+func (as *AgentService) checkBatch(message *iden3comm.BasicMessage, ids []string) error {
+	if as.batch == nil || !as.featureEnabled(FeatureBatchMessages, message.To, message.From, true) {
+		return errors.Wrap(ErrInvalidProtocolMessage, "refresh of several credentials in one message is not enabled")
+	}
+	if as.batchMaxItems > 0 && len(ids) > as.batchMaxItems {
+		return errors.Wrapf(ErrInvalidProtocolMessage,
+			"message carries %d credential ids, at most %d are refreshed at once", len(ids), as.batchMaxItems)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/features"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/iden3/iden3comm/v2"
+	"github.com/iden3/iden3comm/v2/packers"
+	iden3Protocol "github.com/iden3/iden3comm/v2/protocol"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type mockBatchRefresher struct {
+	ids    []string
+	fields []string
+}
+
+func (m *mockBatchRefresher) RefreshCredentials(ctx context.Context, _, _ string, ids []string) []BatchResult {
+	m.ids = ids
+	m.fields = selectedFields(ctx)
+	results := make([]BatchResult, len(ids))
+	for i, id := range ids {
+		if i%2 == 1 {
+			results[i].Err = errors.Wrapf(ErrCredentialNotUpdatable, "credential '%s'", id)
+			continue
+		}
+		results[i].Credential = &verifiable.W3CCredential{ID: "urn:uuid:" + id}
+	}
+	return results
+}
+
+func TestProcess_BatchMessage(t *testing.T) {
+	const (
+		first  = "e342def6-620e-4394-8ea1-7448ea81bb72"
+		second = "0a8d4c2e-1b6f-4a57-9d3e-2f7c8b9e1a40"
+	)
+	pm := iden3comm.NewPackageManager()
+	require.NoError(t, pm.RegisterPackers(&packers.PlainMessagePacker{}))
+
+	tests := []struct {
+		name             string
+		refreshService   *RefreshService
+		opts             []AgentOption
+		ids              []string
+		fields           []string
+		expectedErr      error
+		expectedRefresh  []string
+		expectedResponse string
+	}{
+		{
+			name:            "Several credentials",
+			ids:             []string{"urn:uuid:" + first, second},
+			expectedRefresh: []string{first, second},
+			expectedResponse: `{"credentials": [
+				{"id": "urn:uuid:` + first + `", "credential": {"id": "urn:uuid:` + first + `", "@context": null, "type": null, "credentialSubject": null, "issuer": "", "credentialSchema": {"id": "", "type": ""}}},
+				{"id": "` + second + `", "error": {"code": 4000, "message": "credential '` + second + `': not updatable"}}
+			]}`,
+		},
+		{
+			name:            "Selected fields",
+			ids:             []string{first},
+			fields:          []string{"score"},
+			expectedRefresh: []string{first},
+			expectedResponse: `{"credentials": [
+				{"id": "` + first + `", "credential": {"id": "urn:uuid:` + first + `", "@context": null, "type": null, "credentialSubject": null, "issuer": "", "credentialSchema": {"id": "", "type": ""}}}
+			]}`,
+		},
+		{
+			name:        "Too many credentials",
+			opts:        []AgentOption{WithBatchMessages(nil, 1)},
+			ids:         []string{first, second},
+			expectedErr: ErrInvalidProtocolMessage,
+		},
+		{
+			name: "Disabled by feature flag",
+			refreshService: &RefreshService{features: features.NewSet(map[string]features.Flag{
+				FeatureBatchMessages: {Issuers: []string{"did:iden3:other"}},
+			})},
+			ids:         []string{first, second},
+			expectedErr: ErrInvalidProtocolMessage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refresher := &mockBatchRefresher{}
+			opts := append([]AgentOption{WithBatchMessages(refresher, 10)}, tt.opts...)
+			as := NewAgentService(tt.refreshService, pm, opts...)
+
+			body, err := json.Marshal(map[string][]string{"ids": tt.ids, "fields": tt.fields})
+			require.NoError(t, err)
+			envelope, err := json.Marshal(iden3comm.BasicMessage{
+				ID:       "1",
+				ThreadID: "1",
+				Typ:      packers.MediaTypePlainMessage,
+				Type:     iden3Protocol.CredentialRefreshMessageType,
+				Body:     body,
+				From:     "did:iden3:owner",
+				To:       "did:iden3:issuer",
+			})
+			require.NoError(t, err)
+
+			response, err := as.Process(context.Background(), envelope)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedRefresh, refresher.ids)
+			require.Equal(t, tt.fields, refresher.fields)
+
+			message, _, err := pm.Unpack(response)
+			require.NoError(t, err)
+			require.Equal(t, iden3Protocol.CredentialIssuanceResponseMessageType, message.Type)
+			require.Equal(t, "did:iden3:owner", message.To)
+			require.JSONEq(t, tt.expectedResponse, string(message.Body))
+		})
+	}
+}
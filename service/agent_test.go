@@ -1,8 +1,12 @@
 package service
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/iden3/iden3comm/v2"
 	"github.com/stretchr/testify/require"
 )
 
@@ -36,3 +40,22 @@ func TestConvertID(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckReplay(t *testing.T) {
+	as := NewAgentService(nil, nil, WithReplayProtection(memory.NewStore(), time.Hour))
+	ctx := context.Background()
+
+	first := &iden3comm.BasicMessage{ID: "1", ThreadID: "1", From: "did:owner"}
+	require.NoError(t, as.checkReplay(ctx, first))
+	require.ErrorIs(t, as.checkReplay(ctx, first), ErrReplayedMessage)
+
+	// same id from another sender is a different message
+	require.NoError(t, as.checkReplay(ctx, &iden3comm.BasicMessage{ID: "1", From: "did:other"}))
+
+	// new message id in an already processed thread
+	require.NoError(t, as.checkReplay(ctx, &iden3comm.BasicMessage{ID: "2", ThreadID: "t", From: "did:owner"}))
+	require.ErrorIs(t, as.checkReplay(ctx,
+		&iden3comm.BasicMessage{ID: "3", ThreadID: "t", From: "did:owner"}), ErrReplayedMessage)
+
+	require.ErrorIs(t, as.checkReplay(ctx, &iden3comm.BasicMessage{From: "did:owner"}), ErrInvalidProtocolMessage)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/iden3/iden3comm/v2"
+	"github.com/iden3/iden3comm/v2/packers"
+	iden3Protocol "github.com/iden3/iden3comm/v2/protocol"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidOwnerSession is an unknown or expired owner session token.
+var ErrInvalidOwnerSession = errors.New("invalid owner session")
+
+// SessionJobs runs refreshes in the background. A job is bound to the owner
+// session which enqueued it and is only handed out to the same session.
+type SessionJobs interface {
+	EnqueueForSession(ctx context.Context, session, issuer, owner, credentialID string) (storage.Job, error)
+	GetForSession(ctx context.Context, id, session string) (storage.Job, error)
+	Watch(ctx context.Context, id string) (<-chan storage.Job, error)
+}
+
+// WithAsyncRefresh lets wallets refresh credentials in the background with
+// jobs of queue. An authenticated refresh message opens an owner session,
+// kept in store for sessionTTL, and the session token is required to fetch
+// the results of its jobs, so a job id alone doesn't disclose the credential.
+func WithAsyncRefresh(queue SessionJobs, store storage.Idempotency, sessionTTL time.Duration) AgentOption {
+	return func(as *AgentService) {
+		if sessionTTL <= 0 {
+			sessionTTL = time.Hour
+		}
+		as.asyncJobs = queue
+		as.asyncSessions = store
+		as.asyncSessionTTL = sessionTTL
+	}
+}
+
+// AsyncRefreshEnabled reports whether wallets may refresh credentials in
+// the background.
+func (as *AgentService) AsyncRefreshEnabled() bool {
+	return as != nil && as.asyncJobs != nil && as.asyncSessions != nil
+}
+
+// AsyncRefreshResponse answers a background refresh with the token of its
+// owner session and a job per requested credential.
+type AsyncRefreshResponse struct {
+	SessionToken string            `json:"sessionToken"`
+	ExpiresIn    int64             `json:"expiresIn"`
+	Jobs         []AsyncRefreshJob `json:"jobs"`
+}
+
+type AsyncRefreshJob struct {
+	ID string `json:"id"`
+	// CredentialID is the credential id as it was requested.
+	CredentialID string `json:"credentialId"`
+	Status       string `json:"status"`
+}
+
+// ownerSession is the owner authenticated when the session was opened.
+type ownerSession struct {
+	Owner string `json:"owner"`
+}
+
+// EnqueueRefresh enqueues the refresh of the credentials of a refresh
+// message, one job per credential id. Only messages authenticating their
+// sender open an owner session, plain messages are refused.
+func (as *AgentService) EnqueueRefresh(ctx context.Context, envelope []byte) (*AsyncRefreshResponse, error) {
+	message, mediaType, err := as.packageManager.Unpack(envelope)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidProtocolMessage, "failed to unpack message: %v", err)
+	}
+	if mediaType == packers.MediaTypePlainMessage {
+		return nil, errors.Wrap(ErrInvalidProtocolMessage, "background refreshes need a message authenticating the owner")
+	}
+	if err := as.checkMessage(message); err != nil {
+		return nil, err
+	}
+	return as.enqueueRefresh(ctx, message)
+}
+
+func (as *AgentService) enqueueRefresh(ctx context.Context, message *iden3comm.BasicMessage) (*AsyncRefreshResponse, error) {
+	if message.Type != iden3Protocol.CredentialRefreshMessageType {
+		return nil, errors.Wrapf(ErrInvalidProtocolMessage, "unknown message type '%s'", message.Type)
+	}
+	if err := as.checkReplay(ctx, message); err != nil {
+		return nil, err
+	}
+	var body refreshMessageBody
+	if err := json.Unmarshal(message.Body, &body); err != nil {
+		return nil, errors.Wrapf(ErrInvalidProtocolMessage, "failed to unmarshal body: %v", err)
+	}
+	if len(body.Fields) != 0 {
+		return nil, errors.Wrap(ErrInvalidProtocolMessage, "background refreshes refresh all subject fields")
+	}
+	ids := body.IDs
+	switch {
+	case len(ids) != 0:
+		if err := as.checkBatch(message, ids); err != nil {
+			return nil, err
+		}
+	case body.ID != "":
+		ids = []string{body.ID}
+	default:
+		return nil, errors.Wrap(ErrInvalidProtocolMessage, "missing credential id in message body")
+	}
+
+	token, session, err := as.openOwnerSession(ctx, message.From)
+	if err != nil {
+		return nil, err
+	}
+	response := &AsyncRefreshResponse{
+		SessionToken: token,
+		ExpiresIn:    int64(as.asyncSessionTTL.Seconds()),
+		Jobs:         make([]AsyncRefreshJob, len(ids)),
+	}
+	for i, id := range ids {
+		job, err := as.asyncJobs.EnqueueForSession(ctx, session, message.To, message.From, convertID(id))
+		if err != nil {
+			return nil, err
+		}
+		response.Jobs[i] = AsyncRefreshJob{ID: job.ID, CredentialID: id, Status: job.Status}
+	}
+	return response, nil
+}
+
+// openOwnerSession opens a session of owner and returns its token and the
+// hash the jobs of the session are bound to.
+func (as *AgentService) openOwnerSession(ctx context.Context, owner string) (token, session string, err error) {
+	if token, err = randomToken(); err != nil {
+		return "", "", err
+	}
+	session = hashToken(token)
+	raw, err := json.Marshal(ownerSession{Owner: owner})
+	if err != nil {
+		return "", "", err
+	}
+	stored, err := as.asyncSessions.PutIdempotency(ctx, storage.IdempotencyRecord{
+		Key:       "async:session:" + session,
+		Response:  raw,
+		ExpiresAt: time.Now().Add(as.asyncSessionTTL).UTC(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if !stored {
+		return "", "", errors.New("owner session collision")
+	}
+	return token, session, nil
+}
+
+// SessionJob returns the job id when it was enqueued by the owner session of
+// sessionToken. Jobs of other sessions are not found.
+func (as *AgentService) SessionJob(ctx context.Context, sessionToken, id string) (storage.Job, error) {
+	session := hashToken(sessionToken)
+	record, err := as.asyncSessions.GetIdempotency(ctx, "async:session:"+session)
+	if errors.Is(err, storage.ErrNotFound) {
+		return storage.Job{}, errors.Wrap(ErrInvalidOwnerSession, "unknown or expired")
+	}
+	if err != nil {
+		return storage.Job{}, err
+	}
+	var owner ownerSession
+	if err := json.Unmarshal(record.Response, &owner); err != nil {
+		return storage.Job{}, errors.Wrap(err, "failed to decode owner session")
+	}
+	job, err := as.asyncJobs.GetForSession(ctx, id, session)
+	if err != nil {
+		return storage.Job{}, err
+	}
+	if job.Owner != owner.Owner {
+		return storage.Job{}, errors.Wrapf(storage.ErrNotFound, "job '%s'", id)
+	}
+	return job, nil
+}
+
+// WatchSessionJob sends the job id, first as it is and then on every status
+// transition, when it was enqueued by the owner session of sessionToken.
+func (as *AgentService) WatchSessionJob(ctx context.Context, sessionToken, id string) (<-chan storage.Job, error) {
+	if _, err := as.SessionJob(ctx, sessionToken, id); err != nil {
+		return nil, err
+	}
+	return as.asyncJobs.Watch(ctx, id)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/google/uuid"
+	"github.com/iden3/iden3comm/v2"
+	"github.com/iden3/iden3comm/v2/packers"
+	iden3Protocol "github.com/iden3/iden3comm/v2/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionQueue enqueues session jobs in a store without running them.
+type sessionQueue struct {
+	store *memory.Store
+}
+
+func (q sessionQueue) EnqueueForSession(ctx context.Context, session, issuer, owner, credentialID string) (storage.Job, error) {
+	job := storage.Job{
+		ID:           uuid.New().String(),
+		Issuer:       issuer,
+		Owner:        owner,
+		CredentialID: credentialID,
+		Status:       storage.JobStatusPending,
+		Session:      session,
+	}
+	if err := q.store.SaveJob(ctx, job); err != nil {
+		return storage.Job{}, err
+	}
+	return q.store.GetJob(ctx, job.ID)
+}
+
+func (q sessionQueue) GetForSession(ctx context.Context, id, session string) (storage.Job, error) {
+	job, err := q.store.GetJob(ctx, id)
+	if err != nil {
+		return storage.Job{}, err
+	}
+	if job.Session != session {
+		return storage.Job{}, storage.ErrNotFound
+	}
+	return job, nil
+}
+
+func (q sessionQueue) Watch(ctx context.Context, id string) (<-chan storage.Job, error) {
+	job, err := q.store.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	updates := make(chan storage.Job, 1)
+	updates <- job
+	close(updates)
+	return updates, nil
+}
+
+func refreshMessage(id, from string, body interface{}) *iden3comm.BasicMessage {
+	raw, _ := json.Marshal(body)
+	return &iden3comm.BasicMessage{
+		ID:   id,
+		Type: iden3Protocol.CredentialRefreshMessageType,
+		From: from,
+		To:   "did:issuer",
+		Body: raw,
+	}
+}
+
+func TestEnqueueRefresh(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        interface{}
+		expectedIDs []string
+		expectedErr error
+	}{
+		{
+			name:        "Single credential",
+			body:        map[string]interface{}{"id": "urn:uuid:first"},
+			expectedIDs: []string{"first"},
+		},
+		{
+			name:        "Several credentials",
+			body:        map[string]interface{}{"ids": []string{"urn:uuid:first", "urn:uuid:second"}},
+			expectedIDs: []string{"first", "second"},
+		},
+		{
+			name:        "Too many credentials",
+			body:        map[string]interface{}{"ids": []string{"a", "b", "c"}},
+			expectedErr: ErrInvalidProtocolMessage,
+		},
+		{
+			name:        "Selected fields",
+			body:        map[string]interface{}{"id": "urn:uuid:first", "fields": []string{"balance"}},
+			expectedErr: ErrInvalidProtocolMessage,
+		},
+		{
+			name:        "Missing credential id",
+			body:        map[string]interface{}{"reason": "expired"},
+			expectedErr: ErrInvalidProtocolMessage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.NewStore()
+			as := NewAgentService(nil, nil,
+				WithBatchMessages(&mockBatchRefresher{}, 2),
+				WithAsyncRefresh(sessionQueue{store: store}, store, time.Hour))
+
+			response, err := as.enqueueRefresh(context.Background(), refreshMessage("1", "did:owner", tt.body))
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NotEmpty(t, response.SessionToken)
+			require.EqualValues(t, 3600, response.ExpiresIn)
+			require.Len(t, response.Jobs, len(tt.expectedIDs))
+			for i, id := range tt.expectedIDs {
+				job, err := store.GetJob(context.Background(), response.Jobs[i].ID)
+				require.NoError(t, err)
+				require.Equal(t, id, job.CredentialID)
+				require.Equal(t, "did:owner", job.Owner)
+				require.Equal(t, "did:issuer", job.Issuer)
+				require.Equal(t, hashToken(response.SessionToken), job.Session)
+			}
+		})
+	}
+}
+
+func TestEnqueueRefresh_PlainMessage(t *testing.T) {
+	pm := iden3comm.NewPackageManager()
+	require.NoError(t, pm.RegisterPackers(&packers.PlainMessagePacker{}))
+	store := memory.NewStore()
+	as := NewAgentService(nil, pm, WithAsyncRefresh(sessionQueue{store: store}, store, time.Hour))
+
+	envelope, err := json.Marshal(refreshMessage("1", "did:owner", map[string]string{"id": "urn:uuid:first"}))
+	require.NoError(t, err)
+	_, err = as.EnqueueRefresh(context.Background(), envelope)
+	require.ErrorIs(t, err, ErrInvalidProtocolMessage)
+}
+
+func TestSessionJob(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	queue := sessionQueue{store: store}
+	as := NewAgentService(nil, nil,
+		WithReplayProtection(store, time.Hour),
+		WithAsyncRefresh(queue, store, time.Hour))
+
+	owner, err := as.enqueueRefresh(ctx, refreshMessage("1", "did:owner", map[string]string{"id": "urn:uuid:first"}))
+	require.NoError(t, err)
+	other, err := as.enqueueRefresh(ctx, refreshMessage("1", "did:other", map[string]string{"id": "urn:uuid:second"}))
+	require.NoError(t, err)
+	_, err = as.enqueueRefresh(ctx, refreshMessage("1", "did:owner", map[string]string{"id": "urn:uuid:first"}))
+	require.ErrorIs(t, err, ErrReplayedMessage)
+
+	job, err := as.SessionJob(ctx, owner.SessionToken, owner.Jobs[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, "first", job.CredentialID)
+
+	// the job id alone doesn't give the job to another owner
+	_, err = as.SessionJob(ctx, other.SessionToken, owner.Jobs[0].ID)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = as.SessionJob(ctx, "unknown", owner.Jobs[0].ID)
+	require.ErrorIs(t, err, ErrInvalidOwnerSession)
+
+	// jobs enqueued through the admin API are bound to no session
+	admin, err := queue.EnqueueForSession(ctx, "", "did:issuer", "did:owner", "third")
+	require.NoError(t, err)
+	_, err = as.SessionJob(ctx, owner.SessionToken, admin.ID)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestWatchSessionJob(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	as := NewAgentService(nil, nil, WithAsyncRefresh(sessionQueue{store: store}, store, time.Hour))
+
+	owner, err := as.enqueueRefresh(ctx, refreshMessage("1", "did:owner", map[string]string{"id": "urn:uuid:first"}))
+	require.NoError(t, err)
+	other, err := as.enqueueRefresh(ctx, refreshMessage("2", "did:other", map[string]string{"id": "urn:uuid:second"}))
+	require.NoError(t, err)
+
+	updates, err := as.WatchSessionJob(ctx, owner.SessionToken, owner.Jobs[0].ID)
+	require.NoError(t, err)
+	job := <-updates
+	require.Equal(t, owner.Jobs[0].ID, job.ID)
+
+	_, err = as.WatchSessionJob(ctx, other.SessionToken, owner.Jobs[0].ID)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = as.WatchSessionJob(ctx, "unknown", owner.Jobs[0].ID)
+	require.ErrorIs(t, err, ErrInvalidOwnerSession)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/pkg/errors"
+)
+
+const (
+	CodeInvalidRequestSchema    = 1000
+	CodeInvalidResponseSchema   = 1001
+	CodeDataProviderIssue       = 1002
+	CodeInvalidProtocolMessage  = 2000
+	CodeInvalidProtocolResponse = 2001
+	CodeReplayedMessage         = 2002
+	CodeInvalidOwnershipProof   = 2003
+	CodeInvalidGrant            = 2004
+	CodeInvalidAccessToken      = 2005
+	CodeUnsupportedFormat       = 2006
+	CodeInvalidOwnerSession     = 2007
+	CodeIssuerNotSupported      = 3000
+	CodeGetClaim                = 3001
+	CodeCreateClaim             = 3002
+	CodeOwnerMethodNotSupported = 3003
+	CodeCredentialNotUpdatable  = 4000
+	CodeRefreshInProgress       = 4001
+	CodeQuotaExceeded           = 4002
+	CodeRefreshTimeout          = 4003
+	CodeSubjectTooLarge         = 4004
+	CodeRevocationNonceConflict = 4005
+	CodeInternal                = 500
+)
+
+// ErrorCode classifies err into the refresh service error code.
+func ErrorCode(err error) int {
+	switch {
+	case errors.Is(err, flexiblehttp.ErrInvalidRequestSchema):
+		return CodeInvalidRequestSchema
+	case errors.Is(err, flexiblehttp.ErrInvalidResponseSchema):
+		return CodeInvalidResponseSchema
+	case errors.Is(err, flexiblehttp.ErrDataProviderIssue):
+		return CodeDataProviderIssue
+
+	case errors.Is(err, ErrInvalidProtocolMessage):
+		return CodeInvalidProtocolMessage
+	case errors.Is(err, ErrInvalidProtocolResponse):
+		return CodeInvalidProtocolResponse
+	case errors.Is(err, ErrReplayedMessage):
+		return CodeReplayedMessage
+	case errors.Is(err, ErrInvalidOwnershipProof):
+		return CodeInvalidOwnershipProof
+	case errors.Is(err, ErrInvalidGrant):
+		return CodeInvalidGrant
+	case errors.Is(err, ErrInvalidAccessToken):
+		return CodeInvalidAccessToken
+	case errors.Is(err, ErrUnsupportedCredentialFormat):
+		return CodeUnsupportedFormat
+	case errors.Is(err, ErrInvalidOwnerSession):
+		return CodeInvalidOwnerSession
+
+	case errors.Is(err, ErrIssuerNotSupported):
+		return CodeIssuerNotSupported
+	case errors.Is(err, ErrGetClaim):
+		return CodeGetClaim
+	case errors.Is(err, ErrCreateClaim):
+		return CodeCreateClaim
+	case errors.Is(err, ErrOwnerMethodNotSupported):
+		return CodeOwnerMethodNotSupported
+
+	case errors.Is(err, ErrCredentialNotUpdatable):
+		return CodeCredentialNotUpdatable
+	case errors.Is(err, ErrRefreshInProgress):
+		return CodeRefreshInProgress
+	case errors.Is(err, ErrQuotaExceeded):
+		return CodeQuotaExceeded
+	case errors.Is(err, ErrRefreshTimeout):
+		return CodeRefreshTimeout
+	case errors.Is(err, ErrSubjectTooLarge):
+		return CodeSubjectTooLarge
+	case errors.Is(err, ErrRevocationNonceConflict):
+		return CodeRevocationNonceConflict
+	default:
+		return CodeInternal
+	}
+}
+
+// RetryAfter returns the delay a throttling data provider or issuer node
+// asked for with Retry-After.
+func RetryAfter(err error) (time.Duration, bool) {
+	var throttled *httpclient.ThrottledError
+	if errors.As(err, &throttled) && throttled.RetryAfter > 0 {
+		return throttled.RetryAfter, true
+	}
+	return 0, false
+}
+
+// IsRetryable reports whether err is transient, so the same refresh may
+// succeed when it is retried later.
+func IsRetryable(err error) bool {
+	switch ErrorCode(err) {
+	case CodeDataProviderIssue,
+		CodeGetClaim,
+		CodeCreateClaim,
+		CodeRefreshInProgress,
+		CodeRefreshTimeout,
+		CodeInternal:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsServerError reports whether err is a failure of the refresh service,
+// its data providers or issuer nodes rather than of the request, so it
+// counts against the availability of refreshes.
+func IsServerError(err error) bool {
+	switch ErrorCode(err) {
+	case CodeInvalidRequestSchema,
+		CodeInvalidResponseSchema,
+		CodeDataProviderIssue,
+		CodeInvalidProtocolResponse,
+		CodeGetClaim,
+		CodeCreateClaim,
+		CodeRefreshTimeout,
+		CodeInternal:
+		return true
+	default:
+		return false
+	}
+}
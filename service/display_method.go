@@ -0,0 +1,56 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// WithDisplayMethods sets the display method attached to reissued
+// credentials without one, per issuer DID, so they render in wallets. The
+// '*' key applies to all other issuers. Credentials with a display method
+// keep theirs.
+func WithDisplayMethods(methods map[string]verifiable.DisplayMethod) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.displayMethods = methods
+	}
+}
+
+// ParseDisplayMethod parses a display method in the 'url[|type]' format,
+// e.g. 'https://example.com/display.json' or
+// 'ipfs://Qm...|Iden3BasicDisplayMethodV1'. The type defaults to
+// Iden3BasicDisplayMethodV1.
+func ParseDisplayMethod(value string) (verifiable.DisplayMethod, error) {
+	id, displayType, hasType := strings.Cut(strings.TrimSpace(value), "|")
+	u, err := url.Parse(id)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ipfs") {
+		return verifiable.DisplayMethod{}, errors.Errorf(
+			"invalid display method '%s': the template must be an http, https or ipfs URL", value)
+	}
+	method := verifiable.DisplayMethod{ID: id, Type: verifiable.Iden3BasicDisplayMethodV1}
+	if hasType {
+		if displayType == "" {
+			return verifiable.DisplayMethod{}, errors.Errorf("invalid display method '%s': empty type", value)
+		}
+		method.Type = verifiable.DisplayMethodType(displayType)
+	}
+	return method, nil
+}
+
+// displayMethod returns the display method of the credential reissued by
+// issuer from one with original.
+func (rs *RefreshService) displayMethod(issuer string, original *verifiable.DisplayMethod) *verifiable.DisplayMethod {
+	if original != nil {
+		return original
+	}
+	method, ok := rs.displayMethods[issuer]
+	if !ok {
+		method, ok = rs.displayMethods["*"]
+	}
+	if !ok {
+		return nil
+	}
+	return &method
+}
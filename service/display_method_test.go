@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDisplayMethod(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    verifiable.DisplayMethod
+		expectedErr bool
+	}{
+		{
+			name:     "Default type",
+			value:    "https://example.com/display.json",
+			expected: verifiable.DisplayMethod{ID: "https://example.com/display.json", Type: verifiable.Iden3BasicDisplayMethodV1},
+		},
+		{
+			name:     "IPFS with type",
+			value:    "ipfs://QmZ1zsLspwnjifxsncqDkB7EHb2pnaRnBPc5kqQcVxW5rV|CustomDisplayMethod",
+			expected: verifiable.DisplayMethod{ID: "ipfs://QmZ1zsLspwnjifxsncqDkB7EHb2pnaRnBPc5kqQcVxW5rV", Type: "CustomDisplayMethod"},
+		},
+		{name: "Relative URL", value: "display.json", expectedErr: true},
+		{name: "Unsupported scheme", value: "ftp://example.com/display.json", expectedErr: true},
+		{name: "Empty type", value: "https://example.com/display.json|", expectedErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, err := ParseDisplayMethod(tt.value)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, method)
+		})
+	}
+}
+
+func TestDisplayMethod(t *testing.T) {
+	issuerMethod := verifiable.DisplayMethod{ID: "https://issuer1.example.com/display.json", Type: verifiable.Iden3BasicDisplayMethodV1}
+	defaultMethod := verifiable.DisplayMethod{ID: "https://example.com/display.json", Type: verifiable.Iden3BasicDisplayMethodV1}
+	original := &verifiable.DisplayMethod{ID: "https://original.example.com/display.json", Type: verifiable.Iden3BasicDisplayMethodV1}
+
+	rs := NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{}, WithDisplayMethods(
+		map[string]verifiable.DisplayMethod{"did:iden3:issuer1": issuerMethod, "*": defaultMethod},
+	))
+	require.Equal(t, original, rs.displayMethod("did:iden3:issuer1", original))
+	require.Equal(t, &issuerMethod, rs.displayMethod("did:iden3:issuer1", nil))
+	require.Equal(t, &defaultMethod, rs.displayMethod("did:iden3:issuer2", nil))
+
+	rs = NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{})
+	require.Nil(t, rs.displayMethod("did:iden3:issuer1", nil))
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/didresolver"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/pkg/errors"
+)
+
+var ErrInvalidOwnershipProof = errors.New("invalid ownership proof")
+
+const (
+	eip712DomainName    = "Refresh Service"
+	eip712DomainVersion = "1"
+	eip712PrimaryType   = "CredentialRefresh"
+)
+
+// SignedRefreshRequest is a refresh request signed with EIP-712 by an
+// Ethereum key controlling the owner DID. It is an alternative to an
+// authenticated iden3comm message for wallets which hold such a key.
+type SignedRefreshRequest struct {
+	ID           string `json:"id"`
+	Issuer       string `json:"issuer"`
+	Owner        string `json:"owner"`
+	CredentialID string `json:"credentialId"`
+	// ExpiresAt is a unix timestamp after which the signature is rejected.
+	ExpiresAt int64  `json:"expiresAt"`
+	Signature string `json:"signature"`
+}
+
+// TypedData returns the EIP-712 typed data the owner signs. The domain chain
+// id is the chain of the owner DID, DIDs which aren't bound to a chain have
+// none.
+func (r SignedRefreshRequest) TypedData(chainID int64) apitypes.TypedData {
+	domainTypes := []apitypes.Type{
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+	}
+	domain := apitypes.TypedDataDomain{
+		Name:    eip712DomainName,
+		Version: eip712DomainVersion,
+	}
+	if chainID != 0 {
+		domainTypes = append(domainTypes, apitypes.Type{Name: "chainId", Type: "uint256"})
+		domain.ChainId = (*math.HexOrDecimal256)(big.NewInt(chainID))
+	}
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domainTypes,
+			eip712PrimaryType: {
+				{Name: "id", Type: "string"},
+				{Name: "issuer", Type: "string"},
+				{Name: "owner", Type: "string"},
+				{Name: "credentialId", Type: "string"},
+				{Name: "expiresAt", Type: "uint256"},
+			},
+		},
+		PrimaryType: eip712PrimaryType,
+		Domain:      domain,
+		Message: apitypes.TypedDataMessage{
+			"id":           r.ID,
+			"issuer":       r.Issuer,
+			"owner":        r.Owner,
+			"credentialId": r.CredentialID,
+			"expiresAt":    strconv.FormatInt(r.ExpiresAt, 10),
+		},
+	}
+}
+
+// DIDResolver resolves an owner DID to the Ethereum accounts controlling
+// it.
+type DIDResolver interface {
+	Resolve(ctx context.Context, did string) (didresolver.Document, error)
+}
+
+// WithDIDResolver resolves the owners of signed refresh requests. The
+// default only resolves iden3 and polygonid DIDs created from an Ethereum
+// address.
+func WithDIDResolver(resolver DIDResolver) AgentOption {
+	return func(as *AgentService) {
+		if resolver != nil {
+			as.didResolver = resolver
+		}
+	}
+}
+
+func defaultDIDResolver() DIDResolver {
+	r := didresolver.NewRegistry()
+	r.Register("iden3", didresolver.Iden3{})
+	r.Register("polygonid", didresolver.Iden3{})
+	return r
+}
+
+// verifySignedRefresh checks that the request is signed by an Ethereum
+// account controlling the owner DID.
+func verifySignedRefresh(ctx context.Context, resolver DIDResolver, r SignedRefreshRequest, now time.Time) error {
+	if r.ID == "" || r.Issuer == "" || r.Owner == "" || r.CredentialID == "" {
+		return errors.Wrap(ErrInvalidProtocolMessage, "missing required fields in signed refresh request")
+	}
+	if now.Unix() > r.ExpiresAt {
+		return errors.Wrap(ErrInvalidOwnershipProof, "signed refresh request expired")
+	}
+
+	owner, err := resolver.Resolve(ctx, r.Owner)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidOwnershipProof, "failed to resolve owner DID: %v", err)
+	}
+	if len(owner.Controllers) == 0 {
+		return errors.Wrap(ErrInvalidOwnershipProof, "owner DID is not Ethereum controlled")
+	}
+	hash, _, err := apitypes.TypedDataAndHash(r.TypedData(owner.ChainID))
+	if err != nil {
+		return errors.Wrapf(ErrInvalidOwnershipProof, "failed to hash typed data: %v", err)
+	}
+	sig, err := hexutil.Decode(r.Signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return errors.Wrap(ErrInvalidOwnershipProof, "invalid signature encoding")
+	}
+	// wallets return v as 27/28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidOwnershipProof, "failed to recover signer: %v", err)
+	}
+	signer := crypto.PubkeyToAddress(*pub)
+	if !owner.Controls(signer) {
+		return errors.Wrapf(ErrInvalidOwnershipProof, "signer '%s' does not control owner DID", signer.Hex())
+	}
+	return nil
+}
+
+// ProcessSigned refreshes a credential for an EIP-712 signed request.
+func (as *AgentService) ProcessSigned(ctx context.Context, request SignedRefreshRequest) (*RefreshResult, error) {
+	// owners of methods the issuer doesn't accept are not resolved at all
+	if err := as.refreshService.CheckOwnerMethod(request.Issuer, request.Owner); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := as.checkProofLifetime(request.ExpiresAt, now); err != nil {
+		return nil, err
+	}
+	if err := verifySignedRefresh(ctx, as.didResolver, request, now); err != nil {
+		return nil, err
+	}
+	if err := as.rememberMessage(ctx, request.Owner, request.ID, ""); err != nil {
+		return nil, err
+	}
+	return as.refreshOwned(ctx, request.Issuer, request.Owner, request.CredentialID)
+}
+
+// refreshOwned refreshes a credential for an owner who proved ownership
+// outside of iden3comm.
+func (as *AgentService) refreshOwned(ctx context.Context, issuer, owner, credentialID string) (*RefreshResult, error) {
+	refreshed, err := as.refreshService.Process(ctx, issuer, owner, convertID(credentialID))
+	if err != nil {
+		return nil, err
+	}
+	result, err := as.newRefreshResult(refreshed)
+	if err != nil {
+		return nil, err
+	}
+	if err := as.attachJWT(ctx, issuer, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/didresolver"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	core "github.com/iden3/go-iden3-core/v2"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func signRefresh(t *testing.T, r *SignedRefreshRequest, key []byte, chainID int64) {
+	t.Helper()
+	privateKey, err := crypto.ToECDSA(key)
+	require.NoError(t, err)
+	hash, _, err := apitypes.TypedDataAndHash(r.TypedData(chainID))
+	require.NoError(t, err)
+	sig, err := crypto.Sign(hash, privateKey)
+	require.NoError(t, err)
+	sig[crypto.RecoveryIDOffset] += 27
+	r.Signature = hexutil.Encode(sig)
+}
+
+func ethereumDID(t *testing.T, key []byte) string {
+	t.Helper()
+	privateKey, err := crypto.ToECDSA(key)
+	require.NoError(t, err)
+	typ, err := core.BuildDIDType(core.DIDMethodPolygonID, core.Polygon, core.Amoy)
+	require.NoError(t, err)
+	id := core.NewID(typ, core.GenesisFromEthAddress(crypto.PubkeyToAddress(privateKey.PublicKey)))
+	did, err := core.ParseDIDFromID(id)
+	require.NoError(t, err)
+	return did.String()
+}
+
+func TestVerifySignedRefresh(t *testing.T) {
+	ownerKey := hexutil.MustDecode("0x4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	otherKey := hexutil.MustDecode("0x6cbed15c793ce57650b9877cf6fa156fbef513c4e6134f022a85b1ffdd59b2a1")
+	now := time.Unix(1700000000, 0)
+	const amoyChainID = 80002
+	ownerPrivateKey, err := crypto.ToECDSA(ownerKey)
+	require.NoError(t, err)
+	ownerAddress := crypto.PubkeyToAddress(ownerPrivateKey.PublicKey)
+
+	newRequest := func() SignedRefreshRequest {
+		return SignedRefreshRequest{
+			ID:           "3c8d1a5e-1b3f-4a9e-9f7c-1b2e3d4c5a6b",
+			Issuer:       "did:iden3:polygon:amoy:xFZ5sUp9y7cHfkz3pAk1Z4HNQTvCWQsTRBHk8Pjnq",
+			Owner:        ethereumDID(t, ownerKey),
+			CredentialID: "urn:uuid:7a1e6b2c-8f0d-4c3a-9b5e-2d1f0a9c8b7e",
+			ExpiresAt:    now.Add(time.Minute).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		request     func() SignedRefreshRequest
+		expectedErr error
+	}{
+		{
+			name: "Signed by owner",
+			request: func() SignedRefreshRequest {
+				r := newRequest()
+				signRefresh(t, &r, ownerKey, amoyChainID)
+				return r
+			},
+		},
+		{
+			name: "Signed by other key",
+			request: func() SignedRefreshRequest {
+				r := newRequest()
+				signRefresh(t, &r, otherKey, amoyChainID)
+				return r
+			},
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+		{
+			name: "Tampered credential id",
+			request: func() SignedRefreshRequest {
+				r := newRequest()
+				signRefresh(t, &r, ownerKey, amoyChainID)
+				r.CredentialID = "urn:uuid:00000000-0000-0000-0000-000000000000"
+				return r
+			},
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+		{
+			name: "Expired",
+			request: func() SignedRefreshRequest {
+				r := newRequest()
+				r.ExpiresAt = now.Add(-time.Second).Unix()
+				signRefresh(t, &r, ownerKey, amoyChainID)
+				return r
+			},
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+		{
+			name: "Signed for another chain",
+			request: func() SignedRefreshRequest {
+				r := newRequest()
+				signRefresh(t, &r, ownerKey, 1)
+				return r
+			},
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+		{
+			name: "did:ethr owner",
+			request: func() SignedRefreshRequest {
+				r := newRequest()
+				r.Owner = "did:ethr:amoy:" + ownerAddress.Hex()
+				signRefresh(t, &r, ownerKey, amoyChainID)
+				return r
+			},
+		},
+		{
+			name: "did:key owner",
+			request: func() SignedRefreshRequest {
+				r := newRequest()
+				r.Owner = "did:key:z" + base58.Encode(append([]byte{0xe7, 0x01},
+					crypto.CompressPubkey(&ownerPrivateKey.PublicKey)...))
+				signRefresh(t, &r, ownerKey, 0)
+				return r
+			},
+		},
+		{
+			name: "Unresolvable owner",
+			request: func() SignedRefreshRequest {
+				r := newRequest()
+				r.Owner = "did:example:123"
+				signRefresh(t, &r, ownerKey, 0)
+				return r
+			},
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+		{
+			name: "Missing credential id",
+			request: func() SignedRefreshRequest {
+				r := newRequest()
+				r.CredentialID = ""
+				return r
+			},
+			expectedErr: ErrInvalidProtocolMessage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := didresolver.NewDefaultRegistry(nil)
+			err := verifySignedRefresh(context.Background(), resolver, tt.request(), now)
+			if tt.expectedErr != nil {
+				require.True(t, errors.Is(err, tt.expectedErr), err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/0xPolygonID/refresh-service/events"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+)
+
+// WithEvents publishes when a refresh is requested and how it ended.
+func WithEvents(publisher events.Publisher) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.events = publisher
+	}
+}
+
+func (rs *RefreshService) publish(
+	ctx context.Context,
+	eventType string,
+	trace *refreshTrace,
+	refreshed *verifiable.W3CCredential,
+	refreshErr error,
+) {
+	if rs.events == nil {
+		return
+	}
+	event := events.Event{
+		Type:           eventType,
+		Time:           time.Now().UTC(),
+		RequestID:      correlation.FromContext(ctx),
+		Issuer:         trace.issuer,
+		Owner:          trace.owner,
+		CredentialID:   trace.credentialID,
+		CredentialType: trace.credentialType,
+	}
+	if eventType != events.TypeRefreshRequested {
+		event.DurationMillis = time.Since(trace.start).Milliseconds()
+	}
+	if refreshed != nil {
+		event.RefreshedID = convertID(refreshed.ID)
+	}
+	if refreshErr != nil {
+		event.ErrorCode = ErrorCode(refreshErr)
+		event.Error = refreshErr.Error()
+	}
+	if err := rs.events.Publish(context.WithoutCancel(ctx), event); err != nil {
+		logger.SampledWarnf("failed to publish '%s' event: %v", eventType, err)
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"github.com/0xPolygonID/refresh-service/features"
+	"github.com/pkg/errors"
+)
+
+// FeatureBatchMessages gates agent messages carrying several credential
+// ids. It is enabled unless a flag is defined, rolled out by owner.
+const FeatureBatchMessages = "batch-messages"
+
+// WithFeatureFlags gates features with flags, see the features package.
+// Data providers with a featureFlag setting are only used when their flag
+// is enabled.
+func WithFeatureFlags(flags *features.Set) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.features = flags
+	}
+}
+
+// checkProviderFlag rejects a credential of a provider which is gated by
+// flag, rolled out by credential id.
+func (rs *RefreshService) checkProviderFlag(flag, issuer, credentialID string) error {
+	if flag == "" || rs.features.Enabled(flag, features.Target{Issuer: issuer, Key: credentialID}, false) {
+		return nil
+	}
+	return errors.Errorf("provider disabled by feature flag '%s'", flag)
+}
+
+// featureEnabled evaluates the feature flag name of the refresh service.
+func (as *AgentService) featureEnabled(name, issuer, key string, fallback bool) bool {
+	if as.refreshService == nil {
+		return fallback
+	}
+	return as.refreshService.features.Enabled(name, features.Target{Issuer: issuer, Key: key}, fallback)
+}
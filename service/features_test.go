@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/features"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckProviderFlag(t *testing.T) {
+	flags := features.NewSet(map[string]features.Flag{
+		"new-provider": {Issuers: []string{"did:issuer:a"}},
+	})
+
+	tests := []struct {
+		name        string
+		flags       *features.Set
+		flag        string
+		issuer      string
+		expectedErr bool
+	}{
+		{
+			name:   "Provider without flag",
+			flags:  flags,
+			issuer: "did:issuer:b",
+		},
+		{
+			name:   "Enabled for the issuer",
+			flags:  flags,
+			flag:   "new-provider",
+			issuer: "did:issuer:a",
+		},
+		{
+			name:        "Disabled for the issuer",
+			flags:       flags,
+			flag:        "new-provider",
+			issuer:      "did:issuer:b",
+			expectedErr: true,
+		},
+		{
+			name:        "Undefined flag",
+			flags:       flags,
+			flag:        "other-provider",
+			issuer:      "did:issuer:a",
+			expectedErr: true,
+		},
+		{
+			name:        "No feature flags",
+			flag:        "new-provider",
+			issuer:      "did:issuer:a",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := &RefreshService{features: tt.flags}
+			err := rs.checkProviderFlag(tt.flag, tt.issuer, "urn:uuid:1")
+			if tt.expectedErr {
+				require.ErrorContains(t, err, "feature flag '"+tt.flag+"'")
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
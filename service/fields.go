@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"slices"
+)
+
+type selectedFieldsKey struct{}
+
+// SelectFields limits the refresh run with ctx to the named credential
+// subject fields: only the providers of those fields are called and only
+// they are merged into the subject. Types opt in with the selectiveFields
+// provider setting.
+func SelectFields(ctx context.Context, fields []string) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, selectedFieldsKey{}, slices.Clone(fields))
+}
+
+func selectedFields(ctx context.Context) []string {
+	fields, _ := ctx.Value(selectedFieldsKey{}).([]string)
+	return fields
+}
+
+// keepFields drops the fields which were not selected.
+func keepFields(fields map[string]interface{}, selected []string) {
+	for k := range fields {
+		if !slices.Contains(selected, k) {
+			delete(fields, k)
+		}
+	}
+}
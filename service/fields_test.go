@@ -0,0 +1,20 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectFields(t *testing.T) {
+	require.Nil(t, selectedFields(context.Background()))
+	require.Nil(t, selectedFields(SelectFields(context.Background(), nil)))
+	require.Equal(t, []string{"score"}, selectedFields(SelectFields(context.Background(), []string{"score"})))
+}
+
+func TestKeepFields(t *testing.T) {
+	fields := map[string]interface{}{"score": 42, "tier": "gold", "name": "Alice"}
+	keepFields(fields, []string{"score", "tier"})
+	require.Equal(t, map[string]interface{}{"score": 42, "tier": "gold"}, fields)
+}
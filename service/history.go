@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/0xPolygonID/refresh-service/events"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/metrics"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+)
+
+// refreshTrace collects what is known about a refresh while it runs.
+type refreshTrace struct {
+	issuer         string
+	owner          string
+	credentialID   string
+	credentialType string
+	start          time.Time
+	// stage is the refresh stage running, see enter
+	stage string
+}
+
+func (rs *RefreshService) record(
+	ctx context.Context,
+	trace *refreshTrace,
+	refreshed *verifiable.W3CCredential,
+	refreshErr error,
+) {
+	if refreshErr != nil {
+		rs.publish(ctx, events.TypeRefreshFailed, trace, refreshed, refreshErr)
+	} else {
+		rs.publish(ctx, events.TypeRefreshSucceeded, trace, refreshed, nil)
+	}
+	metrics.ObserveRefresh(ctx, trace.credentialType, refreshResult(refreshErr), time.Since(trace.start))
+
+	if rs.history != nil {
+		record := storage.RefreshRecord{
+			RequestID:      correlation.FromContext(ctx),
+			Issuer:         trace.issuer,
+			Owner:          trace.owner,
+			CredentialID:   trace.credentialID,
+			CredentialType: trace.credentialType,
+			Status:         storage.RefreshStatusSucceeded,
+			Duration:       time.Since(trace.start),
+			CreatedAt:      trace.start.UTC(),
+		}
+		if refreshed != nil {
+			record.RefreshedID = convertID(refreshed.ID)
+		}
+		if refreshErr != nil {
+			record.Status = storage.RefreshStatusFailed
+			record.ErrorCode = ErrorCode(refreshErr)
+			record.Error = refreshErr.Error()
+		}
+		if err := rs.history.SaveRefresh(context.WithoutCancel(ctx), record); err != nil {
+			logger.DefaultLogger.Errorf("failed to save refresh history: %v", err)
+		}
+	}
+
+	if rs.lineage != nil && refreshErr == nil && refreshed != nil {
+		err := rs.lineage.SaveLineage(context.WithoutCancel(ctx), storage.LineageRecord{
+			CredentialID: convertID(refreshed.ID),
+			ParentID:     trace.credentialID,
+			Issuer:       trace.issuer,
+			CreatedAt:    time.Now().UTC(),
+		})
+		if err != nil {
+			logger.DefaultLogger.Errorf("failed to save lineage: %v", err)
+		}
+	}
+}
+
+// refreshResult classifies a refresh for its metrics.
+func refreshResult(err error) string {
+	switch {
+	case err == nil:
+		return metrics.ResultSuccess
+	case IsServerError(err):
+		return metrics.ResultServerError
+	default:
+		return metrics.ResultClientError
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/events"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingStore struct {
+	refreshes []storage.RefreshRecord
+	lineage   []storage.LineageRecord
+}
+
+func (s *recordingStore) SaveRefresh(_ context.Context, r storage.RefreshRecord) error {
+	s.refreshes = append(s.refreshes, r)
+	return nil
+}
+
+func (s *recordingStore) ListRefreshes(context.Context, storage.RefreshFilter) ([]storage.RefreshRecord, error) {
+	return s.refreshes, nil
+}
+
+func (s *recordingStore) CountRefreshes(_ context.Context, f storage.RefreshFilter) (int64, error) {
+	var count int64
+	for _, r := range s.refreshes {
+		if (f.Owner == "" || r.Owner == f.Owner) &&
+			(f.CredentialType == "" || r.CredentialType == f.CredentialType) &&
+			(f.CredentialID == "" || r.CredentialID == f.CredentialID) &&
+			(f.Status == "" || r.Status == f.Status) &&
+			!r.CreatedAt.Before(f.From) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *recordingStore) SaveLineage(_ context.Context, r storage.LineageRecord) error {
+	s.lineage = append(s.lineage, r)
+	return nil
+}
+
+func (s *recordingStore) Lineage(context.Context, string) ([]storage.LineageRecord, error) {
+	return s.lineage, nil
+}
+
+func TestRecord(t *testing.T) {
+	tests := []struct {
+		name            string
+		refreshed       *verifiable.W3CCredential
+		err             error
+		expectedStatus  string
+		expectedCode    int
+		expectedLineage int
+	}{
+		{
+			name:            "Successful refresh",
+			refreshed:       &verifiable.W3CCredential{ID: "urn:uuid:new-id"},
+			expectedStatus:  storage.RefreshStatusSucceeded,
+			expectedLineage: 1,
+		},
+		{
+			name:           "Failed refresh",
+			err:            errors.Wrap(ErrGetClaim, "invalid status code: '500'"),
+			expectedStatus: storage.RefreshStatusFailed,
+			expectedCode:   CodeGetClaim,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &recordingStore{}
+			rs := NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{}, WithHistory(store), WithLineage(store))
+			rs.record(context.Background(), &refreshTrace{
+				issuer:         "did:iden3:issuer",
+				owner:          "did:iden3:owner",
+				credentialID:   "old-id",
+				credentialType: "Balance",
+				start:          time.Now(),
+			}, tt.refreshed, tt.err)
+
+			require.Len(t, store.refreshes, 1)
+			require.Equal(t, tt.expectedStatus, store.refreshes[0].Status)
+			require.Equal(t, tt.expectedCode, store.refreshes[0].ErrorCode)
+			require.Equal(t, "Balance", store.refreshes[0].CredentialType)
+			require.Len(t, store.lineage, tt.expectedLineage)
+			if tt.expectedLineage > 0 {
+				require.Equal(t, "new-id", store.lineage[0].CredentialID)
+				require.Equal(t, "old-id", store.lineage[0].ParentID)
+			}
+		})
+	}
+}
+
+type recordedEvents struct {
+	events []events.Event
+}
+
+func (r *recordedEvents) Publish(_ context.Context, event events.Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordedEvents) Close() error {
+	return nil
+}
+
+func TestProcess_PublishesEvents(t *testing.T) {
+	publisher := &recordedEvents{}
+	is := NewIssuerService(map[string]string{"did:iden3:other": "http://issuer"}, nil, nil)
+	rs := NewRefreshService(is, &slowDocumentLoader{}, flexiblehttp.FactoryFlexibleHTTP{}, WithEvents(publisher))
+
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-1")
+	_, err := rs.Process(ctx, "did:iden3:issuer", "did:iden3:owner", "1")
+	require.ErrorIs(t, err, ErrIssuerNotSupported)
+
+	require.Len(t, publisher.events, 2)
+	requested, failed := publisher.events[0], publisher.events[1]
+	require.Equal(t, events.TypeRefreshRequested, requested.Type)
+	require.Equal(t, "req-1", requested.RequestID)
+	require.Equal(t, "did:iden3:owner", requested.Owner)
+	require.Equal(t, events.TypeRefreshFailed, failed.Type)
+	require.Equal(t, "1", failed.CredentialID)
+	require.Equal(t, CodeIssuerNotSupported, failed.ErrorCode)
+	require.NotEmpty(t, failed.Error)
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	jsonproc "github.com/iden3/go-schema-processor/v2/json"
+	"github.com/iden3/go-schema-processor/v2/merklize"
+	"github.com/iden3/go-schema-processor/v2/processor"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// Inspection is what the refresh pipeline reads of a credential, with the
+// checks deciding whether it is updatable.
+type Inspection struct {
+	ID              string           `json:"id"`
+	Issuer          string           `json:"issuer"`
+	Type            []string         `json:"type"`
+	CredentialType  string           `json:"credentialType,omitempty"`
+	Expiration      *time.Time       `json:"expiration,omitempty"`
+	RevocationNonce *uint64          `json:"revocationNonce,omitempty"`
+	CoreClaim       *CoreClaimLayout `json:"coreClaim,omitempty"`
+	// IssuedLayout is the layout of the core claim in the proofs of the
+	// credential, kept by reissued credentials.
+	IssuedLayout ClaimPositions    `json:"issuedLayout"`
+	Checks       []InspectionCheck `json:"checks"`
+	Updatable    bool              `json:"updatable"`
+}
+
+// CoreClaimLayout is the core claim of a credential as the refresh pipeline
+// computes it from the credential and its schema.
+type CoreClaimLayout struct {
+	SchemaHash      string `json:"schemaHash"`
+	Version         uint32 `json:"version"`
+	UpdatableFlag   bool   `json:"updatableFlag"`
+	RevocationNonce uint64 `json:"revocationNonce"`
+	ClaimPositions
+	// IndexSlots and ValueSlots are the decimal values of the slots.
+	IndexSlots []string `json:"indexSlots"`
+	ValueSlots []string `json:"valueSlots"`
+	// FieldSlots are the slots of the subject fields of a credential which
+	// is not merklized, 2 and 3 in the index and 6 and 7 in the value.
+	FieldSlots map[string]int `json:"fieldSlots,omitempty"`
+	// UnslottedFields have no serialization info in the schema. The index
+	// slots are not checked for credentials with such fields.
+	UnslottedFields []string `json:"unslottedFields,omitempty"`
+}
+
+// InspectionCheck is a check of the refresh pipeline.
+type InspectionCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Inspect fetches a credential from the issuer node and inspects it.
+func (rs *RefreshService) Inspect(ctx context.Context, issuer, owner, id string) (*Inspection, error) {
+	if rs.issuerService == nil {
+		return nil, errors.New("issuerService is nil")
+	}
+	credential, err := rs.issuerService.GetClaimByID(ctx, issuer, id)
+	if err != nil {
+		return nil, err
+	}
+	return rs.InspectCredential(ctx, issuer, owner, credential), nil
+}
+
+// InspectCredential reports the core claim of a credential and runs the
+// checks a refresh of it would, up to the data provider, which is not
+// called. Without owner the ownership checks are skipped. The index slots
+// check tells whether the fields the provider maps can update the index.
+func (rs *RefreshService) InspectCredential(
+	ctx context.Context,
+	issuer, owner string,
+	credential *verifiable.W3CCredential,
+) *Inspection {
+	inspection := &Inspection{
+		ID:           credential.ID,
+		Issuer:       credential.Issuer,
+		Type:         credential.Type,
+		Expiration:   credential.Expiration,
+		IssuedLayout: issuedPositions(credential),
+	}
+	if issuer == "" {
+		issuer = credential.Issuer
+	}
+	check := func(name string, err error, detail string) bool {
+		c := InspectionCheck{Name: name, Passed: err == nil, Detail: detail}
+		if err != nil {
+			c.Detail = err.Error()
+		}
+		inspection.Checks = append(inspection.Checks, c)
+		return err == nil
+	}
+	if !check("required fields", requiredFields(credential), "") {
+		return inspection
+	}
+	if nonce, err := extractRevocationNonce(credential); check("revocation nonce", err, "") {
+		inspection.RevocationNonce = &nonce
+	}
+	check("eligibility", rs.updatability.Updatable(ctx, credential, time.Now()), "")
+	check("refresh service type", rs.checkRefreshServiceType(credential), "")
+	if owner != "" {
+		check("owner method", rs.CheckOwnerMethod(issuer, owner), "")
+		check("ownership", rs.ownership.VerifyOwnership(ctx, credential, owner), "")
+	}
+	var schemaErr error
+	if credential.CredentialSchema.ID == "" {
+		schemaErr = errors.New("credential schema ID is empty")
+	}
+	check("credential schema", schemaErr, credential.CredentialSchema.ID)
+
+	layout, err := rs.coreClaimLayout(ctx, credential)
+	if check("core claim", err, "") {
+		inspection.CoreClaim = layout
+	}
+	credentialType, err := rs.credentialType(credential)
+	if !check("credential type", err, credentialType) {
+		return inspection
+	}
+	inspection.CredentialType = credentialType
+
+	provider, err := rs.providersOf(issuer).ProduceFlexibleHTTP(credentialType)
+	if err == nil {
+		err = rs.checkProviderFlag(provider.Settings.FeatureFlag, issuer, credential.ID)
+	}
+	if check("provider", err, strings.Join(provider.MappedFields(), ", ")) {
+		if provider.Settings.ExpirationOnly {
+			check("renewal", checkRenewable(credential), "the provider renews credentials with their subject unchanged")
+		} else if layout != nil {
+			detail, err := indexSlotsUpdatable(layout, provider.MappedFields())
+			check("index slots", err, detail)
+		}
+	}
+
+	inspection.Updatable = !slices.ContainsFunc(inspection.Checks, func(c InspectionCheck) bool { return !c.Passed })
+	return inspection
+}
+
+// requiredFields checks the fields prepare requires before anything else.
+func requiredFields(credential *verifiable.W3CCredential) error {
+	switch {
+	case credential.Issuer == "":
+		return errors.New("credential issuer is empty")
+	case credential.ID == "":
+		return errors.New("credential ID is empty")
+	case credential.Type == nil:
+		return errors.New("credential type is nil")
+	case credential.Expiration == nil:
+		return errors.New("credential expiration is nil")
+	case credential.CredentialSubject == nil:
+		return errors.New("credential subject is nil")
+	}
+	return nil
+}
+
+// credentialType returns the JSON-LD type id of the credential subject,
+// which selects the provider configuration.
+func (rs *RefreshService) credentialType(credential *verifiable.W3CCredential) (string, error) {
+	subjectType, ok := credential.CredentialSubject["type"].(string)
+	if !ok || subjectType == "" {
+		return "", errors.New("invalid or missing type in credentialSubject")
+	}
+	credentialBytes, err := json.Marshal(credential)
+	if err != nil {
+		return "", errors.Errorf("failed to serialize credential: %v", err)
+	}
+	return merklize.Options{DocumentLoader: rs.documentLoader}.TypeIDFromContext(credentialBytes, subjectType)
+}
+
+// coreClaimLayout parses the core claim of the credential the way the index
+// slots check does.
+func (rs *RefreshService) coreClaimLayout(ctx context.Context, credential *verifiable.W3CCredential) (*CoreClaimLayout, error) {
+	claim, err := jsonproc.Parser{}.ParseClaim(ctx, *credential, &processor.CoreClaimOptions{
+		MerklizerOpts: []merklize.MerklizeOption{
+			merklize.WithDocumentLoader(rs.documentLoader),
+		},
+	})
+	if err != nil {
+		return nil, errors.Errorf("invalid w3c credential: %v", err)
+	}
+	schemaHash := claim.GetSchemaHash()
+	layout := &CoreClaimLayout{
+		SchemaHash:      hex.EncodeToString(schemaHash[:]),
+		Version:         claim.GetVersion(),
+		UpdatableFlag:   claim.GetFlagUpdatable(),
+		RevocationNonce: claim.GetRevocationNonce(),
+		ClaimPositions:  claimPositions(claim),
+	}
+	for i, slot := range claim.RawSlotsAsInts() {
+		if i < 4 {
+			layout.IndexSlots = append(layout.IndexSlots, slot.String())
+		} else {
+			layout.ValueSlots = append(layout.ValueSlots, slot.String())
+		}
+	}
+	if layout.MerklizedRootPosition != "" {
+		return layout, nil
+	}
+
+	subjectType, _ := credential.CredentialSubject["type"].(string)
+	contexts, err := rs.loadContexts(credential.Context)
+	if err != nil {
+		return nil, errors.Errorf("failed to load contexts: %v", err)
+	}
+	layout.FieldSlots = make(map[string]int)
+	for field := range credential.CredentialSubject {
+		if field == "id" || field == "type" {
+			continue
+		}
+		slot, err := jsonproc.Parser{}.GetFieldSlotIndex(field, subjectType, contexts)
+		if err != nil && strings.Contains(err.Error(), "not specified in serialization info") {
+			layout.UnslottedFields = append(layout.UnslottedFields, field)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		layout.FieldSlots[field] = slot
+	}
+	sort.Strings(layout.UnslottedFields)
+	return layout, nil
+}
+
+func issuedPositions(credential *verifiable.W3CCredential) ClaimPositions {
+	merklizedRootPosition, subjectPosition := claimLayout(credential)
+	return ClaimPositions{MerklizedRootPosition: merklizedRootPosition, SubjectPosition: subjectPosition}
+}
+
+// indexSlotsUpdatable tells whether a refresh updating fields can change
+// the index of the claim, which the claims tree of the issuer requires.
+func indexSlotsUpdatable(layout *CoreClaimLayout, fields []string) (string, error) {
+	switch layout.MerklizedRootPosition {
+	case PositionIndex:
+		return "the merklized root is in the index, every subject change updates it", nil
+	case PositionValue:
+		return "", errors.Wrap(errIndexSlotsNotUpdated, "the merklized root is in the value")
+	}
+	if len(layout.UnslottedFields) > 0 {
+		return "fields without serialization info, the index slots are not checked: " +
+			strings.Join(layout.UnslottedFields, ", "), nil
+	}
+	var indexFields, updated []string
+	for field, slot := range layout.FieldSlots {
+		if slot == 2 || slot == 3 {
+			indexFields = append(indexFields, field)
+			if slices.Contains(fields, field) {
+				updated = append(updated, field)
+			}
+		}
+	}
+	sort.Strings(indexFields)
+	sort.Strings(updated)
+	if len(updated) == 0 {
+		return "", errors.Wrapf(errIndexSlotsNotUpdated,
+			"the provider updates none of the index slot fields [%s]", strings.Join(indexFields, ", "))
+	}
+	return "updatable when the provider changes one of " + strings.Join(updated, ", "), nil
+}
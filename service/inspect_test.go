@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/piprate/json-gold/ld"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type offlineDocumentLoader struct{}
+
+func (offlineDocumentLoader) LoadDocument(u string) (*ld.RemoteDocument, error) {
+	return nil, errors.Errorf("offline: %s", u)
+}
+
+func TestInspectCredential(t *testing.T) {
+	newCredential := func(expiration time.Time) *verifiable.W3CCredential {
+		var credential verifiable.W3CCredential
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"@context": ["https://www.w3.org/2018/credentials/v1", "https://example.com/kyc.jsonld"],
+			"id": "urn:uuid:1",
+			"issuer": "did:iden3:issuer",
+			"type": ["VerifiableCredential", "KYCAgeCredential"],
+			"credentialSubject": {"id": "did:iden3:owner", "type": "KYCAgeCredential", "birthday": 19960424},
+			"credentialStatus": {"id": "https://issuer.example.com/status", "type": "SparseMerkleTreeProof", "revocationNonce": 42},
+			"credentialSchema": {"id": "https://example.com/kyc.json", "type": "JsonSchema2023"}
+		}`), &credential))
+		credential.Expiration = &expiration
+		return &credential
+	}
+
+	tests := []struct {
+		name            string
+		credential      *verifiable.W3CCredential
+		owner           string
+		expectedChecks  map[string]bool
+		expectedMissing []string
+	}{
+		{
+			name:       "Offline schema",
+			credential: newCredential(time.Now().Add(-time.Hour)),
+			owner:      "did:iden3:owner",
+			expectedChecks: map[string]bool{
+				"required fields":      true,
+				"revocation nonce":     true,
+				"eligibility":          true,
+				"refresh service type": true,
+				"owner method":         true,
+				"ownership":            true,
+				"credential schema":    true,
+				"core claim":           false,
+				"credential type":      false,
+			},
+			expectedMissing: []string{"provider", "index slots"},
+		},
+		{
+			name:       "Not expired and other owner",
+			credential: newCredential(time.Now().Add(time.Hour)),
+			owner:      "did:iden3:other",
+			expectedChecks: map[string]bool{
+				"eligibility": false,
+				"ownership":   false,
+			},
+		},
+		{
+			name:       "Without owner",
+			credential: newCredential(time.Now().Add(-time.Hour)),
+			expectedChecks: map[string]bool{
+				"eligibility": true,
+			},
+			expectedMissing: []string{"owner method", "ownership"},
+		},
+		{
+			name:       "Without subject",
+			credential: &verifiable.W3CCredential{ID: "urn:uuid:1", Issuer: "did:iden3:issuer", Type: []string{"VerifiableCredential"}},
+			expectedChecks: map[string]bool{
+				"required fields": false,
+			},
+			expectedMissing: []string{"eligibility", "revocation nonce"},
+		},
+	}
+
+	rs := NewRefreshService(nil, offlineDocumentLoader{}, flexiblehttp.FactoryFlexibleHTTP{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspection := rs.InspectCredential(context.Background(), "", tt.owner, tt.credential)
+			require.False(t, inspection.Updatable)
+			require.Equal(t, tt.credential.ID, inspection.ID)
+
+			checks := make(map[string]bool, len(inspection.Checks))
+			for _, c := range inspection.Checks {
+				checks[c.Name] = c.Passed
+				if !c.Passed {
+					require.NotEmpty(t, c.Detail, c.Name)
+				}
+			}
+			for name, passed := range tt.expectedChecks {
+				require.Contains(t, checks, name)
+				require.Equal(t, passed, checks[name], name)
+			}
+			for _, name := range tt.expectedMissing {
+				require.NotContains(t, checks, name)
+			}
+			if checks["revocation nonce"] {
+				require.Equal(t, uint64(42), *inspection.RevocationNonce)
+			}
+		})
+	}
+}
+
+func TestIndexSlotsUpdatable(t *testing.T) {
+	tests := []struct {
+		name        string
+		layout      CoreClaimLayout
+		fields      []string
+		expectedErr bool
+	}{
+		{
+			name:   "Merklized root in index",
+			layout: CoreClaimLayout{ClaimPositions: ClaimPositions{MerklizedRootPosition: PositionIndex}},
+			fields: []string{"balance"},
+		},
+		{
+			name:        "Merklized root in value",
+			layout:      CoreClaimLayout{ClaimPositions: ClaimPositions{MerklizedRootPosition: PositionValue}},
+			fields:      []string{"balance"},
+			expectedErr: true,
+		},
+		{
+			name:   "Provider updates an index slot field",
+			layout: CoreClaimLayout{FieldSlots: map[string]int{"birthday": 2, "documentType": 3, "score": 6}},
+			fields: []string{"documentType"},
+		},
+		{
+			name:        "Provider updates only value slot fields",
+			layout:      CoreClaimLayout{FieldSlots: map[string]int{"birthday": 2, "documentType": 3, "score": 6}},
+			fields:      []string{"score"},
+			expectedErr: true,
+		},
+		{
+			name: "Fields without serialization info",
+			layout: CoreClaimLayout{
+				FieldSlots:      map[string]int{"birthday": 2},
+				UnslottedFields: []string{"nickname"},
+			},
+			fields: []string{"score"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detail, err := indexSlotsUpdatable(&tt.layout, tt.fields)
+			if tt.expectedErr {
+				require.ErrorIs(t, err, errIndexSlotsNotUpdated)
+				return
+			}
+			require.NoError(t, err)
+			require.NotEmpty(t, detail)
+		})
+	}
+}
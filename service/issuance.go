@@ -0,0 +1,121 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// WithIssuanceDate sets the issuance date of reissued credentials whose
+// provider doesn't set settings.issuanceDate: flexiblehttp.IssuanceDateNow,
+// the default, or flexiblehttp.IssuanceDateOriginal. Some verifiers read the
+// issuance date as the date the subject was verified, which a refresh
+// doesn't change.
+func WithIssuanceDate(mode string) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.issuanceDate = mode
+	}
+}
+
+// ValidateIssuanceDate checks an issuance date for WithIssuanceDate. The
+// provider supplied date is configured per credential type, since it needs a
+// field of the provider.
+func ValidateIssuanceDate(mode string) error {
+	switch mode {
+	case "", flexiblehttp.IssuanceDateNow, flexiblehttp.IssuanceDateOriginal:
+		return nil
+	default:
+		return errors.Errorf("issuance date '%s' is not '%s' or '%s'",
+			mode, flexiblehttp.IssuanceDateNow, flexiblehttp.IssuanceDateOriginal)
+	}
+}
+
+// takeIssuanceDate takes the provider supplied issuance date out of fields.
+// It is nil when the provider doesn't supply one or its value is null.
+func takeIssuanceDate(mode, field string, fields map[string]interface{}) (*time.Time, error) {
+	if mode != flexiblehttp.IssuanceDateProvider || field == "" {
+		return nil, nil
+	}
+	value, ok := fields[field]
+	delete(fields, field)
+	if !ok || value == nil {
+		return nil, nil
+	}
+	var issuedAt time.Time
+	switch v := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.Wrapf(flexiblehttp.ErrInvalidResponseSchema,
+				"issuance date field '%s' is not an RFC 3339 time: '%s'", field, v)
+		}
+		issuedAt = t
+	case float64:
+		issuedAt = time.Unix(int64(v), 0)
+	case int64:
+		issuedAt = time.Unix(v, 0)
+	case int:
+		issuedAt = time.Unix(int64(v), 0)
+	case json.Number:
+		seconds, err := v.Int64()
+		if err != nil {
+			return nil, errors.Wrapf(flexiblehttp.ErrInvalidResponseSchema,
+				"issuance date field '%s' is not Unix seconds: '%s'", field, v)
+		}
+		issuedAt = time.Unix(seconds, 0)
+	default:
+		return nil, errors.Wrapf(flexiblehttp.ErrInvalidResponseSchema,
+			"issuance date field '%s' is neither an RFC 3339 time nor Unix seconds", field)
+	}
+	issuedAt = issuedAt.UTC().Truncate(time.Second)
+	return &issuedAt, nil
+}
+
+// reissuedIssuanceDate returns the issuance date of the credential reissued
+// at now from credential. mode is settings.issuanceDate of its provider and
+// provided the date the provider supplied. Without a provider supplied date
+// the original one is kept, e.g. on renewals.
+func (rs *RefreshService) reissuedIssuanceDate(
+	mode string,
+	credential *verifiable.W3CCredential,
+	provided *time.Time,
+	now time.Time,
+) (time.Time, error) {
+	if mode == "" {
+		mode = rs.issuanceDate
+	}
+	switch mode {
+	case flexiblehttp.IssuanceDateProvider:
+		if provided != nil {
+			return *provided, nil
+		}
+		if credential.IssuanceDate != nil {
+			return credential.IssuanceDate.UTC(), nil
+		}
+		return now, nil
+	case flexiblehttp.IssuanceDateOriginal:
+		if credential.IssuanceDate == nil {
+			return time.Time{}, errors.New("credential has no issuance date to keep")
+		}
+		return credential.IssuanceDate.UTC(), nil
+	default:
+		return now, nil
+	}
+}
+
+// checkIssuanceDate warns when the issuer node didn't issue refreshed at
+// the requested issuance date, as issuer nodes which don't support setting
+// it do.
+func checkIssuanceDate(issuer string, requested *time.Time, refreshed *verifiable.W3CCredential) {
+	if requested == nil || refreshed == nil {
+		return
+	}
+	if refreshed.IssuanceDate == nil || !refreshed.IssuanceDate.Equal(*requested) {
+		logger.SampledWarnf("issuer '%s' didn't issue credential '%s' at the requested issuance date %s",
+			issuer, refreshed.ID, requested.Format(time.RFC3339))
+	}
+}
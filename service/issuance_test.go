@@ -0,0 +1,102 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTakeIssuanceDate(t *testing.T) {
+	verifiedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		mode           string
+		value          interface{}
+		expected       *time.Time
+		expectedErr    bool
+		expectedFields map[string]interface{}
+	}{
+		{name: "RFC 3339", mode: flexiblehttp.IssuanceDateProvider, value: "2024-03-01T14:00:00+02:00", expected: &verifiedAt},
+		{name: "Unix seconds", mode: flexiblehttp.IssuanceDateProvider, value: float64(verifiedAt.Unix()), expected: &verifiedAt},
+		{name: "JSON number", mode: flexiblehttp.IssuanceDateProvider, value: json.Number("1709294400"), expected: &verifiedAt},
+		{name: "Null", mode: flexiblehttp.IssuanceDateProvider},
+		{name: "Invalid", mode: flexiblehttp.IssuanceDateProvider, value: "March 1st", expectedErr: true},
+		{
+			name:           "Not provider supplied",
+			mode:           flexiblehttp.IssuanceDateOriginal,
+			value:          "2024-03-01T12:00:00Z",
+			expectedFields: map[string]interface{}{"balance": "10", "verifiedAt": "2024-03-01T12:00:00Z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := map[string]interface{}{"balance": "10", "verifiedAt": tt.value}
+			issuedAt, err := takeIssuanceDate(tt.mode, "verifiedAt", fields)
+			if tt.expectedErr {
+				require.ErrorIs(t, err, flexiblehttp.ErrInvalidResponseSchema)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, issuedAt)
+			if tt.expectedFields == nil {
+				tt.expectedFields = map[string]interface{}{"balance": "10"}
+			}
+			require.Equal(t, tt.expectedFields, fields)
+		})
+	}
+}
+
+func TestReissuedIssuanceDate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	original := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	provided := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	withIssuance := &verifiable.W3CCredential{IssuanceDate: &original}
+	withoutIssuance := &verifiable.W3CCredential{}
+
+	tests := []struct {
+		name        string
+		defaultMode string
+		mode        string
+		credential  *verifiable.W3CCredential
+		provided    *time.Time
+		expected    time.Time
+		expectedErr bool
+	}{
+		{name: "Default", credential: withIssuance, expected: now},
+		{name: "Original by default", defaultMode: flexiblehttp.IssuanceDateOriginal, credential: withIssuance, expected: original},
+		{
+			name:        "Type setting over the default",
+			defaultMode: flexiblehttp.IssuanceDateOriginal,
+			mode:        flexiblehttp.IssuanceDateNow,
+			credential:  withIssuance,
+			expected:    now,
+		},
+		{name: "Original", mode: flexiblehttp.IssuanceDateOriginal, credential: withIssuance, expected: original},
+		{name: "Original without issuance date", mode: flexiblehttp.IssuanceDateOriginal, credential: withoutIssuance, expectedErr: true},
+		{name: "Provider supplied", mode: flexiblehttp.IssuanceDateProvider, credential: withIssuance, provided: &provided, expected: provided},
+		{name: "Provider without date", mode: flexiblehttp.IssuanceDateProvider, credential: withIssuance, expected: original},
+		{name: "Provider without any date", mode: flexiblehttp.IssuanceDateProvider, credential: withoutIssuance, expected: now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{}, WithIssuanceDate(tt.defaultMode))
+			issuedAt, err := rs.reissuedIssuanceDate(tt.mode, tt.credential, tt.provided, now)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, issuedAt)
+		})
+	}
+}
+
+func TestValidateIssuanceDate(t *testing.T) {
+	require.NoError(t, ValidateIssuanceDate(""))
+	require.NoError(t, ValidateIssuanceDate(flexiblehttp.IssuanceDateOriginal))
+	require.Error(t, ValidateIssuanceDate(flexiblehttp.IssuanceDateProvider))
+}
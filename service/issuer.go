@@ -2,14 +2,17 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"strings"
 
+	"github.com/0xPolygonID/refresh-service/codec"
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/0xPolygonID/refresh-service/httpclient"
 	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/secrets"
 	"github.com/iden3/go-schema-processor/v2/verifiable"
 	"github.com/pkg/errors"
 )
@@ -24,33 +27,53 @@ type IssuerService struct {
 	supportedIssuers map[string]string
 	issuerBasicAuth  map[string]string
 	do               http.Client
+	clients          map[string]*http.Client
+	secrets          *secrets.Store
+	nativeRefresh    nativeRefresh
+	failover         failover
+	rateLimits       rateLimits
+	headers          issuerHeaders
+	identifiers      map[string]string
+	maxResponseBytes int64
 }
 
 func NewIssuerService(
 	supportedIssuers map[string]string,
 	issuerBasicAuth map[string]string,
 	client *http.Client,
+	opts ...IssuerOption,
 ) *IssuerService {
 	if client == nil {
 		client = http.DefaultClient
 	}
-	return &IssuerService{
+	is := &IssuerService{
 		supportedIssuers: supportedIssuers,
 		issuerBasicAuth:  issuerBasicAuth,
 		do:               *client,
+		clients:          make(map[string]*http.Client),
+		maxResponseBytes: defaultMaxResponseBytes,
 	}
+	for _, opt := range opts {
+		opt(is)
+	}
+	return is
 }
 
-func (is *IssuerService) GetClaimByID(issuerDID, claimID string) (*verifiable.W3CCredential, error) {
+func (is *IssuerService) GetClaimByID(ctx context.Context, issuerDID, claimID string) (*verifiable.W3CCredential, error) {
 	issuerNode, err := is.getIssuerURL(issuerDID)
 	if err != nil {
 		return nil, err
 	}
 	logger.DefaultLogger.Infof("use issuer node '%s' for issuer '%s'", issuerNode, issuerDID)
+	credentialURL, err := is.credentialURL(issuerNode, issuerDID, claimID)
+	if err != nil {
+		return nil, err
+	}
 
-	getRequest, err := http.NewRequest(
+	getRequest, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodGet,
-		fmt.Sprintf("%s/v2/identities/%s/credentials/%s", issuerNode, issuerDID, claimID),
+		credentialURL,
 		http.NoBody,
 	)
 	if err != nil {
@@ -60,39 +83,46 @@ func (is *IssuerService) GetClaimByID(issuerDID, claimID string) (*verifiable.W3
 	if err := is.setBasicAuth(issuerDID, getRequest); err != nil {
 		return nil, err
 	}
+	correlation.SetHeader(ctx, getRequest)
 
-	resp, err := is.do.Do(getRequest)
+	resp, err := is.send(issuerDID, getRequest)
 	if err != nil {
 		return nil, errors.Wrapf(ErrGetClaim,
 			"failed http GET request: '%v'", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Wrapf(ErrGetClaim,
-			"invalid status code: '%d'", resp.StatusCode)
+		return nil, httpclient.Throttle(resp, errors.Wrapf(ErrGetClaim,
+			"invalid status code: '%d'", resp.StatusCode))
 	}
 
-	rawBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrapf(ErrGetClaim, "failed to read response body: '%v'", err)
+	// the response is decoded as it is read, without buffering it first
+	body := is.responseBody(resp)
+	var rawBody bytes.Buffer
+	if logger.DebugEnabled() {
+		body = io.TeeReader(body, &rawBody)
+	}
+	var response map[string]json.RawMessage
+	if err := codec.Decode(body, &response); err != nil {
+		return nil, errors.Wrapf(ErrGetClaim,
+			"failed to decode response: '%v'", readError(err))
+	}
+	if logger.DebugEnabled() {
+		logger.DefaultLogger.Debugf("📡 Raw response from issuer node (%s):\n%s", getRequest.URL.String(), rawBody.String())
 	}
-	log.Printf("📡 Raw response from issuer node (%s):\n%s", getRequest.URL.String(), string(rawBody))
-
-	resp.Body = io.NopCloser(bytes.NewBuffer(rawBody))
 
-	var response struct {
-		VC verifiable.W3CCredential `json:"vc"`
+	if err := checkPayload(newPayloadObject(response), credentialPayload, ErrGetClaim); err != nil {
+		return nil, err
 	}
-	err = json.NewDecoder(resp.Body).Decode(&response)
+	vc, err := parseCredential(response["vc"])
 	if err != nil {
 		return nil, errors.Wrapf(ErrGetClaim,
 			"failed to decode response: '%v'", err)
 	}
-	log.Printf("✅ Parsed VC: %+v\n", response.VC)
-	return &response.VC, nil
+	return vc, nil
 }
 
-func (is *IssuerService) CreateCredential(issuerDID string, credentialRequest credentialRequest) (
+func (is *IssuerService) CreateCredential(ctx context.Context, issuerDID string, credentialRequest credentialRequest) (
 	id string,
 	err error,
 ) {
@@ -101,17 +131,22 @@ func (is *IssuerService) CreateCredential(issuerDID string, credentialRequest cr
 		return id, err
 	}
 	logger.DefaultLogger.Infof("use issuer node '%s' for issuer '%s'", issuerNode, issuerDID)
+	identityURL, err := is.identityURL(issuerNode, issuerDID)
+	if err != nil {
+		return id, err
+	}
 
 	body := bytes.NewBuffer([]byte{})
-	err = json.NewEncoder(body).Encode(credentialRequest)
+	err = codec.Encode(body, credentialRequest)
 	if err != nil {
 		return id, errors.Wrapf(ErrCreateClaim,
 			"credential request serialization error")
 	}
 
-	postRequest, err := http.NewRequest(
+	postRequest, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
-		fmt.Sprintf("%s/v2/identities/%s/credentials", issuerNode, issuerDID),
+		identityURL+"/credentials",
 		body,
 	)
 	if err != nil {
@@ -121,21 +156,29 @@ func (is *IssuerService) CreateCredential(issuerDID string, credentialRequest cr
 	if err := is.setBasicAuth(issuerDID, postRequest); err != nil {
 		return id, err
 	}
+	correlation.SetHeader(ctx, postRequest)
 
-	resp, err := is.do.Do(postRequest)
+	resp, err := is.send(issuerDID, postRequest)
 	if err != nil {
 		return id, errors.Wrapf(ErrCreateClaim,
 			"failed http POST request: %v", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusCreated {
-		return id, errors.Wrap(ErrCreateClaim,
-			"invalid status code")
+		return id, httpclient.Throttle(resp, errors.Wrapf(ErrCreateClaim,
+			"invalid status code: '%d'", resp.StatusCode))
+	}
+	rawBody, err := io.ReadAll(is.responseBody(resp))
+	if err != nil {
+		return id, errors.Wrapf(ErrCreateClaim, "failed to read response body: '%v'", readError(err))
+	}
+	if err := validatePayload(rawBody, createdPayload, ErrCreateClaim); err != nil {
+		return id, err
 	}
 	responseBody := struct {
 		ID string `json:"id"`
 	}{}
-	err = json.NewDecoder(resp.Body).Decode(&responseBody)
+	err = codec.Unmarshal(rawBody, &responseBody)
 	if err != nil {
 		return id, errors.Wrapf(ErrCreateClaim,
 			"failed to decode response: %v", err)
@@ -144,35 +187,67 @@ func (is *IssuerService) CreateCredential(issuerDID string, credentialRequest cr
 }
 
 func (is *IssuerService) getIssuerURL(issuerDID string) (string, error) {
-	url, ok := is.supportedIssuers[issuerDID]
+	key, ok := is.issuerKey(issuerDID)
 	if !ok {
-		url, ok = is.supportedIssuers["*"]
-		if !ok {
-			return "", errors.Wrapf(ErrIssuerNotSupported, "id '%s'", issuerDID)
-		}
+		return "", errors.Wrapf(ErrIssuerNotSupported, "id '%s'", issuerDID)
 	}
-	return url, nil
+	active, _ := is.activeNode(key)
+	return active, nil
 }
 
 func (is *IssuerService) setBasicAuth(issuerDID string, request *http.Request) error {
-	if is.issuerBasicAuth == nil {
+	if is.issuerBasicAuth == nil && is.secrets == nil {
 		return nil
 	}
-	namepass, ok := is.issuerBasicAuth[issuerDID]
+	namepass, ok := is.basicAuth(issuerDID, false)
 	if !ok {
-		globalNamepass, ok := is.issuerBasicAuth["*"]
-		if !ok {
-			logger.DefaultLogger.Warnf("issuer '%s' not found in basic auth map", issuerDID)
-			return nil
-		}
-		namepass = globalNamepass
+		logger.DefaultLogger.Warnf("issuer '%s' not found in basic auth map", issuerDID)
+		return nil
+	}
+	if err := applyBasicAuth(namepass, request); err != nil {
+		return errors.Errorf("invalid basic auth for issuer '%s'", issuerDID)
 	}
+	return nil
+}
+
+// IssuerNodes returns configured issuer DIDs with their node URLs.
+func (is *IssuerService) IssuerNodes() map[string]string {
+	nodes := make(map[string]string, len(is.supportedIssuers))
+	for k, v := range is.supportedIssuers {
+		nodes[k] = v
+	}
+	return nodes
+}
 
-	namepassPair := strings.Split(namepass, ":")
-	if len(namepassPair) != 2 {
-		return errors.Errorf("invalid basic auth: %q", namepass)
+// Ping checks that the issuer node serving issuerDID responds on its status endpoint.
+func (is *IssuerService) Ping(ctx context.Context, issuerDID string) error {
+	issuerNode, err := is.getIssuerURL(issuerDID)
+	if err != nil {
+		return err
 	}
+	return is.pingNode(ctx, issuerDID, issuerNode)
+}
 
-	request.SetBasicAuth(namepassPair[0], namepassPair[1])
+func (is *IssuerService) pingNode(ctx context.Context, issuerDID, issuerNode string) error {
+	statusRequest, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("%s/status", issuerNode),
+		http.NoBody,
+	)
+	if err != nil {
+		return errors.Errorf("failed to create http request: '%v'", err)
+	}
+	if err := is.setBasicAuth(issuerDID, statusRequest); err != nil {
+		return err
+	}
+	resp, err := is.send(issuerDID, statusRequest)
+	if err != nil {
+		return errors.Errorf("failed http GET request: '%v'", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("invalid status code: '%d'", resp.StatusCode)
+	}
 	return nil
 }
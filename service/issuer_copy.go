@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// CopyCredential creates a new credential of the issuer with the schema,
+// type, subject, proofs and core claim layout of credential, expiring at
+// expiration. The issuer node picks its revocation nonce, so both
+// credentials can be revoked on their own.
+func (is *IssuerService) CopyCredential(
+	ctx context.Context,
+	issuerDID string,
+	credential *verifiable.W3CCredential,
+	expiration time.Time,
+) (string, error) {
+	if credential.CredentialSchema.ID == "" {
+		return "", errors.Wrap(ErrCreateClaim, "credential schema ID is empty")
+	}
+	subjectType, ok := credential.CredentialSubject["type"].(string)
+	if !ok || subjectType == "" {
+		return "", errors.Wrap(ErrCreateClaim, "invalid or missing type in credentialSubject")
+	}
+	request := credentialRequest{
+		CredentialSchema:  credential.CredentialSchema.ID,
+		Type:              subjectType,
+		CredentialSubject: credential.CredentialSubject,
+		Expiration:        expiration.Unix(),
+		DisplayMethod:     credential.DisplayMethod,
+	}
+	request.SignatureProof, request.MTProof = proofPreferences(credential)
+	request.MerklizedRootPosition, request.SubjectPosition = claimLayout(credential)
+	if isVCDM2(credential) {
+		issuedAt := time.Now().UTC().Truncate(time.Second)
+		request.ValidFrom = &issuedAt
+		request.ValidUntil = &expiration
+	}
+	return is.CreateCredential(ctx, issuerDID, request)
+}
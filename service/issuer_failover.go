@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/pkg/errors"
+)
+
+// failover switches issuers between their primary and secondary nodes.
+type failover struct {
+	secondary map[string]string
+	// onSecondary holds the issuer keys currently served by the secondary
+	onSecondary sync.Map
+}
+
+// WithSecondaryNodes configures standby issuer nodes, keyed like the
+// supported issuers. Requests failing to connect to the active node are
+// retried on the other one, which stays active until the primary is
+// healthy again, see RecoverPrimaryNodes.
+func WithSecondaryNodes(nodes map[string]string) IssuerOption {
+	return func(is *IssuerService) {
+		is.failover.secondary = make(map[string]string, len(nodes))
+		for key, node := range nodes {
+			is.failover.secondary[key] = strings.TrimSuffix(node, "/")
+		}
+	}
+}
+
+// issuerKey returns the supported issuers key serving issuerDID.
+func (is *IssuerService) issuerKey(issuerDID string) (string, bool) {
+	if _, ok := is.supportedIssuers[issuerDID]; ok {
+		return issuerDID, true
+	}
+	_, ok := is.supportedIssuers["*"]
+	return "*", ok
+}
+
+// activeNode returns the node serving the issuer key and the other node
+// to fail over to, if there is one.
+func (is *IssuerService) activeNode(key string) (active, other string) {
+	primary, secondary := is.supportedIssuers[key], is.failover.secondary[key]
+	if _, ok := is.failover.onSecondary.Load(key); ok && secondary != "" {
+		return secondary, primary
+	}
+	return primary, secondary
+}
+
+// failoverRequest returns request retargeted to the other node of the
+// issuer when it was sent to the active one, which becomes inactive.
+func (is *IssuerService) failoverRequest(issuerDID string, request *http.Request) (*http.Request, bool) {
+	key, ok := is.issuerKey(issuerDID)
+	if !ok || is.failover.secondary[key] == "" {
+		return nil, false
+	}
+	sent := request.URL.String()
+	active, other := is.activeNode(key)
+	var target, path string
+	switch {
+	case strings.HasPrefix(sent, active+"/"):
+		// the active node is down, switch for all following requests
+		target, path = other, sent[len(active):]
+		if target == is.failover.secondary[key] {
+			is.failover.onSecondary.Store(key, struct{}{})
+		} else {
+			is.failover.onSecondary.Delete(key)
+		}
+		logger.DefaultLogger.Warnf("issuer node '%s' of '%s' is unreachable, failing over to '%s'", active, key, target)
+	case strings.HasPrefix(sent, other+"/"):
+		// another request has already failed over
+		target, path = active, sent[len(other):]
+	default:
+		return nil, false
+	}
+
+	retargeted, err := url.Parse(target + path)
+	if err != nil {
+		return nil, false
+	}
+	retry := request.Clone(request.Context())
+	retry.URL, retry.Host = retargeted, ""
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		retry.Body = body
+	}
+	return retry, true
+}
+
+// canFailOver reports whether request can be sent again to the other node
+// after err. Only idempotent requests are retried after they may have
+// reached the node, e.g. a response timeout of a POST /credentials could
+// otherwise issue the credential twice.
+func canFailOver(request *http.Request, err error) bool {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	}
+	return connectionFailed(err)
+}
+
+// connectionFailed reports whether err happened before the request could be
+// sent: dialing, resolving the host or the TLS handshake.
+func connectionFailed(err error) bool {
+	var (
+		opErr          *net.OpError
+		dnsErr         *net.DNSError
+		recordErr      tls.RecordHeaderError
+		verifyErr      *tls.CertificateVerificationError
+		unknownAuthErr x509.UnknownAuthorityError
+		hostnameErr    x509.HostnameError
+		invalidErr     x509.CertificateInvalidError
+	)
+	switch {
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return true
+	case errors.As(err, &dnsErr), errors.As(err, &recordErr), errors.As(err, &verifyErr),
+		errors.As(err, &unknownAuthErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return true
+	}
+	return false
+}
+
+// RecoverPrimaryNodes checks the primary nodes of failed-over issuers every
+// interval and switches back to those which are healthy.
+func (is *IssuerService) RecoverPrimaryNodes(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		is.failover.onSecondary.Range(func(k, _ interface{}) bool {
+			key, _ := k.(string)
+			primary := is.supportedIssuers[key]
+			if err := is.pingNode(ctx, key, primary); err != nil {
+				return true
+			}
+			is.failover.onSecondary.Delete(key)
+			logger.DefaultLogger.Infof("issuer node '%s' of '%s' recovered, switching back", primary, key)
+			return true
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// issuerHeaders are the static headers sent to issuer nodes.
+type issuerHeaders struct {
+	userAgent string
+	headers   map[string]http.Header
+}
+
+// WithUserAgent sets the User-Agent of issuer node requests.
+func WithUserAgent(userAgent string) IssuerOption {
+	return func(is *IssuerService) {
+		is.headers.userAgent = userAgent
+	}
+}
+
+// WithHeaders adds static headers, such as tenant ids or routing hints, to
+// issuer node requests. Headers are set per issuer DID, '*' for all other
+// issuers, and may override the User-Agent.
+func WithHeaders(headers map[string]http.Header) IssuerOption {
+	return func(is *IssuerService) {
+		is.headers.headers = headers
+	}
+}
+
+// ParseHeaders parses headers in the 'Name:value,Name:value' format.
+func ParseHeaders(value string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, headerValue, ok := strings.Cut(pair, ":")
+		name, headerValue = strings.TrimSpace(name), strings.TrimSpace(headerValue)
+		if !ok || name == "" || strings.ContainsAny(name, " \t\r\n") {
+			return nil, errors.Errorf("invalid header '%s'", pair)
+		}
+		if strings.ContainsAny(headerValue, "\r\n") {
+			return nil, errors.Errorf("invalid value of header '%s'", name)
+		}
+		headers.Add(textproto.CanonicalMIMEHeaderKey(name), headerValue)
+	}
+	return headers, nil
+}
+
+func (is *IssuerService) setHeaders(issuerDID string, request *http.Request) {
+	if is.headers.userAgent != "" {
+		request.Header.Set("User-Agent", is.headers.userAgent)
+	}
+	headers, ok := is.headers.headers[issuerDID]
+	if !ok {
+		headers = is.headers.headers["*"]
+	}
+	for name, values := range headers {
+		request.Header[name] = append([]string(nil), values...)
+	}
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/0xPolygonID/refresh-service/codec"
+	"github.com/pkg/errors"
+)
+
+// UnexpectedPayloadError is returned when an issuer node answers with a
+// body missing required fields or holding fields of the wrong type. It
+// wraps the error of the failed call and lists the offending field paths.
+type UnexpectedPayloadError struct {
+	Err    error
+	Fields []string
+}
+
+func (e *UnexpectedPayloadError) Error() string {
+	return fmt.Sprintf("issuer node returned unexpected payload: %s: %v",
+		strings.Join(e.Fields, "; "), e.Err)
+}
+
+func (e *UnexpectedPayloadError) Unwrap() error {
+	return e.Err
+}
+
+// JSON kinds of payload fields.
+const (
+	kindObject  = "object"
+	kindArray   = "array"
+	kindString  = "string"
+	kindNumber  = "number"
+	kindBoolean = "boolean"
+	kindNull    = "null"
+)
+
+// payloadField is a field an issuer node response is checked for. Paths
+// are dot separated, a trailing '[]' checks every element of an array.
+type payloadField struct {
+	path     string
+	kinds    []string
+	required bool
+}
+
+var (
+	// credentialPayload is the shape of GET .../credentials/{id}, limited
+	// to the fields the refresh relies on.
+	credentialPayload = []payloadField{
+		{path: "vc", kinds: []string{kindObject}, required: true},
+		{path: "vc.id", kinds: []string{kindString}, required: true},
+		{path: "vc.@context", kinds: []string{kindArray}},
+		{path: "vc.@context[]", kinds: []string{kindString}},
+		{path: "vc.type", kinds: []string{kindArray}, required: true},
+		{path: "vc.type[]", kinds: []string{kindString}},
+		{path: "vc.issuer", kinds: []string{kindString}, required: true},
+		{path: "vc.credentialSubject", kinds: []string{kindObject}, required: true},
+		{path: "vc.credentialSchema", kinds: []string{kindObject}},
+		{path: "vc.credentialSchema.id", kinds: []string{kindString}},
+		{path: "vc.credentialStatus", kinds: []string{kindObject, kindArray}},
+		{path: "vc.issuanceDate", kinds: []string{kindString}},
+		{path: "vc.expirationDate", kinds: []string{kindString}},
+		{path: "vc.validFrom", kinds: []string{kindString}},
+		{path: "vc.validUntil", kinds: []string{kindString}},
+		{path: "vc.refreshService", kinds: []string{kindObject}},
+		{path: "vc.displayMethod", kinds: []string{kindObject}},
+		{path: "vc.proof", kinds: []string{kindObject, kindArray}},
+	}
+	// createdPayload is the shape of POST .../credentials.
+	createdPayload = []payloadField{
+		{path: "id", kinds: []string{kindString}, required: true},
+	}
+	// refreshedPayload is the shape of POST .../credentials/{id}/refresh,
+	// which may leave out the id it keeps.
+	refreshedPayload = []payloadField{
+		{path: "id", kinds: []string{kindString}},
+	}
+)
+
+// validatePayload checks raw against fields. Failures wrap sentinel, the
+// error of the calling request.
+func validatePayload(raw []byte, fields []payloadField, sentinel error) error {
+	if !json.Valid(raw) {
+		var payload interface{}
+		err := codec.Unmarshal(raw, &payload)
+		return errors.Wrapf(sentinel, "failed to decode response: %v", err)
+	}
+	return checkPayload(newPayloadNode(raw), fields, sentinel)
+}
+
+// checkPayload checks a decoded payload against fields.
+func checkPayload(payload *payloadNode, fields []payloadField, sentinel error) error {
+	var problems []string
+	for _, field := range fields {
+		problems = append(problems, field.check(payload)...)
+	}
+	if len(problems) > 0 {
+		return &UnexpectedPayloadError{Err: sentinel, Fields: problems}
+	}
+	return nil
+}
+
+func (f payloadField) check(payload *payloadNode) []string {
+	path, each := strings.CutSuffix(f.path, "[]")
+	value, ok, reachable := lookupPayload(payload, path)
+	if !reachable {
+		// the parent is reported by its own field
+		return nil
+	}
+	if !ok || value.kind() == kindNull {
+		if f.required && !each {
+			return []string{fmt.Sprintf("%s: missing", path)}
+		}
+		return nil
+	}
+	if !each {
+		if kind := value.kind(); !f.accepts(kind) {
+			return []string{f.mismatch(path, kind)}
+		}
+		return nil
+	}
+	list := value.elements()
+	if list == nil {
+		// the array itself is checked by its own field
+		return nil
+	}
+	var problems []string
+	for i, element := range list {
+		if kind := element.kind(); !f.accepts(kind) {
+			problems = append(problems, f.mismatch(fmt.Sprintf("%s[%d]", path, i), kind))
+		}
+	}
+	return problems
+}
+
+func (f payloadField) accepts(kind string) bool {
+	for _, k := range f.kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (f payloadField) mismatch(path, kind string) string {
+	return fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(f.kinds, " or "), kind)
+}
+
+// lookupPayload returns the value at path and whether it is set. Paths
+// below a field which is missing or not an object are not reachable.
+func lookupPayload(payload *payloadNode, path string) (value *payloadNode, ok, reachable bool) {
+	keys := strings.Split(path, ".")
+	value = payload
+	for i, key := range keys {
+		object := value.fields()
+		if object == nil {
+			return nil, false, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false, i == len(keys)-1
+		}
+	}
+	return value, true, true
+}
+
+// payloadNode is a JSON value of a payload decoded one level at a time,
+// when a field below it is looked up. Subtrees no field reaches, such as
+// large merklized credential subjects, are never decoded into values.
+type payloadNode struct {
+	raw    json.RawMessage
+	object map[string]*payloadNode
+	array  []*payloadNode
+}
+
+func newPayloadNode(raw json.RawMessage) *payloadNode {
+	return &payloadNode{raw: raw}
+}
+
+// newPayloadObject is the node of an object whose fields are already
+// decoded.
+func newPayloadObject(fields map[string]json.RawMessage) *payloadNode {
+	node := &payloadNode{raw: json.RawMessage("{}"), object: make(map[string]*payloadNode, len(fields))}
+	for k, v := range fields {
+		node.object[k] = newPayloadNode(v)
+	}
+	return node
+}
+
+// fields returns the fields of an object, nil for other kinds.
+func (n *payloadNode) fields() map[string]*payloadNode {
+	if n.object != nil || n.kind() != kindObject {
+		return n.object
+	}
+	var fields map[string]json.RawMessage
+	if err := codec.Unmarshal(n.raw, &fields); err != nil {
+		return nil
+	}
+	n.object = make(map[string]*payloadNode, len(fields))
+	for k, v := range fields {
+		n.object[k] = newPayloadNode(v)
+	}
+	return n.object
+}
+
+// elements returns the elements of an array, nil for other kinds.
+func (n *payloadNode) elements() []*payloadNode {
+	if n.array != nil || n.kind() != kindArray {
+		return n.array
+	}
+	var elements []json.RawMessage
+	if err := codec.Unmarshal(n.raw, &elements); err != nil {
+		return nil
+	}
+	n.array = make([]*payloadNode, len(elements))
+	for i, v := range elements {
+		n.array[i] = newPayloadNode(v)
+	}
+	return n.array
+}
+
+// kind is read from the first byte of the value, which is enough for
+// valid JSON.
+func (n *payloadNode) kind() string {
+	raw := bytes.TrimLeft(n.raw, " \t\r\n")
+	if len(raw) == 0 {
+		return kindNull
+	}
+	switch raw[0] {
+	case '{':
+		return kindObject
+	case '[':
+		return kindArray
+	case '"':
+		return kindString
+	case 't', 'f':
+		return kindBoolean
+	case 'n':
+		return kindNull
+	}
+	return kindNumber
+}
+
+func payloadKind(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return kindObject
+	case []interface{}:
+		return kindArray
+	case string:
+		return kindString
+	case float64:
+		return kindNumber
+	case bool:
+		return kindBoolean
+	case nil:
+		return kindNull
+	}
+	return fmt.Sprintf("%T", value)
+}
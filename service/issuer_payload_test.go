@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		fields   []payloadField
+		expected []string
+	}{
+		{
+			name:    "Valid credential",
+			payload: issuedCredential,
+			fields:  credentialPayload,
+		},
+		{
+			name:     "No credential",
+			payload:  `{"error": "not found"}`,
+			fields:   credentialPayload,
+			expected: []string{"vc: missing"},
+		},
+		{
+			name: "Wrong types",
+			payload: `{"vc": {
+				"id": "urn:uuid:1",
+				"issuer": {"id": "did:iden3:issuer"},
+				"type": ["VerifiableCredential", 1],
+				"credentialSubject": null,
+				"credentialSchema": "https://example.com/schema.json"
+			}}`,
+			fields: credentialPayload,
+			expected: []string{
+				"vc.type[1]: expected string, got number",
+				"vc.issuer: expected string, got object",
+				"vc.credentialSubject: missing",
+				"vc.credentialSchema: expected object, got string",
+			},
+		},
+		{
+			name:     "Created without id",
+			payload:  `{"ID": "1"}`,
+			fields:   createdPayload,
+			expected: []string{"id: missing"},
+		},
+		{
+			name:    "Refreshed without id",
+			payload: `{}`,
+			fields:  refreshedPayload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePayload([]byte(tt.payload), tt.fields, ErrGetClaim)
+			if tt.expected == nil {
+				require.NoError(t, err)
+				return
+			}
+			var payloadErr *UnexpectedPayloadError
+			require.True(t, errors.As(err, &payloadErr))
+			require.Equal(t, tt.expected, payloadErr.Fields)
+			require.ErrorIs(t, err, ErrGetClaim)
+		})
+	}
+}
+
+func TestCreateCredential_UnexpectedPayload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 42}`))
+	}))
+	defer srv.Close()
+
+	is := NewIssuerService(map[string]string{"*": srv.URL}, nil, srv.Client())
+	_, err := is.CreateCredential(context.Background(), "did:iden3:issuer", credentialRequest{})
+	require.ErrorIs(t, err, ErrCreateClaim)
+	require.EqualError(t, err,
+		"issuer node returned unexpected payload: id: expected string, got number: failed to create claim")
+}
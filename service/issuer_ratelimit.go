@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit caps the requests sent to an issuer node, or to the data
+// providers of a tenant. Requests over the cap wait for their turn instead
+// of failing.
+type RateLimit struct {
+	PerSecond float64
+	// Burst is how many requests may be sent at once after the node was
+	// idle. It defaults to the rate rounded up.
+	Burst int
+}
+
+// ParseRateLimit parses a rate limit in the 'perSecond[:burst]' format,
+// e.g. '5' or '0.5:2'.
+func ParseRateLimit(value string) (RateLimit, error) {
+	perSecond, burst, hasBurst := strings.Cut(strings.TrimSpace(value), ":")
+	limit := RateLimit{}
+	var err error
+	limit.PerSecond, err = strconv.ParseFloat(perSecond, 64)
+	if err != nil || limit.PerSecond <= 0 || math.IsInf(limit.PerSecond, 0) {
+		return RateLimit{}, errors.Errorf("invalid rate limit '%s': requests per second must be a positive number", value)
+	}
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(burst)
+		if err != nil || limit.Burst <= 0 {
+			return RateLimit{}, errors.Errorf("invalid rate limit '%s': burst must be a positive integer", value)
+		}
+	}
+	return limit, nil
+}
+
+// Limiter returns a limiter enforcing the rate limit.
+func (l RateLimit) Limiter() *rate.Limiter {
+	burst := l.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(l.PerSecond))
+	}
+	return rate.NewLimiter(rate.Limit(l.PerSecond), burst)
+}
+
+// rateLimits holds one limiter per issuer node.
+type rateLimits struct {
+	limits   map[string]RateLimit
+	limiters sync.Map
+}
+
+// WithRateLimits caps the requests per second sent to issuer nodes, keyed
+// like the supported issuers. Issuers sharing the '*' node share its cap.
+func WithRateLimits(limits map[string]RateLimit) IssuerOption {
+	return func(is *IssuerService) {
+		is.rateLimits.limits = limits
+	}
+}
+
+func (is *IssuerService) limiter(issuerDID string) *rate.Limiter {
+	key, ok := is.issuerKey(issuerDID)
+	if !ok {
+		return nil
+	}
+	if limiter, ok := is.rateLimits.limiters.Load(key); ok {
+		return limiter.(*rate.Limiter)
+	}
+	limit, ok := is.rateLimits.limits[key]
+	if !ok {
+		limit, ok = is.rateLimits.limits["*"]
+	}
+	if !ok {
+		return nil
+	}
+	limiter, _ := is.rateLimits.limiters.LoadOrStore(key, limit.Limiter())
+	return limiter.(*rate.Limiter)
+}
+
+// waitTurn blocks until the issuer node of issuerDID may receive another
+// request. It fails only when ctx ends first.
+func (is *IssuerService) waitTurn(ctx context.Context, issuerDID string) error {
+	limiter := is.limiter(issuerDID)
+	if limiter == nil {
+		return nil
+	}
+	start := time.Now()
+	if err := limiter.Wait(ctx); err != nil {
+		return errors.Wrapf(err, "waiting for the rate limit of issuer '%s'", issuerDID)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		logger.SampledWarnf("issuer '%s' request waited %s for the rate limit", issuerDID, waited.Round(time.Millisecond))
+	}
+	return nil
+}
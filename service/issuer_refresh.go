@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/0xPolygonID/refresh-service/codec"
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/pkg/errors"
+)
+
+// Native refresh modes of an issuer node.
+const (
+	NativeRefreshOff  = "off"
+	NativeRefreshOn   = "on"
+	NativeRefreshAuto = "auto"
+)
+
+var ErrNativeRefreshNotSupported = errors.New("issuer node has no refresh endpoint")
+
+// nativeRefresh tracks which issuer nodes update credentials in place.
+type nativeRefresh struct {
+	modes       map[string]string
+	unsupported sync.Map
+}
+
+// WithNativeRefresh sets the native refresh mode per issuer DID, '*' for
+// all other issuers. Issuer nodes in 'on' mode update credentials through
+// their refresh endpoint, which keeps the credential id. In 'auto' mode the
+// endpoint is tried first and issuers without it fall back to creating a
+// new credential from then on. The default is 'off'.
+func WithNativeRefresh(modes map[string]string) IssuerOption {
+	return func(is *IssuerService) {
+		is.nativeRefresh.modes = modes
+	}
+}
+
+func (is *IssuerService) nativeRefreshMode(issuerDID string) string {
+	mode, ok := is.nativeRefresh.modes[issuerDID]
+	if !ok {
+		mode = is.nativeRefresh.modes["*"]
+	}
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == NativeRefreshAuto {
+		if _, unsupported := is.nativeRefresh.unsupported.Load(issuerDID); unsupported {
+			return NativeRefreshOff
+		}
+	}
+	if mode != NativeRefreshOn && mode != NativeRefreshAuto {
+		return NativeRefreshOff
+	}
+	return mode
+}
+
+// RefreshCredential updates the credential claimID in place and returns the
+// id of the updated credential.
+func (is *IssuerService) RefreshCredential(
+	ctx context.Context,
+	issuerDID, claimID string,
+	credentialRequest credentialRequest,
+) (string, error) {
+	issuerNode, err := is.getIssuerURL(issuerDID)
+	if err != nil {
+		return "", err
+	}
+	credentialURL, err := is.credentialURL(issuerNode, issuerDID, claimID)
+	if err != nil {
+		return "", err
+	}
+
+	body := bytes.NewBuffer([]byte{})
+	if err := codec.Encode(body, credentialRequest); err != nil {
+		return "", errors.Wrapf(ErrCreateClaim,
+			"credential request serialization error")
+	}
+	refreshRequest, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		credentialURL+"/refresh",
+		body,
+	)
+	if err != nil {
+		return "", errors.Wrapf(ErrCreateClaim,
+			"failed to create http request: '%v'", err)
+	}
+	if err := is.setBasicAuth(issuerDID, refreshRequest); err != nil {
+		return "", err
+	}
+	correlation.SetHeader(ctx, refreshRequest)
+
+	resp, err := is.send(issuerDID, refreshRequest)
+	if err != nil {
+		return "", errors.Wrapf(ErrCreateClaim,
+			"failed http POST request: %v", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return "", errors.Wrapf(ErrNativeRefreshNotSupported,
+			"issuer '%s' answered '%d'", issuerDID, resp.StatusCode)
+	default:
+		return "", httpclient.Throttle(resp, errors.Wrapf(ErrCreateClaim,
+			"invalid status code: '%d'", resp.StatusCode))
+	}
+
+	rawBody, err := io.ReadAll(is.responseBody(resp))
+	if err != nil {
+		return "", errors.Wrapf(ErrCreateClaim, "failed to read response body: '%v'", readError(err))
+	}
+	if err := validatePayload(rawBody, refreshedPayload, ErrCreateClaim); err != nil {
+		return "", err
+	}
+	responseBody := struct {
+		ID string `json:"id"`
+	}{}
+	if err := codec.Unmarshal(rawBody, &responseBody); err != nil {
+		return "", errors.Wrapf(ErrCreateClaim,
+			"failed to decode response: %v", err)
+	}
+	if responseBody.ID == "" {
+		return claimID, nil
+	}
+	return responseBody.ID, nil
+}
+
+// issue creates the refreshed credential, through the native refresh
+// endpoint of the issuer node when it has one.
+func (is *IssuerService) issue(
+	ctx context.Context,
+	issuerDID, claimID string,
+	credentialRequest credentialRequest,
+) (string, error) {
+	mode := is.nativeRefreshMode(issuerDID)
+	if mode == NativeRefreshOff {
+		return is.CreateCredential(ctx, issuerDID, credentialRequest)
+	}
+	id, err := is.RefreshCredential(ctx, issuerDID, claimID, credentialRequest)
+	if mode == NativeRefreshAuto && errors.Is(err, ErrNativeRefreshNotSupported) {
+		logger.DefaultLogger.Infof("issuer '%s' has no native refresh endpoint, creating new credentials instead", issuerDID)
+		is.nativeRefresh.unsupported.Store(issuerDID, struct{}{})
+		return is.CreateCredential(ctx, issuerDID, credentialRequest)
+	}
+	if errors.Is(err, ErrNativeRefreshNotSupported) {
+		return "", errors.Wrap(ErrCreateClaim, err.Error())
+	}
+	return id, err
+}
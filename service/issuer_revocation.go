@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/0xPolygonID/refresh-service/codec"
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrRevokeClaim      = errors.New("failed to revoke claim")
+	ErrRevocationStatus = errors.New("failed to get revocation status")
+)
+
+// RevocationNonce returns the revocation nonce of the credential status.
+func RevocationNonce(credential *verifiable.W3CCredential) (uint64, error) {
+	return extractRevocationNonce(credential)
+}
+
+// RevokeCredential revokes the credentials of the issuer with nonce. The
+// issuer node publishes the revocation with its next state.
+func (is *IssuerService) RevokeCredential(ctx context.Context, issuerDID string, nonce uint64) error {
+	request, err := is.revocationRequest(ctx, issuerDID, http.MethodPost, "/credentials/revoke/", nonce, ErrRevokeClaim)
+	if err != nil {
+		return err
+	}
+	resp, err := is.send(issuerDID, request)
+	if err != nil {
+		return errors.Wrapf(ErrRevokeClaim,
+			"failed http POST request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return httpclient.Throttle(resp, errors.Wrapf(ErrRevokeClaim,
+			"invalid status code: '%d'", resp.StatusCode))
+	}
+	return nil
+}
+
+// RevocationStatus tells whether nonce is revoked in the revocation tree of
+// the issuer.
+func (is *IssuerService) RevocationStatus(ctx context.Context, issuerDID string, nonce uint64) (bool, error) {
+	request, err := is.revocationRequest(ctx, issuerDID, http.MethodGet, "/credentials/revocation/status/", nonce,
+		ErrRevocationStatus)
+	if err != nil {
+		return false, err
+	}
+	resp, err := is.send(issuerDID, request)
+	if err != nil {
+		return false, errors.Wrapf(ErrRevocationStatus,
+			"failed http GET request: '%v'", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, httpclient.Throttle(resp, errors.Wrapf(ErrRevocationStatus,
+			"invalid status code: '%d'", resp.StatusCode))
+	}
+	// the nonce exists in the revocation tree when it is revoked
+	var status struct {
+		MTP *struct {
+			Existence bool `json:"existence"`
+		} `json:"mtp"`
+	}
+	if err := codec.Decode(is.responseBody(resp), &status); err != nil {
+		return false, errors.Wrapf(ErrRevocationStatus,
+			"failed to decode response: '%v'", readError(err))
+	}
+	if status.MTP == nil {
+		return false, errors.Wrap(ErrRevocationStatus, "response has no 'mtp' proof")
+	}
+	return status.MTP.Existence, nil
+}
+
+func (is *IssuerService) revocationRequest(
+	ctx context.Context,
+	issuerDID, method, path string,
+	nonce uint64,
+	errKind error,
+) (*http.Request, error) {
+	issuerNode, err := is.getIssuerURL(issuerDID)
+	if err != nil {
+		return nil, err
+	}
+	identityURL, err := is.identityURL(issuerNode, issuerDID)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(
+		ctx,
+		method,
+		identityURL+path+strconv.FormatUint(nonce, 10),
+		http.NoBody,
+	)
+	if err != nil {
+		return nil, errors.Wrapf(errKind,
+			"failed to create http request: '%v'", err)
+	}
+	if err := is.setBasicAuth(issuerDID, request); err != nil {
+		return nil, err
+	}
+	correlation.SetHeader(ctx, request)
+	return request, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/secrets"
+	"github.com/pkg/errors"
+)
+
+// IssuersBasicAuthSecret is the secret which holds issuer basic auth
+// credentials in the ISSUERS_BASIC_AUTH format: 'did1=user:pass;*=user:pass'.
+const IssuersBasicAuthSecret = "ISSUERS_BASIC_AUTH"
+
+// WithBasicAuthSecrets reads issuer basic auth from a rotatable secret store
+// instead of the static map. While a rotation window is open, requests
+// rejected with 401 are retried once with the previous credentials.
+func WithBasicAuthSecrets(store *secrets.Store) IssuerOption {
+	return func(is *IssuerService) {
+		is.secrets = store
+	}
+}
+
+func (is *IssuerService) basicAuth(issuerDID string, previous bool) (string, bool) {
+	auth := is.issuerBasicAuth
+	if is.secrets != nil {
+		get := is.secrets.Get
+		if previous {
+			get = is.secrets.Previous
+		}
+		value, ok := get(IssuersBasicAuthSecret)
+		if !ok {
+			return "", false
+		}
+		auth = parseBasicAuthSecret(value)
+	} else if previous {
+		return "", false
+	}
+
+	namepass, ok := auth[issuerDID]
+	if !ok {
+		namepass, ok = auth["*"]
+	}
+	return namepass, ok
+}
+
+func parseBasicAuthSecret(value string) map[string]string {
+	auth := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		auth[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return auth
+}
+
+func applyBasicAuth(namepass string, request *http.Request) error {
+	namepassPair := strings.Split(namepass, ":")
+	if len(namepassPair) != 2 {
+		return errors.New("invalid basic auth")
+	}
+	request.SetBasicAuth(namepassPair[0], namepassPair[1])
+	return nil
+}
+
+// send executes request with the static issuer headers once the rate
+// limit of the issuer node allows it, failing over to the other node of
+// the issuer when the node can't be reached, see canFailOver.
+func (is *IssuerService) send(issuerDID string, request *http.Request) (*http.Response, error) {
+	is.setHeaders(issuerDID, request)
+	if err := is.waitTurn(request.Context(), issuerDID); err != nil {
+		return nil, err
+	}
+	resp, err := is.sendAuthenticated(issuerDID, request)
+	if err == nil || request.Context().Err() != nil || !canFailOver(request, err) {
+		return resp, err
+	}
+	retry, ok := is.failoverRequest(issuerDID, request)
+	if !ok {
+		return resp, err
+	}
+	done, ok := httpclient.Retry(request.Context())
+	if !ok {
+		return resp, err
+	}
+	defer done()
+	return is.sendAuthenticated(issuerDID, retry)
+}
+
+// sendAuthenticated executes request and retries once with the previous
+// basic auth credentials when the issuer node rejects the current ones.
+func (is *IssuerService) sendAuthenticated(issuerDID string, request *http.Request) (*http.Response, error) {
+	resp, err := is.client(issuerDID).Do(request)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	previous, ok := is.basicAuth(issuerDID, true)
+	if !ok {
+		return resp, nil
+	}
+	done, ok := httpclient.Retry(request.Context())
+	if !ok {
+		return resp, nil
+	}
+	defer done()
+	retry := request.Clone(request.Context())
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	if err := applyBasicAuth(previous, retry); err != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+	logger.DefaultLogger.Infof("issuer '%s' rejected current credentials, retrying with previous ones", issuerDID)
+	return is.client(issuerDID).Do(retry)
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// defaultMaxResponseBytes bounds issuer node responses. Credentials with
+// large merklized payloads are hundreds of KB.
+const defaultMaxResponseBytes = 8 << 20
+
+// WithMaxResponseBytes bounds the size of issuer node responses. A larger
+// response fails the call instead of being buffered, so a misbehaving
+// issuer node can't exhaust the memory of the service.
+func WithMaxResponseBytes(limit int64) IssuerOption {
+	return func(is *IssuerService) {
+		if limit > 0 {
+			is.maxResponseBytes = limit
+		}
+	}
+}
+
+// responseBody returns the body of resp, failing reads past the response
+// limit.
+func (is *IssuerService) responseBody(resp *http.Response) io.Reader {
+	return http.MaxBytesReader(nil, resp.Body, is.maxResponseBytes)
+}
+
+// readError describes an error reading a response body, naming the limit
+// of an oversized one.
+func readError(err error) string {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Sprintf("response is larger than %d bytes", tooLarge.Limit)
+	}
+	return err.Error()
+}
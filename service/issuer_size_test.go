@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/stretchr/testify/require"
+)
+
+// largeCredential returns a credential response of about size bytes, most
+// of them in a merklized credential subject.
+func largeCredential(size int) []byte {
+	var subject strings.Builder
+	subject.WriteString(`{"id": "did:iden3:owner", "type": "Portfolio", "positions": [`)
+	for i := 0; subject.Len() < size; i++ {
+		if i > 0 {
+			subject.WriteString(",")
+		}
+		fmt.Fprintf(&subject, `{"asset": "0x%040x", "amount": %d, "tags": ["liquid", "verified"]}`, i, i*1000)
+	}
+	subject.WriteString("]}")
+	return []byte(`{"vc": {
+		"id": "urn:uuid:1",
+		"@context": ["https://www.w3.org/2018/credentials/v1"],
+		"issuer": "did:iden3:issuer",
+		"type": ["VerifiableCredential", "Portfolio"],
+		"credentialSubject": ` + subject.String() + `
+	}}`)
+}
+
+func newCredentialServer(body []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+}
+
+func TestGetClaimByID_ResponseSize(t *testing.T) {
+	body := largeCredential(256 << 10)
+	srv := newCredentialServer(body)
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		limit       int64
+		expectedErr string
+	}{
+		{name: "Default limit"},
+		{name: "Within limit", limit: int64(len(body))},
+		{name: "Over limit", limit: 64 << 10, expectedErr: "response is larger than 65536 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := NewIssuerService(map[string]string{"did:iden3:issuer": srv.URL}, nil, nil,
+				WithMaxResponseBytes(tt.limit))
+			vc, err := is.GetClaimByID(context.Background(), "did:iden3:issuer", "1")
+			if tt.expectedErr != "" {
+				require.ErrorIs(t, err, ErrGetClaim)
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "urn:uuid:1", vc.ID)
+			require.NotEmpty(t, vc.CredentialSubject["positions"])
+		})
+	}
+}
+
+// BenchmarkGetClaimByID reports the bytes allocated per byte of the
+// response as alloc/byte. It stays flat as credentials grow: a request
+// holds the decoded credential and a few copies of its JSON, no tree of
+// the whole response.
+func BenchmarkGetClaimByID(b *testing.B) {
+	// debug logging keeps a copy of every response
+	require.NoError(b, logger.SetLevel("warn"))
+	b.Cleanup(func() {
+		_ = logger.SetLevel("debug")
+	})
+	for _, size := range []int{16 << 10, 256 << 10, 1 << 20, 4 << 20} {
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			body := largeCredential(size)
+			srv := newCredentialServer(body)
+			defer srv.Close()
+			is := NewIssuerService(map[string]string{"did:iden3:issuer": srv.URL}, nil, nil)
+
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := is.GetClaimByID(context.Background(), "did:iden3:issuer", "1"); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(after.TotalAlloc-before.TotalAlloc)/float64(b.N)/float64(len(body)), "alloc/byte")
+		})
+	}
+}
+
+// BenchmarkValidatePayload checks a credential response without decoding
+// its subject.
+func BenchmarkValidatePayload(b *testing.B) {
+	body := largeCredential(1 << 20)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := validatePayload(body, credentialPayload, ErrGetClaim); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,492 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/secrets"
+	"github.com/stretchr/testify/require"
+)
+
+const issuedCredential = `{"vc": {
+	"id": "urn:uuid:1",
+	"issuer": "did:iden3:issuer",
+	"type": ["VerifiableCredential", "Balance"],
+	"credentialSubject": {"id": "did:iden3:owner", "type": "Balance", "balance": 1}
+}}`
+
+func newClientCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "refresh-service"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestGetClaimByID_ClientCertificate(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(issuedCredential))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	defer srv.Close()
+
+	serverRoots := srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	issuers := map[string]string{"did:mtls": srv.URL, "did:plain": srv.URL}
+	basicAuth := map[string]string{"*": "user:pass"}
+
+	is := NewIssuerService(issuers, basicAuth, srv.Client(), WithIssuerTLS(map[string]*tls.Config{
+		"did:mtls": {
+			MinVersion:   tls.VersionTLS12,
+			RootCAs:      serverRoots,
+			Certificates: []tls.Certificate{newClientCertificate(t)},
+		},
+	}))
+
+	vc, err := is.GetClaimByID(context.Background(), "did:mtls", "1")
+	require.NoError(t, err)
+	require.Equal(t, "urn:uuid:1", vc.ID)
+
+	_, err = is.GetClaimByID(context.Background(), "did:plain", "1")
+	require.ErrorIs(t, err, ErrGetClaim)
+}
+
+func TestGetClaimByID_RotatedBasicAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the issuer node has not picked up the new password yet
+		if _, pass, _ := r.BasicAuth(); pass != "old" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(issuedCredential))
+	}))
+	defer srv.Close()
+
+	store := secrets.NewStore(time.Minute)
+	store.Update(map[string]string{IssuersBasicAuthSecret: "*=user:old"})
+	store.Update(map[string]string{IssuersBasicAuthSecret: "*=user:new"})
+
+	is := NewIssuerService(
+		map[string]string{"*": srv.URL}, nil, srv.Client(),
+		WithBasicAuthSecrets(store),
+	)
+	vc, err := is.GetClaimByID(context.Background(), "did:iden3:issuer", "1")
+	require.NoError(t, err)
+	require.Equal(t, "urn:uuid:1", vc.ID)
+}
+
+func TestGetCredentialJWT(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		status      int
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:        "Issuer node produces JWT",
+			contentType: MediaTypeJWTVC,
+			status:      http.StatusOK,
+			expected:    "eyJhbGciOiJFUzI1NiJ9.eyJ2YyI6e319.c2ln",
+		},
+		{
+			name:        "Issuer node ignores Accept",
+			contentType: "application/json",
+			status:      http.StatusOK,
+			expectedErr: ErrJWTNotSupported,
+		},
+		{
+			name:        "Issuer node rejects Accept",
+			status:      http.StatusNotAcceptable,
+			expectedErr: ErrJWTNotSupported,
+		},
+		{
+			name:        "Issuer node fails",
+			status:      http.StatusInternalServerError,
+			expectedErr: ErrGetClaim,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, MediaTypeJWTVC, r.Header.Get("Accept"))
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte("eyJhbGciOiJFUzI1NiJ9.eyJ2YyI6e319.c2ln\n"))
+			}))
+			defer srv.Close()
+
+			is := NewIssuerService(map[string]string{"*": srv.URL}, nil, srv.Client())
+			jwt, err := is.GetCredentialJWT(context.Background(), "did:iden3:issuer", "1")
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, jwt)
+		})
+	}
+}
+
+func TestIssue_NativeRefresh(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		nativeStatus  int
+		expectedID    string
+		expectedPaths []string
+		expectedErr   error
+	}{
+		{
+			name:          "Off",
+			mode:          NativeRefreshOff,
+			expectedID:    "created",
+			expectedPaths: []string{"/v2/identities/did:iden3:issuer/credentials"},
+		},
+		{
+			name:          "Native endpoint keeps the id",
+			mode:          NativeRefreshOn,
+			nativeStatus:  http.StatusOK,
+			expectedID:    "1",
+			expectedPaths: []string{"/v2/identities/did:iden3:issuer/credentials/1/refresh"},
+		},
+		{
+			name:         "Auto falls back once",
+			mode:         NativeRefreshAuto,
+			nativeStatus: http.StatusNotFound,
+			expectedID:   "created",
+			expectedPaths: []string{
+				"/v2/identities/did:iden3:issuer/credentials/1/refresh",
+				"/v2/identities/did:iden3:issuer/credentials",
+				"/v2/identities/did:iden3:issuer/credentials",
+			},
+		},
+		{
+			name:          "On without endpoint",
+			mode:          NativeRefreshOn,
+			nativeStatus:  http.StatusNotFound,
+			expectedPaths: []string{"/v2/identities/did:iden3:issuer/credentials/1/refresh"},
+			expectedErr:   ErrCreateClaim,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.URL.Path)
+				if strings.HasSuffix(r.URL.Path, "/refresh") {
+					w.WriteHeader(tt.nativeStatus)
+					_, _ = w.Write([]byte(`{}`))
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"id": "created"}`))
+			}))
+			defer srv.Close()
+
+			is := NewIssuerService(map[string]string{"*": srv.URL}, nil, srv.Client(),
+				WithNativeRefresh(map[string]string{"*": tt.mode}))
+			id, err := is.issue(context.Background(), "did:iden3:issuer", "1", credentialRequest{})
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				require.Equal(t, tt.expectedPaths, paths)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedID, id)
+			if tt.mode == NativeRefreshAuto {
+				// the missing endpoint is remembered
+				_, err = is.issue(context.Background(), "did:iden3:issuer", "1", credentialRequest{})
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expectedPaths, paths)
+		})
+	}
+}
+
+func TestGetClaimByID_Failover(t *testing.T) {
+	var secondaryCalls int
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryCalls++
+		_, _ = w.Write([]byte(issuedCredential))
+	}))
+	defer secondary.Close()
+
+	var primaryCalls int
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryCalls++
+		_, _ = w.Write([]byte(issuedCredential))
+	}))
+	primaryURL := primary.URL
+	// nothing listens on the primary address until it recovers
+	primary.Listener.Close()
+	defer primary.Close()
+
+	is := NewIssuerService(map[string]string{"*": primaryURL}, nil, http.DefaultClient,
+		WithSecondaryNodes(map[string]string{"*": secondary.URL + "/"}))
+
+	for range 2 {
+		vc, err := is.GetClaimByID(context.Background(), "did:iden3:issuer", "1")
+		require.NoError(t, err)
+		require.Equal(t, "urn:uuid:1", vc.ID)
+	}
+	require.Equal(t, 2, secondaryCalls)
+	node, err := is.getIssuerURL("did:iden3:issuer")
+	require.NoError(t, err)
+	require.Equal(t, secondary.URL, node)
+
+	// the primary comes back on its address
+	restarted := httptest.NewUnstartedServer(primary.Config.Handler)
+	listener, err := net.Listen("tcp", strings.TrimPrefix(primaryURL, "http://"))
+	require.NoError(t, err)
+	restarted.Listener = listener
+	restarted.Start()
+	defer restarted.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go is.RecoverPrimaryNodes(ctx, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		node, _ := is.getIssuerURL("did:iden3:issuer")
+		return node == primaryURL
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+
+	_, err = is.GetClaimByID(context.Background(), "did:iden3:issuer", "1")
+	require.NoError(t, err)
+	require.Equal(t, 2, secondaryCalls)
+	require.Equal(t, 2, primaryCalls) // status check and credential
+}
+
+func TestCreateCredential_Failover(t *testing.T) {
+	tests := []struct {
+		name          string
+		primary       func(primary *httptest.Server)
+		expectedErr   bool
+		secondaryHits int
+	}{
+		{
+			name: "Primary unreachable",
+			primary: func(primary *httptest.Server) {
+				primary.Listener.Close()
+			},
+			secondaryHits: 1,
+		},
+		{
+			// the request reached the primary, which may have issued the
+			// credential
+			name:        "Connection lost after sending",
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var secondaryHits int
+			secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				secondaryHits++
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(`{"id": "urn:uuid:1"}`))
+			}))
+			defer secondary.Close()
+			primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				_ = conn.Close()
+			}))
+			defer primary.Close()
+			if tt.primary != nil {
+				tt.primary(primary)
+			}
+
+			is := NewIssuerService(map[string]string{"*": primary.URL}, nil, http.DefaultClient,
+				WithSecondaryNodes(map[string]string{"*": secondary.URL}))
+			_, err := is.CreateCredential(context.Background(), "did:iden3:issuer", credentialRequest{})
+			if tt.expectedErr {
+				require.ErrorIs(t, err, ErrCreateClaim)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.secondaryHits, secondaryHits)
+		})
+	}
+}
+
+func TestGetClaimByID_RetryBudget(t *testing.T) {
+	var secondaryCalls int
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryCalls++
+		_, _ = w.Write([]byte(issuedCredential))
+	}))
+	defer secondary.Close()
+
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	is := NewIssuerService(map[string]string{"*": primary.URL}, nil, http.DefaultClient,
+		WithSecondaryNodes(map[string]string{"*": secondary.URL}))
+
+	budget := httpclient.NewRetryBudget(1, 0)
+	ctx := httpclient.WithRetryBudget(context.Background(), budget)
+	// the failover takes the only retry of the budget
+	_, err := is.GetClaimByID(ctx, "did:iden3:issuer", "1")
+	require.NoError(t, err)
+	require.Equal(t, 1, secondaryCalls)
+
+	// the secondary fails too, the failover back to the primary is refused
+	secondary.Close()
+	_, err = is.GetClaimByID(ctx, "did:iden3:issuer", "1")
+	require.Error(t, err)
+	require.Equal(t, 1, budget.Rejected())
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected RateLimit
+		err      bool
+	}{
+		{name: "Rate", value: "5", expected: RateLimit{PerSecond: 5}},
+		{name: "Rate and burst", value: "0.5:2", expected: RateLimit{PerSecond: 0.5, Burst: 2}},
+		{name: "Zero rate", value: "0", err: true},
+		{name: "Invalid burst", value: "5:0", err: true},
+		{name: "Not a number", value: "fast", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := ParseRateLimit(tt.value)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, limit)
+		})
+	}
+}
+
+func TestGetClaimByID_RateLimit(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(issuedCredential))
+	}))
+	defer srv.Close()
+
+	is := NewIssuerService(map[string]string{"*": srv.URL}, nil, srv.Client(),
+		WithRateLimits(map[string]RateLimit{"*": {PerSecond: 20, Burst: 1}}))
+
+	start := time.Now()
+	for range 3 {
+		// different issuers share the '*' node
+		_, err := is.GetClaimByID(context.Background(), "did:iden3:issuer"+strconv.Itoa(calls), "1")
+		require.NoError(t, err)
+	}
+	require.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	// requests over the cap queue until their context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := is.GetClaimByID(ctx, "did:iden3:issuer", "1")
+	require.ErrorIs(t, err, ErrGetClaim)
+	require.Equal(t, 3, calls)
+}
+
+func TestGetClaimByID_Headers(t *testing.T) {
+	var received http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+		_, _ = w.Write([]byte(issuedCredential))
+	}))
+	defer srv.Close()
+
+	tenant, err := ParseHeaders("X-Tenant-Id: acme, x-route:eu-1")
+	require.NoError(t, err)
+	agent, err := ParseHeaders("User-Agent:gateway-probe")
+	require.NoError(t, err)
+	is := NewIssuerService(map[string]string{"*": srv.URL}, nil, srv.Client(),
+		WithUserAgent("refresh-service/1.0"),
+		WithHeaders(map[string]http.Header{"*": tenant, "did:iden3:probe": agent}))
+
+	_, err = is.GetClaimByID(context.Background(), "did:iden3:issuer", "1")
+	require.NoError(t, err)
+	require.Equal(t, "refresh-service/1.0", received.Get("User-Agent"))
+	require.Equal(t, "acme", received.Get("X-Tenant-Id"))
+	require.Equal(t, "eu-1", received.Get("X-Route"))
+
+	_, err = is.GetClaimByID(context.Background(), "did:iden3:probe", "1")
+	require.NoError(t, err)
+	require.Equal(t, "gateway-probe", received.Get("User-Agent"))
+	require.Empty(t, received.Get("X-Tenant-Id"))
+
+	_, err = ParseHeaders("X-Tenant-Id")
+	require.Error(t, err)
+}
+
+func TestRevocation(t *testing.T) {
+	revoked := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/v2/identities/did:iden3:issuer/credentials/"
+		switch {
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, prefix+"revoke/"):
+			revoked[strings.TrimPrefix(r.URL.Path, prefix+"revoke/")] = true
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"message":"credential revocation request sent"}`))
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, prefix+"revocation/status/"):
+			nonce := strings.TrimPrefix(r.URL.Path, prefix+"revocation/status/")
+			_, _ = w.Write([]byte(`{"issuer":{},"mtp":{"existence":` + strconv.FormatBool(revoked[nonce]) + `}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	is := NewIssuerService(map[string]string{"*": srv.URL}, nil, srv.Client())
+	ctx := context.Background()
+
+	status, err := is.RevocationStatus(ctx, "did:iden3:issuer", 42)
+	require.NoError(t, err)
+	require.False(t, status)
+	require.NoError(t, is.RevokeCredential(ctx, "did:iden3:issuer", 42))
+	status, err = is.RevocationStatus(ctx, "did:iden3:issuer", 42)
+	require.NoError(t, err)
+	require.True(t, status)
+
+	require.ErrorIs(t, is.RevokeCredential(ctx, "did:iden3:other", 42), ErrRevokeClaim)
+	is = NewIssuerService(map[string]string{"did:iden3:issuer": srv.URL}, nil, srv.Client())
+	_, err = is.RevocationStatus(ctx, "did:iden3:other", 42)
+	require.ErrorIs(t, err, ErrIssuerNotSupported)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/pkg/errors"
+)
+
+type IssuerOption func(*IssuerService)
+
+// ClientCertificate is the client certificate presented to an issuer node.
+// CAFile is optional and replaces the system roots for that node.
+type ClientCertificate struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+}
+
+// LoadTLSConfig builds a client TLS configuration from certificate files.
+func (cc ClientCertificate) LoadTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cc.CertFile, cc.KeyFile)
+	if err != nil {
+		return nil, errors.Errorf("failed to load client certificate '%s': %v", cc.CertFile, err)
+	}
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if cc.CAFile != "" {
+		//nolint:gosec // CA path comes from the service configuration
+		pem, err := os.ReadFile(cc.CAFile)
+		if err != nil {
+			return nil, errors.Errorf("failed to read CA file '%s': %v", cc.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in CA file '%s'", cc.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// WithIssuerTLS uses a dedicated client with the given TLS configuration
+// for each issuer DID. The '*' key applies to all other issuers.
+func WithIssuerTLS(configs map[string]*tls.Config) IssuerOption {
+	return func(is *IssuerService) {
+		for issuerDID, cfg := range configs {
+			is.clients[issuerDID] = httpclient.WithTLSConfig(&is.do, cfg)
+		}
+	}
+}
+
+func (is *IssuerService) client(issuerDID string) *http.Client {
+	if c, ok := is.clients[issuerDID]; ok {
+		return c
+	}
+	if c, ok := is.clients["*"]; ok {
+		return c
+	}
+	return &is.do
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+
+	core "github.com/iden3/go-iden3-core/v2"
+	"github.com/iden3/go-iden3-core/v2/w3c"
+	"github.com/pkg/errors"
+)
+
+// Identifier formats of the issuer in issuer node URLs.
+const (
+	// IssuerIdentifierDID puts the full DID in the path.
+	IssuerIdentifierDID = "did"
+	// IssuerIdentifierID puts the iden3 identifier of the DID, or the
+	// method-specific id of other DID methods, in the path.
+	IssuerIdentifierID = "id"
+)
+
+// WithIssuerIdentifiers sets the identifier format issuer nodes expect per
+// issuer DID, '*' for all other issuers. The default is the full DID.
+func WithIssuerIdentifiers(formats map[string]string) IssuerOption {
+	return func(is *IssuerService) {
+		is.identifiers = formats
+	}
+}
+
+// identityURL returns the URL of the issuer identity on issuerNode. The
+// identifier is a single escaped path segment, so characters like '%' in
+// did:web ports reach the node as part of the DID.
+func (is *IssuerService) identityURL(issuerNode, issuerDID string) (string, error) {
+	format, ok := is.identifiers[issuerDID]
+	if !ok {
+		format = is.identifiers["*"]
+	}
+	identifier, err := issuerIdentifier(issuerDID, format)
+	if err != nil {
+		return "", errors.Wrapf(ErrIssuerNotSupported, "id '%s': %v", issuerDID, err)
+	}
+	return issuerNode + "/v2/identities/" + url.PathEscape(identifier), nil
+}
+
+// credentialURL returns the URL of the credential claimID of the issuer.
+func (is *IssuerService) credentialURL(issuerNode, issuerDID, claimID string) (string, error) {
+	identity, err := is.identityURL(issuerNode, issuerDID)
+	if err != nil {
+		return "", err
+	}
+	return identity + "/credentials/" + url.PathEscape(claimID), nil
+}
+
+func issuerIdentifier(issuerDID, format string) (string, error) {
+	did := strings.TrimSpace(issuerDID)
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", IssuerIdentifierDID:
+		return did, nil
+	case IssuerIdentifierID:
+		parsed, err := w3c.ParseDID(did)
+		if err != nil {
+			return "", err
+		}
+		// iden3 DIDs end with the identifier after method, blockchain and network
+		segments := strings.Split(parsed.ID, ":")
+		if id, err := core.IDFromString(segments[len(segments)-1]); err == nil {
+			return id.String(), nil
+		}
+		return parsed.ID, nil
+	default:
+		return "", errors.Errorf("unknown identifier format '%s'", format)
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		issuerDID   string
+		claimID     string
+		format      string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:      "Full DID",
+			issuerDID: "did:polygonid:polygon:mumbai:2qMPnHfStSRPTEEuoYKApnh8j8ppVYUAJDNRJwXUzf",
+			claimID:   "e6d0e822-686c-11ee-8afb-3ec1cb517438",
+			expected: "https://issuer.example.com/v2/identities/" +
+				"did:polygonid:polygon:mumbai:2qMPnHfStSRPTEEuoYKApnh8j8ppVYUAJDNRJwXUzf" +
+				"/credentials/e6d0e822-686c-11ee-8afb-3ec1cb517438",
+		},
+		{
+			name:      "Identifier",
+			issuerDID: "did:polygonid:polygon:mumbai:2qMPnHfStSRPTEEuoYKApnh8j8ppVYUAJDNRJwXUzf",
+			claimID:   "1",
+			format:    IssuerIdentifierID,
+			expected:  "https://issuer.example.com/v2/identities/2qMPnHfStSRPTEEuoYKApnh8j8ppVYUAJDNRJwXUzf/credentials/1",
+		},
+		{
+			name:      "Escaped segments",
+			issuerDID: "did:web:example.com%3A8443:issuers:1",
+			claimID:   "../admin?x=1",
+			expected: "https://issuer.example.com/v2/identities/did:web:example.com%253A8443:issuers:1" +
+				"/credentials/..%2Fadmin%3Fx=1",
+		},
+		{
+			name:      "Method-specific id",
+			issuerDID: "did:web:example.com:issuers:1",
+			claimID:   "1",
+			format:    IssuerIdentifierID,
+			expected:  "https://issuer.example.com/v2/identities/example.com:issuers:1/credentials/1",
+		},
+		{
+			name:        "Unknown format",
+			issuerDID:   "did:web:example.com",
+			format:      "uuid",
+			expectedErr: ErrIssuerNotSupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := NewIssuerService(nil, nil, nil, WithIssuerIdentifiers(map[string]string{"*": tt.format}))
+			credentialURL, err := is.credentialURL("https://issuer.example.com", tt.issuerDID, tt.claimID)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, credentialURL)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/pkg/errors"
+)
+
+// MediaTypeJWTVC is the media type of a credential in compact JWT form.
+const MediaTypeJWTVC = "application/vc+jwt"
+
+var ErrJWTNotSupported = errors.New("issuer node does not produce JWT credentials")
+
+type preferredFormatKey struct{}
+
+// WithPreferredFormat records the credential media type the wallet asked for.
+func WithPreferredFormat(ctx context.Context, mediaType string) context.Context {
+	return context.WithValue(ctx, preferredFormatKey{}, mediaType)
+}
+
+func preferredFormat(ctx context.Context) string {
+	format, _ := ctx.Value(preferredFormatKey{}).(string)
+	return format
+}
+
+// GetCredentialJWT requests the credential in compact JWT form. Issuer nodes
+// which can't produce it answer with JSON or 406 and ErrJWTNotSupported is
+// returned.
+func (is *IssuerService) GetCredentialJWT(ctx context.Context, issuerDID, claimID string) (string, error) {
+	issuerNode, err := is.getIssuerURL(issuerDID)
+	if err != nil {
+		return "", err
+	}
+	credentialURL, err := is.credentialURL(issuerNode, issuerDID, claimID)
+	if err != nil {
+		return "", err
+	}
+	getRequest, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		credentialURL,
+		http.NoBody,
+	)
+	if err != nil {
+		return "", errors.Wrapf(ErrGetClaim,
+			"failed to create http request: '%v'", err)
+	}
+	getRequest.Header.Set("Accept", MediaTypeJWTVC)
+	if err := is.setBasicAuth(issuerDID, getRequest); err != nil {
+		return "", err
+	}
+	correlation.SetHeader(ctx, getRequest)
+
+	resp, err := is.send(issuerDID, getRequest)
+	if err != nil {
+		return "", errors.Wrapf(ErrGetClaim,
+			"failed http GET request: '%v'", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotAcceptable {
+		return "", ErrJWTNotSupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", httpclient.Throttle(resp, errors.Wrapf(ErrGetClaim,
+			"invalid status code: '%d'", resp.StatusCode))
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != MediaTypeJWTVC && mediaType != "application/jwt" {
+		return "", ErrJWTNotSupported
+	}
+	body, err := io.ReadAll(is.responseBody(resp))
+	if err != nil {
+		return "", errors.Wrapf(ErrGetClaim, "failed to read response body: '%v'", readError(err))
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// attachJWT adds the JWT form of the refreshed credential when the wallet
+// prefers it. The W3C credential is still returned when the issuer node
+// can't produce a JWT.
+func (as *AgentService) attachJWT(ctx context.Context, issuerDID string, result *RefreshResult) error {
+	if preferredFormat(ctx) != MediaTypeJWTVC {
+		return nil
+	}
+	jwt, err := as.refreshService.issuerService.GetCredentialJWT(ctx, issuerDID, convertID(result.Credential.ID))
+	if errors.Is(err, ErrJWTNotSupported) {
+		logger.DefaultLogger.Infof("issuer '%s' does not produce JWT credentials, returning JSON only", issuerDID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result.JWT = jwt
+	return nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/0xPolygonID/refresh-service/codec"
+	"github.com/0xPolygonID/refresh-service/logger"
+	"github.com/0xPolygonID/refresh-service/metrics"
+	"github.com/google/uuid"
+	"github.com/iden3/iden3comm/v2"
+	"github.com/iden3/iden3comm/v2/packers"
+	iden3Protocol "github.com/iden3/iden3comm/v2/protocol"
+)
+
+// MessageValidationError is returned when an agent message doesn't follow
+// the refresh protocol. It wraps ErrInvalidProtocolMessage and lists the
+// offending fields.
+type MessageValidationError struct {
+	Fields []string
+}
+
+func (e *MessageValidationError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidProtocolMessage, strings.Join(e.Fields, "; "))
+}
+
+func (e *MessageValidationError) Unwrap() error {
+	return ErrInvalidProtocolMessage
+}
+
+// messageMediaTypes are the media types an unpacked message may declare.
+var messageMediaTypes = []iden3comm.MediaType{
+	packers.MediaTypePlainMessage,
+	packers.MediaTypeZKPMessage,
+	packers.MediaTypeSignedMessage,
+	packers.MediaTypeEncryptedMessage,
+}
+
+// messageBodies are the body shapes of the message types the agent
+// dispatches.
+var messageBodies = map[iden3comm.ProtocolMessage][]payloadField{
+	iden3Protocol.CredentialRefreshMessageType: {
+		{path: "id", kinds: []string{kindString}},
+		{path: "ids", kinds: []string{kindArray}},
+		{path: "ids[]", kinds: []string{kindString}},
+		{path: "reason", kinds: []string{kindString}},
+	},
+}
+
+// WithReportOnlyMessageValidation dispatches agent messages which don't
+// follow the refresh protocol, e.g. while wallets are updated. The
+// violations are still counted and logged, only messages missing 'from' or
+// 'to' are rejected.
+func WithReportOnlyMessageValidation() AgentOption {
+	return func(as *AgentService) {
+		as.reportOnlyMessages = true
+	}
+}
+
+// checkMessage validates an unpacked message and decides whether it may be
+// dispatched, see WithReportOnlyMessageValidation.
+func (as *AgentService) checkMessage(message *iden3comm.BasicMessage) error {
+	err := validateMessage(message)
+	if err == nil || !as.reportOnlyMessages || message.From == "" || message.To == "" {
+		return err
+	}
+	logger.SampledWarnf("accepted agent message '%s' with protocol violations: %v", message.ID, err)
+	return nil
+}
+
+// validateMessage checks an unpacked message against the refresh protocol
+// before it is dispatched. Violations are counted by field.
+func validateMessage(message *iden3comm.BasicMessage) error {
+	problems := messageProblems(message)
+	if len(problems) == 0 {
+		return nil
+	}
+	for _, problem := range problems {
+		field, _, _ := strings.Cut(problem, ":")
+		metrics.AgentMessageViolations.WithLabelValues(field).Inc()
+	}
+	return &MessageValidationError{Fields: problems}
+}
+
+func messageProblems(message *iden3comm.BasicMessage) []string {
+	var problems []string
+	if message.ID == "" {
+		problems = append(problems, "id: missing")
+	}
+	if message.Typ != "" && !isMessageMediaType(message.Typ) {
+		problems = append(problems, fmt.Sprintf("typ: unsupported media type '%s'", message.Typ))
+	}
+	for _, party := range []struct{ field, did string }{{"from", message.From}, {"to", message.To}} {
+		switch {
+		case party.did == "":
+			problems = append(problems, party.field+": missing")
+		case !strings.HasPrefix(party.did, "did:"):
+			problems = append(problems, fmt.Sprintf("%s: '%s' is not a DID", party.field, party.did))
+		}
+	}
+	fields, ok := messageBodies[message.Type]
+	if !ok {
+		return append(problems, fmt.Sprintf("type: unknown message type '%s'", message.Type))
+	}
+
+	var body interface{}
+	if len(message.Body) == 0 {
+		return append(problems, "body: missing")
+	}
+	if err := codec.Unmarshal(message.Body, &body); err != nil {
+		return append(problems, fmt.Sprintf("body: %v", err))
+	}
+	if kind := payloadKind(body); kind != kindObject {
+		return append(problems, fmt.Sprintf("body: expected object, got %s", kind))
+	}
+	root := newPayloadNode(message.Body)
+	for _, field := range fields {
+		for _, problem := range field.check(root) {
+			problems = append(problems, "body."+problem)
+		}
+	}
+	if message.Type == iden3Protocol.CredentialRefreshMessageType {
+		problems = append(problems, credentialIDProblems(body.(map[string]interface{}))...)
+	}
+	return problems
+}
+
+// credentialIDProblems checks the credential ids of a refresh message body,
+// either one in 'id' or several in 'ids'. The urn:uuid:, URL and plain
+// forms are accepted, see convertID.
+func credentialIDProblems(body map[string]interface{}) []string {
+	_, hasID := body["id"]
+	_, hasIDs := body["ids"]
+	switch {
+	case !hasID && !hasIDs:
+		return []string{"body.id: missing"}
+	case hasID && hasIDs:
+		return []string{"body.ids: not allowed with body.id"}
+	}
+	var problems []string
+	if id, ok := body["id"].(string); ok && !isCredentialID(id) {
+		problems = append(problems, fmt.Sprintf("body.id: '%s' is not a credential id", id))
+	}
+	ids, isArray := body["ids"].([]interface{})
+	if isArray && len(ids) == 0 {
+		problems = append(problems, "body.ids: empty")
+	}
+	for i, element := range ids {
+		if id, ok := element.(string); ok && !isCredentialID(id) {
+			problems = append(problems, fmt.Sprintf("body.ids[%d]: '%s' is not a credential id", i, id))
+		}
+	}
+	return problems
+}
+
+func isCredentialID(id string) bool {
+	_, err := uuid.Parse(convertID(id))
+	return err == nil
+}
+
+func isMessageMediaType(typ iden3comm.MediaType) bool {
+	for _, mediaType := range messageMediaTypes {
+		if typ == mediaType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/metrics"
+	"github.com/iden3/iden3comm/v2"
+	"github.com/iden3/iden3comm/v2/packers"
+	iden3Protocol "github.com/iden3/iden3comm/v2/protocol"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMessage(t *testing.T) {
+	valid := func() *iden3comm.BasicMessage {
+		return &iden3comm.BasicMessage{
+			ID:   "1",
+			Typ:  packers.MediaTypePlainMessage,
+			Type: iden3Protocol.CredentialRefreshMessageType,
+			Body: []byte(`{"id": "urn:uuid:e342def6-620e-4394-8ea1-7448ea81bb72", "reason": "expired"}`),
+			From: "did:iden3:owner",
+			To:   "did:iden3:issuer",
+		}
+	}
+
+	tests := []struct {
+		name           string
+		modify         func(m *iden3comm.BasicMessage)
+		expectedFields []string
+	}{
+		{
+			name:   "Valid message",
+			modify: func(*iden3comm.BasicMessage) {},
+		},
+		{
+			name: "Credential id as URL",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Body = []byte(`{"id": "https://issuer.example/v1/credentials/e342def6-620e-4394-8ea1-7448ea81bb72"}`)
+			},
+		},
+		{
+			name: "Missing parties",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.ID = ""
+				m.From = ""
+				m.To = "issuer"
+			},
+			expectedFields: []string{"id: missing", "from: missing", "to: 'issuer' is not a DID"},
+		},
+		{
+			name: "Unsupported media type",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Typ = "application/json"
+			},
+			expectedFields: []string{"typ: unsupported media type 'application/json'"},
+		},
+		{
+			name: "Unknown message type",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Type = iden3Protocol.CredentialOfferMessageType
+			},
+			expectedFields: []string{"type: unknown message type '" + string(iden3Protocol.CredentialOfferMessageType) + "'"},
+		},
+		{
+			name: "Missing body",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Body = nil
+			},
+			expectedFields: []string{"body: missing"},
+		},
+		{
+			name: "Body not an object",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Body = []byte(`["e342def6-620e-4394-8ea1-7448ea81bb72"]`)
+			},
+			expectedFields: []string{"body: expected object, got array"},
+		},
+		{
+			name: "Body of the wrong shape",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Body = []byte(`{"reason": 1}`)
+			},
+			expectedFields: []string{"body.reason: expected string, got number", "body.id: missing"},
+		},
+		{
+			name: "Several credential ids",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Body = []byte(`{"ids": ["urn:uuid:e342def6-620e-4394-8ea1-7448ea81bb72", "0a8d4c2e-1b6f-4a57-9d3e-2f7c8b9e1a40"]}`)
+			},
+		},
+		{
+			name: "Credential id and ids",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Body = []byte(`{"id": "e342def6-620e-4394-8ea1-7448ea81bb72", "ids": ["e342def6-620e-4394-8ea1-7448ea81bb72"]}`)
+			},
+			expectedFields: []string{"body.ids: not allowed with body.id"},
+		},
+		{
+			name: "Malformed credential ids",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Body = []byte(`{"ids": ["e342def6-620e-4394-8ea1-7448ea81bb72", 7, "urn:uuid:1"]}`)
+			},
+			expectedFields: []string{"body.ids[1]: expected string, got number", "body.ids[2]: 'urn:uuid:1' is not a credential id"},
+		},
+		{
+			name: "Empty credential ids",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Body = []byte(`{"ids": []}`)
+			},
+			expectedFields: []string{"body.ids: empty"},
+		},
+		{
+			name: "Malformed credential id",
+			modify: func(m *iden3comm.BasicMessage) {
+				m.Body = []byte(`{"id": "urn:uuid:not-a-uuid"}`)
+			},
+			expectedFields: []string{"body.id: 'urn:uuid:not-a-uuid' is not a credential id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := valid()
+			tt.modify(message)
+			err := validateMessage(message)
+			if tt.expectedFields == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidProtocolMessage)
+			var validation *MessageValidationError
+			require.ErrorAs(t, err, &validation)
+			require.Equal(t, tt.expectedFields, validation.Fields)
+		})
+	}
+}
+
+func TestValidateMessage_Metrics(t *testing.T) {
+	before := testutil.ToFloat64(metrics.AgentMessageViolations.WithLabelValues("body.id"))
+	err := validateMessage(&iden3comm.BasicMessage{
+		ID:   "1",
+		Type: iden3Protocol.CredentialRefreshMessageType,
+		Body: []byte(`{}`),
+		From: "did:iden3:owner",
+		To:   "did:iden3:issuer",
+	})
+	require.Error(t, err)
+	require.InDelta(t, before+1, testutil.ToFloat64(metrics.AgentMessageViolations.WithLabelValues("body.id")), 0)
+}
+
+func TestCheckMessage(t *testing.T) {
+	message := func(from, body string) *iden3comm.BasicMessage {
+		return &iden3comm.BasicMessage{
+			Type: iden3Protocol.CredentialRefreshMessageType,
+			Body: []byte(body),
+			From: from,
+			To:   "did:iden3:issuer",
+		}
+	}
+
+	tests := []struct {
+		name        string
+		reportOnly  bool
+		message     *iden3comm.BasicMessage
+		expectedErr bool
+	}{
+		{
+			name:        "Violations rejected",
+			message:     message("did:iden3:owner", `{"id": "credential"}`),
+			expectedErr: true,
+		},
+		{
+			name:       "Violations reported only",
+			reportOnly: true,
+			message:    message("did:iden3:owner", `{"id": "credential"}`),
+		},
+		{
+			name:        "Missing sender when reporting only",
+			reportOnly:  true,
+			message:     message("", `{"id": "urn:uuid:e342def6-620e-4394-8ea1-7448ea81bb72"}`),
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []AgentOption
+			if tt.reportOnly {
+				opts = append(opts, WithReportOnlyMessageValidation())
+			}
+			err := NewAgentService(nil, nil, opts...).checkMessage(tt.message)
+			if !tt.expectedErr {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidProtocolMessage)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/pkg/errors"
+)
+
+var ErrRevocationNonceConflict = errors.New("revocation nonce conflict")
+
+// WithRevocationNonceCheck checks the revocation nonce a credential is
+// reissued with before the issuer node is called. A nonce which is already
+// revoked would make the new credential revoked from the start, and a nonce
+// already carried by another credential reissued from the same one, or from
+// one it was reissued from, per the refresh history and WithLineage, would
+// make revoking either revoke both. Both fail the refresh with
+// ErrRevocationNonceConflict instead. The issuer node can't tell which
+// credentials carry a nonce, so the check requires WithHistory: refreshes
+// fail without it.
+func WithRevocationNonceCheck() RefreshOption {
+	return func(rs *RefreshService) {
+		rs.revocationNonceCheck = true
+	}
+}
+
+// maxNonceLineage bounds the credentials a nonce is traced back through, like
+// the lineage queries of the stores.
+const maxNonceLineage = 1000
+
+// checkRevocationNonce fails when credentialID of issuer can't be reissued
+// with nonce.
+func (rs *RefreshService) checkRevocationNonce(ctx context.Context, issuer, credentialID string, nonce uint64) error {
+	if rs.history == nil {
+		return errors.New("the revocation nonce check requires the refresh history")
+	}
+	revoked, err := rs.issuerService.RevocationStatus(ctx, issuer, nonce)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.Wrapf(ErrRevocationNonceConflict,
+			"nonce %d of credential '%s' is revoked", nonce, credentialID)
+	}
+	chain, err := rs.nonceLineage(ctx, credentialID)
+	if err != nil {
+		return err
+	}
+	carriers := make(map[string]bool, len(chain))
+	for _, id := range chain {
+		carriers[convertID(id)] = true
+	}
+	for i, carrier := range chain {
+		// refreshes are recorded with the id they were requested with
+		ids := []string{carrier}
+		if id := convertID(carrier); id != carrier {
+			ids = append(ids, id)
+		}
+		for _, id := range ids {
+			records, err := rs.history.ListRefreshes(ctx, storage.RefreshFilter{
+				Issuer:       issuer,
+				CredentialID: id,
+				Status:       storage.RefreshStatusSucceeded,
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to list the refreshes of the credential")
+			}
+			for _, record := range records {
+				// native refreshes update the credential in place, and the
+				// credentials reissued along the lineage carry the nonce
+				// anyway
+				if record.RefreshedID == "" || carriers[convertID(record.RefreshedID)] {
+					continue
+				}
+				if i == 0 {
+					return errors.Wrapf(ErrRevocationNonceConflict,
+						"nonce %d of credential '%s' is already used by its reissued credential '%s'",
+						nonce, credentialID, record.RefreshedID)
+				}
+				return errors.Wrapf(ErrRevocationNonceConflict,
+					"nonce %d of credential '%s' is already used by credential '%s' reissued from '%s'",
+					nonce, credentialID, record.RefreshedID, carrier)
+			}
+		}
+	}
+	return nil
+}
+
+// nonceLineage returns credentialID and the credentials it was reissued
+// from, which carry its nonce, closest first. Without WithLineage only
+// credentialID is known.
+func (rs *RefreshService) nonceLineage(ctx context.Context, credentialID string) ([]string, error) {
+	chain := []string{credentialID}
+	if rs.lineage == nil {
+		return chain, nil
+	}
+	seen := map[string]bool{convertID(credentialID): true}
+	// parents are recorded with the id they were requested with, which
+	// doesn't match the id of their own lineage record when it is a URN, so
+	// the lineage is walked one parent at a time
+	for id := convertID(credentialID); len(chain) < maxNonceLineage; {
+		records, err := rs.lineage.Lineage(ctx, id)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the lineage of the credential")
+		}
+		if len(records) == 0 {
+			break
+		}
+		parent := records[0].ParentID
+		id = convertID(parent)
+		if parent == "" || seen[id] {
+			break
+		}
+		seen[id] = true
+		chain = append(chain, parent)
+	}
+	return chain, nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRevocationNonce(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/identities/did:iden3:issuer/credentials/revocation/status/7":
+			_, _ = w.Write([]byte(`{"mtp":{"existence":true}}`))
+		case "/v2/identities/did:iden3:issuer/credentials/revocation/status/42":
+			_, _ = w.Write([]byte(`{"mtp":{"existence":false}}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	history := memory.NewStore()
+	for _, record := range []storage.RefreshRecord{
+		{Issuer: "did:iden3:issuer", CredentialID: "reissued", RefreshedID: "next", Status: storage.RefreshStatusSucceeded},
+		{Issuer: "did:iden3:issuer", CredentialID: "failed", Status: storage.RefreshStatusFailed},
+		{Issuer: "did:iden3:issuer", CredentialID: "native", RefreshedID: "native", Status: storage.RefreshStatusSucceeded},
+		{Issuer: "did:iden3:issuer", CredentialID: "urn:uuid:root", RefreshedID: "parent", Status: storage.RefreshStatusSucceeded},
+		{Issuer: "did:iden3:issuer", CredentialID: "urn:uuid:parent", RefreshedID: "child", Status: storage.RefreshStatusSucceeded},
+		{Issuer: "did:iden3:issuer", CredentialID: "urn:uuid:root", RefreshedID: "sibling", Status: storage.RefreshStatusSucceeded},
+		{Issuer: "did:iden3:issuer", CredentialID: "urn:uuid:first", RefreshedID: "second", Status: storage.RefreshStatusSucceeded},
+	} {
+		require.NoError(t, history.SaveRefresh(ctx, record))
+	}
+	for _, record := range []storage.LineageRecord{
+		{CredentialID: "parent", ParentID: "urn:uuid:root", Issuer: "did:iden3:issuer"},
+		{CredentialID: "child", ParentID: "urn:uuid:parent", Issuer: "did:iden3:issuer"},
+		{CredentialID: "second", ParentID: "urn:uuid:first", Issuer: "did:iden3:issuer"},
+	} {
+		require.NoError(t, history.SaveLineage(ctx, record))
+	}
+	is := NewIssuerService(map[string]string{"*": srv.URL}, nil, srv.Client())
+
+	tests := []struct {
+		name         string
+		credentialID string
+		nonce        uint64
+		withHistory  bool
+		expectedErr  string
+		expectedCode int
+	}{
+		{name: "Unused", credentialID: "fresh", nonce: 42, withHistory: true},
+		{
+			name:         "Revoked",
+			credentialID: "fresh",
+			nonce:        7,
+			withHistory:  true,
+			expectedErr:  "nonce 7 of credential 'fresh' is revoked: revocation nonce conflict",
+			expectedCode: CodeRevocationNonceConflict,
+		},
+		{
+			name:         "Reissued",
+			credentialID: "urn:uuid:reissued",
+			nonce:        42,
+			withHistory:  true,
+			expectedErr:  "nonce 42 of credential 'urn:uuid:reissued' is already used by its reissued credential 'next': revocation nonce conflict",
+			expectedCode: CodeRevocationNonceConflict,
+		},
+		{
+			name:         "Without history",
+			credentialID: "reissued",
+			nonce:        42,
+			expectedErr:  "the revocation nonce check requires the refresh history",
+			expectedCode: CodeInternal,
+		},
+		{
+			name:         "Reissued from the same credential",
+			credentialID: "urn:uuid:child",
+			nonce:        42,
+			withHistory:  true,
+			expectedErr:  "nonce 42 of credential 'urn:uuid:child' is already used by credential 'sibling' reissued from 'urn:uuid:root': revocation nonce conflict",
+			expectedCode: CodeRevocationNonceConflict,
+		},
+		{name: "Latest reissued", credentialID: "urn:uuid:second", nonce: 42, withHistory: true},
+		{name: "Failed refresh", credentialID: "failed", nonce: 42, withHistory: true},
+		{name: "Native refresh", credentialID: "native", nonce: 42, withHistory: true},
+		{
+			name:         "Status unavailable",
+			credentialID: "fresh",
+			nonce:        1,
+			withHistory:  true,
+			expectedErr:  "invalid status code: '500': failed to get revocation status",
+			expectedCode: CodeInternal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []RefreshOption{WithRevocationNonceCheck()}
+			if tt.withHistory {
+				opts = append(opts, WithHistory(history), WithLineage(history))
+			}
+			rs := NewRefreshService(is, nil, flexiblehttp.FactoryFlexibleHTTP{}, opts...)
+			err := rs.checkRevocationNonce(ctx, "did:iden3:issuer", tt.credentialID, tt.nonce)
+			if tt.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.expectedErr)
+			require.Equal(t, tt.expectedCode, ErrorCode(err))
+		})
+	}
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/pkg/errors"
+)
+
+// PreAuthorizedCodeGrantType is the OAuth grant type wallets exchange the
+// pre-authorized code of a credential offer with.
+const PreAuthorizedCodeGrantType = "urn:ietf:params:oauth:grant-type:pre-authorized_code"
+
+// Credential configurations offered over OpenID4VCI: the refreshed
+// credential in its iden3 W3C JSON form, or as a JWT VC when the issuer
+// node produces one.
+const (
+	OpenID4VCIConfigurationW3C = "iden3_w3c"
+	OpenID4VCIConfigurationJWT = "iden3_jwt_vc_json"
+)
+
+const txCodeLength = 6
+
+var (
+	// ErrInvalidGrant is an unknown, expired or already used pre-authorized
+	// code, or a wrong transaction code.
+	ErrInvalidGrant = errors.New("invalid grant")
+	// ErrInvalidAccessToken is an unknown, expired or already used access
+	// token of the credential endpoint.
+	ErrInvalidAccessToken = errors.New("invalid access token")
+	// ErrUnsupportedCredentialFormat is a credential configuration or format
+	// the service doesn't issue.
+	ErrUnsupportedCredentialFormat = errors.New("unsupported credential format")
+)
+
+var openID4VCIFormats = map[string]string{
+	OpenID4VCIConfigurationW3C: "ldp_vc",
+	OpenID4VCIConfigurationJWT: "jwt_vc_json",
+}
+
+// OpenID4VCISettings configure the OpenID4VCI bridge.
+type OpenID4VCISettings struct {
+	// CredentialIssuer is the public URL of the service, the identifier of
+	// the credential issuer in offers and metadata.
+	CredentialIssuer string
+	// OfferTTL is how long a pre-authorized code can be exchanged.
+	OfferTTL time.Duration
+	// TokenTTL is how long an access token can be used.
+	TokenTTL time.Duration
+}
+
+// WithOpenID4VCI lets wallets of the OpenID4VCI ecosystem obtain refreshed
+// credentials with the pre-authorized code flow. Codes and tokens are kept
+// in store, so every replica can redeem them.
+func WithOpenID4VCI(store storage.Idempotency, settings OpenID4VCISettings) AgentOption {
+	return func(as *AgentService) {
+		if settings.OfferTTL <= 0 {
+			settings.OfferTTL = 10 * time.Minute
+		}
+		if settings.TokenTTL <= 0 {
+			settings.TokenTTL = 5 * time.Minute
+		}
+		settings.CredentialIssuer = strings.TrimSuffix(settings.CredentialIssuer, "/")
+		as.openID4VCI = &settings
+		as.openID4VCIStore = store
+	}
+}
+
+// OpenID4VCIEnabled reports whether the OpenID4VCI bridge is configured.
+func (as *AgentService) OpenID4VCIEnabled() bool {
+	return as != nil && as.openID4VCI != nil && as.openID4VCIStore != nil
+}
+
+// CredentialOfferRequest asks for an offer of the refreshed credential
+// to its owner. The refresh runs when the wallet redeems the offer, with
+// the same checks as any other refresh.
+type CredentialOfferRequest struct {
+	Issuer       string `json:"issuer"`
+	Owner        string `json:"owner"`
+	CredentialID string `json:"credentialId"`
+	// TxCode protects the offer with a numeric code the owner must enter
+	// in the wallet, to be sent over another channel than the offer.
+	TxCode bool `json:"txCode"`
+}
+
+type CredentialOffer struct {
+	CredentialIssuer           string                            `json:"credential_issuer"`
+	CredentialConfigurationIDs []string                          `json:"credential_configuration_ids"`
+	Grants                     map[string]PreAuthorizedCodeOffer `json:"grants"`
+}
+
+type PreAuthorizedCodeOffer struct {
+	PreAuthorizedCode string       `json:"pre-authorized_code"`
+	TxCode            *TxCodeInput `json:"tx_code,omitempty"`
+}
+
+// TxCodeInput tells the wallet which transaction code to ask for.
+type TxCodeInput struct {
+	InputMode   string `json:"input_mode"`
+	Length      int    `json:"length"`
+	Description string `json:"description,omitempty"`
+}
+
+// CredentialOfferResponse is a created offer. TxCode is only returned
+// here: it must reach the owner without the offer.
+type CredentialOfferResponse struct {
+	Offer     CredentialOffer `json:"credentialOffer"`
+	OfferURI  string          `json:"credentialOfferUri"`
+	TxCode    string          `json:"txCode,omitempty"`
+	ExpiresAt time.Time       `json:"expiresAt"`
+}
+
+// TokenResponse is the OAuth token response of the token endpoint.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// CredentialRequest is the body of a credential endpoint request. Wallets
+// name a credential_configuration_id or, in older drafts, a format.
+type CredentialRequest struct {
+	CredentialConfigurationID string `json:"credential_configuration_id"`
+	Format                    string `json:"format"`
+}
+
+// CredentialResponse carries the refreshed credential, a JSON object for
+// ldp_vc and a compact JWT for jwt_vc_json.
+type CredentialResponse struct {
+	Credential interface{} `json:"credential"`
+}
+
+// openID4VCIGrant is what a pre-authorized code and its access token grant.
+type openID4VCIGrant struct {
+	Issuer       string `json:"issuer"`
+	Owner        string `json:"owner"`
+	CredentialID string `json:"credentialId"`
+	TxCodeHash   string `json:"txCodeHash,omitempty"`
+}
+
+// CreateCredentialOffer creates an offer of the credential redeemable once
+// within the offer TTL.
+func (as *AgentService) CreateCredentialOffer(ctx context.Context, request CredentialOfferRequest) (*CredentialOfferResponse, error) {
+	if request.Issuer == "" || request.Owner == "" || request.CredentialID == "" {
+		return nil, errors.Wrap(ErrInvalidProtocolMessage, "missing required fields in credential offer request")
+	}
+	if err := as.refreshService.CheckOwnerMethod(request.Issuer, request.Owner); err != nil {
+		return nil, err
+	}
+	code, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	grant := openID4VCIGrant{Issuer: request.Issuer, Owner: request.Owner, CredentialID: request.CredentialID}
+	grantOffer := PreAuthorizedCodeOffer{PreAuthorizedCode: code}
+	var txCode string
+	if request.TxCode {
+		if txCode, err = randomDigits(txCodeLength); err != nil {
+			return nil, err
+		}
+		grant.TxCodeHash = hashToken(txCode)
+		grantOffer.TxCode = &TxCodeInput{InputMode: "numeric", Length: txCodeLength}
+	}
+	expiresAt := time.Now().Add(as.openID4VCI.OfferTTL).UTC()
+	if err := as.putGrant(ctx, "openid4vci:code:"+hashToken(code), grant, expiresAt); err != nil {
+		return nil, err
+	}
+
+	offer := CredentialOffer{
+		CredentialIssuer:           as.openID4VCI.CredentialIssuer,
+		CredentialConfigurationIDs: []string{OpenID4VCIConfigurationW3C, OpenID4VCIConfigurationJWT},
+		Grants:                     map[string]PreAuthorizedCodeOffer{PreAuthorizedCodeGrantType: grantOffer},
+	}
+	rawOffer, err := json.Marshal(offer)
+	if err != nil {
+		return nil, err
+	}
+	return &CredentialOfferResponse{
+		Offer:     offer,
+		OfferURI:  "openid-credential-offer://?credential_offer=" + url.QueryEscape(string(rawOffer)),
+		TxCode:    txCode,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// ExchangePreAuthorizedCode redeems a pre-authorized code for an access
+// token. A code is redeemed at most once, even with a wrong transaction
+// code, so transaction codes can't be guessed.
+func (as *AgentService) ExchangePreAuthorizedCode(ctx context.Context, code, txCode string) (*TokenResponse, error) {
+	codeHash := hashToken(code)
+	grant, err := as.getGrant(ctx, "openid4vci:code:"+codeHash, ErrInvalidGrant)
+	if err != nil {
+		return nil, err
+	}
+	if err := as.markUsed(ctx, "openid4vci:redeemed:"+codeHash, as.openID4VCI.OfferTTL, ErrInvalidGrant); err != nil {
+		return nil, err
+	}
+	if grant.TxCodeHash != "" &&
+		subtle.ConstantTimeCompare([]byte(grant.TxCodeHash), []byte(hashToken(txCode))) != 1 {
+		return nil, errors.Wrap(ErrInvalidGrant, "wrong transaction code")
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(as.openID4VCI.TokenTTL).UTC()
+	if err := as.putGrant(ctx, "openid4vci:token:"+hashToken(token), grant, expiresAt); err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(as.openID4VCI.TokenTTL.Seconds()),
+	}, nil
+}
+
+// IssueOpenID4VCICredential refreshes the credential granted to the access
+// token. A token obtains one credential.
+func (as *AgentService) IssueOpenID4VCICredential(ctx context.Context, accessToken string, request CredentialRequest) (*CredentialResponse, error) {
+	configuration, err := credentialConfiguration(request)
+	if err != nil {
+		return nil, err
+	}
+	tokenHash := hashToken(accessToken)
+	grant, err := as.getGrant(ctx, "openid4vci:token:"+tokenHash, ErrInvalidAccessToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := as.markUsed(ctx, "openid4vci:issued:"+tokenHash, as.openID4VCI.TokenTTL, ErrInvalidAccessToken); err != nil {
+		return nil, err
+	}
+
+	if configuration == OpenID4VCIConfigurationJWT {
+		ctx = WithPreferredFormat(ctx, MediaTypeJWTVC)
+	}
+	result, err := as.refreshOwned(ctx, grant.Issuer, grant.Owner, grant.CredentialID)
+	if err != nil {
+		return nil, err
+	}
+	if configuration == OpenID4VCIConfigurationJWT {
+		if result.JWT == "" {
+			return nil, errors.Wrapf(ErrUnsupportedCredentialFormat, "issuer '%s' does not produce JWT credentials", grant.Issuer)
+		}
+		return &CredentialResponse{Credential: result.JWT}, nil
+	}
+	credential, err := MarshalCredential(result.Credential)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidProtocolResponse, err.Error())
+	}
+	return &CredentialResponse{Credential: json.RawMessage(credential)}, nil
+}
+
+// CredentialIssuerMetadata is served at
+// /.well-known/openid-credential-issuer.
+type CredentialIssuerMetadata struct {
+	CredentialIssuer                  string                             `json:"credential_issuer"`
+	CredentialEndpoint                string                             `json:"credential_endpoint"`
+	CredentialConfigurationsSupported map[string]CredentialConfiguration `json:"credential_configurations_supported"`
+}
+
+type CredentialConfiguration struct {
+	Format string `json:"format"`
+}
+
+// AuthorizationServerMetadata is served at
+// /.well-known/oauth-authorization-server. The service is its own
+// authorization server for the pre-authorized code flow only.
+type AuthorizationServerMetadata struct {
+	Issuer                       string   `json:"issuer"`
+	TokenEndpoint                string   `json:"token_endpoint"`
+	GrantTypesSupported          []string `json:"grant_types_supported"`
+	PreAuthorizedAnonymousAccess bool     `json:"pre-authorized_grant_anonymous_access_supported"`
+}
+
+func (as *AgentService) CredentialIssuerMetadata() CredentialIssuerMetadata {
+	configurations := make(map[string]CredentialConfiguration, len(openID4VCIFormats))
+	for id, format := range openID4VCIFormats {
+		configurations[id] = CredentialConfiguration{Format: format}
+	}
+	return CredentialIssuerMetadata{
+		CredentialIssuer:                  as.openID4VCI.CredentialIssuer,
+		CredentialEndpoint:                as.openID4VCI.CredentialIssuer + "/openid4vci/credential",
+		CredentialConfigurationsSupported: configurations,
+	}
+}
+
+func (as *AgentService) AuthorizationServerMetadata() AuthorizationServerMetadata {
+	return AuthorizationServerMetadata{
+		Issuer:                       as.openID4VCI.CredentialIssuer,
+		TokenEndpoint:                as.openID4VCI.CredentialIssuer + "/openid4vci/token",
+		GrantTypesSupported:          []string{PreAuthorizedCodeGrantType},
+		PreAuthorizedAnonymousAccess: true,
+	}
+}
+
+// credentialConfiguration returns the configuration id of request,
+// iden3_w3c when it names none.
+func credentialConfiguration(request CredentialRequest) (string, error) {
+	if request.CredentialConfigurationID != "" {
+		if _, ok := openID4VCIFormats[request.CredentialConfigurationID]; !ok {
+			return "", errors.Wrapf(ErrUnsupportedCredentialFormat,
+				"unknown credential configuration '%s'", request.CredentialConfigurationID)
+		}
+		return request.CredentialConfigurationID, nil
+	}
+	if request.Format == "" {
+		return OpenID4VCIConfigurationW3C, nil
+	}
+	for id, format := range openID4VCIFormats {
+		if format == request.Format {
+			return id, nil
+		}
+	}
+	return "", errors.Wrapf(ErrUnsupportedCredentialFormat, "format '%s'", request.Format)
+}
+
+func (as *AgentService) putGrant(ctx context.Context, key string, grant openID4VCIGrant, expiresAt time.Time) error {
+	raw, err := json.Marshal(grant)
+	if err != nil {
+		return err
+	}
+	stored, err := as.openID4VCIStore.PutIdempotency(ctx, storage.IdempotencyRecord{
+		Key:       key,
+		Response:  raw,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return errors.New("openid4vci token collision")
+	}
+	return nil
+}
+
+func (as *AgentService) getGrant(ctx context.Context, key string, notFound error) (openID4VCIGrant, error) {
+	var grant openID4VCIGrant
+	record, err := as.openID4VCIStore.GetIdempotency(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return grant, errors.Wrap(notFound, "unknown or expired")
+	}
+	if err != nil {
+		return grant, err
+	}
+	if err := json.Unmarshal(record.Response, &grant); err != nil {
+		return grant, errors.Wrap(err, "failed to decode openid4vci grant")
+	}
+	return grant, nil
+}
+
+// markUsed records the single use of a code or token, failing with used
+// when it was used before.
+func (as *AgentService) markUsed(ctx context.Context, key string, ttl time.Duration, used error) error {
+	stored, err := as.openID4VCIStore.PutIdempotency(ctx, storage.IdempotencyRecord{
+		Key:       key,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return errors.Wrap(used, "already used")
+	}
+	return nil
+}
+
+// hashToken keys codes and tokens by their hash, so the store doesn't
+// hold usable secrets.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func randomDigits(n int) (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	v, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", n, v), nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func newOpenID4VCIAgent() *AgentService {
+	rs := NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{},
+		WithOwnerDIDMethods(map[string][]string{"*": {"iden3", "polygonid"}}))
+	return NewAgentService(rs, nil, WithOpenID4VCI(memory.NewStore(), OpenID4VCISettings{
+		CredentialIssuer: "https://refresh.example.com/",
+	}))
+}
+
+func TestCreateCredentialOffer(t *testing.T) {
+	as := newOpenID4VCIAgent()
+	ctx := context.Background()
+
+	offer, err := as.CreateCredentialOffer(ctx, CredentialOfferRequest{
+		Issuer: "did:iden3:issuer", Owner: "did:iden3:owner", CredentialID: "urn:uuid:1", TxCode: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "https://refresh.example.com", offer.Offer.CredentialIssuer)
+	require.True(t, strings.HasPrefix(offer.OfferURI, "openid-credential-offer://?credential_offer="))
+	require.Len(t, offer.TxCode, txCodeLength)
+	grant := offer.Offer.Grants[PreAuthorizedCodeGrantType]
+	require.NotEmpty(t, grant.PreAuthorizedCode)
+	require.Equal(t, &TxCodeInput{InputMode: "numeric", Length: txCodeLength}, grant.TxCode)
+
+	_, err = as.CreateCredentialOffer(ctx, CredentialOfferRequest{
+		Issuer: "did:iden3:issuer", Owner: "did:ethr:0xab16a96D359eC26a11e2C2b3d8f8B8942d5Bfcdb", CredentialID: "urn:uuid:1",
+	})
+	require.ErrorIs(t, err, ErrOwnerMethodNotSupported)
+
+	_, err = as.CreateCredentialOffer(ctx, CredentialOfferRequest{Issuer: "did:iden3:issuer"})
+	require.ErrorIs(t, err, ErrInvalidProtocolMessage)
+}
+
+func TestExchangePreAuthorizedCode(t *testing.T) {
+	as := newOpenID4VCIAgent()
+	ctx := context.Background()
+	request := CredentialOfferRequest{Issuer: "did:iden3:issuer", Owner: "did:iden3:owner", CredentialID: "urn:uuid:1"}
+
+	offer, err := as.CreateCredentialOffer(ctx, request)
+	require.NoError(t, err)
+	code := offer.Offer.Grants[PreAuthorizedCodeGrantType].PreAuthorizedCode
+	token, err := as.ExchangePreAuthorizedCode(ctx, code, "")
+	require.NoError(t, err)
+	require.Equal(t, "Bearer", token.TokenType)
+	require.EqualValues(t, 300, token.ExpiresIn)
+	require.NotEmpty(t, token.AccessToken)
+
+	// codes are redeemed once
+	_, err = as.ExchangePreAuthorizedCode(ctx, code, "")
+	require.ErrorIs(t, err, ErrInvalidGrant)
+	_, err = as.ExchangePreAuthorizedCode(ctx, "unknown", "")
+	require.ErrorIs(t, err, ErrInvalidGrant)
+
+	// a wrong transaction code burns the code
+	request.TxCode = true
+	offer, err = as.CreateCredentialOffer(ctx, request)
+	require.NoError(t, err)
+	code = offer.Offer.Grants[PreAuthorizedCodeGrantType].PreAuthorizedCode
+	wrong := "000000"
+	if offer.TxCode == wrong {
+		wrong = "111111"
+	}
+	_, err = as.ExchangePreAuthorizedCode(ctx, code, wrong)
+	require.ErrorIs(t, err, ErrInvalidGrant)
+	_, err = as.ExchangePreAuthorizedCode(ctx, code, offer.TxCode)
+	require.ErrorIs(t, err, ErrInvalidGrant)
+
+	_, err = as.IssueOpenID4VCICredential(ctx, "unknown", CredentialRequest{})
+	require.ErrorIs(t, err, ErrInvalidAccessToken)
+	_, err = as.IssueOpenID4VCICredential(ctx, token.AccessToken, CredentialRequest{Format: "mso_mdoc"})
+	require.ErrorIs(t, err, ErrUnsupportedCredentialFormat)
+}
+
+func TestCredentialConfiguration(t *testing.T) {
+	tests := []struct {
+		name        string
+		request     CredentialRequest
+		expected    string
+		expectedErr error
+	}{
+		{name: "Default", expected: OpenID4VCIConfigurationW3C},
+		{
+			name:     "Configuration id",
+			request:  CredentialRequest{CredentialConfigurationID: OpenID4VCIConfigurationJWT},
+			expected: OpenID4VCIConfigurationJWT,
+		},
+		{name: "Format", request: CredentialRequest{Format: "ldp_vc"}, expected: OpenID4VCIConfigurationW3C},
+		{
+			name:        "Unknown configuration id",
+			request:     CredentialRequest{CredentialConfigurationID: "mdl"},
+			expectedErr: ErrUnsupportedCredentialFormat,
+		},
+		{
+			name:        "Unknown format",
+			request:     CredentialRequest{Format: "mso_mdoc"},
+			expectedErr: ErrUnsupportedCredentialFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configuration, err := credentialConfiguration(tt.request)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, configuration)
+		})
+	}
+}
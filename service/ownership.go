@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"github.com/0xPolygonID/refresh-service/didresolver"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+var ErrOwnerMethodNotSupported = errors.New("owner DID method is not supported by the issuer")
+
+// OwnershipVerifier checks that owner, the authenticated sender of a refresh
+// request, holds credential. Verifiers needing more than the owner DID, such
+// as a proof or a session, read it from ctx.
+type OwnershipVerifier interface {
+	VerifyOwnership(ctx context.Context, credential *verifiable.W3CCredential, owner string) error
+}
+
+// SubjectOwnership is the default verifier: owner must be the credential
+// subject id.
+type SubjectOwnership struct{}
+
+func (SubjectOwnership) VerifyOwnership(_ context.Context, credential *verifiable.W3CCredential, owner string) error {
+	if credential == nil {
+		return errors.New("nil credential")
+	}
+
+	if credential.CredentialSubject == nil {
+		return errors.New("credential subject is nil")
+	}
+
+	idValue, exists := credential.CredentialSubject["id"]
+	if !exists {
+		return errors.New("credential subject does not have an id field")
+	}
+
+	if idValue != owner {
+		return errors.New("not owner of the credential")
+	}
+	return nil
+}
+
+// WithOwnershipVerifier replaces the check that the requester owns the
+// credential.
+func WithOwnershipVerifier(verifier OwnershipVerifier) RefreshOption {
+	return func(rs *RefreshService) {
+		if verifier != nil {
+			rs.ownership = verifier
+		}
+	}
+}
+
+// WithOwnerDIDMethods sets the DID methods of the owners an issuer node
+// accepts, per issuer DID. The '*' key applies to all other issuers.
+// Issuers without methods accept owners of any method.
+func WithOwnerDIDMethods(methods map[string][]string) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.ownerMethods = methods
+	}
+}
+
+// CheckOwnerMethod returns ErrOwnerMethodNotSupported when the issuer
+// doesn't accept owners of the DID method of owner.
+func (rs *RefreshService) CheckOwnerMethod(issuer, owner string) error {
+	methods, ok := rs.ownerMethods[issuer]
+	if !ok {
+		methods = rs.ownerMethods["*"]
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+	method, err := didresolver.Method(owner)
+	if err != nil {
+		return errors.Wrap(ErrOwnerMethodNotSupported, err.Error())
+	}
+	if !slices.Contains(methods, method) {
+		return errors.Wrapf(ErrOwnerMethodNotSupported, "'%s' of issuer '%s'", method, issuer)
+	}
+	return nil
+}
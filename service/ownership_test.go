@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubjectOwnership(t *testing.T) {
+	tests := []struct {
+		name        string
+		subject     map[string]interface{}
+		owner       string
+		expectedErr bool
+	}{
+		{
+			name:    "Owner is subject",
+			subject: map[string]interface{}{"id": "did:iden3:owner"},
+			owner:   "did:iden3:owner",
+		},
+		{
+			name:        "Other owner",
+			subject:     map[string]interface{}{"id": "did:iden3:owner"},
+			owner:       "did:iden3:other",
+			expectedErr: true,
+		},
+		{
+			name:        "No subject id",
+			subject:     map[string]interface{}{},
+			owner:       "did:iden3:owner",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SubjectOwnership{}.VerifyOwnership(context.Background(),
+				&verifiable.W3CCredential{CredentialSubject: tt.subject}, tt.owner)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+type sessionOwnership struct {
+	owners []string
+}
+
+func (s *sessionOwnership) VerifyOwnership(_ context.Context, _ *verifiable.W3CCredential, owner string) error {
+	s.owners = append(s.owners, owner)
+	return errors.New("session expired")
+}
+
+func TestWithOwnershipVerifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"vc": {
+			"id": "urn:uuid:1",
+			"issuer": "did:iden3:issuer",
+			"type": ["VerifiableCredential", "Balance"],
+			"expirationDate": %q,
+			"refreshService": {"id": "https://refresh.example.com", "type": "Iden3RefreshService2023"},
+			"credentialSubject": {"id": "did:iden3:owner", "type": "Balance", "balance": 1}
+		}}`, time.Now().Add(-time.Hour).Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	verifier := &sessionOwnership{}
+	is := NewIssuerService(map[string]string{"*": srv.URL}, nil, srv.Client())
+	rs := NewRefreshService(is, &slowDocumentLoader{}, flexiblehttp.FactoryFlexibleHTTP{},
+		WithOwnershipVerifier(verifier))
+
+	_, err := rs.Simulate(context.Background(), "did:iden3:issuer", "did:iden3:owner", "1")
+	require.ErrorIs(t, err, ErrCredentialNotUpdatable)
+	require.ErrorContains(t, err, "session expired")
+	require.Equal(t, []string{"did:iden3:owner"}, verifier.owners)
+}
+
+func TestCheckOwnerMethod(t *testing.T) {
+	rs := NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{},
+		WithOwnerDIDMethods(map[string][]string{
+			"did:iden3:web3":   {"iden3", "ethr", "web"},
+			"did:iden3:legacy": nil,
+			"*":                {"iden3", "polygonid"},
+		}))
+
+	tests := []struct {
+		name        string
+		issuer      string
+		owner       string
+		expectedErr bool
+	}{
+		{
+			name:   "Default method",
+			issuer: "did:iden3:other",
+			owner:  "did:polygonid:polygon:amoy:2qQ68JkRcf3xrHPQPWZei3YeVzHPP58wYNxx2mEouR",
+		},
+		{
+			name:        "Method not accepted by default",
+			issuer:      "did:iden3:other",
+			owner:       "did:ethr:0xab16a96D359eC26a11e2C2b3d8f8B8942d5Bfcdb",
+			expectedErr: true,
+		},
+		{
+			name:   "Method accepted by issuer",
+			issuer: "did:iden3:web3",
+			owner:  "did:web:example.com",
+		},
+		{
+			name:        "Not a DID",
+			issuer:      "did:iden3:web3",
+			owner:       "alice",
+			expectedErr: true,
+		},
+		{
+			name:   "Issuer accepting any method",
+			issuer: "did:iden3:legacy",
+			owner:  "did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rs.CheckOwnerMethod(tt.issuer, tt.owner)
+			if tt.expectedErr {
+				require.True(t, errors.Is(err, ErrOwnerMethodNotSupported), err)
+				require.Equal(t, CodeOwnerMethodNotSupported, ErrorCode(err))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// EligibilityPolicy decides whether a credential may be refreshed at now.
+// Credentials without an expiration or a subject id are never refreshed,
+// the policy only sees credentials which have both.
+type EligibilityPolicy interface {
+	Eligible(credential *verifiable.W3CCredential, now time.Time) error
+}
+
+// ExpirationPolicy is the default policy: credentials are refreshed once
+// they expire. Skew treats credentials expiring within it as expired, so
+// wallets with clocks slightly ahead can refresh right at expiry.
+type ExpirationPolicy struct {
+	Skew time.Duration
+}
+
+func (p ExpirationPolicy) Eligible(credential *verifiable.W3CCredential, now time.Time) error {
+	if credential.Expiration.After(now.Add(p.Skew)) {
+		return errors.Errorf("not expired until %s", credential.Expiration.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// WithEligibilityPolicy replaces the expiration check deciding which
+// credentials are refreshed. It applies to the default UpdatabilityPolicy
+// only.
+func WithEligibilityPolicy(policy EligibilityPolicy) RefreshOption {
+	return func(rs *RefreshService) {
+		if policy != nil {
+			rs.policy = policy
+		}
+	}
+}
+
+// UpdatabilityPolicy decides whether credential can be refreshed at now,
+// before its data provider is called. Policies needing more than the
+// credential, such as whether the subscription of the subject is active or
+// its KYC tier, look it up with ctx. A refusal fails the refresh with
+// ErrCredentialNotUpdatable.
+type UpdatabilityPolicy interface {
+	Updatable(ctx context.Context, credential *verifiable.W3CCredential, now time.Time) error
+}
+
+// UpdatabilityFunc adapts a function to an UpdatabilityPolicy.
+type UpdatabilityFunc func(ctx context.Context, credential *verifiable.W3CCredential, now time.Time) error
+
+func (f UpdatabilityFunc) Updatable(ctx context.Context, credential *verifiable.W3CCredential, now time.Time) error {
+	return f(ctx, credential, now)
+}
+
+// DefaultUpdatability is the default policy: the credential needs an
+// expiration and a subject id, and Eligibility, ExpirationPolicy when nil,
+// must allow the refresh.
+type DefaultUpdatability struct {
+	Eligibility EligibilityPolicy
+}
+
+func (p DefaultUpdatability) Updatable(_ context.Context, credential *verifiable.W3CCredential, now time.Time) error {
+	eligibility := p.Eligibility
+	if eligibility == nil {
+		eligibility = ExpirationPolicy{}
+	}
+	return isUpdatable(credential, eligibility, now)
+}
+
+// AllUpdatable allows a refresh when every policy allows it. Policies are
+// asked in order and the first refusal is returned, so business rules can
+// be layered over DefaultUpdatability without repeating it.
+func AllUpdatable(policies ...UpdatabilityPolicy) UpdatabilityPolicy {
+	return UpdatabilityFunc(func(ctx context.Context, credential *verifiable.W3CCredential, now time.Time) error {
+		for _, policy := range policies {
+			if err := policy.Updatable(ctx, credential, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WithUpdatabilityPolicy replaces the decision whether a credential can be
+// refreshed, DefaultUpdatability with the eligibility policy of the service
+// by default.
+func WithUpdatabilityPolicy(policy UpdatabilityPolicy) RefreshOption {
+	return func(rs *RefreshService) {
+		if policy != nil {
+			rs.updatability = policy
+		}
+	}
+}
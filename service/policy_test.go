@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type subscriptionKey struct{}
+
+// activeSubscription refuses subjects whose subscription in ctx is not
+// active.
+var activeSubscription = UpdatabilityFunc(func(ctx context.Context, credential *verifiable.W3CCredential, _ time.Time) error {
+	if active, _ := ctx.Value(subscriptionKey{}).(bool); !active {
+		return errors.Errorf("subscription of '%v' is not active", credential.CredentialSubject["id"])
+	}
+	return nil
+})
+
+func TestUpdatabilityPolicy(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	notExpired := time.Now().Add(time.Hour)
+	newCredential := func(expiration time.Time, subject map[string]interface{}) *verifiable.W3CCredential {
+		return &verifiable.W3CCredential{ID: "urn:uuid:1", Expiration: &expiration, CredentialSubject: subject}
+	}
+
+	tests := []struct {
+		name        string
+		policy      UpdatabilityPolicy
+		credential  *verifiable.W3CCredential
+		active      bool
+		expectedErr string
+	}{
+		{
+			name:       "Default",
+			policy:     DefaultUpdatability{},
+			credential: newCredential(expired, map[string]interface{}{"id": "did:iden3:owner"}),
+		},
+		{
+			name:        "Default without subject id",
+			policy:      DefaultUpdatability{},
+			credential:  newCredential(expired, map[string]interface{}{"type": "KYCAgeCredential"}),
+			expectedErr: "id field missing in credentialSubject",
+		},
+		{
+			name:       "Default with eligibility",
+			policy:     DefaultUpdatability{Eligibility: ExpirationPolicy{Skew: 2 * time.Hour}},
+			credential: newCredential(notExpired, map[string]interface{}{"id": "did:iden3:owner"}),
+		},
+		{
+			name:       "Layered and active",
+			policy:     AllUpdatable(DefaultUpdatability{}, activeSubscription),
+			credential: newCredential(expired, map[string]interface{}{"id": "did:iden3:owner"}),
+			active:     true,
+		},
+		{
+			name:        "Layered and inactive",
+			policy:      AllUpdatable(DefaultUpdatability{}, activeSubscription),
+			credential:  newCredential(expired, map[string]interface{}{"id": "did:iden3:owner"}),
+			expectedErr: "subscription of 'did:iden3:owner' is not active",
+		},
+		{
+			name:        "Layered and not expired",
+			policy:      AllUpdatable(DefaultUpdatability{}, activeSubscription),
+			credential:  newCredential(notExpired, map[string]interface{}{"id": "did:iden3:owner"}),
+			active:      true,
+			expectedErr: "not expired until",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), subscriptionKey{}, tt.active)
+			err := tt.policy.Updatable(ctx, tt.credential, time.Now())
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWithUpdatabilityPolicy(t *testing.T) {
+	expiration := time.Now().Add(-time.Hour)
+	credential := &verifiable.W3CCredential{
+		ID:                "urn:uuid:1",
+		Issuer:            "did:iden3:issuer",
+		Type:              []string{"VerifiableCredential", "KYCAgeCredential"},
+		Expiration:        &expiration,
+		CredentialSubject: map[string]interface{}{"id": "did:iden3:owner", "type": "KYCAgeCredential"},
+	}
+	rs := NewRefreshService(nil, offlineDocumentLoader{}, flexiblehttp.FactoryFlexibleHTTP{},
+		WithUpdatabilityPolicy(AllUpdatable(DefaultUpdatability{}, activeSubscription)))
+
+	eligibility := func(ctx context.Context) InspectionCheck {
+		for _, c := range rs.InspectCredential(ctx, "", "", credential).Checks {
+			if c.Name == "eligibility" {
+				return c
+			}
+		}
+		t.Fatal("no eligibility check")
+		return InspectionCheck{}
+	}
+	require.Equal(t, InspectionCheck{
+		Name:   "eligibility",
+		Detail: "subscription of 'did:iden3:owner' is not active",
+	}, eligibility(context.Background()))
+	require.True(t, eligibility(context.WithValue(context.Background(), subscriptionKey{}, true)).Passed)
+}
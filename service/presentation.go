@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// JWT algorithms of presentations: ES256K signatures are r||s, ES256K-R
+// ones carry the recovery id as a 65th byte.
+const (
+	algES256K  = "ES256K"
+	algES256KR = "ES256K-R"
+)
+
+// PresentationRefreshRequest proves ownership of the credential with a
+// Verifiable Presentation of it signed by the holder. It is an alternative
+// to iden3comm for integrations which already present credentials.
+type PresentationRefreshRequest struct {
+	Issuer       string `json:"issuer"`
+	CredentialID string `json:"credentialId"`
+	// Presentation is a JWT VP signed with ES256K or ES256K-R by an
+	// Ethereum key controlling the holder DID.
+	Presentation string `json:"presentation"`
+}
+
+// WithPresentationAudience accepts presentations addressed to audience,
+// e.g. the URL of the refresh service. Without an audience presentations
+// are rejected, so ones made for other verifiers can't be replayed here.
+func WithPresentationAudience(audience string) AgentOption {
+	return func(as *AgentService) {
+		as.presentationAudience = audience
+	}
+}
+
+// audience is the JWT aud claim, a string or a list of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+type presentationClaims struct {
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ID        string   `json:"jti"`
+	NotBefore int64    `json:"nbf"`
+	ExpiresAt int64    `json:"exp"`
+	VP        struct {
+		Holder               string            `json:"holder"`
+		VerifiableCredential []json.RawMessage `json:"verifiableCredential"`
+	} `json:"vp"`
+}
+
+// presentation is a parsed JWT VP.
+type presentation struct {
+	alg          string
+	claims       presentationClaims
+	signingInput []byte
+	signature    []byte
+}
+
+// presentedCredential is what is read of a presented credential, in JSON
+// form or as a JWT VC.
+type presentedCredential struct {
+	ID  string `json:"id"`
+	JTI string `json:"jti"`
+	VC  struct {
+		ID string `json:"id"`
+	} `json:"vc"`
+}
+
+// ProcessPresentation refreshes a credential for a request proving
+// ownership with a Verifiable Presentation.
+func (as *AgentService) ProcessPresentation(ctx context.Context, request PresentationRefreshRequest) (*RefreshResult, error) {
+	if request.Issuer == "" || request.CredentialID == "" || request.Presentation == "" {
+		return nil, errors.Wrap(ErrInvalidProtocolMessage, "missing required fields in presentation refresh request")
+	}
+	if as.presentationAudience == "" {
+		return nil, errors.Wrap(ErrInvalidProtocolMessage, "presentations are not accepted without an audience")
+	}
+	p, err := parsePresentation(request.Presentation)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := as.checkProofLifetime(p.claims.ExpiresAt, now); err != nil {
+		return nil, err
+	}
+	owner := p.claims.Issuer
+	// owners of methods the issuer doesn't accept are not resolved at all
+	if err := as.refreshService.CheckOwnerMethod(request.Issuer, owner); err != nil {
+		return nil, err
+	}
+	if err := as.verifyPresentation(ctx, p, request.CredentialID, now); err != nil {
+		return nil, err
+	}
+	if err := as.rememberMessage(ctx, owner, p.claims.ID, ""); err != nil {
+		return nil, err
+	}
+	return as.refreshOwned(ctx, request.Issuer, owner, request.CredentialID)
+}
+
+func parsePresentation(jwt string) (*presentation, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return nil, errors.Wrap(ErrInvalidProtocolMessage, "presentation is not a JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.Wrapf(ErrInvalidProtocolMessage, "invalid presentation header: %v", err)
+	}
+	p := &presentation{alg: header.Alg, signingInput: []byte(parts[0] + "." + parts[1])}
+	if err := decodeSegment(parts[1], &p.claims); err != nil {
+		return nil, errors.Wrapf(ErrInvalidProtocolMessage, "invalid presentation claims: %v", err)
+	}
+	if p.claims.Issuer == "" || len(p.claims.VP.VerifiableCredential) == 0 {
+		return nil, errors.Wrap(ErrInvalidProtocolMessage, "presentation has no holder or no credential")
+	}
+	var err error
+	if p.signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, errors.Wrap(ErrInvalidOwnershipProof, "invalid presentation signature encoding")
+	}
+	return p, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// verifyPresentation checks that the presentation is addressed to the
+// service, valid at now, presents the requested credential and is signed
+// by an Ethereum account controlling the holder DID.
+func (as *AgentService) verifyPresentation(ctx context.Context, p *presentation, credentialID string, now time.Time) error {
+	claims := p.claims
+	if claims.VP.Holder != "" && claims.VP.Holder != claims.Issuer {
+		return errors.Wrap(ErrInvalidOwnershipProof, "presentation holder is not its issuer")
+	}
+	if !slices.Contains(claims.Audience, as.presentationAudience) {
+		return errors.Wrap(ErrInvalidOwnershipProof, "presentation is addressed to another audience")
+	}
+	if claims.ExpiresAt == 0 || now.Unix() > claims.ExpiresAt {
+		return errors.Wrap(ErrInvalidOwnershipProof, "presentation expired or has no expiration")
+	}
+	if claims.NotBefore != 0 && now.Unix() < claims.NotBefore {
+		return errors.Wrap(ErrInvalidOwnershipProof, "presentation is not valid yet")
+	}
+	if !presents(claims.VP.VerifiableCredential, credentialID) {
+		return errors.Wrap(ErrInvalidOwnershipProof, "presentation does not include the credential")
+	}
+
+	holder, err := as.didResolver.Resolve(ctx, claims.Issuer)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidOwnershipProof, "failed to resolve holder DID: %v", err)
+	}
+	hash := sha256.Sum256(p.signingInput)
+	signature := p.signature
+	var recoveryIDs []byte
+	switch {
+	case p.alg == algES256K && len(signature) == 64:
+		recoveryIDs = []byte{0, 1}
+	case p.alg == algES256KR && len(signature) == crypto.SignatureLength:
+		recoveryIDs = []byte{signature[crypto.RecoveryIDOffset] % 27}
+		signature = signature[:64]
+	default:
+		return errors.Wrapf(ErrInvalidOwnershipProof, "unsupported presentation algorithm '%s'", p.alg)
+	}
+	for _, v := range recoveryIDs {
+		pub, err := crypto.SigToPub(hash[:], append(slices.Clone(signature), v))
+		if err == nil && holder.Controls(crypto.PubkeyToAddress(*pub)) {
+			return nil
+		}
+	}
+	return errors.Wrap(ErrInvalidOwnershipProof, "presentation is not signed by the holder")
+}
+
+// presents reports whether credentials include the credential id. Only the
+// id is read: the credential itself is fetched from the issuer node.
+func presents(credentials []json.RawMessage, id string) bool {
+	for _, raw := range credentials {
+		var c presentedCredential
+		var jwt string
+		if err := json.Unmarshal(raw, &jwt); err == nil {
+			parts := strings.Split(jwt, ".")
+			if len(parts) != 3 || decodeSegment(parts[1], &c) != nil {
+				continue
+			}
+		} else if err := json.Unmarshal(raw, &c); err != nil {
+			continue
+		}
+		for _, presented := range []string{c.ID, c.JTI, c.VC.ID} {
+			if presented != "" && convertID(presented) == convertID(id) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/didresolver"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const testAudience = "https://refresh.example.com"
+
+func signPresentation(t *testing.T, alg string, claims map[string]interface{}, key []byte) string {
+	t.Helper()
+	privateKey, err := crypto.ToECDSA(key)
+	require.NoError(t, err)
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+	sig, err := crypto.Sign(hash[:], privateKey)
+	require.NoError(t, err)
+	if alg == algES256K {
+		sig = sig[:64]
+	} else {
+		sig[crypto.RecoveryIDOffset] += 27
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifyPresentation(t *testing.T) {
+	holderKey := hexutil.MustDecode("0x4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	otherKey := hexutil.MustDecode("0x6cbed15c793ce57650b9877cf6fa156fbef513c4e6134f022a85b1ffdd59b2a1")
+	privateKey, err := crypto.ToECDSA(holderKey)
+	require.NoError(t, err)
+	holder := "did:ethr:amoy:" + crypto.PubkeyToAddress(privateKey.PublicKey).Hex()
+	credentialID := "urn:uuid:7a1e6b2c-8f0d-4c3a-9b5e-2d1f0a9c8b7e"
+	now := time.Unix(1700000000, 0)
+
+	newClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": holder,
+			"aud": []string{testAudience},
+			"jti": "urn:uuid:0b5c6f4e-3d2a-4e1f-8c7b-6a5d4c3b2a10",
+			"exp": now.Add(time.Minute).Unix(),
+			"vp": map[string]interface{}{
+				"holder":               holder,
+				"verifiableCredential": []interface{}{map[string]interface{}{"id": credentialID}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		alg         string
+		claims      func() map[string]interface{}
+		key         []byte
+		expectedErr error
+	}{
+		{
+			name:   "Signed by holder",
+			alg:    algES256K,
+			claims: newClaims,
+			key:    holderKey,
+		},
+		{
+			name:   "Recoverable signature",
+			alg:    algES256KR,
+			claims: newClaims,
+			key:    holderKey,
+		},
+		{
+			name: "JWT credential",
+			alg:  algES256K,
+			claims: func() map[string]interface{} {
+				c := newClaims()
+				vc := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256K"}`)) + "." +
+					base64.RawURLEncoding.EncodeToString([]byte(`{"jti":"`+credentialID+`"}`)) + ".c2ln"
+				c["vp"] = map[string]interface{}{"verifiableCredential": []string{vc}}
+				return c
+			},
+			key: holderKey,
+		},
+		{
+			name:        "Signed by other key",
+			alg:         algES256K,
+			claims:      newClaims,
+			key:         otherKey,
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+		{
+			name: "Other audience",
+			alg:  algES256K,
+			claims: func() map[string]interface{} {
+				c := newClaims()
+				c["aud"] = "https://verifier.example.com"
+				return c
+			},
+			key:         holderKey,
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+		{
+			name: "Expired",
+			alg:  algES256K,
+			claims: func() map[string]interface{} {
+				c := newClaims()
+				c["exp"] = now.Add(-time.Second).Unix()
+				return c
+			},
+			key:         holderKey,
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+		{
+			name: "Other credential",
+			alg:  algES256K,
+			claims: func() map[string]interface{} {
+				c := newClaims()
+				c["vp"] = map[string]interface{}{
+					"verifiableCredential": []interface{}{map[string]interface{}{"id": "urn:uuid:other"}},
+				}
+				return c
+			},
+			key:         holderKey,
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+		{
+			name: "Holder is not the signer",
+			alg:  algES256K,
+			claims: func() map[string]interface{} {
+				c := newClaims()
+				c["vp"].(map[string]interface{})["holder"] = "did:ethr:0x0000000000000000000000000000000000000001"
+				return c
+			},
+			key:         holderKey,
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+		{
+			name:        "Unsupported algorithm",
+			alg:         "ES256",
+			claims:      newClaims,
+			key:         holderKey,
+			expectedErr: ErrInvalidOwnershipProof,
+		},
+	}
+
+	as := &AgentService{
+		didResolver:          didresolver.NewDefaultRegistry(nil),
+		presentationAudience: testAudience,
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePresentation(signPresentation(t, tt.alg, tt.claims(), tt.key))
+			require.NoError(t, err)
+			err = as.verifyPresentation(context.Background(), p, credentialID, now)
+			if tt.expectedErr != nil {
+				require.True(t, errors.Is(err, tt.expectedErr), err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestProcessPresentation_Rejected(t *testing.T) {
+	holderKey := hexutil.MustDecode("0x4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	claims := map[string]interface{}{
+		"iss": "did:ethr:0xab16a96D359eC26a11e2C2b3d8f8B8942d5Bfcdb",
+		"aud": testAudience,
+		"exp": time.Now().Add(time.Minute).Unix(),
+		"vp": map[string]interface{}{
+			"verifiableCredential": []interface{}{map[string]interface{}{"id": "urn:uuid:1"}},
+		},
+	}
+	longLived := maps.Clone(claims)
+	longLived["exp"] = time.Now().Add(365 * 24 * time.Hour).Unix()
+	rs := NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{},
+		WithOwnerDIDMethods(map[string][]string{"*": {"iden3", "polygonid"}}))
+
+	tests := []struct {
+		name         string
+		audience     string
+		replayTTL    time.Duration
+		request      PresentationRefreshRequest
+		expectedCode int
+	}{
+		{
+			name:     "Not a JWT",
+			audience: testAudience,
+			request: PresentationRefreshRequest{
+				Issuer: "did:iden3:issuer", CredentialID: "urn:uuid:1", Presentation: "not-a-jwt",
+			},
+			expectedCode: CodeInvalidProtocolMessage,
+		},
+		{
+			name: "No audience configured",
+			request: PresentationRefreshRequest{
+				Issuer: "did:iden3:issuer", CredentialID: "urn:uuid:1",
+				Presentation: signPresentation(t, algES256K, claims, holderKey),
+			},
+			expectedCode: CodeInvalidProtocolMessage,
+		},
+		{
+			name:      "No replay protection",
+			audience:  testAudience,
+			replayTTL: -1,
+			request: PresentationRefreshRequest{
+				Issuer: "did:iden3:issuer", CredentialID: "urn:uuid:1",
+				Presentation: signPresentation(t, algES256K, longLived, holderKey),
+			},
+			expectedCode: CodeInvalidProtocolMessage,
+		},
+		{
+			name:     "Outlives replay record",
+			audience: testAudience,
+			request: PresentationRefreshRequest{
+				Issuer: "did:iden3:issuer", CredentialID: "urn:uuid:1",
+				Presentation: signPresentation(t, algES256K, longLived, holderKey),
+			},
+			expectedCode: CodeInvalidOwnershipProof,
+		},
+		{
+			name:     "Owner method not accepted",
+			audience: testAudience,
+			request: PresentationRefreshRequest{
+				Issuer: "did:iden3:issuer", CredentialID: "urn:uuid:1",
+				Presentation: signPresentation(t, algES256K, claims, holderKey),
+			},
+			expectedCode: CodeOwnerMethodNotSupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replayTTL := time.Hour
+			if tt.replayTTL != 0 {
+				replayTTL = tt.replayTTL
+			}
+			as := NewAgentService(rs, nil,
+				WithPresentationAudience(tt.audience), WithReplayProtection(memory.NewStore(), replayTTL))
+			_, err := as.ProcessPresentation(context.Background(), tt.request)
+			require.Error(t, err)
+			require.Equal(t, tt.expectedCode, ErrorCode(err))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/iden3/iden3comm/v2"
+	"github.com/iden3/iden3comm/v2/packers"
+	iden3Protocol "github.com/iden3/iden3comm/v2/protocol"
+	"github.com/pkg/errors"
+)
+
+// ProblemReportError is a failed message answered with a problem-report.
+// Err is the failure, Report the packed problem-report message to send
+// back instead of a plain error.
+type ProblemReportError struct {
+	Err    error
+	Report []byte
+}
+
+func (e *ProblemReportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProblemReportError) Unwrap() error {
+	return e.Err
+}
+
+// WithProblemReports answers messages which fail to refresh with an
+// iden3comm problem-report carrying the refresh service error code.
+func WithProblemReports() AgentOption {
+	return func(as *AgentService) {
+		as.problemReports = true
+	}
+}
+
+// problemDescriptors are the problem-report descriptors of the error codes,
+// the generic DIDComm descriptor first.
+var problemDescriptors = map[int][]string{
+	CodeInvalidRequestSchema:    {iden3Protocol.ReportDescriptorMe, "invalid-request-schema"},
+	CodeInvalidResponseSchema:   {iden3Protocol.ReportDescriptorMe, "invalid-response-schema"},
+	CodeDataProviderIssue:       {iden3Protocol.ReportDescriptorTransport, "data-provider-issue"},
+	CodeInvalidProtocolMessage:  {iden3Protocol.ReportDescriptorMsg, "invalid-protocol-message"},
+	CodeInvalidProtocolResponse: {iden3Protocol.ReportDescriptorMe, "invalid-protocol-response"},
+	CodeReplayedMessage:         {iden3Protocol.ReportDescriptorMsg, "replayed-message"},
+	CodeInvalidOwnershipProof:   {iden3Protocol.ReportDescriptorTrustCrypto, "invalid-ownership-proof"},
+	CodeInvalidGrant:            {iden3Protocol.ReportDescriptorReq, "invalid-grant"},
+	CodeInvalidAccessToken:      {iden3Protocol.ReportDescriptorReq, "invalid-access-token"},
+	CodeUnsupportedFormat:       {iden3Protocol.ReportDescriptorReq, "unsupported-credential-format"},
+	CodeInvalidOwnerSession:     {iden3Protocol.ReportDescriptorReq, "invalid-owner-session"},
+	CodeIssuerNotSupported:      {iden3Protocol.ReportDescriptorDID, "issuer-not-supported"},
+	CodeGetClaim:                {iden3Protocol.ReportDescriptorTransport, "get-claim"},
+	CodeCreateClaim:             {iden3Protocol.ReportDescriptorTransport, "create-claim"},
+	CodeOwnerMethodNotSupported: {iden3Protocol.ReportDescriptorDID, "owner-method-not-supported"},
+	CodeCredentialNotUpdatable:  {iden3Protocol.ReportDescriptorReq, "credential-not-updatable"},
+	CodeRefreshInProgress:       {iden3Protocol.ReportDescriptorReq, "refresh-in-progress"},
+	CodeQuotaExceeded:           {iden3Protocol.ReportDescriptorReq, "quota-exceeded"},
+	CodeRefreshTimeout:          {iden3Protocol.ReportDescriptorReqTime, "refresh-timeout"},
+	CodeSubjectTooLarge:         {iden3Protocol.ReportDescriptorReq, "subject-too-large"},
+	CodeRevocationNonceConflict: {iden3Protocol.ReportDescriptorReq, "revocation-nonce-conflict"},
+	CodeInternal:                {iden3Protocol.ReportDescriptorMe, "internal"},
+}
+
+// ProblemCode returns the problem-report code of err, e.g.
+// 'e.p.req.credential-not-updatable'.
+func ProblemCode(err error) iden3Protocol.ProblemErrorCode {
+	descriptors, ok := problemDescriptors[ErrorCode(err)]
+	if !ok {
+		descriptors = problemDescriptors[CodeInternal]
+	}
+	// descriptors such as 'req.time' hold several parts
+	parts := strings.Split(strings.Join(descriptors, "."), ".")
+	code, _ := iden3Protocol.NewProblemReportErrorCode(iden3Protocol.ProblemReportTypeError, "p", parts)
+	return code
+}
+
+// problemReport returns err with the problem-report answering message. The
+// report can't be built when packing fails, err is returned as it is then.
+func (as *AgentService) problemReport(message *iden3comm.BasicMessage, err error) error {
+	threadID := message.ThreadID
+	if threadID == "" {
+		threadID = message.ID
+	}
+	report := iden3Protocol.ProblemReportMessage{
+		ID:             uuid.New().String(),
+		Typ:            packers.MediaTypePlainMessage,
+		Type:           iden3Protocol.ProblemReportMessageType,
+		ParentThreadID: threadID,
+		Body: iden3Protocol.ProblemReportMessageBody{
+			Code:    ProblemCode(err),
+			Comment: "refresh failed with code {1}: {2}",
+			Args:    []string{strconv.Itoa(ErrorCode(err)), err.Error()},
+		},
+		From: message.To,
+		To:   message.From,
+	}
+	payload, marshalErr := json.Marshal(report)
+	if marshalErr != nil {
+		return err
+	}
+	envelope, packErr := as.packageManager.Pack(packers.MediaTypePlainMessage, payload, nil)
+	if packErr != nil {
+		return errors.WithMessagef(err, "failed to pack problem report: %v", packErr)
+	}
+	return &ProblemReportError{Err: err, Report: envelope}
+}
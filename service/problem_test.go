@@ -0,0 +1,76 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/iden3/iden3comm/v2"
+	"github.com/iden3/iden3comm/v2/packers"
+	iden3Protocol "github.com/iden3/iden3comm/v2/protocol"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected iden3Protocol.ProblemErrorCode
+	}{
+		{
+			name:     "Not updatable",
+			err:      errors.Wrap(ErrCredentialNotUpdatable, "credential '1'"),
+			expected: "e.p.req.credential-not-updatable",
+		},
+		{
+			name:     "Timeout",
+			err:      errors.Wrap(ErrRefreshTimeout, "credential '1'"),
+			expected: "e.p.req.time.refresh-timeout",
+		},
+		{
+			name:     "Ownership proof",
+			err:      ErrInvalidOwnershipProof,
+			expected: "e.p.trust.crypto.invalid-ownership-proof",
+		},
+		{
+			name:     "Internal",
+			err:      errors.New("boom"),
+			expected: "e.p.me.internal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, ProblemCode(tt.err))
+			_, err := iden3Protocol.ParseProblemErrorCode(string(tt.expected))
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestProblemReport(t *testing.T) {
+	pm := iden3comm.NewPackageManager()
+	require.NoError(t, pm.RegisterPackers(&packers.PlainMessagePacker{}))
+	as := NewAgentService(nil, pm, WithProblemReports())
+
+	message := &iden3comm.BasicMessage{ID: "1", ThreadID: "thread-1", From: "did:owner", To: "did:issuer"}
+	refreshErr := errors.Wrap(ErrQuotaExceeded, "owner reached 1 reissues per 24h0m0s")
+	err := as.problemReport(message, refreshErr)
+
+	var problem *ProblemReportError
+	require.ErrorAs(t, err, &problem)
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	require.Equal(t, CodeQuotaExceeded, ErrorCode(err))
+
+	unpacked, _, err := pm.Unpack(problem.Report)
+	require.NoError(t, err)
+	require.Equal(t, iden3Protocol.ProblemReportMessageType, unpacked.Type)
+	require.Equal(t, "did:issuer", unpacked.From)
+	require.Equal(t, "did:owner", unpacked.To)
+
+	var report iden3Protocol.ProblemReportMessage
+	require.NoError(t, json.Unmarshal(problem.Report, &report))
+	require.Equal(t, "thread-1", report.ParentThreadID)
+	require.Equal(t, iden3Protocol.ProblemErrorCode("e.p.req.quota-exceeded"), report.Body.Code)
+	require.Equal(t, []string{"4002", refreshErr.Error()}, report.Body.Args)
+}
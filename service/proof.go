@@ -0,0 +1,78 @@
+package service
+
+import (
+	core "github.com/iden3/go-iden3-core/v2"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+)
+
+// Core claim slots of the merklized root and the subject, as the issuer
+// node takes them.
+const (
+	PositionIndex = "index"
+	PositionValue = "value"
+)
+
+// proofPreferences returns which proofs the reissued credential needs so it
+// satisfies the same wallet queries as the original one. Both are nil when
+// the original credential has no proofs and the issuer node defaults apply.
+func proofPreferences(credential *verifiable.W3CCredential) (signatureProof, mtProof *bool) {
+	if len(credential.Proof) == 0 {
+		return nil, nil
+	}
+	var signature, mtp bool
+	for _, p := range credential.Proof {
+		switch p.ProofType() {
+		case verifiable.BJJSignatureProofType:
+			signature = true
+		case verifiable.Iden3SparseMerkleProofType,
+			verifiable.Iden3SparseMerkleTreeProofType,
+			verifiable.SparseMerkleTreeProofType:
+			mtp = true
+		}
+	}
+	return &signature, &mtp
+}
+
+// claimLayout returns the slots of the merklized root and the subject in
+// the core claim of the original credential, so the reissued one keeps the
+// layout verifier circuits were built for. A position is empty when the
+// claim doesn't hold it or no proof carries the core claim.
+func claimLayout(credential *verifiable.W3CCredential) (merklizedRootPosition, subjectPosition string) {
+	for _, p := range credential.Proof {
+		claim, err := p.GetCoreClaim()
+		if err != nil || claim == nil {
+			continue
+		}
+		positions := claimPositions(claim)
+		return positions.MerklizedRootPosition, positions.SubjectPosition
+	}
+	return "", ""
+}
+
+// ClaimPositions are the slots of the merklized root and the subject id,
+// 'index' or 'value', or empty when the claim doesn't hold them.
+type ClaimPositions struct {
+	MerklizedRootPosition string `json:"merklizedRootPosition,omitempty"`
+	SubjectPosition       string `json:"subjectPosition,omitempty"`
+}
+
+func claimPositions(claim *core.Claim) ClaimPositions {
+	var positions ClaimPositions
+	if position, err := claim.GetMerklizedPosition(); err == nil {
+		switch position {
+		case core.MerklizedRootPositionIndex:
+			positions.MerklizedRootPosition = PositionIndex
+		case core.MerklizedRootPositionValue:
+			positions.MerklizedRootPosition = PositionValue
+		}
+	}
+	if position, err := claim.GetIDPosition(); err == nil {
+		switch position {
+		case core.IDPositionIndex:
+			positions.SubjectPosition = PositionIndex
+		case core.IDPositionValue:
+			positions.SubjectPosition = PositionValue
+		}
+	}
+	return positions
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	core "github.com/iden3/go-iden3-core/v2"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofPreferences(t *testing.T) {
+	yes, no := true, false
+	signature := &verifiable.BJJSignatureProof2021{Type: verifiable.BJJSignatureProofType}
+	mtp := &verifiable.Iden3SparseMerkleTreeProof{Type: verifiable.Iden3SparseMerkleTreeProofType}
+	tests := []struct {
+		name              string
+		proof             verifiable.CredentialProofs
+		expectedSignature *bool
+		expectedMTP       *bool
+	}{
+		{
+			name: "No proofs",
+		},
+		{
+			name:              "Signature only",
+			proof:             verifiable.CredentialProofs{signature},
+			expectedSignature: &yes,
+			expectedMTP:       &no,
+		},
+		{
+			name:              "Signature and MTP",
+			proof:             verifiable.CredentialProofs{signature, mtp},
+			expectedSignature: &yes,
+			expectedMTP:       &yes,
+		},
+		{
+			name:              "MTP only",
+			proof:             verifiable.CredentialProofs{mtp},
+			expectedSignature: &no,
+			expectedMTP:       &yes,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signatureProof, mtProof := proofPreferences(&verifiable.W3CCredential{Proof: tt.proof})
+			require.Equal(t, tt.expectedSignature, signatureProof)
+			require.Equal(t, tt.expectedMTP, mtProof)
+		})
+	}
+}
+
+func TestClaimLayout(t *testing.T) {
+	subject, err := core.IDFromString("2qHYafoww8yJcMhXk5jvgL33QuDGaasaqwjjVUXDP1")
+	require.NoError(t, err)
+	valueClaim, err := core.NewClaim(core.NewSchemaHashFromInt(big.NewInt(1)),
+		core.WithValueID(subject),
+		core.WithMerklizedRoot(big.NewInt(42), core.MerklizedRootPositionValue))
+	require.NoError(t, err)
+	valueClaimHex, err := valueClaim.Hex()
+	require.NoError(t, err)
+
+	var nonMerklized verifiable.W3CCredential
+	require.NoError(t, json.Unmarshal(nonMerklizedCredential, &nonMerklized))
+
+	tests := []struct {
+		name                  string
+		credential            *verifiable.W3CCredential
+		merklizedRootPosition string
+		subjectPosition       string
+	}{
+		{
+			name:       "No proofs",
+			credential: &verifiable.W3CCredential{},
+		},
+		{
+			name:            "Subject in index",
+			credential:      &nonMerklized,
+			subjectPosition: PositionIndex,
+		},
+		{
+			name: "Subject and root in value",
+			credential: &verifiable.W3CCredential{Proof: verifiable.CredentialProofs{
+				&verifiable.BJJSignatureProof2021{Type: verifiable.BJJSignatureProofType, CoreClaim: valueClaimHex},
+			}},
+			merklizedRootPosition: PositionValue,
+			subjectPosition:       PositionValue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merklizedRootPosition, subjectPosition := claimLayout(tt.credential)
+			require.Equal(t, tt.merklizedRootPosition, merklizedRootPosition)
+			require.Equal(t, tt.subjectPosition, subjectPosition)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/pkg/errors"
+)
+
+var ErrQuotaExceeded = errors.New("QUOTA_EXCEEDED")
+
+// Quota limits successful reissues within Window per owner, per owner and
+// credential type and per credential. Zero limits are not enforced.
+type Quota struct {
+	PerOwner          int64
+	PerCredentialType int64
+	PerCredential     int64
+	Window            time.Duration
+}
+
+// WithQuota enforces quota against the refresh history.
+func WithQuota(history storage.RefreshHistory, quota Quota) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.quotaHistory = history
+		rs.quota = quota
+	}
+}
+
+func (rs *RefreshService) checkQuota(ctx context.Context, owner, credentialType, credentialID string) error {
+	if rs.quotaHistory == nil || rs.quota.Window <= 0 {
+		return nil
+	}
+	from := time.Now().Add(-rs.quota.Window)
+
+	if rs.quota.PerOwner > 0 {
+		count, err := rs.quotaHistory.CountRefreshes(ctx, storage.RefreshFilter{
+			Owner:  owner,
+			Status: storage.RefreshStatusSucceeded,
+			From:   from,
+		})
+		if err != nil {
+			return err
+		}
+		if count >= rs.quota.PerOwner {
+			return errors.Wrapf(ErrQuotaExceeded,
+				"owner reached %d reissues per %s", rs.quota.PerOwner, rs.quota.Window)
+		}
+	}
+
+	if rs.quota.PerCredentialType > 0 {
+		count, err := rs.quotaHistory.CountRefreshes(ctx, storage.RefreshFilter{
+			Owner:          owner,
+			CredentialType: credentialType,
+			Status:         storage.RefreshStatusSucceeded,
+			From:           from,
+		})
+		if err != nil {
+			return err
+		}
+		if count >= rs.quota.PerCredentialType {
+			return errors.Wrapf(ErrQuotaExceeded,
+				"credential '%s' reached %d reissues per %s", credentialType, rs.quota.PerCredentialType, rs.quota.Window)
+		}
+	}
+
+	if rs.quota.PerCredential > 0 {
+		count, err := rs.quotaHistory.CountRefreshes(ctx, storage.RefreshFilter{
+			CredentialID: credentialID,
+			Status:       storage.RefreshStatusSucceeded,
+			From:         from,
+		})
+		if err != nil {
+			return err
+		}
+		if count >= rs.quota.PerCredential {
+			return errors.Wrapf(ErrQuotaExceeded,
+				"credential '%s' reached %d reissues per %s", credentialID, rs.quota.PerCredential, rs.quota.Window)
+		}
+	}
+	return nil
+}
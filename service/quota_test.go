@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckQuota(t *testing.T) {
+	now := time.Now()
+	store := &recordingStore{refreshes: []storage.RefreshRecord{
+		{Owner: "alice", CredentialType: "Balance", CredentialID: "first", Status: storage.RefreshStatusSucceeded, CreatedAt: now},
+		{Owner: "alice", CredentialType: "Balance", CredentialID: "second", Status: storage.RefreshStatusSucceeded, CreatedAt: now},
+		{Owner: "alice", CredentialType: "KYC", Status: storage.RefreshStatusSucceeded, CreatedAt: now},
+		{Owner: "alice", CredentialType: "KYC", Status: storage.RefreshStatusFailed, CreatedAt: now},
+		{Owner: "alice", CredentialType: "KYC", Status: storage.RefreshStatusSucceeded, CreatedAt: now.Add(-48 * time.Hour)},
+	}}
+
+	tests := []struct {
+		name           string
+		quota          Quota
+		owner          string
+		credentialType string
+		credentialID   string
+		exceeded       bool
+	}{
+		{
+			name:           "Per credential type quota reached",
+			quota:          Quota{PerCredentialType: 2, Window: 24 * time.Hour},
+			owner:          "alice",
+			credentialType: "Balance",
+			exceeded:       true,
+		},
+		{
+			name:           "Failed and old refreshes are not counted",
+			quota:          Quota{PerCredentialType: 2, Window: 24 * time.Hour},
+			owner:          "alice",
+			credentialType: "KYC",
+		},
+		{
+			name:           "Per owner quota reached",
+			quota:          Quota{PerOwner: 3, Window: 24 * time.Hour},
+			owner:          "alice",
+			credentialType: "KYC",
+			exceeded:       true,
+		},
+		{
+			name:           "Other owner",
+			quota:          Quota{PerOwner: 1, PerCredentialType: 1, Window: 24 * time.Hour},
+			owner:          "bob",
+			credentialType: "Balance",
+		},
+		{
+			name:           "Per credential quota reached",
+			quota:          Quota{PerCredential: 1, Window: 24 * time.Hour},
+			owner:          "alice",
+			credentialType: "Balance",
+			credentialID:   "first",
+			exceeded:       true,
+		},
+		{
+			name:           "Other credential of the same type",
+			quota:          Quota{PerCredential: 1, Window: 24 * time.Hour},
+			owner:          "alice",
+			credentialType: "Balance",
+			credentialID:   "third",
+		},
+		{
+			name:           "Disabled quota",
+			quota:          Quota{Window: 24 * time.Hour},
+			owner:          "alice",
+			credentialType: "Balance",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := &RefreshService{}
+			WithQuota(store, tt.quota)(rs)
+			err := rs.checkQuota(context.Background(), tt.owner, tt.credentialType, tt.credentialID)
+			if tt.exceeded {
+				require.ErrorIs(t, err, ErrQuotaExceeded)
+				require.Equal(t, CodeQuotaExceeded, ErrorCode(err))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -1,13 +1,22 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/0xPolygonID/refresh-service/correlation"
+	"github.com/0xPolygonID/refresh-service/events"
+	"github.com/0xPolygonID/refresh-service/features"
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/lock"
+	"github.com/0xPolygonID/refresh-service/logger"
 	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/0xPolygonID/refresh-service/reporting"
+	"github.com/0xPolygonID/refresh-service/storage"
 	core "github.com/iden3/go-iden3-core/v2"
 	jsonproc "github.com/iden3/go-schema-processor/v2/json"
 	"github.com/iden3/go-schema-processor/v2/merklize"
@@ -15,61 +24,314 @@ import (
 	"github.com/iden3/go-schema-processor/v2/verifiable"
 	"github.com/piprate/json-gold/ld"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
 	ErrCredentialNotUpdatable = errors.New("not updatable")
+	ErrRefreshInProgress      = errors.New("refresh is already in progress")
+	ErrRefreshTimeout         = errors.New("refresh timed out")
 	errIndexSlotsNotUpdated   = errors.New("no index fields were updated")
 )
 
+const defaultContextLoadConcurrency = 4
+
 type RefreshService struct {
 	issuerService  *IssuerService
 	documentLoader ld.DocumentLoader
 	providers      flexiblehttp.FactoryFlexibleHTTP
+	history        storage.RefreshHistory
+	lineage        storage.Lineage
+	locker         lock.Locker
+	lockTTL        time.Duration
+	quotaHistory   storage.RefreshHistory
+	quota          Quota
+
+	refreshServiceTypes    []verifiable.RefreshServiceType
+	emitRefreshServiceType verifiable.RefreshServiceType
+	credentialStatusTypes  map[string]verifiable.CredentialStatusType
+	displayMethods         map[string]verifiable.DisplayMethod
+	contextLoadConcurrency int
+	expirationSkew         time.Duration
+	policy                 EligibilityPolicy
+	updatability           UpdatabilityPolicy
+	ownership              OwnershipVerifier
+	revocationNonceCheck   bool
+	issuanceDate           string
+	ownerMethods           map[string][]string
+	events                 events.Publisher
+	retryBudget            int
+	retryBudgetLatency     time.Duration
+	timeout                time.Duration
+	subjectLimits          SubjectLimits
+	features               *features.Set
+	issuerProviders        map[string]flexiblehttp.FactoryFlexibleHTTP
+}
+
+type RefreshOption func(*RefreshService)
+
+// WithHistory records the outcome of every refresh.
+func WithHistory(history storage.RefreshHistory) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.history = history
+	}
+}
+
+// WithLineage links every reissued credential to the credential it replaced.
+func WithLineage(lineage storage.Lineage) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.lineage = lineage
+	}
+}
+
+// WithLocker makes sure only one replica refreshes a credential at a time.
+// The lock expires after ttl even if the owner dies, and is extended while
+// the refresh runs.
+func WithLocker(locker lock.Locker, ttl time.Duration) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.locker = locker
+		rs.lockTTL = ttl
+	}
+}
+
+// WithContextLoadConcurrency limits how many JSON-LD contexts of a
+// credential are loaded at the same time.
+func WithContextLoadConcurrency(n int) RefreshOption {
+	return func(rs *RefreshService) {
+		if n > 0 {
+			rs.contextLoadConcurrency = n
+		}
+	}
+}
+
+// WithExpirationSkew treats credentials expiring within skew as expired,
+// so wallets with clocks slightly ahead can refresh right at expiry. It
+// applies to the default ExpirationPolicy only.
+func WithExpirationSkew(skew time.Duration) RefreshOption {
+	return func(rs *RefreshService) {
+		if skew > 0 {
+			rs.expirationSkew = skew
+		}
+	}
+}
+
+// WithRetryBudget bounds the retries of a single refresh across the data
+// providers and the issuer node to retries in total, taking at most latency.
+// A zero value leaves that dimension unlimited.
+func WithRetryBudget(retries int, latency time.Duration) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.retryBudget = retries
+		rs.retryBudgetLatency = latency
+	}
 }
 
 func NewRefreshService(
 	issuerService *IssuerService,
 	documentLoader ld.DocumentLoader,
 	providers flexiblehttp.FactoryFlexibleHTTP,
+	opts ...RefreshOption,
 ) *RefreshService {
-	return &RefreshService{
-		issuerService:  issuerService,
-		documentLoader: documentLoader,
-		providers:      providers,
+	rs := &RefreshService{
+		issuerService:          issuerService,
+		documentLoader:         documentLoader,
+		providers:              providers,
+		refreshServiceTypes:    DefaultRefreshServiceTypes,
+		contextLoadConcurrency: defaultContextLoadConcurrency,
+	}
+	for _, opt := range opts {
+		opt(rs)
+	}
+	if rs.policy == nil {
+		rs.policy = ExpirationPolicy{Skew: rs.expirationSkew}
+	}
+	if rs.updatability == nil {
+		rs.updatability = DefaultUpdatability{Eligibility: rs.policy}
 	}
+	if rs.ownership == nil {
+		rs.ownership = SubjectOwnership{}
+	}
+	return rs
 }
 
 type credentialRequest struct {
-	CredentialSchema  string                     `json:"credentialSchema"`
-	Type              string                     `json:"type"`
-	CredentialSubject map[string]interface{}     `json:"credentialSubject"`
-	Expiration        int64                      `json:"expiration"`
-	RefreshService    *verifiable.RefreshService `json:"refreshService,omitempty"`
-	RevNonce          *uint64                    `json:"revNonce,omitempty"`
-	DisplayMethod     *verifiable.DisplayMethod  `json:"displayMethod,omitempty"`
+	CredentialSchema     string                          `json:"credentialSchema"`
+	Type                 string                          `json:"type"`
+	CredentialSubject    map[string]interface{}          `json:"credentialSubject"`
+	Expiration           int64                           `json:"expiration"`
+	IssuanceDate         *time.Time                      `json:"issuanceDate,omitempty"`
+	ValidFrom            *time.Time                      `json:"validFrom,omitempty"`
+	ValidUntil           *time.Time                      `json:"validUntil,omitempty"`
+	RefreshService       *verifiable.RefreshService      `json:"refreshService,omitempty"`
+	RevNonce             *uint64                         `json:"revNonce,omitempty"`
+	DisplayMethod        *verifiable.DisplayMethod       `json:"displayMethod,omitempty"`
+	SignatureProof       *bool                           `json:"signatureProof,omitempty"`
+	MTProof              *bool                           `json:"mtProof,omitempty"`
+	CredentialStatusType verifiable.CredentialStatusType `json:"credentialStatusType,omitempty"`
+	// MerklizedRootPosition and SubjectPosition are the core claim slots,
+	// 'index' or 'value'. The issuer node picks them when empty.
+	MerklizedRootPosition string `json:"merklizedRootPosition,omitempty"`
+	SubjectPosition       string `json:"subjectPosition,omitempty"`
 }
 
 func (rs *RefreshService) Process(
 	ctx context.Context,
 	issuer, owner, id string,
 ) (*verifiable.W3CCredential, error) {
+	trace := &refreshTrace{
+		issuer:       issuer,
+		owner:        owner,
+		credentialID: id,
+		start:        time.Now(),
+	}
+	rs.publish(ctx, events.TypeRefreshRequested, trace, nil, nil)
+
+	if rs.retryBudget > 0 || rs.retryBudgetLatency > 0 {
+		budget := httpclient.NewRetryBudget(rs.retryBudget, rs.retryBudgetLatency)
+		ctx = httpclient.WithRetryBudget(ctx, budget)
+		defer func() {
+			if rejected := budget.Rejected(); rejected > 0 {
+				logger.SampledWarnf("refresh of credential '%s' skipped %d retries over the retry budget", id, rejected)
+			}
+		}()
+	}
+
+	refreshCtx := ctx
+	if rs.locker != nil {
+		lease, err := rs.locker.Acquire(ctx, issuer+":"+id, rs.lockTTL)
+		if errors.Is(err, lock.ErrNotAcquired) {
+			err = errors.Wrapf(ErrRefreshInProgress, "credential '%s'", id)
+		}
+		if err != nil {
+			rs.publish(ctx, events.TypeRefreshFailed, trace, nil, err)
+			return nil, err
+		}
+		defer func() {
+			if err := lease.Release(context.WithoutCancel(ctx)); err != nil {
+				logger.DefaultLogger.Errorf("failed to release refresh lock: %v", err)
+			}
+		}()
+		var stop func()
+		refreshCtx, stop = rs.keepLease(ctx, lease)
+		defer stop()
+	}
+
+	refreshCtx, cancel := rs.withDeadline(refreshCtx)
+	defer cancel()
+	refreshed, err := rs.process(refreshCtx, trace)
+	err = rs.timedOut(refreshCtx, trace, err)
+	if err != nil && errors.Is(context.Cause(refreshCtx), lock.ErrLost) {
+		err = errors.Wrapf(lock.ErrLost, "refresh lock of credential '%s' in stage '%s'", id, trace.stage)
+	}
+	rs.record(ctx, trace, refreshed, err)
+	return refreshed, err
+}
+
+// keepLease extends the refresh lock every third of its ttl while the
+// refresh runs, which may take longer than the ttl. The returned context is
+// cancelled with lock.ErrLost when the lock can't be extended, so the
+// refresh never goes on while another replica may hold the lock. stop ends
+// the renewal and must be called before the lease is released.
+func (rs *RefreshService) keepLease(ctx context.Context, lease *lock.Lease) (context.Context, func()) {
+	if rs.lockTTL <= 0 {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(rs.lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := lease.Extend(ctx, rs.lockTTL); err != nil {
+					if ctx.Err() == nil {
+						logger.DefaultLogger.Warnf("lost refresh lock '%s': %v", lease.Key, err)
+					}
+					cancel(lock.ErrLost)
+					return
+				}
+			}
+		}
+	}()
+	return ctx, func() {
+		cancel(nil)
+		<-stopped
+	}
+}
+
+func (rs *RefreshService) process(
+	ctx context.Context,
+	trace *refreshTrace,
+) (refreshed *verifiable.W3CCredential, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("🔥 Panic recovered in Process: %v", r)
+			reporting.DefaultReporter.Recover(ctx, r)
+			refreshed, err = nil, errors.Errorf("panic recovered in refresh process: %v", r)
 		}
 	}()
 
+	prepared, err := rs.prepare(ctx, trace)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := trace.enter(ctx, stageIssueCredential); err != nil {
+		return nil, err
+	}
+	if rs.revocationNonceCheck && prepared.request.RevNonce != nil {
+		err := rs.checkRevocationNonce(ctx, trace.issuer, trace.credentialID, *prepared.request.RevNonce)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ctx = httpclient.WithCredentialType(ctx, trace.credentialType)
+	refreshedID, err := rs.issuerService.issue(ctx, trace.issuer, trace.credentialID, prepared.request)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshed, err = rs.issuerService.GetClaimByID(ctx, trace.issuer, refreshedID)
+	if err != nil {
+		return nil, err
+	}
+	checkIssuanceDate(trace.issuer, prepared.issuedAt, refreshed)
+	return refreshed, nil
+}
+
+// preparedRefresh is a refresh up to the point the new credential is issued.
+type preparedRefresh struct {
+	credential *verifiable.W3CCredential
+	changes    []FieldChange
+	request    credentialRequest
+	// issuedAt is the issuance date requested other than now.
+	issuedAt *time.Time
+}
+
+// prepare fetches the credential, checks that it is updatable and builds the
+// request for the new credential from the data provider response.
+func (rs *RefreshService) prepare(ctx context.Context, trace *refreshTrace) (*preparedRefresh, error) {
+	issuer, owner, id := trace.issuer, trace.owner, trace.credentialID
+
 	if rs.issuerService == nil {
 		return nil, errors.New("issuerService is nil")
 	}
 	if rs.documentLoader == nil {
 		return nil, errors.New("documentLoader is nil")
 	}
+	if err := rs.CheckOwnerMethod(issuer, owner); err != nil {
+		return nil, err
+	}
 
-	log.Printf("🔄 Starting refresh for credential ID: %s", id)
+	log.Printf("🔄 Starting refresh for credential ID: %s (request id: %s)", id, correlation.FromContext(ctx))
 
-	credential, err := rs.issuerService.GetClaimByID(issuer, id)
+	if err := trace.enter(ctx, stageFetchCredential); err != nil {
+		return nil, err
+	}
+	credential, err := rs.issuerService.GetClaimByID(ctx, issuer, id)
 	if err != nil {
 		log.Printf("❌ Failed to fetch credential from issuer: %v", err)
 		return nil, err
@@ -78,11 +340,20 @@ func (rs *RefreshService) Process(
 		return nil, errors.New("GetClaimByID returned nil credential")
 	}
 
-	credentialJSON, _ := json.MarshalIndent(credential, "", "  ")
-	log.Printf("🧾 Full credential:\n%s", credentialJSON)
-
-	log.Printf("🔎 Parsed credential — issuer: '%s', type: '%v', subject: %+v",
-		credential.Issuer, credential.Type, credential.CredentialSubject)
+	if err := trace.enter(ctx, stageParseClaim); err != nil {
+		return nil, err
+	}
+	// the credential is serialized once for debug output and the type lookup
+	credentialBytes, err := json.Marshal(credential)
+	if err != nil {
+		return nil, errors.Errorf("failed to serialize credential: %v", err)
+	}
+	if logger.DebugEnabled() {
+		var credentialJSON bytes.Buffer
+		_ = json.Indent(&credentialJSON, credentialBytes, "", "  ")
+		logger.DefaultLogger.Debugf("🧾 Full credential:\n%s", credentialJSON.String())
+	}
+	log.Printf("🔎 Parsed credential — issuer: '%s', type: '%v'", credential.Issuer, credential.Type)
 
 	if credential.Issuer == "" {
 		return nil, errors.New("credential issuer is empty")
@@ -104,15 +375,17 @@ func (rs *RefreshService) Process(
 		return nil, errors.New("credential subject is nil")
 	}
 
-	if err := isUpdatable(credential); err != nil {
+	if err := rs.updatability.Updatable(ctx, credential, time.Now()); err != nil {
 		return nil, errors.Wrapf(ErrCredentialNotUpdatable, "credential '%s': %v", credential.ID, err)
 	}
 
-	if err := checkOwnerShip(credential, owner); err != nil {
+	if err := rs.checkRefreshServiceType(credential); err != nil {
 		return nil, errors.Wrapf(ErrCredentialNotUpdatable, "credential '%s': %v", credential.ID, err)
 	}
 
-	credentialBytes, _ := json.Marshal(credential)
+	if err := rs.ownership.VerifyOwnership(ctx, credential, owner); err != nil {
+		return nil, errors.Wrapf(ErrCredentialNotUpdatable, "credential '%s': %v", credential.ID, err)
+	}
 
 	typeValue, exists := credential.CredentialSubject["type"]
 	if !exists {
@@ -134,34 +407,89 @@ func (rs *RefreshService) Process(
 	if err != nil {
 		return nil, err
 	}
+	trace.credentialType = credentialType
+	ctx = httpclient.WithCredentialType(ctx, credentialType)
+
+	if err := rs.checkQuota(ctx, owner, credentialType, trace.credentialID); err != nil {
+		return nil, err
+	}
 
-	flexibleHTTP, err := rs.providers.ProduceFlexibleHTTP(credentialType)
+	if err := trace.enter(ctx, stageDataProvider); err != nil {
+		return nil, err
+	}
+	flexibleHTTP, err := rs.providersOf(issuer).ProduceFlexibleHTTP(credentialType)
 	if err != nil {
 		return nil, errors.Wrapf(ErrCredentialNotUpdatable, "for credential '%s' no provider: %v", credential.ID, err)
 	}
+	if err := rs.checkProviderFlag(flexibleHTTP.Settings.FeatureFlag, issuer, credential.ID); err != nil {
+		return nil, errors.Wrapf(ErrCredentialNotUpdatable, "credential '%s': %v", credential.ID, err)
+	}
 
-	updatedFields, err := flexibleHTTP.Provide(credential.CredentialSubject)
+	renewal := isExpirationOnly(ctx) || flexibleHTTP.Settings.ExpirationOnly
+	selected := selectedFields(ctx)
+	var updatedFields map[string]interface{}
+	switch {
+	case renewal:
+		if err := checkRenewable(credential); err != nil {
+			return nil, errors.Wrapf(ErrCredentialNotUpdatable, "credential '%s': %v", credential.ID, err)
+		}
+	case len(selected) != 0:
+		if !flexibleHTTP.Settings.SelectiveFields {
+			return nil, errors.Wrapf(ErrCredentialNotUpdatable,
+				"credential '%s': fields of type '%s' can't be refreshed selectively", credential.ID, credentialType)
+		}
+		provider, err := flexibleHTTP.Select(selected)
+		if err != nil {
+			return nil, errors.Wrapf(ErrCredentialNotUpdatable, "credential '%s': %v", credential.ID, err)
+		}
+		updatedFields, err = provider.Provide(ctx, credential.CredentialSubject)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		updatedFields, err = flexibleHTTP.Provide(ctx, credential.CredentialSubject)
+		if err != nil {
+			return nil, err
+		}
+	}
+	providedIssuance, err := takeIssuanceDate(flexibleHTTP.Settings.IssuanceDate,
+		flexibleHTTP.Settings.IssuanceDateField, updatedFields)
 	if err != nil {
 		return nil, err
 	}
+	if !renewal && len(selected) != 0 {
+		keepFields(updatedFields, selected)
+	}
 
 	if updatedFields == nil {
-		log.Printf("⚠️ Warning: updatedFields is nil, using empty map")
+		if !renewal {
+			logger.SampledWarnf("⚠️ Warning: updatedFields is nil, using empty map")
+		}
 		updatedFields = make(map[string]interface{})
 	}
 
 	if flexibleHTTP.Settings.TimeExpiration == 0 {
-		log.Printf("⚠️ Warning: TimeExpiration is 0, using default 5 minutes")
+		logger.SampledWarnf("⚠️ Warning: TimeExpiration is 0, using default 5 minutes")
 		flexibleHTTP.Settings.TimeExpiration = 5 * time.Minute
 	}
 
-	if err := rs.isUpdatedIndexSlots(ctx, credential, credential.CredentialSubject, updatedFields); err != nil {
-		return nil, errors.Wrapf(ErrCredentialNotUpdatable, "index update fail: %v", err)
+	if err := trace.enter(ctx, stageParseClaim); err != nil {
+		return nil, err
+	}
+	if !renewal {
+		if err := rs.isUpdatedIndexSlots(ctx, credential, credential.CredentialSubject, updatedFields); err != nil {
+			return nil, errors.Wrapf(ErrCredentialNotUpdatable, "index update fail: %v", err)
+		}
 	}
 
+	changes := make([]FieldChange, 0, len(updatedFields))
 	for k, v := range updatedFields {
+		changes = append(changes, FieldChange{Field: k, Old: credential.CredentialSubject[k], New: v})
 		credential.CredentialSubject[k] = v
 	}
+	if err := rs.subjectLimits.check(credential.CredentialSubject); err != nil {
+		return nil, errors.WithMessagef(err, "credential '%s'", credential.ID)
+	}
 
 	revNonce, err := extractRevocationNonce(credential)
 	if err != nil {
@@ -173,32 +501,65 @@ func (rs *RefreshService) Process(
 	}
 
 	if credential.RefreshService == nil {
-		log.Printf("⚠️ Warning: RefreshService is nil")
+		logger.SampledWarnf("⚠️ Warning: RefreshService is nil")
 	}
 
-	if credential.DisplayMethod == nil {
-		log.Printf("⚠️ Warning: DisplayMethod is nil")
+	displayMethod := rs.displayMethod(issuer, credential.DisplayMethod)
+	if displayMethod == nil {
+		logger.SampledWarnf("⚠️ Warning: DisplayMethod is nil")
 	}
 
-	credReq := credentialRequest{
-		CredentialSchema:  credential.CredentialSchema.ID,
-		Type:              subjectType,
-		CredentialSubject: credential.CredentialSubject,
-		Expiration:        time.Now().Add(flexibleHTTP.Settings.TimeExpiration).Unix(),
-		RefreshService:    credential.RefreshService,
-		RevNonce:          &revNonce,
-		DisplayMethod:     credential.DisplayMethod,
-	}
-
-	refreshedID, err := rs.issuerService.CreateCredential(issuer, credReq)
+	now := time.Now().UTC().Truncate(time.Second)
+	expiration := now.Add(flexibleHTTP.Settings.TimeExpiration)
+	issuedAt, err := rs.reissuedIssuanceDate(flexibleHTTP.Settings.IssuanceDate, credential, providedIssuance, now)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(ErrCredentialNotUpdatable, "credential '%s': %v", credential.ID, err)
 	}
-
-	return rs.issuerService.GetClaimByID(issuer, refreshedID)
+	if issuedAt.After(expiration) {
+		return nil, errors.Wrapf(ErrCredentialNotUpdatable, "credential '%s': issuance date %s is after the expiration %s",
+			credential.ID, issuedAt.Format(time.RFC3339), expiration.Format(time.RFC3339))
+	}
+	credReq := credentialRequest{
+		CredentialSchema:     credential.CredentialSchema.ID,
+		Type:                 subjectType,
+		CredentialSubject:    credential.CredentialSubject,
+		Expiration:           expiration.Unix(),
+		RefreshService:       rs.reissuedRefreshService(credential.RefreshService),
+		RevNonce:             &revNonce,
+		DisplayMethod:        displayMethod,
+		CredentialStatusType: rs.credentialStatusType(issuer),
+	}
+	credReq.SignatureProof, credReq.MTProof = proofPreferences(credential)
+	credReq.MerklizedRootPosition, credReq.SubjectPosition = claimLayout(credential)
+	if position := flexibleHTTP.Settings.MerklizedRootPosition; position != "" {
+		credReq.MerklizedRootPosition = position
+	}
+	if position := flexibleHTTP.Settings.SubjectPosition; position != "" {
+		credReq.SubjectPosition = position
+	}
+	var requestedIssuance *time.Time
+	if !issuedAt.Equal(now) {
+		requestedIssuance = &issuedAt
+		credReq.IssuanceDate = &issuedAt
+	}
+	if isVCDM2(credential) {
+		// VCDM 2.0 issuers take the validity period instead of expiration
+		credReq.IssuanceDate = nil
+		credReq.ValidFrom = &issuedAt
+		credReq.ValidUntil = &expiration
+	}
+
+	return &preparedRefresh{
+		credential: credential,
+		changes:    changes,
+		request:    credReq,
+		issuedAt:   requestedIssuance,
+	}, nil
 }
 
-func isUpdatable(credential *verifiable.W3CCredential) error {
+// isUpdatable checks that credential has a subject to refresh and that
+// policy allows refreshing it at now.
+func isUpdatable(credential *verifiable.W3CCredential, policy EligibilityPolicy, now time.Time) error {
 	if credential == nil {
 		return errors.New("nil credential")
 	}
@@ -207,10 +568,6 @@ func isUpdatable(credential *verifiable.W3CCredential) error {
 		return errors.New("credential expiration is nil")
 	}
 
-	if credential.Expiration.After(time.Now()) {
-		return errors.New("not expired")
-	}
-
 	if credential.CredentialSubject == nil {
 		return errors.New("credential subject is nil")
 	}
@@ -228,27 +585,7 @@ func isUpdatable(credential *verifiable.W3CCredential) error {
 	if !ok || strings.TrimSpace(idVal) == "" {
 		return errors.New("credential subject does not have a valid id")
 	}
-	return nil
-}
-
-func checkOwnerShip(credential *verifiable.W3CCredential, owner string) error {
-	if credential == nil {
-		return errors.New("nil credential")
-	}
-
-	if credential.CredentialSubject == nil {
-		return errors.New("credential subject is nil")
-	}
-
-	idValue, exists := credential.CredentialSubject["id"]
-	if !exists {
-		return errors.New("credential subject does not have an id field")
-	}
-
-	if idValue != owner {
-		return errors.New("not owner of the credential")
-	}
-	return nil
+	return policy.Eligible(credential, now)
 }
 
 func (rs *RefreshService) isUpdatedIndexSlots(
@@ -282,7 +619,7 @@ func (rs *RefreshService) isUpdatedIndexSlots(
 	case core.MerklizedRootPositionNone:
 
 		if credential.Context == nil {
-			log.Printf("⚠️ Warning: credential.Context is nil, using empty contexts")
+			logger.SampledWarnf("⚠️ Warning: credential.Context is nil, using empty contexts")
 			credential.Context = []string{}
 		}
 
@@ -297,13 +634,13 @@ func (rs *RefreshService) isUpdatedIndexSlots(
 
 			typeValue, ok := oldValues["type"]
 			if !ok || typeValue == nil {
-				log.Printf("⚠️ Warning: type field is missing or nil in oldValues")
+				logger.SampledWarnf("⚠️ Warning: type field is missing or nil in oldValues")
 				continue
 			}
 
 			typeStr, ok := typeValue.(string)
 			if !ok {
-				log.Printf("⚠️ Warning: type field is not a string in oldValues")
+				logger.SampledWarnf("⚠️ Warning: type field is not a string in oldValues")
 				continue
 			}
 
@@ -317,7 +654,7 @@ func (rs *RefreshService) isUpdatedIndexSlots(
 
 			newValue, exists := newValues[k]
 			if !exists {
-				log.Printf("⚠️ Warning: field %s not found in newValues", k)
+				logger.SampledWarnf("⚠️ Warning: field %s not found in newValues", k)
 				continue
 			}
 
@@ -335,67 +672,76 @@ func (rs *RefreshService) loadContexts(contexts []string) ([]byte, error) {
 	}
 
 	if contexts == nil || len(contexts) == 0 {
-		log.Printf("⚠️ Warning: contexts is nil or empty")
+		logger.SampledWarnf("⚠️ Warning: contexts is nil or empty")
 		return json.Marshal(map[string]interface{}{"@context": []interface{}{}})
 	}
 
+	// contexts are loaded concurrently, loaded[i] keeps the order of contexts[i]
+	loaded := make([][]interface{}, len(contexts))
+	var g errgroup.Group
+	g.SetLimit(rs.contextLoadConcurrency)
+	for i, context := range contexts {
+		g.Go(func() error {
+			loaded[i] = rs.loadContext(context)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
 	type uploadedContexts struct {
 		Contexts []interface{} `json:"@context"`
 	}
 	var res uploadedContexts
-	for _, context := range contexts {
-		if context == "" {
-			log.Printf("⚠️ Warning: empty context string, skipping")
-			continue
-		}
+	for _, ldContext := range loaded {
+		res.Contexts = append(res.Contexts, ldContext...)
+	}
+	return json.Marshal(res)
+}
 
-		remoteDocument, err := rs.documentLoader.LoadDocument(context)
-		if err != nil {
-			log.Printf("⚠️ Warning: failed to load context '%s': %v", context, err)
-			continue
-		}
+// loadContext returns the @context entries of the document at context.
+// Documents which fail to load are skipped with a warning.
+func (rs *RefreshService) loadContext(context string) []interface{} {
+	if context == "" {
+		logger.SampledWarnf("⚠️ Warning: empty context string, skipping")
+		return nil
+	}
 
-		if remoteDocument == nil || remoteDocument.Document == nil {
-			log.Printf("⚠️ Warning: remoteDocument or Document is nil for context '%s'", context)
-			continue
-		}
+	remoteDocument, err := rs.documentLoader.LoadDocument(context)
+	if err != nil {
+		logger.SampledWarnf("⚠️ Warning: failed to load context '%s': %v", context, err)
+		return nil
+	}
 
-		document, ok := remoteDocument.Document.(map[string]interface{})
-		if !ok {
-			log.Printf("⚠️ Warning: Document is not a map for context '%s'", context)
-			continue
-		}
+	if remoteDocument == nil || remoteDocument.Document == nil {
+		logger.SampledWarnf("⚠️ Warning: remoteDocument or Document is nil for context '%s'", context)
+		return nil
+	}
 
-		ldContext, ok := document["@context"]
-		if !ok {
-			log.Printf("⚠️ Warning: @context key not found in context '%s'", context)
-			continue
-		}
+	document, ok := remoteDocument.Document.(map[string]interface{})
+	if !ok {
+		logger.SampledWarnf("⚠️ Warning: Document is not a map for context '%s'", context)
+		return nil
+	}
 
-		if v, ok := ldContext.([]interface{}); ok {
-			res.Contexts = append(res.Contexts, v...)
-		} else {
-			res.Contexts = append(res.Contexts, ldContext)
-		}
+	ldContext, ok := document["@context"]
+	if !ok {
+		logger.SampledWarnf("⚠️ Warning: @context key not found in context '%s'", context)
+		return nil
 	}
-	return json.Marshal(res)
+
+	if v, ok := ldContext.([]interface{}); ok {
+		return v
+	}
+	return []interface{}{ldContext}
 }
 
 func extractRevocationNonce(credential *verifiable.W3CCredential) (uint64, error) {
 	if credential == nil {
 		return 0, errors.New("nil credential in extractRevocationNonce")
 	}
-	credentialStatusInfo, ok := credential.CredentialStatus.(map[string]interface{})
-	if !ok {
-		return 0, errors.New("invalid credential status")
-	}
-	nonce, ok := credentialStatusInfo["revocationNonce"]
-	if !ok {
-		return 0, errors.New("revocationNonce not found in credential status")
-	}
-	n, ok := nonce.(float64)
-	if !ok {
-		return 0, errors.New("revocationNonce is not a number")
+	status, err := parseCredentialStatus(credential.CredentialStatus)
+	if err != nil {
+		return 0, err
 	}
-	return uint64(n), nil
+	return status.revocationNonce()
 }
@@ -3,9 +3,16 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/0xPolygonID/refresh-service/lock"
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
 	"github.com/iden3/go-schema-processor/v2/verifiable"
 	"github.com/piprate/json-gold/ld"
 	"github.com/stretchr/testify/require"
@@ -146,3 +153,174 @@ func TestIsUpdatedIndexSlot_Error(t *testing.T) {
 		})
 	}
 }
+
+type slowDocumentLoader struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (l *slowDocumentLoader) LoadDocument(u string) (*ld.RemoteDocument, error) {
+	l.mu.Lock()
+	l.inFlight++
+	if l.inFlight > l.maxInFlight {
+		l.maxInFlight = l.inFlight
+	}
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.inFlight--
+		l.mu.Unlock()
+	}()
+
+	if u == "https://example.com/missing" {
+		return nil, errors.New("not found")
+	}
+	// the first contexts take the longest to load
+	delay, _ := strconv.Atoi(u[len(u)-1:])
+	time.Sleep(time.Duration(10-delay) * 5 * time.Millisecond)
+	return &ld.RemoteDocument{Document: map[string]interface{}{
+		"@context": []interface{}{u},
+	}}, nil
+}
+
+func TestLoadContexts_Order(t *testing.T) {
+	loader := &slowDocumentLoader{}
+	rs := NewRefreshService(nil, loader, flexiblehttp.FactoryFlexibleHTTP{}, WithContextLoadConcurrency(2))
+
+	contexts := []string{
+		"https://example.com/1",
+		"https://example.com/2",
+		"https://example.com/missing",
+		"https://example.com/3",
+		"https://example.com/4",
+	}
+	loaded, err := rs.loadContexts(contexts)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"@context": [
+		"https://example.com/1",
+		"https://example.com/2",
+		"https://example.com/3",
+		"https://example.com/4"
+	]}`, string(loaded))
+	require.Equal(t, 2, loader.maxInFlight)
+}
+
+func TestIsUpdatable_ExpirationSkew(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		expiration time.Time
+		skew       time.Duration
+		expectErr  bool
+	}{
+		{
+			name:       "Expired",
+			expiration: now.Add(-time.Second),
+		},
+		{
+			name:       "Expires in seconds",
+			expiration: now.Add(5 * time.Second),
+			expectErr:  true,
+		},
+		{
+			name:       "Expires within skew",
+			expiration: now.Add(5 * time.Second),
+			skew:       10 * time.Second,
+		},
+		{
+			name:       "Expires after skew",
+			expiration: now.Add(time.Minute),
+			skew:       10 * time.Second,
+			expectErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{}, WithExpirationSkew(tt.skew))
+			credential := &verifiable.W3CCredential{
+				Expiration:        &tt.expiration,
+				CredentialSubject: map[string]interface{}{"id": "did:iden3:owner"},
+			}
+			err := isUpdatable(credential, rs.policy, now)
+			if tt.expectErr {
+				require.ErrorContains(t, err, "not expired until")
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// extendingLocker hands out leases which count their renewals and fail them
+// once lost is set.
+type extendingLocker struct {
+	mu       sync.Mutex
+	lost     bool
+	extended int
+	released bool
+}
+
+func (l *extendingLocker) Acquire(_ context.Context, key string, _ time.Duration) (*lock.Lease, error) {
+	release := func(context.Context) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.released = true
+		return nil
+	}
+	extend := func(context.Context, time.Duration) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.lost {
+			return lock.ErrLost
+		}
+		l.extended++
+		return nil
+	}
+	return lock.NewLease(key, 1, release, extend), nil
+}
+
+func TestProcess_LockRenewal(t *testing.T) {
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the issuer node never answers
+		<-r.Context().Done()
+	}))
+	defer issuer.Close()
+
+	tests := []struct {
+		name        string
+		lost        bool
+		expectedErr error
+	}{
+		{
+			name: "Lock extended past its ttl",
+		},
+		{
+			name:        "Lock lost",
+			lost:        true,
+			expectedErr: lock.ErrLost,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locker := &extendingLocker{lost: tt.lost}
+			is := NewIssuerService(map[string]string{"*": issuer.URL}, nil, http.DefaultClient)
+			rs := NewRefreshService(is, &slowDocumentLoader{}, flexiblehttp.FactoryFlexibleHTTP{},
+				WithLocker(locker, 30*time.Millisecond))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			_, err := rs.Process(ctx, "did:iden3:issuer", "did:iden3:owner", "1")
+			require.Error(t, err)
+			if tt.expectedErr != nil {
+				require.ErrorIs(t, err, tt.expectedErr)
+				require.NoError(t, ctx.Err())
+			} else {
+				require.NotErrorIs(t, err, lock.ErrLost)
+				require.Greater(t, locker.extended, 3)
+			}
+			require.True(t, locker.released)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// DefaultRefreshServiceTypes are the refreshService types accepted when
+// none are configured.
+var DefaultRefreshServiceTypes = []verifiable.RefreshServiceType{verifiable.Iden3RefreshService2023}
+
+// WithRefreshServiceTypes sets the refreshService types the service accepts
+// on credentials and the type set on reissued credentials. An empty emit
+// type keeps the type of the original credential.
+func WithRefreshServiceTypes(accepted []verifiable.RefreshServiceType, emit verifiable.RefreshServiceType) RefreshOption {
+	return func(rs *RefreshService) {
+		if len(accepted) > 0 {
+			rs.refreshServiceTypes = accepted
+		}
+		rs.emitRefreshServiceType = emit
+	}
+}
+
+func (rs *RefreshService) checkRefreshServiceType(credential *verifiable.W3CCredential) error {
+	if credential.RefreshService == nil {
+		return nil
+	}
+	for _, t := range rs.refreshServiceTypes {
+		if credential.RefreshService.Type == t {
+			return nil
+		}
+	}
+	return errors.Errorf("unsupported refresh service type '%s'", credential.RefreshService.Type)
+}
+
+// reissuedRefreshService returns the refreshService of the reissued
+// credential.
+func (rs *RefreshService) reissuedRefreshService(original *verifiable.RefreshService) *verifiable.RefreshService {
+	if original == nil || rs.emitRefreshServiceType == "" {
+		return original
+	}
+	reissued := *original
+	reissued.Type = rs.emitRefreshServiceType
+	return &reissued
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshServiceTypes(t *testing.T) {
+	const future verifiable.RefreshServiceType = "Iden3RefreshService2025"
+	original := &verifiable.RefreshService{ID: "https://refresh.example.com", Type: verifiable.Iden3RefreshService2023}
+
+	rs := NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{})
+	require.NoError(t, rs.checkRefreshServiceType(&verifiable.W3CCredential{RefreshService: original}))
+	require.EqualError(t,
+		rs.checkRefreshServiceType(&verifiable.W3CCredential{
+			RefreshService: &verifiable.RefreshService{Type: future},
+		}),
+		"unsupported refresh service type 'Iden3RefreshService2025'")
+	require.Same(t, original, rs.reissuedRefreshService(original))
+
+	rs = NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{}, WithRefreshServiceTypes(
+		[]verifiable.RefreshServiceType{verifiable.Iden3RefreshService2023, future},
+		future,
+	))
+	require.NoError(t, rs.checkRefreshServiceType(&verifiable.W3CCredential{
+		RefreshService: &verifiable.RefreshService{Type: future},
+	}))
+	reissued := rs.reissuedRefreshService(original)
+	require.Equal(t, &verifiable.RefreshService{ID: "https://refresh.example.com", Type: future}, reissued)
+	require.Equal(t, verifiable.Iden3RefreshService2023, original.Type)
+	require.Nil(t, rs.reissuedRefreshService(nil))
+}
+
+func TestCredentialStatusType(t *testing.T) {
+	rs := NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{}, WithCredentialStatusTypes(
+		map[string]verifiable.CredentialStatusType{
+			"did:iden3:issuer1": verifiable.Iden3OnchainSparseMerkleTreeProof2023,
+			"*":                 verifiable.Iden3commRevocationStatusV1,
+		},
+	))
+	require.Equal(t, verifiable.Iden3OnchainSparseMerkleTreeProof2023, rs.credentialStatusType("did:iden3:issuer1"))
+	require.Equal(t, verifiable.Iden3commRevocationStatusV1, rs.credentialStatusType("did:iden3:issuer2"))
+
+	rs = NewRefreshService(nil, nil, flexiblehttp.FactoryFlexibleHTTP{})
+	require.Empty(t, rs.credentialStatusType("did:iden3:issuer1"))
+
+	require.EqualError(t,
+		ValidateCredentialStatusTypes(map[string]verifiable.CredentialStatusType{"*": "BitstringStatusListEntry"}),
+		"unsupported credential status type 'BitstringStatusListEntry' for issuer '*'")
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+type expirationOnlyKey struct{}
+
+// ExpirationOnly marks the refresh run with ctx as a renewal: the credential
+// is reissued with its subject unchanged and only a new expiration, without
+// calling the data provider. Types can be renewed this way by default with
+// the expirationOnly provider setting.
+func ExpirationOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, expirationOnlyKey{}, true)
+}
+
+func isExpirationOnly(ctx context.Context) bool {
+	renewal, _ := ctx.Value(expirationOnlyKey{}).(bool)
+	return renewal
+}
+
+// checkRenewable fails for credentials with merkle tree proofs. Their
+// renewed claim would have the same index, which the claims tree of the
+// issuer can't hold twice.
+func checkRenewable(credential *verifiable.W3CCredential) error {
+	if _, mtProof := proofPreferences(credential); mtProof != nil && *mtProof {
+		return errors.New("credentials with merkle tree proofs can't be renewed with the same subject")
+	}
+	return nil
+}
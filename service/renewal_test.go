@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRenewable(t *testing.T) {
+	signature := &verifiable.BJJSignatureProof2021{Type: verifiable.BJJSignatureProofType}
+	mtp := &verifiable.Iden3SparseMerkleTreeProof{Type: verifiable.Iden3SparseMerkleTreeProofType}
+	tests := []struct {
+		name  string
+		proof verifiable.CredentialProofs
+		err   bool
+	}{
+		{name: "No proofs"},
+		{name: "Signature", proof: verifiable.CredentialProofs{signature}},
+		{name: "Signature and MTP", proof: verifiable.CredentialProofs{signature, mtp}, err: true},
+		{name: "MTP", proof: verifiable.CredentialProofs{mtp}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRenewable(&verifiable.W3CCredential{Proof: tt.proof})
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExpirationOnly(t *testing.T) {
+	require.False(t, isExpirationOnly(context.Background()))
+	require.True(t, isExpirationOnly(ExpirationOnly(context.Background())))
+}
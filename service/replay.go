@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/iden3/iden3comm/v2"
+	"github.com/pkg/errors"
+)
+
+var ErrReplayedMessage = errors.New("message was already processed")
+
+type AgentOption func(*AgentService)
+
+// WithReplayProtection rejects messages whose id or thread id was seen
+// within ttl.
+func WithReplayProtection(store storage.Idempotency, ttl time.Duration) AgentOption {
+	return func(as *AgentService) {
+		as.processedMessages = store
+		as.replayTTL = ttl
+	}
+}
+
+func (as *AgentService) checkReplay(ctx context.Context, message *iden3comm.BasicMessage) error {
+	return as.rememberMessage(ctx, message.From, message.ID, message.ThreadID)
+}
+
+func (as *AgentService) rememberMessage(ctx context.Context, from, id, threadID string) error {
+	if as.processedMessages == nil || as.replayTTL <= 0 {
+		return nil
+	}
+	if id == "" {
+		return errors.Wrap(ErrInvalidProtocolMessage, "missing 'id' field in message")
+	}
+
+	keys := []string{"message:" + from + ":" + id}
+	if threadID != "" && threadID != id {
+		keys = append(keys, "thread:"+from+":"+threadID)
+	}
+	expiresAt := time.Now().Add(as.replayTTL).UTC()
+	for _, key := range keys {
+		stored, err := as.processedMessages.PutIdempotency(ctx, storage.IdempotencyRecord{
+			Key:       key,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		if !stored {
+			return errors.Wrapf(ErrReplayedMessage, "message '%s'", id)
+		}
+	}
+	return nil
+}
+
+// checkProofLifetime refuses ownership proofs expiring after the replay
+// record of their id: they could be replayed once it is gone. Without
+// replay protection a proof could be replayed until it expires, so none is
+// accepted.
+func (as *AgentService) checkProofLifetime(expiresAt int64, now time.Time) error {
+	if as.processedMessages == nil || as.replayTTL <= 0 {
+		return errors.Wrap(ErrInvalidProtocolMessage, "ownership proofs are not accepted without replay protection")
+	}
+	if time.Unix(expiresAt, 0).Sub(now) > as.replayTTL {
+		return errors.Wrapf(ErrInvalidOwnershipProof, "ownership proof expires in more than %s", as.replayTTL)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckProofLifetime(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name         string
+		replayTTL    time.Duration
+		expiresAt    time.Time
+		expectedCode int
+	}{
+		{name: "Within replay TTL", replayTTL: time.Hour, expiresAt: now.Add(time.Hour)},
+		{name: "Expired", replayTTL: time.Hour, expiresAt: now.Add(-time.Minute)},
+		{
+			name:         "Outlives replay record",
+			replayTTL:    time.Hour,
+			expiresAt:    now.Add(365 * 24 * time.Hour),
+			expectedCode: CodeInvalidOwnershipProof,
+		},
+		{
+			name:         "Replay protection disabled",
+			expiresAt:    now.Add(time.Minute),
+			expectedCode: CodeInvalidProtocolMessage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			as := NewAgentService(nil, nil, WithReplayProtection(memory.NewStore(), tt.replayTTL))
+			err := as.checkProofLifetime(tt.expiresAt.Unix(), now)
+			if tt.expectedCode == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Equal(t, tt.expectedCode, ErrorCode(err))
+		})
+	}
+}
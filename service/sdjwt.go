@@ -0,0 +1,43 @@
+package service
+
+import (
+	"github.com/0xPolygonID/refresh-service/sdjwt"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// RefreshResult is a refreshed credential with its optional JWT and SD-JWT
+// VC forms.
+type RefreshResult struct {
+	Credential *verifiable.W3CCredential `json:"credential"`
+	JWT        string                    `json:"jwt,omitempty"`
+	SDJWT      string                    `json:"sdJwt,omitempty"`
+}
+
+// WithSDJWT additionally issues refreshed credentials as SD-JWT VCs.
+// vcts maps the credential subject type to the SD-JWT VC type (vct);
+// credentials of other types are returned in W3C form only.
+func WithSDJWT(issuer *sdjwt.Issuer, vcts map[string]string) AgentOption {
+	return func(as *AgentService) {
+		as.sdjwtIssuer = issuer
+		as.sdjwtTypes = vcts
+	}
+}
+
+func (as *AgentService) newRefreshResult(credential *verifiable.W3CCredential) (*RefreshResult, error) {
+	result := &RefreshResult{Credential: credential}
+	if as.sdjwtIssuer == nil {
+		return result, nil
+	}
+	subjectType, _ := credential.CredentialSubject["type"].(string)
+	vct, ok := as.sdjwtTypes[subjectType]
+	if !ok {
+		return result, nil
+	}
+	sdJWT, err := as.sdjwtIssuer.Issue(credential, vct)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidProtocolResponse, "failed to issue SD-JWT VC: %v", err)
+	}
+	result.SDJWT = sdJWT
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// FieldChange is a credential subject field updated by the data provider.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Simulation is the outcome of a refresh that stopped before the issuer
+// node was asked for the new credential.
+type Simulation struct {
+	CredentialType string                    `json:"credentialType"`
+	Credential     *verifiable.W3CCredential `json:"credential"`
+	Changes        []FieldChange             `json:"changes"`
+	Request        json.RawMessage           `json:"credentialRequest"`
+}
+
+// Simulate runs the refresh pipeline for a credential without issuing the
+// new credential, holding a lock or recording history. Credential is the
+// credential with the updated subject and Request the body the issuer node
+// would get.
+func (rs *RefreshService) Simulate(ctx context.Context, issuer, owner, id string) (*Simulation, error) {
+	trace := &refreshTrace{
+		issuer:       issuer,
+		owner:        owner,
+		credentialID: id,
+		start:        time.Now(),
+	}
+	prepared, err := rs.prepare(ctx, trace)
+	if err != nil {
+		return nil, err
+	}
+	request, err := json.Marshal(prepared.request)
+	if err != nil {
+		return nil, errors.Errorf("failed to serialize credential request: %v", err)
+	}
+	sort.Slice(prepared.changes, func(i, j int) bool {
+		return prepared.changes[i].Field < prepared.changes[j].Field
+	})
+	return &Simulation{
+		CredentialType: trace.credentialType,
+		Credential:     prepared.credential,
+		Changes:        prepared.changes,
+		Request:        request,
+	}, nil
+}
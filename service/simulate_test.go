@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulate_DoesNotIssue(t *testing.T) {
+	var posts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posts++
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"vc": {
+			"id": "urn:uuid:1",
+			"issuer": "did:iden3:issuer",
+			"type": ["VerifiableCredential", "Balance"],
+			"expirationDate": %q,
+			"credentialSubject": {"id": "did:iden3:owner", "type": "Balance", "balance": 1}
+		}}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	is := NewIssuerService(map[string]string{"*": srv.URL}, nil, srv.Client())
+	rs := NewRefreshService(is, &slowDocumentLoader{}, flexiblehttp.FactoryFlexibleHTTP{})
+
+	_, err := rs.Simulate(context.Background(), "did:iden3:issuer", "did:iden3:owner", "1")
+	require.ErrorIs(t, err, ErrCredentialNotUpdatable)
+	require.Zero(t, posts)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// credentialStatus is a credential status as found in issued credentials.
+// Issuers encode revocationNonce either as a number or as a string.
+type credentialStatus struct {
+	ID              string                          `json:"id"`
+	Type            verifiable.CredentialStatusType `json:"type"`
+	RevocationNonce json.Number                     `json:"revocationNonce"`
+	StatusIssuer    *credentialStatus               `json:"statusIssuer,omitempty"`
+}
+
+// parseCredentialStatus decodes credentialStatus of a credential. VCDM 2.0
+// credentials may carry a list of statuses; the first supported one is used.
+func parseCredentialStatus(status interface{}) (*credentialStatus, error) {
+	if status == nil {
+		return nil, errors.New("credential status is empty")
+	}
+	raw, err := json.Marshal(status)
+	if err != nil {
+		return nil, errors.Errorf("invalid credential status: %v", err)
+	}
+
+	var statuses []credentialStatus
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		if err := json.Unmarshal(raw, &statuses); err != nil {
+			return nil, errors.Errorf("invalid credential status: %v", err)
+		}
+	} else {
+		var s credentialStatus
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, errors.Errorf("invalid credential status: %v", err)
+		}
+		statuses = append(statuses, s)
+	}
+
+	for i := range statuses {
+		if isSupportedStatusType(statuses[i].Type) {
+			return &statuses[i], nil
+		}
+	}
+	return nil, errors.Errorf("unsupported credential status type '%s'", statuses[0].Type)
+}
+
+func isSupportedStatusType(t verifiable.CredentialStatusType) bool {
+	switch t {
+	case verifiable.SparseMerkleTreeProof,
+		verifiable.Iden3ReverseSparseMerkleTreeProof,
+		verifiable.Iden3OnchainSparseMerkleTreeProof2023,
+		verifiable.Iden3commRevocationStatusV1:
+		return true
+	default:
+		return false
+	}
+}
+
+// revocationNonce returns the revocation nonce of the status. Onchain
+// statuses may only carry it in the status id query, and statuses with a
+// statusIssuer fallback may only carry it in the nested status.
+func (s *credentialStatus) revocationNonce() (uint64, error) {
+	if s.RevocationNonce != "" {
+		return parseNonce(s.RevocationNonce.String())
+	}
+	if s.Type == verifiable.Iden3OnchainSparseMerkleTreeProof2023 {
+		if u, err := url.Parse(s.ID); err == nil {
+			if nonce := u.Query().Get("revocationNonce"); nonce != "" {
+				return parseNonce(nonce)
+			}
+		}
+	}
+	if s.StatusIssuer != nil {
+		return s.StatusIssuer.revocationNonce()
+	}
+	return 0, errors.Errorf("revocationNonce not found in credential status '%s'", s.Type)
+}
+
+func parseNonce(nonce string) (uint64, error) {
+	n, err := strconv.ParseUint(nonce, 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("revocationNonce '%s' is not a valid number", nonce)
+	}
+	return n, nil
+}
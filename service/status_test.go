@@ -0,0 +1,90 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractRevocationNonce(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      string
+		expected    uint64
+		expectedErr string
+	}{
+		{
+			name: "Sparse merkle tree proof",
+			status: `{"id": "https://issuer.example.com/v1/credentials/revocation/status/42",
+				"type": "SparseMerkleTreeProof", "revocationNonce": 42}`,
+			expected: 42,
+		},
+		{
+			name: "Reverse sparse merkle tree proof with nonce above 2^53",
+			status: `{"id": "https://rhs.example.com/node", "type": "Iden3ReverseSparseMerkleTreeProof",
+				"revocationNonce": 18446744073709551615}`,
+			expected: 18446744073709551615,
+		},
+		{
+			name: "Onchain status with nonce in id",
+			status: `{"id": "did:iden3:polygon:amoy:x6x5sor7zpxUwajVSoHGg8aAhoHNoAW1xFDTPCF49/credentialStatus?revocationNonce=1001&contractAddress=80002:0x1a4cC30f2aA0377b0c3bc9848766D90cb4404124",
+				"type": "Iden3OnchainSparseMerkleTreeProof2023"}`,
+			expected: 1001,
+		},
+		{
+			name: "Agent status with nonce as string",
+			status: `{"id": "https://issuer.example.com/v2/agent", "type": "Iden3commRevocationStatusV1.0",
+				"revocationNonce": "7"}`,
+			expected: 7,
+		},
+		{
+			name: "Nonce in nested status issuer",
+			status: `{"id": "https://rhs.example.com/node", "type": "Iden3ReverseSparseMerkleTreeProof",
+				"statusIssuer": {"id": "https://issuer.example.com/v2/agent",
+					"type": "Iden3commRevocationStatusV1.0", "revocationNonce": 9}}`,
+			expected: 9,
+		},
+		{
+			name: "List of statuses",
+			status: `[{"id": "https://example.com/status/1", "type": "BitstringStatusListEntry"},
+				{"id": "https://issuer.example.com/v2/agent", "type": "Iden3commRevocationStatusV1.0", "revocationNonce": 3}]`,
+			expected: 3,
+		},
+		{
+			name:        "Unsupported type",
+			status:      `{"id": "https://example.com/status/1", "type": "BitstringStatusListEntry"}`,
+			expectedErr: "unsupported credential status type 'BitstringStatusListEntry'",
+		},
+		{
+			name:        "Missing nonce",
+			status:      `{"id": "https://issuer.example.com/v2/agent", "type": "Iden3commRevocationStatusV1.0"}`,
+			expectedErr: "revocationNonce not found in credential status 'Iden3commRevocationStatusV1.0'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credential, err := parseCredential([]byte(`{"credentialStatus": ` + tt.status + `}`))
+			require.NoError(t, err)
+			nonce, err := extractRevocationNonce(credential)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, nonce)
+		})
+	}
+}
+
+func TestExtractRevocationNonce_DecodedStatus(t *testing.T) {
+	var status interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "SparseMerkleTreeProof", "revocationNonce": 12}`), &status))
+	credential, err := parseCredential([]byte(`{}`))
+	require.NoError(t, err)
+	credential.CredentialStatus = status
+	nonce, err := extractRevocationNonce(credential)
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), nonce)
+}
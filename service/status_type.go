@@ -0,0 +1,33 @@
+package service
+
+import (
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// WithCredentialStatusTypes sets the credentialStatus type the issuer node
+// uses for reissued credentials, per issuer DID. The '*' key applies to all
+// other issuers. Issuers without a type keep the issuer node default.
+func WithCredentialStatusTypes(types map[string]verifiable.CredentialStatusType) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.credentialStatusTypes = types
+	}
+}
+
+// ValidateCredentialStatusTypes checks that every configured status type is
+// one the refresh service can read back from reissued credentials.
+func ValidateCredentialStatusTypes(types map[string]verifiable.CredentialStatusType) error {
+	for issuer, t := range types {
+		if !isSupportedStatusType(t) {
+			return errors.Errorf("unsupported credential status type '%s' for issuer '%s'", t, issuer)
+		}
+	}
+	return nil
+}
+
+func (rs *RefreshService) credentialStatusType(issuer string) verifiable.CredentialStatusType {
+	if t, ok := rs.credentialStatusTypes[issuer]; ok {
+		return t
+	}
+	return rs.credentialStatusTypes["*"]
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+var ErrSubjectTooLarge = errors.New("credential subject is too large")
+
+// SubjectLimits bound the credentialSubject of reissued credentials, so a
+// data provider can't make the issuer node sign arbitrarily large claims.
+// Zero limits are not enforced.
+type SubjectLimits struct {
+	// MaxBytes is the size of the JSON encoded subject.
+	MaxBytes int
+	// MaxFields counts the fields of the subject and of all nested objects.
+	MaxFields int
+	// MaxDepth is how deep objects and arrays may nest, the subject itself
+	// being 1.
+	MaxDepth int
+}
+
+// WithSubjectLimits rejects refreshes whose merged subject is over limits
+// before the issuer node is called.
+func WithSubjectLimits(limits SubjectLimits) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.subjectLimits = limits
+	}
+}
+
+func (l SubjectLimits) check(subject map[string]interface{}) error {
+	if l.MaxFields > 0 || l.MaxDepth > 0 {
+		fields, depth := subjectShape(subject, 1)
+		if l.MaxFields > 0 && fields > l.MaxFields {
+			return errors.Wrapf(ErrSubjectTooLarge, "%d fields, at most %d allowed", fields, l.MaxFields)
+		}
+		if l.MaxDepth > 0 && depth > l.MaxDepth {
+			return errors.Wrapf(ErrSubjectTooLarge, "nested %d levels deep, at most %d allowed", depth, l.MaxDepth)
+		}
+	}
+	if l.MaxBytes > 0 {
+		encoded, err := json.Marshal(subject)
+		if err != nil {
+			return errors.Errorf("failed to serialize credential subject: %v", err)
+		}
+		if len(encoded) > l.MaxBytes {
+			return errors.Wrapf(ErrSubjectTooLarge, "%d bytes, at most %d allowed", len(encoded), l.MaxBytes)
+		}
+	}
+	return nil
+}
+
+// subjectShape returns the number of object fields within value and how
+// deep it nests, value being at depth.
+func subjectShape(value interface{}, depth int) (fields, maxDepth int) {
+	maxDepth = depth
+	var children []interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		fields = len(v)
+		for _, child := range v {
+			children = append(children, child)
+		}
+	case []interface{}:
+		children = v
+	default:
+		return 0, depth - 1
+	}
+	for _, child := range children {
+		childFields, childDepth := subjectShape(child, depth+1)
+		fields += childFields
+		maxDepth = max(maxDepth, childDepth)
+	}
+	return fields, maxDepth
+}
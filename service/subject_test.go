@@ -0,0 +1,39 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubjectLimits(t *testing.T) {
+	subject := map[string]interface{}{
+		"id":      "did:iden3:owner",
+		"type":    "Address",
+		"address": map[string]interface{}{"city": "Zug", "lines": []interface{}{"a", map[string]interface{}{"b": 1}}},
+	}
+	tests := []struct {
+		name   string
+		limits SubjectLimits
+		err    string
+	}{
+		{name: "No limits"},
+		{name: "Within limits", limits: SubjectLimits{MaxBytes: 1024, MaxFields: 6, MaxDepth: 4}},
+		{name: "Too many fields", limits: SubjectLimits{MaxFields: 5}, err: "6 fields, at most 5 allowed"},
+		{name: "Too deep", limits: SubjectLimits{MaxDepth: 3}, err: "nested 4 levels deep, at most 3 allowed"},
+		{name: "Too large", limits: SubjectLimits{MaxBytes: 16}, err: "bytes, at most 16 allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.check(subject)
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrSubjectTooLarge)
+			require.Contains(t, err.Error(), tt.err)
+			require.Equal(t, CodeSubjectTooLarge, ErrorCode(err))
+		})
+	}
+}
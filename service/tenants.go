@@ -0,0 +1,23 @@
+package service
+
+import (
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+)
+
+// WithIssuerProviders refreshes the credentials of the issuers in providers
+// with their own provider registry instead of the default one, e.g. one per
+// tenant of a multi-tenant deployment. Issuers sharing a tenant share its
+// registry.
+func WithIssuerProviders(providers map[string]flexiblehttp.FactoryFlexibleHTTP) RefreshOption {
+	return func(rs *RefreshService) {
+		rs.issuerProviders = providers
+	}
+}
+
+// providersOf returns the provider registry of the credentials of issuer.
+func (rs *RefreshService) providersOf(issuer string) *flexiblehttp.FactoryFlexibleHTTP {
+	if providers, ok := rs.issuerProviders[issuer]; ok {
+		return &providers
+	}
+	return &rs.providers
+}
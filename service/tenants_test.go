@@ -0,0 +1,48 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProviders loads a registry configuring credentialType.
+func writeProviders(t *testing.T, credentialType string) flexiblehttp.FactoryFlexibleHTTP {
+	t.Helper()
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(credentialType+`:
+  provider:
+    url: https://api.example.com/{{ credentialSubject.id }}
+  responseSchema:
+    properties:
+      balance:
+        type: integer
+        match: credentialSubject.balance
+`), 0o600))
+	providers, err := flexiblehttp.NewFactoryFlexibleHTTP(config, nil)
+	require.NoError(t, err)
+	return providers
+}
+
+func TestProvidersOf(t *testing.T) {
+	acme := writeProviders(t, "KYCAge")
+	rs := NewRefreshService(nil, nil, writeProviders(t, "Balance"),
+		WithIssuerProviders(map[string]flexiblehttp.FactoryFlexibleHTTP{"did:iden3:acme": acme}))
+
+	tests := []struct {
+		name          string
+		issuer        string
+		expectedTypes []string
+	}{
+		{name: "Tenant issuer", issuer: "did:iden3:acme", expectedTypes: []string{"KYCAge"}},
+		{name: "Other issuer", issuer: "did:iden3:globex", expectedTypes: []string{"Balance"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedTypes, rs.providersOf(tt.issuer).CredentialTypes())
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Stages of a refresh, named in timeout errors.
+const (
+	stageFetchCredential = "fetch credential"
+	stageParseClaim      = "parse claim"
+	stageDataProvider    = "data provider"
+	stageIssueCredential = "issue credential"
+)
+
+// WithRefreshTimeout bounds a whole refresh, from fetching the credential to
+// issuing the new one. A refresh over timeout fails with ErrRefreshTimeout
+// naming the stage it was in.
+func WithRefreshTimeout(timeout time.Duration) RefreshOption {
+	return func(rs *RefreshService) {
+		if timeout > 0 {
+			rs.timeout = timeout
+		}
+	}
+}
+
+// withDeadline returns ctx bounded by the refresh timeout.
+func (rs *RefreshService) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if rs.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, rs.timeout, ErrRefreshTimeout)
+}
+
+// timedOut replaces err with ErrRefreshTimeout when the refresh deadline of
+// ctx passed. Deadlines of the caller are left as they are.
+func (rs *RefreshService) timedOut(ctx context.Context, trace *refreshTrace, err error) error {
+	if err == nil || !errors.Is(context.Cause(ctx), ErrRefreshTimeout) {
+		return err
+	}
+	return errors.Wrapf(ErrRefreshTimeout, "credential '%s' after %s in stage '%s'",
+		trace.credentialID, rs.timeout, trace.stage)
+}
+
+// enter moves the refresh to stage. It fails when ctx ended during the
+// previous stage, so that stage is reported.
+func (t *refreshTrace) enter(ctx context.Context, stage string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	t.stage = stage
+	return nil
+}
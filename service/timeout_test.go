@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcess_RefreshTimeout(t *testing.T) {
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the issuer node never answers
+		<-r.Context().Done()
+	}))
+	defer issuer.Close()
+
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		deadline time.Duration
+		expected string
+	}{
+		{
+			name:     "Refresh timeout",
+			timeout:  20 * time.Millisecond,
+			expected: "credential '1' after 20ms in stage 'fetch credential': refresh timed out",
+		},
+		{
+			name:     "Caller deadline",
+			timeout:  time.Minute,
+			deadline: 20 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := NewIssuerService(map[string]string{"*": issuer.URL}, nil, http.DefaultClient)
+			rs := NewRefreshService(is, &slowDocumentLoader{}, flexiblehttp.FactoryFlexibleHTTP{},
+				WithRefreshTimeout(tt.timeout))
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+			_, err := rs.Process(ctx, "did:iden3:issuer", "did:iden3:owner", "1")
+			require.Error(t, err)
+			if tt.expected == "" {
+				require.NotErrorIs(t, err, ErrRefreshTimeout)
+				return
+			}
+			require.ErrorIs(t, err, ErrRefreshTimeout)
+			require.EqualError(t, err, tt.expected)
+			require.Equal(t, CodeRefreshTimeout, ErrorCode(err))
+		})
+	}
+}
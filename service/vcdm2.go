@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/codec"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/pkg/errors"
+)
+
+// VCDM2Context is the base context of W3C VC Data Model 2.0 credentials.
+const VCDM2Context = "https://www.w3.org/ns/credentials/v2"
+
+type validityPeriod struct {
+	ValidFrom  *time.Time `json:"validFrom,omitempty"`
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+}
+
+func isVCDM2(credential *verifiable.W3CCredential) bool {
+	return len(credential.Context) > 0 && credential.Context[0] == VCDM2Context
+}
+
+// parseCredential decodes a credential. The VCDM 2.0 validity period is
+// kept in IssuanceDate and Expiration, so the rest of the refresh flow
+// handles both data model versions the same way.
+func parseCredential(raw []byte) (*verifiable.W3CCredential, error) {
+	var credential verifiable.W3CCredential
+	if err := codec.Unmarshal(raw, &credential); err != nil {
+		return nil, err
+	}
+	// keep credentialStatus as issued, large revocation nonces don't fit float64
+	var status struct {
+		CredentialStatus json.RawMessage `json:"credentialStatus,omitempty"`
+	}
+	if err := codec.Unmarshal(raw, &status); err != nil {
+		return nil, err
+	}
+	if len(status.CredentialStatus) > 0 {
+		credential.CredentialStatus = status.CredentialStatus
+	}
+	if !isVCDM2(&credential) {
+		return &credential, nil
+	}
+	var validity validityPeriod
+	if err := codec.Unmarshal(raw, &validity); err != nil {
+		return nil, errors.Errorf("invalid validity period: %v", err)
+	}
+	if validity.ValidFrom != nil {
+		credential.IssuanceDate = validity.ValidFrom
+	}
+	if validity.ValidUntil != nil {
+		credential.Expiration = validity.ValidUntil
+	}
+	return &credential, nil
+}
+
+// MarshalCredential encodes a credential in the data model version it was
+// issued in.
+func MarshalCredential(credential *verifiable.W3CCredential) ([]byte, error) {
+	raw, err := json.Marshal(credential)
+	if err != nil || !isVCDM2(credential) {
+		return raw, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	for from, to := range map[string]string{
+		"issuanceDate":   "validFrom",
+		"expirationDate": "validUntil",
+	} {
+		if v, ok := fields[from]; ok {
+			fields[to] = v
+			delete(fields, from)
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// MarshalJSON encodes the credential in the data model version it was
+// issued in.
+func (r RefreshResult) MarshalJSON() ([]byte, error) {
+	credential, err := MarshalCredential(r.Credential)
+	if err != nil {
+		return nil, err
+	}
+	type result RefreshResult
+	return json.Marshal(struct {
+		result
+		Credential json.RawMessage `json:"credential"`
+	}{result: result(r), Credential: credential})
+}
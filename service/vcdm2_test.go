@@ -0,0 +1,64 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCredential_VCDM2(t *testing.T) {
+	raw := []byte(`{
+		"@context": ["https://www.w3.org/ns/credentials/v2"],
+		"id": "urn:uuid:1",
+		"type": ["VerifiableCredential", "BalanceCredential"],
+		"issuer": "did:iden3:issuer",
+		"validFrom": "2024-01-01T00:00:00Z",
+		"validUntil": "2024-01-02T00:00:00Z",
+		"credentialSubject": {"id": "did:iden3:owner", "type": "BalanceCredential"},
+		"credentialSchema": {"id": "https://example.com/schema.json", "type": "JsonSchema2023"}
+	}`)
+
+	credential, err := parseCredential(raw)
+	require.NoError(t, err)
+	require.True(t, isVCDM2(credential))
+	require.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), credential.IssuanceDate.UTC())
+	require.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), credential.Expiration.UTC())
+	require.NoError(t, isUpdatable(credential, ExpirationPolicy{}, time.Now()))
+
+	encoded, err := MarshalCredential(credential)
+	require.NoError(t, err)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(encoded, &fields))
+	require.Equal(t, "2024-01-01T00:00:00Z", fields["validFrom"])
+	require.Equal(t, "2024-01-02T00:00:00Z", fields["validUntil"])
+	require.NotContains(t, fields, "issuanceDate")
+	require.NotContains(t, fields, "expirationDate")
+
+	result, err := json.Marshal(RefreshResult{Credential: credential, JWT: "jwt"})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(result, &fields))
+	require.Equal(t, "jwt", fields["jwt"])
+	require.Contains(t, fields["credential"], "validUntil")
+}
+
+func TestParseCredential_VCDM11(t *testing.T) {
+	raw := []byte(`{
+		"@context": ["https://www.w3.org/2018/credentials/v1"],
+		"id": "urn:uuid:1",
+		"issuanceDate": "2024-01-01T00:00:00Z",
+		"expirationDate": "2024-01-02T00:00:00Z",
+		"validUntil": "2030-01-01T00:00:00Z",
+		"credentialSubject": {"id": "did:iden3:owner"}
+	}`)
+
+	credential, err := parseCredential(raw)
+	require.NoError(t, err)
+	require.False(t, isVCDM2(credential))
+	require.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), credential.Expiration.UTC())
+
+	encoded, err := MarshalCredential(credential)
+	require.NoError(t, err)
+	require.Contains(t, string(encoded), `"expirationDate"`)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"reflect"
+
+	"github.com/0xPolygonID/refresh-service/httpclient"
+	"github.com/0xPolygonID/refresh-service/testsupport"
+	"github.com/pkg/errors"
+)
+
+// simulate runs the refresh pipeline for one credential and prints the
+// updated fields and the request the issuer node would get. The issuer node
+// and the data provider can be replaced with fixtures, the data provider
+// also with a mock serving canned responses with latency and failures.
+func simulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	issuer := fs.String("issuer", "", "issuer DID")
+	owner := fs.String("owner", "", "owner DID")
+	claimID := fs.String("claim-id", "", "credential id")
+	credentialFile := fs.String("credential", "", "JSON file with the credential, used in place of the issuer node")
+	providerFile := fs.String("provider-response", "", "JSON file with the data provider response, used in place of the provider")
+	providerMock := fs.String("provider-mock", "", "YAML file with canned data provider responses and failures, served in place of the provider")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *issuer == "" || *owner == "" || *claimID == "" {
+		fs.Usage()
+		return errors.New("--issuer, --owner and --claim-id are required")
+	}
+	if *providerFile != "" && *providerMock != "" {
+		return errors.New("--provider-response and --provider-mock are exclusive")
+	}
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+
+	supportedIssuers, issuerClient := cfg.SupportedIssuers, httpclient.NewClient(httpclient.DefaultOptions, 0)
+	if *credentialFile != "" {
+		if supportedIssuers, issuerClient, err = credentialFixture(*credentialFile); err != nil {
+			return err
+		}
+	}
+	var providerClient *http.Client
+	if *providerFile != "" {
+		body, err := os.ReadFile(*providerFile)
+		if err != nil {
+			return errors.Errorf("failed to read provider response: %v", err)
+		}
+		providerClient = &http.Client{Transport: fixtureTransport{body: body}}
+	}
+	if *providerMock != "" {
+		provider := testsupport.NewProvider()
+		defer provider.Close()
+		if err := provider.Load(*providerMock); err != nil {
+			return err
+		}
+		providerClient = provider.Client()
+	}
+	refreshService, err := cfg.refreshService(supportedIssuers, issuerClient, providerClient)
+	if err != nil {
+		return err
+	}
+
+	simulation, err := refreshService.Simulate(context.Background(), *issuer, *owner, *claimID)
+	if err != nil {
+		return errors.Errorf("refresh failed: %v", err)
+	}
+
+	printf("credential type: %s\n\nchanges:\n", simulation.CredentialType)
+	for _, change := range simulation.Changes {
+		marker := " "
+		if !reflect.DeepEqual(change.Old, change.New) {
+			marker = "~"
+		}
+		printf("%s %s: %v -> %v\n", marker, change.Field, change.Old, change.New)
+	}
+	var request bytes.Buffer
+	if err := json.Indent(&request, simulation.Request, "", "  "); err != nil {
+		return err
+	}
+	printf("\ncredential request:\n%s\n", request.String())
+	return nil
+}
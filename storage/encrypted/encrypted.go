@@ -0,0 +1,117 @@
+package encrypted
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/encryption"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/pkg/errors"
+)
+
+// Store encrypts job results and idempotency responses, which contain
+// credential subjects, before they reach the underlying store.
+type Store struct {
+	storage.Store
+	cipher encryption.Cipher
+}
+
+func NewStore(store storage.Store, cipher encryption.Cipher) *Store {
+	return &Store{
+		Store:  store,
+		cipher: cipher,
+	}
+}
+
+// sealedResult keeps encrypted job results valid JSON.
+type sealedResult struct {
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (s *Store) SaveJob(ctx context.Context, job storage.Job) error {
+	if len(job.Result) > 0 {
+		ciphertext, err := s.cipher.Encrypt(job.Result)
+		if err != nil {
+			return errors.Errorf("failed to encrypt job result: %v", err)
+		}
+		job.Result, err = json.Marshal(sealedResult{Ciphertext: ciphertext})
+		if err != nil {
+			return err
+		}
+	}
+	return s.Store.SaveJob(ctx, job)
+}
+
+func (s *Store) GetJob(ctx context.Context, id string) (storage.Job, error) {
+	job, err := s.Store.GetJob(ctx, id)
+	if err != nil {
+		return job, err
+	}
+	return s.openJob(job)
+}
+
+func (s *Store) ListJobs(ctx context.Context, status string, limit int) ([]storage.Job, error) {
+	jobs, err := s.Store.ListJobs(ctx, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.openJobs(jobs)
+}
+
+func (s *Store) ClaimDueJobs(ctx context.Context, limit int, staleAfter time.Duration) ([]storage.Job, error) {
+	jobs, err := s.Store.ClaimDueJobs(ctx, limit, staleAfter)
+	if err != nil {
+		return nil, err
+	}
+	return s.openJobs(jobs)
+}
+
+func (s *Store) openJobs(jobs []storage.Job) ([]storage.Job, error) {
+	for i := range jobs {
+		var err error
+		if jobs[i], err = s.openJob(jobs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+func (s *Store) openJob(job storage.Job) (storage.Job, error) {
+	if len(job.Result) == 0 {
+		return job, nil
+	}
+	var sealed sealedResult
+	if err := json.Unmarshal(job.Result, &sealed); err != nil || sealed.Ciphertext == nil {
+		return job, errors.Errorf("job '%s' result is not encrypted", job.ID)
+	}
+	plaintext, err := s.cipher.Decrypt(sealed.Ciphertext)
+	if err != nil {
+		return job, errors.Errorf("failed to decrypt job '%s' result: %v", job.ID, err)
+	}
+	job.Result = plaintext
+	return job, nil
+}
+
+func (s *Store) PutIdempotency(ctx context.Context, record storage.IdempotencyRecord) (bool, error) {
+	if len(record.Response) > 0 {
+		var err error
+		record.Response, err = s.cipher.Encrypt(record.Response)
+		if err != nil {
+			return false, errors.Errorf("failed to encrypt idempotency response: %v", err)
+		}
+	}
+	return s.Store.PutIdempotency(ctx, record)
+}
+
+func (s *Store) GetIdempotency(ctx context.Context, key string) (storage.IdempotencyRecord, error) {
+	record, err := s.Store.GetIdempotency(ctx, key)
+	if err != nil || len(record.Response) == 0 {
+		return record, err
+	}
+	record.Response, err = s.cipher.Decrypt(record.Response)
+	if err != nil {
+		return storage.IdempotencyRecord{}, errors.Errorf("failed to decrypt idempotency response: %v", err)
+	}
+	return record, nil
+}
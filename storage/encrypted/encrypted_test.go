@@ -0,0 +1,52 @@
+package encrypted
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/encryption"
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	cipher, err := encryption.NewAESGCM(map[string]string{
+		"v1": base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")),
+	}, "v1")
+	require.NoError(t, err)
+	plain := memory.NewStore()
+	store := NewStore(plain, cipher)
+	ctx := context.Background()
+
+	result := []byte(`{"credentialSubject":{"birthday":19960424}}`)
+	require.NoError(t, store.SaveJob(ctx, storage.Job{ID: "job", Status: storage.JobStatusSucceeded, Result: result}))
+
+	raw, err := plain.GetJob(ctx, "job")
+	require.NoError(t, err)
+	require.NotContains(t, string(raw.Result), "birthday")
+
+	job, err := store.GetJob(ctx, "job")
+	require.NoError(t, err)
+	require.JSONEq(t, string(result), string(job.Result))
+
+	jobs, err := store.ListJobs(ctx, storage.JobStatusSucceeded, 10)
+	require.NoError(t, err)
+	require.JSONEq(t, string(result), string(jobs[0].Result))
+
+	stored, err := store.PutIdempotency(ctx, storage.IdempotencyRecord{
+		Key:       "key",
+		Response:  result,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.True(t, stored)
+	rawRecord, err := plain.GetIdempotency(ctx, "key")
+	require.NoError(t, err)
+	require.NotContains(t, string(rawRecord.Response), "birthday")
+	record, err := store.GetIdempotency(ctx, "key")
+	require.NoError(t, err)
+	require.Equal(t, result, record.Response)
+}
@@ -0,0 +1,404 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/pkg/errors"
+)
+
+// Store keeps state in process memory. It is used when no database is
+// configured, so its state does not survive restarts.
+type Store struct {
+	mu          sync.RWMutex
+	refreshes   []storage.RefreshRecord
+	lastID      int64
+	lineage     map[string]storage.LineageRecord
+	jobs        map[string]storage.Job
+	idempotency map[string]storage.IdempotencyRecord
+	changes     []storage.ConfigChange
+}
+
+func NewStore() *Store {
+	return &Store{
+		lineage:     make(map[string]storage.LineageRecord),
+		jobs:        make(map[string]storage.Job),
+		idempotency: make(map[string]storage.IdempotencyRecord),
+	}
+}
+
+func (s *Store) Ping(context.Context) error {
+	return nil
+}
+
+func (s *Store) Close() {}
+
+func (s *Store) SaveRefresh(_ context.Context, r storage.RefreshRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	r.ID = s.lastID
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now().UTC()
+	}
+	s.refreshes = append(s.refreshes, r)
+	return nil
+}
+
+func (s *Store) ListRefreshes(_ context.Context, filter storage.RefreshFilter) ([]storage.RefreshRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var records []storage.RefreshRecord
+	for i := len(s.refreshes) - 1; i >= 0; i-- {
+		if !matchRefresh(s.refreshes[i], filter) {
+			continue
+		}
+		records = append(records, s.refreshes[i])
+		if filter.Limit > 0 && len(records) == filter.Limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+func (s *Store) CountRefreshes(_ context.Context, filter storage.RefreshFilter) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var count int64
+	for _, r := range s.refreshes {
+		if matchRefresh(r, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func matchRefresh(r storage.RefreshRecord, f storage.RefreshFilter) bool {
+	return (f.Issuer == "" || r.Issuer == f.Issuer) &&
+		(f.Owner == "" || r.Owner == f.Owner) &&
+		(f.CredentialType == "" || r.CredentialType == f.CredentialType) &&
+		(f.CredentialID == "" || r.CredentialID == f.CredentialID) &&
+		(f.Status == "" || r.Status == f.Status) &&
+		(f.From.IsZero() || !r.CreatedAt.Before(f.From)) &&
+		(f.To.IsZero() || r.CreatedAt.Before(f.To))
+}
+
+func (s *Store) RefreshStats(_ context.Context, from time.Time, topErrors int) (storage.RefreshStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		all     []storage.RefreshRecord
+		byType  = make(map[string][]storage.RefreshRecord)
+		byIss   = make(map[string][]storage.RefreshRecord)
+		byError = make(map[int]int64)
+	)
+	for _, r := range s.refreshes {
+		if r.CreatedAt.Before(from) {
+			continue
+		}
+		all = append(all, r)
+		byType[r.CredentialType] = append(byType[r.CredentialType], r)
+		byIss[r.Issuer] = append(byIss[r.Issuer], r)
+		if r.Status == storage.RefreshStatusFailed {
+			byError[r.ErrorCode]++
+		}
+	}
+
+	overall := groupStats("all", all)
+	stats := storage.RefreshStats{
+		From:             from,
+		Total:            overall.Total,
+		Succeeded:        overall.Succeeded,
+		SuccessRate:      overall.SuccessRate,
+		P95LatencyMS:     overall.P95LatencyMS,
+		ByCredentialType: groups(byType),
+		ByIssuer:         groups(byIss),
+	}
+	for code, count := range byError {
+		stats.TopErrorCodes = append(stats.TopErrorCodes, storage.ErrorCodeCount{Code: code, Count: count})
+	}
+	sort.Slice(stats.TopErrorCodes, func(i, j int) bool {
+		if stats.TopErrorCodes[i].Count != stats.TopErrorCodes[j].Count {
+			return stats.TopErrorCodes[i].Count > stats.TopErrorCodes[j].Count
+		}
+		return stats.TopErrorCodes[i].Code < stats.TopErrorCodes[j].Code
+	})
+	if topErrors > 0 && len(stats.TopErrorCodes) > topErrors {
+		stats.TopErrorCodes = stats.TopErrorCodes[:topErrors]
+	}
+	return stats, nil
+}
+
+func groups(records map[string][]storage.RefreshRecord) []storage.GroupStats {
+	result := make([]storage.GroupStats, 0, len(records))
+	for key, rr := range records {
+		result = append(result, groupStats(key, rr))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+func groupStats(key string, records []storage.RefreshRecord) storage.GroupStats {
+	g := storage.GroupStats{Key: key, Total: int64(len(records))}
+	if len(records) == 0 {
+		return g
+	}
+	durations := make([]float64, 0, len(records))
+	for _, r := range records {
+		if r.Status == storage.RefreshStatusSucceeded {
+			g.Succeeded++
+		}
+		durations = append(durations, float64(r.Duration.Milliseconds()))
+	}
+	g.SuccessRate = float64(g.Succeeded) / float64(g.Total)
+	g.P95LatencyMS = percentile(durations, 0.95)
+	return g
+}
+
+// percentile interpolates like Postgres percentile_cont.
+func percentile(values []float64, p float64) float64 {
+	sort.Float64s(values)
+	pos := p * float64(len(values)-1)
+	lower := int(pos)
+	if lower+1 >= len(values) {
+		return values[lower]
+	}
+	return values[lower] + (pos-float64(lower))*(values[lower+1]-values[lower])
+}
+
+func (s *Store) SaveLineage(_ context.Context, r storage.LineageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.lineage[r.CredentialID]; !ok {
+		if r.CreatedAt.IsZero() {
+			r.CreatedAt = time.Now().UTC()
+		}
+		s.lineage[r.CredentialID] = r
+	}
+	return nil
+}
+
+func (s *Store) ListLineage(_ context.Context, from, to time.Time) ([]storage.LineageRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var records []storage.LineageRecord
+	for _, r := range s.lineage {
+		if !r.CreatedAt.Before(from) && r.CreatedAt.Before(to) {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CredentialID < records[j].CredentialID
+		}
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	return records, nil
+}
+
+func (s *Store) Lineage(_ context.Context, credentialID string) ([]storage.LineageRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var chain []storage.LineageRecord
+	for id := credentialID; len(chain) < 1000; {
+		r, ok := s.lineage[id]
+		if !ok {
+			break
+		}
+		chain = append(chain, r)
+		id = r.ParentID
+	}
+	return chain, nil
+}
+
+func (s *Store) SaveJob(_ context.Context, j storage.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	if existing, ok := s.jobs[j.ID]; ok {
+		j.CreatedAt, j.Session = existing.CreatedAt, existing.Session
+	} else if j.CreatedAt.IsZero() {
+		j.CreatedAt = now
+	}
+	if j.NextAttemptAt.IsZero() {
+		j.NextAttemptAt = now
+	}
+	j.UpdatedAt = now
+	s.jobs[j.ID] = j
+	return nil
+}
+
+func (s *Store) GetJob(_ context.Context, id string) (storage.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return storage.Job{}, errors.Wrapf(storage.ErrNotFound, "job '%s'", id)
+	}
+	return j, nil
+}
+
+func (s *Store) ListJobs(_ context.Context, status string, limit int) ([]storage.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if limit <= 0 {
+		limit = 100
+	}
+	jobs := make([]storage.Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		if status == "" || j.Status == status {
+			jobs = append(jobs, j)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].UpdatedAt.After(jobs[j].UpdatedAt)
+	})
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+func (s *Store) ClaimDueJobs(_ context.Context, limit int, staleAfter time.Duration) ([]storage.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	var due []storage.Job
+	for _, j := range s.jobs {
+		pending := j.Status == storage.JobStatusPending && !j.NextAttemptAt.After(now)
+		stale := j.Status == storage.JobStatusRunning && j.UpdatedAt.Before(now.Add(-staleAfter))
+		if pending || stale {
+			due = append(due, j)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].Status = storage.JobStatusRunning
+		due[i].UpdatedAt = now
+		s.jobs[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+func (s *Store) PutIdempotency(_ context.Context, r storage.IdempotencyRecord) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.idempotency[r.Key]; ok && !existing.ExpiresAt.Before(time.Now()) {
+		return false, nil
+	}
+	s.idempotency[r.Key] = r
+	return true, nil
+}
+
+func (s *Store) GetIdempotency(_ context.Context, key string) (storage.IdempotencyRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.idempotency[key]
+	if !ok || r.ExpiresAt.Before(time.Now()) {
+		return storage.IdempotencyRecord{}, errors.Wrapf(storage.ErrNotFound, "idempotency key '%s'", key)
+	}
+	return r, nil
+}
+
+func (s *Store) DeleteRefreshes(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.refreshes[:0]
+	for _, r := range s.refreshes {
+		if !r.CreatedAt.Before(before) {
+			kept = append(kept, r)
+		}
+	}
+	deleted := int64(len(s.refreshes) - len(kept))
+	s.refreshes = kept
+	return deleted, nil
+}
+
+func (s *Store) DeleteLineage(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int64
+	for id, r := range s.lineage {
+		if r.CreatedAt.Before(before) {
+			delete(s.lineage, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Store) DeleteFinishedJobs(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int64
+	for id, j := range s.jobs {
+		switch j.Status {
+		case storage.JobStatusSucceeded, storage.JobStatusFailed, storage.JobStatusDead:
+		default:
+			continue
+		}
+		if j.UpdatedAt.Before(before) {
+			delete(s.jobs, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Store) DeleteExpiredIdempotency(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted int64
+	for key, r := range s.idempotency {
+		if r.ExpiresAt.Before(before) {
+			delete(s.idempotency, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *Store) SaveConfigChange(_ context.Context, c storage.ConfigChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.ID = int64(len(s.changes) + 1)
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = time.Now().UTC()
+	}
+	s.changes = append(s.changes, c)
+	return nil
+}
+
+func (s *Store) ListConfigChanges(_ context.Context, filter storage.ConfigChangeFilter) ([]storage.ConfigChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var changes []storage.ConfigChange
+	for i := len(s.changes) - 1; i >= 0; i-- {
+		c := s.changes[i]
+		if (filter.Target != "" && c.Target != filter.Target) ||
+			(filter.Actor != "" && c.Actor != filter.Actor) ||
+			(!filter.From.IsZero() && c.CreatedAt.Before(filter.From)) ||
+			(!filter.To.IsZero() && !c.CreatedAt.Before(filter.To)) {
+			continue
+		}
+		changes = append(changes, c)
+		if filter.Limit > 0 && len(changes) == filter.Limit {
+			break
+		}
+	}
+	return changes, nil
+}
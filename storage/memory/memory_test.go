@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/0xPolygonID/refresh-service/storage/storagetest"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(*testing.T) storage.Store {
+		return NewStore()
+	})
+}
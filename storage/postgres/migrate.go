@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"embed"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLockID serializes migrations between replicas starting at once.
+const migrationLockID = 7_140_001
+
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	entries, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return errors.Errorf("failed to read migrations: %v", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".sql") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return errors.Errorf("failed to acquire connection: %v", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return errors.Errorf("failed to lock migrations: %v", err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}()
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return errors.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	for _, name := range names {
+		var applied bool
+		err := conn.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, name).Scan(&applied)
+		if err != nil {
+			return errors.Errorf("failed to check migration '%s': %v", name, err)
+		}
+		if applied {
+			continue
+		}
+		script, err := migrations.ReadFile("migrations/" + name)
+		if err != nil {
+			return errors.Errorf("failed to read migration '%s': %v", name, err)
+		}
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(script)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, name)
+			return err
+		})
+		if err != nil {
+			return errors.Errorf("failed to apply migration '%s': %v", name, err)
+		}
+	}
+	return nil
+}
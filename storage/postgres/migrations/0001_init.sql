@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS refresh_history (
+    id              BIGSERIAL PRIMARY KEY,
+    request_id      TEXT        NOT NULL DEFAULT '',
+    issuer          TEXT        NOT NULL,
+    owner           TEXT        NOT NULL,
+    credential_id   TEXT        NOT NULL,
+    refreshed_id    TEXT        NOT NULL DEFAULT '',
+    credential_type TEXT        NOT NULL DEFAULT '',
+    status          TEXT        NOT NULL,
+    error_code      INTEGER     NOT NULL DEFAULT 0,
+    error           TEXT        NOT NULL DEFAULT '',
+    duration_ms     BIGINT      NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS refresh_history_issuer_idx ON refresh_history (issuer, created_at);
+CREATE INDEX IF NOT EXISTS refresh_history_type_idx ON refresh_history (credential_type, created_at);
+CREATE INDEX IF NOT EXISTS refresh_history_credential_idx ON refresh_history (credential_id);
+
+CREATE TABLE IF NOT EXISTS lineage (
+    credential_id TEXT PRIMARY KEY,
+    parent_id     TEXT        NOT NULL,
+    issuer        TEXT        NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS lineage_parent_idx ON lineage (parent_id);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id            TEXT PRIMARY KEY,
+    issuer        TEXT        NOT NULL,
+    owner         TEXT        NOT NULL,
+    credential_id TEXT        NOT NULL,
+    status        TEXT        NOT NULL,
+    attempts      INTEGER     NOT NULL DEFAULT 0,
+    error         TEXT        NOT NULL DEFAULT '',
+    result        JSONB,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS jobs_status_idx ON jobs (status, updated_at);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key        TEXT PRIMARY KEY,
+    response   BYTEA,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_idx ON idempotency_keys (expires_at);
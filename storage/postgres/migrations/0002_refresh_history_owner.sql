@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS refresh_history_owner_idx ON refresh_history (owner, credential_type, created_at);
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS refresh_history_created_idx ON refresh_history (created_at);
+CREATE INDEX IF NOT EXISTS lineage_created_idx ON lineage (created_at);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS config_changes (
+    id         BIGSERIAL PRIMARY KEY,
+    actor      TEXT        NOT NULL,
+    target     TEXT        NOT NULL,
+    source     TEXT        NOT NULL,
+    added      TEXT[],
+    removed    TEXT[],
+    changed    TEXT[],
+    details    JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS config_changes_target_idx ON config_changes (target, created_at);
+CREATE INDEX IF NOT EXISTS config_changes_created_idx ON config_changes (created_at);
@@ -0,0 +1,418 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pkg/errors"
+)
+
+type Store struct {
+	pool *pgxpool.Pool
+}
+
+// NewStore connects to the database and applies pending migrations.
+func NewStore(ctx context.Context, databaseURL string) (*Store, error) {
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		return nil, errors.Errorf("failed to connect to database: %v", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, errors.Errorf("failed to ping database: %v", err)
+	}
+	if err := migrate(ctx, pool); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return &Store{pool: pool}, nil
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+func (s *Store) Close() {
+	s.pool.Close()
+}
+
+func (s *Store) SaveRefresh(ctx context.Context, r storage.RefreshRecord) error {
+	_, err := s.pool.Exec(ctx, `INSERT INTO refresh_history
+		(request_id, issuer, owner, credential_id, refreshed_id, credential_type,
+		 status, error_code, error, duration_ms, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		r.RequestID, r.Issuer, r.Owner, r.CredentialID, r.RefreshedID, r.CredentialType,
+		r.Status, r.ErrorCode, r.Error, r.Duration.Milliseconds(), createdAt(r.CreatedAt))
+	if err != nil {
+		return errors.Errorf("failed to save refresh record: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) ListRefreshes(ctx context.Context, filter storage.RefreshFilter) ([]storage.RefreshRecord, error) {
+	where, args := refreshConditions(filter)
+	query := `SELECT id, request_id, issuer, owner, credential_id, refreshed_id, credential_type,
+		status, error_code, error, duration_ms, created_at FROM refresh_history` + where
+	query += " ORDER BY created_at DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Errorf("failed to query refresh history: %v", err)
+	}
+	records, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.RefreshRecord, error) {
+		var (
+			r          storage.RefreshRecord
+			durationMS int64
+		)
+		err := row.Scan(&r.ID, &r.RequestID, &r.Issuer, &r.Owner, &r.CredentialID, &r.RefreshedID,
+			&r.CredentialType, &r.Status, &r.ErrorCode, &r.Error, &durationMS, &r.CreatedAt)
+		r.Duration = time.Duration(durationMS) * time.Millisecond
+		return r, err
+	})
+	if err != nil {
+		return nil, errors.Errorf("failed to read refresh history: %v", err)
+	}
+	return records, nil
+}
+
+func (s *Store) CountRefreshes(ctx context.Context, filter storage.RefreshFilter) (int64, error) {
+	where, args := refreshConditions(filter)
+	var count int64
+	err := s.pool.QueryRow(ctx, `SELECT count(*) FROM refresh_history`+where, args...).Scan(&count)
+	if err != nil {
+		return 0, errors.Errorf("failed to count refresh history: %v", err)
+	}
+	return count, nil
+}
+
+func refreshConditions(filter storage.RefreshFilter) (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Issuer != "" {
+		addCondition("issuer = $%d", filter.Issuer)
+	}
+	if filter.Owner != "" {
+		addCondition("owner = $%d", filter.Owner)
+	}
+	if filter.CredentialType != "" {
+		addCondition("credential_type = $%d", filter.CredentialType)
+	}
+	if filter.CredentialID != "" {
+		addCondition("credential_id = $%d", filter.CredentialID)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (s *Store) SaveLineage(ctx context.Context, r storage.LineageRecord) error {
+	_, err := s.pool.Exec(ctx, `INSERT INTO lineage (credential_id, parent_id, issuer, created_at)
+		VALUES ($1, $2, $3, $4) ON CONFLICT (credential_id) DO NOTHING`,
+		r.CredentialID, r.ParentID, r.Issuer, createdAt(r.CreatedAt))
+	if err != nil {
+		return errors.Errorf("failed to save lineage: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) Lineage(ctx context.Context, credentialID string) ([]storage.LineageRecord, error) {
+	rows, err := s.pool.Query(ctx, `WITH RECURSIVE chain AS (
+			SELECT credential_id, parent_id, issuer, created_at, 1 AS depth
+			FROM lineage WHERE credential_id = $1
+			UNION ALL
+			SELECT l.credential_id, l.parent_id, l.issuer, l.created_at, c.depth + 1
+			FROM lineage l JOIN chain c ON l.credential_id = c.parent_id
+			WHERE c.depth < 1000
+		)
+		SELECT credential_id, parent_id, issuer, created_at FROM chain ORDER BY depth`,
+		credentialID)
+	if err != nil {
+		return nil, errors.Errorf("failed to query lineage: %v", err)
+	}
+	records, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.LineageRecord, error) {
+		var r storage.LineageRecord
+		err := row.Scan(&r.CredentialID, &r.ParentID, &r.Issuer, &r.CreatedAt)
+		return r, err
+	})
+	if err != nil {
+		return nil, errors.Errorf("failed to read lineage: %v", err)
+	}
+	return records, nil
+}
+
+func (s *Store) ListLineage(ctx context.Context, from, to time.Time) ([]storage.LineageRecord, error) {
+	rows, err := s.pool.Query(ctx, `SELECT credential_id, parent_id, issuer, created_at FROM lineage
+		WHERE created_at >= $1 AND created_at < $2 ORDER BY created_at, credential_id`,
+		from, to)
+	if err != nil {
+		return nil, errors.Errorf("failed to query lineage: %v", err)
+	}
+	records, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.LineageRecord, error) {
+		var r storage.LineageRecord
+		err := row.Scan(&r.CredentialID, &r.ParentID, &r.Issuer, &r.CreatedAt)
+		return r, err
+	})
+	if err != nil {
+		return nil, errors.Errorf("failed to read lineage: %v", err)
+	}
+	return records, nil
+}
+
+const jobColumns = `id, issuer, owner, credential_id, status, attempts, error, error_code,
+	error_class, result, next_attempt_at, created_at, updated_at, session`
+
+func (s *Store) SaveJob(ctx context.Context, j storage.Job) error {
+	now := time.Now().UTC()
+	nextAttemptAt := j.NextAttemptAt
+	if nextAttemptAt.IsZero() {
+		nextAttemptAt = now
+	}
+	_, err := s.pool.Exec(ctx, `INSERT INTO jobs (`+jobColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			attempts = EXCLUDED.attempts,
+			error = EXCLUDED.error,
+			error_code = EXCLUDED.error_code,
+			error_class = EXCLUDED.error_class,
+			result = EXCLUDED.result,
+			next_attempt_at = EXCLUDED.next_attempt_at,
+			updated_at = EXCLUDED.updated_at`,
+		j.ID, j.Issuer, j.Owner, j.CredentialID, j.Status, j.Attempts, j.Error, j.ErrorCode,
+		j.ErrorClass, nullableJSON(j.Result), nextAttemptAt, createdAt(j.CreatedAt), now, j.Session)
+	if err != nil {
+		return errors.Errorf("failed to save job: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) GetJob(ctx context.Context, id string) (storage.Job, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id)
+	if err != nil {
+		return storage.Job{}, errors.Errorf("failed to query job: %v", err)
+	}
+	job, err := pgx.CollectExactlyOneRow(rows, scanJob)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.Job{}, errors.Wrapf(storage.ErrNotFound, "job '%s'", id)
+	}
+	if err != nil {
+		return storage.Job{}, errors.Errorf("failed to read job: %v", err)
+	}
+	return job, nil
+}
+
+func (s *Store) ListJobs(ctx context.Context, status string, limit int) ([]storage.Job, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.pool.Query(ctx, `SELECT `+jobColumns+` FROM jobs
+		WHERE $1 = '' OR status = $1 ORDER BY updated_at DESC LIMIT $2`, status, limit)
+	if err != nil {
+		return nil, errors.Errorf("failed to query jobs: %v", err)
+	}
+	jobs, err := pgx.CollectRows(rows, scanJob)
+	if err != nil {
+		return nil, errors.Errorf("failed to read jobs: %v", err)
+	}
+	return jobs, nil
+}
+
+func (s *Store) ClaimDueJobs(ctx context.Context, limit int, staleAfter time.Duration) ([]storage.Job, error) {
+	rows, err := s.pool.Query(ctx, `UPDATE jobs SET status = $1, updated_at = now()
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE (status = $2 AND next_attempt_at <= now())
+			   OR (status = $1 AND updated_at < now() - make_interval(secs => $3))
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+jobColumns,
+		storage.JobStatusRunning, storage.JobStatusPending, staleAfter.Seconds(), limit)
+	if err != nil {
+		return nil, errors.Errorf("failed to claim jobs: %v", err)
+	}
+	jobs, err := pgx.CollectRows(rows, scanJob)
+	if err != nil {
+		return nil, errors.Errorf("failed to read claimed jobs: %v", err)
+	}
+	return jobs, nil
+}
+
+func scanJob(row pgx.CollectableRow) (storage.Job, error) {
+	var (
+		j      storage.Job
+		result []byte
+	)
+	err := row.Scan(&j.ID, &j.Issuer, &j.Owner, &j.CredentialID, &j.Status, &j.Attempts,
+		&j.Error, &j.ErrorCode, &j.ErrorClass, &result, &j.NextAttemptAt, &j.CreatedAt, &j.UpdatedAt, &j.Session)
+	j.Result = result
+	return j, err
+}
+
+func (s *Store) PutIdempotency(ctx context.Context, r storage.IdempotencyRecord) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `INSERT INTO idempotency_keys (key, response, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET response = EXCLUDED.response, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < now()`,
+		r.Key, r.Response, r.ExpiresAt)
+	if err != nil {
+		return false, errors.Errorf("failed to save idempotency key: %v", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *Store) GetIdempotency(ctx context.Context, key string) (storage.IdempotencyRecord, error) {
+	r := storage.IdempotencyRecord{Key: key}
+	err := s.pool.QueryRow(ctx, `SELECT response, expires_at FROM idempotency_keys
+		WHERE key = $1 AND expires_at >= now()`, key).Scan(&r.Response, &r.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.IdempotencyRecord{}, errors.Wrapf(storage.ErrNotFound, "idempotency key '%s'", key)
+	}
+	if err != nil {
+		return storage.IdempotencyRecord{}, errors.Errorf("failed to read idempotency key: %v", err)
+	}
+	return r, nil
+}
+
+// deleteBatch bounds the rows deleted by one statement, so cleaning up a
+// large backlog doesn't hold locks for long.
+const deleteBatch = 10_000
+
+func (s *Store) DeleteRefreshes(ctx context.Context, before time.Time) (int64, error) {
+	return s.deleteBatched(ctx, "refresh history", `DELETE FROM refresh_history WHERE id IN (
+		SELECT id FROM refresh_history WHERE created_at < $1 LIMIT $2)`, before)
+}
+
+func (s *Store) DeleteLineage(ctx context.Context, before time.Time) (int64, error) {
+	return s.deleteBatched(ctx, "lineage", `DELETE FROM lineage WHERE credential_id IN (
+		SELECT credential_id FROM lineage WHERE created_at < $1 LIMIT $2)`, before)
+}
+
+func (s *Store) DeleteFinishedJobs(ctx context.Context, before time.Time) (int64, error) {
+	return s.deleteBatched(ctx, "jobs", `DELETE FROM jobs WHERE id IN (
+		SELECT id FROM jobs WHERE status IN ('`+storage.JobStatusSucceeded+`', '`+
+		storage.JobStatusFailed+`', '`+storage.JobStatusDead+`') AND updated_at < $1 LIMIT $2)`, before)
+}
+
+func (s *Store) DeleteExpiredIdempotency(ctx context.Context, before time.Time) (int64, error) {
+	return s.deleteBatched(ctx, "idempotency keys", `DELETE FROM idempotency_keys WHERE key IN (
+		SELECT key FROM idempotency_keys WHERE expires_at < $1 LIMIT $2)`, before)
+}
+
+// deleteBatched runs query, which deletes up to $2 rows older than $1,
+// until it deletes less than a full batch.
+func (s *Store) deleteBatched(ctx context.Context, table, query string, before time.Time) (int64, error) {
+	var deleted int64
+	for {
+		tag, err := s.pool.Exec(ctx, query, before, deleteBatch)
+		if err != nil {
+			return deleted, errors.Errorf("failed to delete %s: %v", table, err)
+		}
+		deleted += tag.RowsAffected()
+		if tag.RowsAffected() < deleteBatch {
+			return deleted, nil
+		}
+	}
+}
+
+func (s *Store) SaveConfigChange(ctx context.Context, c storage.ConfigChange) error {
+	_, err := s.pool.Exec(ctx, `INSERT INTO config_changes
+		(actor, target, source, added, removed, changed, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		c.Actor, c.Target, c.Source, c.Added, c.Removed, c.Changed, nullableJSON(c.Details), createdAt(c.CreatedAt))
+	if err != nil {
+		return errors.Errorf("failed to save config change: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) ListConfigChanges(ctx context.Context, filter storage.ConfigChangeFilter) ([]storage.ConfigChange, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Target != "" {
+		addCondition("target = $%d", filter.Target)
+	}
+	if filter.Actor != "" {
+		addCondition("actor = $%d", filter.Actor)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+	query := `SELECT id, actor, target, source, added, removed, changed, details, created_at FROM config_changes`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Errorf("failed to query config changes: %v", err)
+	}
+	changes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.ConfigChange, error) {
+		var (
+			c       storage.ConfigChange
+			details []byte
+		)
+		err := row.Scan(&c.ID, &c.Actor, &c.Target, &c.Source, &c.Added, &c.Removed, &c.Changed,
+			&details, &c.CreatedAt)
+		c.Details = details
+		return c, err
+	})
+	if err != nil {
+		return nil, errors.Errorf("failed to read config changes: %v", err)
+	}
+	return changes, nil
+}
+
+func createdAt(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+	return t
+}
+
+func nullableJSON(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
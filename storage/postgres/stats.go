@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+func (s *Store) RefreshStats(ctx context.Context, from time.Time, topErrors int) (storage.RefreshStats, error) {
+	stats := storage.RefreshStats{From: from}
+
+	overall, err := s.groupStats(ctx, "'all'", from)
+	if err != nil {
+		return stats, err
+	}
+	if len(overall) == 1 {
+		stats.Total = overall[0].Total
+		stats.Succeeded = overall[0].Succeeded
+		stats.SuccessRate = overall[0].SuccessRate
+		stats.P95LatencyMS = overall[0].P95LatencyMS
+	}
+	if stats.ByCredentialType, err = s.groupStats(ctx, "credential_type", from); err != nil {
+		return stats, err
+	}
+	if stats.ByIssuer, err = s.groupStats(ctx, "issuer", from); err != nil {
+		return stats, err
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT error_code, count(*) FROM refresh_history
+		WHERE created_at >= $1 AND status = $2
+		GROUP BY error_code ORDER BY count(*) DESC, error_code LIMIT $3`,
+		from, storage.RefreshStatusFailed, topErrors)
+	if err != nil {
+		return stats, errors.Errorf("failed to query error codes: %v", err)
+	}
+	stats.TopErrorCodes, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.ErrorCodeCount, error) {
+		var c storage.ErrorCodeCount
+		err := row.Scan(&c.Code, &c.Count)
+		return c, err
+	})
+	if err != nil {
+		return stats, errors.Errorf("failed to read error codes: %v", err)
+	}
+	return stats, nil
+}
+
+// groupStats aggregates refresh history by column. The column is never
+// taken from user input.
+func (s *Store) groupStats(ctx context.Context, column string, from time.Time) ([]storage.GroupStats, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+column+` AS key,
+			count(*) AS total,
+			count(*) FILTER (WHERE status = $2) AS succeeded,
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms), 0) AS p95
+		FROM refresh_history
+		WHERE created_at >= $1
+		GROUP BY 1 ORDER BY total DESC`,
+		from, storage.RefreshStatusSucceeded)
+	if err != nil {
+		return nil, errors.Errorf("failed to query refresh stats: %v", err)
+	}
+	groups, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.GroupStats, error) {
+		var g storage.GroupStats
+		err := row.Scan(&g.Key, &g.Total, &g.Succeeded, &g.P95LatencyMS)
+		if g.Total > 0 {
+			g.SuccessRate = float64(g.Succeeded) / float64(g.Total)
+		}
+		return g, err
+	})
+	if err != nil {
+		return nil, errors.Errorf("failed to read refresh stats: %v", err)
+	}
+	return groups, nil
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrNotFound = errors.New("record not found")
+
+const (
+	RefreshStatusSucceeded = "succeeded"
+	RefreshStatusFailed    = "failed"
+)
+
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	// JobStatusDead marks jobs in the dead-letter queue.
+	JobStatusDead = "dead"
+)
+
+const (
+	ErrorClassTransient = "transient"
+	ErrorClassPermanent = "permanent"
+)
+
+// RefreshRecord is one entry of the refresh history.
+type RefreshRecord struct {
+	ID             int64         `json:"id"`
+	RequestID      string        `json:"requestId,omitempty"`
+	Issuer         string        `json:"issuer"`
+	Owner          string        `json:"owner"`
+	CredentialID   string        `json:"credentialId"`
+	RefreshedID    string        `json:"refreshedId,omitempty"`
+	CredentialType string        `json:"credentialType,omitempty"`
+	Status         string        `json:"status"`
+	ErrorCode      int           `json:"errorCode,omitempty"`
+	Error          string        `json:"error,omitempty"`
+	Duration       time.Duration `json:"duration"`
+	CreatedAt      time.Time     `json:"createdAt"`
+}
+
+type RefreshFilter struct {
+	Issuer         string
+	Owner          string
+	CredentialType string
+	CredentialID   string
+	Status         string
+	From           time.Time
+	To             time.Time
+	Limit          int
+}
+
+// LineageRecord links a reissued credential to the credential it replaced.
+type LineageRecord struct {
+	CredentialID string    `json:"credentialId"`
+	ParentID     string    `json:"parentId"`
+	Issuer       string    `json:"issuer"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type Job struct {
+	ID            string          `json:"id"`
+	Issuer        string          `json:"issuer"`
+	Owner         string          `json:"owner"`
+	CredentialID  string          `json:"credentialId"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	Error         string          `json:"error,omitempty"`
+	ErrorCode     int             `json:"errorCode,omitempty"`
+	ErrorClass    string          `json:"errorClass,omitempty"`
+	Result        json.RawMessage `json:"result,omitempty"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+	// Session is the hash of the owner session the job is bound to, empty
+	// for jobs enqueued through the admin API. It never leaves the service.
+	Session string `json:"-"`
+}
+
+type IdempotencyRecord struct {
+	Key       string    `json:"key"`
+	Response  []byte    `json:"response,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type RefreshHistory interface {
+	SaveRefresh(ctx context.Context, record RefreshRecord) error
+	ListRefreshes(ctx context.Context, filter RefreshFilter) ([]RefreshRecord, error)
+	CountRefreshes(ctx context.Context, filter RefreshFilter) (int64, error)
+}
+
+type Lineage interface {
+	SaveLineage(ctx context.Context, record LineageRecord) error
+	// Lineage returns the chain of credentials replaced by credentialID,
+	// starting with the closest ancestor.
+	Lineage(ctx context.Context, credentialID string) ([]LineageRecord, error)
+}
+
+// LineageLog lists lineage records by creation time, for export.
+type LineageLog interface {
+	// ListLineage returns the lineage records created in [from, to),
+	// oldest first.
+	ListLineage(ctx context.Context, from, to time.Time) ([]LineageRecord, error)
+}
+
+type Jobs interface {
+	SaveJob(ctx context.Context, job Job) error
+	GetJob(ctx context.Context, id string) (Job, error)
+	ListJobs(ctx context.Context, status string, limit int) ([]Job, error)
+	// ClaimDueJobs marks up to limit pending jobs whose next attempt is due
+	// as running and returns them. Running jobs not updated for staleAfter
+	// are claimed again, since their runner is considered dead.
+	ClaimDueJobs(ctx context.Context, limit int, staleAfter time.Duration) ([]Job, error)
+}
+
+type Idempotency interface {
+	// PutIdempotency stores the record if its key is not used yet and
+	// reports whether it was stored.
+	PutIdempotency(ctx context.Context, record IdempotencyRecord) (bool, error)
+	GetIdempotency(ctx context.Context, key string) (IdempotencyRecord, error)
+}
+
+// Retention deletes state which is no longer needed. Every method returns
+// the number of deleted records.
+type Retention interface {
+	DeleteRefreshes(ctx context.Context, before time.Time) (int64, error)
+	DeleteLineage(ctx context.Context, before time.Time) (int64, error)
+	// DeleteFinishedJobs deletes succeeded, failed and dead jobs last
+	// updated before.
+	DeleteFinishedJobs(ctx context.Context, before time.Time) (int64, error)
+	// DeleteExpiredIdempotency deletes records which expired before.
+	DeleteExpiredIdempotency(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ConfigChange is a change of the runtime configuration, e.g. a provider
+// reload. Added, Removed and Changed list the affected keys, like
+// credential types or flag names.
+type ConfigChange struct {
+	ID     int64  `json:"id"`
+	Actor  string `json:"actor"`
+	Target string `json:"target"`
+	// Source is how the change was made, 'admin-api' or 'reload'.
+	Source    string          `json:"source"`
+	Added     []string        `json:"added,omitempty"`
+	Removed   []string        `json:"removed,omitempty"`
+	Changed   []string        `json:"changed,omitempty"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type ConfigChangeFilter struct {
+	Target string
+	Actor  string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+type ConfigAudit interface {
+	SaveConfigChange(ctx context.Context, change ConfigChange) error
+	// ListConfigChanges returns the matching changes, newest first.
+	ListConfigChanges(ctx context.Context, filter ConfigChangeFilter) ([]ConfigChange, error)
+}
+
+// Store is the persistence layer of the refresh service.
+type Store interface {
+	RefreshHistory
+	Statistics
+	Lineage
+	LineageLog
+	Jobs
+	Idempotency
+	Retention
+	ConfigAudit
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// GroupStats are refresh statistics of one issuer or credential type.
+type GroupStats struct {
+	Key          string  `json:"key"`
+	Total        int64   `json:"total"`
+	Succeeded    int64   `json:"succeeded"`
+	SuccessRate  float64 `json:"successRate"`
+	P95LatencyMS float64 `json:"p95LatencyMs"`
+}
+
+type ErrorCodeCount struct {
+	Code  int   `json:"code"`
+	Count int64 `json:"count"`
+}
+
+type RefreshStats struct {
+	From             time.Time        `json:"from"`
+	Total            int64            `json:"total"`
+	Succeeded        int64            `json:"succeeded"`
+	SuccessRate      float64          `json:"successRate"`
+	P95LatencyMS     float64          `json:"p95LatencyMs"`
+	ByCredentialType []GroupStats     `json:"byCredentialType"`
+	ByIssuer         []GroupStats     `json:"byIssuer"`
+	TopErrorCodes    []ErrorCodeCount `json:"topErrorCodes"`
+}
+
+type Statistics interface {
+	// RefreshStats aggregates the refresh history recorded since from.
+	RefreshStats(ctx context.Context, from time.Time, topErrors int) (RefreshStats, error)
+}
@@ -0,0 +1,236 @@
+// Package storagetest checks that implementations of storage.Store behave
+// alike, so the memory store stays a faithful stand-in for Postgres.
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// Run tests the store returned by newStore, which must be empty.
+func Run(t *testing.T, newStore func(t *testing.T) storage.Store) {
+	t.Run("Idempotency", func(t *testing.T) { testIdempotency(t, newStore(t)) })
+	t.Run("ClaimDueJobs", func(t *testing.T) { testClaimDueJobs(t, newStore(t)) })
+	t.Run("ClaimDueJobs limit", func(t *testing.T) { testClaimDueJobsLimit(t, newStore(t)) })
+	t.Run("Retention", func(t *testing.T) { testRetention(t, newStore(t)) })
+	t.Run("RefreshStats", func(t *testing.T) { testRefreshStats(t, newStore(t)) })
+}
+
+func testIdempotency(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	stored, err := store.PutIdempotency(ctx, storage.IdempotencyRecord{
+		Key: "live", Response: []byte("first"), ExpiresAt: now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.True(t, stored)
+	stored, err = store.PutIdempotency(ctx, storage.IdempotencyRecord{
+		Key: "live", Response: []byte("second"), ExpiresAt: now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.False(t, stored, "a live key is kept")
+	record, err := store.GetIdempotency(ctx, "live")
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), record.Response)
+
+	stored, err = store.PutIdempotency(ctx, storage.IdempotencyRecord{
+		Key: "expired", Response: []byte("first"), ExpiresAt: now.Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.True(t, stored)
+	_, err = store.GetIdempotency(ctx, "expired")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	stored, err = store.PutIdempotency(ctx, storage.IdempotencyRecord{
+		Key: "expired", Response: []byte("second"), ExpiresAt: now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.True(t, stored, "an expired key is reused")
+	record, err = store.GetIdempotency(ctx, "expired")
+	require.NoError(t, err)
+	require.Equal(t, []byte("second"), record.Response)
+
+	_, err = store.GetIdempotency(ctx, "unknown")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testClaimDueJobs(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, job := range []storage.Job{
+		{ID: "due", Status: storage.JobStatusPending, NextAttemptAt: now.Add(-time.Minute)},
+		{ID: "later", Status: storage.JobStatusPending, NextAttemptAt: now.Add(time.Hour)},
+		{ID: "running", Status: storage.JobStatusRunning, NextAttemptAt: now.Add(-time.Minute)},
+		{ID: "succeeded", Status: storage.JobStatusSucceeded, NextAttemptAt: now.Add(-time.Minute)},
+		{ID: "dead", Status: storage.JobStatusDead, NextAttemptAt: now.Add(-time.Minute)},
+	} {
+		require.NoError(t, store.SaveJob(ctx, job))
+	}
+
+	claimed, err := store.ClaimDueJobs(ctx, 10, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, []string{"due"}, jobIDs(claimed))
+	require.Equal(t, storage.JobStatusRunning, claimed[0].Status)
+	job, err := store.GetJob(ctx, "due")
+	require.NoError(t, err)
+	require.Equal(t, storage.JobStatusRunning, job.Status)
+
+	claimed, err = store.ClaimDueJobs(ctx, 10, time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, claimed, "claimed jobs are running")
+
+	// running jobs not updated for staleAfter are claimed again
+	time.Sleep(50 * time.Millisecond)
+	claimed, err = store.ClaimDueJobs(ctx, 10, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"due", "running"}, jobIDs(claimed))
+}
+
+func testClaimDueJobsLimit(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, job := range []storage.Job{
+		{ID: "second", Status: storage.JobStatusPending, NextAttemptAt: now.Add(-2 * time.Minute)},
+		{ID: "third", Status: storage.JobStatusPending, NextAttemptAt: now.Add(-time.Minute)},
+		{ID: "first", Status: storage.JobStatusPending, NextAttemptAt: now.Add(-3 * time.Minute)},
+	} {
+		require.NoError(t, store.SaveJob(ctx, job))
+	}
+
+	// the oldest due jobs are claimed, in any order
+	claimed, err := store.ClaimDueJobs(ctx, 2, time.Hour)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"first", "second"}, jobIDs(claimed))
+	claimed, err = store.ClaimDueJobs(ctx, 2, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, []string{"third"}, jobIDs(claimed))
+}
+
+func testRetention(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	old, cutoff := now.Add(-48*time.Hour), now.Add(-24*time.Hour)
+
+	for _, record := range []storage.RefreshRecord{
+		{Issuer: "did:iden3:issuer", CredentialID: "old", Status: storage.RefreshStatusSucceeded, CreatedAt: old},
+		{Issuer: "did:iden3:issuer", CredentialID: "new", Status: storage.RefreshStatusSucceeded, CreatedAt: now},
+	} {
+		require.NoError(t, store.SaveRefresh(ctx, record))
+	}
+	deleted, err := store.DeleteRefreshes(ctx, cutoff)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, deleted)
+	refreshes, err := store.ListRefreshes(ctx, storage.RefreshFilter{})
+	require.NoError(t, err)
+	require.Len(t, refreshes, 1)
+	require.Equal(t, "new", refreshes[0].CredentialID)
+
+	for _, record := range []storage.LineageRecord{
+		{CredentialID: "old", ParentID: "older", Issuer: "did:iden3:issuer", CreatedAt: old},
+		{CredentialID: "new", ParentID: "old", Issuer: "did:iden3:issuer", CreatedAt: now},
+	} {
+		require.NoError(t, store.SaveLineage(ctx, record))
+	}
+	deleted, err = store.DeleteLineage(ctx, cutoff)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, deleted)
+	lineage, err := store.ListLineage(ctx, time.Time{}, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, lineage, 1)
+	require.Equal(t, "new", lineage[0].CredentialID)
+
+	// jobs are deleted by the time of their last update, which is when they
+	// were saved
+	for _, job := range []storage.Job{
+		{ID: "pending", Status: storage.JobStatusPending},
+		{ID: "running", Status: storage.JobStatusRunning},
+		{ID: "succeeded", Status: storage.JobStatusSucceeded},
+		{ID: "failed", Status: storage.JobStatusFailed},
+		{ID: "dead", Status: storage.JobStatusDead},
+	} {
+		require.NoError(t, store.SaveJob(ctx, job))
+	}
+	deleted, err = store.DeleteFinishedJobs(ctx, now.Add(-time.Minute))
+	require.NoError(t, err)
+	require.Zero(t, deleted, "jobs updated since are kept")
+	deleted, err = store.DeleteFinishedJobs(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.EqualValues(t, 3, deleted)
+	jobs, err := store.ListJobs(ctx, "", 10)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"pending", "running"}, jobIDs(jobs))
+
+	for _, record := range []storage.IdempotencyRecord{
+		{Key: "expired", ExpiresAt: now.Add(-time.Hour)},
+		{Key: "live", ExpiresAt: now.Add(time.Hour)},
+	} {
+		_, err := store.PutIdempotency(ctx, record)
+		require.NoError(t, err)
+	}
+	deleted, err = store.DeleteExpiredIdempotency(ctx, now)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, deleted)
+	_, err = store.GetIdempotency(ctx, "live")
+	require.NoError(t, err)
+}
+
+func testRefreshStats(t *testing.T, store storage.Store) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	from := now.Add(-time.Hour)
+
+	records := []storage.RefreshRecord{
+		{CredentialType: "KYCAgeCredential", Status: storage.RefreshStatusSucceeded, Duration: 100 * time.Millisecond},
+		{CredentialType: "KYCAgeCredential", Status: storage.RefreshStatusSucceeded, Duration: 200 * time.Millisecond},
+		{CredentialType: "KYCAgeCredential", Status: storage.RefreshStatusSucceeded, Duration: 300 * time.Millisecond},
+		{CredentialType: "KYCAgeCredential", Status: storage.RefreshStatusFailed, ErrorCode: 2000, Duration: 400 * time.Millisecond},
+		{CredentialType: "BalanceCredential", Status: storage.RefreshStatusFailed, ErrorCode: 1000, Duration: 100 * time.Millisecond},
+		{CredentialType: "BalanceCredential", Status: storage.RefreshStatusFailed, ErrorCode: 1000, Duration: 100 * time.Millisecond},
+		// recorded before from, left out
+		{CredentialType: "BalanceCredential", Status: storage.RefreshStatusFailed, ErrorCode: 3000, CreatedAt: from.Add(-time.Minute)},
+	}
+	for i, record := range records {
+		record.Issuer, record.Owner, record.CredentialID = "did:iden3:issuer", "did:iden3:owner", "credential"
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = now.Add(-time.Duration(i) * time.Second)
+		}
+		require.NoError(t, store.SaveRefresh(ctx, record))
+	}
+
+	stats, err := store.RefreshStats(ctx, from, 1)
+	require.NoError(t, err)
+	require.EqualValues(t, 6, stats.Total)
+	require.EqualValues(t, 3, stats.Succeeded)
+	require.InDelta(t, 0.5, stats.SuccessRate, 1e-9)
+	require.Equal(t, []storage.ErrorCodeCount{{Code: 1000, Count: 2}}, stats.TopErrorCodes)
+
+	require.Len(t, stats.ByCredentialType, 2)
+	kyc, balance := stats.ByCredentialType[0], stats.ByCredentialType[1]
+	require.Equal(t, "KYCAgeCredential", kyc.Key)
+	require.EqualValues(t, 4, kyc.Total)
+	require.InDelta(t, 0.75, kyc.SuccessRate, 1e-9)
+	require.InDelta(t, 385, kyc.P95LatencyMS, 1e-9)
+	require.Equal(t, "BalanceCredential", balance.Key)
+	require.EqualValues(t, 2, balance.Total)
+	require.Zero(t, balance.Succeeded)
+
+	require.Len(t, stats.ByIssuer, 1)
+	require.Equal(t, "did:iden3:issuer", stats.ByIssuer[0].Key)
+	require.EqualValues(t, 6, stats.ByIssuer[0].Total)
+
+	stats, err = store.RefreshStats(ctx, from, 10)
+	require.NoError(t, err)
+	require.Equal(t, []storage.ErrorCodeCount{{Code: 1000, Count: 2}, {Code: 2000, Count: 1}}, stats.TopErrorCodes)
+}
+
+func jobIDs(jobs []storage.Job) []string {
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// testProvider calls the data provider configured for a credential type with
+// a sample credential subject and prints the fields it would update.
+func testProvider(args []string) error {
+	fs := flag.NewFlagSet("test-provider", flag.ContinueOnError)
+	configPath := fs.String("config", "", "provider configuration, HTTP_CONFIG_PATH by default")
+	credentialType := fs.String("type", "", "credential type of the provider configuration")
+	subjectFile := fs.String("subject", "", "JSON file with a sample credentialSubject")
+	responseFile := fs.String("response", "", "JSON file with the provider response, used in place of the provider")
+	schemaFile := fs.String("schema", "", "JSON schema of the credential to validate the updated fields against")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *subjectFile == "" {
+		fs.Usage()
+		return errors.New("--subject is required")
+	}
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+	if *configPath != "" {
+		cfg.HTTPConfigPath = *configPath
+	}
+	var providerClient *http.Client
+	if *responseFile != "" {
+		body, err := os.ReadFile(*responseFile)
+		if err != nil {
+			return errors.Errorf("failed to read provider response: %v", err)
+		}
+		providerClient = &http.Client{Transport: fixtureTransport{body: body}}
+	}
+	providers, err := cfg.providerFactory(providerClient)
+	if err != nil {
+		return errors.Errorf("failed init flexiblehttp: %v", err)
+	}
+	if *credentialType == "" {
+		types := providers.CredentialTypes()
+		if len(types) != 1 {
+			return errors.Errorf("--type is required, configured types: %v", types)
+		}
+		*credentialType = types[0]
+	}
+	provider, err := providers.ProduceFlexibleHTTP(*credentialType)
+	if err != nil {
+		return err
+	}
+
+	var subject map[string]interface{}
+	if err := readJSON(*subjectFile, &subject); err != nil {
+		return errors.Errorf("failed to read credential subject: %v", err)
+	}
+
+	var problems []error
+	problems = append(problems, provider.Validate()...)
+	updatedFields, err := provider.Provide(context.Background(), subject)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	if *schemaFile != "" && updatedFields != nil {
+		schemaProblems, err := validateSubjectFields(*schemaFile, updatedFields)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, schemaProblems...)
+	}
+
+	printf("updated fields:\n")
+	fields := make([]string, 0, len(updatedFields))
+	for field := range updatedFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		printf("  %s: %v (%T)\n", field, updatedFields[field], updatedFields[field])
+	}
+	if len(problems) == 0 {
+		printf("\nvalidation: ok\n")
+		return nil
+	}
+	printf("\nvalidation:\n")
+	for _, problem := range problems {
+		printf("  - %v\n", problem)
+	}
+	return errors.Errorf("validation failed with %d problem(s)", len(problems))
+}
+
+// validateSubjectFields checks that the updated fields are credentialSubject
+// properties of the JSON schema and have the declared types.
+func validateSubjectFields(schemaFile string, fields map[string]interface{}) ([]error, error) {
+	var schema struct {
+		Properties struct {
+			CredentialSubject struct {
+				Properties map[string]struct {
+					Type json.RawMessage `json:"type"`
+				} `json:"properties"`
+			} `json:"credentialSubject"`
+		} `json:"properties"`
+	}
+	if err := readJSON(schemaFile, &schema); err != nil {
+		return nil, errors.Errorf("failed to read schema: %v", err)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []error
+	for _, name := range names {
+		property, ok := schema.Properties.CredentialSubject.Properties[name]
+		if !ok {
+			problems = append(problems, errors.Errorf("field '%s' is not in the schema", name))
+			continue
+		}
+		// type is either a single type or a list of types
+		var types []string
+		var single string
+		if err := json.Unmarshal(property.Type, &single); err == nil {
+			types = []string{single}
+		} else if err := json.Unmarshal(property.Type, &types); err != nil {
+			problems = append(problems, errors.Errorf("field '%s' has no type in the schema", name))
+			continue
+		}
+		if !matchesJSONType(fields[name], types) {
+			problems = append(problems, errors.Errorf("field '%s' is %T, the schema expects %v", name, fields[name], types))
+		}
+	}
+	return problems, nil
+}
+
+func matchesJSONType(v interface{}, types []string) bool {
+	for _, t := range types {
+		switch value := v.(type) {
+		case string:
+			if t == "string" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case int:
+			if t == "integer" || t == "number" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && value == float64(int64(value))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func readJSON(path string, v interface{}) error {
+	//nolint:gosec // the path is given by the operator
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
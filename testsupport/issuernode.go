@@ -0,0 +1,417 @@
+// Package testsupport runs in-process fakes of the upstreams of the refresh
+// service, so integration tests of the service, or of code embedding it,
+// don't need an issuer node or data providers running in Docker.
+package testsupport
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+)
+
+// Issuer node endpoints, to select the requests a Failure applies to.
+const (
+	EndpointStatus           = "status"
+	EndpointGetCredential    = "get credential"
+	EndpointCreateCredential = "create credential"
+	EndpointRefresh          = "refresh credential"
+	EndpointRevoke           = "revoke"
+	EndpointRevocationStatus = "revocation status"
+)
+
+const w3cCredentialContext = "https://www.w3.org/2018/credentials/v1"
+
+// Failure makes requests to Endpoint, or to every endpoint when empty, fail
+// with Status after Latency. Without Status the connection is closed with no
+// response. Times limits how many requests fail, 0 fails all of them.
+type Failure struct {
+	Endpoint string        `yaml:"endpoint"`
+	Status   int           `yaml:"status"`
+	Latency  time.Duration `yaml:"latency"`
+	Times    int           `yaml:"times"`
+}
+
+// CreateRequest is the body of a credential creation or native refresh
+// request the issuer node received.
+type CreateRequest struct {
+	CredentialSchema     string                     `json:"credentialSchema"`
+	Type                 string                     `json:"type"`
+	CredentialSubject    map[string]interface{}     `json:"credentialSubject"`
+	Expiration           int64                      `json:"expiration"`
+	IssuanceDate         *time.Time                 `json:"issuanceDate,omitempty"`
+	ValidFrom            *time.Time                 `json:"validFrom,omitempty"`
+	ValidUntil           *time.Time                 `json:"validUntil,omitempty"`
+	RefreshService       *verifiable.RefreshService `json:"refreshService,omitempty"`
+	RevNonce             *uint64                    `json:"revNonce,omitempty"`
+	DisplayMethod        *verifiable.DisplayMethod  `json:"displayMethod,omitempty"`
+	CredentialStatusType string                     `json:"credentialStatusType,omitempty"`
+}
+
+type IssuerNodeOption func(*IssuerNode)
+
+// WithIssuerBasicAuth requires basic auth with user and password.
+func WithIssuerBasicAuth(user, password string) IssuerNodeOption {
+	return func(n *IssuerNode) {
+		n.user, n.password = user, password
+	}
+}
+
+// WithSchemaContexts sets the JSON-LD context of the credentials created
+// for a JSON schema URL. Without one a created credential takes the
+// contexts of a stored credential with the same schema.
+func WithSchemaContexts(contexts map[string]string) IssuerNodeOption {
+	return func(n *IssuerNode) {
+		n.schemaContexts = contexts
+	}
+}
+
+// IssuerNode emulates the credential endpoints of an issuer node the refresh
+// service calls: status, get, create and native refresh of credentials,
+// revocation and revocation status. Credentials are kept in memory per
+// issuer DID; created credentials have no proofs.
+type IssuerNode struct {
+	server         *httptest.Server
+	user, password string
+	schemaContexts map[string]string
+
+	mu          sync.Mutex
+	credentials map[string]map[string]*verifiable.W3CCredential
+	requests    map[string][]CreateRequest
+	revoked     map[string]map[uint64]bool
+	failures    []*failureRule
+	nextNonce   uint64
+}
+
+// NewIssuerNode starts an issuer node. It is closed with Close.
+func NewIssuerNode(opts ...IssuerNodeOption) *IssuerNode {
+	n := &IssuerNode{
+		credentials: make(map[string]map[string]*verifiable.W3CCredential),
+		requests:    make(map[string][]CreateRequest),
+		revoked:     make(map[string]map[uint64]bool),
+		nextNonce:   1000,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	router := chi.NewRouter()
+	router.Use(n.authenticate)
+	router.With(n.inject(EndpointStatus)).Get("/status", n.status)
+	router.Route("/v2/identities/{identifier}/credentials", func(r chi.Router) {
+		r.With(n.inject(EndpointCreateCredential)).Post("/", n.createCredential)
+		r.With(n.inject(EndpointGetCredential)).Get("/{id}", n.getCredential)
+		r.With(n.inject(EndpointRefresh)).Post("/{id}/refresh", n.refreshCredential)
+		r.With(n.inject(EndpointRevoke)).Post("/revoke/{nonce}", n.revoke)
+		r.With(n.inject(EndpointRevocationStatus)).Get("/revocation/status/{nonce}", n.revocationStatus)
+	})
+	n.server = httptest.NewServer(router)
+	return n
+}
+
+// URL is the base URL of the issuer node, e.g. for SUPPORTED_ISSUERS.
+func (n *IssuerNode) URL() string {
+	return n.server.URL
+}
+
+// Client returns a client for the issuer node.
+func (n *IssuerNode) Client() *http.Client {
+	return n.server.Client()
+}
+
+func (n *IssuerNode) Close() {
+	n.server.Close()
+}
+
+// AddCredential stores credential under its issuer and id, as if the issuer
+// node had issued it.
+func (n *IssuerNode) AddCredential(credential *verifiable.W3CCredential) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.store(credential.Issuer, credential)
+}
+
+// Credential returns the credential id of the issuer.
+func (n *IssuerNode) Credential(issuerDID, id string) (*verifiable.W3CCredential, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	credential, ok := n.credentials[issuerDID][id]
+	return credential, ok
+}
+
+// Requests returns the creation and native refresh requests the issuer
+// received, in order.
+func (n *IssuerNode) Requests(issuerDID string) []CreateRequest {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]CreateRequest(nil), n.requests[issuerDID]...)
+}
+
+// Revoked tells whether nonce is revoked for the issuer.
+func (n *IssuerNode) Revoked(issuerDID string, nonce uint64) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.revoked[issuerDID][nonce]
+}
+
+// Fail adds a failure. Failures are matched in the order they were added.
+func (n *IssuerNode) Fail(failure Failure) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failures = append(n.failures, &failureRule{Failure: failure})
+}
+
+// ClearFailures removes all failures.
+func (n *IssuerNode) ClearFailures() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failures = nil
+}
+
+func (n *IssuerNode) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.user != "" {
+			user, password, ok := r.BasicAuth()
+			if !ok || user != n.user || password != n.password {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "unauthorized"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (n *IssuerNode) inject(endpoint string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			failure, ok := n.failure(endpoint)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			fail(w, r, failure)
+		})
+	}
+}
+
+// failure takes a failure of endpoint, if there is one left.
+func (n *IssuerNode) failure(endpoint string) (Failure, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return takeFailure(n.failures, func(e string) bool { return e == endpoint })
+}
+
+func (n *IssuerNode) status(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "OK"})
+}
+
+func (n *IssuerNode) getCredential(w http.ResponseWriter, r *http.Request) {
+	credential, ok := n.Credential(pathParam(r, "identifier"), pathParam(r, "id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "credential not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"vc": credential})
+}
+
+func (n *IssuerNode) createCredential(w http.ResponseWriter, r *http.Request) {
+	var request CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.CredentialSchema == "" || request.Type == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid credential request"})
+		return
+	}
+	issuerDID := pathParam(r, "identifier")
+	id := "urn:uuid:" + uuid.NewString()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.requests[issuerDID] = append(n.requests[issuerDID], request)
+	n.store(issuerDID, n.credential(issuerDID, id, request))
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+// refreshCredential replaces the credential in place, keeping its id.
+func (n *IssuerNode) refreshCredential(w http.ResponseWriter, r *http.Request) {
+	var request CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.CredentialSchema == "" || request.Type == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid credential request"})
+		return
+	}
+	issuerDID, id := pathParam(r, "identifier"), pathParam(r, "id")
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.credentials[issuerDID][id]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "credential not found"})
+		return
+	}
+	n.requests[issuerDID] = append(n.requests[issuerDID], request)
+	n.store(issuerDID, n.credential(issuerDID, id, request))
+	writeJSON(w, http.StatusOK, map[string]string{"id": id})
+}
+
+func (n *IssuerNode) revoke(w http.ResponseWriter, r *http.Request) {
+	nonce, err := strconv.ParseUint(pathParam(r, "nonce"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid nonce"})
+		return
+	}
+	issuerDID := pathParam(r, "identifier")
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.revoked[issuerDID] == nil {
+		n.revoked[issuerDID] = make(map[uint64]bool)
+	}
+	n.revoked[issuerDID][nonce] = true
+	writeJSON(w, http.StatusAccepted, map[string]string{"message": "credential revocation request sent"})
+}
+
+func (n *IssuerNode) revocationStatus(w http.ResponseWriter, r *http.Request) {
+	nonce, err := strconv.ParseUint(pathParam(r, "nonce"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid nonce"})
+		return
+	}
+	revoked := n.Revoked(pathParam(r, "identifier"), nonce)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer": map[string]interface{}{},
+		"mtp":    map[string]interface{}{"existence": revoked, "siblings": []string{}},
+	})
+}
+
+func (n *IssuerNode) store(issuerDID string, credential *verifiable.W3CCredential) {
+	if n.credentials[issuerDID] == nil {
+		n.credentials[issuerDID] = make(map[string]*verifiable.W3CCredential)
+	}
+	n.credentials[issuerDID][credential.ID] = credential
+}
+
+// credential builds the credential the issuer node would issue for request.
+func (n *IssuerNode) credential(issuerDID, id string, request CreateRequest) *verifiable.W3CCredential {
+	nonce := n.nextNonce
+	if request.RevNonce != nil {
+		nonce = *request.RevNonce
+	} else {
+		n.nextNonce++
+	}
+	statusType := verifiable.CredentialStatusType(request.CredentialStatusType)
+	if statusType == "" {
+		statusType = verifiable.Iden3commRevocationStatusV1
+	}
+	subject := make(map[string]interface{}, len(request.CredentialSubject)+1)
+	for k, v := range request.CredentialSubject {
+		subject[k] = v
+	}
+	subject["type"] = request.Type
+
+	issuedAt := time.Now().UTC().Truncate(time.Second)
+	switch {
+	case request.IssuanceDate != nil:
+		issuedAt = request.IssuanceDate.UTC()
+	case request.ValidFrom != nil:
+		issuedAt = request.ValidFrom.UTC()
+	}
+	credential := &verifiable.W3CCredential{
+		ID:                id,
+		Context:           n.contexts(request.CredentialSchema),
+		Type:              []string{verifiable.TypeW3CVerifiableCredential, request.Type},
+		Issuer:            issuerDID,
+		IssuanceDate:      &issuedAt,
+		CredentialSubject: subject,
+		CredentialStatus: verifiable.CredentialStatus{
+			ID:              n.server.URL + "/v2/agent",
+			Type:            statusType,
+			RevocationNonce: nonce,
+		},
+		CredentialSchema: verifiable.CredentialSchema{
+			ID:   request.CredentialSchema,
+			Type: verifiable.JSONSchema2023,
+		},
+		RefreshService: request.RefreshService,
+		DisplayMethod:  request.DisplayMethod,
+	}
+	if request.Expiration != 0 {
+		expiration := time.Unix(request.Expiration, 0).UTC()
+		credential.Expiration = &expiration
+	}
+	return credential
+}
+
+func (n *IssuerNode) contexts(schema string) []string {
+	if ldContext, ok := n.schemaContexts[schema]; ok {
+		return []string{w3cCredentialContext, verifiable.JSONLDSchemaIden3Credential, ldContext}
+	}
+	for _, credentials := range n.credentials {
+		for _, credential := range credentials {
+			if credential.CredentialSchema.ID == schema {
+				return append([]string(nil), credential.Context...)
+			}
+		}
+	}
+	return []string{w3cCredentialContext, verifiable.JSONLDSchemaIden3Credential}
+}
+
+// failureRule is a Failure with the requests it failed.
+type failureRule struct {
+	Failure
+	used int
+}
+
+// takeFailure returns the first failure whose endpoint matches which is not
+// spent and counts it.
+func takeFailure(failures []*failureRule, match func(endpoint string) bool) (Failure, bool) {
+	for _, rule := range failures {
+		if rule.Endpoint != "" && !match(rule.Endpoint) {
+			continue
+		}
+		if rule.Times > 0 && rule.used >= rule.Times {
+			continue
+		}
+		rule.used++
+		return rule.Failure, true
+	}
+	return Failure{}, false
+}
+
+// fail answers r as failure says.
+func fail(w http.ResponseWriter, r *http.Request, failure Failure) {
+	if failure.Latency > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(failure.Latency):
+		}
+	}
+	if failure.Status == 0 {
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				_ = conn.Close()
+				return
+			}
+		}
+		failure.Status = http.StatusBadGateway
+	}
+	writeJSON(w, failure.Status, map[string]string{"message": http.StatusText(failure.Status)})
+}
+
+func pathParam(r *http.Request, name string) string {
+	value := chi.URLParam(r, name)
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,116 @@
+package testsupport
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/issuercontract"
+	"github.com/0xPolygonID/refresh-service/service"
+	"github.com/iden3/go-schema-processor/v2/verifiable"
+	"github.com/stretchr/testify/require"
+)
+
+const issuerDID = "did:iden3:polygon:amoy:x6x5sor7zpyT5mmpg4fADaKEbf6ZuuU9y5bgaiWDLa"
+
+func sampleCredential() *verifiable.W3CCredential {
+	expiration := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	return &verifiable.W3CCredential{
+		ID:         "urn:uuid:sample",
+		Context:    []string{w3cCredentialContext, verifiable.JSONLDSchemaIden3Credential, "https://example.com/balance.jsonld"},
+		Type:       []string{verifiable.TypeW3CVerifiableCredential, "Balance"},
+		Issuer:     issuerDID,
+		Expiration: &expiration,
+		CredentialSubject: map[string]interface{}{
+			"id":      "did:iden3:owner",
+			"type":    "Balance",
+			"balance": float64(10),
+		},
+		CredentialStatus: verifiable.CredentialStatus{
+			ID:              "https://issuer/status",
+			Type:            verifiable.Iden3commRevocationStatusV1,
+			RevocationNonce: 1,
+		},
+		CredentialSchema: verifiable.CredentialSchema{ID: "https://example.com/balance.json", Type: verifiable.JSONSchema2023},
+	}
+}
+
+func TestIssuerNode(t *testing.T) {
+	node := NewIssuerNode(WithIssuerBasicAuth("user", "pass"))
+	defer node.Close()
+	node.AddCredential(sampleCredential())
+	ctx := context.Background()
+
+	is := service.NewIssuerService(map[string]string{"*": node.URL()}, map[string]string{"*": "user:pass"}, node.Client())
+	require.NoError(t, is.Ping(ctx, issuerDID))
+
+	sample, err := is.GetClaimByID(ctx, issuerDID, "urn:uuid:sample")
+	require.NoError(t, err)
+	require.Equal(t, "Balance", sample.CredentialSubject["type"])
+
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	id, err := is.CopyCredential(ctx, issuerDID, sample, expiration)
+	require.NoError(t, err)
+	copied, err := is.GetClaimByID(ctx, issuerDID, id)
+	require.NoError(t, err)
+	require.Equal(t, sample.Context, copied.Context)
+	require.Equal(t, sample.CredentialSubject, copied.CredentialSubject)
+	require.Equal(t, expiration, *copied.Expiration)
+	require.Len(t, node.Requests(issuerDID), 1)
+	require.Equal(t, "Balance", node.Requests(issuerDID)[0].Type)
+
+	nonce, err := service.RevocationNonce(copied)
+	require.NoError(t, err)
+	require.NotEqual(t, uint64(1), nonce)
+	require.NoError(t, is.RevokeCredential(ctx, issuerDID, nonce))
+	revoked, err := is.RevocationStatus(ctx, issuerDID, nonce)
+	require.NoError(t, err)
+	require.True(t, revoked)
+	require.True(t, node.Revoked(issuerDID, nonce))
+
+	_, err = is.GetClaimByID(ctx, issuerDID, "urn:uuid:unknown")
+	require.ErrorIs(t, err, service.ErrGetClaim)
+	unauthenticated := service.NewIssuerService(map[string]string{"*": node.URL()}, nil, node.Client())
+	_, err = unauthenticated.GetClaimByID(ctx, issuerDID, "urn:uuid:sample")
+	require.ErrorContains(t, err, "'401'")
+}
+
+func TestIssuerNode_Failures(t *testing.T) {
+	node := NewIssuerNode()
+	defer node.Close()
+	node.AddCredential(sampleCredential())
+	is := service.NewIssuerService(map[string]string{"*": node.URL()}, nil, node.Client())
+	ctx := context.Background()
+
+	node.Fail(Failure{Endpoint: EndpointGetCredential, Status: http.StatusServiceUnavailable, Times: 1})
+	_, err := is.GetClaimByID(ctx, issuerDID, "urn:uuid:sample")
+	require.ErrorContains(t, err, "'503'")
+	_, err = is.GetClaimByID(ctx, issuerDID, "urn:uuid:sample")
+	require.NoError(t, err)
+
+	node.Fail(Failure{Endpoint: EndpointStatus})
+	require.ErrorContains(t, is.Ping(ctx, issuerDID), "failed http GET request")
+	require.ErrorContains(t, is.Ping(ctx, issuerDID), "failed http GET request")
+
+	node.ClearFailures()
+	node.Fail(Failure{Status: http.StatusTooManyRequests, Latency: 20 * time.Millisecond, Times: 1})
+	start := time.Now()
+	require.ErrorContains(t, is.Ping(ctx, issuerDID), "'429'")
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	require.NoError(t, is.Ping(ctx, issuerDID))
+}
+
+func TestIssuerNode_Contract(t *testing.T) {
+	node := NewIssuerNode()
+	defer node.Close()
+	node.AddCredential(sampleCredential())
+	is := service.NewIssuerService(map[string]string{"*": node.URL()}, nil, node.Client())
+
+	report := issuercontract.Run(context.Background(), is, issuercontract.Options{
+		IssuerDID:    issuerDID,
+		CredentialID: "urn:uuid:sample",
+		AsyncTimeout: time.Second,
+	})
+	require.True(t, report.Compatible, "%+v", report.Results)
+}
@@ -0,0 +1,261 @@
+package testsupport
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Response is a canned data provider response to the requests matching
+// Method, any method when empty, Path, a path.Match pattern or any path when
+// empty, and Query, query params the request must have. It is sent after
+// Latency with Status, 200 by default, and Body: a string is sent as it is,
+// any other value as JSON.
+type Response struct {
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Query   map[string]string `yaml:"query"`
+	Status  int               `yaml:"status"`
+	Latency time.Duration     `yaml:"latency"`
+	Headers map[string]string `yaml:"headers"`
+	Body    interface{}       `yaml:"body"`
+}
+
+// ProviderRequest is a request the data provider received.
+type ProviderRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+type ProviderOption func(*Provider)
+
+// WithResponses adds canned responses.
+func WithResponses(responses ...Response) ProviderOption {
+	return func(p *Provider) {
+		p.responses = append(p.responses, responses...)
+	}
+}
+
+// Provider is a data provider answering with canned responses. Responses and
+// failures are matched in the order they were added, failures first; the
+// Endpoint of a Failure is a path pattern as the Path of a Response. Requests
+// matching no response get 404.
+type Provider struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	responses []Response
+	failures  []*failureRule
+	requests  []ProviderRequest
+}
+
+// NewProvider starts a data provider. It is closed with Close.
+func NewProvider(opts ...ProviderOption) *Provider {
+	p := &Provider{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.server = httptest.NewServer(http.HandlerFunc(p.serve))
+	return p
+}
+
+// providerMock is the file format of Load.
+type providerMock struct {
+	Responses []Response `yaml:"responses"`
+	Failures  []Failure  `yaml:"failures"`
+}
+
+// Load adds the responses and failures of a YAML file:
+//
+//	responses:
+//	  - path: /balance/*
+//	    latency: 100ms
+//	    body: {"balance": 100}
+//	failures:
+//	  - endpoint: /balance/*
+//	    status: 503
+//	    times: 1
+func (p *Provider) Load(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return errors.Errorf("failed to read provider mock: %v", err)
+	}
+	var mock providerMock
+	if err := yaml.Unmarshal(data, &mock); err != nil {
+		return errors.Errorf("failed to parse provider mock: %v", err)
+	}
+	for i, response := range mock.Responses {
+		if _, err := path.Match(response.Path, "/"); err != nil {
+			return errors.Errorf("response %d: invalid path '%s': %v", i, response.Path, err)
+		}
+	}
+	for i, failure := range mock.Failures {
+		if _, err := path.Match(failure.Endpoint, "/"); err != nil {
+			return errors.Errorf("failure %d: invalid endpoint '%s': %v", i, failure.Endpoint, err)
+		}
+	}
+	for _, response := range mock.Responses {
+		p.Respond(response)
+	}
+	for _, failure := range mock.Failures {
+		p.Fail(failure)
+	}
+	return nil
+}
+
+// URL is the base URL of the data provider.
+func (p *Provider) URL() string {
+	return p.server.URL
+}
+
+// Client returns a client sending every request to the data provider,
+// whatever its scheme and host, so a provider configuration with the URLs of
+// the real provider can be run against it unchanged.
+func (p *Provider) Client() *http.Client {
+	target, _ := url.Parse(p.server.URL)
+	client := p.server.Client()
+	client.Transport = redirectTransport{target: target, next: client.Transport}
+	return client
+}
+
+func (p *Provider) Close() {
+	p.server.Close()
+}
+
+// Respond adds a canned response.
+func (p *Provider) Respond(response Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses = append(p.responses, response)
+}
+
+// Requests returns the requests the data provider received, in order.
+func (p *Provider) Requests() []ProviderRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ProviderRequest(nil), p.requests...)
+}
+
+// Fail adds a failure.
+func (p *Provider) Fail(failure Failure) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures = append(p.failures, &failureRule{Failure: failure})
+}
+
+// ClearFailures removes all failures.
+func (p *Provider) ClearFailures() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures = nil
+}
+
+func (p *Provider) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	p.mu.Lock()
+	p.requests = append(p.requests, ProviderRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	failure, failed := takeFailure(p.failures, func(endpoint string) bool {
+		ok, _ := path.Match(endpoint, r.URL.Path)
+		return ok
+	})
+	response, found := p.response(r)
+	p.mu.Unlock()
+
+	switch {
+	case failed:
+		fail(w, r, failure)
+	case !found:
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "no canned response"})
+	default:
+		respond(w, r, response)
+	}
+}
+
+// response returns the first response matching r.
+func (p *Provider) response(r *http.Request) (Response, bool) {
+	query := r.URL.Query()
+	for _, response := range p.responses {
+		if response.Method != "" && !strings.EqualFold(response.Method, r.Method) {
+			continue
+		}
+		if ok, _ := path.Match(response.Path, r.URL.Path); response.Path != "" && !ok {
+			continue
+		}
+		matched := true
+		for key, value := range response.Query {
+			matched = matched && query.Get(key) == value
+		}
+		if matched {
+			return response, true
+		}
+	}
+	return Response{}, false
+}
+
+func respond(w http.ResponseWriter, r *http.Request, response Response) {
+	if response.Latency > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(response.Latency):
+		}
+	}
+	var body []byte
+	switch v := response.Body.(type) {
+	case nil:
+	case string:
+		body = []byte(v)
+	default:
+		var err error
+		if body, err = json.Marshal(v); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"message": err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+	}
+	for key, value := range response.Headers {
+		w.Header().Set(key, value)
+	}
+	status := response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// redirectTransport sends requests to target in place of their host.
+type redirectTransport struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	req.Host = t.target.Host
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(req)
+}
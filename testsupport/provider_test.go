@@ -0,0 +1,208 @@
+package testsupport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xPolygonID/refresh-service/providers/flexiblehttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	provider := NewProvider(
+		WithResponses(
+			Response{Method: http.MethodGet, Path: "/balance/*", Query: map[string]string{"chain": "80002"}, Body: map[string]interface{}{"balance": 100}},
+			Response{Method: http.MethodGet, Path: "/balance/*", Body: map[string]interface{}{"balance": 1}},
+			Response{Method: http.MethodPost, Path: "/kyc", Status: http.StatusAccepted, Headers: map[string]string{"Content-Type": "text/csv"}, Body: "age\n30\n"},
+		),
+	)
+	defer provider.Close()
+
+	tests := []struct {
+		name                string
+		method              string
+		url                 string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "Query match",
+			method:              http.MethodGet,
+			url:                 "/balance/0x1?chain=80002",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"balance":100}`,
+		},
+		{
+			name:                "First match",
+			method:              http.MethodGet,
+			url:                 "/balance/0x1",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `{"balance":1}`,
+		},
+		{
+			name:                "Raw body",
+			method:              http.MethodPost,
+			url:                 "/kyc",
+			expectedStatus:      http.StatusAccepted,
+			expectedContentType: "text/csv",
+			expectedBody:        "age\n30\n",
+		},
+		{
+			name:                "Other method",
+			method:              http.MethodPost,
+			url:                 "/balance/0x1",
+			expectedStatus:      http.StatusNotFound,
+			expectedContentType: "application/json",
+			expectedBody:        `{"message":"no canned response"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, provider.URL()+tt.url, nil)
+			require.NoError(t, err)
+			resp, err := provider.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+			require.Equal(t, tt.expectedContentType, resp.Header.Get("Content-Type"))
+			require.Equal(t, tt.expectedBody, string(body))
+		})
+	}
+
+	requests := provider.Requests()
+	require.Len(t, requests, len(tests))
+	require.Equal(t, "/balance/0x1", requests[0].Path)
+	require.Equal(t, "80002", requests[0].Query.Get("chain"))
+}
+
+func TestProvider_Failures(t *testing.T) {
+	provider := NewProvider(WithResponses(Response{Body: map[string]interface{}{"balance": 1}}))
+	defer provider.Close()
+	provider.Fail(Failure{Endpoint: "/balance/*", Status: http.StatusServiceUnavailable, Times: 1})
+	provider.Fail(Failure{Endpoint: "/closed"})
+
+	get := func(path string) (*http.Response, error) {
+		resp, err := provider.Client().Get("https://api.example.com" + path)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return resp, err
+	}
+	resp, err := get("/balance/0x1")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp, err = get("/balance/0x1")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = get("/closed")
+	require.Error(t, err)
+	provider.ClearFailures()
+	resp, err = get("/closed")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestProvider_Load(t *testing.T) {
+	tests := []struct {
+		name        string
+		mock        string
+		expectedErr string
+	}{
+		{
+			name: "Responses and failures",
+			mock: `
+responses:
+  - path: /balance/*
+    latency: 10ms
+    body: {"balance": 100}
+failures:
+  - endpoint: /balance/*
+    status: 503
+    times: 1
+`,
+		},
+		{
+			name:        "Invalid path",
+			mock:        "responses:\n  - path: '/balance/['\n",
+			expectedErr: "response 0: invalid path '/balance/['",
+		},
+		{
+			name:        "Invalid YAML",
+			mock:        "responses: {",
+			expectedErr: "failed to parse provider mock",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "mock.yaml")
+			require.NoError(t, os.WriteFile(file, []byte(tt.mock), 0o600))
+			provider := NewProvider()
+			defer provider.Close()
+
+			err := provider.Load(file)
+			if tt.expectedErr != "" {
+				require.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			resp, err := provider.Client().Get(provider.URL() + "/balance/0x1")
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+			start := time.Now()
+			resp, err = provider.Client().Get(provider.URL() + "/balance/0x1")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, `{"balance":100}`, string(body))
+			require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+		})
+	}
+}
+
+func TestProvider_FlexibleHTTP(t *testing.T) {
+	provider := NewProvider(WithResponses(Response{
+		Path: "/balance/did:iden3:owner",
+		Body: map[string]interface{}{"result": map[string]interface{}{"balance": 150}},
+	}))
+	defer provider.Close()
+
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(strings.TrimSpace(`
+Balance:
+  provider:
+    url: https://api.example.com/balance/{{ credentialSubject.id }}
+  responseSchema:
+    properties:
+      result.balance:
+        type: integer
+        match: credentialSubject.balance
+`)), 0o600))
+	factory, err := flexiblehttp.NewFactoryFlexibleHTTP(config, provider.Client())
+	require.NoError(t, err)
+	fh, err := factory.ProduceFlexibleHTTP("Balance")
+	require.NoError(t, err)
+
+	fields, err := fh.Provide(context.Background(), map[string]interface{}{"id": "did:iden3:owner", "balance": 10})
+	require.NoError(t, err)
+	require.EqualValues(t, 150, fields["balance"])
+
+	provider.Fail(Failure{Status: http.StatusInternalServerError, Times: 1})
+	_, err = fh.Provide(context.Background(), map[string]interface{}{"id": "did:iden3:owner", "balance": 10})
+	require.Error(t, err)
+	require.Len(t, provider.Requests(), 2)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"strings"
+
+	"github.com/iden3/go-schema-processor/v2/merklize"
+	"github.com/piprate/json-gold/ld"
+	"github.com/pkg/errors"
+)
+
+// verifySchemas resolves the JSON-LD schema of every credential type in the
+// provider configuration, with the contexts it imports, through the
+// document loader the service uses.
+func verifySchemas(args []string) error {
+	fs := flag.NewFlagSet("verify-schemas", flag.ContinueOnError)
+	configPath := fs.String("config", "", "provider configuration, HTTP_CONFIG_PATH by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCommandConfig()
+	if err != nil {
+		return err
+	}
+	if *configPath != "" {
+		cfg.HTTPConfigPath = *configPath
+	}
+	providers, err := cfg.providerFactory(nil)
+	if err != nil {
+		return errors.Errorf("failed init flexiblehttp: %v", err)
+	}
+	documentLoader, _, err := initDocumentLoaderWithCache(cfg.IPFSGWURL, nil, cfg.schemaRegistry())
+	if err != nil {
+		return errors.Errorf("failed init document loader: %v", err)
+	}
+
+	var failed int
+	for _, credentialType := range providers.CredentialTypes() {
+		problems := verifyCredentialType(documentLoader, credentialType)
+		if len(problems) == 0 {
+			printf("ok    %s\n", credentialType)
+			continue
+		}
+		failed++
+		printf("FAIL  %s\n", credentialType)
+		for _, problem := range problems {
+			printf("      - %v\n", problem)
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d credential type(s) failed to resolve", failed)
+	}
+	return nil
+}
+
+// verifyCredentialType checks that credentialType, a JSON-LD type id in the
+// form <schema url>#<type>, resolves to itself.
+func verifyCredentialType(documentLoader ld.DocumentLoader, credentialType string) []error {
+	schemaURL, typeName, ok := strings.Cut(credentialType, "#")
+	if !ok || schemaURL == "" || typeName == "" {
+		return []error{errors.New("not a JSON-LD type id '<schema url>#<type>'")}
+	}
+
+	document, err := documentLoader.LoadDocument(schemaURL)
+	if err != nil {
+		return []error{errors.Errorf("schema '%s' is unreachable: %v", schemaURL, err)}
+	}
+	schema, ok := document.Document.(map[string]interface{})
+	if !ok {
+		return []error{errors.Errorf("schema '%s' is not a JSON object", schemaURL)}
+	}
+	ldContext, ok := schema["@context"]
+	if !ok {
+		return []error{errors.Errorf("schema '%s' has no @context", schemaURL)}
+	}
+
+	var problems []error
+	entries, ok := ldContext.([]interface{})
+	if !ok {
+		entries = []interface{}{ldContext}
+	}
+	for _, entry := range entries {
+		// imported contexts are referenced by url
+		contextURL, ok := entry.(string)
+		if !ok {
+			continue
+		}
+		if _, err := documentLoader.LoadDocument(contextURL); err != nil {
+			problems = append(problems, errors.Errorf("context '%s' is unreachable: %v", contextURL, err))
+		}
+	}
+
+	credential, err := json.Marshal(map[string]interface{}{"@context": []string{schemaURL}})
+	if err != nil {
+		return append(problems, err)
+	}
+	resolved, err := merklize.Options{DocumentLoader: documentLoader}.TypeIDFromContext(credential, typeName)
+	switch {
+	case err != nil:
+		problems = append(problems, errors.Errorf("type '%s' does not resolve: %v", typeName, err))
+	case resolved != credentialType:
+		problems = append(problems, errors.Errorf("type '%s' resolves to '%s'", typeName, resolved))
+	}
+	return problems
+}